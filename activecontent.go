@@ -0,0 +1,171 @@
+package parsemail
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	FindingScript        FindingCode = "script"
+	FindingEventHandler  FindingCode = "event-handler"
+	FindingForeignObject FindingCode = "foreign-object"
+)
+
+// markupContentTypes and markupExtensions identify svg and html files
+var (
+	markupContentTypes = []string{"image/svg+xml", "text/html", "application/xhtml+xml", "text/xml", "application/xml"}
+	markupExtensions   = []string{".svg", ".htm", ".html", ".xhtml", ".shtml", ".mht", ".xht"}
+)
+
+// CheckActiveContent reports the active content of an svg or html
+// attachment: script elements and javascript URLs, event handler attributes
+// and svg foreignObject elements, which embed html into images. Files are
+// recognized by their content as well, so a renamed file is still checked.
+func (a *Attachment) CheckActiveContent() ([]Finding, error) {
+	data, err := a.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMarkupFile(a.Filename, a.ContentType, data) {
+		return nil, nil
+	}
+
+	return markupFindings("attachment", a.Filename, data), nil
+}
+
+// CheckActiveContent reports the active content of an svg or html embedded
+// file, see Attachment.CheckActiveContent
+func (e *EmbeddedFile) CheckActiveContent() ([]Finding, error) {
+	data, err := e.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	name := e.Filename
+	if name == "" {
+		name = e.CID
+	}
+
+	if !isMarkupFile(name, e.ContentType, data) {
+		return nil, nil
+	}
+
+	return markupFindings("embedded file", name, data), nil
+}
+
+// CheckActiveContentFiles runs CheckActiveContent on all attachments and
+// embedded files, skipping files that can't be read
+func (e *Email) CheckActiveContentFiles() (findings []Finding) {
+	for i := range e.Attachments {
+		f, _ := e.Attachments[i].CheckActiveContent()
+		findings = append(findings, f...)
+	}
+
+	for i := range e.EmbeddedFiles {
+		f, _ := e.EmbeddedFiles[i].CheckActiveContent()
+		findings = append(findings, f...)
+	}
+
+	return
+}
+
+// isMarkupFile reports whether a file is svg or html by its content type,
+// extension or beginning
+func isMarkupFile(filename, contentType string, data []byte) bool {
+	if matchContentType(contentType, markupContentTypes) {
+		return true
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, e := range markupExtensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	head := bytes.ToLower(bytes.TrimLeft(bytes.TrimPrefix(data[:minInt(len(data), 1024)], []byte("\xef\xbb\xbf")), " \t\r\n"))
+	for _, prefix := range []string{"<svg", "<html", "<!doctype html", "<script", "<head", "<body"} {
+		if bytes.HasPrefix(head, []byte(prefix)) {
+			return true
+		}
+	}
+
+	return bytes.HasPrefix(head, []byte("<?xml")) && bytes.Contains(head, []byte("<svg"))
+}
+
+// markupFindings scans svg or html for active content
+func markupFindings(kind, name string, data []byte) (findings []Finding) {
+	scripts, handlers, foreign := 0, map[string]bool{}, 0
+
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		tag, hasAttr := z.TagName()
+		switch string(tag) {
+		case "script":
+			scripts++
+		case "foreignobject":
+			foreign++
+		}
+
+		for hasAttr {
+			var key, val []byte
+			key, val, hasAttr = z.TagAttr()
+
+			attr := string(key)
+			if strings.HasPrefix(attr, "on") && len(attr) > 2 {
+				handlers[attr] = true
+			}
+
+			// javascript URLs in links and references, "xlink:href" included
+			if strings.HasSuffix(attr, "href") || attr == "src" || attr == "action" || attr == "formaction" {
+				v := strings.ToLower(strings.Join(strings.Fields(string(val)), ""))
+				if strings.HasPrefix(v, "javascript:") {
+					scripts++
+				}
+			}
+		}
+	}
+
+	if scripts > 0 {
+		findings = append(findings, Finding{
+			Code:    FindingScript,
+			Message: fmt.Sprintf("%s %q contains %d scripts", kind, name, scripts),
+		})
+	}
+
+	if len(handlers) > 0 {
+		var names []string
+		for h := range handlers {
+			names = append(names, h)
+		}
+		sort.Strings(names)
+
+		findings = append(findings, Finding{
+			Code:    FindingEventHandler,
+			Message: fmt.Sprintf("%s %q contains event handlers %s", kind, name, strings.Join(names, ", ")),
+		})
+	}
+
+	if foreign > 0 {
+		findings = append(findings, Finding{
+			Code:    FindingForeignObject,
+			Message: fmt.Sprintf("%s %q embeds html in %d foreignObject elements", kind, name, foreign),
+		})
+	}
+
+	return
+}
@@ -0,0 +1,66 @@
+package parsemail
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCheckActiveContent(t *testing.T) {
+	var testData = map[int]struct {
+		filename    string
+		contentType string
+		data        string
+		codes       []string
+	}{
+		1: {
+			filename:    "logo.svg",
+			contentType: "image/svg+xml",
+			data:        `<svg xmlns="http://www.w3.org/2000/svg"><circle r="4"/></svg>`,
+		},
+		2: {
+			filename:    "invoice.svg",
+			contentType: "image/svg+xml",
+			data:        `<svg xmlns="http://www.w3.org/2000/svg" onload="location='https://evil.example'"><script>alert(1)</script></svg>`,
+			codes:       []string{string(FindingScript), string(FindingEventHandler)},
+		},
+		3: {
+			filename:    "scan.png",
+			contentType: "application/octet-stream",
+			data:        "\xef\xbb\xbf<?xml version=\"1.0\"?>\n<svg><foreignObject><body><form action=\"https://evil.example\"></form></body></foreignObject></svg>",
+			codes:       []string{string(FindingForeignObject)},
+		},
+		4: {
+			filename:    "message.htm",
+			contentType: "application/octet-stream",
+			data:        `<p><a href=" JavaScript:void(0)">Open</a><img src="x" onerror="run()"></p>`,
+			codes:       []string{string(FindingScript), string(FindingEventHandler)},
+		},
+		5: {
+			filename:    "notes.txt",
+			contentType: "text/plain",
+			data:        `call onload() in <script> to start`,
+		},
+	}
+
+	for index, td := range testData {
+		e := Email{Attachments: []Attachment{{Filename: td.filename, ContentType: td.contentType, Data: bytes.NewReader([]byte(td.data))}}}
+
+		var codes []string
+		for _, f := range e.CheckActiveContentFiles() {
+			codes = append(codes, string(f.Code))
+		}
+
+		if !assertSliceEq(codes, td.codes) {
+			t.Errorf("[Test Case %v] Wrong findings. Expected: %s, Got: %s", index, td.codes, codes)
+		}
+	}
+}
+
+func TestCheckActiveContentEmbedded(t *testing.T) {
+	e := Email{EmbeddedFiles: []EmbeddedFile{{CID: "logo@example", ContentType: "image/svg+xml", Data: bytes.NewReader([]byte(`<svg><a xlink:href="javascript:run()"/></svg>`))}}}
+
+	findings := e.CheckActiveContentFiles()
+	if len(findings) != 1 || findings[0].Code != FindingScript {
+		t.Errorf("Wrong findings. Expected a script, Got: %v", findings)
+	}
+}
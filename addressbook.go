@@ -0,0 +1,122 @@
+package parsemail
+
+import (
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Contact is an entry of the AddressBook frequency table
+type Contact struct {
+	Address     string
+	Names       []string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	AsSender    int
+	AsRecipient int
+}
+
+// Count returns how many times the contact was seen in any role
+func (c Contact) Count() int {
+	return c.AsSender + c.AsRecipient
+}
+
+// AddressBook aggregates the addresses of many parsed emails into a contact
+// frequency table. The zero value is not usable, create it with NewAddressBook.
+type AddressBook struct {
+	contacts map[string]*Contact
+}
+
+// NewAddressBook returns an empty AddressBook
+func NewAddressBook() *AddressBook {
+	return &AddressBook{contacts: map[string]*Contact{}}
+}
+
+// Add records the senders (From, Sender, Reply-To) and recipients (To, Cc, Bcc)
+// of the email.
+func (ab *AddressBook) Add(e *Email) {
+	seen := map[string]bool{}
+
+	senders := append([]*mail.Address{e.Sender}, e.From...)
+	senders = append(senders, e.ReplyTo...)
+	for _, a := range senders {
+		ab.add(a, e.Date, true, seen)
+	}
+
+	for _, list := range [][]*mail.Address{e.To, e.Cc, e.Bcc} {
+		for _, a := range list {
+			ab.add(a, e.Date, false, seen)
+		}
+	}
+}
+
+func (ab *AddressBook) add(a *mail.Address, date time.Time, sender bool, seen map[string]bool) {
+	if a == nil || a.Address == "" {
+		return
+	}
+
+	key := strings.ToLower(a.Address)
+	c, ok := ab.contacts[key]
+	if !ok {
+		c = &Contact{Address: key}
+		ab.contacts[key] = c
+	}
+
+	if a.Name != "" && !containsString(c.Names, a.Name) {
+		c.Names = append(c.Names, a.Name)
+	}
+
+	if !date.IsZero() {
+		if c.FirstSeen.IsZero() || date.Before(c.FirstSeen) {
+			c.FirstSeen = date
+		}
+		if date.After(c.LastSeen) {
+			c.LastSeen = date
+		}
+	}
+
+	// count every address once per role and message
+	role := "r:" + key
+	if sender {
+		role = "s:" + key
+	}
+	if seen[role] {
+		return
+	}
+	seen[role] = true
+
+	if sender {
+		c.AsSender++
+	} else {
+		c.AsRecipient++
+	}
+}
+
+// Contacts returns the collected contacts, most frequent first
+func (ab *AddressBook) Contacts() []Contact {
+	result := make([]Contact, 0, len(ab.contacts))
+	for _, c := range ab.contacts {
+		result = append(result, *c)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count() != result[j].Count() {
+			return result[i].Count() > result[j].Count()
+		}
+
+		return result[i].Address < result[j].Address
+	})
+
+	return result
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddressBook(t *testing.T) {
+	ab := NewAddressBook()
+	for _, data := range []string{rfc5322exampleA11, rfc5322exampleA2a, rfc5322exampleA2b} {
+		e, err := Parse(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ab.Add(&e)
+	}
+
+	var testData = map[int]struct {
+		address     string
+		names       []string
+		asSender    int
+		asRecipient int
+	}{
+		1: {
+			address:     "jdoe@machine.example",
+			names:       []string{"John Doe"},
+			asSender:    2,
+			asRecipient: 1,
+		},
+		2: {
+			address:     "mary@example.net",
+			names:       []string{"Mary Smith"},
+			asSender:    1,
+			asRecipient: 1,
+		},
+		3: {
+			address:     "smith@home.example",
+			names:       []string{"Mary Smith: Personal Account"},
+			asSender:    1,
+			asRecipient: 1,
+		},
+		4: {
+			address:  "mjones@machine.example",
+			names:    []string{"Michael Jones"},
+			asSender: 1,
+		},
+	}
+
+	contacts := ab.Contacts()
+	if len(contacts) != len(testData) {
+		t.Fatalf("Wrong number of contacts. Expected: %v, Got: %v", len(testData), len(contacts))
+	}
+
+	if contacts[0].Address != "jdoe@machine.example" {
+		t.Errorf("Wrong most frequent contact. Expected: jdoe@machine.example, Got: %s", contacts[0].Address)
+	}
+
+	for index, td := range testData {
+		var c *Contact
+		for i := range contacts {
+			if contacts[i].Address == td.address {
+				c = &contacts[i]
+			}
+		}
+
+		if c == nil {
+			t.Errorf("[Test Case %v] Contact not found: %s", index, td.address)
+			continue
+		}
+
+		if !assertSliceEq(td.names, c.Names) {
+			t.Errorf("[Test Case %v] Wrong names. Expected: %s, Got: %s", index, td.names, c.Names)
+		}
+
+		if td.asSender != c.AsSender || td.asRecipient != c.AsRecipient {
+			t.Errorf("[Test Case %v] Wrong counts. Expected: %v/%v, Got: %v/%v", index, td.asSender, td.asRecipient, c.AsSender, c.AsRecipient)
+		}
+	}
+
+	jdoe := contacts[0]
+	if !jdoe.FirstSeen.Equal(parseDate("Fri, 21 Nov 1997 09:55:06 -0600")) || !jdoe.LastSeen.Equal(parseDate("Fri, 21 Nov 1997 11:00:00 -0600")) {
+		t.Errorf("Wrong first/last seen: %v %v", jdoe.FirstSeen, jdoe.LastSeen)
+	}
+}
@@ -0,0 +1,128 @@
+package parsemail
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// AddressGroup is a named group of addresses (RFC 5322 section 3.4). Name is
+// empty for addresses outside of a group.
+type AddressGroup struct {
+	Name      string
+	Addresses []*mail.Address
+}
+
+// AddressGroups returns the addresses of the first header field called name,
+// like To or Cc, keeping the groups they were listed in. Consecutive
+// addresses outside of groups form a group without name, empty groups like
+// "undisclosed-recipients:;" are kept.
+func (e *Email) AddressGroups(name string) []AddressGroup {
+	value, _, ok := e.rawAddressField(name)
+	if !ok {
+		return nil
+	}
+
+	var groups []AddressGroup
+	inGroup := false
+	for _, tok := range addressTokens(value) {
+		text := UnfoldHeader(value[tok.start:tok.end])
+
+		switch tok.kind {
+		case groupStartToken:
+			groups = append(groups, AddressGroup{Name: decodeMimeSentenceVersion(unquotePhrase(strings.TrimSpace(text)), e.SchemaVersion)})
+			inGroup = true
+		case groupEndToken:
+			inGroup = false
+		default:
+			a, err := mail.ParseAddress(text)
+			if err != nil {
+				continue
+			}
+			if !inGroup && (len(groups) == 0 || groups[len(groups)-1].Name != "") {
+				groups = append(groups, AddressGroup{})
+			}
+			g := &groups[len(groups)-1]
+			g.Addresses = append(g.Addresses, a)
+		}
+	}
+
+	return groups
+}
+
+// FormatAddressList renders groups as the value of an address header field,
+// quoting names where needed and encoding non-ASCII names as encoded-words
+func FormatAddressList(groups []AddressGroup) string {
+	return formatAddressGroups(groups, formatAddress, EncodePhrase)
+}
+
+// formatAddress renders an address for a header field
+func formatAddress(a *mail.Address) string {
+	addr := (&mail.Address{Address: a.Address}).String()
+	if a.Name == "" {
+		return addr
+	}
+
+	return EncodePhrase(a.Name) + " " + addr
+}
+
+// DisplayAddressList renders groups for display. Names are quoted where they
+// would be ambiguous but not encoded.
+func DisplayAddressList(groups []AddressGroup) string {
+	return formatAddressGroups(groups, func(a *mail.Address) string {
+		if a.Name == "" {
+			return a.Address
+		}
+		return quotePhrase(a.Name) + " <" + a.Address + ">"
+	}, quotePhrase)
+}
+
+func formatAddressGroups(groups []AddressGroup, address func(*mail.Address) string, phrase func(string) string) string {
+	var parts []string
+	for _, g := range groups {
+		list := make([]string, 0, len(g.Addresses))
+		for _, a := range g.Addresses {
+			if a != nil {
+				list = append(list, address(a))
+			}
+		}
+
+		if g.Name == "" {
+			parts = append(parts, list...)
+			continue
+		}
+
+		group := phrase(g.Name) + ":"
+		if len(list) > 0 {
+			group += " " + strings.Join(list, ", ")
+		}
+		parts = append(parts, group+";")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// quotePhrase quotes a display name that isn't a sequence of atoms
+func quotePhrase(s string) string {
+	if s != "" && s == strings.Join(strings.Fields(s), " ") && !strings.ContainsAny(s, `()<>[]:;@\,."`) {
+		return s
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// unquotePhrase removes the quotes and escapes of a quoted display name
+func unquotePhrase(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+
+	return sb.String()
+}
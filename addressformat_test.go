@@ -0,0 +1,71 @@
+package parsemail
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestAddressGroups(t *testing.T) {
+	e, err := Parse(strings.NewReader(addressGroupMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := e.AddressGroups("To")
+	if len(groups) != 4 {
+		t.Fatalf("Wrong number of groups. Expected: 4, Got: %d", len(groups))
+	}
+
+	var testData = map[int]struct {
+		name      string
+		addresses []string
+	}{
+		0: {addresses: []string{"mary@example.com"}},
+		1: {name: "Team, Köln", addresses: []string{"bob@example.com", "juergen@example.com"}},
+		2: {addresses: []string{"alice@example.com"}},
+		3: {name: "undisclosed-recipients"},
+	}
+
+	for index, td := range testData {
+		g := groups[index]
+		if g.Name != td.name {
+			t.Errorf("[Test Case %v] Wrong group name. Expected: %s, Got: %s", index, td.name, g.Name)
+		}
+
+		var addresses []string
+		for _, a := range g.Addresses {
+			addresses = append(addresses, a.Address)
+		}
+		if !assertSliceEq(addresses, td.addresses) {
+			t.Errorf("[Test Case %v] Wrong addresses. Expected: %v, Got: %v", index, td.addresses, addresses)
+		}
+	}
+
+	expected := `mary@example.com, "Team, Köln": "Doe, Bob" <bob@example.com>, Jürgen <juergen@example.com>;, alice@example.com, undisclosed-recipients:;`
+	if got := DisplayAddressList(groups); got != expected {
+		t.Errorf("Wrong display list.\nExpected: %s\nGot:      %s", expected, got)
+	}
+
+	formatted := FormatAddressList(groups)
+	expected = `<mary@example.com>, =?utf-8?b?VGVhbSwgS8O2bG4=?=: "Doe, Bob" <bob@example.com>, =?utf-8?q?J=C3=BCrgen?= <juergen@example.com>;, <alice@example.com>, undisclosed-recipients:;`
+	if formatted != expected {
+		t.Errorf("Wrong formatted list.\nExpected: %s\nGot:      %s", expected, formatted)
+	}
+
+	// the formatted list parses to the same addresses
+	list, err := mail.ParseAddressList(formatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assertAddressListEq(dereferenceAddressList(list), dereferenceAddressList(e.To)) {
+		t.Errorf("Wrong reparsed list. Expected: %v, Got: %v", e.To, list)
+	}
+}
+
+var addressGroupMessage = "Subject: Groups\r\n" +
+	"To: mary@example.com, =?utf-8?b?VGVhbSwgS8O2bG4=?=: \"Doe, Bob\" <bob@example.com>,\r\n" +
+	" =?utf-8?q?J=C3=BCrgen?= <juergen@example.com>;, alice@example.com, undisclosed-recipients:;\r\n" +
+	"Date: Fri, 21 Nov 1997 09:55:06 -0600\r\n" +
+	"\r\n" +
+	"Hi\r\n"
@@ -0,0 +1,153 @@
+package parsemail
+
+import (
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// AddressRange is an address of a header field together with the position of
+// its text in the original message. Start and End are byte offsets into the
+// message returned by Raw with Options.KeepRaw, End is exclusive.
+type AddressRange struct {
+	Address *mail.Address
+	Start   int
+	End     int
+}
+
+// Raw returns the message as it was parsed. It equals the input of Parse
+// unless Options.NotesQuirks repaired it or Options.SMIMEKey decrypted it.
+// It is nil unless the email was parsed with Options.KeepRaw.
+func (e *Email) Raw() []byte {
+	raw, _ := e.rawMessage()
+	return raw
+}
+
+// rawMessage returns the original message, or ErrRawNotKept if only its
+// header was kept
+func (e *Email) rawMessage() ([]byte, error) {
+	if e.raw == nil || !e.opts.KeepRaw {
+		return nil, ErrRawNotKept
+	}
+
+	return e.raw, nil
+}
+
+// AddressRanges returns the addresses of the first header field called name,
+// like From or To, with the byte ranges they occupy in the original message.
+// Group names and the separators between addresses are not part of the
+// ranges. Text that doesn't parse as an address is skipped.
+func (e *Email) AddressRanges(name string) []AddressRange {
+	value, offset, ok := e.rawAddressField(name)
+	if !ok {
+		return nil
+	}
+
+	var ranges []AddressRange
+	for _, tok := range addressTokens(value) {
+		if tok.kind != addressToken {
+			continue
+		}
+
+		a, err := mail.ParseAddress(UnfoldHeader(value[tok.start:tok.end]))
+		if err != nil {
+			continue
+		}
+
+		ranges = append(ranges, AddressRange{Address: a, Start: offset + tok.start, End: offset + tok.end})
+	}
+
+	return ranges
+}
+
+// rawAddressField returns the raw value of the first header field called
+// name and its offset in the original message
+func (e *Email) rawAddressField(name string) (value string, offset int, ok bool) {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+
+	header, _ := splitHeader(e.raw)
+	for _, f := range splitHeaderFields(header) {
+		if f.Name == name {
+			colon := strings.IndexByte(string(f.Raw), ':')
+			return string(f.Raw[colon+1:]), offset + colon + 1, true
+		}
+		offset += len(f.Raw)
+	}
+
+	return "", 0, false
+}
+
+const (
+	addressToken = iota
+	groupStartToken
+	groupEndToken
+)
+
+// addressListToken is an address or the start or end of a group in an
+// address list. The range of a group start is the group name.
+type addressListToken struct {
+	kind       int
+	start, end int
+}
+
+// addressTokens splits an address list into addresses and group delimiters,
+// trimming the whitespace around them and skipping empty entries
+func addressTokens(s string) (tokens []addressListToken) {
+	start := 0
+	quoted, comment, angle := false, 0, false
+
+	add := func(kind, end int) {
+		tok := addressListToken{kind: kind, start: start, end: end}
+		for tok.start < tok.end && isFoldingSpace(s[tok.start]) {
+			tok.start++
+		}
+		for tok.end > tok.start && isFoldingSpace(s[tok.end-1]) {
+			tok.end--
+		}
+		if tok.start < tok.end || kind == groupStartToken {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && (quoted || comment > 0):
+			i++
+		case quoted:
+			quoted = c != '"'
+		case comment > 0:
+			if c == '(' {
+				comment++
+			} else if c == ')' {
+				comment--
+			}
+		case c == '"':
+			quoted = true
+		case c == '(':
+			comment++
+		case c == '<':
+			angle = true
+		case c == '>':
+			angle = false
+		case angle:
+		case c == ':':
+			add(groupStartToken, i)
+			start = i + 1
+		case c == ',':
+			add(addressToken, i)
+			start = i + 1
+		case c == ';':
+			add(addressToken, i)
+			tokens = append(tokens, addressListToken{kind: groupEndToken, start: i, end: i + 1})
+			start = i + 1
+		}
+	}
+	add(addressToken, len(s))
+
+	return
+}
+
+func isFoldingSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
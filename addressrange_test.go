@@ -0,0 +1,63 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddressRanges(t *testing.T) {
+	e, err := ParseWithOptions(strings.NewReader(addressRangeMessage), Options{KeepRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = map[int]struct {
+		field     string
+		texts     []string
+		addresses []string
+	}{
+		1: {
+			field:     "From",
+			texts:     []string{`"Doe, John" (CEO) <jdoe@example.com>`},
+			addresses: []string{"jdoe@example.com"},
+		},
+		2: {
+			field:     "to",
+			texts:     []string{"mary@example.com", "Bob <bob@example.com>", "=?utf-8?q?J=C3=BCrgen?=\r\n <juergen@example.com>"},
+			addresses: []string{"mary@example.com", "bob@example.com", "juergen@example.com"},
+		},
+		3: {
+			field: "Cc",
+		},
+	}
+
+	raw := e.Raw()
+	for index, td := range testData {
+		ranges := e.AddressRanges(td.field)
+		if len(ranges) != len(td.texts) {
+			t.Errorf("[Test Case %v] Wrong number of addresses. Expected: %d, Got: %d", index, len(td.texts), len(ranges))
+			continue
+		}
+
+		for i, r := range ranges {
+			if got := string(raw[r.Start:r.End]); got != td.texts[i] {
+				t.Errorf("[Test Case %v] Wrong range %d. Expected: %q, Got: %q", index, i, td.texts[i], got)
+			}
+			if r.Address.Address != td.addresses[i] {
+				t.Errorf("[Test Case %v] Wrong address %d. Expected: %s, Got: %s", index, i, td.addresses[i], r.Address.Address)
+			}
+		}
+	}
+
+	if ranges := e.AddressRanges("Reply-To"); ranges != nil {
+		t.Errorf("Expected no ranges for a missing field, Got: %v", ranges)
+	}
+}
+
+var addressRangeMessage = "Subject: Ranges\r\n" +
+	"From: \"Doe, John\" (CEO) <jdoe@example.com>\r\n" +
+	"To: mary@example.com, Team: Bob <bob@example.com>,\r\n =?utf-8?q?J=C3=BCrgen?=\r\n <juergen@example.com>;\r\n" +
+	"Cc: undisclosed-recipients:;\r\n" +
+	"Date: Fri, 21 Nov 1997 09:55:06 -0600\r\n" +
+	"\r\n" +
+	"Hi\r\n"
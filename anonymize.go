@@ -0,0 +1,287 @@
+package parsemail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// anonymizeTokenPattern matches addresses and message IDs, and words
+// otherwise
+var anonymizeTokenPattern = regexp.MustCompile("[A-Za-z0-9.!#$%&'*+/=?^_`{|}~-]+@[A-Za-z0-9-]+(?:\\.[A-Za-z0-9-]+)+|[\\pL\\pN]+")
+
+// anonymizeDroppedHeaders are signatures made invalid by the anonymization
+var anonymizeDroppedHeaders = map[string]bool{
+	"Dkim-Signature":        true,
+	"Arc-Seal":              true,
+	"Arc-Message-Signature": true,
+}
+
+const (
+	pseudonymVowels     = "aeiou"
+	pseudonymConsonants = "bcdfghjklmnprstvwz"
+)
+
+// Anonymizer replaces addresses, display names and message IDs with
+// pseudonyms derived from them with HMAC-SHA256 under Key. The same value
+// always gets the same pseudonym, so threads and correspondents stay
+// recognizable across a corpus anonymized with one key, and pseudonyms keep
+// the length, case and punctuation of the original: "John Doe
+// <john.doe+news@mail.example.com>" becomes something like "Kave Tup
+// <kave.tup+lobi@wunu.nesapib.com>". Top level domains and the domains in
+// KeepDomains, including their subdomains, are kept.
+type Anonymizer struct {
+	Key         []byte
+	KeepDomains []string
+}
+
+// Anonymize returns a copy of the email with pseudonyms for the addresses
+// and display names of all address fields and for its message IDs. Names,
+// addresses and message IDs are also replaced wherever they appear in the
+// subject, the other header fields and the text and HTML bodies, and the
+// original message of a report is anonymized in turn. DKIM and ARC
+// signatures, the S/MIME signature and the raw message are dropped.
+// Attachments and embedded files are kept as they are, callers must remove
+// them if they may hold personal data. Serialize writes the result as a
+// fixture.
+func (a *Anonymizer) Anonymize(e *Email) Email {
+	ids := map[string]bool{}
+	for _, id := range append(append([]string{e.MessageID, e.ResentMessageID}, e.InReplyTo...), e.References...) {
+		if id != "" {
+			ids[strings.ToLower(id)] = true
+		}
+	}
+
+	names := map[string]bool{}
+	lists := [][]*mail.Address{
+		e.From, e.ReplyTo, e.To, e.Cc, e.Bcc, e.MailReplyTo, e.MailFollowupTo,
+		e.ResentFrom, e.ResentTo, e.ResentCc, e.ResentBcc,
+		{e.Sender, e.ResentSender},
+	}
+	for _, list := range lists {
+		for _, addr := range list {
+			if addr == nil {
+				continue
+			}
+			for _, w := range anonymizeTokenPattern.FindAllString(addr.Name, -1) {
+				// initials would replace every single letter of the text
+				if utf8.RuneCountInString(w) > 1 {
+					names[strings.ToLower(w)] = true
+				}
+			}
+		}
+	}
+
+	text := func(s string) string {
+		return anonymizeTokenPattern.ReplaceAllStringFunc(s, func(token string) string {
+			switch {
+			case ids[strings.ToLower(token)]:
+				return a.MessageID(token)
+			case strings.Contains(token, "@"):
+				return a.Address(token)
+			case names[strings.ToLower(token)]:
+				return a.Name(token)
+			}
+			return token
+		})
+	}
+
+	c := *e
+	c.Subject = text(e.Subject)
+	c.Sender = a.address(e.Sender)
+	c.From = a.addresses(e.From)
+	c.ReplyTo = a.addresses(e.ReplyTo)
+	c.To = a.addresses(e.To)
+	c.Cc = a.addresses(e.Cc)
+	c.Bcc = a.addresses(e.Bcc)
+	c.MailReplyTo = a.addresses(e.MailReplyTo)
+	c.MailFollowupTo = a.addresses(e.MailFollowupTo)
+	c.ResentFrom = a.addresses(e.ResentFrom)
+	c.ResentSender = a.address(e.ResentSender)
+	c.ResentTo = a.addresses(e.ResentTo)
+	c.ResentCc = a.addresses(e.ResentCc)
+	c.ResentBcc = a.addresses(e.ResentBcc)
+	c.MessageID = a.MessageID(e.MessageID)
+	c.ResentMessageID = a.MessageID(e.ResentMessageID)
+	c.InReplyTo = a.messageIDs(e.InReplyTo)
+	c.References = a.messageIDs(e.References)
+	c.TextBody = text(e.TextBody)
+	c.HTMLBody = text(e.HTMLBody)
+
+	if e.Header != nil {
+		c.Header = mail.Header{}
+		for k, values := range e.Header {
+			if anonymizeDroppedHeaders[k] {
+				continue
+			}
+			if strings.HasPrefix(k, "Content-") {
+				c.Header[k] = values
+				continue
+			}
+			for _, v := range values {
+				c.Header[k] = append(c.Header[k], text(v))
+			}
+		}
+		c.AuthenticationResults = parseAuthenticationResults(c.Header["Authentication-Results"])
+	}
+
+	if e.OriginalMessage != nil {
+		original := a.Anonymize(e.OriginalMessage)
+		c.OriginalMessage = &original
+	}
+
+	c.Signature = nil
+	c.raw = nil
+
+	return c
+}
+
+// Address returns the pseudonym of an address. The local part is
+// pseudonymized like a name, so the address of "John Doe" is
+// "kave.tup@..." when the name becomes "Kave Tup".
+func (a *Anonymizer) Address(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return a.wordwise("name", address)
+	}
+
+	return a.wordwise("name", address[:at]) + "@" + a.Domain(address[at+1:])
+}
+
+// Name returns the pseudonym of a display name, word by word
+func (a *Anonymizer) Name(name string) string {
+	return a.wordwise("name", name)
+}
+
+// MessageID returns the pseudonym of a message ID, keeping the structure of
+// its left part and using the pseudonym of its domain
+func (a *Anonymizer) MessageID(id string) string {
+	if id == "" {
+		return ""
+	}
+
+	at := strings.LastIndex(id, "@")
+	if at < 0 {
+		return a.wordwise("id", id)
+	}
+
+	return a.wordwise("id", id[:at]) + "@" + a.Domain(id[at+1:])
+}
+
+// Domain returns the pseudonym of a domain, label by label. The top level
+// domain and the KeepDomains are kept.
+func (a *Anonymizer) Domain(domain string) string {
+	lower := strings.ToLower(domain)
+	for _, keep := range a.KeepDomains {
+		keep = strings.ToLower(keep)
+		if lower == keep || strings.HasSuffix(lower, "."+keep) {
+			return domain
+		}
+	}
+
+	labels := strings.Split(domain, ".")
+	for i := 0; i < len(labels)-1; i++ {
+		labels[i] = a.wordwise("domain", labels[i])
+	}
+
+	return strings.Join(labels, ".")
+}
+
+func (a *Anonymizer) address(addr *mail.Address) *mail.Address {
+	if addr == nil {
+		return nil
+	}
+
+	return &mail.Address{Name: a.Name(addr.Name), Address: a.Address(addr.Address)}
+}
+
+func (a *Anonymizer) addresses(list []*mail.Address) (out []*mail.Address) {
+	for _, addr := range list {
+		out = append(out, a.address(addr))
+	}
+
+	return
+}
+
+func (a *Anonymizer) messageIDs(ids []string) (out []string) {
+	for _, id := range ids {
+		out = append(out, a.MessageID(id))
+	}
+
+	return
+}
+
+// wordwise replaces the runs of letters and digits of s with their
+// pseudonyms, keeping everything between them
+func (a *Anonymizer) wordwise(kind, s string) string {
+	var b strings.Builder
+	start := -1
+	for i, r := range s + " " {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			b.WriteString(a.pseudonym(kind, s[start:i]))
+			start = -1
+		}
+		if i < len(s) {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// pseudonym returns a pronounceable word of the length of word, with
+// letters where word has letters, digits where it has digits, and its case.
+// Words are compared without case.
+func (a *Anonymizer) pseudonym(kind, word string) string {
+	// one HMAC block per 32 bytes of the word
+	var stream []byte
+	for block := uint64(0); len(stream) <= len(word); block++ {
+		var counter [8]byte
+		binary.BigEndian.PutUint64(counter[:], block)
+
+		mac := hmac.New(sha256.New, a.Key)
+		mac.Write(counter[:])
+		mac.Write([]byte(kind + ":" + strings.ToLower(word)))
+		stream = mac.Sum(stream)
+	}
+	next := func() byte {
+		b := stream[0]
+		stream = stream[1:]
+		return b
+	}
+
+	var b strings.Builder
+	vowel := next()&1 == 1
+	for _, r := range word {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteByte('0' + next()%10)
+		case unicode.IsLetter(r):
+			set := pseudonymConsonants
+			if vowel {
+				set = pseudonymVowels
+			}
+			vowel = !vowel
+			c := rune(set[int(next())%len(set)])
+			if unicode.IsUpper(r) {
+				c = unicode.ToUpper(c)
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package parsemail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizerPseudonyms(t *testing.T) {
+	a := &Anonymizer{Key: []byte("secret"), KeepDomains: []string{"gmail.com"}}
+	other := &Anonymizer{Key: []byte("other")}
+
+	var testData = map[int]struct {
+		value     string
+		anonymize func(*Anonymizer, string) string
+		same      string
+		kept      string
+	}{
+		1: {value: "John Doe", anonymize: (*Anonymizer).Name, same: "JOHN doe"},
+		2: {value: "john.doe+news@Mail.Example.com", anonymize: (*Anonymizer).Address, same: "JOHN.DOE+news@mail.example.com"},
+		3: {value: "jdoe@mail.gmail.com", anonymize: (*Anonymizer).Address, kept: "@mail.gmail.com"},
+		4: {value: "CAF12-x9@mail.example.com", anonymize: (*Anonymizer).MessageID, kept: "-"},
+	}
+
+	for index, td := range testData {
+		p := td.anonymize(a, td.value)
+		if p == td.value || len(p) != len(td.value) {
+			t.Errorf("[Test Case %v] Wrong pseudonym. Value: %s, Got: %s", index, td.value, p)
+		}
+		if p2 := td.anonymize(a, td.value); p2 != p {
+			t.Errorf("[Test Case %v] Pseudonym isn't stable. Expected: %s, Got: %s", index, p, p2)
+		}
+		if td.anonymize(other, td.value) == p {
+			t.Errorf("[Test Case %v] Pseudonym doesn't depend on the key: %s", index, p)
+		}
+		if td.same != "" && !strings.EqualFold(td.anonymize(a, td.same), p) {
+			t.Errorf("[Test Case %v] Wrong pseudonym of %s. Expected: %s, Got: %s", index, td.same, p, td.anonymize(a, td.same))
+		}
+		if td.kept != "" && !strings.Contains(p, td.kept) {
+			t.Errorf("[Test Case %v] Expected %q to be kept. Got: %s", index, td.kept, p)
+		}
+	}
+
+	if n := a.Name("John Doe"); n[0] < 'A' || n[0] > 'Z' || n[4] != ' ' || n[5] < 'A' || n[5] > 'Z' {
+		t.Errorf("Expected the case and spacing of names to be kept. Got: %s", n)
+	}
+	if d := a.Domain("mail.example.com"); !strings.HasSuffix(d, ".com") || strings.Count(d, ".") != 2 {
+		t.Errorf("Expected the labels and top level domain to be kept. Got: %s", d)
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	a := &Anonymizer{Key: []byte("secret")}
+
+	e, err := ParseWithOptions(strings.NewReader(anonymizeMessage), Options{KeepRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anon := a.Anonymize(&e)
+	raw, err := Serialize(&anon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"John", "Doe", "jdoe", "Mary", "mary", "example.org", "1234.abcd", "5678.efgh", "DKIM-Signature", "Dkim-Signature"} {
+		if bytes.Contains(raw, []byte(s)) {
+			t.Errorf("Expected %q to be anonymized. Got:\n%s", s, raw)
+		}
+	}
+
+	if anon.From[0].Address != a.Address("jdoe@example.org") || anon.From[0].Name != a.Name("John Doe") {
+		t.Errorf("Wrong from. Got: %v", anon.From[0])
+	}
+	if anon.InReplyTo[0] != a.MessageID("5678.efgh@example.org") || anon.References[0] != anon.InReplyTo[0] {
+		t.Errorf("Wrong thread. In-Reply-To: %v, References: %v", anon.InReplyTo, anon.References)
+	}
+	if !strings.Contains(anon.TextBody, "Hi "+a.Name("Mary")+",") || !strings.Contains(anon.TextBody, a.Address("mary@example.org")) {
+		t.Errorf("Wrong text body. Got: %s", anon.TextBody)
+	}
+	if !strings.Contains(anon.TextBody, "the meeting on Monday") {
+		t.Errorf("Expected other words to be kept. Got: %s", anon.TextBody)
+	}
+	if anon.Header.Get("Delivered-To") != a.Address("mary@example.org") {
+		t.Errorf("Wrong Delivered-To. Got: %s", anon.Header.Get("Delivered-To"))
+	}
+	if e.From[0].Name != "John Doe" || len(e.Raw()) == 0 || anon.Raw() != nil {
+		t.Errorf("Expected the original email to be unchanged and the raw message to be dropped")
+	}
+
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.MessageID != anon.MessageID || parsed.Subject != anon.Subject || parsed.TextBody != anon.TextBody {
+		t.Errorf("Wrong serialized message. Got:\n%s", raw)
+	}
+}
+
+var anonymizeMessage = "DKIM-Signature: v=1; a=rsa-sha256; d=example.org; s=mail; h=from; bh=YWJj; b=ZGVm\r\n" +
+	"Delivered-To: mary@example.org\r\n" +
+	"From: John Doe <jdoe@example.org>\r\n" +
+	"To: Mary Major <mary@example.org>\r\n" +
+	"Subject: Re: Lunch with Mary\r\n" +
+	"Date: Mon, 12 Oct 2026 10:00:00 +0000\r\n" +
+	"Message-ID: <1234.abcd@example.org>\r\n" +
+	"In-Reply-To: <5678.efgh@example.org>\r\n" +
+	"References: <5678.efgh@example.org>\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Hi Mary,\r\n" +
+	"\r\n" +
+	"see you at the meeting on Monday. Write to mary@example.org or me.\r\n" +
+	"\r\n" +
+	"John\r\n"
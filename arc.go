@@ -0,0 +1,320 @@
+package parsemail
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ARCStatus is the validation state of an ARC chain (RFC 8617)
+type ARCStatus string
+
+const (
+	ARCNone ARCStatus = "none"
+	ARCPass ARCStatus = "pass"
+	ARCFail ARCStatus = "fail"
+)
+
+// maxARCInstances is the largest instance number of an ARC set
+const maxARCInstances = 50
+
+// ARCSet is the set of ARC fields added by one intermediary, like a mailing
+// list, that handled the message
+type ARCSet struct {
+	Instance int
+	// AuthenticationResults are the checks the intermediary made when it
+	// received the message
+	AuthenticationResults AuthenticationResults
+	MessageSignature      *DKIMSignature
+	Seal                  *ARCSeal
+
+	results HeaderField
+}
+
+// ARCSeal is a parsed ARC-Seal field, signing the ARC sets up to its own
+type ARCSeal struct {
+	Algorithm string
+	Domain    string
+	Selector  string
+	// ChainValidation is the cv= tag, the state of the chain the sealer found
+	ChainValidation ARCStatus
+	Signature       []byte
+	Timestamp       time.Time
+
+	field HeaderField
+}
+
+// ARCChain returns the ARC sets of the message ordered by instance. An error
+// is returned when the fields don't form a chain: when a field is
+// malformed, an instance lacks a field or has one twice, or instances are
+// missing.
+func (e *Email) ARCChain() ([]ARCSet, error) {
+	return parseARCChain(e.RawHeaderFields())
+}
+
+func parseARCChain(fields []HeaderField) ([]ARCSet, error) {
+	sets := map[int]*ARCSet{}
+	set := func(instance int) (*ARCSet, error) {
+		if instance < 1 || instance > maxARCInstances {
+			return nil, fmt.Errorf("arc: invalid instance %d", instance)
+		}
+		if sets[instance] == nil {
+			sets[instance] = &ARCSet{Instance: instance}
+		}
+		return sets[instance], nil
+	}
+
+	for _, f := range fields {
+		switch f.Name {
+		case "Arc-Authentication-Results":
+			instance, rest, err := splitARCInstance(f.Value())
+			if err != nil {
+				return nil, err
+			}
+			s, err := set(instance)
+			if err != nil {
+				return nil, err
+			}
+			if s.results.Raw != nil {
+				return nil, fmt.Errorf("arc: duplicate authentication results of instance %d", instance)
+			}
+			s.results = f
+			s.AuthenticationResults, _ = ParseAuthenticationResults(rest)
+
+		case "Arc-Message-Signature":
+			sig, err := parseDKIMSignature(f.Raw, true)
+			if err != nil {
+				return nil, fmt.Errorf("arc: message signature: %v", err)
+			}
+			instance, err := arcInstanceTag(f)
+			if err != nil {
+				return nil, err
+			}
+			s, err := set(instance)
+			if err != nil {
+				return nil, err
+			}
+			if s.MessageSignature != nil {
+				return nil, fmt.Errorf("arc: duplicate message signature of instance %d", instance)
+			}
+			s.MessageSignature = sig
+
+		case "Arc-Seal":
+			seal, instance, err := parseARCSeal(f)
+			if err != nil {
+				return nil, err
+			}
+			s, err := set(instance)
+			if err != nil {
+				return nil, err
+			}
+			if s.Seal != nil {
+				return nil, fmt.Errorf("arc: duplicate seal of instance %d", instance)
+			}
+			s.Seal = seal
+		}
+	}
+
+	chain := make([]ARCSet, 0, len(sets))
+	for _, s := range sets {
+		chain = append(chain, *s)
+	}
+	sort.Slice(chain, func(i, j int) bool { return chain[i].Instance < chain[j].Instance })
+
+	for i, s := range chain {
+		if s.Instance != i+1 {
+			return nil, fmt.Errorf("arc: instance %d is missing", i+1)
+		}
+		if s.results.Raw == nil || s.MessageSignature == nil || s.Seal == nil {
+			return nil, fmt.Errorf("arc: incomplete set of instance %d", s.Instance)
+		}
+	}
+
+	return chain, nil
+}
+
+// splitARCInstance splits the "i=1;" prefix of an ARC-Authentication-Results
+// value from the results
+func splitARCInstance(value string) (int, string, error) {
+	semicolon := strings.IndexByte(value, ';')
+	if semicolon < 0 {
+		return 0, "", errors.New("arc: authentication results without instance")
+	}
+
+	tag := strings.SplitN(value[:semicolon], "=", 2)
+	if len(tag) != 2 || strings.TrimSpace(tag[0]) != "i" {
+		return 0, "", errors.New("arc: authentication results without instance")
+	}
+
+	instance, err := strconv.Atoi(strings.TrimSpace(tag[1]))
+	if err != nil {
+		return 0, "", fmt.Errorf("arc: malformed instance %s", tag[1])
+	}
+
+	return instance, value[semicolon+1:], nil
+}
+
+// arcInstanceTag returns the i= tag of an ARC field
+func arcInstanceTag(f HeaderField) (int, error) {
+	tags, err := parseDKIMTags(f.Value())
+	if err != nil {
+		return 0, err
+	}
+
+	instance, err := strconv.Atoi(tags["i"])
+	if err != nil {
+		return 0, fmt.Errorf("arc: malformed instance %q", tags["i"])
+	}
+
+	return instance, nil
+}
+
+func parseARCSeal(f HeaderField) (*ARCSeal, int, error) {
+	tags, err := parseDKIMTags(f.Value())
+	if err != nil {
+		return nil, 0, fmt.Errorf("arc: seal: %v", err)
+	}
+
+	for _, t := range []string{"i", "a", "b", "cv", "d", "s"} {
+		if _, ok := tags[t]; !ok {
+			return nil, 0, fmt.Errorf("arc: seal is missing the %s= tag", t)
+		}
+	}
+	if _, ok := tags["h"]; ok {
+		return nil, 0, errors.New("arc: seal must not have an h= tag")
+	}
+
+	instance, err := strconv.Atoi(tags["i"])
+	if err != nil {
+		return nil, 0, fmt.Errorf("arc: malformed instance %q", tags["i"])
+	}
+
+	seal := &ARCSeal{
+		Algorithm:       strings.ToLower(tags["a"]),
+		Domain:          strings.ToLower(tags["d"]),
+		Selector:        tags["s"],
+		ChainValidation: ARCStatus(strings.ToLower(tags["cv"])),
+		field:           f,
+	}
+	if seal.Signature, err = base64.StdEncoding.DecodeString(stripWhitespace(tags["b"])); err != nil {
+		return nil, 0, fmt.Errorf("arc: malformed seal: %v", err)
+	}
+	if t, ok := tags["t"]; ok {
+		n, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("arc: malformed t= tag %s", t)
+		}
+		seal.Timestamp = time.Unix(n, 0)
+	}
+
+	return seal, instance, nil
+}
+
+// ARCResult is the outcome of validating the ARC chain of a message
+type ARCResult struct {
+	Status ARCStatus
+	Chain  []ARCSet
+	// FailedInstance is the instance whose signature or seal failed, or 0
+	FailedInstance int
+	// Err explains a failed chain
+	Err error
+}
+
+// VerifyARC validates the ARC chain of the original message (RFC 8617
+// section 5.2) with the keys published in the DNS, looked up with resolver
+// or the default resolver if it is nil. The message signature of the last
+// set and the seals of all sets are verified. A message without ARC fields
+// has the status none. A chain that is malformed, was already found failed
+// by an intermediary or has a signature that doesn't verify fails, and so
+// does one whose keys can't be looked up, or whose message wasn't kept with
+// Options.KeepRaw.
+func (e *Email) VerifyARC(ctx context.Context, resolver TXTResolver) ARCResult {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	raw, rawErr := e.rawMessage()
+	header, body := splitHeader(raw)
+	if rawErr != nil {
+		header, _ = splitHeader(e.raw)
+	}
+	fields := splitHeaderFields(header)
+
+	chain, err := parseARCChain(fields)
+	if err != nil {
+		return ARCResult{Status: ARCFail, Err: err}
+	}
+	if len(chain) == 0 {
+		return ARCResult{Status: ARCNone}
+	}
+	if rawErr != nil {
+		return ARCResult{Status: ARCFail, Chain: chain, Err: rawErr}
+	}
+
+	r := ARCResult{Status: ARCFail, Chain: chain}
+	for _, s := range chain {
+		expected := ARCPass
+		if s.Instance == 1 {
+			expected = ARCNone
+		}
+		if s.Seal.ChainValidation != expected {
+			r.FailedInstance = s.Instance
+			r.Err = fmt.Errorf("arc: seal of instance %d has cv=%s", s.Instance, s.Seal.ChainValidation)
+			return r
+		}
+	}
+
+	last := chain[len(chain)-1]
+	if _, err := last.MessageSignature.verify(ctx, resolver, fields, body, time.Now()); err != nil {
+		r.FailedInstance = last.Instance
+		r.Err = fmt.Errorf("arc: message signature of instance %d: %v", last.Instance, err)
+		return r
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := chain[i].Seal.verify(ctx, resolver, chain[:i+1]); err != nil {
+			r.FailedInstance = chain[i].Instance
+			r.Err = fmt.Errorf("arc: seal of instance %d: %v", chain[i].Instance, err)
+			return r
+		}
+	}
+
+	r.Status = ARCPass
+
+	return r
+}
+
+// verify checks the seal over the ARC sets up to and including its own,
+// which is the last of sets
+func (seal *ARCSeal) verify(ctx context.Context, resolver TXTResolver, sets []ARCSet) error {
+	key, _, err := (&DKIMSignature{
+		Algorithm: seal.Algorithm,
+		Domain:    seal.Domain,
+		Selector:  seal.Selector,
+		Identity:  "@" + seal.Domain,
+	}).lookupKey(ctx, resolver)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	for i, s := range sets {
+		h.Write(CanonicalizeHeader(s.results.Raw, CanonicalizationRelaxed))
+		h.Write(CanonicalizeHeader(s.MessageSignature.field.Raw, CanonicalizationRelaxed))
+		if i < len(sets)-1 {
+			h.Write(CanonicalizeHeader(s.Seal.field.Raw, CanonicalizationRelaxed))
+		}
+	}
+	unsigned := CanonicalizeHeader(withoutSignatureValue(seal.field.Raw), CanonicalizationRelaxed)
+	h.Write(bytes.TrimSuffix(unsigned, []byte("\r\n")))
+
+	return verifyDKIMDigest(key, h.Sum(nil), seal.Signature)
+}
@@ -0,0 +1,180 @@
+package parsemail
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"testing"
+)
+
+// arcSign prepends an ARC set to raw, signed with key under the selector
+// "arc" of example.org
+func arcSign(t *testing.T, raw []byte, instance int, key *rsa.PrivateKey, cv string) []byte {
+	header, body := splitHeader(raw)
+	fields := splitHeaderFields(header)
+	chain, err := parseARCChain(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(h []byte) string {
+		digest := sha256.Sum256(h)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+	trimmed := func(field string) []byte {
+		return bytes.TrimSuffix(CanonicalizeHeader([]byte(field+"\r\n"), CanonicalizationRelaxed), []byte("\r\n"))
+	}
+
+	aar := fmt.Sprintf("ARC-Authentication-Results: i=%d; lists.example.org; spf=pass smtp.mailfrom=example.com\r\n", instance)
+
+	bh := sha256.Sum256(CanonicalizeBody(body, CanonicalizationRelaxed))
+	ams := fmt.Sprintf("ARC-Message-Signature: i=%d; a=rsa-sha256; c=relaxed/relaxed; d=example.org; s=arc;\r\n h=from:to:subject; bh=%s; b=", instance, base64.StdEncoding.EncodeToString(bh[:]))
+	var signed []byte
+	for _, f := range selectHeaderFields(fields, []string{"from", "to", "subject"}) {
+		signed = append(signed, CanonicalizeHeader(f.Raw, CanonicalizationRelaxed)...)
+	}
+	ams += sign(append(signed, trimmed(ams)...)) + "\r\n"
+
+	seal := fmt.Sprintf("ARC-Seal: i=%d; a=rsa-sha256; cv=%s; d=example.org; s=arc; b=", instance, cv)
+	signed = nil
+	for _, s := range chain {
+		signed = append(signed, CanonicalizeHeader(s.results.Raw, CanonicalizationRelaxed)...)
+		signed = append(signed, CanonicalizeHeader(s.MessageSignature.field.Raw, CanonicalizationRelaxed)...)
+		signed = append(signed, CanonicalizeHeader(s.Seal.field.Raw, CanonicalizationRelaxed)...)
+	}
+	signed = append(signed, CanonicalizeHeader([]byte(aar), CanonicalizationRelaxed)...)
+	signed = append(signed, CanonicalizeHeader([]byte(ams), CanonicalizationRelaxed)...)
+	seal += sign(append(signed, trimmed(seal)...)) + "\r\n"
+
+	return append([]byte(seal+ams+aar), raw...)
+}
+
+func TestVerifyARC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := txtRecords{"arc._domainkey.example.org": {"v=DKIM1; p=" + base64.StdEncoding.EncodeToString(der)}}
+
+	original := []byte("From: John Doe <jdoe@example.com>\r\nTo: list@example.org\r\nSubject: Hello\r\n\r\nHello list\r\n")
+	footer := func(raw []byte) []byte { return append(raw, "-- \r\nlist footer\r\n"...) }
+
+	var testData = map[int]struct {
+		raw      func() []byte
+		resolver TXTResolver
+		status   ARCStatus
+		failed   int
+		sets     int
+	}{
+		1: {raw: func() []byte { return original }, status: ARCNone},
+		2: {raw: func() []byte { return arcSign(t, original, 1, key, "none") }, status: ARCPass, sets: 1},
+		3: {
+			raw: func() []byte {
+				return arcSign(t, footer(arcSign(t, original, 1, key, "none")), 2, key, "pass")
+			},
+			status: ARCPass,
+			sets:   2,
+		},
+		4: {
+			raw: func() []byte {
+				return footer(arcSign(t, arcSign(t, original, 1, key, "none"), 2, key, "pass"))
+			},
+			status: ARCFail,
+			failed: 2,
+			sets:   2,
+		},
+		5: {raw: func() []byte { return arcSign(t, arcSign(t, original, 1, key, "none"), 2, key, "fail") }, status: ARCFail, failed: 2, sets: 2},
+		6: {
+			raw: func() []byte {
+				raw := arcSign(t, arcSign(t, original, 1, key, "none"), 2, key, "pass")
+				return bytes.Replace(raw, []byte("i=1; lists.example.org; spf=pass"), []byte("i=1; lists.example.org; spf=fail"), 1)
+			},
+			status: ARCFail,
+			failed: 2,
+			sets:   2,
+		},
+		7: {raw: func() []byte { return arcSign(t, original, 2, key, "pass") }, status: ARCFail},
+		8: {raw: func() []byte { return arcSign(t, original, 1, key, "pass") }, status: ARCFail, failed: 1, sets: 1},
+		9: {raw: func() []byte { return arcSign(t, original, 1, key, "none") }, resolver: txtRecords{}, status: ARCFail, failed: 1, sets: 1},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(bytes.NewReader(td.raw()), Options{KeepRaw: true})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		res := td.resolver
+		if res == nil {
+			res = resolver
+		}
+
+		r := e.VerifyARC(context.Background(), res)
+		if r.Status != td.status || r.FailedInstance != td.failed || len(r.Chain) != td.sets {
+			t.Errorf("[Test Case %v] Wrong result. Expected: %s %d %d, Got: %s %d %d (%v)", index, td.status, td.failed, td.sets, r.Status, r.FailedInstance, len(r.Chain), r.Err)
+		}
+		if td.status == ARCFail && r.Err == nil {
+			t.Errorf("[Test Case %v] Expected an error", index)
+		}
+	}
+}
+
+func TestARCChain(t *testing.T) {
+	e, err := Parse(bytes.NewReader([]byte("ARC-Seal: i=1; a=rsa-sha256; cv=none; d=example.org; s=arc; t=1700000000; b=YWJj\r\n" +
+		"ARC-Message-Signature: i=1; a=rsa-sha256; c=relaxed/relaxed; d=example.org; s=arc; h=from; bh=YWJj; b=ZGVm\r\n" +
+		"ARC-Authentication-Results: i=1; mx.example.org; dkim=pass header.d=example.com\r\n" +
+		"From: jdoe@example.com\r\n\r\nHi\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := e.ARCChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 1 || chain[0].Instance != 1 {
+		t.Fatalf("Wrong chain. Got: %+v", chain)
+	}
+
+	s := chain[0]
+	if s.Seal.ChainValidation != ARCNone || s.Seal.Domain != "example.org" || s.Seal.Timestamp.Unix() != 1700000000 || string(s.Seal.Signature) != "abc" {
+		t.Errorf("Wrong seal. Got: %+v", s.Seal)
+	}
+	if s.MessageSignature.Selector != "arc" || s.MessageSignature.Identity != "@example.org" {
+		t.Errorf("Wrong message signature. Got: %+v", s.MessageSignature)
+	}
+	if dkim, ok := s.AuthenticationResults.Result("dkim"); s.AuthenticationResults.AuthServID != "mx.example.org" || !ok || dkim.Property("header.d") != "example.com" {
+		t.Errorf("Wrong authentication results. Got: %+v", s.AuthenticationResults)
+	}
+
+	var testData = map[int]string{
+		1: "ARC-Seal: i=1; a=rsa-sha256; cv=none; d=example.org; s=arc; b=YWJj\r\nFrom: jdoe@example.com\r\n\r\nHi\r\n",
+		2: "ARC-Authentication-Results: mx.example.org; none\r\nFrom: jdoe@example.com\r\n\r\nHi\r\n",
+		3: "ARC-Seal: i=1; a=rsa-sha256; cv=none; d=example.org; s=arc; h=from; b=YWJj\r\nFrom: jdoe@example.com\r\n\r\nHi\r\n",
+		4: "ARC-Seal: i=51; a=rsa-sha256; cv=none; d=example.org; s=arc; b=YWJj\r\nFrom: jdoe@example.com\r\n\r\nHi\r\n",
+	}
+
+	for index, raw := range testData {
+		e, err := ParseWithOptions(bytes.NewReader([]byte(raw)), Options{KeepRaw: true})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if _, err := e.ARCChain(); err == nil {
+			t.Errorf("[Test Case %v] Expected an error", index)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package parsemail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/text/transform"
+)
+
+// bytes returns the data of the attachment and resets Data so it can be read
+// again
+func (a *Attachment) bytes() ([]byte, error) {
+	return rewindData(&a.Data)
+}
+
+// bytes returns the data of the embedded file and resets Data so it can be
+// read again
+func (e *EmbeddedFile) bytes() ([]byte, error) {
+	return rewindData(&e.Data)
+}
+
+// rewindData reads all of data from its start and replaces it with a reader
+// over the returned bytes. Readers decoding their content when first read
+// buffer it when they are rewound, so the copies of an attachment sharing
+// them still read all of it.
+func rewindData(data *io.Reader) ([]byte, error) {
+	if *data == nil {
+		return nil, nil
+	}
+
+	if s, ok := (*data).(io.Seeker); ok {
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := ioutil.ReadAll(*data)
+	if err != nil {
+		return nil, err
+	}
+	*data = bytes.NewReader(b)
+
+	return b, nil
+}
+
+// bufferedReader returns r once it holds the whole content opened by open,
+// reading the content into a buffer the first time. The readers of
+// attachments decoding their content when first read are shared by the
+// copies of an attachment, which each seek before reading it.
+func bufferedReader(r *io.Reader, open func() (io.Reader, error)) (*bytes.Reader, error) {
+	if b, ok := (*r).(*bytes.Reader); ok {
+		return b, nil
+	}
+
+	content, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+
+	b := bytes.NewReader(data)
+	*r = b
+
+	return b, nil
+}
+
+// DecodedText returns the content of a text/* attachment converted from the
+// charset given in its Content-Type to UTF-8, the same way text bodies are
+// decoded, applying the CharsetFallback and Strict options the attachment
+// was parsed with. Data can still be read afterwards.
+func (a *Attachment) DecodedText() (string, error) {
+	if !strings.HasPrefix(strings.ToLower(a.ContentType), "text/") {
+		return "", fmt.Errorf("attachment %s is not text: %s", a.Filename, a.ContentType)
+	}
+
+	return a.text()
+}
+
+// text returns the data converted to UTF-8 regardless of the content type
+func (a *Attachment) text() (string, error) {
+	b, err := a.bytes()
+	if err != nil {
+		return "", err
+	}
+
+	if len(b) == 0 {
+		return "", nil
+	}
+
+	preview := b
+	if len(preview) > 1024 {
+		preview = preview[:1024]
+	}
+
+	enc, err := (&parser{opts: a.opts}).textEncoding(preview, a.RawContentType)
+	if err != nil {
+		return "", err
+	}
+
+	decoded, err := ioutil.ReadAll(transform.NewReader(bytes.NewReader(b), enc.NewDecoder()))
+
+	return string(decoded), err
+}
@@ -0,0 +1,238 @@
+package parsemail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestAttachmentDecodedText(t *testing.T) {
+	e, err := Parse(strings.NewReader(latin1Attachment))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.Attachments) != 2 {
+		t.Fatalf("Incorrect number of attachments! Expected: 2, Got: %v.", len(e.Attachments))
+	}
+
+	text, err := e.Attachments[0].DecodedText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text != "name;city\nJürgen;Köln\n" {
+		t.Errorf("Wrong decoded text: %q", text)
+	}
+
+	b, err := ioutil.ReadAll(e.Attachments[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "name;city\nJ\xfcrgen;K\xf6ln\n" {
+		t.Errorf("Wrong raw data after decoding: %q", b)
+	}
+
+	if _, err := e.Attachments[1].DecodedText(); err == nil {
+		t.Errorf("Expected an error for a binary attachment")
+	}
+
+	// the charset options the attachment was parsed with apply
+	unknown := strings.Replace(latin1Attachment, "charset=iso-8859-1", "charset=x-unknown", 1)
+	e, err = ParseWithOptions(strings.NewReader(unknown), Options{CharsetFallback: "iso-8859-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, err := e.Attachments[0].DecodedText(); err != nil || text != "name;city\nJürgen;Köln\n" {
+		t.Errorf("Wrong decoded text with a charset fallback: %q, %v", text, err)
+	}
+
+	e, err = ParseWithOptions(strings.NewReader(unknown), Options{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Attachments[0].DecodedText(); err == nil {
+		t.Error("Expected an error for an unsupported charset")
+	}
+}
+
+func TestLazyAttachmentCopies(t *testing.T) {
+	e, err := ParseWithOptions(strings.NewReader(latin1Attachment), Options{LazyAttachments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// copies share the reader decoding the content, each reads all of it
+	copies := append([]Attachment(nil), e.Attachments...)
+	for i, at := range [][]Attachment{e.Attachments, copies} {
+		text, err := at[0].DecodedText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != "name;city\nJürgen;Köln\n" {
+			t.Errorf("[Test Case %v] Wrong decoded text: %q", i, text)
+		}
+	}
+
+	b, err := ioutil.ReadAll(copies[1].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := e.Attachments[1].bytes(); err != nil || !bytes.Equal(data, b) || len(b) != 3 {
+		t.Errorf("Wrong data of a copy read before. Expected: %q, Got: %q, %v", b, data, err)
+	}
+}
+
+var latin1Attachment = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Report
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Content-Type: multipart/mixed; boundary="MIX"
+
+--MIX
+Content-Type: text/plain
+
+See attachment.
+--MIX
+Content-Type: text/csv; charset=iso-8859-1; name="report.csv"
+Content-Disposition: attachment; filename="report.csv"
+Content-Transfer-Encoding: quoted-printable
+
+name;city
+J=FCrgen;K=F6ln
+
+--MIX
+Content-Type: application/octet-stream; name="report.bin"
+Content-Disposition: attachment; filename="report.bin"
+Content-Transfer-Encoding: base64
+
+AAEC
+--MIX--
+`
+
+func TestAttachmentFilenameVersions(t *testing.T) {
+	var testData = map[int]struct {
+		disposition string
+		version     int
+		filename    string
+	}{
+		1: {disposition: `attachment; filename="Rechnung =?utf-8?q?M=C3=A4rz.pdf?="`, filename: "Rechnung März.pdf"},
+		2: {disposition: `attachment; filename="Rechnung =?utf-8?q?M=C3=A4rz.pdf?="`, version: 3, filename: "RechnungMärz.pdf"},
+		3: {disposition: `attachment; filename="=?big5?b?pKSk5S5wZGY=?="`, filename: "中文.pdf"},
+		4: {disposition: `attachment; filename="=?big5?b?pKSk5S5wZGY=?="`, version: 3, filename: "=?big5?b?pKSk5S5wZGY=?="},
+	}
+
+	for index, td := range testData {
+		raw := "From: a@example.com\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+			"--b\r\nContent-Type: text/plain\r\n\r\nSee attached\r\n" +
+			"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: " + td.disposition + "\r\n\r\n%PDF\r\n" +
+			"--b--\r\n"
+
+		e, err := ParseWithOptions(strings.NewReader(raw), Options{SchemaVersion: td.version})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if len(e.Attachments) != 1 {
+			t.Fatalf("[Test Case %v] Incorrect number of attachments! Expected: 1, Got: %v.", index, len(e.Attachments))
+		}
+
+		if e.Attachments[0].Filename != td.filename {
+			t.Errorf("[Test Case %v] Wrong filename. Expected: %q, Got: %q", index, td.filename, e.Attachments[0].Filename)
+		}
+	}
+}
+
+func TestAttachmentNameFallback(t *testing.T) {
+	var testData = map[int]struct {
+		contentType string
+		disposition string
+		filename    string
+	}{
+		1: {contentType: `application/pdf; name="invoice.pdf"`, disposition: "attachment", filename: "invoice.pdf"},
+		2: {contentType: `application/pdf; name="=?utf-8?q?Rechnung_M=C3=A4rz.pdf?="`, disposition: "attachment", filename: "Rechnung März.pdf"},
+		3: {contentType: `application/pdf; name*=iso-8859-1''R%E9sum%E9.pdf`, disposition: "attachment", filename: "Résumé.pdf"},
+		4: {contentType: `application/pdf; name="name.pdf"`, disposition: `attachment; filename="filename.pdf"`, filename: "filename.pdf"},
+		5: {contentType: `application/pdf; name="C:/Documents/plan.pdf"`, disposition: "attachment", filename: "plan.pdf"},
+		6: {contentType: "application/pdf", disposition: "attachment", filename: ""},
+	}
+
+	for index, td := range testData {
+		raw := "From: a@example.com\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+			"--b\r\nContent-Type: text/plain\r\n\r\nSee attached\r\n" +
+			"--b\r\nContent-Type: " + td.contentType + "\r\nContent-Disposition: " + td.disposition + "\r\n\r\n%PDF\r\n" +
+			"--b--\r\n"
+
+		e, err := Parse(strings.NewReader(raw))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if len(e.Attachments) != 1 {
+			t.Fatalf("[Test Case %v] Incorrect number of attachments! Expected: 1, Got: %v.", index, len(e.Attachments))
+		}
+
+		if e.Attachments[0].Filename != td.filename {
+			t.Errorf("[Test Case %v] Wrong filename. Expected: %q, Got: %q", index, td.filename, e.Attachments[0].Filename)
+		}
+
+		// schema versions before 6 only take the Content-Disposition
+		e, err = ParseWithOptions(strings.NewReader(raw), Options{SchemaVersion: 5})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		expected := ""
+		if strings.Contains(td.disposition, "filename") {
+			expected = td.filename
+		}
+		if e.Attachments[0].Filename != expected {
+			t.Errorf("[Test Case %v] Wrong version 5 filename. Expected: %q, Got: %q", index, expected, e.Attachments[0].Filename)
+		}
+	}
+}
+
+func TestUnknownNestedParts(t *testing.T) {
+	var testData = map[int]struct {
+		raw         string
+		contentType string
+	}{
+		1: {
+			raw: "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nKey attached\r\n" +
+				"--b\r\nContent-Type: application/pgp-keys\r\n\r\n-----BEGIN PGP PUBLIC KEY BLOCK-----\r\n--b--\r\n",
+			contentType: "application/pgp-keys",
+		},
+		2: {
+			raw: "Content-Type: multipart/alternative; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nKey attached\r\n" +
+				"--b\r\nContent-Type: text/enriched\r\n\r\n<bold>Key</bold> attached\r\n--b--\r\n",
+			contentType: "text/enriched",
+		},
+		3: {
+			raw: "Content-Type: multipart/related; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nKey attached\r\n" +
+				"--b\r\nContent-Type: model/gltf+json\r\n\r\n{}\r\n--b--\r\n",
+			contentType: "model/gltf+json",
+		},
+	}
+
+	for index, td := range testData {
+		raw := "From: a@example.com\r\nSubject: Key\r\n" + td.raw
+
+		if _, err := ParseWithOptions(strings.NewReader(raw), Options{SchemaVersion: 1}); err == nil {
+			t.Errorf("[Test Case %v] Unknown part parsed with schema version 1", index)
+		}
+
+		e, err := Parse(strings.NewReader(raw))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if e.TextBody != "Key attached" {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %s, Got: %q", index, "Key attached", e.TextBody)
+		}
+		if len(e.Attachments) != 1 || e.Attachments[0].ContentType != td.contentType {
+			t.Errorf("[Test Case %v] Wrong attachments. Expected: %s, Got: %+v", index, td.contentType, e.Attachments)
+		}
+	}
+}
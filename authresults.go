@@ -0,0 +1,220 @@
+package parsemail
+
+import (
+	"strconv"
+	"strings"
+)
+
+// AuthenticationResults is a parsed Authentication-Results field (RFC 8601),
+// recording the checks an inbound server made
+type AuthenticationResults struct {
+	// AuthServID identifies the server that made the checks
+	AuthServID string
+	Version    int
+	Results    []AuthenticationResult
+}
+
+// AuthenticationResult is the result of one method, like dkim, spf, dmarc or
+// arc. Method and Result are lower case.
+type AuthenticationResult struct {
+	Method     string
+	Result     string
+	Reason     string
+	Properties []AuthenticationProperty
+}
+
+// AuthenticationProperty is a property of a result, like header.d=example.com
+// with type "header", property "d" and value "example.com". Type and property
+// are lower case.
+type AuthenticationProperty struct {
+	Type     string
+	Property string
+	Value    string
+}
+
+// Result returns the first result of method
+func (ar AuthenticationResults) Result(method string) (AuthenticationResult, bool) {
+	for _, r := range ar.Results {
+		if r.Method == strings.ToLower(method) {
+			return r, true
+		}
+	}
+
+	return AuthenticationResult{}, false
+}
+
+// Property returns the value of the first property named "type.property",
+// e.g. "header.d" or "smtp.mailfrom"
+func (r AuthenticationResult) Property(name string) string {
+	name = strings.ToLower(name)
+	for _, p := range r.Properties {
+		if p.Type+"."+p.Property == name {
+			return p.Value
+		}
+	}
+
+	return ""
+}
+
+// parseAuthenticationResults parses Authentication-Results fields. Fields
+// without authserv-id are skipped, malformed results within a field too.
+func parseAuthenticationResults(values []string) (results []AuthenticationResults) {
+	for _, v := range values {
+		if ar, ok := ParseAuthenticationResults(v); ok {
+			results = append(results, ar)
+		}
+	}
+
+	return
+}
+
+// ParseAuthenticationResults parses the value of an Authentication-Results
+// field. Comments are ignored. ok is false if the value has no authserv-id.
+func ParseAuthenticationResults(value string) (ar AuthenticationResults, ok bool) {
+	tokens := lexAuthenticationResults(value)
+
+	// the authserv-id and optional version precede the first result
+	i := 0
+	for ; i < len(tokens) && tokens[i] != ";"; i++ {
+		switch {
+		case i == 0:
+			ar.AuthServID = unquoteAuthToken(tokens[i])
+		case i == 1:
+			ar.Version, _ = strconv.Atoi(tokens[i])
+		}
+	}
+	if ar.AuthServID == "" {
+		return ar, false
+	}
+	if ar.Version == 0 {
+		ar.Version = 1
+	}
+
+	for i < len(tokens) {
+		// skip the ";" and collect the tokens of the result
+		start := i + 1
+		for i = start; i < len(tokens) && tokens[i] != ";"; i++ {
+		}
+
+		if r, ok := parseAuthenticationResult(tokens[start:i]); ok {
+			ar.Results = append(ar.Results, r)
+		}
+	}
+
+	return ar, true
+}
+
+// parseAuthenticationResult parses the "name = value" pairs of a result, the
+// first being the method and its result
+func parseAuthenticationResult(tokens []string) (r AuthenticationResult, ok bool) {
+	for j := 0; j < len(tokens); {
+		if j+1 >= len(tokens) || tokens[j+1] != "=" {
+			return r, false
+		}
+		first := j == 0
+
+		name := strings.ToLower(tokens[j])
+		value := ""
+		j += 2
+		// a missing value is followed by the next name
+		if j < len(tokens) && tokens[j] != "=" && (j+1 >= len(tokens) || tokens[j+1] != "=") {
+			value = unquoteAuthToken(tokens[j])
+			j++
+		}
+
+		switch {
+		case first:
+			// the method may carry a version, "dkim/1"
+			if slash := strings.IndexByte(name, '/'); slash >= 0 {
+				name = name[:slash]
+			}
+			r.Method, r.Result = name, strings.ToLower(value)
+		case name == "reason":
+			r.Reason = value
+		default:
+			dot := strings.IndexByte(name, '.')
+			if dot < 0 {
+				continue
+			}
+			r.Properties = append(r.Properties, AuthenticationProperty{
+				Type:     name[:dot],
+				Property: name[dot+1:],
+				Value:    value,
+			})
+		}
+	}
+
+	return r, r.Method != "" && r.Result != ""
+}
+
+// lexAuthenticationResults splits a field value into quoted strings, the
+// separators ";" and "=" and the runs of other characters between them,
+// dropping white space and comments
+func lexAuthenticationResults(s string) (tokens []string) {
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '(':
+			depth := 0
+			for ; i < len(s); i++ {
+				if s[i] == '\\' {
+					i++
+				} else if s[i] == '(' {
+					depth++
+				} else if s[i] == ')' {
+					if depth--; depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		case c == ')':
+			// unbalanced
+			i++
+		case c == ';' || c == '=':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j < len(s) {
+				j++
+			}
+			tokens = append(tokens, s[i:minInt(j, len(s))])
+			i = j
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n();=\"", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+
+	return
+}
+
+// unquoteAuthToken returns the content of a quoted string token
+func unquoteAuthToken(token string) string {
+	if !strings.HasPrefix(token, `"`) {
+		return token
+	}
+
+	token = strings.TrimSuffix(token[1:], `"`)
+
+	var b strings.Builder
+	for i := 0; i < len(token); i++ {
+		if token[i] == '\\' && i+1 < len(token) {
+			i++
+		}
+		b.WriteByte(token[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAuthenticationResults(t *testing.T) {
+	var testData = map[int]struct {
+		value      string
+		ok         bool
+		authServID string
+		version    int
+		results    []AuthenticationResult
+	}{
+		1: {
+			value:      "example.org 1; none",
+			ok:         true,
+			authServID: "example.org",
+			version:    1,
+		},
+		2: {
+			value: "mx.google.com;\r\n       dkim=pass header.i=@example.com header.s=mail header.b=\"aB/c+1==\";\r\n" +
+				"       spf=pass (google.com: domain of jdoe@example.com designates 192.0.2.1 as permitted sender) smtp.mailfrom=jdoe@example.com;\r\n" +
+				"       dmarc=pass (p=REJECT sp=REJECT dis=NONE) header.from=example.com",
+			ok:         true,
+			authServID: "mx.google.com",
+			version:    1,
+			results: []AuthenticationResult{
+				{Method: "dkim", Result: "pass", Properties: []AuthenticationProperty{
+					{Type: "header", Property: "i", Value: "@example.com"},
+					{Type: "header", Property: "s", Value: "mail"},
+					{Type: "header", Property: "b", Value: "aB/c+1=="},
+				}},
+				{Method: "spf", Result: "pass", Properties: []AuthenticationProperty{
+					{Type: "smtp", Property: "mailfrom", Value: "jdoe@example.com"},
+				}},
+				{Method: "dmarc", Result: "pass", Properties: []AuthenticationProperty{
+					{Type: "header", Property: "from", Value: "example.com"},
+				}},
+			},
+		},
+		3: {
+			value:      "\"relay (primary)\" (comment) 2; DKIM/1 = FAIL reason=\"signature \\\"expired\\\"\" header.d=example.com; arc=none; broken; spf=",
+			ok:         true,
+			authServID: "relay (primary)",
+			version:    2,
+			results: []AuthenticationResult{
+				{Method: "dkim", Result: "fail", Reason: "signature \"expired\"", Properties: []AuthenticationProperty{
+					{Type: "header", Property: "d", Value: "example.com"},
+				}},
+				{Method: "arc", Result: "none"},
+			},
+		},
+		4: {
+			value: "(only a comment)) ",
+			ok:    false,
+		},
+	}
+
+	for index, td := range testData {
+		ar, ok := ParseAuthenticationResults(td.value)
+		if ok != td.ok {
+			t.Errorf("[Test Case %v] Wrong ok. Expected: %v, Got: %v", index, td.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if ar.AuthServID != td.authServID || ar.Version != td.version {
+			t.Errorf("[Test Case %v] Wrong authserv-id. Expected: %s %d, Got: %s %d", index, td.authServID, td.version, ar.AuthServID, ar.Version)
+		}
+		if len(ar.Results) != len(td.results) {
+			t.Errorf("[Test Case %v] Wrong number of results. Expected: %v, Got: %+v", index, len(td.results), ar.Results)
+			continue
+		}
+		for i, r := range ar.Results {
+			expected := td.results[i]
+			if r.Method != expected.Method || r.Result != expected.Result || r.Reason != expected.Reason || len(r.Properties) != len(expected.Properties) {
+				t.Errorf("[Test Case %v] Wrong result %d. Expected: %+v, Got: %+v", index, i, expected, r)
+				continue
+			}
+			for j, p := range r.Properties {
+				if p != expected.Properties[j] {
+					t.Errorf("[Test Case %v] Wrong property. Expected: %+v, Got: %+v", index, expected.Properties[j], p)
+				}
+			}
+		}
+	}
+}
+
+func TestEmailAuthenticationResults(t *testing.T) {
+	e, err := Parse(strings.NewReader("Authentication-Results: mx2.example.org; dmarc=fail header.from=example.com\r\n" +
+		"Authentication-Results: mx1.example.org; spf=softfail smtp.mailfrom=example.com\r\n" +
+		"Authentication-Results: ;\r\n" +
+		"From: jdoe@example.com\r\n" +
+		"\r\n" +
+		"Hi\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.AuthenticationResults) != 2 {
+		t.Fatalf("Wrong number of fields. Expected: 2, Got: %+v", e.AuthenticationResults)
+	}
+	if e.AuthenticationResults[0].AuthServID != "mx2.example.org" {
+		t.Errorf("Wrong order. Got: %+v", e.AuthenticationResults)
+	}
+
+	dmarc, ok := e.AuthenticationResults[0].Result("DMARC")
+	if !ok || dmarc.Result != "fail" || dmarc.Property("Header.From") != "example.com" {
+		t.Errorf("Wrong dmarc result. Got: %+v", dmarc)
+	}
+	if _, ok := e.AuthenticationResults[1].Result("dkim"); ok {
+		t.Errorf("Expected no dkim result")
+	}
+}
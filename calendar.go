@@ -0,0 +1,126 @@
+package parsemail
+
+import (
+	"strings"
+	"time"
+)
+
+const contentTypeTextCalendar = "text/calendar"
+
+// Calendar is an iCalendar object (RFC 5545) sent as text/calendar body,
+// usually a meeting invitation or reply
+type Calendar struct {
+	Method string
+	Data   []byte
+	Events []CalendarEvent
+}
+
+// CalendarEvent holds the commonly used properties of a VEVENT component
+type CalendarEvent struct {
+	UID       string
+	Summary   string
+	Location  string
+	Organizer string
+	Start     time.Time
+	End       time.Time
+}
+
+// parseCalendar extracts the method and events of the decoded iCalendar
+// data. The method parameter of the Content-Type takes precedence over the
+// METHOD property.
+func parseCalendar(data []byte, method string) Calendar {
+	c := Calendar{Method: strings.ToUpper(method), Data: data}
+
+	var event *CalendarEvent
+	for _, line := range unfoldCalendarLines(string(data)) {
+		name, params, value := splitCalendarLine(line)
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			event = &CalendarEvent{}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if event != nil {
+				c.Events = append(c.Events, *event)
+				event = nil
+			}
+		case name == "METHOD" && c.Method == "":
+			c.Method = strings.ToUpper(value)
+		case event == nil:
+			continue
+		case name == "UID":
+			event.UID = value
+		case name == "SUMMARY":
+			event.Summary = unescapeCalendarText(value)
+		case name == "LOCATION":
+			event.Location = unescapeCalendarText(value)
+		case name == "ORGANIZER":
+			if strings.HasPrefix(strings.ToLower(value), "mailto:") {
+				value = value[len("mailto:"):]
+			}
+			event.Organizer = value
+		case name == "DTSTART":
+			event.Start = parseCalendarTime(value, params["TZID"])
+		case name == "DTEND":
+			event.End = parseCalendarTime(value, params["TZID"])
+		}
+	}
+
+	return c
+}
+
+func unfoldCalendarLines(s string) (lines []string) {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	for _, line := range strings.Split(s, "\n") {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return
+}
+
+// splitCalendarLine splits a content line into its upper cased name, its
+// parameters and its value
+func splitCalendarLine(line string) (name string, params map[string]string, value string) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+
+	value = line[i+1:]
+	parts := strings.Split(line[:i], ";")
+	name = strings.ToUpper(parts[0])
+	params = map[string]string{}
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	return
+}
+
+func parseCalendarTime(value, tzid string) time.Time {
+	loc := time.UTC
+	if tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	for _, format := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(format, value, loc); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func unescapeCalendarText(s string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return r.Replace(s)
+}
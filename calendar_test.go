@@ -0,0 +1,117 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendars(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		method   string
+		events   []CalendarEvent
+		textBody string
+	}{
+		1: {
+			mailData: calendarTopLevel,
+			method:   "REQUEST",
+			events: []CalendarEvent{
+				{
+					UID:       "meeting-1@example.net",
+					Summary:   "Planning, next steps",
+					Location:  "Room 1",
+					Organizer: "mary@example.net",
+					Start:     time.Date(2020, 2, 13, 14, 0, 0, 0, time.UTC),
+					End:       time.Date(2020, 2, 13, 15, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		2: {
+			mailData: calendarAlternative,
+			method:   "CANCEL",
+			textBody: "The meeting is cancelled.",
+			events: []CalendarEvent{
+				{
+					UID:   "meeting-2@example.net",
+					Start: time.Date(2020, 2, 14, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if e.TextBody != td.textBody {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, td.textBody, e.TextBody)
+		}
+
+		if len(e.Calendars) != 1 {
+			t.Errorf("[Test Case %v] Incorrect number of calendars! Expected: 1, Got: %v.", index, len(e.Calendars))
+			continue
+		}
+
+		c := e.Calendars[0]
+		if c.Method != td.method {
+			t.Errorf("[Test Case %v] Wrong method. Expected: %s, Got: %s", index, td.method, c.Method)
+		}
+
+		if len(c.Events) != len(td.events) {
+			t.Errorf("[Test Case %v] Wrong events. Expected: %v, Got: %v", index, td.events, c.Events)
+			continue
+		}
+
+		for i, ev := range c.Events {
+			expected := td.events[i]
+			if ev.UID != expected.UID || ev.Summary != expected.Summary || ev.Location != expected.Location ||
+				ev.Organizer != expected.Organizer || !ev.Start.Equal(expected.Start) || !ev.End.Equal(expected.End) {
+				t.Errorf("[Test Case %v] Wrong event. Expected: %v, Got: %v", index, expected, ev)
+			}
+		}
+	}
+}
+
+var calendarTopLevel = "From: Mary Smith <mary@example.net>\r\n" +
+	"To: John Doe <jdoe@machine.example>\r\n" +
+	"Subject: Invitation: Planning\r\n" +
+	"Date: Thu, 13 Feb 2020 13:39:30 +0000\r\n" +
+	"Content-Type: text/calendar; charset=utf-8; method=REQUEST\r\n" +
+	"\r\n" +
+	"BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"METHOD:PUBLISH\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:meeting-1@example.net\r\n" +
+	"SUMMARY:Planning\\, next\r\n" +
+	"  steps\r\n" +
+	"LOCATION:Room 1\r\n" +
+	"ORGANIZER;CN=Mary Smith:mailto:mary@example.net\r\n" +
+	"DTSTART:20200213T140000Z\r\n" +
+	"DTEND:20200213T150000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+var calendarAlternative = `From: Mary Smith <mary@example.net>
+To: John Doe <jdoe@machine.example>
+Subject: Cancelled: Planning
+Date: Thu, 13 Feb 2020 13:39:30 +0000
+Content-Type: multipart/alternative; boundary="ALT"
+
+--ALT
+Content-Type: text/plain; charset=utf-8
+
+The meeting is cancelled.
+--ALT
+Content-Type: text/calendar; charset=utf-8
+Content-Transfer-Encoding: base64
+
+QkVHSU46VkNBTEVOREFSDQpNRVRIT0Q6Q0FOQ0VMDQpCRUdJTjpWRVZFTlQNClVJRDptZWV0aW5n
+LTJAZXhhbXBsZS5uZXQNCkRUU1RBUlQ7VkFMVUU9REFURToyMDIwMDIxNA0KRU5EOlZFVkVOVA0K
+RU5EOlZDQUxFTkRBUg0K
+--ALT--
+`
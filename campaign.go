@@ -0,0 +1,85 @@
+package parsemail
+
+import (
+	"strings"
+)
+
+// CampaignInfo normalizes the campaign and tracking headers added by email
+// service providers, used to attribute complaints and bounces to sends
+type CampaignInfo struct {
+	Provider          string
+	CampaignID        string
+	Tags              []string
+	ProviderMessageID string
+	FeedbackID        []string
+	ReportAbuse       string
+}
+
+// CampaignInfo extracts the ESP campaign headers (X-Campaign, X-Mailgun-*,
+// X-SES-*, X-Mandrill-*/X-MC-*, X-SG-*, Feedback-ID, X-Report-Abuse) of the
+// email. It returns nil if none of them are present.
+func (e *Email) CampaignInfo() *CampaignInfo {
+	h := e.Header
+	ci := CampaignInfo{}
+
+	switch {
+	case h.Get("X-Mailgun-Tag") != "" || h.Get("X-Mailgun-Sid") != "" || h.Get("X-Mailgun-Campaign-Id") != "":
+		ci.Provider = "mailgun"
+		ci.CampaignID = h.Get("X-Mailgun-Campaign-Id")
+		ci.Tags = headerValues(h["X-Mailgun-Tag"])
+		ci.ProviderMessageID = h.Get("X-Mailgun-Sid")
+	case h.Get("X-Ses-Message-Id") != "" || h.Get("X-Ses-Outgoing") != "":
+		ci.Provider = "ses"
+		ci.ProviderMessageID = h.Get("X-Ses-Message-Id")
+		ci.CampaignID = h.Get("X-Ses-Configuration-Set")
+	case h.Get("X-Mandrill-User") != "" || h.Get("X-Mc-User") != "":
+		ci.Provider = "mandrill"
+		ci.Tags = headerValues(h["X-Mc-Tags"])
+		ci.CampaignID = h.Get("X-Mc-Campaign")
+	case h.Get("X-Sg-Eid") != "" || h.Get("X-Sg-Id") != "":
+		ci.Provider = "sendgrid"
+		ci.ProviderMessageID = h.Get("X-Sg-Eid")
+		if ci.ProviderMessageID == "" {
+			ci.ProviderMessageID = h.Get("X-Sg-Id")
+		}
+	}
+
+	if ci.CampaignID == "" {
+		for _, name := range []string{"X-Campaign", "X-Campaign-Id", "X-Campaignid", "X-Mc-Campaign"} {
+			if v := h.Get(name); v != "" {
+				ci.CampaignID = strings.TrimSpace(v)
+				break
+			}
+		}
+	}
+
+	if v := h.Get("Feedback-Id"); v != "" {
+		for _, f := range strings.Split(v, ":") {
+			ci.FeedbackID = append(ci.FeedbackID, strings.TrimSpace(f))
+		}
+	}
+
+	ci.ReportAbuse = h.Get("X-Report-Abuse")
+	if ci.ReportAbuse == "" {
+		ci.ReportAbuse = h.Get("X-Report-Abuse-To")
+	}
+
+	if ci.Provider == "" && ci.CampaignID == "" && len(ci.FeedbackID) == 0 && ci.ReportAbuse == "" {
+		return nil
+	}
+
+	return &ci
+}
+
+// headerValues splits comma separated values of all fields
+func headerValues(fields []string) (values []string) {
+	for _, f := range fields {
+		for _, v := range strings.Split(f, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+
+	return
+}
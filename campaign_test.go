@@ -0,0 +1,86 @@
+package parsemail
+
+import (
+	"net/mail"
+	"testing"
+)
+
+func TestCampaignInfo(t *testing.T) {
+	var testData = map[int]struct {
+		header   mail.Header
+		campaign *CampaignInfo
+	}{
+		1: {
+			header: mail.Header{"Subject": {"Hello"}},
+		},
+		2: {
+			header: mail.Header{
+				"X-Mailgun-Tag":         {"newsletter", "weekly"},
+				"X-Mailgun-Campaign-Id": {"c123"},
+				"X-Mailgun-Sid":         {"WyI0ZTNhZiJd"},
+				"Feedback-Id":           {"c123:newsletter:mailgun:1234"},
+			},
+			campaign: &CampaignInfo{
+				Provider:          "mailgun",
+				CampaignID:        "c123",
+				Tags:              []string{"newsletter", "weekly"},
+				ProviderMessageID: "WyI0ZTNhZiJd",
+				FeedbackID:        []string{"c123", "newsletter", "mailgun", "1234"},
+			},
+		},
+		3: {
+			header: mail.Header{
+				"X-Ses-Message-Id":        {"0100017044a4b3a1-bd0a"},
+				"X-Ses-Configuration-Set": {"marketing"},
+			},
+			campaign: &CampaignInfo{
+				Provider:          "ses",
+				CampaignID:        "marketing",
+				ProviderMessageID: "0100017044a4b3a1-bd0a",
+			},
+		},
+		4: {
+			header: mail.Header{
+				"X-Mandrill-User": {"md_123"},
+				"X-Mc-Tags":       {"welcome, onboarding"},
+				"X-Report-Abuse":  {"Please forward a copy of this message to abuse@mandrill.com"},
+			},
+			campaign: &CampaignInfo{
+				Provider:    "mandrill",
+				Tags:        []string{"welcome", "onboarding"},
+				ReportAbuse: "Please forward a copy of this message to abuse@mandrill.com",
+			},
+		},
+		5: {
+			header: mail.Header{
+				"X-Campaign": {"spring-sale"},
+			},
+			campaign: &CampaignInfo{
+				CampaignID: "spring-sale",
+			},
+		},
+	}
+
+	for index, td := range testData {
+		e := Email{Header: td.header}
+		ci := e.CampaignInfo()
+
+		if td.campaign == nil {
+			if ci != nil {
+				t.Errorf("[Test Case %v] Unexpected campaign info: %v", index, ci)
+			}
+			continue
+		}
+
+		if ci == nil {
+			t.Errorf("[Test Case %v] Campaign info not found", index)
+			continue
+		}
+
+		if ci.Provider != td.campaign.Provider || ci.CampaignID != td.campaign.CampaignID ||
+			ci.ProviderMessageID != td.campaign.ProviderMessageID || ci.ReportAbuse != td.campaign.ReportAbuse ||
+			!assertSliceEq(ci.Tags, td.campaign.Tags) || !assertSliceEq(ci.FeedbackID, td.campaign.FeedbackID) {
+			t.Errorf("[Test Case %v] Wrong campaign info. Expected: %v, Got: %v", index, td.campaign, ci)
+		}
+	}
+}
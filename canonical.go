@@ -0,0 +1,146 @@
+package parsemail
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Canonicalization is a DKIM canonicalization algorithm (RFC 6376 section 3.4)
+type Canonicalization string
+
+const (
+	CanonicalizationSimple  Canonicalization = "simple"
+	CanonicalizationRelaxed Canonicalization = "relaxed"
+)
+
+// ParseCanonicalization parses the value of the c= tag of a DKIM-Signature,
+// "header/body" or only the header algorithm, in which case the body uses
+// simple canonicalization
+func ParseCanonicalization(s string) (header, body Canonicalization, err error) {
+	parts := strings.SplitN(strings.ToLower(strings.TrimSpace(s)), "/", 2)
+	if parts[0] == "" {
+		return CanonicalizationSimple, CanonicalizationSimple, nil
+	}
+	if len(parts) == 1 {
+		parts = append(parts, string(CanonicalizationSimple))
+	}
+
+	for _, p := range parts {
+		if p != string(CanonicalizationSimple) && p != string(CanonicalizationRelaxed) {
+			return "", "", fmt.Errorf("unknown canonicalization: %s", s)
+		}
+	}
+
+	return Canonicalization(parts[0]), Canonicalization(parts[1]), nil
+}
+
+// CanonicalizeHeader canonicalizes a raw header field, including its line
+// break, with c. Bare LF line breaks are treated as CRLF.
+func CanonicalizeHeader(field []byte, c Canonicalization) []byte {
+	if c != CanonicalizationRelaxed {
+		return crlfLines(field)
+	}
+
+	i := bytes.IndexByte(field, ':')
+	if i < 0 {
+		return crlfLines(field)
+	}
+
+	name := strings.ToLower(strings.TrimRight(string(field[:i]), " \t"))
+	value := strings.Trim(string(collapseWhitespace([]byte(UnfoldHeader(string(field[i+1:]))))), " ")
+
+	return []byte(name + ":" + value + "\r\n")
+}
+
+// CanonicalizeBody canonicalizes a message body with c. Bare LF line breaks
+// are treated as CRLF.
+func CanonicalizeBody(body []byte, c Canonicalization) []byte {
+	lines := bytes.Split(crlfLines(body), []byte("\r\n"))
+
+	if c == CanonicalizationRelaxed {
+		for i, line := range lines {
+			lines[i] = bytes.TrimRight(collapseWhitespace(line), " ")
+		}
+	}
+
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		if c == CanonicalizationRelaxed {
+			return nil
+		}
+		return []byte("\r\n")
+	}
+
+	return append(bytes.Join(lines, []byte("\r\n")), '\r', '\n')
+}
+
+// CanonicalHeader returns the canonicalized header fields of the original
+// message selected by names, the way the h= tag of a DKIM-Signature selects
+// them: every name takes the last field of that name not taken yet.
+func (e *Email) CanonicalHeader(c Canonicalization, names ...string) []byte {
+	header, _ := splitHeader(e.raw)
+
+	var buf bytes.Buffer
+	for _, f := range selectHeaderFields(splitHeaderFields(header), names) {
+		buf.Write(CanonicalizeHeader(f.Raw, c))
+	}
+
+	return buf.Bytes()
+}
+
+// CanonicalBody returns the canonicalized body of the original message. The
+// body is empty unless the email was parsed with Options.KeepRaw.
+func (e *Email) CanonicalBody(c Canonicalization) []byte {
+	raw, _ := e.rawMessage()
+	_, body := splitHeader(raw)
+	return CanonicalizeBody(body, c)
+}
+
+// selectHeaderFields selects the fields named by names from the bottom up,
+// each field at most once. Names without a remaining field are skipped.
+func selectHeaderFields(fields []HeaderField, names []string) (selected []HeaderField) {
+	used := make([]bool, len(fields))
+	for _, name := range names {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fields[i].Name, name) {
+				used[i] = true
+				selected = append(selected, fields[i])
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// crlfLines converts bare LF line breaks to CRLF
+func crlfLines(b []byte) []byte {
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.Replace(b, []byte("\n"), []byte("\r\n"), -1)
+}
+
+// collapseWhitespace replaces runs of spaces and tabs with a single space
+func collapseWhitespace(line []byte) []byte {
+	out := make([]byte, 0, len(line))
+	space := false
+	for _, c := range line {
+		if c == ' ' || c == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			out = append(out, ' ')
+			space = false
+		}
+		out = append(out, c)
+	}
+	if space {
+		out = append(out, ' ')
+	}
+
+	return out
+}
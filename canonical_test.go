@@ -0,0 +1,95 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	// the examples of RFC 6376 section 3.4.5
+	header := "A: X\r\nB : Y\t\r\n\tZ  \r\n"
+	body := " C \r\nD \t E\r\n\r\n\r\n"
+
+	var testData = map[int]struct {
+		c              Canonicalization
+		expectedHeader string
+		expectedBody   string
+	}{
+		1: {c: CanonicalizationRelaxed, expectedHeader: "a:X\r\nb:Y Z\r\n", expectedBody: " C\r\nD E\r\n"},
+		2: {c: CanonicalizationSimple, expectedHeader: header, expectedBody: " C \r\nD \t E\r\n"},
+	}
+
+	for index, td := range testData {
+		var got []byte
+		for _, f := range splitHeaderFields([]byte(header)) {
+			got = append(got, CanonicalizeHeader(f.Raw, td.c)...)
+		}
+		if string(got) != td.expectedHeader {
+			t.Errorf("[Test Case %v] Wrong header. Expected: %q, Got: %q", index, td.expectedHeader, got)
+		}
+
+		if got := CanonicalizeBody([]byte(body), td.c); string(got) != td.expectedBody {
+			t.Errorf("[Test Case %v] Wrong body. Expected: %q, Got: %q", index, td.expectedBody, got)
+		}
+	}
+
+	if got := CanonicalizeBody(nil, CanonicalizationSimple); string(got) != "\r\n" {
+		t.Errorf("Wrong simple empty body: %q", got)
+	}
+	if got := CanonicalizeBody([]byte("\r\n\r\n"), CanonicalizationRelaxed); len(got) != 0 {
+		t.Errorf("Wrong relaxed empty body: %q", got)
+	}
+	if got := CanonicalizeBody([]byte("a \nb"), CanonicalizationRelaxed); string(got) != "a\r\nb\r\n" {
+		t.Errorf("Wrong relaxed LF body: %q", got)
+	}
+}
+
+func TestParseCanonicalization(t *testing.T) {
+	var testData = map[int]struct {
+		value  string
+		header Canonicalization
+		body   Canonicalization
+		err    bool
+	}{
+		1: {value: "relaxed/relaxed", header: CanonicalizationRelaxed, body: CanonicalizationRelaxed},
+		2: {value: "relaxed", header: CanonicalizationRelaxed, body: CanonicalizationSimple},
+		3: {value: "", header: CanonicalizationSimple, body: CanonicalizationSimple},
+		4: {value: " Simple/Relaxed ", header: CanonicalizationSimple, body: CanonicalizationRelaxed},
+		5: {value: "nowsp/simple", err: true},
+	}
+
+	for index, td := range testData {
+		header, body, err := ParseCanonicalization(td.value)
+		if (err != nil) != td.err {
+			t.Errorf("[Test Case %v] Wrong error: %v", index, err)
+			continue
+		}
+
+		if header != td.header || body != td.body {
+			t.Errorf("[Test Case %v] Wrong canonicalization. Expected: %s/%s, Got: %s/%s", index, td.header, td.body, header, body)
+		}
+	}
+}
+
+func TestEmailCanonical(t *testing.T) {
+	e, err := ParseWithOptions(strings.NewReader(canonicalMessage), Options{KeepRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := e.CanonicalHeader(CanonicalizationRelaxed, "From", "Subject", "Received", "Received", "Received", "X-Missing")
+	expected := "from:John Doe <jdoe@example.com>\r\nsubject:Hello world\r\nreceived:by b\r\nreceived:by a\r\n"
+	if string(header) != expected {
+		t.Errorf("Wrong canonical header. Expected: %q, Got: %q", expected, header)
+	}
+
+	if body := e.CanonicalBody(CanonicalizationRelaxed); string(body) != "Hi Mary,\r\nbye\r\n" {
+		t.Errorf("Wrong canonical body: %q", body)
+	}
+
+	if body := e.CanonicalBody(CanonicalizationSimple); string(body) != "Hi   Mary, \r\nbye\r\n" {
+		t.Errorf("Wrong simple canonical body: %q", body)
+	}
+}
+
+var canonicalMessage = "Received: by a\nReceived: by b\nFrom: John Doe <jdoe@example.com>\nSubject:  Hello\n\t world \nDate: Fri, 21 Nov 1997 09:55:06 -0600\n\nHi   Mary, \nbye\n\n\n"
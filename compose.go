@@ -0,0 +1,324 @@
+package parsemail
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// EmailBuilder composes an Email step by step. Errors of the steps, like
+// unparsable addresses or failing templates, are kept and returned by Build.
+//
+//	email, err := parsemail.NewEmail().
+//		From("John Doe <jdoe@example.com>").
+//		To("mary@example.com").
+//		Subject("Report").
+//		TextTemplate(t, data).
+//		Attach("report.csv", "text/csv", csv).
+//		Build()
+type EmailBuilder struct {
+	email    Email
+	images   map[string][]byte
+	fileRoot string
+	err      error
+}
+
+// NewEmail starts composing an email
+func NewEmail() *EmailBuilder {
+	return &EmailBuilder{email: Email{Header: mail.Header{}}}
+}
+
+// From adds senders to the From field
+func (b *EmailBuilder) From(addresses ...string) *EmailBuilder {
+	b.email.From = append(b.email.From, b.addresses(addresses)...)
+	return b
+}
+
+// To adds recipients to the To field
+func (b *EmailBuilder) To(addresses ...string) *EmailBuilder {
+	b.email.To = append(b.email.To, b.addresses(addresses)...)
+	return b
+}
+
+// Cc adds recipients to the Cc field
+func (b *EmailBuilder) Cc(addresses ...string) *EmailBuilder {
+	b.email.Cc = append(b.email.Cc, b.addresses(addresses)...)
+	return b
+}
+
+// Bcc adds blind recipients. They are kept in the Email but not serialized.
+func (b *EmailBuilder) Bcc(addresses ...string) *EmailBuilder {
+	b.email.Bcc = append(b.email.Bcc, b.addresses(addresses)...)
+	return b
+}
+
+// ReplyTo adds addresses to the Reply-To field
+func (b *EmailBuilder) ReplyTo(addresses ...string) *EmailBuilder {
+	b.email.ReplyTo = append(b.email.ReplyTo, b.addresses(addresses)...)
+	return b
+}
+
+// Subject sets the subject
+func (b *EmailBuilder) Subject(subject string) *EmailBuilder {
+	b.email.Subject = subject
+	return b
+}
+
+// Date sets the date, which defaults to the time Build is called
+func (b *EmailBuilder) Date(date time.Time) *EmailBuilder {
+	b.email.Date = date
+	return b
+}
+
+// MessageID sets the message id, given without angle brackets
+func (b *EmailBuilder) MessageID(id string) *EmailBuilder {
+	b.email.MessageID = id
+	return b
+}
+
+// Header adds a value to an extra header field
+func (b *EmailBuilder) Header(name, value string) *EmailBuilder {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	b.email.Header[name] = append(b.email.Header[name], value)
+	return b
+}
+
+// Text sets the text body
+func (b *EmailBuilder) Text(text string) *EmailBuilder {
+	b.email.TextBody = text
+	return b
+}
+
+// HTML sets the html body
+func (b *EmailBuilder) HTML(html string) *EmailBuilder {
+	b.email.HTMLBody = html
+	return b
+}
+
+// TextTemplate sets the text body to the result of executing t with data
+func (b *EmailBuilder) TextTemplate(t *texttemplate.Template, data interface{}) *EmailBuilder {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		b.fail(err)
+	}
+
+	b.email.TextBody = buf.String()
+	return b
+}
+
+// HTMLTemplate sets the html body to the result of executing t with data
+func (b *EmailBuilder) HTMLTemplate(t *htmltemplate.Template, data interface{}) *EmailBuilder {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		b.fail(err)
+	}
+
+	b.email.HTMLBody = buf.String()
+	return b
+}
+
+// Attach adds an attachment. Without a content type it is guessed from the
+// extension of the filename.
+func (b *EmailBuilder) Attach(filename, contentType string, data []byte) *EmailBuilder {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	ct, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		b.fail(err)
+		return b
+	}
+
+	b.email.Attachments = append(b.email.Attachments, Attachment{
+		Filename:    filename,
+		ContentType: ct,
+		Params:      params,
+		Data:        bytes.NewReader(data),
+	})
+	return b
+}
+
+// AttachInline adds a file referenced from the html body as "cid:" + cid.
+// Without a content type it is sniffed from data.
+func (b *EmailBuilder) AttachInline(cid string, data []byte, contentType string) *EmailBuilder {
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	ct, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		b.fail(err)
+		return b
+	}
+
+	b.email.EmbeddedFiles = append(b.email.EmbeddedFiles, EmbeddedFile{
+		CID:         cid,
+		ContentType: ct,
+		Params:      params,
+		Data:        bytes.NewReader(data),
+	})
+	return b
+}
+
+// InlineImages provides the content of images referenced by the src of img
+// tags in the html body. On Build the tags are changed to "cid:" references
+// to embedded files with the content.
+func (b *EmailBuilder) InlineImages(images map[string][]byte) *EmailBuilder {
+	if b.images == nil {
+		b.images = map[string][]byte{}
+	}
+	for src, data := range images {
+		b.images[src] = data
+	}
+	return b
+}
+
+// InlineFiles embeds the images of img tags with a "file://" src on Build,
+// reading them from disk, if they are files below the directory root. Other
+// files, and files that can't be read, keep their src. Without InlineFiles
+// no files are read, so html taken from a received message can't attach
+// local files.
+func (b *EmailBuilder) InlineFiles(root string) *EmailBuilder {
+	b.fileRoot = root
+	return b
+}
+
+// Build returns the composed email or the first error of the steps
+func (b *EmailBuilder) Build() (Email, error) {
+	if b.err == nil {
+		b.embedImages()
+	}
+	if b.err != nil {
+		return Email{}, b.err
+	}
+
+	e := b.email
+	if e.Date.IsZero() {
+		e.Date = time.Now().Truncate(time.Second)
+	}
+
+	return e, nil
+}
+
+func (b *EmailBuilder) addresses(list []string) []*mail.Address {
+	var result []*mail.Address
+	for _, s := range list {
+		a, err := mail.ParseAddress(s)
+		if err != nil {
+			b.fail(err)
+			continue
+		}
+		result = append(result, a)
+	}
+
+	return result
+}
+
+func (b *EmailBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+var imgSrc = regexp.MustCompile(`(?is)(<img\b[^>]*?\bsrc\s*=\s*)(["'])([^"']*)(["'])`)
+
+// embedImages replaces the src of img tags referencing provided images or
+// the local files of InlineFiles with cid references to embedded files
+func (b *EmailBuilder) embedImages() {
+	cids := map[string]string{}
+
+	b.email.HTMLBody = imgSrc.ReplaceAllStringFunc(b.email.HTMLBody, func(tag string) string {
+		m := imgSrc.FindStringSubmatch(tag)
+		src := html.UnescapeString(m[3])
+
+		cid, ok := cids[src]
+		if !ok {
+			data, name, found := b.imageData(src)
+			if !found {
+				return tag
+			}
+
+			cid = fmt.Sprintf("%s.%d@parsemail", cidLocalPart(name), len(cids)+1)
+			cids[src] = cid
+			b.AttachInline(cid, data, mime.TypeByExtension(path.Ext(name)))
+			b.email.EmbeddedFiles[len(b.email.EmbeddedFiles)-1].Filename = name
+		}
+
+		return m[1] + m[2] + "cid:" + cid + m[4]
+	})
+}
+
+// imageData returns the content and file name of an image src
+func (b *EmailBuilder) imageData(src string) (data []byte, name string, found bool) {
+	if data, ok := b.images[src]; ok {
+		return data, path.Base(src), true
+	}
+
+	if b.fileRoot == "" || !strings.HasPrefix(strings.ToLower(src), "file://") {
+		return nil, "", false
+	}
+
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, "", false
+	}
+
+	// symbolic links are resolved so they can't point out of the root
+	root, err := filepath.EvalSymlinks(b.fileRoot)
+	if err != nil {
+		return nil, "", false
+	}
+	file, err := filepath.EvalSymlinks(filepath.FromSlash(u.Path))
+	if err != nil {
+		return nil, "", false
+	}
+	rel, err := filepath.Rel(root, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, "", false
+	}
+
+	data, err = ioutil.ReadFile(file)
+	if err != nil {
+		return nil, "", false
+	}
+
+	return data, path.Base(u.Path), true
+}
+
+// cidLocalPart turns a file name into the local part of a Content-ID,
+// replacing the characters a dot-atom doesn't allow
+func cidLocalPart(name string) string {
+	local := strings.Map(func(r rune) rune {
+		if r < 0x80 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(".-_+", r)) {
+			return r
+		}
+		return '_'
+	}, name)
+
+	for strings.Contains(local, "..") {
+		local = strings.Replace(local, "..", ".", -1)
+	}
+	local = strings.Trim(local, ".")
+	if local == "" {
+		return "image"
+	}
+
+	return local
+}
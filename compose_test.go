@@ -0,0 +1,176 @@
+package parsemail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	texttemplate "text/template"
+	"time"
+)
+
+func TestEmailBuilder(t *testing.T) {
+	text := texttemplate.Must(texttemplate.New("text").Parse("Hello {{.Name}},\nyour report is attached.\n"))
+	html := htmltemplate.Must(htmltemplate.New("html").Parse("<p>Hello {{.Name}},</p>"))
+	data := struct{ Name string }{"Mary <3"}
+	date := time.Date(2020, 11, 18, 23, 41, 0, 0, time.UTC)
+
+	e, err := NewEmail().
+		From("John Doe <jdoe@example.com>").
+		To("Mary Smith <mary@example.com>", "bob@example.com").
+		Cc("Jürgen <juergen@example.com>").
+		Bcc("audit@example.com").
+		Subject("Monatsbericht für Mary").
+		Date(date).
+		MessageID("1234@example.com").
+		Header("X-Mailer", "parsemail").
+		TextTemplate(text, data).
+		HTMLTemplate(html, data).
+		Attach("report.csv", "", []byte("name;city\nMary;Köln\n")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := Serialize(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(raw, []byte("audit@example.com")) {
+		t.Errorf("Bcc recipient must not be serialized")
+	}
+
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Subject != "Monatsbericht für Mary" {
+		t.Errorf("Wrong subject. Expected: %s, Got: %s", "Monatsbericht für Mary", parsed.Subject)
+	}
+
+	if !assertAddressListEq(dereferenceAddressList(parsed.To), dereferenceAddressList(e.To)) {
+		t.Errorf("Wrong to. Expected: %v, Got: %v", e.To, parsed.To)
+	}
+
+	if parsed.Cc[0].Name != "Jürgen" {
+		t.Errorf("Wrong cc. Expected: %s, Got: %s", "Jürgen", parsed.Cc[0].Name)
+	}
+
+	if !parsed.Date.Equal(date) || parsed.MessageID != "1234@example.com" {
+		t.Errorf("Wrong date or message id: %v %s", parsed.Date, parsed.MessageID)
+	}
+
+	if parsed.Header.Get("X-Mailer") != "parsemail" {
+		t.Errorf("Wrong extra header: %s", parsed.Header.Get("X-Mailer"))
+	}
+
+	if parsed.TextBody != "Hello Mary <3,\nyour report is attached.\n" {
+		t.Errorf("Wrong text body: %q", parsed.TextBody)
+	}
+
+	if parsed.HTMLBody != "<p>Hello Mary &lt;3,</p>" {
+		t.Errorf("Wrong html body: %q", parsed.HTMLBody)
+	}
+
+	if len(parsed.Attachments) != 1 {
+		t.Fatalf("Wrong number of attachments. Expected: 1, Got: %d", len(parsed.Attachments))
+	}
+
+	at := parsed.Attachments[0]
+	b, _ := ioutil.ReadAll(at.Data)
+	if at.Filename != "report.csv" || at.ContentType != "text/csv" || string(b) != "name;city\nMary;Köln\n" {
+		t.Errorf("Wrong attachment: %s %s %q", at.Filename, at.ContentType, b)
+	}
+}
+
+func TestEmailBuilderErrors(t *testing.T) {
+	_, err := NewEmail().From("not an address").To("mary@example.com").Build()
+	if err == nil {
+		t.Errorf("Expected an error for an invalid address")
+	}
+
+	text := texttemplate.Must(texttemplate.New("text").Parse("{{.Missing.Field}}"))
+	_, err = NewEmail().TextTemplate(text, struct{ Missing *struct{ Field string } }{}).Build()
+	if err == nil {
+		t.Errorf("Expected an error for a failing template")
+	}
+}
+
+func TestEmailBuilderInlineImages(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	dir, err := ioutil.TempDir("", "parsemail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "photo.png")
+	if err := ioutil.WriteFile(file, png, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewEmail().
+		From("jdoe@example.com").
+		HTML(`<img src="file://`+filepath.ToSlash(file)+`"><img src='logo.png'><img src="logo.png"><img src="https://example.com/x.png"><img src="cid:sig">`).
+		InlineImages(map[string][]byte{"logo.png": png}).
+		InlineFiles(dir).
+		AttachInline("sig", png, "").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := Serialize(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.EmbeddedFiles) != 3 {
+		t.Fatalf("Wrong number of embedded files. Expected: 3, Got: %d", len(parsed.EmbeddedFiles))
+	}
+
+	expected := `<img src="cid:photo.png.1@parsemail"><img src='cid:logo.png.2@parsemail'><img src="cid:logo.png.2@parsemail"><img src="https://example.com/x.png"><img src="cid:sig">`
+	if parsed.HTMLBody != expected {
+		t.Errorf("Wrong html body. Expected: %s, Got: %s", expected, parsed.HTMLBody)
+	}
+
+	for _, ef := range parsed.EmbeddedFiles {
+		b, _ := ioutil.ReadAll(ef.Data)
+		if ef.ContentType != "image/png" || !bytes.Equal(b, png) {
+			t.Errorf("Wrong embedded file %s: %s %q", ef.CID, ef.ContentType, b)
+		}
+	}
+
+	// files are only read below the root of InlineFiles, other sources and
+	// missing files are kept
+	var testData = map[int]struct {
+		root     string
+		html     string
+		expected string
+	}{
+		1: {html: `<img src="file://` + filepath.ToSlash(file) + `">`, expected: `<img src="file://` + filepath.ToSlash(file) + `">`},
+		2: {root: filepath.Join(dir, "sub"), html: `<img src="file://` + filepath.ToSlash(file) + `">`, expected: `<img src="file://` + filepath.ToSlash(file) + `">`},
+		3: {root: dir, html: `<img src="file:///does/not/exist.png">`, expected: `<img src="file:///does/not/exist.png">`},
+		4: {html: `<img src="my logo ü.png">`, expected: `<img src="cid:my_logo__.png.1@parsemail">`},
+	}
+
+	for index, td := range testData {
+		e, err := NewEmail().HTML(td.html).InlineImages(map[string][]byte{"my logo ü.png": png}).InlineFiles(td.root).Build()
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+		if e.HTMLBody != td.expected {
+			t.Errorf("[Test Case %v] Wrong html body. Expected: %s, Got: %s", index, td.expected, e.HTMLBody)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package parsemail
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConvertFormat is the output format of a Converter
+type ConvertFormat int
+
+const (
+	// ConvertJSON writes one JSON object per line holding the schema
+	// version, the path and the TemplateData of each message
+	ConvertJSON ConvertFormat = iota
+	// ConvertMbox writes the original messages as an mboxrd mailbox
+	ConvertMbox
+)
+
+// ConvertProgress reports a converted file to the Progress callback. Err is
+// set when the file failed.
+type ConvertProgress struct {
+	Path  string
+	Done  int
+	Total int
+	Err   error
+}
+
+// ConvertError is the failure to convert a single file
+type ConvertError struct {
+	Path string
+	Err  error
+}
+
+func (e ConvertError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Converter converts collections of .eml files into a single JSON or mbox
+// stream. Outlook .msg files are converted by Msg, they are reported as
+// failed without it. Files larger than Options.MaxMessageBytes fail too,
+// and are not read further.
+type Converter struct {
+	Format   ConvertFormat
+	Options  Options
+	Progress func(ConvertProgress)
+	// Msg converts the data of a .msg file into a MIME message, like
+	// pst.ConvertMsg
+	Msg func(data []byte) ([]byte, error)
+}
+
+// convertRecord is a line of the JSON output
+type convertRecord struct {
+	SchemaVersion int
+	Path          string
+	Message       TemplateData
+}
+
+// ConvertDir converts all .eml and .msg files below root, in lexical order,
+// to w. Failing files are skipped and returned; the error is only set when
+// walking root or writing to w fails.
+func (c *Converter) ConvertDir(root string, w io.Writer) ([]ConvertError, error) {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && isConvertible(path) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.convert(paths, func(path string) ([]byte, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return c.readMessage(f)
+	}, w)
+}
+
+// ConvertZip converts all .eml and .msg files of a zip archive to w, see
+// ConvertDir. Entries are read up to Options.MaxMessageBytes, whatever size
+// the archive claims for them.
+func (c *Converter) ConvertZip(r io.ReaderAt, size int64, w io.Writer) ([]ConvertError, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]*zip.File{}
+	var paths []string
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() && isConvertible(f.Name) {
+			files[f.Name] = f
+			paths = append(paths, f.Name)
+		}
+	}
+	sort.Strings(paths)
+
+	return c.convert(paths, func(path string) ([]byte, error) {
+		rc, err := files[path].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		return c.readMessage(rc)
+	}, w)
+}
+
+func isConvertible(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".eml", ".msg":
+		return true
+	}
+
+	return false
+}
+
+func (c *Converter) convert(paths []string, read func(string) ([]byte, error), w io.Writer) (failed []ConvertError, err error) {
+	bw := bufio.NewWriter(w)
+
+	for i, path := range paths {
+		ferr := c.convertFile(path, read, bw)
+		if werr, ok := ferr.(writeError); ok {
+			return failed, werr.err
+		}
+
+		if ferr != nil {
+			failed = append(failed, ConvertError{Path: path, Err: ferr})
+		}
+
+		if c.Progress != nil {
+			c.Progress(ConvertProgress{Path: path, Done: i + 1, Total: len(paths), Err: ferr})
+		}
+	}
+
+	return failed, bw.Flush()
+}
+
+// writeError marks failures of the output, which abort the conversion
+type writeError struct {
+	err error
+}
+
+func (e writeError) Error() string {
+	return e.err.Error()
+}
+
+var errMsgUnsupported = fmt.Errorf("outlook .msg files are not supported")
+
+var errConvertTooLarge = fmt.Errorf("message exceeds MaxMessageBytes")
+
+// readMessage reads a file to convert, failing once it exceeds
+// MaxMessageBytes
+func (c *Converter) readMessage(r io.Reader) ([]byte, error) {
+	max := c.Options.MaxMessageBytes
+	if max <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err == nil && int64(len(data)) > max {
+		err = errConvertTooLarge
+	}
+
+	return data, err
+}
+
+func (c *Converter) convertFile(path string, read func(string) ([]byte, error), w io.Writer) error {
+	data, err := read(path)
+	if err != nil {
+		return err
+	}
+
+	// .msg files are compound files, not MIME
+	if bytes.HasPrefix(data, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")) {
+		if c.Msg == nil {
+			return errMsgUnsupported
+		}
+		if data, err = c.Msg(data); err != nil {
+			return err
+		}
+	}
+
+	e, err := ParseWithOptions(bytes.NewReader(data), c.Options)
+	if err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case ConvertMbox:
+		err = writeMboxMessage(w, &e, data)
+	default:
+		var b []byte
+		b, err = json.Marshal(convertRecord{SchemaVersion: e.SchemaVersion, Path: filepath.ToSlash(path), Message: e.TemplateData()})
+		if err == nil {
+			b = append(b, '\n')
+			_, err = w.Write(b)
+		}
+	}
+
+	if err != nil {
+		return writeError{err}
+	}
+
+	return nil
+}
+
+var mboxFromLine = regexp.MustCompile(`(?m)^(>*From )`)
+
+// writeMboxMessage writes raw as an mboxrd message, quoting its From_ lines
+func writeMboxMessage(w io.Writer, e *Email, raw []byte) error {
+	sender := "MAILER-DAEMON"
+	if len(e.From) > 0 && e.From[0].Address != "" {
+		sender = e.From[0].Address
+	}
+
+	date := e.Date
+	if date.IsZero() {
+		date = time.Unix(0, 0)
+	}
+
+	raw = bytes.Replace(raw, []byte("\r\n"), []byte("\n"), -1)
+	raw = mboxFromLine.ReplaceAll(raw, []byte(">$1"))
+	if !bytes.HasSuffix(raw, []byte("\n")) {
+		raw = append(raw, '\n')
+	}
+
+	if _, err := fmt.Fprintf(w, "From %s %s\n", sender, date.UTC().Format(time.ANSIC)); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
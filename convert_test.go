@@ -0,0 +1,134 @@
+package parsemail
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var convertFiles = map[string]string{
+	"a/hello.eml":    headerScanMessage,
+	"a/b/report.eml": latin1Attachment,
+	"a/outlook.msg":  "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1rest",
+	"a/broken.eml":   "From: John Doe <jdoe@machine.example>\nContent-Type: multipart/mixed\n\nbody\n",
+	"a/notes.txt":    "not a message",
+}
+
+func TestConvertDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "parsemail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for name, content := range convertFiles {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var progress []string
+	c := Converter{Progress: func(p ConvertProgress) {
+		progress = append(progress, filepath.Base(p.Path))
+		if p.Total != 4 {
+			t.Errorf("Wrong total. Expected: 4, Got: %v", p.Total)
+		}
+	}}
+
+	var out bytes.Buffer
+	failed, err := c.ConvertDir(root, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"report.eml", "broken.eml", "hello.eml", "outlook.msg"}; !assertSliceEq(progress, expected) {
+		t.Errorf("Wrong progress. Expected: %s, Got: %s", expected, progress)
+	}
+
+	if len(failed) != 2 || filepath.Base(failed[0].Path) != "broken.eml" || failed[1].Err != errMsgUnsupported {
+		t.Errorf("Wrong failed files: %v", failed)
+	}
+
+	var subjects []string
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var record convertRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.SchemaVersion != SchemaVersion {
+			t.Errorf("Wrong schema version. Expected: %d, Got: %d", SchemaVersion, record.SchemaVersion)
+		}
+		subjects = append(subjects, record.Message.Subject)
+	}
+
+	if expected := []string{"Report", "Saying Hello"}; !assertSliceEq(subjects, expected) {
+		t.Errorf("Wrong converted messages. Expected: %s, Got: %s", expected, subjects)
+	}
+
+	// .msg files are converted by Msg
+	c.Msg = func(data []byte) ([]byte, error) {
+		return []byte("Subject: Outlook\r\n\r\nHi\r\n"), nil
+	}
+	out.Reset()
+	failed, err = c.ConvertDir(root, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || !strings.Contains(out.String(), `"Subject":"Outlook"`) {
+		t.Errorf("Wrong converted .msg file. Failed: %v, Got: %s", failed, out.String())
+	}
+}
+
+func TestConvertZipMbox(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, name := range []string{"a/hello.eml", "a/notes.txt"} {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(convertFiles[name] + "From here on\n"))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Converter{Format: ConvertMbox}
+
+	var out bytes.Buffer
+	failed, err := c.ConvertZip(bytes.NewReader(archive.Bytes()), int64(archive.Len()), &out)
+	if err != nil || len(failed) != 0 {
+		t.Fatal(err, failed)
+	}
+
+	mbox := out.String()
+	if !strings.HasPrefix(mbox, "From jdoe@machine.example Fri Nov 21 15:55:06 1997\nReturn-Path:") {
+		t.Errorf("Wrong mbox From_ line: %q", mbox[:60])
+	}
+
+	if !strings.HasSuffix(mbox, "\n>From here on\n\n") {
+		t.Errorf("From_ line of the body not quoted: %q", mbox[len(mbox)-30:])
+	}
+
+	// entries are read up to MaxMessageBytes
+	c.Options.MaxMessageBytes = 64
+	out.Reset()
+	failed, err = c.ConvertZip(bytes.NewReader(archive.Bytes()), int64(archive.Len()), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].Err != errConvertTooLarge || out.Len() != 0 {
+		t.Errorf("Wrong failed files. Expected: %v, Got: %v", errConvertTooLarge, failed)
+	}
+}
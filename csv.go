@@ -0,0 +1,127 @@
+package parsemail
+
+import (
+	"encoding/csv"
+	"path"
+	"strings"
+)
+
+var csvContentTypes = []string{
+	"text/csv",
+	"text/comma-separated-values",
+	"text/tab-separated-values",
+	"application/csv",
+}
+
+// csvDelimiters are the candidates tried when sniffing the delimiter
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// IsCSV reports whether the attachment holds comma, semicolon or tab
+// separated values, judged by its content type, its filename extension or,
+// for generic text and binary types, by sniffing its content.
+func (a *Attachment) IsCSV() bool {
+	contentType := strings.ToLower(a.ContentType)
+	for _, t := range csvContentTypes {
+		if contentType == t {
+			return true
+		}
+	}
+
+	switch strings.ToLower(path.Ext(a.Filename)) {
+	case ".csv", ".tsv", ".tab":
+		return true
+	}
+
+	if contentType != "text/plain" && contentType != "application/octet-stream" {
+		return false
+	}
+
+	text, err := a.text()
+	if err != nil {
+		return false
+	}
+
+	_, ok := sniffCSVDelimiter(text)
+
+	return ok
+}
+
+// CSVReader returns an encoding/csv reader over the attachment content
+// converted to UTF-8, configured with the sniffed delimiter. Quotes are
+// parsed leniently and records may have varying numbers of fields.
+func (a *Attachment) CSVReader() (*csv.Reader, error) {
+	text, err := a.text()
+	if err != nil {
+		return nil, err
+	}
+
+	text = strings.TrimPrefix(text, "\uFEFF")
+
+	r := csv.NewReader(strings.NewReader(text))
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	if d, ok := sniffCSVDelimiter(text); ok {
+		r.Comma = d
+	} else if strings.ToLower(path.Ext(a.Filename)) == ".tsv" || strings.ToLower(a.ContentType) == "text/tab-separated-values" {
+		r.Comma = '\t'
+	}
+
+	return r, nil
+}
+
+// sniffCSVDelimiter returns the delimiter occurring the same, non zero number
+// of times on each of the first lines, preferring the most frequent one.
+func sniffCSVDelimiter(text string) (rune, bool) {
+	var lines []string
+	for _, line := range strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		lines = append(lines, line)
+		if len(lines) == 5 {
+			break
+		}
+	}
+
+	if len(lines) < 2 {
+		return 0, false
+	}
+
+	best, bestCount := rune(0), 0
+	for _, d := range csvDelimiters {
+		count := countUnquoted(lines[0], d)
+		if count == 0 {
+			continue
+		}
+
+		consistent := true
+		for _, line := range lines[1:] {
+			if countUnquoted(line, d) != count {
+				consistent = false
+				break
+			}
+		}
+
+		if consistent && count > bestCount {
+			best, bestCount = d, count
+		}
+	}
+
+	return best, bestCount > 0
+}
+
+// countUnquoted counts the occurrences of r outside of double quotes
+func countUnquoted(line string, r rune) (count int) {
+	quoted := false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == r && !quoted:
+			count++
+		}
+	}
+
+	return
+}
@@ -0,0 +1,69 @@
+package parsemail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSVReader(t *testing.T) {
+	e, err := Parse(strings.NewReader(latin1Attachment))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Attachments[0].IsCSV() {
+		t.Errorf("Expected text/csv attachment to be detected as CSV")
+	}
+
+	if e.Attachments[1].IsCSV() {
+		t.Errorf("Expected binary attachment not to be detected as CSV")
+	}
+
+	r, err := e.Attachments[0].CSVReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || len(records[1]) != 2 || records[1][0] != "Jürgen" || records[1][1] != "Köln" {
+		t.Errorf("Wrong records: %q", records)
+	}
+}
+
+func TestSniffCSV(t *testing.T) {
+	var testData = map[int]struct {
+		contentType string
+		data        string
+		isCSV       bool
+		comma       rune
+	}{
+		1: {"application/octet-stream", "a\tb\tc\n1\t2\t3\n", true, '\t'},
+		2: {"text/plain", "a,\"b,c\"\n1,2\n", true, ','},
+		3: {"text/plain", "a|b\n1|2\n", true, '|'},
+		4: {"text/plain", "Hello, world.\nBye.\n", false, ','},
+		5: {"text/plain", "a,b", false, ','},
+	}
+
+	for index, td := range testData {
+		a := Attachment{ContentType: td.contentType, Data: bytes.NewReader([]byte(td.data))}
+
+		if a.IsCSV() != td.isCSV {
+			t.Errorf("[Test Case %v] Wrong CSV detection. Expected: %v, Got: %v", index, td.isCSV, !td.isCSV)
+		}
+
+		r, err := a.CSVReader()
+		if err != nil {
+			t.Errorf("[Test Case %v] Unexpected error: %v", index, err)
+			continue
+		}
+
+		if r.Comma != td.comma {
+			t.Errorf("[Test Case %v] Wrong delimiter. Expected: %q, Got: %q", index, td.comma, r.Comma)
+		}
+	}
+}
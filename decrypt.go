@@ -0,0 +1,256 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const FindingDecryptionFailed FindingCode = "decryption-failed"
+
+var (
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAOAEP       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+	oidAES128CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// decryptMessage replaces the body of an S/MIME encrypted message by the
+// decrypted MIME entity, keeping the other header fields, and reports
+// whether it did. Other messages are returned unchanged. A message that
+// can't be decrypted is returned unchanged with a warning.
+func (p *parser) decryptMessage(raw []byte) ([]byte, bool, *Finding) {
+	header, body := splitHeader(raw)
+	mh, err := parseRawHeader(header)
+	if err != nil {
+		return raw, false, nil
+	}
+
+	contentType, params, err := parseContentType(mh.Get("Content-Type"))
+	if err != nil || !isEnvelopedData(contentType, params) {
+		return raw, false, nil
+	}
+
+	fail := func(err error) ([]byte, bool, *Finding) {
+		return raw, false, &Finding{Code: FindingDecryptionFailed, Message: err.Error()}
+	}
+
+	der, err := p.decodeRawBody(mh, body)
+	if err != nil {
+		return fail(err)
+	}
+
+	entity, err := decryptEnvelopedData(der, p.opts.SMIMECertificate, p.opts.SMIMEKey)
+	if err != nil {
+		return fail(err)
+	}
+
+	var buf bytes.Buffer
+	for _, f := range splitHeaderFields(header) {
+		if !strings.HasPrefix(f.Name, "Content-") {
+			buf.Write(f.Raw)
+		}
+	}
+	buf.Write(entity)
+
+	return buf.Bytes(), true, nil
+}
+
+// isEnvelopedData reports whether a content type is an S/MIME encrypted
+// body. Old clients omit the smime-type parameter.
+func isEnvelopedData(contentType string, params map[string]string) bool {
+	contentType = strings.ToLower(contentType)
+	if contentType != "application/pkcs7-mime" && contentType != "application/x-pkcs7-mime" {
+		return false
+	}
+
+	smimeType := strings.ToLower(params["smime-type"])
+
+	return smimeType == "enveloped-data" || smimeType == "" && !strings.HasSuffix(strings.ToLower(params["name"]), ".p7s")
+}
+
+// decryptEnvelopedData decrypts a DER encoded PKCS #7 EnvelopedData content
+// info with a key transported to cert, or to any recipient if cert is nil
+func decryptEnvelopedData(der []byte, cert *x509.Certificate, key crypto.Decrypter) ([]byte, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("smime: %v", err)
+	}
+	if !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, fmt.Errorf("smime: content type %v is not enveloped data", ci.ContentType)
+	}
+
+	elems, err := asn1Elements(ci.Content.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// the version is followed by the optional originator info [0], the
+	// recipient infos and the encrypted content info
+	i := 1
+	if i < len(elems) && elems[i].Class == asn1.ClassContextSpecific && elems[i].Tag == 0 {
+		i++
+	}
+	if i+1 >= len(elems) {
+		return nil, errors.New("smime: truncated enveloped data")
+	}
+
+	recipients, err := asn1Children(elems[i].Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	contentKey, err := decryptContentKey(recipients, cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptContent(elems[i+1].Bytes, contentKey)
+}
+
+// decryptContentKey decrypts the content encryption key of the first
+// matching key transport recipient info
+func decryptContentKey(recipients []asn1.RawValue, cert *x509.Certificate, key crypto.Decrypter) ([]byte, error) {
+	err := errors.New("smime: message isn't encrypted for the certificate")
+
+	for _, r := range recipients {
+		// other recipient types are tagged
+		if r.Class != asn1.ClassUniversal || r.Tag != asn1.TagSequence {
+			continue
+		}
+
+		elems, cerr := asn1Children(r.Bytes)
+		if cerr != nil || len(elems) < 4 {
+			continue
+		}
+
+		if cert != nil && !identifiesCertificate(elems[1], cert) {
+			continue
+		}
+
+		var alg algorithmIdentifier
+		if _, cerr := asn1.Unmarshal(elems[2].FullBytes, &alg); cerr != nil {
+			continue
+		}
+
+		var encryptedKey []byte
+		if _, cerr := asn1.Unmarshal(elems[3].FullBytes, &encryptedKey); cerr != nil {
+			continue
+		}
+
+		var opts crypto.DecrypterOpts
+		if alg.Algorithm.Equal(oidRSAOAEP) {
+			hash, cerr := oaepHash(alg.Parameters)
+			if cerr != nil {
+				err = cerr
+				continue
+			}
+			opts = &rsa.OAEPOptions{Hash: hash}
+		}
+
+		contentKey, cerr := key.Decrypt(rand.Reader, encryptedKey, opts)
+		if cerr == nil {
+			return contentKey, nil
+		}
+		err = fmt.Errorf("smime: %v", cerr)
+	}
+
+	return nil, err
+}
+
+// oaepHash returns the hash of RSAES-OAEP parameters, SHA-1 by default
+func oaepHash(params asn1.RawValue) (crypto.Hash, error) {
+	var p struct {
+		Hash algorithmIdentifier `asn1:"optional,explicit,tag:0"`
+	}
+	if len(params.FullBytes) > 0 {
+		if _, err := asn1.Unmarshal(params.FullBytes, &p); err != nil {
+			return 0, fmt.Errorf("smime: %v", err)
+		}
+	}
+	if p.Hash.Algorithm == nil {
+		return crypto.SHA1, nil
+	}
+
+	hash, ok := digestHash(p.Hash.Algorithm)
+	if !ok {
+		return 0, fmt.Errorf("smime: unsupported OAEP hash %v", p.Hash.Algorithm)
+	}
+
+	return hash, nil
+}
+
+// decryptContent decrypts the content of an EncryptedContentInfo with the
+// CBC mode block cipher it names
+func decryptContent(b []byte, key []byte) ([]byte, error) {
+	elems, err := asn1Children(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) < 3 {
+		return nil, errors.New("smime: encrypted content is missing")
+	}
+
+	var alg algorithmIdentifier
+	if _, err := asn1.Unmarshal(elems[1].FullBytes, &alg); err != nil {
+		return nil, fmt.Errorf("smime: %v", err)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("smime: %v", err)
+	}
+
+	var block cipher.Block
+	switch {
+	case alg.Algorithm.Equal(oidAES128CBC), alg.Algorithm.Equal(oidAES192CBC), alg.Algorithm.Equal(oidAES256CBC):
+		block, err = aes.NewCipher(key)
+	case alg.Algorithm.Equal(oidDESEDE3CBC):
+		block, err = des.NewTripleDESCipher(key)
+	default:
+		return nil, fmt.Errorf("smime: unsupported content encryption %v", alg.Algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smime: %v", err)
+	}
+
+	// the [0] encrypted content is split into octet strings by some clients
+	content := elems[2].Bytes
+	if elems[2].IsCompound {
+		chunks, err := asn1Children(elems[2].Bytes)
+		if err != nil {
+			return nil, err
+		}
+		content = nil
+		for _, c := range chunks {
+			content = append(content, c.Bytes...)
+		}
+	}
+
+	size := block.BlockSize()
+	if len(iv) != size || len(content) == 0 || len(content)%size != 0 {
+		return nil, errors.New("smime: malformed encrypted content")
+	}
+
+	plain := make([]byte, len(content))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, content)
+
+	// remove the PKCS #7 padding
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > size || !bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, errors.New("smime: wrong padding of decrypted content")
+	}
+
+	return plain[:len(plain)-pad], nil
+}
@@ -0,0 +1,157 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"strings"
+	"testing"
+)
+
+// envelopedMessage encrypts entity for cert with AES-256-CBC and returns it
+// as an S/MIME encrypted message
+func envelopedMessage(t *testing.T, entity string, cert *x509.Certificate) string {
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	rand.Read(key)
+	rand.Read(iv)
+
+	pad := aes.BlockSize - len(entity)%aes.BlockSize
+	plain := append([]byte(entity), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, _ := aes.NewCipher(key)
+	content := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(content, plain)
+
+	encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, cert.PublicKey.(*rsa.PublicKey), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ivDER, _ := asn1.Marshal(iv)
+	ed, err := asn1.Marshal(struct {
+		Version        int
+		RecipientInfos []struct {
+			Version      int
+			RID          issuerAndSerial
+			Algorithm    algorithmIdentifier
+			EncryptedKey []byte
+		} `asn1:"set"`
+		EncryptedContentInfo struct {
+			ContentType asn1.ObjectIdentifier
+			Algorithm   algorithmIdentifier
+			Content     []byte `asn1:"tag:0"`
+		}
+	}{
+		RecipientInfos: []struct {
+			Version      int
+			RID          issuerAndSerial
+			Algorithm    algorithmIdentifier
+			EncryptedKey []byte
+		}{{
+			RID:          issuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber},
+			Algorithm:    algorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.NullRawValue},
+			EncryptedKey: encryptedKey,
+		}},
+		EncryptedContentInfo: struct {
+			ContentType asn1.ObjectIdentifier
+			Algorithm   algorithmIdentifier
+			Content     []byte `asn1:"tag:0"`
+		}{
+			ContentType: oidData,
+			Algorithm:   algorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivDER}},
+			Content:     content,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := asn1.Marshal(contentInfo{
+		ContentType: oidEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: ed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	writeBase64Lines(&body, der)
+
+	return "From: jdoe@example.com\r\n" +
+		"To: mary@example.com\r\n" +
+		"Subject: Encrypted\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: application/pkcs7-mime; smime-type=enveloped-data; name=\"smime.p7m\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" + body.String()
+}
+
+func TestSMIMEDecryption(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := testCertificate(t, key)
+
+	entity := "Content-Type: multipart/mixed; boundary=\"inner\"\r\n\r\n" +
+		"--inner\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nThe secret plan.\r\n" +
+		"--inner\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=\"plan.pdf\"\r\n\r\n%PDF-1.4\r\n" +
+		"--inner--\r\n"
+	message := envelopedMessage(t, entity, cert)
+
+	var testData = map[int]struct {
+		opts      Options
+		decrypted bool
+		text      string
+		warning   FindingCode
+	}{
+		1: {opts: Options{SMIMECertificate: cert, SMIMEKey: key}, decrypted: true, text: "The secret plan."},
+		2: {opts: Options{SMIMEKey: key}, decrypted: true, text: "The secret plan."},
+		3: {opts: Options{SMIMEKey: otherKey}, warning: FindingDecryptionFailed},
+		4: {opts: Options{}},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(message), td.opts)
+		if err != nil {
+			t.Errorf("[Test Case %v] Error parsing email: %v", index, err)
+			continue
+		}
+
+		if e.Decrypted != td.decrypted {
+			t.Errorf("[Test Case %v] Wrong decrypted flag. Expected: %v, Got: %v", index, td.decrypted, e.Decrypted)
+		}
+
+		if e.TextBody != td.text {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %s, Got: %s", index, td.text, e.TextBody)
+		}
+
+		if e.Subject != "Encrypted" {
+			t.Errorf("[Test Case %v] Wrong subject. Expected: %s, Got: %s", index, "Encrypted", e.Subject)
+		}
+
+		if td.decrypted {
+			if len(e.Attachments) != 1 || e.Attachments[0].Filename != "plan.pdf" {
+				t.Errorf("[Test Case %v] Wrong attachments. Expected: plan.pdf, Got: %v", index, e.Attachments)
+			}
+		} else if e.Content == nil {
+			t.Errorf("[Test Case %v] Wrong content. Expected the encrypted content, Got: nil", index)
+		}
+
+		var warning FindingCode
+		if len(e.Warnings) > 0 {
+			warning = e.Warnings[0].Code
+		}
+		if warning != td.warning {
+			t.Errorf("[Test Case %v] Wrong warning. Expected: %s, Got: %s", index, td.warning, warning)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package parsemail
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// DeliveryClass is the kind of traffic an email belongs to, for routing it to
+// a queue
+type DeliveryClass string
+
+const (
+	// DeliveryPersonal is mail written by a person
+	DeliveryPersonal DeliveryClass = "personal"
+	// DeliveryTransactional is mail sent by an application in response to an
+	// action of the recipient, like receipts or password resets
+	DeliveryTransactional DeliveryClass = "transactional"
+	// DeliveryBulk is mail sent to many recipients, like newsletters
+	DeliveryBulk DeliveryClass = "bulk"
+	// DeliveryList is mail distributed by a mailing list
+	DeliveryList DeliveryClass = "list"
+	// DeliveryAutomated is mail generated without a person involved, like
+	// auto-replies and delivery reports
+	DeliveryAutomated DeliveryClass = "automated"
+)
+
+// noReplyLocalParts mark sender addresses of applications
+var noReplyLocalParts = []string{"noreply", "no-reply", "no_reply", "donotreply", "do-not-reply", "do_not_reply"}
+
+// DeliveryClass classifies the email by its Auto-Submitted, Precedence,
+// List-*, X-Priority, Importance and Priority headers and the ESP headers known to
+// CampaignInfo. The first matching class of automated, list, bulk and
+// transactional wins, other emails are personal.
+func (e *Email) DeliveryClass() DeliveryClass {
+	h := e.Header
+	precedence := strings.ToLower(strings.TrimSpace(h.Get("Precedence")))
+
+	if isAutoSubmitted(h.Get("Auto-Submitted")) || precedence == "auto_reply" ||
+		h.Get("X-Autoreply") != "" || h.Get("X-Autorespond") != "" {
+		return DeliveryAutomated
+	}
+	if contentType, _, err := parseContentType(e.ContentType); err == nil && strings.EqualFold(contentType, contentTypeMultipartReport) {
+		return DeliveryAutomated
+	}
+
+	if precedence == "list" || h.Get("List-Id") != "" || h.Get("List-Post") != "" || h.Get("Mailing-List") != "" {
+		return DeliveryList
+	}
+
+	campaign := e.CampaignInfo()
+	if precedence == "bulk" || precedence == "junk" || h.Get("List-Unsubscribe") != "" ||
+		campaign != nil && campaign.CampaignID != "" || isLowPriority(h) {
+		return DeliveryBulk
+	}
+
+	if campaign != nil || isNoReply(e.From) {
+		return DeliveryTransactional
+	}
+
+	return DeliveryPersonal
+}
+
+// isAutoSubmitted reports whether an Auto-Submitted value (RFC 3834) marks
+// an automatic message
+func isAutoSubmitted(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if i := strings.IndexByte(value, ';'); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	return value != "" && value != "no"
+}
+
+// isLowPriority reports whether an X-Priority of 5 ("5 (Lowest)"), a low
+// Importance or a non-urgent Priority (RFC 2156) is set
+func isLowPriority(h mail.Header) bool {
+	return strings.HasPrefix(strings.TrimSpace(h.Get("X-Priority")), "5") ||
+		strings.EqualFold(strings.TrimSpace(h.Get("Importance")), "low") ||
+		strings.EqualFold(strings.TrimSpace(h.Get("Priority")), "non-urgent")
+}
+
+// isNoReply reports whether a sender address is a no-reply address
+func isNoReply(from []*mail.Address) bool {
+	for _, a := range from {
+		local := strings.ToLower(a.Address)
+		if i := strings.LastIndexByte(local, '@'); i >= 0 {
+			local = local[:i]
+		}
+
+		for _, p := range noReplyLocalParts {
+			if strings.HasPrefix(local, p) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package parsemail
+
+import (
+	"net/mail"
+	"testing"
+)
+
+func TestDeliveryClass(t *testing.T) {
+	var testData = map[int]struct {
+		header      mail.Header
+		contentType string
+		from        string
+		class       DeliveryClass
+	}{
+		1: {
+			header: mail.Header{"Subject": {"Lunch?"}},
+			from:   "mary@example.com",
+			class:  DeliveryPersonal,
+		},
+		2: {
+			header: mail.Header{"Auto-Submitted": {"auto-replied"}, "List-Id": {"<dev.lists.example.com>"}},
+			class:  DeliveryAutomated,
+		},
+		3: {
+			header: mail.Header{"Auto-Submitted": {"no"}},
+			class:  DeliveryPersonal,
+		},
+		4: {
+			header:      mail.Header{},
+			contentType: `multipart/report; report-type=delivery-status; boundary="b"`,
+			class:       DeliveryAutomated,
+		},
+		5: {
+			header: mail.Header{"List-Id": {"Developers <dev.lists.example.com>"}, "List-Unsubscribe": {"<mailto:leave@example.com>"}},
+			class:  DeliveryList,
+		},
+		6: {
+			header: mail.Header{"Precedence": {"bulk"}},
+			class:  DeliveryBulk,
+		},
+		7: {
+			header: mail.Header{"X-Mailgun-Sid": {"WyI0ZTNhZiJd"}, "X-Mailgun-Campaign-Id": {"c123"}},
+			class:  DeliveryBulk,
+		},
+		8: {
+			header: mail.Header{"X-Priority": {"5 (Lowest)"}},
+			class:  DeliveryBulk,
+		},
+		9: {
+			header: mail.Header{"X-Ses-Message-Id": {"0100017044a4b3a1-bd0a"}, "X-Priority": {"1 (Highest)"}},
+			class:  DeliveryTransactional,
+		},
+		10: {
+			header: mail.Header{},
+			from:   "Shop <no-reply@shop.example.com>",
+			class:  DeliveryTransactional,
+		},
+	}
+
+	for index, td := range testData {
+		e := Email{Header: td.header, ContentType: td.contentType}
+		if td.from != "" {
+			from, err := mail.ParseAddress(td.from)
+			if err != nil {
+				t.Fatalf("[Test Case %v] %v", index, err)
+			}
+			e.From = []*mail.Address{from}
+		}
+
+		if class := e.DeliveryClass(); class != td.class {
+			t.Errorf("[Test Case %v] Wrong delivery class. Expected: %q, Got: %q", index, td.class, class)
+		}
+	}
+}
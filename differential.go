@@ -0,0 +1,119 @@
+package parsemail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+const FindingParseDivergence FindingCode = "parse-divergence"
+
+// baseline is the result of the minimal parse a differential parse compares
+// with
+type baseline struct {
+	textBody string
+	htmlBody string
+	files    int
+}
+
+// parseBaseline parses raw with net/mail and the mime packages only: text
+// and html parts not marked as attachments make up the bodies, without
+// charset decoding, and every other leaf part counts as a file
+func parseBaseline(raw []byte) (b baseline, err error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return
+	}
+
+	err = b.entity(textproto.MIMEHeader(msg.Header), msg.Body, 0)
+
+	return
+}
+
+func (b *baseline) entity(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	contentType, params, err := parseContentType(header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(contentType, "multipart/") {
+		if depth >= maxMessageDepth {
+			return fmt.Errorf("multipart nested deeper than %d levels", maxMessageDepth)
+		}
+
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := b.entity(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	// multipart.Part decodes quoted-printable itself and drops the header
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	disposition, _, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	switch {
+	case disposition != "attachment" && contentType == contentTypeTextPlain:
+		b.textBody += strings.TrimSuffix(string(data), "\n")
+	case disposition != "attachment" && contentType == contentTypeTextHtml:
+		b.htmlBody += strings.TrimSuffix(string(data), "\n")
+	default:
+		b.files++
+	}
+
+	return nil
+}
+
+// divergences compares a parse result with the baseline parse of raw. Line
+// breaks and trailing line breaks are normalized before the bodies are
+// compared, "expected" being the baseline.
+func divergences(e *Email, raw []byte) (findings []Finding) {
+	diverge := func(format string, args ...interface{}) {
+		findings = append(findings, Finding{Code: FindingParseDivergence, Message: fmt.Sprintf(format, args...)})
+	}
+
+	b, err := parseBaseline(raw)
+	if err != nil {
+		diverge("baseline parse failed: %v", err)
+		return
+	}
+
+	lf := func(s string) string { return strings.TrimRight(strings.Replace(s, "\r\n", "\n", -1), "\r\n") }
+	if d := diffText(lf(b.textBody), lf(e.TextBody)); d != "" {
+		diverge("text body: %s", d)
+	}
+	if d := diffText(lf(b.htmlBody), lf(e.HTMLBody)); d != "" {
+		diverge("html body: %s", d)
+	}
+
+	if files := len(e.Attachments) + len(e.EmbeddedFiles); files != b.files {
+		diverge("files: %d attachments and %d embedded files, the baseline found %d", len(e.Attachments), len(e.EmbeddedFiles), b.files)
+	}
+
+	return
+}
@@ -0,0 +1,59 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDifferential(t *testing.T) {
+	var testData = map[int]struct {
+		raw         string
+		divergences []string
+	}{
+		1: {
+			raw: "From: a@example.com\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: multipart/alternative; boundary=c\r\n\r\n" +
+				"--c\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nHello =\r\nworld\r\n" +
+				"--c\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<p>Hello world</p>\r\n" +
+				"--c--\r\n" +
+				"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=a.pdf\r\nContent-Transfer-Encoding: base64\r\n\r\nJVBERi0=\r\n" +
+				"--b--\r\n",
+		},
+		2: {
+			raw:         "From: a@example.com\r\nContent-Type: text/plain; charset=iso-8859-1\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nGr=FC=DFe\r\n",
+			divergences: []string{`text body: expected "Gr\xfc\xdfe", got "Grüße"`},
+		},
+		3: {
+			raw: "From: a@example.com\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nSee the invitation\r\n" +
+				"--b\r\nContent-Type: text/calendar; method=REQUEST\r\n\r\nBEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n" +
+				"--b--\r\n",
+			divergences: []string{"files: 0 attachments and 0 embedded files, the baseline found 1"},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(td.raw), Options{Differential: true})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		var got []string
+		for _, w := range e.Warnings {
+			if w.Code == FindingParseDivergence {
+				got = append(got, w.Message)
+			}
+		}
+		if !assertSliceEq(got, td.divergences) {
+			t.Errorf("[Test Case %v] Wrong divergences. Expected: %q, Got: %q", index, td.divergences, got)
+		}
+	}
+
+	e, err := Parse(strings.NewReader(testData[2].raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Warnings) != 0 {
+		t.Errorf("Unexpected warnings without Differential: %v", e.Warnings)
+	}
+}
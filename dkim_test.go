@@ -0,0 +1,131 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDKIMSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = map[int]struct {
+		signer DKIMSigner
+	}{
+		1: {signer: DKIMSigner{Domain: "example.com", Selector: "rsa", Key: rsaKey}},
+		2: {signer: DKIMSigner{Domain: "example.com", Selector: "ed", Key: edKey}},
+		3: {signer: DKIMSigner{Domain: "example.com", Selector: "rsa", Key: rsaKey, HeaderCanonicalization: CanonicalizationSimple, BodyCanonicalization: CanonicalizationSimple}},
+	}
+
+	e, err := NewEmail().
+		From("John Doe <jdoe@example.com>").
+		To("mary@example.com").
+		Subject("Signed").
+		Text("Hello  Mary, \nbye\n").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index, td := range testData {
+		signer := td.signer
+		raw, err := SerializeSigned(&e, &signer)
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if err := verifyDKIM(raw, signer.Key.Public()); err != "" {
+			t.Errorf("[Test Case %v] %s", index, err)
+		}
+
+		tampered := bytes.Replace(raw, []byte("bye"), []byte("BYE"), 1)
+		if err := verifyDKIM(tampered, signer.Key.Public()); err == "" {
+			t.Errorf("[Test Case %v] Expected the tampered body to fail", index)
+		}
+
+		parsed, err := Parse(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if parsed.TextBody != e.TextBody || parsed.Header.Get("DKIM-Signature") == "" {
+			t.Errorf("[Test Case %v] Wrong parsed message: %q", index, parsed.TextBody)
+		}
+	}
+
+	if _, err := (&DKIMSigner{Domain: "example.com"}).Sign([]byte("From: a@example.com\r\n\r\n")); err == nil {
+		t.Errorf("Expected an error without key")
+	}
+}
+
+// verifyDKIM checks the first DKIM-Signature of raw and describes a failure
+func verifyDKIM(raw []byte, key crypto.PublicKey) string {
+	header, body := splitHeader(raw)
+	fields := splitHeaderFields(header)
+	if len(fields) == 0 || fields[0].Name != "Dkim-Signature" {
+		return "no signature"
+	}
+
+	tags := map[string]string{}
+	for _, tag := range strings.Split(fields[0].Value(), ";") {
+		kv := strings.SplitN(strings.TrimSpace(tag), "=", 2)
+		if len(kv) == 2 {
+			tags[kv[0]] = kv[1]
+		}
+	}
+
+	hc, bc, err := ParseCanonicalization(tags["c"])
+	if err != nil {
+		return err.Error()
+	}
+
+	bh := sha256.Sum256(CanonicalizeBody(body, bc))
+	if base64.StdEncoding.EncodeToString(bh[:]) != tags["bh"] {
+		return "body hash mismatch"
+	}
+
+	h := sha256.New()
+	used := make([]bool, len(fields))
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i > 0; i-- {
+			if !used[i] && strings.EqualFold(fields[i].Name, name) {
+				used[i] = true
+				h.Write(CanonicalizeHeader(fields[i].Raw, hc))
+				break
+			}
+		}
+	}
+
+	unsigned := bytes.Replace(fields[0].Raw, []byte(tags["b"]), nil, 1)
+	h.Write(bytes.TrimSuffix(CanonicalizeHeader(unsigned, hc), []byte("\r\n")))
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return err.Error()
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, h.Sum(nil), sig); err != nil {
+			return err.Error()
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, h.Sum(nil), sig) {
+			return "invalid ed25519 signature"
+		}
+	}
+
+	return ""
+}
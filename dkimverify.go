@@ -0,0 +1,441 @@
+package parsemail
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DKIMStatus is the result of verifying a DKIM signature, named like the
+// dkim results of an Authentication-Results field (RFC 8601)
+type DKIMStatus string
+
+const (
+	DKIMPass      DKIMStatus = "pass"
+	DKIMFail      DKIMStatus = "fail"
+	DKIMTempError DKIMStatus = "temperror"
+	DKIMPermError DKIMStatus = "permerror"
+)
+
+// minDKIMKeyBits is the smallest RSA key accepted (RFC 8301)
+const minDKIMKeyBits = 1024
+
+// DKIMSignature is a parsed DKIM-Signature field (RFC 6376)
+type DKIMSignature struct {
+	// Algorithm is the a= tag, e.g. rsa-sha256 or ed25519-sha256
+	Algorithm              string
+	Domain                 string
+	Selector               string
+	HeaderCanonicalization Canonicalization
+	BodyCanonicalization   Canonicalization
+	// Headers are the signed header field names in lower case
+	Headers   []string
+	BodyHash  []byte
+	Signature []byte
+	// Identity is the i= tag, by default "@" followed by the domain
+	Identity string
+	// BodyLength is the number of signed body bytes, or -1 if the whole body
+	// is signed
+	BodyLength int64
+	Timestamp  time.Time
+	Expiration time.Time
+
+	field HeaderField
+}
+
+// ParseDKIMSignature parses a raw DKIM-Signature field, including its name
+func ParseDKIMSignature(field []byte) (*DKIMSignature, error) {
+	return parseDKIMSignature(field, false)
+}
+
+// parseDKIMSignature parses a DKIM-Signature or, if arc is set, an
+// ARC-Message-Signature field, which has no version and an instance number
+// as i= tag
+func parseDKIMSignature(field []byte, arc bool) (*DKIMSignature, error) {
+	i := bytes.IndexByte(field, ':')
+	if i < 0 {
+		return nil, errors.New("dkim: malformed signature field")
+	}
+
+	tags, err := parseDKIMTags(string(field[i+1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	required := []string{"v", "a", "b", "bh", "d", "h", "s"}
+	if arc {
+		required = required[1:]
+	}
+	for _, t := range required {
+		if _, ok := tags[t]; !ok {
+			return nil, fmt.Errorf("dkim: signature is missing the %s= tag", t)
+		}
+	}
+	if !arc && tags["v"] != "1" {
+		return nil, fmt.Errorf("dkim: unsupported signature version %s", tags["v"])
+	}
+
+	sig := &DKIMSignature{
+		Algorithm:  strings.ToLower(tags["a"]),
+		Domain:     strings.ToLower(tags["d"]),
+		Selector:   tags["s"],
+		BodyLength: -1,
+		field:      HeaderField{Name: string(bytes.TrimSpace(field[:i])), Raw: field},
+	}
+
+	if sig.HeaderCanonicalization, sig.BodyCanonicalization, err = ParseCanonicalization(tags["c"]); err != nil {
+		return nil, fmt.Errorf("dkim: %v", err)
+	}
+
+	from := false
+	for _, h := range strings.Split(tags["h"], ":") {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" {
+			sig.Headers = append(sig.Headers, h)
+		}
+		from = from || h == "from"
+	}
+	if !from {
+		return nil, errors.New("dkim: the From field is not signed")
+	}
+
+	if sig.BodyHash, err = base64.StdEncoding.DecodeString(stripWhitespace(tags["bh"])); err != nil {
+		return nil, fmt.Errorf("dkim: malformed body hash: %v", err)
+	}
+	if sig.Signature, err = base64.StdEncoding.DecodeString(stripWhitespace(tags["b"])); err != nil {
+		return nil, fmt.Errorf("dkim: malformed signature: %v", err)
+	}
+
+	sig.Identity = "@" + sig.Domain
+	if i, ok := tags["i"]; ok && !arc {
+		at := strings.LastIndex(i, "@")
+		domain := strings.ToLower(i[at+1:])
+		if at < 0 || domain != sig.Domain && !strings.HasSuffix(domain, "."+sig.Domain) {
+			return nil, fmt.Errorf("dkim: identity %s is outside of domain %s", i, sig.Domain)
+		}
+		sig.Identity = i
+	}
+
+	if l, ok := tags["l"]; ok {
+		if sig.BodyLength, err = strconv.ParseInt(l, 10, 64); err != nil || sig.BodyLength < 0 {
+			return nil, fmt.Errorf("dkim: malformed body length %s", l)
+		}
+	}
+
+	for t, v := range map[string]*time.Time{"t": &sig.Timestamp, "x": &sig.Expiration} {
+		if s, ok := tags[t]; ok {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("dkim: malformed %s= tag %s", t, s)
+			}
+			*v = time.Unix(n, 0)
+		}
+	}
+
+	return sig, nil
+}
+
+// parseDKIMTags parses a tag list (RFC 6376 section 3.2)
+func parseDKIMTags(s string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, spec := range strings.Split(UnfoldHeader(s), ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(spec, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || name == "" {
+			return nil, fmt.Errorf("dkim: malformed tag %q", strings.TrimSpace(spec))
+		}
+		if _, ok := tags[name]; ok {
+			return nil, fmt.Errorf("dkim: duplicate tag %s=", name)
+		}
+		tags[name] = strings.TrimSpace(kv[1])
+	}
+
+	return tags, nil
+}
+
+func stripWhitespace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// DKIMSignatures returns the DKIM signatures of the message in the order of
+// their fields. Malformed signatures are skipped, VerifyDKIM reports them.
+func (e *Email) DKIMSignatures() (signatures []DKIMSignature) {
+	for _, f := range e.RawHeaderFields() {
+		if f.Name != "Dkim-Signature" {
+			continue
+		}
+		if sig, err := ParseDKIMSignature(f.Raw); err == nil {
+			signatures = append(signatures, *sig)
+		}
+	}
+
+	return
+}
+
+// TXTResolver looks up DNS TXT records. *net.Resolver implements it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DKIMResult is the outcome of verifying one DKIM-Signature field
+type DKIMResult struct {
+	Status DKIMStatus
+	// Signature is nil for malformed fields
+	Signature *DKIMSignature
+	// Err explains every status but pass
+	Err error
+}
+
+// VerifyDKIM verifies the DKIM signatures of the original message with the
+// keys published in the DNS, looked up with resolver or the default
+// resolver if it is nil. A result is returned for every DKIM-Signature field
+// in order. RSA signatures with SHA-1 or keys shorter than 1024 bits are
+// rejected as permerror (RFC 8301). Without Options.KeepRaw the body
+// isn't there to verify, and every signature is a temperror.
+func (e *Email) VerifyDKIM(ctx context.Context, resolver TXTResolver) (results []DKIMResult) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	raw, rawErr := e.rawMessage()
+	header, body := splitHeader(raw)
+	if rawErr != nil {
+		header, _ = splitHeader(e.raw)
+	}
+	fields := splitHeaderFields(header)
+	now := time.Now()
+
+	for _, f := range fields {
+		if f.Name != "Dkim-Signature" {
+			continue
+		}
+
+		sig, err := ParseDKIMSignature(f.Raw)
+		if err != nil {
+			results = append(results, DKIMResult{Status: DKIMPermError, Err: err})
+			continue
+		}
+		if rawErr != nil {
+			results = append(results, DKIMResult{Status: DKIMTempError, Signature: sig, Err: rawErr})
+			continue
+		}
+
+		status, err := sig.verify(ctx, resolver, fields, body, now)
+		results = append(results, DKIMResult{Status: status, Signature: sig, Err: err})
+	}
+
+	return
+}
+
+// verify checks the signature against the header fields and body of a
+// message
+func (sig *DKIMSignature) verify(ctx context.Context, resolver TXTResolver, fields []HeaderField, body []byte, now time.Time) (DKIMStatus, error) {
+	if !sig.Expiration.IsZero() && sig.Expiration.Before(now) {
+		return DKIMPermError, fmt.Errorf("dkim: signature expired at %v", sig.Expiration)
+	}
+
+	key, status, err := sig.lookupKey(ctx, resolver)
+	if err != nil {
+		return status, err
+	}
+
+	canonical := CanonicalizeBody(body, sig.BodyCanonicalization)
+	if sig.BodyLength >= 0 {
+		if sig.BodyLength > int64(len(canonical)) {
+			return DKIMPermError, errors.New("dkim: body is shorter than the signed length")
+		}
+		canonical = canonical[:sig.BodyLength]
+	}
+
+	bh := sha256.Sum256(canonical)
+	if !bytes.Equal(bh[:], sig.BodyHash) {
+		return DKIMFail, errors.New("dkim: body hash mismatch")
+	}
+
+	h := sha256.New()
+	for _, f := range selectHeaderFields(fields, sig.Headers) {
+		h.Write(CanonicalizeHeader(f.Raw, sig.HeaderCanonicalization))
+	}
+	unsigned := CanonicalizeHeader(withoutSignatureValue(sig.field.Raw), sig.HeaderCanonicalization)
+	h.Write(bytes.TrimSuffix(unsigned, []byte("\r\n")))
+
+	if err := verifyDKIMDigest(key, h.Sum(nil), sig.Signature); err != nil {
+		return DKIMFail, err
+	}
+
+	return DKIMPass, nil
+}
+
+// verifyDKIMDigest checks a signature over the SHA-256 digest of the
+// canonicalized header fields
+func verifyDKIMDigest(key crypto.PublicKey, digest, signature []byte) (err error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, digest, signature) {
+			err = errors.New("ed25519: verification error")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("dkim: %v", err)
+	}
+
+	return nil
+}
+
+// lookupKey returns the public key of the signature published in the DNS
+// (RFC 6376 section 3.6)
+func (sig *DKIMSignature) lookupKey(ctx context.Context, resolver TXTResolver) (crypto.PublicKey, DKIMStatus, error) {
+	var keyType string
+	switch sig.Algorithm {
+	case "rsa-sha256":
+		keyType = "rsa"
+	case "ed25519-sha256":
+		keyType = "ed25519"
+	default:
+		return nil, DKIMPermError, fmt.Errorf("dkim: unsupported algorithm %s", sig.Algorithm)
+	}
+
+	name := sig.Selector + "._domainkey." + sig.Domain
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, DKIMPermError, fmt.Errorf("dkim: no key for %s", name)
+		}
+		return nil, DKIMTempError, fmt.Errorf("dkim: %v", err)
+	}
+
+	err = fmt.Errorf("dkim: no key for %s", name)
+	for _, record := range records {
+		tags, terr := parseDKIMTags(record)
+		if terr != nil {
+			err = terr
+			continue
+		}
+		if v, ok := tags["v"]; ok && v != "DKIM1" {
+			continue
+		}
+
+		k := strings.ToLower(tags["k"])
+		if k == "" {
+			k = "rsa"
+		}
+		if k != keyType {
+			err = fmt.Errorf("dkim: key type %s doesn't match algorithm %s", k, sig.Algorithm)
+			continue
+		}
+
+		if strings.Contains(":"+tags["t"]+":", ":s:") && !strings.HasSuffix(strings.ToLower(sig.Identity), "@"+sig.Domain) {
+			err = errors.New("dkim: identity must match the domain of a strict key")
+			continue
+		}
+
+		p := stripWhitespace(tags["p"])
+		if p == "" {
+			err = fmt.Errorf("dkim: key for %s is revoked", name)
+			continue
+		}
+
+		der, derr := base64.StdEncoding.DecodeString(p)
+		if derr != nil {
+			err = fmt.Errorf("dkim: malformed key: %v", derr)
+			continue
+		}
+
+		key, kerr := parseDKIMKey(keyType, der)
+		if kerr != nil {
+			err = kerr
+			continue
+		}
+
+		return key, DKIMPass, nil
+	}
+
+	return nil, DKIMPermError, err
+}
+
+// parseDKIMKey parses the p= tag of a key record. RSA keys are published as
+// SubjectPublicKeyInfo, sometimes as bare RSAPublicKey.
+func parseDKIMKey(keyType string, der []byte) (crypto.PublicKey, error) {
+	if keyType == "ed25519" {
+		if len(der) != ed25519.PublicKeySize {
+			return nil, errors.New("dkim: malformed ed25519 key")
+		}
+		return ed25519.PublicKey(der), nil
+	}
+
+	var key *rsa.PublicKey
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		key, _ = pub.(*rsa.PublicKey)
+	} else if key, err = x509.ParsePKCS1PublicKey(der); err != nil {
+		return nil, fmt.Errorf("dkim: malformed rsa key: %v", err)
+	}
+	if key == nil {
+		return nil, errors.New("dkim: key is not an rsa key")
+	}
+	if key.N.BitLen() < minDKIMKeyBits {
+		return nil, fmt.Errorf("dkim: rsa key of %d bits is too short", key.N.BitLen())
+	}
+
+	return key, nil
+}
+
+// withoutSignatureValue empties the value of the b= tag of a raw
+// DKIM-Signature field, keeping everything else as it was signed
+func withoutSignatureValue(field []byte) []byte {
+	i := bytes.IndexByte(field, ':')
+	if i < 0 {
+		return field
+	}
+
+	for start := i + 1; start <= len(field); {
+		end := bytes.IndexByte(field[start:], ';')
+		if end < 0 {
+			end = len(field)
+		} else {
+			end += start
+		}
+
+		spec := field[start:end]
+		if eq := bytes.IndexByte(spec, '='); eq >= 0 && string(bytes.TrimSpace(spec[:eq])) == "b" {
+			out := append([]byte(nil), field[:start+eq+1]...)
+			if end < len(field) {
+				return append(out, field[end:]...)
+			}
+
+			// keep the line break ending the field
+			if bytes.HasSuffix(field, []byte("\r\n")) {
+				return append(out, '\r', '\n')
+			}
+			if bytes.HasSuffix(field, []byte("\n")) {
+				return append(out, '\n')
+			}
+			return out
+		}
+
+		start = end + 1
+	}
+
+	return field
+}
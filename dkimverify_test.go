@@ -0,0 +1,198 @@
+package parsemail
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"net"
+	"testing"
+)
+
+type txtRecords map[string][]string
+
+func (r txtRecords) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if name == "down._domainkey.example.com" {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+
+	records, ok := r[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return records, nil
+}
+
+func TestParseDKIMSignature(t *testing.T) {
+	var testData = map[int]struct {
+		field      string
+		err        bool
+		domain     string
+		selector   string
+		headers    []string
+		identity   string
+		bodyLength int64
+	}{
+		1: {
+			field:      "DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/simple; d=Example.com;\r\n s=mail; h=From : To:Subject; bh=YWJj; b=ZG\r\n Vm; l=42\r\n",
+			domain:     "example.com",
+			selector:   "mail",
+			headers:    []string{"from", "to", "subject"},
+			identity:   "@example.com",
+			bodyLength: 42,
+		},
+		2: {
+			field:      "DKIM-Signature: v=1; a=ed25519-sha256; d=example.com; s=ed; i=jdoe@news.example.com; h=from; bh=YWJj; b=ZGVm\r\n",
+			domain:     "example.com",
+			selector:   "ed",
+			headers:    []string{"from"},
+			identity:   "jdoe@news.example.com",
+			bodyLength: -1,
+		},
+		3: {field: "DKIM-Signature: v=1; a=rsa-sha256; d=example.com; s=mail; h=to; bh=YWJj; b=ZGVm\r\n", err: true},
+		4: {field: "DKIM-Signature: v=1; a=rsa-sha256; d=example.com; s=mail; h=from; b=ZGVm\r\n", err: true},
+		5: {field: "DKIM-Signature: v=1; a=rsa-sha256; d=example.com; s=mail; i=@evil.example; h=from; bh=YWJj; b=ZGVm\r\n", err: true},
+		6: {field: "DKIM-Signature: v=1; a=rsa-sha256; d=example.com; d=example.org; s=mail; h=from; bh=YWJj; b=ZGVm\r\n", err: true},
+	}
+
+	for index, td := range testData {
+		sig, err := ParseDKIMSignature([]byte(td.field))
+		if td.err {
+			if err == nil {
+				t.Errorf("[Test Case %v] Expected an error", index)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		if sig.Domain != td.domain || sig.Selector != td.selector || sig.Identity != td.identity || sig.BodyLength != td.bodyLength {
+			t.Errorf("[Test Case %v] Wrong signature. Got: %+v", index, sig)
+		}
+		if !assertSliceEq(sig.Headers, td.headers) {
+			t.Errorf("[Test Case %v] Wrong headers. Expected: %v, Got: %v", index, td.headers, sig.Headers)
+		}
+		if string(sig.BodyHash) != "abc" || string(sig.Signature) != "def" {
+			t.Errorf("[Test Case %v] Wrong hashes. Got: %q, %q", index, sig.BodyHash, sig.Signature)
+		}
+	}
+}
+
+func TestVerifyDKIM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortKey, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkix := func(key interface{}) string {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(der)
+	}
+
+	resolver := txtRecords{
+		"rsa._domainkey.example.com":     {"v=DKIM1; k=rsa; p=" + pkix(&rsaKey.PublicKey)},
+		"pkcs1._domainkey.example.com":   {"v=DKIM1; p=" + base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))},
+		"ed._domainkey.example.com":      {"v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPub)},
+		"revoked._domainkey.example.com": {"v=DKIM1; p="},
+		"short._domainkey.example.com":   {"v=DKIM1; p=" + pkix(&shortKey.PublicKey)},
+	}
+
+	e, err := NewEmail().
+		From("John Doe <jdoe@example.com>").
+		To("mary@example.com").
+		Subject("Signed").
+		Text("Hello  Mary, \nbye\n").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = map[int]struct {
+		signer DKIMSigner
+		tamper func([]byte) []byte
+		status DKIMStatus
+	}{
+		1: {signer: DKIMSigner{Domain: "example.com", Selector: "rsa", Key: rsaKey}, status: DKIMPass},
+		2: {signer: DKIMSigner{Domain: "example.com", Selector: "ed", Key: edKey}, status: DKIMPass},
+		3: {signer: DKIMSigner{Domain: "example.com", Selector: "pkcs1", Key: rsaKey, HeaderCanonicalization: CanonicalizationSimple, BodyCanonicalization: CanonicalizationSimple}, status: DKIMPass},
+		4: {
+			signer: DKIMSigner{Domain: "example.com", Selector: "rsa", Key: rsaKey},
+			tamper: func(raw []byte) []byte { return bytes.Replace(raw, []byte("bye"), []byte("BYE"), 1) },
+			status: DKIMFail,
+		},
+		5: {
+			signer: DKIMSigner{Domain: "example.com", Selector: "rsa", Key: rsaKey},
+			tamper: func(raw []byte) []byte {
+				return bytes.Replace(raw, []byte("Subject: Signed"), []byte("Subject: Changed"), 1)
+			},
+			status: DKIMFail,
+		},
+		6: {
+			signer: DKIMSigner{Domain: "example.com", Selector: "rsa", Key: rsaKey},
+			tamper: func(raw []byte) []byte { return append([]byte("Received: from relay\r\n"), raw...) },
+			status: DKIMPass,
+		},
+		7:  {signer: DKIMSigner{Domain: "example.com", Selector: "missing", Key: rsaKey}, status: DKIMPermError},
+		8:  {signer: DKIMSigner{Domain: "example.com", Selector: "down", Key: rsaKey}, status: DKIMTempError},
+		9:  {signer: DKIMSigner{Domain: "example.com", Selector: "revoked", Key: rsaKey}, status: DKIMPermError},
+		10: {signer: DKIMSigner{Domain: "example.com", Selector: "short", Key: shortKey}, status: DKIMPermError},
+		11: {signer: DKIMSigner{Domain: "example.com", Selector: "rsa", Key: edKey}, status: DKIMPermError},
+	}
+
+	for index, td := range testData {
+		signer := td.signer
+		raw, err := SerializeSigned(&e, &signer)
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if td.tamper != nil {
+			raw = td.tamper(raw)
+		}
+
+		parsed, err := ParseWithOptions(bytes.NewReader(raw), Options{KeepRaw: true})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		results := parsed.VerifyDKIM(context.Background(), resolver)
+		if len(results) != 1 {
+			t.Errorf("[Test Case %v] Wrong number of results. Expected: 1, Got: %v", index, len(results))
+			continue
+		}
+		if results[0].Status != td.status {
+			t.Errorf("[Test Case %v] Wrong status. Expected: %s, Got: %s (%v)", index, td.status, results[0].Status, results[0].Err)
+		}
+		if results[0].Signature == nil || results[0].Signature.Selector != signer.Selector {
+			t.Errorf("[Test Case %v] Wrong signature. Got: %+v", index, results[0].Signature)
+		}
+	}
+
+	parsed, err := Parse(bytes.NewReader([]byte("DKIM-Signature: v=1; a=rsa-sha256\r\nFrom: jdoe@example.com\r\n\r\nHi\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := parsed.VerifyDKIM(context.Background(), resolver)
+	if len(results) != 1 || results[0].Status != DKIMPermError || results[0].Signature != nil {
+		t.Errorf("Wrong result of a malformed signature: %+v", results)
+	}
+	if len(parsed.DKIMSignatures()) != 0 {
+		t.Errorf("Expected malformed signatures to be skipped")
+	}
+}
@@ -0,0 +1,174 @@
+package parsemail
+
+import (
+	"encoding/base64"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxEncodedWord is the longest encoded-word RFC 2047 allows
+	maxEncodedWord = 75
+
+	// maxPlainWord is the longest word left unencoded, longer words can't be
+	// folded
+	maxPlainWord = 76
+
+	encodedWordPrefixQ = "=?utf-8?q?"
+	encodedWordPrefixB = "=?utf-8?b?"
+	encodedWordSuffix  = "?="
+)
+
+// EncodeHeaderText encodes unstructured header text like a subject with
+// RFC 2047 encoded-words. Only runs of words that need it are encoded: words
+// with non-ASCII or control characters, words that could be mistaken for
+// encoded-words and words too long to be folded. Every encoded-word stays
+// within 75 characters and uses Q or B encoding, whichever is shorter. Text
+// that needs no encoding is returned unchanged.
+func EncodeHeaderText(s string) string {
+	return encodeWords(s, false)
+}
+
+// EncodePhrase encodes a display name for an address or group. Names with
+// non-ASCII characters become encoded-words restricted to the characters RFC
+// 2047 allows in phrases, other names are quoted when they aren't plain atoms.
+func EncodePhrase(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 || s[i] < ' ' && s[i] != '\t' {
+			return encodeWords(s, true)
+		}
+	}
+
+	return quotePhrase(s)
+}
+
+// encodeWords encodes the runs of words of s that need encoding
+func encodeWords(s string, phrase bool) string {
+	words := strings.Split(s, " ")
+
+	var out []string
+	for i := 0; i < len(words); {
+		if !needsEncoding(words[i], phrase) {
+			out = append(out, words[i])
+			i++
+			continue
+		}
+
+		// adjacent words are encoded together, keeping the space between
+		// them, which decoders drop between encoded-words
+		j := i + 1
+		for j < len(words) && needsEncoding(words[j], phrase) {
+			j++
+		}
+
+		out = append(out, encodeRun(strings.Join(words[i:j], " "), phrase)...)
+		i = j
+	}
+
+	return strings.Join(out, " ")
+}
+
+func needsEncoding(word string, phrase bool) bool {
+	if phrase {
+		// a phrase is either encoded as a whole or quoted
+		return true
+	}
+
+	if len(word) > maxPlainWord || strings.Contains(word, "=?") {
+		return true
+	}
+
+	for i := 0; i < len(word); i++ {
+		if word[i] >= 0x7f || word[i] < ' ' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encodeRun encodes text as encoded-words split at character boundaries
+func encodeRun(text string, phrase bool) []string {
+	qLen := 0
+	for i := 0; i < len(text); i++ {
+		qLen += qCharLen(text[i], phrase)
+	}
+	useQ := qLen <= (len(text)+2)/3*4
+
+	prefix := encodedWordPrefixB
+	if useQ {
+		prefix = encodedWordPrefixQ
+	}
+	room := maxEncodedWord - len(prefix) - len(encodedWordSuffix)
+
+	var words []string
+	start, encodedLen := 0, 0
+	for i := 0; i < len(text); {
+		_, size := utf8.DecodeRuneInString(text[i:])
+
+		runeLen := 0
+		if useQ {
+			for k := i; k < i+size; k++ {
+				runeLen += qCharLen(text[k], phrase)
+			}
+		}
+
+		fits := encodedLen+runeLen <= room
+		if !useQ {
+			fits = (i+size-start+2)/3*4 <= room
+		}
+
+		if !fits && i > start {
+			words = append(words, encodeWord(text[start:i], useQ, phrase))
+			start, encodedLen = i, 0
+		}
+
+		encodedLen += runeLen
+		i += size
+	}
+
+	return append(words, encodeWord(text[start:], useQ, phrase))
+}
+
+func encodeWord(text string, useQ, phrase bool) string {
+	if !useQ {
+		return encodedWordPrefixB + base64.StdEncoding.EncodeToString([]byte(text)) + encodedWordSuffix
+	}
+
+	var sb strings.Builder
+	sb.WriteString(encodedWordPrefixQ)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == ' ':
+			sb.WriteByte('_')
+		case qCharLen(c, phrase) == 1:
+			sb.WriteByte(c)
+		default:
+			sb.WriteString(qpEscape(c))
+		}
+	}
+	sb.WriteString(encodedWordSuffix)
+
+	return sb.String()
+}
+
+// qCharLen returns the length of c in a Q encoded-word. Phrases only allow
+// letters, digits and "!*+-/" unencoded (RFC 2047 section 5).
+func qCharLen(c byte, phrase bool) int {
+	switch {
+	case c == ' ':
+		return 1
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return 1
+	case phrase:
+		if strings.IndexByte("!*+-/", c) >= 0 {
+			return 1
+		}
+		return 3
+	case c > ' ' && c < 0x7f && c != '=' && c != '?' && c != '_':
+		return 1
+	}
+
+	return 3
+}
@@ -0,0 +1,97 @@
+package parsemail
+
+import (
+	"math/rand"
+	"mime"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestEncodeHeaderText(t *testing.T) {
+	var testData = map[int]struct {
+		text     string
+		expected string
+	}{
+		1: {text: "Hello world", expected: "Hello world"},
+		2: {text: "Grüße aus Köln", expected: "=?utf-8?b?R3LDvMOfZQ==?= aus =?utf-8?b?S8O2bG4=?="},
+		3: {text: "Bücherregalsystem im Büro", expected: "=?utf-8?q?B=C3=BCcherregalsystem?= im =?utf-8?b?QsO8cm8=?="},
+		4: {text: "日本語", expected: "=?utf-8?b?5pel5pys6Kqe?="},
+		5: {text: "=?utf-8?q?x?=", expected: "=?utf-8?b?PT91dGYtOD9xP3g/PQ==?="},
+		7: {text: strings.Repeat("x", 100), expected: "=?utf-8?q?" + strings.Repeat("x", 63) + "?= =?utf-8?q?" + strings.Repeat("x", 37) + "?="},
+		6: {text: "tab\there", expected: "=?utf-8?q?tab=09here?="},
+	}
+
+	for index, td := range testData {
+		if got := EncodeHeaderText(td.text); got != td.expected {
+			t.Errorf("[Test Case %v] Wrong encoding. Expected: %s, Got: %s", index, td.expected, got)
+		}
+	}
+}
+
+func TestEncodePhrase(t *testing.T) {
+	var testData = map[int]struct {
+		name     string
+		expected string
+	}{
+		1: {name: "John Doe", expected: "John Doe"},
+		2: {name: "Doe, John", expected: `"Doe, John"`},
+		3: {name: "Jürgen", expected: "=?utf-8?q?J=C3=BCrgen?="},
+		4: {name: "Müller, Jürgen (HR)", expected: "=?utf-8?b?TcO8bGxlciwgSsO8cmdlbiAoSFIp?="},
+	}
+
+	for index, td := range testData {
+		if got := EncodePhrase(td.name); got != td.expected {
+			t.Errorf("[Test Case %v] Wrong encoding. Expected: %s, Got: %s", index, td.expected, got)
+		}
+	}
+}
+
+// headerText generates texts mixing ASCII words, non-ASCII words and long
+// words
+type headerText string
+
+var headerTextTokens = []string{"a", "Hello", "Grüße", "日本語", "😀", "=?", "?=", "_", "=", "(", ",", strings.Repeat("x", 90), "é"}
+
+func (headerText) Generate(r *rand.Rand, size int) reflect.Value {
+	words := make([]string, r.Intn(size+1))
+	for i := range words {
+		var sb strings.Builder
+		for n := r.Intn(4) + 1; n > 0; n-- {
+			sb.WriteString(headerTextTokens[r.Intn(len(headerTextTokens))])
+		}
+		words[i] = sb.String()
+	}
+
+	return reflect.ValueOf(headerText(strings.Join(words, " ")))
+}
+
+func TestEncodeHeaderTextRoundTrip(t *testing.T) {
+	dec := new(mime.WordDecoder)
+
+	roundTrip := func(text headerText, phrase bool) bool {
+		var encoded string
+		if phrase {
+			encoded = encodeWords(string(text), true)
+		} else {
+			encoded = EncodeHeaderText(string(text))
+		}
+
+		for _, word := range strings.Split(encoded, " ") {
+			if len(word) > maxEncodedWord && strings.HasPrefix(word, "=?") {
+				return false
+			}
+			if phrase && strings.ContainsAny(word[minInt(len(word), 10):], `,()<>@:;"`) {
+				return false
+			}
+		}
+
+		decoded, err := dec.DecodeHeader(encoded)
+		return err == nil && decoded == string(text) && decodeMimeSentence(encoded) == string(text)
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
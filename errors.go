@@ -0,0 +1,57 @@
+package parsemail
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors of parsing, wrapped in a *ParseError that names the
+// offending value. They can be told apart with errors.Is, or by comparing
+// the Err of the ParseError on Go versions without it.
+var (
+	// ErrUnknownEncoding is returned for a Content-Transfer-Encoding the
+	// parser can't decode, see Options.TolerateUnknownEncoding
+	ErrUnknownEncoding = errors.New("unknown transfer encoding")
+	// ErrMalformedContentType is returned for a Content-Type that doesn't
+	// parse
+	ErrMalformedContentType = errors.New("malformed content type")
+	// ErrBoundaryMissing is returned for a multipart body without a boundary
+	// parameter
+	ErrBoundaryMissing = errors.New("multipart boundary missing")
+	// ErrUnsupportedCharset is returned by strict parses for a text part in
+	// a charset that can't be decoded, unless CharsetFallback is set
+	ErrUnsupportedCharset = errors.New("unsupported charset")
+)
+
+// ErrRawNotKept is returned by the methods that need the original message
+// of an email parsed without Options.KeepRaw
+var ErrRawNotKept = errors.New("raw message not kept, parse with Options.KeepRaw")
+
+// ParseError is a parse error of a kind given by one of the sentinel
+// errors, with the value that caused it
+type ParseError struct {
+	// Err is ErrUnknownEncoding, ErrMalformedContentType, ErrBoundaryMissing
+	// or ErrUnsupportedCharset
+	Err error
+	// Value is the offending encoding, content type or charset
+	Value string
+
+	cause error
+}
+
+func (e *ParseError) Error() string {
+	msg := e.Err.Error()
+	if e.Value != "" {
+		msg += fmt.Sprintf(" %q", e.Value)
+	}
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+
+	return msg
+}
+
+// Unwrap returns the sentinel error for errors.Is
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,62 @@
+package parsemail
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	var testData = map[int]struct {
+		raw    string
+		opts   Options
+		target error
+		value  string
+	}{
+		1: {
+			raw:    "Content-Type: application/pdf\r\nContent-Transfer-Encoding: x-gzip64\r\n\r\nH4sI\r\n",
+			target: ErrUnknownEncoding,
+			value:  "x-gzip64",
+		},
+		2: {
+			raw:    "Content-Type: text/plain; charset\r\n\r\nHi\r\n",
+			target: ErrMalformedContentType,
+			value:  "text/plain; charset",
+		},
+		3: {
+			raw:    "Content-Type: multipart/mixed\r\n\r\n--b\r\n\r\nHi\r\n--b--\r\n",
+			target: ErrBoundaryMissing,
+		},
+		4: {
+			raw:    "Content-Type: text/plain; charset=x-klingon\r\n\r\nHi\r\n",
+			opts:   Options{Strict: true},
+			target: ErrUnsupportedCharset,
+			value:  "x-klingon",
+		},
+		5: {
+			raw: "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/html; charset=\"utf-8\r\n\r\nHi\r\n--b--\r\n",
+			opts:   Options{Strict: true},
+			target: ErrMalformedContentType,
+			value:  "text/html; charset=\"utf-8",
+		},
+	}
+
+	for index, td := range testData {
+		_, err := ParseWithOptions(strings.NewReader("From: a@example.com\r\n"+td.raw), td.opts)
+		if !errors.Is(err, td.target) {
+			t.Errorf("[Test Case %v] Wrong error. Expected: %v, Got: %v", index, td.target, err)
+			continue
+		}
+
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.Value != td.value {
+			t.Errorf("[Test Case %v] Wrong value. Expected: %s, Got: %+v", index, td.value, pe)
+		}
+	}
+
+	// the charset is sniffed without Strict
+	if _, err := Parse(strings.NewReader("From: a@example.com\r\nContent-Type: text/plain; charset=x-klingon\r\n\r\nHi\r\n")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
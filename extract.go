@@ -0,0 +1,170 @@
+package parsemail
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+const FindingExtractionFailed FindingCode = "extraction-failed"
+
+// TextExtractor produces searchable text from the content of an attachment,
+// like the text of a PDF or the cells of a spreadsheet
+type TextExtractor interface {
+	ExtractText(filename, contentType string, data []byte) (string, error)
+}
+
+// TextExtractorFunc adapts a function to a TextExtractor
+type TextExtractorFunc func(filename, contentType string, data []byte) (string, error)
+
+// ExtractText calls f
+func (f TextExtractorFunc) ExtractText(filename, contentType string, data []byte) (string, error) {
+	return f(filename, contentType, data)
+}
+
+// ExtractorRegistry maps content types to text extractors. Set it as
+// Options.Extractors to have the parser fill in the ExtractedText of
+// attachments. Extractors must be registered before parsing starts.
+type ExtractorRegistry struct {
+	entries []extractorEntry
+}
+
+type extractorEntry struct {
+	pattern   string
+	extractor TextExtractor
+}
+
+// NewExtractorRegistry returns an empty registry
+func NewExtractorRegistry() *ExtractorRegistry {
+	return &ExtractorRegistry{}
+}
+
+// Register adds an extractor for the content types matching pattern, a full
+// content type like "application/pdf" or a type with a "*" subtype like
+// "text/*". A full content type takes precedence over patterns, and a later
+// registration over an earlier one of the same kind.
+func (r *ExtractorRegistry) Register(pattern string, x TextExtractor) {
+	r.entries = append(r.entries, extractorEntry{pattern: pattern, extractor: x})
+}
+
+// Lookup returns the extractor for contentType, or nil if none is registered
+func (r *ExtractorRegistry) Lookup(contentType string) TextExtractor {
+	var wildcard TextExtractor
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		e := r.entries[i]
+		if !matchContentType(contentType, []string{e.pattern}) {
+			continue
+		}
+		if !strings.Contains(e.pattern, "*") {
+			return e.extractor
+		}
+		if wildcard == nil {
+			wildcard = e.extractor
+		}
+	}
+
+	return wildcard
+}
+
+// lookupFile returns the extractor for a file. Files sent as
+// application/octet-stream are looked up by the content type of their
+// extension.
+func (r *ExtractorRegistry) lookupFile(filename, contentType string) (TextExtractor, string) {
+	if x := r.Lookup(contentType); x != nil {
+		return x, contentType
+	}
+
+	if !strings.EqualFold(contentType, "application/octet-stream") {
+		return nil, ""
+	}
+
+	byExt, _, err := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(filename)))
+	if err != nil {
+		return nil, ""
+	}
+
+	return r.Lookup(byExt), byExt
+}
+
+// extractText sets the ExtractedText of an attachment with the extractor
+// registered in the options or the image text hook. Failing extractors are
+// reported as warnings.
+func (p *parser) extractText(at *Attachment) {
+	at.ExtractedText = p.fileText(&at.Data, "attachment", at.Filename, at.ContentType)
+}
+
+// extractEmbeddedText sets the ExtractedText of an embedded file, see
+// extractText
+func (p *parser) extractEmbeddedText(ef *EmbeddedFile) {
+	name := ef.Filename
+	if name == "" {
+		name = ef.CID
+	}
+
+	ef.ExtractedText = p.fileText(&ef.Data, "embedded file", name, ef.ContentType)
+}
+
+// fileText runs the extractor for a file and returns its text
+func (p *parser) fileText(data *io.Reader, kind, filename, contentType string) string {
+	if *data == nil {
+		return ""
+	}
+
+	// only attachments are looked up in the registry, embedded files are
+	// mostly images of the html body
+	var x TextExtractor
+	if p.opts.Extractors != nil && kind == "attachment" {
+		if found, ct := p.opts.Extractors.lookupFile(filename, contentType); found != nil {
+			x, contentType = found, ct
+		}
+	}
+	if x == nil && p.opts.ImageText != nil && matchContentType(contentType, []string{"image/*"}) &&
+		(p.opts.MinImageTextSize <= 0 || decodedLen(*data) >= int64(p.opts.MinImageTextSize)) {
+		x = p.opts.ImageText
+	}
+	if x == nil {
+		return ""
+	}
+
+	b, err := rewindData(data)
+	if err != nil {
+		p.warn(FindingExtractionFailed, fmt.Sprintf("extracting text of %s %q (%s): %v", kind, filename, contentType, err))
+		return ""
+	}
+
+	text, err := x.ExtractText(filename, contentType, b)
+	if err != nil {
+		p.warn(FindingExtractionFailed, fmt.Sprintf("extracting text of %s %q (%s): %v", kind, filename, contentType, err))
+	}
+
+	return text
+}
+
+// SearchText returns the text to index the email by: the subject, the text
+// body, or the text of the html body without one, and the extracted text of
+// attachments and embedded files, separated by blank lines.
+func (e *Email) SearchText() string {
+	body := e.TextBody
+	if strings.TrimSpace(body) == "" {
+		body = htmlToText(e.HTMLBody)
+	}
+
+	parts := []string{e.Subject, body}
+	for _, a := range e.Attachments {
+		parts = append(parts, a.ExtractedText)
+	}
+	for _, ef := range e.EmbeddedFiles {
+		parts = append(parts, ef.ExtractedText)
+	}
+
+	var nonEmpty []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+
+	return strings.Join(nonEmpty, "\n\n")
+}
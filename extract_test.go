@@ -0,0 +1,162 @@
+package parsemail
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExtractorRegistryLookup(t *testing.T) {
+	named := func(name string) TextExtractor {
+		return TextExtractorFunc(func(string, string, []byte) (string, error) {
+			return name, nil
+		})
+	}
+
+	r := NewExtractorRegistry()
+	r.Register("application/pdf", named("pdf"))
+	r.Register("*/*", named("any"))
+	r.Register("text/*", named("text"))
+	r.Register("text/csv", named("csv"))
+	r.Register("TEXT/CSV", named("csv2"))
+
+	var testData = map[int]struct {
+		contentType string
+		extractor   string
+	}{
+		1: {"application/pdf", "pdf"},
+		2: {"text/plain", "text"},
+		3: {"text/csv", "csv2"},
+		4: {"image/png", "any"},
+	}
+
+	for index, td := range testData {
+		x := r.Lookup(td.contentType)
+		if x == nil {
+			t.Errorf("[Test Case %v] No extractor for %s", index, td.contentType)
+			continue
+		}
+
+		if name, _ := x.ExtractText("", td.contentType, nil); name != td.extractor {
+			t.Errorf("[Test Case %v] Wrong extractor. Expected: %s, Got: %s", index, td.extractor, name)
+		}
+	}
+
+	if x := NewExtractorRegistry().Lookup("application/pdf"); x != nil {
+		t.Errorf("Empty registry returned an extractor")
+	}
+}
+
+func TestParseExtractsText(t *testing.T) {
+	r := NewExtractorRegistry()
+	r.Register("application/pdf", TextExtractorFunc(func(filename, contentType string, data []byte) (string, error) {
+		return fmt.Sprintf("%s %s %q", filename, contentType, data), nil
+	}))
+	r.Register("application/vnd.ms-excel", TextExtractorFunc(func(string, string, []byte) (string, error) {
+		return "", errors.New("corrupt workbook")
+	}))
+
+	e, err := ParseWithOptions(strings.NewReader(extractableParts), Options{Extractors: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`invoice.pdf application/pdf "%PDF-1.4\n"`,
+		`scan.pdf application/pdf "%PDF-1.5\n"`,
+		"",
+		"",
+	}
+	if len(e.Attachments) != len(expected) {
+		t.Fatalf("Wrong number of attachments. Expected: %v, Got: %v", len(expected), len(e.Attachments))
+	}
+	for i, a := range e.Attachments {
+		if a.ExtractedText != expected[i] {
+			t.Errorf("[Attachment %v] Wrong extracted text. Expected: %s, Got: %s", i, expected[i], a.ExtractedText)
+		}
+	}
+
+	// the data can still be read after the extraction
+	if data, err := e.Attachments[0].bytes(); err != nil || string(data) != "%PDF-1.4\n" {
+		t.Errorf("Wrong attachment data after extraction: %q, %v", data, err)
+	}
+
+	if len(e.Warnings) != 1 || e.Warnings[0].Code != FindingExtractionFailed ||
+		!strings.Contains(e.Warnings[0].Message, "corrupt workbook") {
+		t.Errorf("Wrong warnings. Expected an extraction failure, Got: %v", e.Warnings)
+	}
+}
+
+var extractableParts = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Documents
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Content-Type: multipart/mixed; boundary="MIX"
+
+--MIX
+Content-Type: text/plain
+
+See attached.
+--MIX
+Content-Type: application/pdf; name="invoice.pdf"
+Content-Disposition: attachment; filename="invoice.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0xLjQK
+--MIX
+Content-Type: application/octet-stream; name="scan.pdf"
+Content-Disposition: attachment; filename="scan.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0xLjUK
+--MIX
+Content-Type: application/vnd.ms-excel; name="report.xls"
+Content-Disposition: attachment; filename="report.xls"
+Content-Transfer-Encoding: base64
+
+0M8R4KGxGuE=
+--MIX
+Content-Type: application/zip; name="archive.zip"
+Content-Disposition: attachment; filename="archive.zip"
+Content-Transfer-Encoding: base64
+
+UEsFBgAAAAAAAAAAAAAAAAAAAAAAAA==
+--MIX--
+`
+
+func TestParseImageText(t *testing.T) {
+	// lazy attachments are sized by their decoded length
+	for _, lazy := range []bool{false, true} {
+		var calls []string
+		ocr := TextExtractorFunc(func(filename, contentType string, data []byte) (string, error) {
+			calls = append(calls, filename)
+			return "text of " + filename, nil
+		})
+
+		e, err := ParseWithOptions(strings.NewReader(filteredParts), Options{ImageText: ocr, MinImageTextSize: 16, LazyAttachments: lazy})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the pixel is below the threshold and the pdf is no image
+		if !assertSliceEq(calls, []string{"logo@example.com"}) {
+			t.Errorf("[lazy %v] Wrong image text calls. Expected: %s, Got: %s", lazy, []string{"logo@example.com"}, calls)
+		}
+
+		for _, ef := range e.EmbeddedFiles {
+			expected := ""
+			if ef.CID == "logo@example.com" {
+				expected = "text of logo@example.com"
+			}
+			if ef.ExtractedText != expected {
+				t.Errorf("[lazy %v] Wrong extracted text of %s. Expected: %s, Got: %s", lazy, ef.CID, expected, ef.ExtractedText)
+			}
+		}
+
+		expected := "Invoice\n\nYour invoice\n\ntext of logo@example.com"
+		if text := e.SearchText(); text != expected {
+			t.Errorf("[lazy %v] Wrong search text. Expected: %q, Got: %q", lazy, expected, text)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package parsemail
+
+import (
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const contentTypeFeedbackReport = "message/feedback-report"
+
+// FeedbackReport is the content of an abuse feedback report in the Abuse
+// Reporting Format (RFC 5965), sent by mailbox providers when a user marks a
+// message as spam
+type FeedbackReport struct {
+	// FeedbackType is abuse, fraud, virus, not-spam, auth-failure or other
+	FeedbackType string
+	UserAgent    string
+	Version      string
+
+	SourceIP         net.IP
+	ArrivalDate      time.Time
+	ReportingMTA     string
+	OriginalMailFrom string
+	OriginalRcptTo   []string
+	ReportedDomain   []string
+	ReportedURI      []string
+	Incidents        int
+
+	AuthenticationResults []string
+
+	// OriginalHeader is the header of the reported message, which is
+	// included completely or as text/rfc822-headers
+	OriginalHeader mail.Header
+}
+
+// FeedbackReport returns the abuse feedback report of a multipart/report
+// message with a message/feedback-report part, also when the report is
+// forwarded within another message. It returns nil if the email contains no
+// feedback report.
+func (e *Email) FeedbackReport() (*FeedbackReport, error) {
+	var report *FeedbackReport
+	var parseErr error
+
+	p := e.partParser()
+	err := e.walkReportParts(p, func(header textproto.MIMEHeader, body []byte) {
+		contentType, _, _ := parseContentType(header.Get("Content-Type"))
+		if report != nil || !strings.EqualFold(contentType, contentTypeFeedbackReport) {
+			return
+		}
+
+		data, err := p.decodeRawBody(header, body)
+		if err != nil {
+			parseErr = err
+			return
+		}
+
+		h, err := readHeaderBlock(data)
+		if err != nil {
+			parseErr = err
+			return
+		}
+		report = parseFeedbackReport(h)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if parseErr != nil || report == nil {
+		return nil, parseErr
+	}
+
+	original := e.OriginalMessage
+	if original == nil {
+		original = e.parseOriginalMessage()
+	}
+	if original != nil {
+		report.OriginalHeader = original.Header
+	}
+
+	return report, nil
+}
+
+func parseFeedbackReport(h textproto.MIMEHeader) *FeedbackReport {
+	report := &FeedbackReport{
+		FeedbackType:          strings.ToLower(strings.TrimSpace(h.Get("Feedback-Type"))),
+		UserAgent:             strings.TrimSpace(h.Get("User-Agent")),
+		Version:               strings.TrimSpace(h.Get("Version")),
+		SourceIP:              net.ParseIP(strings.Trim(h.Get("Source-Ip"), "[] ")),
+		ReportingMTA:          reportAddress(h.Get("Reporting-Mta")),
+		OriginalMailFrom:      strings.Trim(strings.TrimSpace(h.Get("Original-Mail-From")), "<>"),
+		ReportedDomain:        trimmedValues(h["Reported-Domain"]),
+		ReportedURI:           trimmedValues(h["Reported-Uri"]),
+		AuthenticationResults: trimmedValues(h["Authentication-Results"]),
+		Incidents:             1,
+	}
+
+	for _, r := range h["Original-Rcpt-To"] {
+		report.OriginalRcptTo = append(report.OriginalRcptTo, strings.Trim(strings.TrimSpace(r), "<>"))
+	}
+
+	// Received-Date is the name used by early drafts
+	date := h.Get("Arrival-Date")
+	if date == "" {
+		date = h.Get("Received-Date")
+	}
+	if t, err := mail.ParseDate(strings.TrimSpace(date)); err == nil {
+		report.ArrivalDate = t
+	}
+
+	if n, err := strconv.Atoi(strings.TrimSpace(h.Get("Incidents"))); err == nil && n > 0 {
+		report.Incidents = n
+	}
+
+	return report
+}
+
+func trimmedValues(values []string) (trimmed []string) {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			trimmed = append(trimmed, v)
+		}
+	}
+
+	return
+}
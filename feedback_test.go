@@ -0,0 +1,168 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedbackReport(t *testing.T) {
+	var testData = map[int]struct {
+		mailData        string
+		feedbackType    string
+		userAgent       string
+		sourceIP        string
+		mailFrom        string
+		rcptTo          []string
+		arrivalDate     time.Time
+		originalSubject string
+	}{
+		1: {
+			mailData: rfc5322exampleA11,
+		},
+		2: {
+			mailData:        feedbackAbuse,
+			feedbackType:    "abuse",
+			userAgent:       "SomeGenerator/1.0",
+			sourceIP:        "192.0.2.2",
+			mailFrom:        "somespammer@example.net",
+			rcptTo:          []string{"user@example.com"},
+			arrivalDate:     time.Date(2005, 3, 8, 18, 0, 0, 0, time.FixedZone("", -5*3600)),
+			originalSubject: "Earn money",
+		},
+		3: {
+			mailData: "From: Postmaster <postmaster@example.org>\nSubject: FW: complaint\n" +
+				"Content-Type: multipart/mixed; boundary=\"outer\"\n\n--outer\n" +
+				"Content-Type: text/plain\n\nForwarding a complaint.\n--outer\n" +
+				strings.SplitN(feedbackAbuse, "Subject: Abuse report\n", 2)[1] + "\n--outer--\n",
+			feedbackType:    "abuse",
+			userAgent:       "SomeGenerator/1.0",
+			sourceIP:        "192.0.2.2",
+			mailFrom:        "somespammer@example.net",
+			rcptTo:          []string{"user@example.com"},
+			arrivalDate:     time.Date(2005, 3, 8, 18, 0, 0, 0, time.FixedZone("", -5*3600)),
+			originalSubject: "Earn money",
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Errorf("[Test Case %v] Error parsing email: %v", index, err)
+			continue
+		}
+
+		report, err := e.FeedbackReport()
+		if err != nil {
+			t.Errorf("[Test Case %v] Error reading feedback report: %v", index, err)
+			continue
+		}
+
+		if td.feedbackType == "" {
+			if report != nil {
+				t.Errorf("[Test Case %v] Wrong report. Expected: nil, Got: %+v", index, report)
+			}
+			continue
+		}
+		if report == nil {
+			t.Errorf("[Test Case %v] Wrong report. Expected a report, Got: nil", index)
+			continue
+		}
+
+		if report.FeedbackType != td.feedbackType {
+			t.Errorf("[Test Case %v] Wrong feedback type. Expected: %s, Got: %s", index, td.feedbackType, report.FeedbackType)
+		}
+
+		if report.UserAgent != td.userAgent {
+			t.Errorf("[Test Case %v] Wrong user agent. Expected: %s, Got: %s", index, td.userAgent, report.UserAgent)
+		}
+
+		if report.SourceIP.String() != td.sourceIP {
+			t.Errorf("[Test Case %v] Wrong source IP. Expected: %s, Got: %s", index, td.sourceIP, report.SourceIP)
+		}
+
+		if report.OriginalMailFrom != td.mailFrom {
+			t.Errorf("[Test Case %v] Wrong original mail from. Expected: %s, Got: %s", index, td.mailFrom, report.OriginalMailFrom)
+		}
+
+		if !assertSliceEq(report.OriginalRcptTo, td.rcptTo) {
+			t.Errorf("[Test Case %v] Wrong original rcpt to. Expected: %s, Got: %s", index, td.rcptTo, report.OriginalRcptTo)
+		}
+
+		if !report.ArrivalDate.Equal(td.arrivalDate) {
+			t.Errorf("[Test Case %v] Wrong arrival date. Expected: %s, Got: %s", index, td.arrivalDate, report.ArrivalDate)
+		}
+
+		if got := report.OriginalHeader.Get("Subject"); got != td.originalSubject {
+			t.Errorf("[Test Case %v] Wrong original subject. Expected: %s, Got: %s", index, td.originalSubject, got)
+		}
+	}
+}
+
+var feedbackAbuse = `From: <abusedesk@example.com>
+Date: Thu, 8 Mar 2005 17:40:36 EDT
+Subject: Abuse report
+To: <abuse@example.net>
+Message-ID: <20030712040037.46341.5F8J@example.com>
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report; boundary="part1_13d.2e68ed54_boundary"
+
+--part1_13d.2e68ed54_boundary
+Content-Type: text/plain; charset="US-ASCII"
+Content-Transfer-Encoding: 7bit
+
+This is an email abuse report for an email message received from IP
+192.0.2.2 on Thu, 8 Mar 2005 14:00:00 EDT.
+--part1_13d.2e68ed54_boundary
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: SomeGenerator/1.0
+Version: 1
+Original-Mail-From: <somespammer@example.net>
+Original-Rcpt-To: <user@example.com>
+Arrival-Date: Tue, 8 Mar 2005 18:00:00 -0500
+Reporting-MTA: dns; mail.example.com
+Source-IP: 192.0.2.2
+Authentication-Results: mail.example.com; spf=fail smtp.mailfrom=somespammer@example.com
+Reported-Domain: example.net
+Reported-Uri: http://example.net/earn_money.html
+
+--part1_13d.2e68ed54_boundary
+Content-Type: message/rfc822
+Content-Disposition: inline
+
+From: <somespammer@example.net>
+Received: from mailserver.example.net (mailserver.example.net [192.0.2.1])
+        by example.com with ESMTP id M63d4137594e46;
+        Thu, 08 Mar 2005 14:00:00 -0400
+To: <Undisclosed Recipients>
+Subject: Earn money
+MIME-Version: 1.0
+Content-Type: text/plain
+Message-ID: 8787KJKJ3K4J3K4J3K4J3.mail@example.net
+Date: Thu, 02 Sep 2004 12:31:03 -0500
+
+Spam Spam Spam
+Spam Spam Spam
+--part1_13d.2e68ed54_boundary--
+`
+
+func TestFeedbackReportLimits(t *testing.T) {
+	mailData := "From: Postmaster <postmaster@example.org>\nSubject: FW: complaint\n" +
+		"Content-Type: multipart/mixed; boundary=\"outer\"\n\n--outer\n" +
+		"Content-Type: text/plain\n\nForwarding a complaint.\n--outer\n" +
+		strings.SplitN(feedbackAbuse, "Subject: Abuse report\n", 2)[1] + "\n--outer--\n"
+
+	// the parts of the report are decoded within the limits of the options
+	e, err := ParseWithOptions(strings.NewReader(mailData), Options{KeepRaw: true, LazyAttachments: true, MaxDecodedSize: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.FeedbackReport(); err == nil {
+		t.Error("Expected an error for a report part exceeding MaxDecodedSize")
+	} else if _, ok := err.(*DecodedSizeError); !ok {
+		t.Errorf("Wrong error. Expected: *DecodedSizeError, Got: %v", err)
+	}
+}
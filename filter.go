@@ -0,0 +1,66 @@
+package parsemail
+
+import (
+	"fmt"
+	"strings"
+)
+
+// addAttachment adds at to the email unless the content type filters of the
+// options drop it
+func (p *parser) addAttachment(at Attachment) {
+	if reason := p.dropReason(at.ContentType, at.Data == nil, readerLen(at.Data)); reason != "" {
+		p.warn(FindingPartDropped, fmt.Sprintf("dropped attachment %q (%s): %s", at.Filename, at.ContentType, reason))
+		return
+	}
+
+	at.opts = p.opts
+	p.extractText(&at)
+	p.email.Attachments = append(p.email.Attachments, at)
+}
+
+// addEmbeddedFile adds ef to the email unless the content type filters of
+// the options drop it
+func (p *parser) addEmbeddedFile(ef EmbeddedFile) {
+	if reason := p.dropReason(ef.ContentType, ef.Data == nil, readerLen(ef.Data)); reason != "" {
+		p.warn(FindingPartDropped, fmt.Sprintf("dropped embedded file %q (%s): %s", ef.CID, ef.ContentType, reason))
+		return
+	}
+
+	p.extractEmbeddedText(&ef)
+	p.email.EmbeddedFiles = append(p.email.EmbeddedFiles, ef)
+}
+
+// dropReason returns why a file of the given content type and size is
+// dropped by the options, or an empty string if it is kept. The size of
+// indexed files is unknown.
+func (p *parser) dropReason(contentType string, indexed bool, size int) string {
+	if len(p.opts.AllowContentTypes) > 0 && !matchContentType(contentType, p.opts.AllowContentTypes) {
+		return "content type not allowed"
+	}
+
+	if matchContentType(contentType, p.opts.DenyContentTypes) {
+		return "content type denied"
+	}
+
+	if p.opts.MinImageSize > 0 && !indexed && size >= 0 && size < p.opts.MinImageSize &&
+		matchContentType(contentType, []string{"image/*"}) {
+		return fmt.Sprintf("image smaller than %d bytes", p.opts.MinImageSize)
+	}
+
+	return ""
+}
+
+// matchContentType reports whether contentType matches one of the patterns,
+// which are either full content types or a type with a "*" subtype
+func matchContentType(contentType string, patterns []string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == contentType || pattern == "*/*" ||
+			(strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, pattern[:len(pattern)-1])) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,97 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContentTypeFilters(t *testing.T) {
+	var testData = map[int]struct {
+		opts          Options
+		attachments   []string
+		embeddedFiles []string
+		dropped       int
+	}{
+		1: {Options{}, []string{"setup.exe", "invoice.pdf"}, []string{"pixel@example.com", "logo@example.com"}, 0},
+		2: {Options{DenyContentTypes: []string{"application/x-msdownload"}}, []string{"invoice.pdf"}, []string{"pixel@example.com", "logo@example.com"}, 1},
+		3: {Options{AllowContentTypes: []string{"application/pdf", "IMAGE/*"}}, []string{"invoice.pdf"}, []string{"pixel@example.com", "logo@example.com"}, 1},
+		4: {Options{MinImageSize: 16}, []string{"setup.exe", "invoice.pdf"}, []string{"logo@example.com"}, 1},
+		5: {Options{DenyContentTypes: []string{"image/*"}, MinImageSize: 16}, []string{"setup.exe", "invoice.pdf"}, nil, 2},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(filteredParts), td.opts)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		var attachments, embeddedFiles []string
+		for _, a := range e.Attachments {
+			attachments = append(attachments, a.Filename)
+		}
+		for _, ef := range e.EmbeddedFiles {
+			embeddedFiles = append(embeddedFiles, ef.CID)
+		}
+
+		if !assertSliceEq(attachments, td.attachments) {
+			t.Errorf("[Test Case %v] Wrong attachments. Expected: %s, Got: %s", index, td.attachments, attachments)
+		}
+
+		if !assertSliceEq(embeddedFiles, td.embeddedFiles) {
+			t.Errorf("[Test Case %v] Wrong embedded files. Expected: %s, Got: %s", index, td.embeddedFiles, embeddedFiles)
+		}
+
+		dropped := 0
+		for _, w := range e.Warnings {
+			if w.Code == FindingPartDropped {
+				dropped++
+			}
+		}
+
+		if dropped != td.dropped {
+			t.Errorf("[Test Case %v] Wrong number of dropped part warnings. Expected: %v, Got: %v", index, td.dropped, dropped)
+		}
+	}
+}
+
+var filteredParts = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Invoice
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Content-Type: multipart/mixed; boundary="MIX"
+
+--MIX
+Content-Type: multipart/related; boundary="REL"
+
+--REL
+Content-Type: text/html
+
+<p>Your invoice <img src="cid:logo@example.com"><img src="cid:pixel@example.com"></p>
+--REL
+Content-Type: image/gif
+Content-Transfer-Encoding: base64
+Content-Id: <pixel@example.com>
+
+R0lGODlhAQABAAAAACw=
+--REL
+Content-Type: image/png
+Content-Transfer-Encoding: base64
+Content-Id: <logo@example.com>
+
+iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg==
+--REL--
+--MIX
+Content-Type: application/x-msdownload; name="setup.exe"
+Content-Disposition: attachment; filename="setup.exe"
+Content-Transfer-Encoding: base64
+
+TVqQAAMAAAAEAAAA
+--MIX
+Content-Type: application/pdf; name="invoice.pdf"
+Content-Disposition: attachment; filename="invoice.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0xLjQK
+--MIX--
+`
@@ -0,0 +1,67 @@
+package parsemail
+
+// FindingCode identifies the kind of anomaly described by a Finding
+type FindingCode string
+
+// Finding is a single anomaly detected while inspecting an email. Findings are
+// meant as signals for security and compliance tooling, not as parse errors.
+type Finding struct {
+	Code    FindingCode
+	Message string
+}
+
+const (
+	FindingBccPresent       FindingCode = "bcc-present"
+	FindingMissingFrom      FindingCode = "missing-from"
+	FindingMissingRecipient FindingCode = "missing-recipient"
+	FindingMissingDate      FindingCode = "missing-date"
+	FindingMissingMessageID FindingCode = "missing-message-id"
+	FindingMissingSender    FindingCode = "missing-sender"
+)
+
+// CheckHeaders reports header anomalies of a received email: a Bcc header,
+// which indicates a sender leaking its blind recipients, missing originator
+// or destination fields, and multiple From addresses without a Sender.
+func (e *Email) CheckHeaders() (findings []Finding) {
+	if _, ok := e.Header["Bcc"]; ok {
+		findings = append(findings, Finding{
+			Code:    FindingBccPresent,
+			Message: "received message contains a Bcc header",
+		})
+	}
+
+	if len(e.From) == 0 {
+		findings = append(findings, Finding{
+			Code:    FindingMissingFrom,
+			Message: "message has no From address",
+		})
+	} else if len(e.From) > 1 && e.Sender == nil {
+		findings = append(findings, Finding{
+			Code:    FindingMissingSender,
+			Message: "message has multiple From addresses but no Sender",
+		})
+	}
+
+	if len(e.To) == 0 && len(e.Cc) == 0 && len(e.Bcc) == 0 {
+		findings = append(findings, Finding{
+			Code:    FindingMissingRecipient,
+			Message: "message has no To, Cc or Bcc address",
+		})
+	}
+
+	if e.Date.IsZero() {
+		findings = append(findings, Finding{
+			Code:    FindingMissingDate,
+			Message: "message has no Date",
+		})
+	}
+
+	if e.MessageID == "" {
+		findings = append(findings, Finding{
+			Code:    FindingMissingMessageID,
+			Message: "message has no Message-ID",
+		})
+	}
+
+	return
+}
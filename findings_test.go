@@ -0,0 +1,59 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckHeaders(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		codes    []FindingCode
+	}{
+		1: {
+			mailData: rfc5322exampleA11,
+		},
+		2: {
+			mailData: checkHeadersBcc,
+			codes:    []FindingCode{FindingBccPresent},
+		},
+		3: {
+			mailData: checkHeadersIncomplete,
+			codes:    []FindingCode{FindingMissingSender, FindingMissingRecipient, FindingMissingDate, FindingMissingMessageID},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		findings := e.CheckHeaders()
+		if len(findings) != len(td.codes) {
+			t.Errorf("[Test Case %v] Wrong number of findings. Expected: %v, Got: %v", index, td.codes, findings)
+			continue
+		}
+
+		for i, f := range findings {
+			if f.Code != td.codes[i] {
+				t.Errorf("[Test Case %v] Wrong finding. Expected: %s, Got: %s", index, td.codes[i], f.Code)
+			}
+		}
+	}
+}
+
+var checkHeadersBcc = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Bcc: Secret <secret@example.net>
+Subject: Saying Hello
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Message-ID: <1234@local.machine.example>
+
+Hello.`
+
+var checkHeadersIncomplete = `From: John Doe <jdoe@machine.example>, Mary Smith <mary@example.net>
+Subject: Saying Hello
+
+Hello.`
@@ -0,0 +1,63 @@
+package parsemail
+
+import (
+	"strings"
+)
+
+const (
+	// headerLineLength is the line length header fields are folded at
+	headerLineLength = 78
+
+	// headerMaxLineLength is the longest line RFC 5322 allows
+	headerMaxLineLength = 998
+)
+
+// UnfoldHeader returns the logical form of a folded header value by removing
+// its line breaks. The whitespace after each line break is kept.
+func UnfoldHeader(value string) string {
+	value = strings.Replace(value, "\r\n", "\n", -1)
+	return strings.Replace(value, "\n", "", -1)
+}
+
+// FoldHeader formats a header field, including its final CRLF, folding the
+// value before whitespace so lines stay within 78 characters where possible.
+// A long first word starts on a line of its own. Folded values are unfolded
+// first and surrounding whitespace is removed. A word that doesn't fit into
+// the 998 characters RFC 5322 allows per line is split, which inserts a space.
+func FoldHeader(name, value string) string {
+	var sb strings.Builder
+
+	line := name + ":"
+	for _, token := range headerTokens(" " + strings.Trim(UnfoldHeader(value), " \t")) {
+		if len(line)+len(token) > headerLineLength {
+			sb.WriteString(line + "\r\n")
+			line = ""
+		}
+		line += token
+
+		for len(line) > headerMaxLineLength {
+			sb.WriteString(line[:headerMaxLineLength] + "\r\n")
+			line = " " + line[headerMaxLineLength:]
+		}
+	}
+	sb.WriteString(line + "\r\n")
+
+	return sb.String()
+}
+
+// headerTokens splits s before each run of whitespace following a word
+func headerTokens(s string) (tokens []string) {
+	start := 0
+	for i := 1; i < len(s); i++ {
+		if isHeaderSpace(s[i]) && !isHeaderSpace(s[i-1]) {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+	}
+
+	return append(tokens, s[start:])
+}
+
+func isHeaderSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
@@ -0,0 +1,104 @@
+package parsemail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestFoldHeader(t *testing.T) {
+	long := strings.Repeat("word ", 30)
+
+	var testData = map[int]struct {
+		name     string
+		value    string
+		expected string
+	}{
+		1: {name: "Subject", value: "Hello", expected: "Subject: Hello\r\n"},
+		2: {name: "Subject", value: "  padded\t", expected: "Subject: padded\r\n"},
+		3: {name: "Subject", value: "folded\r\n value", expected: "Subject: folded value\r\n"},
+		4: {
+			name:     "Subject",
+			value:    strings.TrimSpace(long),
+			expected: "Subject:" + strings.Repeat(" word", 14) + "\r\n" + strings.Repeat(" word", 15) + "\r\n" + " word\r\n",
+		},
+		5: {
+			name:     "X-Long",
+			value:    strings.Repeat("x", 1000),
+			expected: "X-Long:\r\n " + strings.Repeat("x", 997) + "\r\n xxx\r\n",
+		},
+	}
+
+	for index, td := range testData {
+		if got := FoldHeader(td.name, td.value); got != td.expected {
+			t.Errorf("[Test Case %v] Wrong folding. Expected: %q, Got: %q", index, td.expected, got)
+		}
+	}
+}
+
+func TestFoldHeaderRoundTrip(t *testing.T) {
+	// words shorter than the line limit survive folding and unfolding
+	roundTrip := func(words []string) bool {
+		for i, w := range words {
+			words[i] = strings.Map(func(r rune) rune {
+				if r <= ' ' || r == ':' || r > '~' {
+					return 'x'
+				}
+				return r
+			}, w)
+			if words[i] == "" {
+				words[i] = "x"
+			}
+		}
+		value := strings.Join(words, " ")
+
+		folded := FoldHeader("Subject", value)
+		for _, line := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+			if len(line) > headerMaxLineLength || len(line) > headerLineLength && strings.Contains(strings.TrimSpace(line), " ") {
+				return false
+			}
+		}
+
+		return strings.TrimSpace(UnfoldHeader(folded[len("Subject:"):])) == value
+	}
+
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnfoldHeader(t *testing.T) {
+	if got := UnfoldHeader("a\r\n b\n\tc"); got != "a b\tc" {
+		t.Errorf("Wrong unfolding: %q", got)
+	}
+}
+
+func TestSerializeFoldsHeaders(t *testing.T) {
+	subject := strings.TrimSpace(strings.Repeat("Grüße aus Köln ", 10))
+	e, err := NewEmail().From("jdoe@example.com").Subject(subject).Text("hi").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := Serialize(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, _ := splitHeader(raw)
+	for _, line := range bytes.Split(header, []byte("\r\n")) {
+		if len(line) > headerLineLength {
+			t.Errorf("Header line too long: %q", line)
+		}
+	}
+
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Subject != subject {
+		t.Errorf("Wrong subject. Expected: %q, Got: %q", subject, parsed.Subject)
+	}
+}
@@ -0,0 +1,116 @@
+package parsemail
+
+import (
+	"bytes"
+	"strings"
+)
+
+// forwardPrefixes are the subject prefixes used by mail clients for forwards
+var forwardPrefixes = []string{"fwd:", "fw:"}
+
+// forwardMarkers introduce an inline forwarded message in text bodies
+var forwardMarkers = []string{
+	"---------- Forwarded message ---------",
+	"-------- Forwarded Message --------",
+	"Begin forwarded message:",
+}
+
+// outlookSeparator precedes the quoted message of Outlook replies and
+// forwards alike, so it only introduces a forwarded message in an email
+// marked as forward by its subject or header
+const outlookSeparator = "-----Original Message-----"
+
+// IsForwarded reports whether the email forwards another message, detected by
+// the X-Forwarded-Message-Id header, a "Fwd:" or "FW:" subject, an attached
+// message/rfc822 part or an inline forwarded message marker in the text body.
+// The "-----Original Message-----" separator of Outlook, which replies use
+// too, doesn't mark a forward by itself.
+func (e *Email) IsForwarded() bool {
+	if e.markedForwarded() || e.forwardedAttachment() != nil {
+		return true
+	}
+
+	_, ok := inlineForward(e.TextBody, false)
+
+	return ok
+}
+
+// markedForwarded reports whether the header or the subject of the email
+// mark it as forward
+func (e *Email) markedForwarded() bool {
+	if e.Header.Get("X-Forwarded-Message-Id") != "" {
+		return true
+	}
+
+	subject := strings.ToLower(strings.TrimSpace(e.Subject))
+	for _, prefix := range forwardPrefixes {
+		if strings.HasPrefix(subject, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ForwardedMessage parses the forwarded message, either from an attached
+// message/rfc822 part or from the headers and body following an inline
+// forward marker, with the options the email was parsed with. It returns nil
+// if the message can't be recovered.
+func (e *Email) ForwardedMessage() (*Email, error) {
+	if at := e.forwardedAttachment(); at != nil {
+		if at.ChildEmail != nil {
+			return at.ChildEmail, nil
+		}
+
+		data, err := at.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		fwd, err := e.parseEmbedded(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		return &fwd, nil
+	}
+
+	inline, ok := inlineForward(e.TextBody, e.markedForwarded())
+	if !ok {
+		return nil, nil
+	}
+
+	fwd, err := e.parseEmbedded(strings.NewReader(inline))
+	if err != nil {
+		return nil, err
+	}
+
+	return &fwd, nil
+}
+
+func (e *Email) forwardedAttachment() *Attachment {
+	for i := range e.Attachments {
+		if e.Attachments[i].ContentType == messageRFC822 {
+			return &e.Attachments[i]
+		}
+	}
+
+	return nil
+}
+
+// inlineForward returns the text following an inline forward marker, or
+// with outlook set the Outlook separator
+func inlineForward(body string, outlook bool) (string, bool) {
+	markers := forwardMarkers
+	if outlook {
+		markers = append(markers[:len(markers):len(markers)], outlookSeparator)
+	}
+
+	for _, marker := range markers {
+		if i := strings.Index(body, marker); i >= 0 {
+			return strings.TrimLeft(body[i+len(marker):], " \r\n"), true
+		}
+	}
+
+	return "", false
+}
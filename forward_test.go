@@ -0,0 +1,153 @@
+package parsemail
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestForwardedMessage(t *testing.T) {
+	var testData = map[int]struct {
+		mailData  string
+		forwarded bool
+		subject   string
+		textBody  string
+	}{
+		1: {
+			mailData: rfc5322exampleA11,
+		},
+		2: {
+			mailData:  rfc822,
+			forwarded: true,
+			subject:   "Internal Test",
+			textBody:  "test",
+		},
+		3: {
+			mailData:  forwardInline,
+			forwarded: true,
+			subject:   "Original subject",
+			textBody:  "Original body.",
+		},
+		4: {
+			mailData:  forwardHeader,
+			forwarded: true,
+		},
+		5: {
+			mailData: fmt.Sprintf(forwardOutlook, "RE"),
+		},
+		6: {
+			mailData:  fmt.Sprintf(forwardOutlook, "FW"),
+			forwarded: true,
+			subject:   "Original subject",
+			textBody:  "Original body.",
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if e.IsForwarded() != td.forwarded {
+			t.Errorf("[Test Case %v] Wrong forwarded flag. Expected: %v, Got: %v", index, td.forwarded, e.IsForwarded())
+		}
+
+		fwd, err := e.ForwardedMessage()
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		if td.subject == "" {
+			if fwd != nil {
+				t.Errorf("[Test Case %v] Unexpected forwarded message: %v", index, fwd.Subject)
+			}
+			continue
+		}
+
+		if fwd == nil {
+			t.Errorf("[Test Case %v] Forwarded message not recovered", index)
+			continue
+		}
+
+		if fwd.Subject != td.subject {
+			t.Errorf("[Test Case %v] Wrong subject. Expected: %s, Got: %s", index, td.subject, fwd.Subject)
+		}
+
+		if fwd.TextBody != td.textBody {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, td.textBody, fwd.TextBody)
+		}
+	}
+}
+
+func TestForwardedMessageOptions(t *testing.T) {
+	tagger := NewTagger()
+	tagger.Add(SubjectContains(""), "parsed")
+
+	for index, mailData := range []string{rfc822, forwardInline, journalReport} {
+		e, err := ParseWithOptions(strings.NewReader(mailData), Options{Tagger: tagger})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		fwd, err := e.ForwardedMessage()
+		if err != nil || fwd == nil {
+			t.Fatalf("[Test Case %v] Forwarded message not recovered: %v", index, err)
+		}
+		if !assertSliceEq([]string{"parsed"}, fwd.Tags) {
+			t.Errorf("[Test Case %v] Options not applied to the forwarded message. Got: %s %v", index, fwd.Subject, fwd.Tags)
+		}
+	}
+
+	// strict parses reject a forwarded message with a malformed date
+	e, err := ParseWithOptions(strings.NewReader(strings.Replace(forwardInline, "Date: Fri, 21 Nov 1997 09:55:06 -0600\n", "Date: yesterday\n", 1)), Options{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.ForwardedMessage(); err == nil {
+		t.Error("Expected the strict parse of the forwarded message to fail")
+	}
+}
+
+var forwardInline = `From: Mary Smith <mary@example.net>
+To: Jane Brown <j-brown@other.example>
+Subject: Fwd: Original subject
+Date: Fri, 21 Nov 1997 10:01:10 -0600
+
+See below.
+
+---------- Forwarded message ---------
+From: John Doe <jdoe@machine.example>
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Subject: Original subject
+To: Mary Smith <mary@example.net>
+
+Original body.
+`
+
+var forwardHeader = `From: Mary Smith <mary@example.net>
+To: Jane Brown <j-brown@other.example>
+Subject: Have a look
+Date: Fri, 21 Nov 1997 10:01:10 -0600
+X-Forwarded-Message-Id: <1234@local.machine.example>
+
+See the forwarded message.
+`
+
+var forwardOutlook = `From: Mary Smith <mary@example.net>
+To: Jane Brown <j-brown@other.example>
+Subject: %s: Original subject
+Date: Fri, 21 Nov 1997 10:01:10 -0600
+
+See below.
+
+-----Original Message-----
+From: John Doe <jdoe@machine.example>
+Sent: Friday, November 21, 1997 9:55 AM
+To: Mary Smith <mary@example.net>
+Subject: Original subject
+
+Original body.
+`
@@ -0,0 +1,447 @@
+package parsemail
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"mime"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Defects the Generator introduces into malformed messages
+const (
+	DefectBareLF             = "bare-lf"
+	DefectMissingMIMEVersion = "missing-mime-version"
+	DefectRawHeaderText      = "raw-8bit-header"
+	DefectLongHeaderLine     = "long-header-line"
+	DefectTruncated          = "truncated"
+	DefectMissingCloseDelim  = "missing-close-delimiter"
+	DefectWrongBoundary      = "wrong-boundary"
+	DefectInvalidBase64      = "invalid-base64"
+	DefectUnknownCharset     = "unknown-charset"
+)
+
+var generatorDefects = []string{
+	DefectBareLF, DefectMissingMIMEVersion, DefectRawHeaderText,
+	DefectLongHeaderLine, DefectTruncated, DefectMissingCloseDelim,
+	DefectWrongBoundary, DefectInvalidBase64, DefectUnknownCharset,
+}
+
+// generatorTexts are sample texts for the charsets the Generator uses
+var generatorTexts = map[string][]string{
+	"us-ascii":     {"Hello world", "Meeting notes for Monday", "Re: Re: Fwd: status"},
+	"utf-8":        {"Grüße aus Köln", "Привет, мир", "こんにちは世界", "Ελληνικά και 中文 🙂"},
+	"iso-8859-1":   {"Grüße aus Köln", "Señor García, ¿qué tal?", "Café crème"},
+	"iso-8859-15":  {"Prix: 5 €", "Œuvres complètes"},
+	"windows-1252": {"Preis: 5 € – „Angebot“", "Smart “quotes” and …"},
+	"koi8-r":       {"Привет, мир", "Отчёт за март"},
+	"shift_jis":    {"こんにちは世界", "会議の議事録"},
+	"iso-2022-jp":  {"こんにちは世界", "お知らせ"},
+	"gbk":          {"你好世界", "会议纪要"},
+	"big5":         {"您好世界", "會議記錄"},
+	"euc-kr":       {"안녕하세요", "회의록"},
+}
+
+var generatorNames = []string{
+	"John Doe", "Mary Major", "Jürgen Müller", "Zoë Ångström", "Иван Петров",
+	"山田太郎", "O'Brien, Pat", "support team",
+}
+
+var generatorFiles = []struct {
+	filename    string
+	contentType string
+	magic       string
+}{
+	{"report.pdf", "application/pdf", "%PDF-1.4\n"},
+	{"photo.png", "image/png", "\x89PNG\r\n\x1a\n"},
+	{"archive.zip", "application/zip", "PK\x03\x04"},
+	{"data.bin", "application/octet-stream", ""},
+	{"Übersicht März.pdf", "application/pdf", "%PDF-1.7\n"},
+	{"議事録.docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "PK\x03\x04"},
+}
+
+// Generator produces random messages for fuzzing and load testing systems
+// built on this package. Messages mix charsets, transfer encodings, encoded
+// header fields, multipart nestings, inline images, attachments with non
+// ASCII filenames and attached messages. The same seed produces the same
+// messages.
+type Generator struct {
+	// Malformed is the share of messages, from 0 to 1, that get one or two
+	// defects
+	Malformed float64
+	// MaxDepth limits the nesting of multiparts and attached messages. It
+	// defaults to 3.
+	MaxDepth int
+
+	rand *rand.Rand
+}
+
+// GeneratedMessage is a message of a Generator and the defects it has
+type GeneratedMessage struct {
+	Raw     []byte
+	Defects []string
+}
+
+// NewGenerator returns a Generator of valid messages seeded with seed. The
+// zero Generator can't be used.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{rand: rand.New(rand.NewSource(seed))}
+}
+
+// generation is the state of generating one message
+type generation struct {
+	*Generator
+	defects map[string]bool
+	applied map[string]bool
+}
+
+// Generate returns the next message
+func (g *Generator) Generate() GeneratedMessage {
+	gen := &generation{Generator: g, defects: map[string]bool{}, applied: map[string]bool{}}
+	if g.rand.Float64() < g.Malformed {
+		for n := 1 + g.rand.Intn(2); len(gen.defects) < n; {
+			gen.defects[generatorDefects[g.rand.Intn(len(generatorDefects))]] = true
+		}
+	}
+
+	depth := g.MaxDepth
+	if depth <= 0 {
+		depth = 3
+	}
+
+	raw := gen.message(depth)
+
+	if gen.use(DefectBareLF) {
+		raw = bytes.Replace(raw, []byte("\r\n"), []byte("\n"), -1)
+	}
+	if gen.use(DefectTruncated) {
+		raw = raw[:len(raw)/2+g.rand.Intn(len(raw)/2)]
+	}
+
+	msg := GeneratedMessage{Raw: raw}
+	for _, d := range generatorDefects {
+		if gen.applied[d] {
+			msg.Defects = append(msg.Defects, d)
+		}
+	}
+
+	return msg
+}
+
+// use reports whether the message gets defect, recording it as applied
+func (g *generation) use(defect string) bool {
+	if g.defects[defect] {
+		g.applied[defect] = true
+		return true
+	}
+
+	return false
+}
+
+// message writes a message with a random body of at most depth levels
+func (g *generation) message(depth int) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(FoldHeader("From", g.addresses(1)))
+	buf.WriteString(FoldHeader("To", g.addresses(1+g.rand.Intn(3))))
+	if g.rand.Intn(3) == 0 {
+		buf.WriteString(FoldHeader("Cc", g.addresses(1+g.rand.Intn(2))))
+	}
+
+	charset, text := g.text()
+	if g.use(DefectRawHeaderText) {
+		buf.WriteString("Subject: " + text + "\r\n")
+	} else {
+		buf.WriteString(FoldHeader("Subject", g.encodedWord(charset, text)))
+	}
+
+	date := time.Date(2000+g.rand.Intn(27), time.Month(1+g.rand.Intn(12)), 1+g.rand.Intn(28), g.rand.Intn(24), g.rand.Intn(60), g.rand.Intn(60), 0, time.FixedZone("", (g.rand.Intn(27)-12)*3600))
+	buf.WriteString(FoldHeader("Date", date.Format(time.RFC1123Z)))
+	buf.WriteString(FoldHeader("Message-ID", fmt.Sprintf("<%016x.%d@generator.example>", g.rand.Uint64(), g.rand.Intn(1000))))
+	if g.use(DefectLongHeaderLine) {
+		buf.WriteString("X-Long: " + strings.Repeat("x", 2000+g.rand.Intn(2000)) + "\r\n")
+	}
+	if !g.use(DefectMissingMIMEVersion) {
+		buf.WriteString("MIME-Version: 1.0\r\n")
+	}
+
+	buf.Write(g.entity(depth))
+
+	return buf.Bytes()
+}
+
+// entity writes the header fields and body of a random MIME entity
+func (g *generation) entity(depth int) []byte {
+	if depth <= 1 {
+		return g.textEntity(g.rand.Intn(2) == 0)
+	}
+
+	switch g.rand.Intn(6) {
+	case 0:
+		return g.textEntity(false)
+	case 1:
+		return g.textEntity(true)
+	case 2:
+		return g.multipart("alternative", g.textEntity(false), g.textEntity(true))
+	case 3:
+		return g.related()
+	default:
+		parts := [][]byte{g.entity(depth - 1)}
+		for n := 1 + g.rand.Intn(3); n > 0; n-- {
+			if depth > 2 && g.rand.Intn(4) == 0 {
+				parts = append(parts, g.attachedMessage(depth-1))
+			} else {
+				parts = append(parts, g.attachment())
+			}
+		}
+		return g.multipart("mixed", parts...)
+	}
+}
+
+// textEntity writes a text/plain or text/html part in a random charset and
+// transfer encoding
+func (g *generation) textEntity(html bool) []byte {
+	charset, text := g.text()
+	lines := []string{text}
+	for n := g.rand.Intn(6); n > 0; n-- {
+		texts := generatorTexts[charset]
+		lines = append(lines, texts[g.rand.Intn(len(texts))])
+	}
+	if g.rand.Intn(4) == 0 {
+		// a line longer than 998 characters needs an encoding
+		lines = append(lines, strings.Repeat(text+" ", 1000/len(text)+1))
+	}
+
+	body := strings.Join(lines, "\r\n") + "\r\n"
+	contentType := "text/plain"
+	if html {
+		contentType = "text/html"
+		body = "<html><body><p>" + strings.Join(lines, "</p>\r\n<p>") + "</p></body></html>\r\n"
+	}
+
+	label := charset
+	if g.use(DefectUnknownCharset) {
+		label = "x-unknown-charset"
+	}
+
+	header := fmt.Sprintf("Content-Type: %s; charset=%s\r\n", contentType, label)
+	return g.encoded(header, g.encode(charset, body), true)
+}
+
+// encoded writes a header and a body with a random transfer encoding that
+// can carry it
+func (g *generation) encoded(header string, body []byte, text bool) []byte {
+	ascii, longLines := true, false
+	for _, line := range bytes.Split(body, []byte("\r\n")) {
+		longLines = longLines || len(line) > 998
+		for _, c := range line {
+			ascii = ascii && c < 0x80 && c != 0 && c != '\r' && c != '\n'
+		}
+	}
+
+	var encodings []string
+	if text && !longLines {
+		if ascii {
+			encodings = append(encodings, "7bit")
+		}
+		encodings = append(encodings, "8bit")
+	}
+	if text {
+		encodings = append(encodings, "quoted-printable")
+	}
+	encodings = append(encodings, "base64")
+
+	encoding := encodings[g.rand.Intn(len(encodings))]
+	if !text {
+		encoding = "base64"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("Content-Transfer-Encoding: " + encoding + "\r\n\r\n")
+
+	switch encoding {
+	case "quoted-printable":
+		buf.Write(encodeQuotedPrintable(string(body)))
+		buf.WriteString("\r\n")
+	case "base64":
+		start := buf.Len()
+		writeBase64Lines(&buf, body)
+		if len(body) > 0 && g.use(DefectInvalidBase64) {
+			// corrupt the middle of the first line
+			b := buf.Bytes()[start:]
+			i := bytes.IndexByte(b, '\r') / 2
+			b[i] = '!'
+		}
+	default:
+		buf.Write(body)
+	}
+
+	return buf.Bytes()
+}
+
+// related writes an html body referring to an inline image
+func (g *generation) related() []byte {
+	cid := fmt.Sprintf("%08x@generator.example", g.rand.Uint32())
+
+	html := []byte("<html><body><p>Logo:</p><img src=\"cid:" + cid + "\"></body></html>\r\n")
+	body := g.encoded("Content-Type: text/html; charset=us-ascii\r\n", html, true)
+
+	image := g.encoded("Content-Type: image/png\r\nContent-ID: <"+cid+">\r\nContent-Disposition: inline\r\n", g.data("\x89PNG\r\n\x1a\n"), false)
+
+	return g.multipart("related", body, image)
+}
+
+// attachment writes a file attachment with a random filename
+func (g *generation) attachment() []byte {
+	f := generatorFiles[g.rand.Intn(len(generatorFiles))]
+
+	// non-ASCII filenames are written per RFC 2231 by FormatMediaType or as
+	// the encoded-words many clients use
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": f.filename})
+	if !isASCII(f.filename) && g.rand.Intn(2) == 0 {
+		disposition = `attachment; filename="` + mime.BEncoding.Encode("utf-8", f.filename) + `"`
+	}
+
+	header := FoldHeader("Content-Type", f.contentType) + FoldHeader("Content-Disposition", disposition)
+
+	return g.encoded(header, g.data(f.magic), false)
+}
+
+// attachedMessage writes a message/rfc822 part
+func (g *generation) attachedMessage(depth int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("Content-Type: message/rfc822\r\n")
+	if g.rand.Intn(2) == 0 {
+		buf.WriteString("Content-Disposition: attachment; filename=\"forwarded.eml\"\r\n")
+	}
+	buf.WriteString("\r\n")
+	buf.Write(g.message(depth))
+
+	return buf.Bytes()
+}
+
+// multipart writes a multipart entity of the parts
+func (g *generation) multipart(subtype string, parts ...[]byte) []byte {
+	boundary := fmt.Sprintf("=_%x_%d", g.rand.Uint64(), g.rand.Intn(100))
+
+	var buf bytes.Buffer
+	declared := boundary
+	if g.use(DefectWrongBoundary) {
+		declared = "wrong" + boundary
+	}
+	buf.WriteString(FoldHeader("Content-Type", mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": declared})))
+	buf.WriteString("\r\n")
+	if g.rand.Intn(2) == 0 {
+		buf.WriteString("This is a multi-part message in MIME format.\r\n")
+	}
+
+	for _, p := range parts {
+		buf.WriteString("\r\n--" + boundary + "\r\n")
+		buf.Write(bytes.TrimSuffix(p, []byte("\r\n")))
+	}
+	if !g.use(DefectMissingCloseDelim) {
+		buf.WriteString("\r\n--" + boundary + "--\r\n")
+	}
+
+	return buf.Bytes()
+}
+
+// text returns a random charset and a text in it, encoded in UTF-8
+func (g *generation) text() (string, string) {
+	charsets := make([]string, 0, len(generatorTexts))
+	for c := range generatorTexts {
+		charsets = append(charsets, c)
+	}
+	// map order is random, the generator must be deterministic
+	sort.Strings(charsets)
+
+	charset := charsets[g.rand.Intn(len(charsets))]
+	texts := generatorTexts[charset]
+
+	return charset, texts[g.rand.Intn(len(texts))]
+}
+
+// encode encodes text in charset
+func (g *generation) encode(charset, text string) []byte {
+	if charset == "utf-8" || charset == "us-ascii" {
+		return []byte(text)
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return []byte(text)
+	}
+	encoded, err := enc.NewEncoder().String(text)
+	if err != nil {
+		return []byte(text)
+	}
+
+	return []byte(encoded)
+}
+
+// encodedWord returns text as an encoded-word in charset, or as is if it is
+// ASCII and the generator chooses so
+func (g *generation) encodedWord(charset, text string) string {
+	if isASCII(text) && g.rand.Intn(2) == 0 {
+		return text
+	}
+
+	// Q encoding may split the characters of other charsets between
+	// encoded-words, the short B encoded texts fit into one
+	encoded := string(g.encode(charset, text))
+	if (charset == "utf-8" || isASCII(encoded)) && g.rand.Intn(2) == 0 {
+		return mime.QEncoding.Encode(charset, encoded)
+	}
+
+	return mime.BEncoding.Encode(charset, encoded)
+}
+
+// addresses returns n random addresses
+func (g *generation) addresses(n int) string {
+	list := make([]string, n)
+	for i := range list {
+		name := generatorNames[g.rand.Intn(len(generatorNames))]
+		local := strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' {
+				return r
+			}
+			return -1
+		}, strings.ToLower(strings.Fields(name)[0]))
+		if local == "" {
+			local = "user"
+		}
+		address := fmt.Sprintf("%s%d@%s.example", local, g.rand.Intn(100), []string{"mail", "corp", "lists"}[g.rand.Intn(3)])
+
+		if g.rand.Intn(4) == 0 {
+			list[i] = address
+		} else {
+			list[i] = formatAddress(&mail.Address{Name: name, Address: address})
+		}
+	}
+
+	return strings.Join(list, ", ")
+}
+
+// data returns random data of up to 8 KiB starting with magic
+func (g *generation) data(magic string) []byte {
+	b := make([]byte, g.rand.Intn(8<<10))
+	g.rand.Read(b)
+
+	return append([]byte(magic), b...)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
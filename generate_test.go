@@ -0,0 +1,72 @@
+package parsemail
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerator(t *testing.T) {
+	subjects := map[string]bool{}
+	for _, texts := range generatorTexts {
+		for _, text := range texts {
+			subjects[text] = true
+		}
+	}
+
+	var testData = map[int]struct {
+		malformed float64
+		maxDepth  int
+	}{
+		1: {malformed: 0, maxDepth: 3},
+		2: {malformed: 0, maxDepth: 1},
+		3: {malformed: 1, maxDepth: 4},
+	}
+
+	for index, td := range testData {
+		g := NewGenerator(int64(index))
+		g.Malformed, g.MaxDepth = td.malformed, td.maxDepth
+
+		again := NewGenerator(int64(index))
+		again.Malformed, again.MaxDepth = td.malformed, td.maxDepth
+
+		attachments, defective := 0, 0
+		for i := 0; i < 200; i++ {
+			msg := g.Generate()
+			if other := again.Generate(); !bytes.Equal(msg.Raw, other.Raw) {
+				t.Fatalf("[Test Case %v] Expected the same message for the same seed", index)
+			}
+
+			// malformed messages must not break the parser, errors are fine
+			e, err := Parse(bytes.NewReader(msg.Raw))
+			if len(msg.Defects) > 0 {
+				defective++
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("[Test Case %v] Message %d doesn't parse: %v\n%s", index, i, err, msg.Raw)
+				continue
+			}
+			if e.TextBody == "" && e.HTMLBody == "" {
+				t.Errorf("[Test Case %v] Message %d has no body:\n%s", index, i, msg.Raw)
+			}
+			if len(e.From) != 1 || len(e.To) == 0 || e.MessageID == "" {
+				t.Errorf("[Test Case %v] Message %d has wrong headers: %v %v %q", index, i, e.From, e.To, e.MessageID)
+			}
+			if !subjects[e.Subject] {
+				t.Errorf("[Test Case %v] Message %d has a wrongly decoded subject: %q\n%s", index, i, e.Subject, msg.Raw)
+			}
+			attachments += len(e.Attachments)
+		}
+
+		if td.malformed == 0 && defective > 0 {
+			t.Errorf("[Test Case %v] Expected no defects, got %d defective messages", index, defective)
+		}
+		if td.malformed == 1 && defective < 150 {
+			t.Errorf("[Test Case %v] Expected mostly defective messages, got %d", index, defective)
+		}
+		if td.maxDepth == 3 && attachments == 0 {
+			t.Errorf("[Test Case %v] Expected attachments", index)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package parsemail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// GmailMessage is a message resource of the Gmail API, with the fields of
+// the Message of google.golang.org/api/gmail/v1. A message fetched in the
+// "raw" format has Raw, one fetched in the "full" format Payload.
+type GmailMessage struct {
+	ID           string            `json:"id,omitempty"`
+	ThreadID     string            `json:"threadId,omitempty"`
+	LabelIDs     []string          `json:"labelIds,omitempty"`
+	Snippet      string            `json:"snippet,omitempty"`
+	HistoryID    string            `json:"historyId,omitempty"`
+	InternalDate int64             `json:"internalDate,string,omitempty"`
+	SizeEstimate int               `json:"sizeEstimate,omitempty"`
+	Raw          string            `json:"raw,omitempty"`
+	Payload      *GmailMessagePart `json:"payload,omitempty"`
+}
+
+// GmailMessagePart is a part of the payload of a Gmail message. The
+// headers of the payload are the header fields of the message.
+type GmailMessagePart struct {
+	PartID   string               `json:"partId"`
+	MimeType string               `json:"mimeType"`
+	Filename string               `json:"filename"`
+	Headers  []GmailHeader        `json:"headers,omitempty"`
+	Body     GmailMessagePartBody `json:"body"`
+	Parts    []*GmailMessagePart  `json:"parts,omitempty"`
+}
+
+// GmailHeader is a header field of a message part, unfolded but not decoded
+type GmailHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// GmailMessagePartBody is the content of a part, without its transfer
+// encoding and base64url encoded in Data. The content of large parts is
+// left out and fetched by AttachmentID.
+type GmailMessagePartBody struct {
+	AttachmentID string `json:"attachmentId,omitempty"`
+	Size         int    `json:"size"`
+	Data         string `json:"data,omitempty"`
+}
+
+// GmailAttachmentFetcher returns the body of the attachment with the given
+// id, i.e. the result of users.messages.attachments.get
+type GmailAttachmentFetcher func(attachmentID string) (GmailMessagePartBody, error)
+
+// ParseGmailMessage builds an email from a message of the Gmail API, from
+// Raw if it is set and otherwise by reassembling the MIME structure of
+// Payload. fetch is called for the parts whose content was left out of the
+// payload, and may be nil if there are none. The id, labels and other
+// metadata of the message are not part of the email.
+func ParseGmailMessage(m *GmailMessage, fetch GmailAttachmentFetcher, opts Options) (Email, error) {
+	if m.Raw != "" {
+		raw, err := decodeGmailData(m.Raw)
+		if err != nil {
+			return Email{}, fmt.Errorf("gmail: raw: %v", err)
+		}
+
+		return ParseWithOptions(bytes.NewReader(raw), opts)
+	}
+
+	if m.Payload == nil {
+		return Email{}, errors.New("gmail: message without raw or payload")
+	}
+
+	part, err := gmailMIMEPart(m.Payload, fetch)
+	if err != nil {
+		return Email{}, err
+	}
+
+	fields := make([]HeaderField, 0, len(m.Payload.Headers))
+	for _, h := range m.Payload.Headers {
+		fields = append(fields, HeaderField{
+			Name: textproto.CanonicalMIMEHeaderKey(h.Name),
+			Raw:  []byte(FoldHeader(h.Name, h.Value)),
+		})
+	}
+
+	raw, err := assembleMessage(fields, part)
+	if err != nil {
+		return Email{}, err
+	}
+
+	return ParseWithOptions(bytes.NewReader(raw), opts)
+}
+
+// gmailMIMEPart converts a part of a Gmail payload and the parts nested in
+// it. Only the Content fields of the headers are kept, and the content is
+// encoded anew.
+func gmailMIMEPart(gp *GmailMessagePart, fetch GmailAttachmentFetcher) (mimePart, error) {
+	header := textproto.MIMEHeader{}
+	for _, h := range gp.Headers {
+		if k := textproto.CanonicalMIMEHeaderKey(h.Name); strings.HasPrefix(k, "Content-") && k != "Content-Transfer-Encoding" {
+			header.Add(k, h.Value)
+		}
+	}
+
+	mimeType := strings.ToLower(gp.MimeType)
+	if header.Get("Content-Type") == "" && mimeType != "" {
+		header.Set("Content-Type", mimeType)
+	}
+
+	if strings.HasPrefix(mimeType, "multipart/") {
+		var parts []mimePart
+		for _, child := range gp.Parts {
+			p, err := gmailMIMEPart(child, fetch)
+			if err != nil {
+				return mimePart{}, err
+			}
+			parts = append(parts, p)
+		}
+
+		// the parameters of the content type are kept but the boundary
+		mp := multipartPart(strings.TrimPrefix(mimeType, "multipart/"), parts)
+		_, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+		_, mpParams, _ := mime.ParseMediaType(mp.header.Get("Content-Type"))
+		if params == nil {
+			params = map[string]string{}
+		}
+		params["boundary"] = mpParams["boundary"]
+		header.Set("Content-Type", mime.FormatMediaType(mimeType, params))
+		mp.header = header
+
+		return mp, nil
+	}
+
+	data, err := gp.Body.content(fetch)
+	if err != nil {
+		return mimePart{}, err
+	}
+
+	// message/rfc822 parts can't be base64 encoded
+	if mimeType == messageRFC822 {
+		header.Set("Content-Transfer-Encoding", "8bit")
+
+		return mimePart{header: header, content: data, write: func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		}}, nil
+	}
+
+	return filePart(header, data), nil
+}
+
+// content returns the decoded content of the body, fetching it if it was
+// left out
+func (b GmailMessagePartBody) content(fetch GmailAttachmentFetcher) ([]byte, error) {
+	if b.Data == "" && b.AttachmentID != "" {
+		if fetch == nil {
+			return nil, fmt.Errorf("gmail: no fetcher for attachment %s", b.AttachmentID)
+		}
+
+		fetched, err := fetch(b.AttachmentID)
+		if err != nil {
+			return nil, err
+		}
+		b = fetched
+	}
+
+	data, err := decodeGmailData(b.Data)
+	if err != nil {
+		return nil, fmt.Errorf("gmail: body: %v", err)
+	}
+
+	return data, nil
+}
+
+// decodeGmailData decodes base64url data with or without padding
+func decodeGmailData(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// GmailMessage converts the email to a message of the Gmail API, with Raw
+// set for users.messages.send and insert and Payload describing it like a
+// message fetched in the "full" format. Bcc recipients are not written,
+// like with Serialize.
+func (e *Email) GmailMessage() (*GmailMessage, error) {
+	body, err := e.mimeBody()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := serialize(e, body)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := gmailPayloadPart(body, "")
+	header, _ := splitHeader(raw)
+	payload.Headers = nil
+	for _, f := range splitHeaderFields(header) {
+		i := bytes.IndexByte(f.Raw, ':')
+		if i < 0 {
+			continue
+		}
+		payload.Headers = append(payload.Headers, GmailHeader{
+			Name:  string(bytes.TrimSpace(f.Raw[:i])),
+			Value: f.Value(),
+		})
+	}
+
+	return &GmailMessage{
+		SizeEstimate: len(raw),
+		Raw:          base64.URLEncoding.EncodeToString(raw),
+		Payload:      payload,
+	}, nil
+}
+
+// gmailPayloadPart describes a part of the serialized email and the parts
+// nested in it, numbered like Gmail does
+func gmailPayloadPart(p mimePart, partID string) *GmailMessagePart {
+	mimeType, _, _ := mime.ParseMediaType(p.header.Get("Content-Type"))
+
+	gp := &GmailMessagePart{
+		PartID:   partID,
+		MimeType: mimeType,
+		Filename: decodeMimeSentence(partFilename(p.header, SchemaVersion)),
+	}
+	for _, k := range sortedKeys(p.header) {
+		for _, v := range p.header[k] {
+			gp.Headers = append(gp.Headers, GmailHeader{Name: k, Value: v})
+		}
+	}
+
+	if p.parts == nil {
+		gp.Body = GmailMessagePartBody{
+			Size: len(p.content),
+			Data: base64.URLEncoding.EncodeToString(p.content),
+		}
+
+		return gp
+	}
+
+	for i, child := range p.parts {
+		childID := strconv.Itoa(i)
+		if partID != "" {
+			childID = partID + "." + childID
+		}
+		gp.Parts = append(gp.Parts, gmailPayloadPart(child, childID))
+	}
+
+	return gp
+}
@@ -0,0 +1,185 @@
+package parsemail
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// gmailData encodes s like the Gmail API does
+func gmailData(s string) string {
+	return base64.URLEncoding.EncodeToString([]byte(s))
+}
+
+func TestParseGmailMessage(t *testing.T) {
+	data := `{
+		"id": "18c1", "threadId": "18c0", "labelIds": ["INBOX", "UNREAD"], "internalDate": "1700000000000",
+		"payload": {
+			"partId": "", "mimeType": "multipart/mixed", "filename": "",
+			"headers": [
+				{"name": "From", "value": "Alice <alice@example.org>"},
+				{"name": "To", "value": "bob@example.net"},
+				{"name": "Subject", "value": "=?utf-8?q?R=C3=A9sum=C3=A9?="},
+				{"name": "Content-Type", "value": "multipart/mixed; boundary=\"b\""}
+			],
+			"body": {"size": 0},
+			"parts": [
+				{"partId": "0", "mimeType": "multipart/alternative", "filename": "",
+					"headers": [{"name": "Content-Type", "value": "multipart/alternative; boundary=\"c\""}],
+					"body": {"size": 0},
+					"parts": [
+						{"partId": "0.0", "mimeType": "text/plain", "filename": "",
+							"headers": [
+								{"name": "Content-Type", "value": "text/plain; charset=\"iso-8859-1\""},
+								{"name": "Content-Transfer-Encoding", "value": "quoted-printable"}
+							],
+							"body": {"size": 5, "data": "` + gmailData("Caf\xe9\r\n") + `"}},
+						{"partId": "0.1", "mimeType": "text/html", "filename": "",
+							"headers": [{"name": "Content-Type", "value": "text/html; charset=\"UTF-8\""}],
+							"body": {"size": 12, "data": "` + gmailData("<p>Café</p>") + `"}}
+					]},
+				{"partId": "1", "mimeType": "application/pdf", "filename": "report.pdf",
+					"headers": [
+						{"name": "Content-Type", "value": "application/pdf; name=\"report.pdf\""},
+						{"name": "Content-Disposition", "value": "attachment; filename=\"report.pdf\""},
+						{"name": "Content-Transfer-Encoding", "value": "base64"}
+					],
+					"body": {"attachmentId": "ANGjdJ8", "size": 8}}
+			]
+		}
+	}`
+
+	var m GmailMessage
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	var fetched []string
+	fetch := func(id string) (GmailMessagePartBody, error) {
+		fetched = append(fetched, id)
+		if id != "ANGjdJ8" {
+			return GmailMessagePartBody{}, fmt.Errorf("no attachment %s", id)
+		}
+		return GmailMessagePartBody{Size: 8, Data: gmailData("%PDF-1.4")}, nil
+	}
+
+	e, err := ParseGmailMessage(&m, fetch, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Subject != "Résumé" || len(e.From) != 1 || e.From[0].Address != "alice@example.org" {
+		t.Errorf("Wrong header. Got: %s, %v", e.Subject, e.From)
+	}
+	if strings.TrimSpace(e.TextBody) != "Café" {
+		t.Errorf("Wrong text body. Expected: %s, Got: %q", "Café", e.TextBody)
+	}
+	if strings.TrimSpace(e.HTMLBody) != "<p>Café</p>" {
+		t.Errorf("Wrong html body. Expected: %s, Got: %q", "<p>Café</p>", e.HTMLBody)
+	}
+	if !assertSliceEq([]string{"ANGjdJ8"}, fetched) {
+		t.Errorf("Wrong attachments fetched. Got: %v", fetched)
+	}
+
+	if len(e.Attachments) != 1 || e.Attachments[0].Filename != "report.pdf" || e.Attachments[0].ContentType != "application/pdf" {
+		t.Fatalf("Wrong attachments. Got: %+v", e.Attachments)
+	}
+	content, _ := ioutil.ReadAll(e.Attachments[0].Data)
+	if string(content) != "%PDF-1.4" {
+		t.Errorf("Wrong attachment data. Expected: %s, Got: %q", "%PDF-1.4", content)
+	}
+
+	if _, err := ParseGmailMessage(&m, nil, Options{}); err == nil {
+		t.Error("Expected an error for an attachment without fetcher")
+	}
+}
+
+func TestParseGmailMessageRaw(t *testing.T) {
+	raw := "From: alice@example.org\r\nSubject: Raw\r\n\r\nHello\r\n"
+
+	// the API leaves out the padding in some responses
+	for _, enc := range []*base64.Encoding{base64.URLEncoding, base64.RawURLEncoding} {
+		e, err := ParseGmailMessage(&GmailMessage{Raw: enc.EncodeToString([]byte(raw))}, nil, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Subject != "Raw" || strings.TrimSpace(e.TextBody) != "Hello" {
+			t.Errorf("Wrong email. Got: %s, %q", e.Subject, e.TextBody)
+		}
+	}
+
+	if _, err := ParseGmailMessage(&GmailMessage{ID: "18c1"}, nil, Options{}); err == nil {
+		t.Error("Expected an error for a message without content")
+	}
+}
+
+func TestEmailGmailMessage(t *testing.T) {
+	e, err := Parse(strings.NewReader(roundTripMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := e.GmailMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Payload.MimeType != "multipart/mixed" || len(m.Payload.Parts) != 2 {
+		t.Fatalf("Wrong payload. Got: %s with %d parts", m.Payload.MimeType, len(m.Payload.Parts))
+	}
+	if at := m.Payload.Parts[1]; at.PartID != "1" || at.Filename != "report.pdf" || at.Body.Data != gmailData("%PDF-1.4") {
+		t.Errorf("Wrong attachment part. Got: %+v", at)
+	}
+
+	var subject string
+	for _, h := range m.Payload.Headers {
+		if h.Name == "Subject" {
+			subject = h.Value
+		}
+	}
+	if !strings.HasPrefix(subject, "=?utf-8?") || decodeMimeSentence(subject) != "Résumé" {
+		t.Errorf("Wrong subject header. Got: %q", subject)
+	}
+
+	// the payload and the raw message parse like the original
+	raw := *m
+	raw.Payload = nil
+	payload := *m
+	payload.Raw = ""
+
+	for _, gm := range []*GmailMessage{&raw, &payload} {
+		parsed, err := ParseGmailMessage(gm, nil, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.Subject != e.Subject || parsed.TextBody != e.TextBody || parsed.HTMLBody != e.HTMLBody {
+			t.Errorf("Wrong bodies. Expected: %q, %q, Got: %q, %q", e.TextBody, e.HTMLBody, parsed.TextBody, parsed.HTMLBody)
+		}
+		if len(parsed.Attachments) != 1 || parsed.Attachments[0].Filename != "report.pdf" {
+			t.Errorf("Wrong attachments. Got: %+v", parsed.Attachments)
+		}
+		if len(parsed.EmbeddedFiles) != 1 || parsed.EmbeddedFiles[0].CID != "logo" {
+			t.Errorf("Wrong embedded files. Got: %+v", parsed.EmbeddedFiles)
+		}
+	}
+}
+
+// roundTripMessage has bodies, an embedded file and an attachment
+const roundTripMessage = "From: Alice <alice@example.org>\r\n" +
+	"To: Bob <bob@example.net>\r\n" +
+	"Subject: =?utf-8?q?R=C3=A9sum=C3=A9?=\r\n" +
+	"Message-ID: <1@example.org>\r\n" +
+	"X-Priority: 1\r\n" +
+	"Content-Type: multipart/mixed; boundary=m\r\n\r\n" +
+	"--m\r\nContent-Type: multipart/alternative; boundary=a\r\n\r\n" +
+	"--a\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nHello\r\n" +
+	"--a\r\nContent-Type: multipart/related; boundary=r\r\n\r\n" +
+	"--r\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<p>Hello <img src=\"cid:logo\"></p>\r\n" +
+	"--r\r\nContent-Type: image/png\r\nContent-ID: <logo>\r\nContent-Transfer-Encoding: base64\r\n\r\niVBORw0KGgo=\r\n" +
+	"--r--\r\n--a--\r\n" +
+	"--m\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=report.pdf\r\n" +
+	"Content-Transfer-Encoding: base64\r\n\r\nJVBERi0xLjQ=\r\n--m--\r\n"
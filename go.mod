@@ -1,5 +1,8 @@
 module github.com/patrick-othmer/parsemail
 
-go 1.12
+go 1.13
 
-require golang.org/x/net v0.0.0-20200927032502-5d4f70055728
+require (
+	golang.org/x/net v0.0.0-20200927032502-5d4f70055728
+	golang.org/x/text v0.3.0
+)
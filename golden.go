@@ -0,0 +1,273 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Snapshot is the part of a parse result a golden corpus compares: the
+// address fields as "Name <address>" without encoding, the date in RFC 3339
+// form, the bodies with LF line breaks and the files with their size and
+// SHA-256 digest. Error is set instead when parsing failed.
+type Snapshot struct {
+	Error string `json:",omitempty"`
+
+	Subject    string
+	From       []string
+	To         []string
+	Cc         []string
+	Date       string
+	MessageID  string
+	InReplyTo  []string
+	References []string
+
+	TextBody string
+	HTMLBody string
+
+	Attachments   []SnapshotFile
+	EmbeddedFiles []SnapshotFile
+
+	Warnings []string `json:",omitempty"`
+}
+
+// SnapshotFile describes an attachment or embedded file of a Snapshot
+type SnapshotFile struct {
+	Filename    string
+	CID         string `json:",omitempty"`
+	ContentType string
+	Size        int
+	SHA256      string
+}
+
+// NewSnapshot returns the snapshot of a parse result. The data of the
+// attachments and embedded files is read and rewound.
+func NewSnapshot(e *Email, err error) Snapshot {
+	if err != nil {
+		return Snapshot{Error: err.Error()}
+	}
+
+	s := Snapshot{
+		Subject:    e.Subject,
+		From:       snapshotAddresses(e.From),
+		To:         snapshotAddresses(e.To),
+		Cc:         snapshotAddresses(e.Cc),
+		MessageID:  e.MessageID,
+		InReplyTo:  e.InReplyTo,
+		References: e.References,
+		TextBody:   strings.Replace(e.TextBody, "\r\n", "\n", -1),
+		HTMLBody:   strings.Replace(e.HTMLBody, "\r\n", "\n", -1),
+	}
+	if !e.Date.IsZero() {
+		s.Date = e.Date.Format(time.RFC3339)
+	}
+
+	for i := range e.Attachments {
+		a := &e.Attachments[i]
+		data, err := a.bytes()
+		s.Attachments = append(s.Attachments, snapshotFile(a.Filename, "", a.ContentType, data, err))
+	}
+	for i := range e.EmbeddedFiles {
+		ef := &e.EmbeddedFiles[i]
+		data, err := ef.bytes()
+		s.EmbeddedFiles = append(s.EmbeddedFiles, snapshotFile(ef.Filename, ef.CID, ef.ContentType, data, err))
+	}
+
+	for _, w := range e.Warnings {
+		s.Warnings = append(s.Warnings, string(w.Code))
+	}
+
+	return s
+}
+
+func snapshotAddresses(list []*mail.Address) (addresses []string) {
+	for _, a := range list {
+		switch {
+		case a == nil:
+		case a.Name == "":
+			addresses = append(addresses, "<"+a.Address+">")
+		default:
+			addresses = append(addresses, a.Name+" <"+a.Address+">")
+		}
+	}
+
+	return
+}
+
+func snapshotFile(filename, cid, contentType string, data []byte, err error) SnapshotFile {
+	f := SnapshotFile{Filename: filename, CID: cid, ContentType: contentType, Size: len(data)}
+	if err != nil {
+		f.SHA256 = "error: " + err.Error()
+		return f
+	}
+
+	sum := sha256.Sum256(data)
+	f.SHA256 = hex.EncodeToString(sum[:])
+
+	return f
+}
+
+// Diff describes the differences of s from the expected snapshot, one line
+// per field. Bodies are compared line by line and report the first
+// differing line. It is empty when the snapshots are equal.
+func (s Snapshot) Diff(expected Snapshot) (diffs []string) {
+	got, want := reflect.ValueOf(s), reflect.ValueOf(expected)
+	for i := 0; i < got.NumField(); i++ {
+		name := got.Type().Field(i).Name
+		g, w := got.Field(i).Interface(), want.Field(i).Interface()
+
+		switch gv := g.(type) {
+		case string:
+			if d := diffText(w.(string), gv); d != "" {
+				diffs = append(diffs, name+": "+d)
+			}
+		default:
+			gl, wl := got.Field(i), want.Field(i)
+			if gl.Len() != wl.Len() {
+				diffs = append(diffs, fmt.Sprintf("%s: expected %d entries, got %d: %v", name, wl.Len(), gl.Len(), g))
+				continue
+			}
+			for j := 0; j < gl.Len(); j++ {
+				if !reflect.DeepEqual(gl.Index(j).Interface(), wl.Index(j).Interface()) {
+					diffs = append(diffs, fmt.Sprintf("%s[%d]: expected %+v, got %+v", name, j, wl.Index(j).Interface(), gl.Index(j).Interface()))
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// diffText describes the first differing line of two texts
+func diffText(want, got string) string {
+	if want == got {
+		return ""
+	}
+
+	wl, gl := strings.Split(want, "\n"), strings.Split(got, "\n")
+	if len(wl) == 1 && len(gl) == 1 {
+		return fmt.Sprintf("expected %q, got %q", want, got)
+	}
+
+	for i := 0; i < len(wl) || i < len(gl); i++ {
+		switch {
+		case i >= len(gl):
+			return fmt.Sprintf("line %d: missing %q", i+1, wl[i])
+		case i >= len(wl):
+			return fmt.Sprintf("line %d: unexpected %q", i+1, gl[i])
+		case wl[i] != gl[i]:
+			return fmt.Sprintf("line %d: expected %q, got %q", i+1, wl[i], gl[i])
+		}
+	}
+
+	return ""
+}
+
+// GoldenCorpus checks a directory of messages against JSON snapshots of
+// their expected parse results, so users of this package can keep their own
+// regression corpora. The snapshot of "name.eml" is "name.json" in the same
+// directory.
+type GoldenCorpus struct {
+	Options Options
+	// Update writes the snapshots of missing or differing messages instead
+	// of reporting them
+	Update bool
+}
+
+// GoldenResult is the outcome of checking one message
+type GoldenResult struct {
+	Path string
+	// Diffs describes the differences from the snapshot, see Snapshot.Diff
+	Diffs []string
+	// Missing is set when the message has no snapshot
+	Missing bool
+	// Updated is set when the snapshot was written
+	Updated bool
+}
+
+// Failed reports whether the message doesn't match its snapshot
+func (r GoldenResult) Failed() bool {
+	return !r.Updated && (r.Missing || len(r.Diffs) > 0)
+}
+
+// CheckDir parses all .eml files below root, in lexical order, and compares
+// them with their snapshots. A result is returned for every message; the
+// error is only set when walking root, reading a message or a snapshot or
+// writing a snapshot fails.
+func (g *GoldenCorpus) CheckDir(root string) (results []GoldenResult, err error) {
+	var paths []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && strings.EqualFold(filepath.Ext(path), ".eml") {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		r, err := g.check(path)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// check compares a message with its snapshot
+func (g *GoldenCorpus) check(path string) (GoldenResult, error) {
+	r := GoldenResult{Path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+
+	e, perr := ParseWithOptions(bytes.NewReader(data), g.Options)
+	got := NewSnapshot(&e, perr)
+
+	snapshotPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+	expected, err := ioutil.ReadFile(snapshotPath)
+	switch {
+	case os.IsNotExist(err):
+		r.Missing = true
+	case err != nil:
+		return r, err
+	default:
+		var want Snapshot
+		if err := json.Unmarshal(expected, &want); err != nil {
+			return r, fmt.Errorf("%s: %v", snapshotPath, err)
+		}
+		r.Diffs = got.Diff(want)
+	}
+
+	if g.Update && (r.Missing || len(r.Diffs) > 0) {
+		b, err := json.MarshalIndent(got, "", "  ")
+		if err != nil {
+			return r, err
+		}
+		if err := ioutil.WriteFile(snapshotPath, append(b, '\n'), 0644); err != nil {
+			return r, err
+		}
+		r.Updated = true
+	}
+
+	return r, nil
+}
@@ -0,0 +1,121 @@
+package parsemail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoldenCorpus(t *testing.T) {
+	root, err := ioutil.TempDir("", "parsemail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for name, content := range convertFiles {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := GoldenCorpus{}
+	results, err := g.CheckDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Wrong number of results. Expected: 3, Got: %v", len(results))
+	}
+	for _, r := range results {
+		if !r.Missing || !r.Failed() {
+			t.Errorf("Expected %s to miss its snapshot. Got: %+v", r.Path, r)
+		}
+	}
+
+	g.Update = true
+	if results, err = g.CheckDir(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if !r.Updated || r.Failed() {
+			t.Errorf("Expected the snapshot of %s to be written. Got: %+v", r.Path, r)
+		}
+	}
+
+	snapshot, err := ioutil.ReadFile(filepath.Join(root, "a", "b", "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(snapshot), `"SHA256": "`) {
+		t.Errorf("Wrong snapshot:\n%s", snapshot)
+	}
+	broken, err := ioutil.ReadFile(filepath.Join(root, "a", "broken.json"))
+	if err != nil || !strings.Contains(string(broken), `"Error": "`) {
+		t.Errorf("Expected the parse error in the snapshot. Got:\n%s", broken)
+	}
+
+	// a parser change shows up as a diff
+	g.Update = false
+	changed := strings.Replace(string(snapshot), "\"Subject\": \"", "\"Subject\": \"Old ", 1)
+	if err := ioutil.WriteFile(filepath.Join(root, "a", "b", "report.json"), []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if results, err = g.CheckDir(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		failed := filepath.Base(r.Path) == "report.eml"
+		if r.Failed() != failed {
+			t.Errorf("Wrong result of %s. Got: %+v", r.Path, r)
+		}
+		if failed && (len(r.Diffs) != 1 || !strings.HasPrefix(r.Diffs[0], "Subject: expected \"Old ")) {
+			t.Errorf("Wrong diffs. Got: %q", r.Diffs)
+		}
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	var testData = map[int]struct {
+		got      Snapshot
+		expected Snapshot
+		diffs    []string
+	}{
+		1: {
+			got:      Snapshot{Subject: "Hi", TextBody: "a\nb\n"},
+			expected: Snapshot{Subject: "Hi", TextBody: "a\nb\n"},
+		},
+		2: {
+			got:      Snapshot{TextBody: "a\nb\nc"},
+			expected: Snapshot{TextBody: "a\nB\nc"},
+			diffs:    []string{`TextBody: line 2: expected "B", got "b"`},
+		},
+		3: {
+			got:      Snapshot{To: []string{"<a@example.com>"}, Attachments: []SnapshotFile{{Filename: "a.pdf", Size: 2}}},
+			expected: Snapshot{To: []string{"<a@example.com>", "<b@example.com>"}, Attachments: []SnapshotFile{{Filename: "a.pdf", Size: 3}}},
+			diffs: []string{
+				"To: expected 2 entries, got 1: [<a@example.com>]",
+				"Attachments[0]: expected {Filename:a.pdf CID: ContentType: Size:3 SHA256:}, got {Filename:a.pdf CID: ContentType: Size:2 SHA256:}",
+			},
+		},
+		4: {
+			got:      Snapshot{HTMLBody: "a\n"},
+			expected: Snapshot{HTMLBody: "a"},
+			diffs:    []string{`HTMLBody: line 2: unexpected ""`},
+		},
+	}
+
+	for index, td := range testData {
+		diffs := td.got.Diff(td.expected)
+		if !assertSliceEq(diffs, td.diffs) {
+			t.Errorf("[Test Case %v] Wrong diffs. Expected: %q, Got: %q", index, td.diffs, diffs)
+		}
+	}
+}
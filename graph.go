@@ -0,0 +1,267 @@
+package parsemail
+
+import (
+	"bytes"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// graphFileAttachment is the OData type of the attachments of a Graph
+// message that carry their content
+const graphFileAttachment = "#microsoft.graph.fileAttachment"
+
+// GraphMessage is a message resource of the Microsoft Graph API, as returned
+// by /me/messages and accepted when creating one. InternetMessageHeaders is
+// only returned when selected with $select.
+type GraphMessage struct {
+	ID                     string            `json:"id,omitempty"`
+	ConversationID         string            `json:"conversationId,omitempty"`
+	InternetMessageID      string            `json:"internetMessageId,omitempty"`
+	Subject                string            `json:"subject"`
+	Body                   *GraphItemBody    `json:"body,omitempty"`
+	From                   *GraphRecipient   `json:"from,omitempty"`
+	Sender                 *GraphRecipient   `json:"sender,omitempty"`
+	ToRecipients           []GraphRecipient  `json:"toRecipients,omitempty"`
+	CcRecipients           []GraphRecipient  `json:"ccRecipients,omitempty"`
+	BccRecipients          []GraphRecipient  `json:"bccRecipients,omitempty"`
+	ReplyTo                []GraphRecipient  `json:"replyTo,omitempty"`
+	SentDateTime           *time.Time        `json:"sentDateTime,omitempty"`
+	ReceivedDateTime       *time.Time        `json:"receivedDateTime,omitempty"`
+	InternetMessageHeaders []GraphHeader     `json:"internetMessageHeaders,omitempty"`
+	HasAttachments         bool              `json:"hasAttachments,omitempty"`
+	Attachments            []GraphAttachment `json:"attachments,omitempty"`
+}
+
+// GraphItemBody is the body of a Graph message. ContentType is "text" or
+// "html".
+type GraphItemBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+// GraphRecipient is a sender or recipient of a Graph message
+type GraphRecipient struct {
+	EmailAddress GraphEmailAddress `json:"emailAddress"`
+}
+
+// GraphEmailAddress is the name and address of a GraphRecipient
+type GraphEmailAddress struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+// GraphHeader is an internet message header of a Graph message
+type GraphHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// GraphAttachment is an attachment of a Graph message, expanded with
+// $expand=attachments. Only file attachments have ContentBytes; item and
+// reference attachments are left out when converting.
+type GraphAttachment struct {
+	ODataType       string `json:"@odata.type"`
+	ID              string `json:"id,omitempty"`
+	Name            string `json:"name"`
+	ContentType     string `json:"contentType,omitempty"`
+	Size            int    `json:"size,omitempty"`
+	IsInline        bool   `json:"isInline"`
+	ContentID       string `json:"contentId,omitempty"`
+	ContentLocation string `json:"contentLocation,omitempty"`
+	ContentBytes    []byte `json:"contentBytes,omitempty"`
+}
+
+// ParseGraphMessage builds an email from a message of the Graph API. The
+// message is assembled from its body and file attachments and parsed with
+// opts, so it yields the same email as the MIME message would. The header
+// is taken from InternetMessageHeaders if they were selected and hold the
+// original header, and from the address, subject and date properties with
+// the fields of InternetMessageHeaders added otherwise.
+func ParseGraphMessage(m *GraphMessage, opts Options) (Email, error) {
+	body := Email{
+		Subject:   m.Subject,
+		MessageID: strings.Trim(m.InternetMessageID, "<>"),
+		From:      graphAddresses(m.From),
+		ReplyTo:   graphAddressList(m.ReplyTo),
+		To:        graphAddressList(m.ToRecipients),
+		Cc:        graphAddressList(m.CcRecipients),
+	}
+	// the sender is only written when it differs from the author
+	if sender := graphAddresses(m.Sender); len(sender) > 0 && (len(body.From) == 0 || sender[0].Address != body.From[0].Address) {
+		body.Sender = sender[0]
+	}
+	if m.SentDateTime != nil {
+		body.Date = *m.SentDateTime
+	}
+
+	if m.Body != nil {
+		if strings.EqualFold(m.Body.ContentType, "html") {
+			body.HTMLBody = m.Body.Content
+		} else {
+			body.TextBody = m.Body.Content
+		}
+	}
+
+	for _, a := range m.Attachments {
+		if a.ODataType != "" && a.ODataType != graphFileAttachment {
+			continue
+		}
+
+		if a.IsInline {
+			body.EmbeddedFiles = append(body.EmbeddedFiles, EmbeddedFile{
+				CID:             strings.Trim(a.ContentID, "<>"),
+				ContentLocation: a.ContentLocation,
+				Filename:        a.Name,
+				ContentType:     a.ContentType,
+				Data:            bytes.NewReader(a.ContentBytes),
+			})
+		} else {
+			body.Attachments = append(body.Attachments, Attachment{
+				Filename:    a.Name,
+				ContentType: a.ContentType,
+				Data:        bytes.NewReader(a.ContentBytes),
+			})
+		}
+	}
+
+	part, err := body.mimeBody()
+	if err != nil {
+		return Email{}, err
+	}
+
+	fields := make([]HeaderField, 0, len(m.InternetMessageHeaders))
+	original := false
+	for _, h := range m.InternetMessageHeaders {
+		k := textproto.CanonicalMIMEHeaderKey(h.Name)
+		fields = append(fields, HeaderField{Name: k, Raw: []byte(FoldHeader(h.Name, h.Value))})
+		original = original || k == "From"
+	}
+
+	var raw []byte
+	if original {
+		raw, err = assembleMessage(fields, part)
+	} else {
+		body.Header = mail.Header{}
+		for _, h := range m.InternetMessageHeaders {
+			k := textproto.CanonicalMIMEHeaderKey(h.Name)
+			body.Header[k] = append(body.Header[k], h.Value)
+		}
+		raw, err = serialize(&body, part)
+	}
+	if err != nil {
+		return Email{}, err
+	}
+
+	e, err := ParseWithOptions(bytes.NewReader(raw), opts)
+	if err == nil && len(e.Bcc) == 0 {
+		e.Bcc = graphAddressList(m.BccRecipients)
+	}
+
+	return e, err
+}
+
+// GraphMessage converts the email to a message of the Graph API, with its
+// HTML body if it has one and its text body otherwise. Only the X- fields
+// of Header are kept, the only internet message headers Graph accepts when
+// creating a message.
+func (e *Email) GraphMessage() (*GraphMessage, error) {
+	m := &GraphMessage{
+		Subject:       e.Subject,
+		ToRecipients:  graphRecipients(e.To),
+		CcRecipients:  graphRecipients(e.Cc),
+		BccRecipients: graphRecipients(e.Bcc),
+		ReplyTo:       graphRecipients(e.ReplyTo),
+	}
+	if e.MessageID != "" {
+		m.InternetMessageID = "<" + e.MessageID + ">"
+	}
+	if from := graphRecipients(e.From); len(from) > 0 {
+		m.From = &from[0]
+	}
+	if e.Sender != nil {
+		m.Sender = &graphRecipients([]*mail.Address{e.Sender})[0]
+	}
+	if !e.Date.IsZero() {
+		date := e.Date
+		m.SentDateTime = &date
+	}
+
+	if e.HTMLBody != "" {
+		m.Body = &GraphItemBody{ContentType: "html", Content: e.HTMLBody}
+	} else {
+		m.Body = &GraphItemBody{ContentType: "text", Content: e.TextBody}
+	}
+
+	for _, k := range sortedKeys(textproto.MIMEHeader(e.Header)) {
+		if !strings.HasPrefix(textproto.CanonicalMIMEHeaderKey(k), "X-") {
+			continue
+		}
+		for _, v := range e.Header[k] {
+			m.InternetMessageHeaders = append(m.InternetMessageHeaders, GraphHeader{Name: k, Value: v})
+		}
+	}
+
+	for i := range e.Attachments {
+		a := &e.Attachments[i]
+		data, err := a.bytes()
+		if err != nil {
+			return nil, err
+		}
+		m.Attachments = append(m.Attachments, GraphAttachment{
+			ODataType:    graphFileAttachment,
+			Name:         a.Filename,
+			ContentType:  a.ContentType,
+			Size:         len(data),
+			ContentBytes: data,
+		})
+	}
+
+	for i := range e.EmbeddedFiles {
+		ef := &e.EmbeddedFiles[i]
+		data, err := ef.bytes()
+		if err != nil {
+			return nil, err
+		}
+		m.Attachments = append(m.Attachments, GraphAttachment{
+			ODataType:       graphFileAttachment,
+			Name:            ef.Filename,
+			ContentType:     ef.ContentType,
+			Size:            len(data),
+			IsInline:        true,
+			ContentID:       ef.CID,
+			ContentLocation: ef.ContentLocation,
+			ContentBytes:    data,
+		})
+	}
+	m.HasAttachments = len(m.Attachments) > 0
+
+	return m, nil
+}
+
+func graphAddresses(r *GraphRecipient) []*mail.Address {
+	if r == nil || r.EmailAddress.Address == "" {
+		return nil
+	}
+
+	return []*mail.Address{{Name: r.EmailAddress.Name, Address: r.EmailAddress.Address}}
+}
+
+func graphAddressList(list []GraphRecipient) (addresses []*mail.Address) {
+	for i := range list {
+		addresses = append(addresses, graphAddresses(&list[i])...)
+	}
+
+	return
+}
+
+func graphRecipients(list []*mail.Address) (recipients []GraphRecipient) {
+	for _, a := range list {
+		if a != nil {
+			recipients = append(recipients, GraphRecipient{GraphEmailAddress{Name: a.Name, Address: a.Address}})
+		}
+	}
+
+	return
+}
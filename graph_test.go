@@ -0,0 +1,138 @@
+package parsemail
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGraphMessage(t *testing.T) {
+	data := `{
+		"id": "AAMkAGI2",
+		"internetMessageId": "<1@example.org>",
+		"subject": "Résumé",
+		"body": {"contentType": "html", "content": "<p>Hello <img src=\"cid:logo\"></p>"},
+		"from": {"emailAddress": {"name": "Alice", "address": "alice@example.org"}},
+		"sender": {"emailAddress": {"name": "Alice", "address": "alice@example.org"}},
+		"toRecipients": [{"emailAddress": {"name": "Bob", "address": "bob@example.net"}}],
+		"ccRecipients": [],
+		"bccRecipients": [{"emailAddress": {"address": "carol@example.net"}}],
+		"sentDateTime": "2023-11-14T22:13:20Z",
+		"hasAttachments": true,
+		"attachments": [
+			{"@odata.type": "#microsoft.graph.fileAttachment", "name": "logo.png", "contentType": "image/png",
+				"isInline": true, "contentId": "logo", "contentBytes": "iVBORw0KGgo="},
+			{"@odata.type": "#microsoft.graph.fileAttachment", "name": "report.pdf", "contentType": "application/pdf",
+				"isInline": false, "contentBytes": "JVBERi0xLjQ="},
+			{"@odata.type": "#microsoft.graph.itemAttachment", "name": "Meeting", "isInline": false}
+		]
+	}`
+
+	var m GraphMessage
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := ParseGraphMessage(&m, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Subject != "Résumé" || e.MessageID != "1@example.org" || len(e.From) != 1 || e.From[0].Name != "Alice" || e.Sender != nil {
+		t.Errorf("Wrong header. Got: %s, %s, %v, %v", e.Subject, e.MessageID, e.From, e.Sender)
+	}
+	if len(e.To) != 1 || e.To[0].Address != "bob@example.net" || len(e.Bcc) != 1 || e.Bcc[0].Address != "carol@example.net" {
+		t.Errorf("Wrong recipients. Got: %v, %v", e.To, e.Bcc)
+	}
+	if !e.Date.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Wrong date. Got: %v", e.Date)
+	}
+	if strings.TrimSpace(e.HTMLBody) != m.Body.Content || e.TextBody != "" {
+		t.Errorf("Wrong bodies. Got: %q, %q", e.TextBody, e.HTMLBody)
+	}
+
+	if len(e.EmbeddedFiles) != 1 || e.EmbeddedFiles[0].CID != "logo" || e.EmbeddedFiles[0].ContentType != "image/png" {
+		t.Errorf("Wrong embedded files. Got: %+v", e.EmbeddedFiles)
+	}
+	if len(e.Attachments) != 1 || e.Attachments[0].Filename != "report.pdf" {
+		t.Fatalf("Wrong attachments. Got: %+v", e.Attachments)
+	}
+	content, _ := ioutil.ReadAll(e.Attachments[0].Data)
+	if string(content) != "%PDF-1.4" {
+		t.Errorf("Wrong attachment data. Expected: %s, Got: %q", "%PDF-1.4", content)
+	}
+}
+
+func TestParseGraphMessageHeaders(t *testing.T) {
+	m := &GraphMessage{
+		Subject: "ignored",
+		Body:    &GraphItemBody{ContentType: "text", Content: "Hello"},
+		InternetMessageHeaders: []GraphHeader{
+			{Name: "Received", Value: "from mx.example.org by mx.example.net"},
+			{Name: "From", Value: "Alice <alice@example.org>"},
+			{Name: "Subject", Value: "=?utf-8?q?R=C3=A9sum=C3=A9?="},
+			{Name: "In-Reply-To", Value: "<0@example.org>"},
+			{Name: "Content-Type", Value: "text/html"},
+		},
+	}
+
+	e, err := ParseGraphMessage(m, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Subject != "Résumé" || !assertSliceEq([]string{"0@example.org"}, e.InReplyTo) || e.Header.Get("Received") == "" {
+		t.Errorf("Wrong header. Got: %s, %v, %v", e.Subject, e.InReplyTo, e.Header)
+	}
+	if strings.TrimSpace(e.TextBody) != "Hello" || e.HTMLBody != "" {
+		t.Errorf("Wrong bodies. Got: %q, %q", e.TextBody, e.HTMLBody)
+	}
+}
+
+func TestEmailGraphMessage(t *testing.T) {
+	e, err := Parse(strings.NewReader(roundTripMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := e.GraphMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Subject != "Résumé" || m.InternetMessageID != "<1@example.org>" || m.From == nil || m.From.EmailAddress.Name != "Alice" {
+		t.Errorf("Wrong header. Got: %s, %s, %+v", m.Subject, m.InternetMessageID, m.From)
+	}
+	if m.Body.ContentType != "html" || m.Body.Content != e.HTMLBody {
+		t.Errorf("Wrong body. Got: %+v", m.Body)
+	}
+	if len(m.InternetMessageHeaders) != 1 || m.InternetMessageHeaders[0].Name != "X-Priority" {
+		t.Errorf("Wrong headers. Got: %+v", m.InternetMessageHeaders)
+	}
+	if len(m.Attachments) != 2 || !m.HasAttachments || m.Attachments[1].ContentID != "logo" || !m.Attachments[1].IsInline {
+		t.Fatalf("Wrong attachments. Got: %+v", m.Attachments)
+	}
+
+	// the message survives its JSON form
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded GraphMessage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseGraphMessage(&decoded, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Subject != e.Subject || parsed.HTMLBody != e.HTMLBody || parsed.MessageID != e.MessageID {
+		t.Errorf("Wrong email. Expected: %s, %q, Got: %s, %q", e.Subject, e.HTMLBody, parsed.Subject, parsed.HTMLBody)
+	}
+	if len(parsed.Attachments) != 1 || len(parsed.EmbeddedFiles) != 1 {
+		t.Errorf("Wrong files. Got: %+v, %+v", parsed.Attachments, parsed.EmbeddedFiles)
+	}
+}
@@ -0,0 +1,150 @@
+package parsemail
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/mail"
+	"net/textproto"
+)
+
+// HeaderScanner iterates the fields of a raw header block in place. It does
+// not allocate, which makes it suited for header-only processing of many
+// messages when just a few fields are needed.
+type HeaderScanner struct {
+	data  []byte
+	pos   int
+	name  []byte
+	value []byte
+}
+
+// NewHeaderScanner returns a scanner over the header block at the start of
+// data. Scanning stops at the blank line ending the header.
+func NewHeaderScanner(data []byte) *HeaderScanner {
+	return &HeaderScanner{data: data}
+}
+
+// Next advances to the next header field. It returns false at the end of the
+// header block.
+func (s *HeaderScanner) Next() bool {
+	for s.pos < len(s.data) {
+		start := s.pos
+		end := nextLine(s.data, start)
+		line := s.data[start:end]
+
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			s.pos = len(s.data)
+			return false
+		}
+
+		for end < len(s.data) && (s.data[end] == ' ' || s.data[end] == '\t') {
+			end = nextLine(s.data, end)
+		}
+		s.pos = end
+
+		c := bytes.IndexByte(line, ':')
+		if c < 0 || line[0] == ' ' || line[0] == '\t' {
+			// not a field, like a stray continuation line
+			continue
+		}
+
+		s.name = bytes.TrimSpace(line[:c])
+		s.value = bytes.TrimSpace(s.data[start+c+1 : end])
+
+		return true
+	}
+
+	return false
+}
+
+// Name returns the name of the current field as it appears in the header
+func (s *HeaderScanner) Name() []byte {
+	return s.name
+}
+
+// Value returns the value of the current field without surrounding
+// whitespace. Folded values still contain their line breaks.
+func (s *HeaderScanner) Value() []byte {
+	return s.value
+}
+
+// Is reports whether the current field has the given name, ignoring case
+func (s *HeaderScanner) Is(name string) bool {
+	if len(s.name) != len(name) {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		a, b := s.name[i], name[i]
+		if 'A' <= a && a <= 'Z' {
+			a += 'a' - 'A'
+		}
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		if a != b {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nextLine returns the index after the line break of the line starting at i
+func nextLine(data []byte, i int) int {
+	if end := bytes.IndexByte(data[i:], '\n'); end >= 0 {
+		return i + end + 1
+	}
+
+	return len(data)
+}
+
+// ParseHeaderFields reads only the header of a message and parses just the
+// given fields, like From, To, Subject, Date and Message-ID, into the
+// returned Email. The body is not read and no other fields are set.
+func ParseHeaderFields(r io.Reader, fields ...string) (email Email, err error) {
+	data, err := readHeaderBytes(r)
+	if err != nil {
+		return
+	}
+
+	header := make(mail.Header, len(fields))
+	s := NewHeaderScanner(data)
+	for s.Next() {
+		for _, f := range fields {
+			if s.Is(f) {
+				key := textproto.CanonicalMIMEHeaderKey(f)
+				header[key] = append(header[key], UnfoldHeader(string(s.Value())))
+				break
+			}
+		}
+	}
+
+	return createEmailFromHeader(header, SchemaVersion)
+}
+
+// readHeaderBytes reads r up to and including the blank line ending the
+// header
+func readHeaderBytes(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+
+	var data []byte
+	lineStart := 0
+	for {
+		chunk, err := br.ReadSlice('\n')
+		data = append(data, chunk...)
+
+		if err == io.EOF {
+			return data, nil
+		} else if err == bufio.ErrBufferFull {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(bytes.TrimRight(data[lineStart:], "\r\n")) == 0 {
+			return data, nil
+		}
+		lineStart = len(data)
+	}
+}
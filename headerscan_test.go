@@ -0,0 +1,123 @@
+package parsemail
+
+import (
+	"bytes"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestHeaderScanner(t *testing.T) {
+	data := []byte("From: John Doe <jdoe@machine.example>\r\n" +
+		"Subject: Saying\r\n Hello\r\n" +
+		" continued\r\n" +
+		"X-Empty:\r\n" +
+		"\r\n" +
+		"Not: a header\r\n")
+
+	var names, values []string
+	s := NewHeaderScanner(data)
+	for s.Next() {
+		names = append(names, string(s.Name()))
+		values = append(values, string(s.Value()))
+	}
+
+	if expected := []string{"From", "Subject", "X-Empty"}; !assertSliceEq(names, expected) {
+		t.Errorf("Wrong names. Expected: %q, Got: %q", expected, names)
+	}
+
+	if expected := []string{"John Doe <jdoe@machine.example>", "Saying\r\n Hello\r\n continued", ""}; !assertSliceEq(values, expected) {
+		t.Errorf("Wrong values. Expected: %q, Got: %q", expected, values)
+	}
+
+	s = NewHeaderScanner(data)
+	s.Next()
+	if !s.Is("from") || s.Is("fro") || s.Is("to") {
+		t.Errorf("Wrong case insensitive name comparison")
+	}
+}
+
+func TestParseHeaderFields(t *testing.T) {
+	e, err := ParseHeaderFields(strings.NewReader(headerScanMessage), "From", "subject", "Message-ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []mail.Address{{Name: "John Doe", Address: "jdoe@machine.example"}}; !assertAddressListEq(dereferenceAddressList(e.From), expected) {
+		t.Errorf("Wrong from. Expected: %v, Got: %v", expected, dereferenceAddressList(e.From))
+	}
+
+	if e.Subject != "Saying Hello" {
+		t.Errorf("Wrong subject. Expected: %s, Got: %s", "Saying Hello", e.Subject)
+	}
+
+	if e.MessageID != "1234@local.machine.example" {
+		t.Errorf("Wrong message id. Expected: %s, Got: %s", "1234@local.machine.example", e.MessageID)
+	}
+
+	if len(e.To) != 0 || !e.Date.IsZero() {
+		t.Errorf("Fields not requested were parsed")
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(strings.NewReader(headerScanMessage)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadMessageHeader(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		msg, err := mail.ReadMessage(strings.NewReader(headerScanMessage))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := createEmailFromHeader(msg.Header, SchemaVersion); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseHeaderFields(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseHeaderFields(strings.NewReader(headerScanMessage), "From", "To", "Subject", "Date", "Message-ID"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHeaderScanner(b *testing.B) {
+	data := []byte(headerScanMessage)
+	subject := []byte("Subject")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := NewHeaderScanner(data)
+		for s.Next() {
+			if bytes.Equal(s.Name(), subject) {
+				break
+			}
+		}
+	}
+}
+
+var headerScanMessage = `Return-Path: <jdoe@machine.example>
+Received: from mx.example.net by mx.example.org with ESMTP id 1; Fri, 21 Nov 1997 09:55:08 -0600
+Received: from machine.example by mx.example.net with ESMTP id 2; Fri, 21 Nov 1997 09:55:07 -0600
+DKIM-Signature: v=1; a=rsa-sha256; d=machine.example; s=sel; h=from:to:subject:date;
+ bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=; b=dGVzdA==
+From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Saying
+ Hello
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Message-ID: <1234@local.machine.example>
+X-Mailer: Example 1.0
+Content-Type: text/plain
+
+This is a message just to say hello.
+So, "Hello".
+`
@@ -0,0 +1,137 @@
+package parsemail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+)
+
+// ImageInfo holds the format, the dimensions and the EXIF orientation of an
+// image. Orientation is 1, the default, unless the image says otherwise.
+type ImageInfo struct {
+	Format      string
+	Width       int
+	Height      int
+	Orientation int
+}
+
+// ImageInfo returns the format, dimensions and orientation of an image/*
+// attachment without decoding the pixel data.
+func (a *Attachment) ImageInfo() (*ImageInfo, error) {
+	if !strings.HasPrefix(strings.ToLower(a.ContentType), "image/") {
+		return nil, fmt.Errorf("attachment %s is not an image: %s", a.Filename, a.ContentType)
+	}
+
+	data, err := a.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeImageInfo(data)
+}
+
+// ImageInfo returns the format, dimensions and orientation of an image/*
+// embedded file without decoding the pixel data.
+func (e *EmbeddedFile) ImageInfo() (*ImageInfo, error) {
+	if !strings.HasPrefix(strings.ToLower(e.ContentType), "image/") {
+		return nil, fmt.Errorf("embedded file %s is not an image: %s", e.CID, e.ContentType)
+	}
+
+	data, err := e.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeImageInfo(data)
+}
+
+func decodeImageInfo(data []byte) (*ImageInfo, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ImageInfo{
+		Format:      format,
+		Width:       config.Width,
+		Height:      config.Height,
+		Orientation: 1,
+	}
+
+	if format == "jpeg" {
+		if o := jpegOrientation(data); o >= 1 && o <= 8 {
+			info.Orientation = o
+		}
+	}
+
+	return info, nil
+}
+
+// jpegOrientation returns the orientation tag of the EXIF data in the APP1
+// segment of a jpeg image or 0 if there is none
+func jpegOrientation(data []byte) int {
+	// skip SOI
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return 0
+		}
+
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xda || length < 2 || i+2+length > len(data) {
+			// start of scan, the metadata segments are over
+			return 0
+		}
+
+		segment := data[i+4 : i+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+
+		i += 2 + length
+	}
+
+	return 0
+}
+
+// tiffOrientation reads the orientation tag 0x0112 from the first IFD of
+// the TIFF structure used by EXIF
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,64 @@
+package parsemail
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestImageInfo(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 3))
+
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+
+	var jpegData bytes.Buffer
+	if err := jpeg.Encode(&jpegData, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// APP1 segment with a big endian EXIF IFD holding orientation 6
+	exif := []byte("\xff\xe1\x00\x22Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00")
+	rotated := append(append(append([]byte{}, jpegData.Bytes()[:2]...), exif...), jpegData.Bytes()[2:]...)
+
+	var testData = map[int]struct {
+		contentType string
+		data        []byte
+		info        ImageInfo
+		err         bool
+	}{
+		1: {"image/png", pngData.Bytes(), ImageInfo{Format: "png", Width: 4, Height: 3, Orientation: 1}, false},
+		2: {"image/jpeg", jpegData.Bytes(), ImageInfo{Format: "jpeg", Width: 4, Height: 3, Orientation: 1}, false},
+		3: {"image/jpeg", rotated, ImageInfo{Format: "jpeg", Width: 4, Height: 3, Orientation: 6}, false},
+		4: {"application/pdf", pngData.Bytes(), ImageInfo{}, true},
+		5: {"image/png", []byte("garbage"), ImageInfo{}, true},
+	}
+
+	for index, td := range testData {
+		a := Attachment{ContentType: td.contentType, Data: bytes.NewReader(td.data)}
+		e := EmbeddedFile{ContentType: td.contentType, Data: bytes.NewReader(td.data)}
+
+		for _, f := range []func() (*ImageInfo, error){a.ImageInfo, e.ImageInfo} {
+			info, err := f()
+			if td.err {
+				if err == nil {
+					t.Errorf("[Test Case %v] Expected an error", index)
+				}
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("[Test Case %v] Unexpected error: %v", index, err)
+				continue
+			}
+
+			if *info != td.info {
+				t.Errorf("[Test Case %v] Wrong image info. Expected: %+v, Got: %+v", index, td.info, *info)
+			}
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package parsemail
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// IMAPBodyStructure is the BODYSTRUCTURE of a message or one of its parts
+// as returned by an IMAP server (RFC 3501), with the fields of the
+// BodyStructure of go-imap. MIMEType and MIMESubType are like "text" and
+// "plain"; the parts of a multipart body are in Parts.
+type IMAPBodyStructure struct {
+	MIMEType    string
+	MIMESubType string
+	Params      map[string]string
+
+	ID          string
+	Description string
+	Encoding    string
+	Size        uint32
+
+	Parts []*IMAPBodyStructure
+
+	Disposition       string
+	DispositionParams map[string]string
+	Language          []string
+	Location          []string
+}
+
+// IMAPSectionFetcher returns the content of a section of the message, like
+// "1.2", as sent by the server with its transfer encoding, i.e. the literal
+// of a BODY.PEEK[1.2] fetch
+type IMAPSectionFetcher func(section string) (io.Reader, error)
+
+// ParseIMAP builds an email from the header of a message, fetched with
+// BODY.PEEK[HEADER], and its BODYSTRUCTURE, without fetching the whole
+// message. Only the text and html bodies are fetched while parsing;
+// attachments and embedded files are fetched when their Data is first read,
+// so large files are only downloaded when used. Messages fetched whole with
+// BODY[], and messages retrieved with POP3, are parsed with Parse instead.
+func ParseIMAP(header io.Reader, bs *IMAPBodyStructure, fetch IMAPSectionFetcher, opts Options) (email Email, err error) {
+	version, err := opts.schemaVersion()
+	if err != nil {
+		return
+	}
+
+	raw, err := ioutil.ReadAll(header)
+	if err != nil {
+		return
+	}
+	if !bytes.HasSuffix(raw, []byte("\n\n")) && !bytes.HasSuffix(raw, []byte("\r\n\r\n")) {
+		raw = append(raw, "\r\n"...)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return
+	}
+
+	email, err = createEmailFromHeader(msg.Header, version)
+	if err != nil {
+		return
+	}
+
+	if opts.Strict {
+		if err = checkHeaderFields(msg.Header); err != nil {
+			return
+		}
+	}
+
+	email.opts = opts
+	email.ContentType = bs.contentType()
+
+	p := parser{email: &email, opts: opts}
+	im := imapParser{p: &p, fetch: fetch}
+
+	// the body of a single part message is its section 1
+	if len(bs.Parts) == 0 {
+		err = im.part(bs, "1", "")
+	} else {
+		err = im.part(bs, "", "")
+	}
+
+	if err == nil && opts.Tagger != nil {
+		email.Tags = opts.Tagger.Tags(&email)
+	}
+
+	return
+}
+
+// contentType returns the Content-Type header value of the part
+func (bs *IMAPBodyStructure) contentType() string {
+	return mime.FormatMediaType(strings.ToLower(bs.MIMEType+"/"+bs.MIMESubType), bs.Params)
+}
+
+// header returns the MIME header of the part as far as the structure tells
+func (bs *IMAPBodyStructure) header() textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", bs.contentType())
+	if bs.Encoding != "" {
+		h.Set("Content-Transfer-Encoding", bs.Encoding)
+	}
+	if bs.ID != "" {
+		h.Set("Content-Id", bs.ID)
+	}
+	if bs.Disposition != "" {
+		h.Set("Content-Disposition", mime.FormatMediaType(strings.ToLower(bs.Disposition), bs.DispositionParams))
+	}
+	if len(bs.Language) > 0 {
+		h.Set("Content-Language", strings.Join(bs.Language, ", "))
+	}
+	if len(bs.Location) > 0 {
+		h.Set("Content-Location", bs.Location[0])
+	}
+
+	return h
+}
+
+// imapParser walks the BODYSTRUCTURE of a message for ParseIMAP
+type imapParser struct {
+	p     *parser
+	fetch IMAPSectionFetcher
+}
+
+// part adds the part in the given section to the email. parent is the
+// content type of the enclosing multipart body.
+func (im *imapParser) part(bs *IMAPBodyStructure, section, parent string) error {
+	contentType := strings.ToLower(bs.MIMEType + "/" + bs.MIMESubType)
+	header := bs.header()
+	disposition := strings.ToLower(bs.Disposition)
+
+	if strings.HasPrefix(contentType, "multipart/") {
+		if err := im.p.enterMultipart(); err != nil {
+			return err
+		}
+		defer im.p.leaveMultipart()
+
+		for i, child := range bs.Parts {
+			if err := im.p.countPart(); err != nil {
+				return err
+			}
+
+			childSection := strconv.Itoa(i + 1)
+			if section != "" {
+				childSection = section + "." + childSection
+			}
+			if err := im.part(child, childSection, contentType); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if (contentType == contentTypeTextPlain || contentType == contentTypeTextHtml) && disposition != "attachment" &&
+		(parent != contentTypeMultipartRelated || bs.ID == "") {
+		r, err := im.fetch(section)
+		if err != nil {
+			return err
+		}
+
+		content, err := im.p.readAllDecode(r, bs.Encoding, header.Get("Content-Type"))
+		if err != nil {
+			return err
+		}
+
+		if contentType == contentTypeTextPlain {
+			im.p.addTextBody(content, header)
+		} else {
+			im.p.addHTMLBody(content, header)
+		}
+
+		return nil
+	}
+
+	encoding, err := im.p.transferEncoding(bs.Encoding)
+	if err != nil {
+		return err
+	}
+	if contentType == messageRFC822 {
+		encoding = ""
+	}
+	version := im.p.email.SchemaVersion
+	data := &imapSectionReader{fetch: im.fetch, section: section, encoding: encoding, opts: im.p.opts}
+
+	if disposition != "attachment" && (bs.ID != "" || parent == contentTypeMultipartRelated) {
+		im.p.addEmbeddedFile(EmbeddedFile{
+			CID:               strings.Trim(decodeMimeSentenceVersion(bs.ID, version), "<>"),
+			ContentLocation:   header.Get("Content-Location"),
+			Filename:          decodeMimeSentenceVersion(partFilename(header, version), version),
+			ContentType:       contentType,
+			Params:            bs.Params,
+			RawContentType:    header.Get("Content-Type"),
+			Disposition:       disposition,
+			DispositionParams: bs.DispositionParams,
+			ContentLanguage:   bs.Language,
+			Data:              data,
+		})
+
+		return nil
+	}
+
+	im.p.addAttachment(Attachment{
+		Filename:        decodeMimeSentenceVersion(partFilename(header, version), version),
+		ContentType:     contentType,
+		Params:          bs.Params,
+		RawContentType:  header.Get("Content-Type"),
+		ContentLanguage: bs.Language,
+		Data:            data,
+	})
+
+	return nil
+}
+
+// imapSectionReader fetches and decodes a section of a message once it is
+// first read, enforcing the decoded size limits of the options
+type imapSectionReader struct {
+	fetch    IMAPSectionFetcher
+	section  string
+	encoding string
+	opts     Options
+	r        io.Reader
+}
+
+// open returns a reader fetching and decoding the section from its start
+func (s *imapSectionReader) open() (io.Reader, error) {
+	fetched, err := s.fetch(s.section)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := &countingReader{r: fetched}
+	decoded, err := decoder(encoded, s.encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sizeGuard{r: decoded, encoded: encoded, opts: s.opts}, nil
+}
+
+func (s *imapSectionReader) Read(b []byte) (int, error) {
+	if s.r == nil {
+		r, err := s.open()
+		if err != nil {
+			return 0, err
+		}
+		s.r = r
+	}
+
+	return s.r.Read(b)
+}
+
+// Seek fetches the whole section into a buffer the first time, see
+// bufferedReader
+func (s *imapSectionReader) Seek(offset int64, whence int) (int64, error) {
+	b, err := bufferedReader(&s.r, s.open)
+	if err != nil {
+		return 0, err
+	}
+
+	return b.Seek(offset, whence)
+}
@@ -0,0 +1,94 @@
+package parsemail
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParseIMAP(t *testing.T) {
+	header := "From: Alice <alice@example.org>\r\nTo: bob@example.net\r\nSubject: =?utf-8?q?R=C3=A9sum=C3=A9?=\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n"
+
+	sections := map[string]string{
+		"1.1":   "Caf=E9\r\n",
+		"1.2.1": "<p>Caf&eacute; <img src=\"cid:logo\"></p>",
+		"1.2.2": "iVBORw0KGgo=",
+		"2":     "JVBERi0xLjQ=",
+	}
+
+	bs := &IMAPBodyStructure{MIMEType: "multipart", MIMESubType: "mixed", Params: map[string]string{"boundary": "b"}, Parts: []*IMAPBodyStructure{
+		{MIMEType: "multipart", MIMESubType: "alternative", Parts: []*IMAPBodyStructure{
+			{MIMEType: "text", MIMESubType: "plain", Params: map[string]string{"charset": "iso-8859-1"}, Encoding: "quoted-printable"},
+			{MIMEType: "multipart", MIMESubType: "related", Parts: []*IMAPBodyStructure{
+				{MIMEType: "text", MIMESubType: "html", Params: map[string]string{"charset": "utf-8"}, Encoding: "7bit"},
+				{MIMEType: "image", MIMESubType: "png", ID: "<logo>", Encoding: "base64", Disposition: "inline"},
+			}},
+		}},
+		{MIMEType: "application", MIMESubType: "pdf", Encoding: "base64", Size: 12,
+			Disposition: "attachment", DispositionParams: map[string]string{"filename": "report.pdf"}},
+	}}
+
+	var fetched []string
+	fetch := func(section string) (io.Reader, error) {
+		fetched = append(fetched, section)
+		content, ok := sections[section]
+		if !ok {
+			return nil, fmt.Errorf("no section %s", section)
+		}
+		return strings.NewReader(content), nil
+	}
+
+	e, err := ParseIMAP(strings.NewReader(header), bs, fetch, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Subject != "Résumé" || len(e.From) != 1 || e.From[0].Address != "alice@example.org" {
+		t.Errorf("Wrong header. Got: %s, %v", e.Subject, e.From)
+	}
+	if strings.TrimSpace(e.TextBody) != "Café" {
+		t.Errorf("Wrong text body. Expected: %s, Got: %q", "Café", e.TextBody)
+	}
+	if e.HTMLBody != sections["1.2.1"] {
+		t.Errorf("Wrong html body. Expected: %s, Got: %q", sections["1.2.1"], e.HTMLBody)
+	}
+	if !assertSliceEq([]string{"1.1", "1.2.1"}, fetched) {
+		t.Errorf("Wrong sections fetched while parsing. Got: %v", fetched)
+	}
+
+	if len(e.EmbeddedFiles) != 1 || e.EmbeddedFiles[0].CID != "logo" || e.EmbeddedFiles[0].ContentType != "image/png" {
+		t.Fatalf("Wrong embedded files. Got: %+v", e.EmbeddedFiles)
+	}
+	if len(e.Attachments) != 1 || e.Attachments[0].Filename != "report.pdf" || e.Attachments[0].ContentType != "application/pdf" {
+		t.Fatalf("Wrong attachments. Got: %+v", e.Attachments)
+	}
+
+	data, err := ioutil.ReadAll(e.Attachments[0].Data)
+	if err != nil || string(data) != "%PDF-1.4" {
+		t.Errorf("Wrong attachment data. Expected: %s, Got: %q, %v", "%PDF-1.4", data, err)
+	}
+	if !assertSliceEq([]string{"1.1", "1.2.1", "2"}, fetched) {
+		t.Errorf("Wrong sections fetched. Got: %v", fetched)
+	}
+}
+
+func TestParseIMAPSinglePart(t *testing.T) {
+	bs := &IMAPBodyStructure{MIMEType: "TEXT", MIMESubType: "PLAIN", Params: map[string]string{"charset": "us-ascii"}, Encoding: "7BIT"}
+
+	e, err := ParseIMAP(strings.NewReader("Subject: Hi\r\n\r\n"), bs, func(section string) (io.Reader, error) {
+		if section != "1" {
+			t.Errorf("Wrong section. Expected: %s, Got: %s", "1", section)
+		}
+		return strings.NewReader("Hello\r\n"), nil
+	}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Subject != "Hi" || strings.TrimSpace(e.TextBody) != "Hello" {
+		t.Errorf("Wrong email. Got: %s, %q", e.Subject, e.TextBody)
+	}
+}
@@ -0,0 +1,152 @@
+package parsemail
+
+import (
+	"bufio"
+	"bytes"
+	"net/mail"
+	"strings"
+)
+
+// JournalRecord is the envelope of an Exchange journal report together with
+// the journaled original message
+type JournalRecord struct {
+	Sender     string
+	OnBehalfOf string
+	Subject    string
+	MessageID  string
+	Recipients []JournalRecipient
+
+	// Message is the journaled message, nil if the report doesn't contain it
+	Message *Email
+}
+
+// JournalRecipient is a recipient of the journaled message as resolved by
+// Exchange. Field is "To", "Cc" or "Bcc" when the report tells, Expanded
+// lists the distribution lists the recipient was expanded from and
+// Forwarded the mailboxes that forwarded to it.
+type JournalRecipient struct {
+	Address   string
+	Field     string
+	Expanded  []string
+	Forwarded []string
+}
+
+// IsJournalReport reports whether the email is an Exchange journal report
+func (e *Email) IsJournalReport() bool {
+	_, ok := e.Header["X-Ms-Journal-Report"]
+
+	return ok
+}
+
+// JournalRecord parses the envelope of an Exchange journal report from its
+// body and the attached original message, which is parsed with the options
+// the email was parsed with. It returns nil if the email is no journal
+// report.
+func (e *Email) JournalRecord() (*JournalRecord, error) {
+	if !e.IsJournalReport() {
+		return nil, nil
+	}
+
+	jr := parseJournalEnvelope(e.TextBody, e.SchemaVersion)
+
+	if at := e.forwardedAttachment(); at != nil && at.ChildEmail != nil {
+		jr.Message = at.ChildEmail
+	} else if data := e.journaledMessage(); data != nil {
+		msg, err := e.parseEmbedded(bytes.NewReader(data))
+		if err != nil {
+			return jr, err
+		}
+		jr.Message = &msg
+	}
+
+	return jr, nil
+}
+
+// journaledMessage returns the raw message/rfc822 part of the report
+func (e *Email) journaledMessage() []byte {
+	if at := e.forwardedAttachment(); at != nil {
+		data, err := at.bytes()
+		if err == nil {
+			return data
+		}
+	}
+
+	for i := range e.EmbeddedFiles {
+		ef := &e.EmbeddedFiles[i]
+		if ef.ContentType == messageRFC822 {
+			if data, err := ef.bytes(); err == nil {
+				return data
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseJournalEnvelope parses the "Field: value" lines of the report body.
+// Recipient lines may carry ", Expanded: list" and ", Forwarded: mailbox"
+// annotations.
+func parseJournalEnvelope(body string, version int) *JournalRecord {
+	jr := &JournalRecord{}
+
+	s := bufio.NewScanner(strings.NewReader(body))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+
+		field, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch strings.ToLower(field) {
+		case "sender":
+			jr.Sender = journalAddress(value)
+		case "on-behalf-of":
+			jr.OnBehalfOf = journalAddress(value)
+		case "subject":
+			jr.Subject = decodeMimeSentenceVersion(value, version)
+		case "message-id":
+			jr.MessageID = strings.Trim(value, "<>")
+		case "recipient", "to", "cc", "bcc":
+			r := parseJournalRecipient(value)
+			if field = strings.Title(strings.ToLower(field)); field != "Recipient" {
+				r.Field = field
+			}
+			jr.Recipients = append(jr.Recipients, r)
+		}
+	}
+
+	return jr
+}
+
+func parseJournalRecipient(value string) JournalRecipient {
+	parts := strings.Split(value, ",")
+	r := JournalRecipient{Address: journalAddress(parts[0])}
+
+	for _, p := range parts[1:] {
+		i := strings.Index(p, ":")
+		if i < 0 {
+			continue
+		}
+
+		name, addr := strings.ToLower(strings.TrimSpace(p[:i])), journalAddress(p[i+1:])
+		switch name {
+		case "expanded":
+			r.Expanded = append(r.Expanded, addr)
+		case "forwarded":
+			r.Forwarded = append(r.Forwarded, addr)
+		}
+	}
+
+	return r
+}
+
+// journalAddress returns the address of a bare or named address
+func journalAddress(s string) string {
+	s = strings.TrimSpace(s)
+	if a, err := mail.ParseAddress(s); err == nil {
+		return a.Address
+	}
+
+	return s
+}
@@ -0,0 +1,138 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJournalRecord(t *testing.T) {
+	e, err := Parse(strings.NewReader(journalReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.IsJournalReport() {
+		t.Fatalf("Expected a journal report")
+	}
+
+	jr, err := e.JournalRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jr.Sender != "jdoe@example.com" {
+		t.Errorf("Wrong sender. Expected: %s, Got: %s", "jdoe@example.com", jr.Sender)
+	}
+
+	if jr.Subject != "Quarterly numbers" {
+		t.Errorf("Wrong subject. Expected: %s, Got: %s", "Quarterly numbers", jr.Subject)
+	}
+
+	if jr.MessageID != "1234@example.com" {
+		t.Errorf("Wrong message id. Expected: %s, Got: %s", "1234@example.com", jr.MessageID)
+	}
+
+	var testData = map[int]struct {
+		address   string
+		field     string
+		expanded  []string
+		forwarded []string
+	}{
+		0: {address: "mary@example.com", field: "To"},
+		1: {address: "bob@example.com", field: "Cc", expanded: []string{"sales@example.com"}},
+		2: {address: "audit@example.com", field: "Bcc"},
+		3: {address: "alice@example.com", forwarded: []string{"carol@example.com"}},
+	}
+
+	if len(jr.Recipients) != len(testData) {
+		t.Fatalf("Wrong number of recipients. Expected: %d, Got: %d", len(testData), len(jr.Recipients))
+	}
+
+	for index, td := range testData {
+		r := jr.Recipients[index]
+		if r.Address != td.address {
+			t.Errorf("[Test Case %v] Wrong address. Expected: %s, Got: %s", index, td.address, r.Address)
+		}
+		if r.Field != td.field {
+			t.Errorf("[Test Case %v] Wrong field. Expected: %s, Got: %s", index, td.field, r.Field)
+		}
+		if !assertSliceEq(r.Expanded, td.expanded) {
+			t.Errorf("[Test Case %v] Wrong expanded. Expected: %v, Got: %v", index, td.expanded, r.Expanded)
+		}
+		if !assertSliceEq(r.Forwarded, td.forwarded) {
+			t.Errorf("[Test Case %v] Wrong forwarded. Expected: %v, Got: %v", index, td.forwarded, r.Forwarded)
+		}
+	}
+
+	if jr.Message == nil {
+		t.Fatalf("Expected the journaled message")
+	}
+
+	if jr.Message.Subject != "Quarterly numbers" || !strings.Contains(jr.Message.TextBody, "numbers attached") {
+		t.Errorf("Wrong journaled message: %s %q", jr.Message.Subject, jr.Message.TextBody)
+	}
+}
+
+func TestJournalRecordOptions(t *testing.T) {
+	tagger := NewTagger()
+	tagger.Add(SubjectContains("numbers"), "numbers")
+
+	e, err := ParseWithOptions(strings.NewReader(journalReport), Options{Tagger: tagger})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jr, err := e.JournalRecord()
+	if err != nil || jr.Message == nil {
+		t.Fatalf("Expected the journaled message, Got: %v", err)
+	}
+	if !assertSliceEq([]string{"numbers"}, jr.Message.Tags) {
+		t.Errorf("Options not applied to the journaled message. Got: %v", jr.Message.Tags)
+	}
+}
+
+func TestJournalRecordNoReport(t *testing.T) {
+	e, err := Parse(strings.NewReader(latin1Attachment))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jr, err := e.JournalRecord()
+	if err != nil || jr != nil {
+		t.Errorf("Expected no journal record, Got: %v %v", jr, err)
+	}
+}
+
+var journalReport = `From: Microsoft Exchange <journal@example.com>
+To: archive@example.com
+Subject: Quarterly numbers
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+X-MS-Journal-Report:
+Content-Type: multipart/mixed; boundary="JOURNAL"
+
+--JOURNAL
+Content-Type: text/plain; charset=us-ascii
+
+Sender: jdoe@example.com
+Subject: Quarterly numbers
+Message-Id: <1234@example.com>
+To: mary@example.com
+Cc: bob@example.com, Expanded: sales@example.com
+Bcc: audit@example.com
+Recipient: alice@example.com, Forwarded: carol@example.com
+
+--JOURNAL
+Content-Type: message/rfc822
+Content-Disposition: attachment
+
+From: John Doe <jdoe@example.com>
+To: Mary <mary@example.com>
+Cc: sales@example.com
+Subject: Quarterly numbers
+Message-Id: <1234@example.com>
+Date: Fri, 21 Nov 1997 09:50:00 -0600
+Content-Type: text/plain
+
+The numbers attached.
+--JOURNAL--
+`
@@ -0,0 +1,51 @@
+package parsemail
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseLanguageTags parses a Content-Language or Accept-Language header value
+// into its language tags. Tags with quality values are ordered by descending
+// quality and tags with a quality of 0 are dropped.
+func ParseLanguageTags(value string) []string {
+	type weightedTag struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weightedTag
+	for _, field := range strings.Split(value, ",") {
+		params := strings.Split(field, ";")
+		tag := strings.TrimSpace(params[0])
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > 0 {
+			tags = append(tags, weightedTag{tag: tag, q: q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	var result []string
+	for _, t := range tags {
+		result = append(result, t.tag)
+	}
+
+	return result
+}
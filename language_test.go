@@ -0,0 +1,80 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLanguageTags(t *testing.T) {
+	var testData = map[int]struct {
+		value string
+		tags  []string
+	}{
+		1: {
+			value: "",
+		},
+		2: {
+			value: "de",
+			tags:  []string{"de"},
+		},
+		3: {
+			value: "en, fr",
+			tags:  []string{"en", "fr"},
+		},
+		4: {
+			value: "en;q=0.8, de-CH, de;q=0.9, *;q=0",
+			tags:  []string{"de-CH", "de", "en"},
+		},
+	}
+
+	for index, td := range testData {
+		if tags := ParseLanguageTags(td.value); !assertSliceEq(td.tags, tags) {
+			t.Errorf("[Test Case %v] Wrong tags. Expected: %s, Got: %s", index, td.tags, tags)
+		}
+	}
+}
+
+func TestContentLanguage(t *testing.T) {
+	e, err := Parse(strings.NewReader(contentLanguageExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assertSliceEq([]string{"en", "de"}, e.ContentLanguage) {
+		t.Errorf("Wrong content language: %s", e.ContentLanguage)
+	}
+
+	if !assertSliceEq([]string{"de", "en"}, e.AcceptLanguage) {
+		t.Errorf("Wrong accept language: %s", e.AcceptLanguage)
+	}
+
+	if len(e.Attachments) != 1 || !assertSliceEq([]string{"fr"}, e.Attachments[0].ContentLanguage) {
+		t.Errorf("Wrong attachment content language: %v", e.Attachments)
+	}
+
+	if tags := ParseLanguageTags(e.TextBodyHeader.Get("Content-Language")); !assertSliceEq([]string{"en"}, tags) {
+		t.Errorf("Wrong text body content language: %s", tags)
+	}
+}
+
+var contentLanguageExample = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Saying Hello
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Content-Language: en, de
+Accept-Language: en;q=0.5, de
+Content-Type: multipart/mixed; boundary="MIX"
+
+--MIX
+Content-Type: text/plain
+Content-Language: en
+
+Hello.
+--MIX
+Content-Type: application/octet-stream; name="bonjour.txt"
+Content-Disposition: attachment; filename="bonjour.txt"
+Content-Language: fr
+
+Bonjour.
+--MIX--
+`
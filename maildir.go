@@ -0,0 +1,214 @@
+package parsemail
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaildirReader reads the messages of a Maildir one at a time, those in new
+// and in cur, ordered by their modification time. Messages still written to
+// tmp are not read.
+type MaildirReader struct {
+	dir    string
+	parser *Parser
+
+	files []maildirFile
+	i     int
+	path  string
+
+	cp MaildirCheckpoint
+
+	// cur maps the unique names of the messages in cur to their file names,
+	// listed when a message moved since the reader was created
+	cur        map[string]string
+	curModTime time.Time
+}
+
+// maildirFile is a message of a Maildir. key is its unique name, the file
+// name without the flags that change when it moves from new to cur.
+type maildirFile struct {
+	path    string
+	key     string
+	modTime time.Time
+}
+
+// NewMaildirReader returns a reader parsing the messages of the Maildir dir
+// with opts
+func NewMaildirReader(dir string, opts Options) (*MaildirReader, error) {
+	return ResumeMaildirReader(dir, opts, MaildirCheckpoint{})
+}
+
+// MaildirCheckpoint records how far a Maildir was read, so that a later scan
+// can resume with the messages delivered since. It can be stored as JSON.
+type MaildirCheckpoint struct {
+	// ModTime is the modification time of the newest message read
+	ModTime time.Time
+	// Files are the unique names of the messages read that were modified at
+	// ModTime, which are skipped when resuming
+	Files []string
+	// Messages is the number of messages read
+	Messages int
+}
+
+// Checkpoint returns the state of the reader after the message last
+// returned by Next. Messages keep their modification time when they move
+// from new to cur or their flags change, so they aren't read again. A
+// delivery that was written before the checkpoint but moved from tmp to new
+// after it is missed, so it should be taken while no delivery is in
+// progress.
+func (mr *MaildirReader) Checkpoint() MaildirCheckpoint {
+	cp := mr.cp
+	cp.Files = append([]string(nil), mr.cp.Files...)
+
+	return cp
+}
+
+// ResumeMaildirReader returns a reader for the messages of the Maildir dir
+// delivered since the checkpoint was taken
+func ResumeMaildirReader(dir string, opts Options, cp MaildirCheckpoint) (*MaildirReader, error) {
+	seen := map[string]bool{}
+	for _, key := range cp.Files {
+		seen[key] = true
+	}
+
+	mr := &MaildirReader{dir: dir, parser: NewParser(opts), cp: cp}
+	for _, sub := range []string{"new", "cur"} {
+		infos, err := ioutil.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, info := range infos {
+			if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+				continue
+			}
+
+			f := maildirFile{path: filepath.Join(dir, sub, info.Name()), key: maildirKey(info.Name()), modTime: info.ModTime()}
+			if f.modTime.Before(cp.ModTime) || f.modTime.Equal(cp.ModTime) && seen[f.key] {
+				continue
+			}
+			mr.files = append(mr.files, f)
+		}
+	}
+
+	sort.Slice(mr.files, func(i, j int) bool {
+		a, b := mr.files[i], mr.files[j]
+		if !a.modTime.Equal(b.modTime) {
+			return a.modTime.Before(b.modTime)
+		}
+		return a.key < b.key
+	})
+
+	return mr, nil
+}
+
+// Next returns the next message of the Maildir. It returns io.EOF after the
+// last message. A message that fails to parse returns an error, the
+// following messages can still be read. Messages deleted since the reader
+// was created are skipped.
+func (mr *MaildirReader) Next() (Email, error) {
+	for mr.i < len(mr.files) {
+		f := mr.files[mr.i]
+		mr.i++
+
+		file, err := mr.open(f)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if f.modTime.After(mr.cp.ModTime) {
+			mr.cp.ModTime, mr.cp.Files = f.modTime, nil
+		}
+		mr.cp.Files = append(mr.cp.Files, f.key)
+		mr.cp.Messages++
+
+		if err != nil {
+			mr.path = f.path
+			return Email{}, fmt.Errorf("maildir: %s: %v", f.key, err)
+		}
+
+		mr.path = file.Name()
+		e, err := mr.parser.Parse(file)
+		file.Close()
+		if err != nil {
+			return e, fmt.Errorf("maildir: %s: %v", f.key, err)
+		}
+
+		return e, nil
+	}
+
+	return Email{}, io.EOF
+}
+
+// Filename returns the path of the message last returned by Next
+func (mr *MaildirReader) Filename() string {
+	return mr.path
+}
+
+// open opens the message f, also when it was moved to cur or its flags
+// changed since the reader was created
+func (mr *MaildirReader) open(f maildirFile) (*os.File, error) {
+	file, err := os.Open(f.path)
+	if !os.IsNotExist(err) {
+		return file, err
+	}
+
+	// the listing of cur may be missing the message or be outdated, it is
+	// listed again once
+	for i := 0; i < 2; i++ {
+		if name, ok := mr.cur[f.key]; ok {
+			file, ferr := os.Open(filepath.Join(mr.dir, "cur", name))
+			if !os.IsNotExist(ferr) {
+				return file, ferr
+			}
+		}
+
+		if mr.listCur() != nil {
+			break
+		}
+	}
+
+	return nil, err
+}
+
+// listCur lists the messages in cur by their unique names. The listing is
+// only repeated once cur was modified, so that looking up many moved or
+// deleted messages doesn't read the directory for each.
+func (mr *MaildirReader) listCur() error {
+	dir := filepath.Join(mr.dir, "cur")
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if mr.cur != nil && info.ModTime().Equal(mr.curModTime) {
+		return nil
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	mr.cur, mr.curModTime = make(map[string]string, len(infos)), info.ModTime()
+	for _, info := range infos {
+		mr.cur[maildirKey(info.Name())] = info.Name()
+	}
+
+	return nil
+}
+
+// maildirKey returns the unique name of a message file, without the
+// ":2,flags" info
+func maildirKey(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[:i]
+	}
+
+	return name
+}
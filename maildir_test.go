@@ -0,0 +1,112 @@
+package parsemail
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMaildirReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parsemail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := time.Unix(1700000000, 0)
+	deliver := func(name, subject string, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("From: a@example.org\r\nSubject: "+subject+"\r\n\r\nHi\r\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(mr *MaildirReader) (subjects []string) {
+		for {
+			e, err := mr.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			subjects = append(subjects, e.Subject)
+		}
+	}
+
+	deliver("cur/1700000002.M1P1.host:2,S", "Second", base.Add(2*time.Second))
+	deliver("new/1700000001.M1P1.host", "First", base.Add(time.Second))
+	deliver("new/1700000003.M1P1.host", "Third", base.Add(3*time.Second))
+	deliver("new/1700000003.M2P1.host", "Fourth", base.Add(3*time.Second))
+	deliver("tmp/1700000004.M1P1.host", "Delivering", base.Add(4*time.Second))
+
+	mr, err := NewMaildirReader(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subjects, expected := read(mr), []string{"First", "Second", "Third", "Fourth"}; !assertSliceEq(expected, subjects) {
+		t.Errorf("Wrong messages. Expected: %v, Got: %v", expected, subjects)
+	}
+	if mr.Filename() != filepath.Join(dir, "new/1700000003.M2P1.host") {
+		t.Errorf("Wrong filename. Got: %s", mr.Filename())
+	}
+
+	// checkpoints survive JSON
+	data, err := json.Marshal(mr.Checkpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp MaildirCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Messages != 4 || !cp.ModTime.Equal(base.Add(3*time.Second)) || len(cp.Files) != 2 {
+		t.Errorf("Wrong checkpoint. Got: %+v", cp)
+	}
+
+	// seen messages moving to cur are not read again, new deliveries are
+	if err := os.Rename(filepath.Join(dir, "new/1700000003.M1P1.host"), filepath.Join(dir, "cur/1700000003.M1P1.host:2,S")); err != nil {
+		t.Fatal(err)
+	}
+	deliver("new/1700000003.M3P1.host", "Fifth", base.Add(3*time.Second))
+	deliver("new/1700000005.M1P1.host", "Sixth", base.Add(5*time.Second))
+
+	mr, err = ResumeMaildirReader(dir, Options{}, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// messages moved after the reader listed them are still found, with a
+	// single listing of cur
+	for _, name := range []string{"1700000003.M3P1.host", "1700000005.M1P1.host"} {
+		if err := os.Rename(filepath.Join(dir, "new", name), filepath.Join(dir, "cur", name+":2,")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if subjects, expected := read(mr), []string{"Fifth", "Sixth"}; !assertSliceEq(expected, subjects) {
+		t.Errorf("Wrong resumed messages. Expected: %v, Got: %v", expected, subjects)
+	}
+	if cp := mr.Checkpoint(); cp.Messages != 6 || !assertSliceEq([]string{"1700000005.M1P1.host"}, cp.Files) {
+		t.Errorf("Wrong checkpoint. Got: %+v", cp)
+	}
+	if len(mr.cur) != 4 {
+		t.Errorf("Wrong listing of cur. Expected: 4 messages, Got: %v", mr.cur)
+	}
+
+	if _, err := NewMaildirReader(filepath.Join(dir, "missing"), Options{}); err == nil {
+		t.Error("Expected an error for a missing Maildir")
+	}
+}
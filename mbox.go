@@ -0,0 +1,222 @@
+package parsemail
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// MboxReader reads the messages of an mbox file one at a time. It accepts the
+// mboxo and mboxrd variants: a From_ line only separates messages after an
+// empty line and quoted ">From " lines are unquoted. Lines of any length are
+// supported, as found in Google Takeout exports.
+type MboxReader struct {
+	r      *bufio.Reader
+	parser *Parser
+
+	from    []byte
+	pending []byte
+	n       int
+
+	// offset counts the bytes read, fromOffset and pendingOffset are the
+	// offsets of the lines in from and pending. digest is the SHA-256 of the
+	// last message as stored.
+	offset        int64
+	fromOffset    int64
+	pendingOffset int64
+	digest        []byte
+}
+
+// NewMboxReader returns a reader parsing the messages of r with opts
+func NewMboxReader(r io.Reader, opts Options) *MboxReader {
+	return &MboxReader{r: bufio.NewReader(r), parser: NewParser(opts)}
+}
+
+// Next returns the next message of the mailbox. It returns io.EOF after the
+// last message. A message that fails to parse returns an error, the following
+// messages can still be read.
+func (mr *MboxReader) Next() (Email, error) {
+	raw, err := mr.nextRaw()
+	if err != nil {
+		return Email{}, err
+	}
+	mr.n++
+
+	e, err := mr.parser.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return e, fmt.Errorf("mbox: message %d: %v", mr.n, err)
+	}
+
+	return e, nil
+}
+
+// ErrMboxChanged is returned when resuming from a checkpoint of a mailbox
+// that was rewritten since, e.g. by deleting messages. It has to be read
+// from the start again.
+var ErrMboxChanged = errors.New("mbox: mailbox changed since the checkpoint")
+
+// MboxCheckpoint records how far a mailbox was read, so that a later scan
+// can resume with the messages appended since. It can be stored as JSON.
+type MboxCheckpoint struct {
+	// Offset is the byte offset following the last message read
+	Offset int64
+	// Messages is the number of messages read
+	Messages int
+	// LastOffset and LastDigest are the offset and the SHA-256 of the last
+	// message read as stored, From_ line included, which detect a rewritten
+	// mailbox
+	LastOffset int64
+	LastDigest []byte
+}
+
+// Checkpoint returns the state of the reader after the message last
+// returned by Next. A checkpoint of a mailbox that is being appended to may
+// miss the end of the last message, so it should be taken while the
+// mailbox is locked or not written.
+func (mr *MboxReader) Checkpoint() MboxCheckpoint {
+	cp := MboxCheckpoint{Offset: mr.offset, Messages: mr.n, LastOffset: mr.fromOffset, LastDigest: mr.digest}
+	if mr.pending != nil {
+		cp.Offset = mr.pendingOffset
+	}
+
+	return cp
+}
+
+// ResumeMboxReader returns a reader for the messages appended to the
+// mailbox r since the checkpoint was taken. It returns ErrMboxChanged if the
+// mailbox no longer starts with the messages read up to the checkpoint.
+func ResumeMboxReader(r io.ReadSeeker, opts Options, cp MboxCheckpoint) (*MboxReader, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size < cp.Offset {
+		return nil, ErrMboxChanged
+	}
+
+	if cp.LastDigest != nil {
+		if _, err := r.Seek(cp.LastOffset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		if _, err := io.CopyN(h, r, cp.Offset-cp.LastOffset); err != nil || !bytes.Equal(h.Sum(nil), cp.LastDigest) {
+			return nil, ErrMboxChanged
+		}
+	}
+
+	if _, err := r.Seek(cp.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	mr := NewMboxReader(r, opts)
+	mr.n = cp.Messages
+	mr.offset = cp.Offset
+	mr.fromOffset = cp.LastOffset
+	mr.digest = cp.LastDigest
+
+	return mr, nil
+}
+
+// EnvelopeSender returns the sender given in the From_ line of the message
+// last returned by Next
+func (mr *MboxReader) EnvelopeSender() string {
+	fields := bytes.Fields(mr.from)
+	if len(fields) < 2 {
+		return ""
+	}
+
+	return string(fields[1])
+}
+
+// nextRaw returns the unquoted content of the next message
+func (mr *MboxReader) nextRaw() ([]byte, error) {
+	// anything before the first From_ line is skipped
+	for mr.pending == nil {
+		line, err := mr.r.ReadBytes('\n')
+		mr.offset += int64(len(line))
+		if isMboxFromLine(line) {
+			mr.pending = line
+			mr.pendingOffset = mr.offset - int64(len(line))
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mr.from, mr.pending = mr.pending, nil
+	mr.fromOffset = mr.pendingOffset
+	h := sha256.New()
+	h.Write(mr.from)
+	defer func() { mr.digest = h.Sum(nil) }()
+
+	var buf bytes.Buffer
+	blank := false
+	for {
+		line, err := mr.r.ReadBytes('\n')
+		mr.offset += int64(len(line))
+		if len(line) > 0 {
+			if blank && isMboxFromLine(line) {
+				mr.pending = line
+				mr.pendingOffset = mr.offset - int64(len(line))
+				break
+			}
+			blank = len(bytes.TrimRight(line, "\r\n")) == 0
+			h.Write(line)
+			buf.Write(unquoteMboxLine(line))
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the empty line before the next From_ line belongs to the mailbox
+	raw := buf.Bytes()
+	if bytes.HasSuffix(raw, []byte("\r\n\r\n")) {
+		raw = raw[:len(raw)-2]
+	} else if bytes.HasSuffix(raw, []byte("\n\n")) {
+		raw = raw[:len(raw)-1]
+	}
+
+	return raw, nil
+}
+
+var mboxTime = regexp.MustCompile(`^\d{1,2}:\d\d(:\d\d)?$`)
+
+// isMboxFromLine reports whether line is a From_ line, "From" followed by the
+// sender and a date
+func isMboxFromLine(line []byte) bool {
+	if !bytes.HasPrefix(line, []byte("From ")) {
+		return false
+	}
+
+	fields := bytes.Fields(line)
+	if len(fields) < 4 {
+		return false
+	}
+
+	for _, f := range fields[2:] {
+		if mboxTime.Match(f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unquoteMboxLine removes one level of ">" quoting from ">From " lines
+func unquoteMboxLine(line []byte) []byte {
+	if m := mboxFromLine.FindIndex(line); m != nil && m[0] == 0 && line[0] == '>' {
+		return line[1:]
+	}
+
+	return line
+}
@@ -0,0 +1,153 @@
+package parsemail
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMboxReader(t *testing.T) {
+	mbox := "garbage before the first message\n" + takeoutMbox + "From " + strings.Repeat("x", 100000) + "@xxx Thu Jun 09 10:00:00 +0000 2022\n" + takeoutLast
+
+	mr := NewMboxReader(strings.NewReader(mbox), Options{})
+
+	var testData = map[int]struct {
+		sender   string
+		subject  string
+		textBody string
+	}{
+		0: {sender: "1735671052341234567@xxx", subject: "Hello", textBody: "Hi,\nFrom here on it works.\n\n>From the archive."},
+		1: {sender: "1735671052341234568@xxx", subject: "Chat with Bob"},
+		2: {sender: strings.Repeat("x", 100000) + "@xxx", subject: "Last", textBody: "Bye"},
+	}
+
+	for index := 0; index < len(testData); index++ {
+		td := testData[index]
+
+		e, err := mr.Next()
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if mr.EnvelopeSender() != td.sender {
+			t.Errorf("[Test Case %v] Wrong envelope sender. Expected: %.40s, Got: %.40s", index, td.sender, mr.EnvelopeSender())
+		}
+		if e.Subject != td.subject {
+			t.Errorf("[Test Case %v] Wrong subject. Expected: %s, Got: %s", index, td.subject, e.Subject)
+		}
+		if td.textBody != "" && e.TextBody != td.textBody {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, td.textBody, e.TextBody)
+		}
+	}
+
+	if _, err := mr.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, Got: %v", err)
+	}
+}
+
+func TestMboxReaderEmpty(t *testing.T) {
+	if _, err := NewMboxReader(strings.NewReader(""), Options{}).Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, Got: %v", err)
+	}
+}
+
+var takeoutMbox = `From 1735671052341234567@xxx Wed Nov 18 23:41:02 +0000 2020
+X-GM-THRID: 1683529148731234567
+X-Gmail-Labels: Inbox,Important,"Work, Projects",Category Updates
+From: John Doe <jdoe@example.com>
+To: mary@example.com
+Subject: Hello
+Date: Wed, 18 Nov 2020 23:41:00 +0000
+Content-Type: text/plain
+
+Hi,
+From here on it works.
+
+>>From the archive.
+
+From 1735671052341234568@xxx Thu Nov 19 08:00:00 +0000 2020
+X-GM-THRID: 1683529148731234568
+X-Gmail-Labels: Chat,Archived
+From: Bob <bob@gmail.com>
+Subject: Chat with Bob
+Date: Thu, 19 Nov 2020 08:00:00 +0000
+Content-Type: text/xml; charset=utf-8
+
+<con:conversation xmlns:con="google:archive:conversation"><cli:message xmlns:cli="jabber:client" to="alice@gmail.com" iconset="classic" from="bob@gmail.com/Talk.v104" type="chat"><cli:body>hey there</cli:body></cli:message><cli:message xmlns:cli="jabber:client" to="bob@gmail.com" from="alice@gmail.com" type="chat"><cli:body>hi Bob &amp; co</cli:body></cli:message></con:conversation>
+
+`
+
+var takeoutLast = `From: Mary <mary@example.com>
+Subject: Last
+Date: Thu, 19 Nov 2020 09:00:00 +0000
+
+Bye
+`
+
+func TestMboxCheckpoint(t *testing.T) {
+	message := func(subject string) string {
+		return "From sender@example.com Thu Jun 09 10:00:00 2022\nFrom: sender@example.com\nSubject: " + subject + "\n\n" + subject + " body\n\n"
+	}
+	mbox := message("One") + message("Two")
+
+	read := func(mr *MboxReader) (subjects []string) {
+		for {
+			e, err := mr.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			subjects = append(subjects, e.Subject)
+		}
+	}
+
+	mr := NewMboxReader(strings.NewReader(mbox), Options{})
+	if _, err := mr.Next(); err != nil {
+		t.Fatal(err)
+	}
+	first := mr.Checkpoint()
+	read(mr)
+	last := mr.Checkpoint()
+
+	if last.Offset != int64(len(mbox)) || last.Messages != 2 {
+		t.Errorf("Wrong checkpoint. Got: %+v", last)
+	}
+
+	appended := mbox + message("Three")
+
+	var testData = map[int]struct {
+		mailbox    string
+		checkpoint MboxCheckpoint
+		subjects   []string
+		err        error
+	}{
+		1: {mailbox: mbox, checkpoint: last},
+		2: {mailbox: appended, checkpoint: last, subjects: []string{"Three"}},
+		3: {mailbox: appended, checkpoint: first, subjects: []string{"Two", "Three"}},
+		4: {mailbox: appended, checkpoint: MboxCheckpoint{}, subjects: []string{"One", "Two", "Three"}},
+		5: {mailbox: message("Two") + message("Three"), checkpoint: last, err: ErrMboxChanged},
+		6: {mailbox: message("One"), checkpoint: last, err: ErrMboxChanged},
+	}
+
+	for index, td := range testData {
+		mr, err := ResumeMboxReader(strings.NewReader(td.mailbox), Options{}, td.checkpoint)
+		if err != td.err {
+			t.Errorf("[Test Case %v] Wrong error. Expected: %v, Got: %v", index, td.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if subjects := read(mr); !assertSliceEq(subjects, td.subjects) {
+			t.Errorf("[Test Case %v] Wrong messages. Expected: %s, Got: %s", index, td.subjects, subjects)
+		}
+
+		cp := mr.Checkpoint()
+		if cp.Offset != int64(len(td.mailbox)) || cp.Messages != td.checkpoint.Messages+len(td.subjects) {
+			t.Errorf("[Test Case %v] Wrong checkpoint. Got: %+v", index, cp)
+		}
+	}
+}
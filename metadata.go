@@ -0,0 +1,226 @@
+package parsemail
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetadataColumn names a column written by MetadataWriter
+type MetadataColumn string
+
+const (
+	ColumnMessageID       MetadataColumn = "message_id"
+	ColumnFrom            MetadataColumn = "from"
+	ColumnTo              MetadataColumn = "to"
+	ColumnCc              MetadataColumn = "cc"
+	ColumnSubject         MetadataColumn = "subject"
+	ColumnDate            MetadataColumn = "date"
+	ColumnSize            MetadataColumn = "size"
+	ColumnTextSize        MetadataColumn = "text_size"
+	ColumnHTMLSize        MetadataColumn = "html_size"
+	ColumnAttachmentCount MetadataColumn = "attachment_count"
+	ColumnAttachmentTypes MetadataColumn = "attachment_types"
+	ColumnAttachmentSize  MetadataColumn = "attachment_size"
+	ColumnAuthResults     MetadataColumn = "auth_results"
+)
+
+// DefaultMetadataColumns are the columns written when none are configured
+var DefaultMetadataColumns = []MetadataColumn{
+	ColumnMessageID,
+	ColumnFrom,
+	ColumnTo,
+	ColumnSubject,
+	ColumnDate,
+	ColumnSize,
+	ColumnAttachmentCount,
+	ColumnAttachmentTypes,
+}
+
+// MetadataWriter writes one row of metadata per email, as CSV preceded by a
+// header row naming the columns, or as a Parquet file. Multi-valued fields
+// are joined with "; ".
+type MetadataWriter struct {
+	w             *csv.Writer
+	parquet       *parquetWriter
+	columns       []MetadataColumn
+	headerWritten bool
+}
+
+// NewMetadataWriter returns a MetadataWriter writing the given columns to w
+// as CSV, or DefaultMetadataColumns when no columns are given.
+func NewMetadataWriter(w io.Writer, columns ...MetadataColumn) *MetadataWriter {
+	if len(columns) == 0 {
+		columns = DefaultMetadataColumns
+	}
+
+	return &MetadataWriter{w: csv.NewWriter(w), columns: columns}
+}
+
+// NewParquetMetadataWriter returns a MetadataWriter writing the given
+// columns to w as an uncompressed Parquet file, or DefaultMetadataColumns
+// when no columns are given. Sizes and counts are INT64 columns, the date
+// an optional timestamp in milliseconds and the others UTF8 strings. Rows
+// are buffered and written in row groups of 65536 rows; the file is only
+// complete after Close.
+func NewParquetMetadataWriter(w io.Writer, columns ...MetadataColumn) *MetadataWriter {
+	if len(columns) == 0 {
+		columns = DefaultMetadataColumns
+	}
+
+	return &MetadataWriter{parquet: newParquetWriter(w, columns), columns: columns}
+}
+
+// Write writes the row of the email
+func (mw *MetadataWriter) Write(e *Email) error {
+	row := make([]interface{}, len(mw.columns))
+	for i, c := range mw.columns {
+		v, err := metadataValue(e, c)
+		if err != nil {
+			return err
+		}
+		row[i] = v
+	}
+
+	if mw.parquet != nil {
+		return mw.parquet.write(row)
+	}
+
+	if !mw.headerWritten {
+		header := make([]string, len(mw.columns))
+		for i, c := range mw.columns {
+			header[i] = string(c)
+		}
+
+		if err := mw.w.Write(header); err != nil {
+			return err
+		}
+		mw.headerWritten = true
+	}
+
+	record := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case string:
+			record[i] = v
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case time.Time:
+			if !v.IsZero() {
+				record[i] = v.UTC().Format(time.RFC3339)
+			}
+		}
+	}
+
+	return mw.w.Write(record)
+}
+
+// Flush writes any buffered data to the underlying io.Writer. Parquet rows
+// are written as a row group.
+func (mw *MetadataWriter) Flush() error {
+	if mw.parquet != nil {
+		return mw.parquet.flush()
+	}
+
+	mw.w.Flush()
+	return mw.w.Error()
+}
+
+// Close flushes the writer and completes a Parquet file with its footer
+func (mw *MetadataWriter) Close() error {
+	if mw.parquet != nil {
+		return mw.parquet.close()
+	}
+
+	return mw.Flush()
+}
+
+// metadataValue returns the value of the column, a string, an int64 or a
+// time that is zero when unknown
+func metadataValue(e *Email, c MetadataColumn) (interface{}, error) {
+	switch c {
+	case ColumnMessageID:
+		return e.MessageID, nil
+	case ColumnFrom:
+		return joinAddresses(e.From), nil
+	case ColumnTo:
+		return joinAddresses(e.To), nil
+	case ColumnCc:
+		return joinAddresses(e.Cc), nil
+	case ColumnSubject:
+		return e.Subject, nil
+	case ColumnDate:
+		return e.Date, nil
+	case ColumnSize:
+		return e.size, nil
+	case ColumnTextSize:
+		return int64(len(e.TextBody)), nil
+	case ColumnHTMLSize:
+		return int64(len(e.HTMLBody)), nil
+	case ColumnAttachmentCount:
+		return int64(len(e.Attachments)), nil
+	case ColumnAttachmentTypes:
+		var types []string
+		for _, a := range e.Attachments {
+			if !containsString(types, a.ContentType) {
+				types = append(types, a.ContentType)
+			}
+		}
+		return strings.Join(types, "; "), nil
+	case ColumnAttachmentSize:
+		size := int64(0)
+		for _, a := range e.Attachments {
+			if l := decodedLen(a.Data); l > 0 {
+				size += l
+			}
+		}
+		return size, nil
+	case ColumnAuthResults:
+		return strings.Join(e.Header["Authentication-Results"], "; "), nil
+	default:
+		return nil, fmt.Errorf("unknown metadata column: %s", c)
+	}
+}
+
+// decodedLen returns the number of unread bytes of r like readerLen, or for
+// attachments read lazily the length of their decoded content, which is
+// decoded without being consumed. It returns -1 if the length is unknown or
+// the content fails to decode.
+func decodedLen(r io.Reader) int64 {
+	l, ok := r.(*lazyReader)
+	if !ok {
+		return int64(readerLen(r))
+	}
+	if b, ok := l.r.(*bytes.Reader); ok {
+		return b.Size()
+	}
+
+	content, err := l.open()
+	if err != nil {
+		return -1
+	}
+
+	n, err := io.Copy(ioutil.Discard, content)
+	if err != nil {
+		return -1
+	}
+
+	return n
+}
+
+func joinAddresses(al []*mail.Address) string {
+	s := make([]string, 0, len(al))
+	for _, a := range al {
+		if a != nil {
+			s = append(s, a.Address)
+		}
+	}
+
+	return strings.Join(s, "; ")
+}
@@ -0,0 +1,216 @@
+package parsemail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetadataWriter(t *testing.T) {
+	var testData = map[int]struct {
+		mailData []string
+		opts     Options
+		columns  []MetadataColumn
+		expected string
+		err      bool
+	}{
+		1: {
+			mailData: []string{rfc5322exampleA12, attachment7bit},
+			columns:  []MetadataColumn{ColumnFrom, ColumnTo, ColumnDate, ColumnAttachmentCount, ColumnAttachmentTypes, ColumnAttachmentSize},
+			expected: "from,to,date,attachment_count,attachment_types,attachment_size\n" +
+				"john.q.public@example.com,mary@x.test; jdoe@example.org; one@y.test,2003-07-01T08:52:37Z,0,,0\n" +
+				"peter.foobar@gmail.com,dusan@kasan.sk,2019-04-02T11:12:26Z,1,application/csv,73\n",
+		},
+		2: {
+			mailData: []string{rfc5322exampleA11},
+			expected: "message_id,from,to,subject,date,size,attachment_count,attachment_types\n" +
+				"1234@local.machine.example,jdoe@machine.example,mary@example.net,Saying Hello,1997-11-21T15:55:06Z,271,0,\n",
+		},
+		3: {
+			mailData: []string{rfc5322exampleA11},
+			columns:  []MetadataColumn{"unknown"},
+			err:      true,
+		},
+		4: {
+			mailData: []string{rfc5322exampleA12, attachment7bit},
+			opts:     Options{LazyAttachments: true},
+			columns:  []MetadataColumn{ColumnAttachmentCount, ColumnAttachmentSize},
+			expected: "attachment_count,attachment_size\n0,0\n1,73\n",
+		},
+	}
+
+	for index, td := range testData {
+		var buf bytes.Buffer
+		mw := NewMetadataWriter(&buf, td.columns...)
+
+		var err error
+		for _, data := range td.mailData {
+			e, perr := ParseWithOptions(strings.NewReader(data), td.opts)
+			if perr != nil {
+				t.Fatal(perr)
+			}
+
+			if err = mw.Write(&e); err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			err = mw.Flush()
+		}
+
+		if td.err {
+			if err == nil {
+				t.Errorf("[Test Case %v] Expected an error", index)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+		} else if buf.String() != td.expected {
+			t.Errorf("[Test Case %v] Wrong output. Expected: %q, Got: %q", index, td.expected, buf.String())
+		}
+	}
+}
+
+func TestParquetMetadataWriter(t *testing.T) {
+	var buf bytes.Buffer
+	mw := NewParquetMetadataWriter(&buf, ColumnSubject, ColumnDate, ColumnAttachmentSize)
+
+	lazy, err := ParseWithOptions(strings.NewReader(attachment7bit), Options{LazyAttachments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	undated, err := Parse(strings.NewReader("From: a@example.org\r\nSubject: No date\r\n\r\nHi\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each flush writes a row group
+	if err := mw.Write(&lazy); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Write(&undated); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the size of lazy attachments is computed without consuming them
+	if data, _ := ioutil.ReadAll(lazy.Attachments[0].Data); len(data) != 73 {
+		t.Errorf("Wrong attachment data. Expected: 73 bytes, Got: %d", len(data))
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatal("Missing Parquet magic number")
+	}
+	footer := data[len(data)-8-int(binary.LittleEndian.Uint32(data[len(data)-8:])) : len(data)-8]
+	meta := readThrift(bytes.NewReader(footer), thriftStruct).(map[int16]interface{})
+
+	if meta[3] != int64(2) {
+		t.Errorf("Wrong number of rows. Expected: 2, Got: %v", meta[3])
+	}
+
+	var names []string
+	for _, e := range meta[2].([]interface{})[1:] {
+		names = append(names, e.(map[int16]interface{})[4].(string))
+	}
+	if expected := []string{"subject", "date", "attachment_size"}; !assertSliceEq(expected, names) {
+		t.Errorf("Wrong schema. Expected: %v, Got: %v", expected, names)
+	}
+
+	var subjects []string
+	var dates, sizes []int64
+	var nulls int
+	for _, g := range meta[4].([]interface{}) {
+		for i, c := range g.(map[int16]interface{})[1].([]interface{}) {
+			md := c.(map[int16]interface{})[3].(map[int16]interface{})
+			r := bytes.NewReader(data[md[9].(int64):])
+			header := readThrift(r, thriftStruct).(map[int16]interface{})
+			page := make([]byte, header[3].(int64))
+			r.Read(page)
+
+			switch i {
+			case 0:
+				n := binary.LittleEndian.Uint32(page)
+				subjects = append(subjects, string(page[4:4+n]))
+			case 1:
+				// a single bit-packed run of definition levels
+				levels := page[4 : 4+binary.LittleEndian.Uint32(page)]
+				if levels[1]&1 == 0 {
+					nulls++
+					continue
+				}
+				dates = append(dates, int64(binary.LittleEndian.Uint64(page[4+len(levels):])))
+			case 2:
+				sizes = append(sizes, int64(binary.LittleEndian.Uint64(page)))
+			}
+		}
+	}
+
+	if expected := []string{"Peter Foobar", "No date"}; !assertSliceEq(expected, subjects) {
+		t.Errorf("Wrong subjects. Expected: %v, Got: %v", expected, subjects)
+	}
+	if len(dates) != 1 || nulls != 1 || dates[0] != lazy.Date.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("Wrong dates. Expected: %v and null, Got: %v and %d nulls", lazy.Date, dates, nulls)
+	}
+	if len(sizes) != 2 || sizes[0] != 73 || sizes[1] != 0 {
+		t.Errorf("Wrong attachment sizes. Expected: [73 0], Got: %v", sizes)
+	}
+}
+
+// readThrift reads a value of the thrift compact protocol, structs as maps
+// of their fields and lists as slices
+func readThrift(r *bytes.Reader, typ byte) interface{} {
+	readInt := func() int64 {
+		u, _ := binary.ReadUvarint(r)
+		return int64(u>>1) ^ -int64(u&1)
+	}
+
+	switch typ {
+	case thriftI32, thriftI64:
+		return readInt()
+	case thriftBinary:
+		n, _ := binary.ReadUvarint(r)
+		b := make([]byte, n)
+		r.Read(b)
+		return string(b)
+	case thriftList:
+		h, _ := r.ReadByte()
+		n := int(h >> 4)
+		if n == 15 {
+			u, _ := binary.ReadUvarint(r)
+			n = int(u)
+		}
+		l := make([]interface{}, n)
+		for i := range l {
+			l[i] = readThrift(r, h&0x0f)
+		}
+		return l
+	case thriftStruct:
+		m := map[int16]interface{}{}
+		for last := int16(0); ; {
+			h, err := r.ReadByte()
+			if err != nil || h == 0 {
+				return m
+			}
+			if h>>4 == 0 {
+				last = int16(readInt())
+			} else {
+				last += int16(h >> 4)
+			}
+			m[last] = readThrift(r, h&0x0f)
+		}
+	}
+
+	return nil
+}
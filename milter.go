@@ -0,0 +1,473 @@
+package parsemail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// MilterAction is the final verdict on a message passed through the milter
+// protocol
+type MilterAction int
+
+const (
+	MilterAccept MilterAction = iota
+	MilterReject
+	MilterTempFail
+	MilterDiscard
+)
+
+// maxMilterPacket bounds the packets read from the MTA
+const maxMilterPacket = 1 << 20
+
+// milter protocol version 6, as spoken by Sendmail 8.14 and Postfix
+const milterVersion = 6
+
+// commands of the MTA
+const (
+	milterOptNeg  = 'O'
+	milterMacro   = 'D'
+	milterConnect = 'C'
+	milterHelo    = 'H'
+	milterMail    = 'M'
+	milterRcpt    = 'R'
+	milterData    = 'T'
+	milterHeader  = 'L'
+	milterEOH     = 'N'
+	milterBody    = 'B'
+	milterEOB     = 'E'
+	milterAbort   = 'A'
+	milterQuit    = 'Q'
+	milterQuitNC  = 'K'
+	milterUnknown = 'U'
+)
+
+// responses and modifications of the filter
+const (
+	milterContinue   = 'c'
+	milterAcceptResp = 'a'
+	milterRejectResp = 'r'
+	milterTempResp   = 't'
+	milterDiscResp   = 'd'
+	milterReplyCode  = 'y'
+	milterAddHeader  = 'h'
+	milterChgHeader  = 'm'
+	milterReplBody   = 'b'
+	milterAddRcpt    = '+'
+	milterDelRcpt    = '-'
+	milterQuarantine = 'q'
+)
+
+// modification actions negotiated with the MTA
+const (
+	milterFlagAddHeaders = 0x01
+	milterFlagChgBody    = 0x02
+	milterFlagAddRcpt    = 0x04
+	milterFlagDelRcpt    = 0x08
+	milterFlagChgHeaders = 0x10
+	milterFlagQuarantine = 0x20
+)
+
+// MilterEnvelope is what the MTA told the filter about the SMTP session of
+// a message
+type MilterEnvelope struct {
+	// Client is the host name of the SMTP client and ClientAddress its IP
+	// address, if known
+	Client        string
+	ClientAddress string
+	Helo          string
+	From          string
+	Recipients    []string
+	// Macros are the macros sent by the MTA, like "i" for the queue id
+	Macros map[string]string
+}
+
+// MilterHeader is a header field added or changed by a MilterDecision.
+// Index counts the fields of the same name from 1 and selects the field
+// to change; an empty Value deletes it.
+type MilterHeader struct {
+	Name  string
+	Value string
+	Index int
+}
+
+// MilterDecision is the answer of a MilterHandler: the final action and
+// the modifications of the message the MTA applies before accepting it
+type MilterDecision struct {
+	Action MilterAction
+	// Reply replaces the SMTP reply of a reject or temporary failure, e.g.
+	// "550 5.7.1 Message refused"
+	Reply string
+
+	AddHeaders    []MilterHeader
+	ChangeHeaders []MilterHeader
+	// Body replaces the body of the message if it isn't nil
+	Body []byte
+
+	AddRecipients    []string
+	DeleteRecipients []string
+	// Quarantine holds the message in the quarantine of the MTA with the
+	// reason, if it isn't empty
+	Quarantine string
+}
+
+// MilterHandler decides on a message received through the milter protocol.
+// err is set when the message failed to parse; e is then incomplete.
+type MilterHandler func(env *MilterEnvelope, e *Email, err error) MilterDecision
+
+// MilterServer exposes the parser to MTAs like Sendmail and Postfix through
+// the milter protocol. The message is collected from the header and body
+// events, parsed with Options once complete and passed to Handler, whose
+// decision is sent back with its modifications. A message growing beyond
+// the MaxMessageBytes of Options is rejected while it is collected, without
+// asking Handler.
+type MilterServer struct {
+	Handler MilterHandler
+	Options Options
+}
+
+// Serve accepts connections from l and serves each in its own goroutine
+// until l fails
+func (s *MilterServer) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn serves a single connection. It returns nil when the MTA quits
+// or closes the connection.
+func (s *MilterServer) ServeConn(conn io.ReadWriter) error {
+	ms := &milterSession{server: s, r: bufio.NewReader(conn), w: conn}
+	ms.reset(true)
+
+	for {
+		cmd, data, err := ms.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		quit, err := ms.handle(cmd, data)
+		if err != nil || quit {
+			return err
+		}
+	}
+}
+
+type milterSession struct {
+	server *MilterServer
+	r      *bufio.Reader
+	w      io.Writer
+
+	// actions are the modifications the MTA allows
+	actions uint32
+
+	env    MilterEnvelope
+	header bytes.Buffer
+	body   bytes.Buffer
+	// tooLarge is set once the message exceeded MaxMessageBytes
+	tooLarge bool
+}
+
+// reset forgets the message, and the connection if conn is set
+func (ms *milterSession) reset(conn bool) {
+	if conn {
+		ms.env = MilterEnvelope{Macros: map[string]string{}}
+	} else {
+		ms.env.From, ms.env.Recipients = "", nil
+	}
+	ms.header.Reset()
+	ms.body.Reset()
+	ms.tooLarge = false
+}
+
+func (ms *milterSession) read() (cmd byte, data []byte, err error) {
+	var n uint32
+	if err = binary.Read(ms.r, binary.BigEndian, &n); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return
+	}
+	if n == 0 || n > maxMilterPacket {
+		return 0, nil, fmt.Errorf("milter: invalid packet length %d", n)
+	}
+
+	packet := make([]byte, n)
+	if _, err = io.ReadFull(ms.r, packet); err != nil {
+		return 0, nil, fmt.Errorf("milter: %v", err)
+	}
+
+	return packet[0], packet[1:], nil
+}
+
+func (ms *milterSession) write(cmd byte, data []byte) error {
+	packet := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(packet, uint32(len(data)+1))
+	packet[4] = cmd
+	_, err := ms.w.Write(append(packet, data...))
+
+	return err
+}
+
+// handle answers a command of the MTA. quit is set when the MTA ends the
+// connection.
+func (ms *milterSession) handle(cmd byte, data []byte) (quit bool, err error) {
+	switch cmd {
+	case milterOptNeg:
+		if len(data) < 12 {
+			return false, errors.New("milter: malformed option negotiation")
+		}
+		version := binary.BigEndian.Uint32(data)
+		if version < 2 {
+			return false, fmt.Errorf("milter: unsupported protocol version %d", version)
+		}
+		if version > milterVersion {
+			version = milterVersion
+		}
+		ms.actions = binary.BigEndian.Uint32(data[4:]) & (milterFlagAddHeaders | milterFlagChgBody |
+			milterFlagAddRcpt | milterFlagDelRcpt | milterFlagChgHeaders | milterFlagQuarantine)
+
+		// all protocol steps are wanted
+		resp := make([]byte, 12)
+		binary.BigEndian.PutUint32(resp, version)
+		binary.BigEndian.PutUint32(resp[4:], ms.actions)
+		return false, ms.write(milterOptNeg, resp)
+
+	case milterMacro:
+		if len(data) > 0 {
+			fields := milterStrings(data[1:])
+			for i := 0; i+1 < len(fields); i += 2 {
+				ms.env.Macros[strings.Trim(fields[i], "{}")] = fields[i+1]
+			}
+		}
+		return false, nil
+
+	case milterConnect:
+		// the host name is followed by the family, the binary port and the
+		// address
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			ms.env.Client = string(data[:i])
+			if rest := data[i+1:]; len(rest) > 3 && (rest[0] == '4' || rest[0] == '6') {
+				if fields := milterStrings(rest[3:]); len(fields) > 0 {
+					ms.env.ClientAddress = strings.TrimPrefix(fields[0], "IPv6:")
+				}
+			}
+		}
+
+	case milterHelo:
+		if fields := milterStrings(data); len(fields) > 0 {
+			ms.env.Helo = fields[0]
+		}
+
+	case milterMail:
+		ms.reset(false)
+		if fields := milterStrings(data); len(fields) > 0 {
+			ms.env.From = strings.Trim(fields[0], "<>")
+		}
+
+	case milterRcpt:
+		if fields := milterStrings(data); len(fields) > 0 {
+			ms.env.Recipients = append(ms.env.Recipients, strings.Trim(fields[0], "<>"))
+		}
+
+	case milterHeader:
+		fields := milterStrings(data)
+		if len(fields) < 2 {
+			return false, errors.New("milter: malformed header")
+		}
+		value := strings.Replace(strings.Replace(fields[1], "\r\n", "\n", -1), "\n", "\r\n", -1)
+		if !ms.collect(&ms.header, []byte(fmt.Sprintf("%s: %s\r\n", fields[0], strings.TrimLeft(value, " ")))) {
+			return false, ms.refuse()
+		}
+
+	case milterBody:
+		if !ms.collect(&ms.body, data) {
+			return false, ms.refuse()
+		}
+
+	case milterEOB:
+		if !ms.collect(&ms.body, data) {
+			err = ms.refuse()
+		} else {
+			err = ms.decide()
+		}
+		ms.reset(false)
+		return false, err
+
+	case milterAbort:
+		ms.reset(false)
+		return false, nil
+
+	case milterQuitNC:
+		ms.reset(true)
+		return false, nil
+
+	case milterQuit:
+		return true, nil
+
+	case milterData, milterEOH, milterUnknown:
+	default:
+		return false, fmt.Errorf("milter: unknown command %q", cmd)
+	}
+
+	return false, ms.write(milterContinue, nil)
+}
+
+// collect appends data to buf unless the message exceeds MaxMessageBytes
+// with it, which drops what was collected. It reports whether the message
+// is still collected.
+func (ms *milterSession) collect(buf *bytes.Buffer, data []byte) bool {
+	max := ms.server.Options.MaxMessageBytes
+	if !ms.tooLarge && max > 0 && int64(ms.header.Len()+ms.body.Len()+len(data)) > max {
+		ms.tooLarge = true
+		ms.header.Reset()
+		ms.body.Reset()
+	}
+	if ms.tooLarge {
+		return false
+	}
+
+	buf.Write(data)
+
+	return true
+}
+
+// refuse rejects a message exceeding MaxMessageBytes
+func (ms *milterSession) refuse() error {
+	return ms.write(milterReplyCode, milterString("552 5.3.4 Message exceeds fixed maximum message size"))
+}
+
+// decide parses the collected message, asks the handler and sends its
+// modifications and action
+func (ms *milterSession) decide() error {
+	raw := append(append(ms.header.Bytes(), "\r\n"...), ms.body.Bytes()...)
+	e, err := ParseWithOptions(bytes.NewReader(raw), ms.server.Options)
+
+	env := ms.env
+	d := ms.server.Handler(&env, &e, err)
+
+	if d.Action == MilterAccept {
+		if err := ms.modify(d); err != nil {
+			return err
+		}
+	}
+
+	switch d.Action {
+	case MilterReject, MilterTempFail:
+		if d.Reply != "" {
+			return ms.write(milterReplyCode, milterString(d.Reply))
+		}
+		if d.Action == MilterReject {
+			return ms.write(milterRejectResp, nil)
+		}
+		return ms.write(milterTempResp, nil)
+	case MilterDiscard:
+		return ms.write(milterDiscResp, nil)
+	}
+
+	return ms.write(milterAcceptResp, nil)
+}
+
+// modify sends the modifications of an accepting decision. Modifications
+// the MTA didn't allow fail.
+func (ms *milterSession) modify(d MilterDecision) error {
+	allowed := func(flag uint32, what string) error {
+		if ms.actions&flag == 0 {
+			return fmt.Errorf("milter: the MTA doesn't allow to %s", what)
+		}
+		return nil
+	}
+
+	for _, h := range d.AddHeaders {
+		if err := allowed(milterFlagAddHeaders, "add header fields"); err != nil {
+			return err
+		}
+		if err := ms.write(milterAddHeader, append(milterString(h.Name), milterString(h.Value)...)); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range d.ChangeHeaders {
+		if err := allowed(milterFlagChgHeaders, "change header fields"); err != nil {
+			return err
+		}
+		index := make([]byte, 4)
+		binary.BigEndian.PutUint32(index, uint32(h.Index))
+		if err := ms.write(milterChgHeader, append(append(index, milterString(h.Name)...), milterString(h.Value)...)); err != nil {
+			return err
+		}
+	}
+
+	if d.Body != nil {
+		if err := allowed(milterFlagChgBody, "replace the body"); err != nil {
+			return err
+		}
+		body := d.Body
+		for {
+			n := minInt(len(body), 65535)
+			if err := ms.write(milterReplBody, body[:n]); err != nil {
+				return err
+			}
+			if body = body[n:]; len(body) == 0 {
+				break
+			}
+		}
+	}
+
+	for _, r := range d.AddRecipients {
+		if err := allowed(milterFlagAddRcpt, "add recipients"); err != nil {
+			return err
+		}
+		if err := ms.write(milterAddRcpt, milterString("<"+r+">")); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range d.DeleteRecipients {
+		if err := allowed(milterFlagDelRcpt, "delete recipients"); err != nil {
+			return err
+		}
+		if err := ms.write(milterDelRcpt, milterString("<"+r+">")); err != nil {
+			return err
+		}
+	}
+
+	if d.Quarantine != "" {
+		if err := allowed(milterFlagQuarantine, "quarantine messages"); err != nil {
+			return err
+		}
+		return ms.write(milterQuarantine, milterString(d.Quarantine))
+	}
+
+	return nil
+}
+
+// milterStrings splits NUL terminated strings
+func milterStrings(data []byte) []string {
+	fields := strings.Split(string(data), "\x00")
+	if len(fields) > 0 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+
+	return fields
+}
+
+func milterString(s string) []byte {
+	return append([]byte(s), 0)
+}
@@ -0,0 +1,226 @@
+package parsemail
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// milterClient plays the MTA side of a milter connection
+type milterClient struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func (c *milterClient) send(cmd byte, fields ...string) {
+	data := []byte{cmd}
+	for _, f := range fields {
+		data = append(data, f...)
+	}
+	packet := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(packet, uint32(len(data)))
+	if _, err := c.conn.Write(append(packet, data...)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *milterClient) recv() (byte, string) {
+	var n uint32
+	if err := binary.Read(c.conn, binary.BigEndian, &n); err != nil {
+		c.t.Fatal(err)
+	}
+	packet := make([]byte, n)
+	if _, err := io.ReadFull(c.conn, packet); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return packet[0], string(packet[1:])
+}
+
+func (c *milterClient) expect(cmd byte) string {
+	got, data := c.recv()
+	if got != cmd {
+		c.t.Fatalf("Wrong response. Expected: %q, Got: %q %q", cmd, got, data)
+	}
+
+	return data
+}
+
+func TestMilterServer(t *testing.T) {
+	var testData = map[int]struct {
+		decision MilterDecision
+		// responses are the packets answering the end of the message
+		responses []string
+	}{
+		1: {
+			decision:  MilterDecision{Action: MilterAccept},
+			responses: []string{"a"},
+		},
+		2: {
+			decision: MilterDecision{
+				Action:        MilterAccept,
+				AddHeaders:    []MilterHeader{{Name: "X-Spam", Value: "no"}},
+				ChangeHeaders: []MilterHeader{{Name: "Subject", Index: 1}},
+				Body:          []byte("Replaced\r\n"),
+				AddRecipients: []string{"archive@example.net"},
+			},
+			responses: []string{"hX-Spam\x00no\x00", "m\x00\x00\x00\x01Subject\x00\x00", "bReplaced\r\n", "+<archive@example.net>\x00", "a"},
+		},
+		3: {
+			decision:  MilterDecision{Action: MilterReject, Reply: "550 5.7.1 Refused"},
+			responses: []string{"y550 5.7.1 Refused\x00"},
+		},
+		4: {
+			decision:  MilterDecision{Action: MilterTempFail},
+			responses: []string{"t"},
+		},
+		5: {
+			decision:  MilterDecision{Action: MilterDiscard, AddHeaders: []MilterHeader{{Name: "X-Ignored", Value: "1"}}},
+			responses: []string{"d"},
+		},
+	}
+
+	for index, td := range testData {
+		var env MilterEnvelope
+		var email Email
+		server := &MilterServer{Handler: func(en *MilterEnvelope, e *Email, err error) MilterDecision {
+			if err != nil {
+				t.Errorf("[Test Case %v] %v", index, err)
+			}
+			env, email = *en, *e
+			return td.decision
+		}}
+
+		mta, filter := net.Pipe()
+		done := make(chan error)
+		go func() { done <- server.ServeConn(filter) }()
+		c := &milterClient{t: t, conn: mta}
+
+		c.send('O', "\x00\x00\x00\x06", "\x00\x00\x01\xff", "\x00\x00\x00\x00")
+		if got := c.expect('O'); got != "\x00\x00\x00\x06\x00\x00\x00\x3f\x00\x00\x00\x00" {
+			t.Errorf("[Test Case %v] Wrong negotiation. Got: %q", index, got)
+		}
+		c.send('D', "C", "{daemon_name}\x00", "mx\x00")
+		c.send('C', "mail.example.org\x00", "4\x00\x19", "192.0.2.1\x00")
+		c.expect('c')
+		c.send('H', "mail.example.org\x00")
+		c.expect('c')
+		c.send('D', "M", "i\x00", "4XyZ\x00")
+		c.send('M', "<alice@example.org>\x00", "SIZE=100\x00")
+		c.expect('c')
+		c.send('R', "<bob@example.net>\x00")
+		c.expect('c')
+		c.send('T')
+		c.expect('c')
+		c.send('L', "From\x00", " Alice <alice@example.org>\x00")
+		c.expect('c')
+		c.send('L', "Subject\x00", "Quarterly\n\treport\x00")
+		c.expect('c')
+		c.send('N')
+		c.expect('c')
+		c.send('B', "Numbers ")
+		c.expect('c')
+		c.send('B', "attached\r\n")
+		c.expect('c')
+		c.send('E')
+		for _, want := range td.responses {
+			cmd, data := c.recv()
+			if got := string(cmd) + data; got != want {
+				t.Errorf("[Test Case %v] Wrong response. Expected: %q, Got: %q", index, want, got)
+			}
+		}
+		c.send('Q')
+		if err := <-done; err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+		}
+		mta.Close()
+
+		if env.Client != "mail.example.org" || env.ClientAddress != "192.0.2.1" || env.Helo != "mail.example.org" {
+			t.Errorf("[Test Case %v] Wrong client. Got: %+v", index, env)
+		}
+		if env.From != "alice@example.org" || len(env.Recipients) != 1 || env.Recipients[0] != "bob@example.net" {
+			t.Errorf("[Test Case %v] Wrong envelope. Got: %+v", index, env)
+		}
+		if env.Macros["i"] != "4XyZ" || env.Macros["daemon_name"] != "mx" {
+			t.Errorf("[Test Case %v] Wrong macros. Got: %v", index, env.Macros)
+		}
+		if email.Subject != "Quarterly report" {
+			t.Errorf("[Test Case %v] Wrong subject. Expected: %s, Got: %s", index, "Quarterly report", email.Subject)
+		}
+		if strings.TrimSpace(email.TextBody) != "Numbers attached" {
+			t.Errorf("[Test Case %v] Wrong text body. Got: %q", index, email.TextBody)
+		}
+	}
+}
+
+func TestMilterServerNotAllowed(t *testing.T) {
+	server := &MilterServer{Handler: func(*MilterEnvelope, *Email, error) MilterDecision {
+		return MilterDecision{Body: []byte("Replaced")}
+	}}
+
+	mta, filter := net.Pipe()
+	defer mta.Close()
+	done := make(chan error)
+	go func() { done <- server.ServeConn(filter) }()
+	c := &milterClient{t: t, conn: mta}
+
+	// the MTA only allows to add header fields
+	c.send('O', "\x00\x00\x00\x06", "\x00\x00\x00\x01", "\x00\x00\x00\x00")
+	c.expect('O')
+	c.send('L', "Subject\x00", "Hi\x00")
+	c.expect('c')
+	c.send('E', "Hi\r\n")
+
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "replace the body") {
+		t.Errorf("Wrong error. Got: %v", err)
+	}
+}
+
+func TestMilterServerTooLarge(t *testing.T) {
+	var subjects []string
+	server := &MilterServer{Options: Options{MaxMessageBytes: 64}, Handler: func(en *MilterEnvelope, e *Email, err error) MilterDecision {
+		subjects = append(subjects, e.Subject)
+		return MilterDecision{Action: MilterAccept}
+	}}
+
+	mta, filter := net.Pipe()
+	defer mta.Close()
+	done := make(chan error)
+	go func() { done <- server.ServeConn(filter) }()
+	c := &milterClient{t: t, conn: mta}
+
+	c.send('O', "\x00\x00\x00\x06", "\x00\x00\x00\x00", "\x00\x00\x00\x00")
+	c.expect('O')
+
+	// the message is rejected with the body chunk exceeding the limit
+	c.send('M', "<alice@example.org>\x00")
+	c.expect('c')
+	c.send('L', "Subject\x00", "Large\x00")
+	c.expect('c')
+	c.send('B', strings.Repeat("x", 32))
+	c.expect('c')
+	c.send('B', strings.Repeat("x", 32))
+	if got := c.expect('y'); !strings.HasPrefix(got, "552 ") {
+		t.Errorf("Wrong reply. Got: %q", got)
+	}
+	c.send('E')
+	c.expect('y')
+
+	// the next message of the connection is collected again
+	c.send('M', "<alice@example.org>\x00")
+	c.expect('c')
+	c.send('L', "Subject\x00", "Small\x00")
+	c.expect('c')
+	c.send('E', "Hi\r\n")
+	c.expect('a')
+
+	c.send('Q')
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if !assertSliceEq([]string{"Small"}, subjects) {
+		t.Errorf("Wrong messages passed to the handler. Expected: [Small], Got: %v", subjects)
+	}
+}
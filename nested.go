@@ -0,0 +1,51 @@
+package parsemail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const FindingNestedMessageFailed FindingCode = "nested-message-failed"
+
+// maxMessageDepth limits the nesting of parsed message/rfc822 attachments
+const maxMessageDepth = 8
+
+// parseChildEmail parses the message of a message/rfc822 attachment with the
+// options of the parser into its ChildEmail. Its parts count against the
+// MaxDepth and MaxParts of the parent, whose parse an exceeded limit fails.
+// A message that can't be parsed otherwise is reported as a warning and
+// stays available as raw data only.
+func (p *parser) parseChildEmail(at *Attachment) error {
+	if at.Data == nil {
+		return nil
+	}
+
+	if p.depth+1 >= maxMessageDepth {
+		p.warn(FindingNestedMessageFailed, fmt.Sprintf("message %q nested deeper than %d levels is not parsed", at.Filename, maxMessageDepth))
+		return nil
+	}
+
+	data, err := at.bytes()
+	if err == nil {
+		var child Email
+		child, err = (&Parser{opts: p.opts}).parse(bytes.NewReader(data), p)
+		if err == nil {
+			at.ChildEmail = &child
+			return nil
+		}
+		if limitError(err) {
+			return err
+		}
+	}
+
+	p.warn(FindingNestedMessageFailed, fmt.Sprintf("parsing message %q: %v", at.Filename, err))
+
+	return nil
+}
+
+// parseEmbedded parses a message embedded in the email, like a forwarded or
+// journaled message, with the options the email was parsed with
+func (e *Email) parseEmbedded(r io.Reader) (Email, error) {
+	return NewParser(e.opts).Parse(r)
+}
@@ -0,0 +1,92 @@
+package parsemail
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestChildEmail(t *testing.T) {
+	e, err := Parse(strings.NewReader(rfc822))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.Attachments) != 1 {
+		t.Fatalf("Wrong number of attachments. Expected: 1, Got: %v", len(e.Attachments))
+	}
+
+	at := e.Attachments[0]
+	if at.ChildEmail == nil {
+		t.Fatal("Attached message was not parsed")
+	}
+
+	if at.ChildEmail.Subject != "Internal Test" || at.ChildEmail.TextBody != "test" {
+		t.Errorf("Wrong child email. Got subject %q and text body %q", at.ChildEmail.Subject, at.ChildEmail.TextBody)
+	}
+
+	data, err := at.bytes()
+	if err != nil || !strings.HasPrefix(string(data), "From: Test Internal <internal@test.lan>") {
+		t.Errorf("Raw data of the attached message is not available: %q, %v", data, err)
+	}
+}
+
+func TestChildEmailDepth(t *testing.T) {
+	message := "Subject: Level 0\n\nInnermost\n"
+	for i := 1; i <= maxMessageDepth+2; i++ {
+		message = fmt.Sprintf("Subject: Level %d\nContent-Type: multipart/mixed; boundary=\"B%d\"\n\n--B%d\nContent-Type: message/rfc822\nContent-Disposition: attachment\n\n%s\n--B%d--\n", i, i, i, message, i)
+	}
+
+	e, err := Parse(strings.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	depth := 0
+	for cur := &e; len(cur.Attachments) > 0 && cur.Attachments[0].ChildEmail != nil; cur = cur.Attachments[0].ChildEmail {
+		depth++
+	}
+
+	if depth != maxMessageDepth-1 {
+		t.Errorf("Wrong depth of parsed messages. Expected: %v, Got: %v", maxMessageDepth-1, depth)
+	}
+}
+
+func TestChildEmailLimits(t *testing.T) {
+	// three parts and a multipart body in the attached message, below two
+	// parts and a multipart body of the outer message
+	const child = "Subject: Inner\nContent-Type: multipart/mixed; boundary=\"inner\"\n\n" +
+		"--inner\nContent-Type: text/plain\n\none\n--inner\nContent-Type: text/plain\n\ntwo\n" +
+		"--inner\nContent-Type: text/plain\n\nthree\n--inner--\n"
+	const message = "Subject: Outer\nContent-Type: multipart/mixed; boundary=\"outer\"\n\n" +
+		"--outer\nContent-Type: text/plain\n\nHello\n" +
+		"--outer\nContent-Type: message/rfc822\nContent-Disposition: attachment\n\n" + child + "\n--outer--\n"
+
+	var testData = map[int]struct {
+		opts   Options
+		option string
+	}{
+		1: {opts: Options{}},
+		2: {opts: Options{MaxParts: 5}},
+		3: {opts: Options{MaxParts: 4}, option: "MaxParts"},
+		4: {opts: Options{MaxDepth: 2}},
+		5: {opts: Options{MaxDepth: 1}, option: "MaxDepth"},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(message), td.opts)
+
+		if td.option == "" {
+			if err != nil {
+				t.Errorf("[Test Case %v] Unexpected error: %v", index, err)
+			} else if len(e.Attachments) != 1 || e.Attachments[0].ChildEmail == nil {
+				t.Errorf("[Test Case %v] Attached message was not parsed", index)
+			}
+			continue
+		}
+
+		if le, ok := err.(*PartLimitError); !ok || le.Option != td.option {
+			t.Errorf("[Test Case %v] Wrong error. Expected: %s exceeded, Got: %v", index, td.option, err)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package parsemail
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// executableExtensions are the file extensions run by a double click on
+// common desktops
+var executableExtensions = []string{
+	".exe", ".scr", ".com", ".pif", ".bat", ".cmd", ".cpl", ".dll", ".msi", ".msp",
+	".vbs", ".vbe", ".js", ".jse", ".wsf", ".wsh", ".hta", ".ps1", ".jar", ".lnk",
+	".reg", ".app", ".sh",
+}
+
+// executableSignatures start Windows, Linux and macOS binaries
+var executableSignatures = [][]byte{
+	[]byte("MZ"),
+	[]byte("\x7fELF"),
+	{0xcf, 0xfa, 0xed, 0xfe},
+	{0xce, 0xfa, 0xed, 0xfe},
+	{0xca, 0xfe, 0xba, 0xbe},
+}
+
+// activeElements are removed from html bodies with their content,
+// formElements without it
+var (
+	activeElements = map[string]bool{
+		"script": true, "noscript": true, "iframe": true, "frame": true, "frameset": true,
+		"object": true, "embed": true, "applet": true, "template": true,
+		"button": true, "select": true, "textarea": true,
+	}
+	formElements = map[string]bool{
+		"form": true, "input": true, "base": true, "meta": true, "link": true,
+	}
+)
+
+// urlAttributes hold URLs loaded by the renderer or followed by a click
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "srcset": true, "background": true, "poster": true,
+	"action": true, "formaction": true, "data": true, "xlink:href": true, "lowsrc": true,
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s<>"'()]+`)
+
+// CSS constructs loading resources
+var (
+	cssImport   = regexp.MustCompile(`(?i)@import\b[^;]*;?`)
+	cssURL      = regexp.MustCompile(`(?i)\burl\(\s*(?:"([^"]*)"|'([^']*)'|([^)\s]*))\s*\)`)
+	cssImageSet = regexp.MustCompile(`(?i)(?:-webkit-)?image-set\(`)
+	cssString   = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+)
+
+// Neutralize writes a copy of the email that is safe to deliver to a user
+// from quarantine. Scripts, event handlers, forms and embedded objects are
+// removed from the html body, links in both bodies are defanged so they are
+// shown but can't be followed, and remote images are blocked, including
+// those of style sheets and style attributes. Executables,
+// Office documents with macros or external content and svg or html files
+// with active content are removed, attached messages are neutralized in
+// turn. The bodies are preceded by a text banner listing what was changed.
+func Neutralize(e *Email) ([]byte, error) {
+	return neutralize(e, 0)
+}
+
+func neutralize(e *Email, depth int) ([]byte, error) {
+	n := *e
+	n.Attachments, n.EmbeddedFiles = nil, nil
+	n.Content, n.ContentType = nil, ""
+
+	var changes []string
+	note := func(count int, format string) {
+		if count > 0 {
+			changes = append(changes, fmt.Sprintf(format, count))
+		}
+	}
+
+	var links int
+	n.TextBody, links = defangLinks(e.TextBody)
+
+	var h neutralizedHTML
+	n.HTMLBody = h.neutralize(e.HTMLBody)
+	if n.TextBody == "" && n.HTMLBody != "" {
+		n.TextBody = htmlToText(n.HTMLBody)
+	}
+
+	note(links+h.links, "%d links were disabled")
+	note(h.images, "%d remote images were blocked")
+	note(h.active, "%d scripts, forms and other active elements were removed")
+	if e.Content != nil && e.TextBody == "" && e.HTMLBody == "" {
+		changes = append(changes, fmt.Sprintf("the %s body was removed", e.ContentType))
+	}
+
+	for i := range e.Attachments {
+		a := e.Attachments[i]
+
+		reason, err := neutralizeAttachment(&a, depth)
+		if err != nil {
+			reason = fmt.Sprintf("it can't be read: %v", err)
+		}
+		if reason != "" {
+			changes = append(changes, fmt.Sprintf("the attachment %q was removed because %s", a.Filename, reason))
+			continue
+		}
+
+		n.Attachments = append(n.Attachments, a)
+	}
+
+	for i := range e.EmbeddedFiles {
+		ef := e.EmbeddedFiles[i]
+
+		data, err := ef.bytes()
+		switch {
+		case err != nil:
+			changes = append(changes, fmt.Sprintf("the embedded file %q was removed because it can't be read: %v", ef.CID, err))
+		case !strings.HasPrefix(ef.ContentType, "image/") || isExecutable(ef.Filename, data) ||
+			isMarkupFile(ef.Filename, ef.ContentType, data) && len(markupFindings("embedded file", ef.CID, data)) > 0:
+			changes = append(changes, fmt.Sprintf("the embedded file %q was removed because it isn't a plain image", ef.CID))
+		default:
+			n.EmbeddedFiles = append(n.EmbeddedFiles, ef)
+		}
+	}
+
+	body, err := n.mimeBody()
+	if err != nil {
+		return nil, err
+	}
+
+	banner := textPart(contentTypeTextPlain, neutralizedBanner(changes))
+	banner.header.Set("Content-Disposition", "inline")
+
+	return serialize(&n, multipartPart("mixed", []mimePart{banner, body}))
+}
+
+// neutralizedBanner explains the changes made by Neutralize
+func neutralizedBanner(changes []string) string {
+	var sb strings.Builder
+	sb.WriteString("This message was neutralized for safe viewing. Links are shown but can't be followed and active content is removed.\n")
+
+	if len(changes) == 0 {
+		sb.WriteString("Nothing had to be changed.\n")
+	}
+	for _, c := range changes {
+		sb.WriteString("- " + strings.ToUpper(c[:1]) + c[1:] + ".\n")
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// neutralizeAttachment returns why an attachment has to be removed.
+// Attached messages are replaced by their neutralized version.
+func neutralizeAttachment(a *Attachment, depth int) (string, error) {
+	data, err := a.bytes()
+	if err != nil {
+		return "", err
+	}
+
+	if isExecutable(a.Filename, data) {
+		return "it is executable", nil
+	}
+
+	if isMarkupFile(a.Filename, a.ContentType, data) && len(markupFindings("attachment", a.Filename, data)) > 0 {
+		return "it contains scripts", nil
+	}
+
+	if findings, err := a.CheckOffice(); err != nil {
+		return "it is an unreadable Office document", nil
+	} else if len(findings) > 0 {
+		return "it contains macros or external content", nil
+	}
+
+	if a.ContentType != messageRFC822 {
+		return "", nil
+	}
+
+	if depth+1 >= maxMessageDepth {
+		return "it nests too many messages", nil
+	}
+
+	child := a.ChildEmail
+	if child == nil {
+		parsed, err := Parse(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		child = &parsed
+	}
+
+	neutralized, err := neutralize(child, depth+1)
+	if err != nil {
+		return "", err
+	}
+	a.Data = bytes.NewReader(neutralized)
+	a.ChildEmail = nil
+
+	return "", nil
+}
+
+// isExecutable reports whether a file is a program by its extension or
+// signature
+func isExecutable(filename string, data []byte) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, e := range executableExtensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(data, sig) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// defangLinks rewrites the URLs of text so they aren't turned into links,
+// "https://evil.example/login" becomes "hxxps://evil[.]example/login"
+func defangLinks(text string) (string, int) {
+	count := 0
+	text = linkPattern.ReplaceAllStringFunc(text, func(u string) string {
+		count++
+		return defangURL(u)
+	})
+
+	return text, count
+}
+
+func defangURL(u string) string {
+	i := strings.Index(u, "://")
+	if i < 0 {
+		return u
+	}
+
+	scheme := strings.Replace(strings.ToLower(u[:i]), "t", "x", -1)
+	rest := u[i+3:]
+	host := rest
+	if end := strings.IndexAny(rest, "/?#"); end >= 0 {
+		host, rest = rest[:end], rest[end:]
+	} else {
+		rest = ""
+	}
+
+	return scheme + "://" + strings.Replace(host, ".", "[.]", -1) + rest
+}
+
+// neutralizedHTML counts the changes made to an html body
+type neutralizedHTML struct {
+	links  int
+	images int
+	active int
+}
+
+// neutralize removes active elements and attributes from an html body,
+// defangs its links and blocks remote images
+func (h *neutralizedHTML) neutralize(body string) string {
+	var sb strings.Builder
+	skip := 0
+	style := false
+	var linkText []string
+
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return sb.String()
+		case html.CommentToken:
+			continue
+		case html.TextToken:
+			if skip == 0 && style {
+				sb.WriteString(h.css(string(z.Raw())))
+			} else if skip == 0 {
+				text, n := defangLinks(string(z.Raw()))
+				h.links += n
+				sb.WriteString(text)
+			}
+			continue
+		case html.DoctypeToken:
+			sb.Write(z.Raw())
+			continue
+		}
+
+		tok := z.Token()
+		if activeElements[tok.Data] {
+			switch tt {
+			case html.StartTagToken:
+				if skip == 0 {
+					h.active++
+				}
+				skip++
+			case html.EndTagToken:
+				if skip > 0 {
+					skip--
+				}
+			case html.SelfClosingTagToken:
+				if skip == 0 {
+					h.active++
+				}
+			}
+			continue
+		}
+		if skip > 0 {
+			continue
+		}
+
+		if formElements[tok.Data] {
+			if tt != html.EndTagToken {
+				h.active++
+			}
+			continue
+		}
+
+		if tok.Data == "style" {
+			style = tt == html.StartTagToken
+		}
+
+		if tok.Data == "a" && tt == html.EndTagToken {
+			if len(linkText) > 0 {
+				sb.WriteString(tok.String())
+				if t := linkText[len(linkText)-1]; t != "" {
+					sb.WriteString(" [" + html.EscapeString(t) + "]")
+				}
+				linkText = linkText[:len(linkText)-1]
+				continue
+			}
+		}
+
+		if tt != html.EndTagToken {
+			link := ""
+			tok.Attr, link = h.attributes(tok.Data, tok.Attr)
+			if tok.Data == "a" && tt == html.StartTagToken {
+				linkText = append(linkText, link)
+			}
+		}
+
+		sb.WriteString(tok.String())
+	}
+}
+
+// attributes removes event handlers and URLs of a tag. The defanged target
+// of a link is returned to be shown after it.
+func (h *neutralizedHTML) attributes(tag string, attrs []html.Attribute) (kept []html.Attribute, link string) {
+	for _, attr := range attrs {
+		key := attr.Key
+		if attr.Namespace != "" {
+			key = attr.Namespace + ":" + key
+		}
+		value := strings.ToLower(strings.TrimSpace(attr.Val))
+
+		switch {
+		case strings.HasPrefix(key, "on"):
+			h.active++
+			continue
+		case key == "style":
+			attr.Val = h.css(attr.Val)
+		case !urlAttributes[key]:
+		case strings.HasPrefix(value, "cid:") || strings.HasPrefix(value, "#"):
+		case (tag == "a" || tag == "area") && key == "href" && strings.HasPrefix(value, "mailto:"):
+		case (tag == "a" || tag == "area") && key == "href":
+			if linkPattern.MatchString(attr.Val) {
+				h.links++
+				link, _ = defangLinks(strings.TrimSpace(attr.Val))
+			} else {
+				h.active++
+			}
+			continue
+		case tag == "img" && key == "src" && strings.HasPrefix(value, "data:image/") && !strings.HasPrefix(value, "data:image/svg"):
+		case strings.HasPrefix(value, "http:") || strings.HasPrefix(value, "https:") || strings.HasPrefix(value, "//"):
+			h.images++
+			continue
+		default:
+			h.active++
+			continue
+		}
+
+		kept = append(kept, attr)
+	}
+
+	return
+}
+
+// css blocks the remote resources of a style sheet or style attribute.
+// @import rules are removed, and url() and image-set() references other
+// than fragments, cid: URLs and data: images become none. CSS with escapes,
+// which could spell these functions, or with an unterminated url() is
+// dropped as a whole.
+func (h *neutralizedHTML) css(css string) string {
+	css = cssComment.ReplaceAllString(css, "")
+	if strings.Contains(css, `\`) {
+		h.images++
+		return ""
+	}
+
+	css = cssImport.ReplaceAllStringFunc(css, func(string) string {
+		h.images++
+		return ""
+	})
+
+	var sb strings.Builder
+	for {
+		loc := cssImageSet.FindStringIndex(css)
+		if loc == nil {
+			sb.WriteString(css)
+			break
+		}
+
+		// the set ends at its closing parenthesis or the end of the css
+		end, depth := len(css), 1
+		for i := loc[1]; i < len(css) && depth > 0; i++ {
+			switch css[i] {
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					end = i + 1
+				}
+			}
+		}
+
+		sb.WriteString(css[:loc[0]])
+		if set := css[loc[0]:end]; cssRemote(set) || depth > 0 {
+			h.images++
+			sb.WriteString("none")
+		} else {
+			sb.WriteString(set)
+		}
+		css = css[end:]
+	}
+	css = sb.String()
+
+	css = cssURL.ReplaceAllStringFunc(css, func(u string) string {
+		if cssRemote(u) {
+			h.images++
+			return "none"
+		}
+		return u
+	})
+	if strings.Count(strings.ToLower(css), "url(") != len(cssURL.FindAllString(css, -1)) {
+		h.images++
+		return ""
+	}
+
+	return css
+}
+
+// cssRemote reports whether the url() references or strings of css refer to
+// anything but fragments, cid: URLs and data: images
+func cssRemote(css string) bool {
+	refs := cssString.FindAllStringSubmatch(css, -1)
+	refs = append(refs, cssURL.FindAllStringSubmatch(css, -1)...)
+	for _, m := range refs {
+		v := strings.ToLower(strings.TrimSpace(strings.Join(m[1:], "")))
+		if !strings.HasPrefix(v, "#") && !strings.HasPrefix(v, "cid:") &&
+			(!strings.HasPrefix(v, "data:image/") || strings.HasPrefix(v, "data:image/svg")) {
+			return true
+		}
+	}
+
+	return false
+}
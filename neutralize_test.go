@@ -0,0 +1,149 @@
+package parsemail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDefangLinks(t *testing.T) {
+	var testData = map[int]struct {
+		text     string
+		expected string
+	}{
+		1: {"see https://evil.example/login?u=1", "see hxxps://evil[.]example/login?u=1"},
+		2: {"HTTP://a.b.example", "hxxp://a[.]b[.]example"},
+		3: {"files at ftp://files.example/x.zip.", "files at fxp://files[.]example/x.zip."},
+		4: {"no links here.", "no links here."},
+	}
+
+	for index, td := range testData {
+		if got, _ := defangLinks(td.text); got != td.expected {
+			t.Errorf("[Test Case %v] Wrong text. Expected: %s, Got: %s", index, td.expected, got)
+		}
+	}
+}
+
+func TestNeutralize(t *testing.T) {
+	e, err := NewEmail().
+		From("Billing <billing@evil.example>").
+		To("user@example.com").
+		Subject("Your invoice").
+		Text("Pay at https://evil.example/pay").
+		HTML(`<p onclick="steal()">Pay <a href="https://evil.example/pay">here</a>`+
+			`<img src="https://tracker.example/p.gif"><script>steal()</script>`+
+			`<form action="https://evil.example/login"><input type="password" name="pwd"></form></p>`).
+		Attach("invoice.pdf", "application/pdf", []byte("%PDF-1.4\n")).
+		Attach("invoice.exe", "application/octet-stream", []byte("MZ\x90\x00")).
+		Attach("scan.svg", "image/svg+xml", []byte(`<svg onload="steal()"/>`)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := Neutralize(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Subject != "Your invoice" {
+		t.Errorf("Wrong subject. Expected: %s, Got: %s", "Your invoice", n.Subject)
+	}
+
+	for _, s := range []string{"This message was neutralized", `"invoice.exe" was removed`, `"scan.svg" was removed`, "hxxps://evil[.]example/pay"} {
+		if !strings.Contains(n.TextBody, s) {
+			t.Errorf("Wrong text body. Expected to contain: %s, Got: %s", s, n.TextBody)
+		}
+	}
+
+	for _, s := range []string{"<script", "onclick", "https://", "<form", "<input"} {
+		if strings.Contains(n.HTMLBody, s) {
+			t.Errorf("Wrong html body. Expected not to contain: %s, Got: %s", s, n.HTMLBody)
+		}
+	}
+	if !strings.Contains(n.HTMLBody, "here</a> [hxxps://evil[.]example/pay]") {
+		t.Errorf("Wrong html body. Expected the defanged link after its text, Got: %s", n.HTMLBody)
+	}
+
+	var names []string
+	for _, a := range n.Attachments {
+		names = append(names, a.Filename)
+	}
+	if !assertSliceEq(names, []string{"invoice.pdf"}) {
+		t.Errorf("Wrong attachments. Expected: %s, Got: %s", []string{"invoice.pdf"}, names)
+	}
+}
+
+func TestNeutralizeAttachedMessage(t *testing.T) {
+	inner := "From: a@example.com\r\nSubject: inner\r\nContent-Type: text/plain\r\n\r\nGo to http://evil.example\r\n"
+
+	e, err := NewEmail().From("b@example.com").Text("see attached").
+		Attach("inner.eml", messageRFC822, []byte(inner)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := Neutralize(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(n.Attachments) != 1 {
+		t.Fatalf("Wrong attachments. Expected: 1, Got: %d", len(n.Attachments))
+	}
+
+	data, _ := n.Attachments[0].bytes()
+	if !bytes.Contains(data, []byte("hxxp://evil[.]example")) || bytes.Contains(data, []byte("http://evil")) {
+		t.Errorf("Wrong attached message. Expected neutralized links, Got: %s", data)
+	}
+}
+
+func TestNeutralizeCSS(t *testing.T) {
+	var testData = map[int]struct {
+		html     string
+		expected string
+		images   int
+	}{
+		1: {
+			html:     `<div style="background:url(https://track.example/p.gif)">Hi</div>`,
+			expected: `<div style="background:none">Hi</div>`,
+			images:   1,
+		},
+		2: {
+			html:     `<style>body{background:url(//track.example/q.gif)}</style>`,
+			expected: `<style>body{background:none}</style>`,
+			images:   1,
+		},
+		3: {
+			html:     `<style>@import url(https://track.example/a.css); p { background: -webkit-image-set("https://track.example/x.png" 1x) }</style>`,
+			expected: `<style> p { background: none }</style>`,
+			images:   2,
+		},
+		4: {
+			html:     `<p style="background:url('cid:logo') , url(data:image/png;base64,AA==)">Hi</p>`,
+			expected: `<p style="background:url(&#39;cid:logo&#39;) , url(data:image/png;base64,AA==)">Hi</p>`,
+		},
+		5: {
+			html:     `<p style="background:u\72l(https://track.example/p.gif)">Hi</p><style>p{background:url(https://track.example/p.gif</style>`,
+			expected: `<p style="">Hi</p><style></style>`,
+			images:   2,
+		},
+	}
+
+	for index, td := range testData {
+		var h neutralizedHTML
+		if got := h.neutralize(td.html); got != td.expected || h.images != td.images {
+			t.Errorf("[Test Case %v] Wrong html. Expected: %s (%d), Got: %s (%d)", index, td.expected, td.images, got, h.images)
+		}
+	}
+}
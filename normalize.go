@@ -0,0 +1,95 @@
+package parsemail
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SubjectForms holds the subject of an email in the form it was sent and in
+// normalized forms for display, matching and indexing
+type SubjectForms struct {
+	// Original is the decoded subject
+	Original string
+	// Visible is the subject without invisible and bidi control characters
+	Visible string
+	// ASCII is the transliterated ASCII fallback of Visible
+	ASCII string
+}
+
+// SubjectForms returns the original and the normalized forms of the subject
+func (e *Email) SubjectForms() SubjectForms {
+	visible := StripInvisible(e.Subject)
+
+	return SubjectForms{
+		Original: e.Subject,
+		Visible:  visible,
+		ASCII:    ToASCII(visible),
+	}
+}
+
+// isInvisible reports whether r is a zero-width or bidi control character,
+// which spoofed subjects and names use to hide or reorder text
+func isInvisible(r rune) bool {
+	switch r {
+	case '\u00ad', // soft hyphen
+		'\u034f',           // combining grapheme joiner
+		'\u180e',           // mongolian vowel separator
+		'\u200b', '\u200c', // zero width space and non-joiner
+		'\u200d',           // zero width joiner
+		'\u2060', '\ufeff': // word joiner, zero width no-break space
+		return true
+	}
+
+	return isBidiControl(r)
+}
+
+// isBidiControl reports whether r is an explicit bidi formatting character:
+// a directional mark, embedding, override or isolate
+func isBidiControl(r rune) bool {
+	return r == '\u061c' || r == '\u200e' || r == '\u200f' ||
+		r >= '\u202a' && r <= '\u202e' || r >= '\u2066' && r <= '\u2069'
+}
+
+// StripInvisible removes zero-width characters and bidi control characters
+// from s
+func StripInvisible(s string) string {
+	return strings.Map(func(r rune) rune {
+		if isInvisible(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// asciiReplacements transliterates the letters and punctuation that don't
+// decompose into ASCII letters with combining marks
+var asciiReplacements = map[rune]string{
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D",
+	'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "TH", 'ı': "i",
+	'‘': "'", '’': "'", '‚': "'", '“': `"`, '”': `"`, '„': `"`,
+	'«': `"`, '»': `"`, '‹': "'", '›': "'", '–': "-", '—': "-",
+	'…': "...", '⁄': "/", '€': "EUR", '£': "GBP", '©': "(c)", '®': "(R)", '™': "(TM)",
+	' ': " ",
+}
+
+// ToASCII transliterates s to ASCII. Accents are removed, letters like "ß"
+// are spelled out and characters without an ASCII form, like emoji, are
+// dropped. Runs of whitespace left behind are collapsed.
+func ToASCII(s string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		switch {
+		case r < unicode.MaxASCII:
+			sb.WriteRune(r)
+		case asciiReplacements[r] != "":
+			sb.WriteString(asciiReplacements[r])
+		case unicode.IsSpace(r):
+			sb.WriteByte(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
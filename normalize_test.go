@@ -0,0 +1,55 @@
+package parsemail
+
+import (
+	"testing"
+)
+
+func TestStripInvisible(t *testing.T) {
+	var testData = map[int]struct {
+		text     string
+		expected string
+	}{
+		1: {"Invoice", "Invoice"},
+		2: {"In\u200bvoi\u200dce", "Invoice"},
+		3: {"Payment \u202egpj.exe\u202c", "Payment gpj.exe"},
+		4: {"\ufeffPass\u00adword \u2066reset\u2069", "Password reset"},
+	}
+
+	for index, td := range testData {
+		if s := StripInvisible(td.text); s != td.expected {
+			t.Errorf("[Test Case %v] Wrong text. Expected: %q, Got: %q", index, td.expected, s)
+		}
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	var testData = map[int]struct {
+		text     string
+		expected string
+	}{
+		1: {"Grüße aus Köln", "Grusse aus Koln"},
+		2: {"Café – “Œuvre” Ørsted…", `Cafe - "OEuvre" Orsted...`},
+		3: {"🎉 Party 🎉 tonight", "Party tonight"},
+		4: {"ﬁle №1 ½", "file No1 1/2"},
+		5: {"日本語", ""},
+	}
+
+	for index, td := range testData {
+		if s := ToASCII(td.text); s != td.expected {
+			t.Errorf("[Test Case %v] Wrong text. Expected: %q, Got: %q", index, td.expected, s)
+		}
+	}
+}
+
+func TestSubjectForms(t *testing.T) {
+	e := Email{Subject: "Rechnung\u200b für \u202eFDP.exe"}
+
+	expected := SubjectForms{
+		Original: "Rechnung\u200b für \u202eFDP.exe",
+		Visible:  "Rechnung für FDP.exe",
+		ASCII:    "Rechnung fur FDP.exe",
+	}
+	if forms := e.SubjectForms(); forms != expected {
+		t.Errorf("Wrong subject forms. Expected: %+q, Got: %+q", expected, forms)
+	}
+}
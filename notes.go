@@ -0,0 +1,112 @@
+package parsemail
+
+import (
+	"bytes"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+const FindingNotesRepaired FindingCode = "notes-repaired"
+
+var (
+	// notesOpenBoundary matches a quoted boundary parameter missing its
+	// closing quote
+	notesOpenBoundary = regexp.MustCompile(`(?i)(boundary="[^"\r\n]*)(\r?\n)`)
+	notesBoundary     = regexp.MustCompile(`(?i)boundary="?([^";\r\n]+)"?`)
+)
+
+// repairNotesMIME fixes the malformed MIME structures Lotus Notes gateways
+// are known to produce: boundary parameters with an unterminated quote,
+// boundaries declared in a different case or spacing than used in the body
+// and multipart bodies missing their closing delimiter.
+func repairNotesMIME(raw []byte) ([]byte, []Finding) {
+	var repairs []Finding
+	repair := func(format string, args ...interface{}) {
+		repairs = append(repairs, Finding{Code: FindingNotesRepaired, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if notesOpenBoundary.Match(raw) {
+		raw = notesOpenBoundary.ReplaceAll(raw, []byte(`$1"$2`))
+		repair("closed the quote of a boundary parameter")
+	}
+
+	var boundaries []string
+	for _, m := range notesBoundary.FindAllSubmatch(raw, -1) {
+		declared := string(m[1])
+		if containsString(boundaries, declared) {
+			continue
+		}
+
+		used := usedBoundary(raw, declared)
+		if used != "" && used != declared {
+			raw = bytes.Replace(raw, m[0], bytes.Replace(m[0], m[1], []byte(used), 1), -1)
+			repair("boundary %q is used as %q", declared, used)
+		}
+
+		if used == "" {
+			used = declared
+		}
+		boundaries = append(boundaries, used)
+	}
+
+	nl := "\n"
+	if bytes.Contains(raw, []byte("\r\n")) {
+		nl = "\r\n"
+	}
+
+	// close the innermost multipart first
+	for i := len(boundaries) - 1; i >= 0; i-- {
+		b := boundaries[i]
+		if bytes.Contains(raw, []byte("\n--"+b+"\n")) || bytes.Contains(raw, []byte("\n--"+b+"\r\n")) {
+			if !bytes.Contains(raw, []byte("\n--"+b+"--")) {
+				if !bytes.HasSuffix(raw, []byte("\n")) {
+					raw = append(raw, nl...)
+				}
+				raw = append(raw, "--"+b+"--"+nl...)
+				repair("added the closing delimiter of boundary %q", b)
+			}
+		}
+	}
+
+	return raw, repairs
+}
+
+// usedBoundary returns the delimiter of the body matching the declared
+// boundary, ignoring case and surrounding spaces, or "" if there is none
+func usedBoundary(raw []byte, declared string) string {
+	want := strings.TrimSpace(declared)
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("--")) {
+			continue
+		}
+
+		candidate := strings.TrimSuffix(strings.TrimSpace(string(line[2:])), "--")
+		if candidate == declared {
+			return declared
+		}
+
+		if strings.EqualFold(strings.TrimSpace(candidate), want) {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// isNotesRichText reports whether a part holds Notes rich text, which only
+// Notes clients can render
+func isNotesRichText(header textproto.MIMEHeader) bool {
+	contentType := strings.ToLower(header.Get("Content-Type"))
+
+	return strings.HasPrefix(contentType, "application/vnd.lotus-notes") ||
+		strings.HasPrefix(contentType, "application/x-notes") ||
+		strings.HasPrefix(contentType, "text/x-notes")
+}
+
+// KeepPrivate reports whether a message exported from Lotus Notes was marked
+// to prevent copying, forwarding and printing with the $KeepPrivate item.
+func (e *Email) KeepPrivate() bool {
+	return strings.TrimSpace(e.Header.Get("$KeepPrivate")) == "1"
+}
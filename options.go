@@ -0,0 +1,617 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	cs "golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// Options configures ParseWithOptions. The zero value applies no limits but
+// the default MaxDepth.
+type Options struct {
+	// MaxDecodedRatio stops decoding a part once its decoded size exceeds
+	// this multiple of its encoded size
+	MaxDecodedRatio float64
+	// MaxDecodedSize stops decoding a part once its decoded size exceeds
+	// this number of bytes
+	MaxDecodedSize int64
+
+	// MaxPartBytes is the budget of encoded bytes processed per part. The
+	// rest of a part exceeding it is skipped.
+	MaxPartBytes int64
+	// MaxMessageBytes is the budget of bytes of the message body processed
+	// in total. Parsing stops once it is exceeded.
+	MaxMessageBytes int64
+	// MaxParseTime is the budget of time spent parsing the body. Parsing
+	// stops once it is exceeded.
+	MaxParseTime time.Duration
+
+	// MaxDepth limits the nesting of multipart bodies, which stops the
+	// recursion on maliciously nested messages. 0 selects 64 levels, or no
+	// limit for schema versions before 6.
+	MaxDepth int
+	// MaxParts limits the number of parts of the multipart bodies of a
+	// message
+	MaxParts int
+
+	// MaxBodyLength caps the length in bytes of TextBody and HTMLBody. The
+	// rest of a longer body can be read with TextBodyRemainder and
+	// HTMLBodyRemainder.
+	MaxBodyLength int
+
+	// SkipPart is called with the header of every part of a multipart body
+	// before it is decoded and decides whether the part is decoded, skipped
+	// or only indexed
+	SkipPart func(header textproto.MIMEHeader) SkipDecision
+
+	// AllowContentTypes, when not empty, drops the attachments and embedded
+	// files whose content type matches none of its patterns, like
+	// "application/pdf" or "image/*"
+	AllowContentTypes []string
+	// DenyContentTypes drops the attachments and embedded files whose
+	// content type matches one of its patterns
+	DenyContentTypes []string
+	// MinImageSize drops image attachments and embedded files smaller than
+	// this number of bytes, like tracking pixels
+	MinImageSize int
+
+	// Extractors produce the ExtractedText of attachments during the parse
+	Extractors *ExtractorRegistry
+	// ImageText produces the ExtractedText of image attachments and embedded
+	// files of at least MinImageTextSize decoded bytes that no extractor is
+	// registered for, like an OCR engine for screenshots
+	ImageText        TextExtractor
+	MinImageTextSize int
+
+	// Tagger sets the Tags of parsed emails by its rules
+	Tagger *Tagger
+
+	// NotesQuirks repairs the malformed MIME structures emitted by Lotus
+	// Notes gateways and drops Notes rich text parts. Repairs are listed in
+	// the warnings of the email.
+	NotesQuirks bool
+
+	// Differential parses the message a second time with a minimal pipeline
+	// of net/mail and the mime packages and reports where the bodies or the
+	// number of files differ as parse-divergence warnings. It is meant for
+	// triaging messages that parse differently than expected, and doubles
+	// the parse time.
+	Differential bool
+
+	// KeepRaw keeps the original message in the email, which Raw, DecodePart,
+	// Root, PrepareResend, the remainders of truncated bodies and verifying
+	// DKIM and ARC signatures need. Without it only the header is kept and the
+	// body is parsed as it is read.
+	KeepRaw bool
+
+	// CharsetFallback is the charset of text parts whose Content-Type names
+	// no charset or one that isn't supported, like "windows-1252". Without
+	// it the charset is guessed from the content.
+	CharsetFallback string
+
+	// LazyAttachments keeps the content of attachments and embedded files
+	// encoded until their Data is first read, which saves decoding them when
+	// only the header and bodies are used. Errors of decoding them, like
+	// exceeding MaxDecodedSize, are returned by the reads, and MinImageSize
+	// doesn't apply to them.
+	LazyAttachments bool
+
+	// Strict makes parsing fail on malformed address lists and dates in the
+	// header, which are otherwise left empty, on malformed parts of
+	// multipart bodies, which are otherwise skipped with a part-failed
+	// warning, and on text parts in a charset that isn't supported without
+	// CharsetFallback, which are otherwise decoded with a sniffed charset
+	Strict bool
+
+	// TolerateUnknownEncoding passes the content of parts with an unknown
+	// Content-Transfer-Encoding through undecoded with an
+	// unknown-transfer-encoding warning, instead of failing the parse
+	TolerateUnknownEncoding bool
+
+	// SchemaVersion parses with the semantics of an earlier SchemaVersion,
+	// so results archived by an earlier version of this package can be
+	// reproduced. 0 selects the current version; later versions than the
+	// current fail to parse.
+	SchemaVersion int
+
+	// SMIMEKey decrypts S/MIME encrypted messages (application/pkcs7-mime
+	// enveloped data) addressed to SMIMECertificate, or to any recipient if
+	// it is nil. The decrypted MIME entity is parsed in place of the
+	// encrypted body. Messages that can't be decrypted keep their encrypted
+	// Content and get a warning.
+	SMIMECertificate *x509.Certificate
+	SMIMEKey         crypto.Decrypter
+}
+
+// SkipDecision tells the parser what to do with a part, see Options.SkipPart
+type SkipDecision int
+
+const (
+	// PartDecode decodes the part as usual
+	PartDecode SkipDecision = iota
+	// PartSkip ignores the part, nested parts included
+	PartSkip
+	// PartIndex records the part as an attachment without decoding it. The
+	// Data of the attachment is nil.
+	PartIndex
+)
+
+const (
+	FindingPartBudgetExceeded    FindingCode = "part-budget-exceeded"
+	FindingMessageBudgetExceeded FindingCode = "message-budget-exceeded"
+	FindingPartDropped           FindingCode = "part-dropped"
+	FindingUnknownEncoding       FindingCode = "unknown-transfer-encoding"
+	FindingPartFailed            FindingCode = "part-failed"
+)
+
+// errBudgetExceeded stops reading when a budget of the options is exhausted
+var errBudgetExceeded = errors.New("parse budget exceeded")
+
+// DecodedSizeError is returned when decoding a part was stopped because it
+// exceeded MaxDecodedRatio or MaxDecodedSize
+type DecodedSizeError struct {
+	EncodedSize int64
+	DecodedSize int64
+}
+
+func (e *DecodedSizeError) Error() string {
+	return fmt.Sprintf("decoded size of part exceeds limit: %d bytes decoded from %d bytes", e.DecodedSize, e.EncodedSize)
+}
+
+// defaultMaxDepth is the MaxDepth of options that don't set it, since schema
+// version 6
+const defaultMaxDepth = 64
+
+// PartLimitError is returned when a message exceeds the MaxDepth or the
+// MaxParts of the options
+type PartLimitError struct {
+	// Option is "MaxDepth" or "MaxParts"
+	Option string
+	Limit  int
+}
+
+func (e *PartLimitError) Error() string {
+	return fmt.Sprintf("message exceeds %s of %d", e.Option, e.Limit)
+}
+
+// maxDepth returns the MaxDepth of the options, or 0 for no limit
+func (p *parser) maxDepth() int {
+	if p.opts.MaxDepth != 0 {
+		return p.opts.MaxDepth
+	}
+	if p.email != nil && p.email.SchemaVersion < 6 {
+		return 0
+	}
+
+	return defaultMaxDepth
+}
+
+// enterMultipart counts a multipart body about to be parsed against
+// MaxDepth. leaveMultipart is called once it is done.
+func (p *parser) enterMultipart() error {
+	if max := p.maxDepth(); max > 0 && p.nesting >= max {
+		return &PartLimitError{Option: "MaxDepth", Limit: max}
+	}
+	p.nesting++
+
+	return nil
+}
+
+func (p *parser) leaveMultipart() {
+	p.nesting--
+}
+
+// limitError reports whether err is the error of an exceeded size, depth or
+// part limit, which fails the whole parse
+func limitError(err error) bool {
+	switch err.(type) {
+	case *DecodedSizeError, *PartLimitError:
+		return true
+	}
+
+	return false
+}
+
+// countPart counts a part of a multipart body against MaxParts
+func (p *parser) countPart() error {
+	p.parts++
+	if p.opts.MaxParts > 0 && p.parts > p.opts.MaxParts {
+		return &PartLimitError{Option: "MaxParts", Limit: p.opts.MaxParts}
+	}
+
+	return nil
+}
+
+// decodeContent undoes the transfer encoding of a part like decodeContent,
+// stopping with a DecodedSizeError when the limits of the options are
+// exceeded. When a budget is exhausted the content decoded so far is
+// returned and a warning is added to the email.
+func (p *parser) decodeContent(content io.Reader, encoding string) (io.Reader, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := p.decodeInto(buf, content, encoding); err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(append([]byte(nil), buf.Bytes()...)), nil
+}
+
+// fileContent returns the content of an attachment or embedded file, decoded
+// right away or, with LazyAttachments, when it is first read
+func (p *parser) fileContent(content io.Reader, encoding string) (io.Reader, error) {
+	if !p.opts.LazyAttachments {
+		return p.decodeContent(content, encoding)
+	}
+
+	// an unknown encoding still fails the parse
+	encoding, err := p.transferEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	encoded := &countingReader{r: &partBudgetReader{r: content, p: p}}
+	_, err = buf.ReadFrom(encoded)
+	if err := p.partError(err, encoded); err != nil {
+		return nil, err
+	}
+
+	return &lazyReader{encoded: append([]byte(nil), buf.Bytes()...), encoding: encoding, opts: p.opts}, nil
+}
+
+// lazyReader undoes the transfer encoding of its content once it is read,
+// enforcing the decoded size limits of the options
+type lazyReader struct {
+	encoded  []byte
+	encoding string
+	opts     Options
+	r        io.Reader
+}
+
+// open returns a reader decoding the content from its start
+func (l *lazyReader) open() (io.Reader, error) {
+	encoded := &countingReader{r: bytes.NewReader(l.encoded)}
+	decoded, err := decoder(encoded, l.encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sizeGuard{r: decoded, encoded: encoded, opts: l.opts}, nil
+}
+
+func (l *lazyReader) Read(b []byte) (int, error) {
+	if l.r == nil {
+		r, err := l.open()
+		if err != nil {
+			return 0, err
+		}
+		l.r = r
+	}
+
+	return l.r.Read(b)
+}
+
+// Seek decodes the whole content into a buffer the first time, see
+// bufferedReader
+func (l *lazyReader) Seek(offset int64, whence int) (int64, error) {
+	b, err := bufferedReader(&l.r, l.open)
+	if err != nil {
+		return 0, err
+	}
+
+	return b.Seek(offset, whence)
+}
+
+// decodeInto writes the decoded content to buf, see decodeContent
+func (p *parser) decodeInto(buf *bytes.Buffer, content io.Reader, encoding string) error {
+	r, encoded, err := p.partReader(content, encoding)
+	if err != nil {
+		return err
+	}
+
+	_, err = buf.ReadFrom(r)
+
+	return p.partError(err, encoded)
+}
+
+// partReader returns a reader undoing the transfer encoding of a part that
+// enforces the limits and budgets of the options, and the counter of the
+// encoded bytes it consumes
+func (p *parser) partReader(content io.Reader, encoding string) (io.Reader, *countingReader, error) {
+	encoding, err := p.transferEncoding(encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encoded := &countingReader{r: &partBudgetReader{r: content, p: p}}
+	decoded, err := decoder(encoded, encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &sizeGuard{r: decoded, encoded: encoded, opts: p.opts}, encoded, nil
+}
+
+// recoverPart turns the error of a part of a multipart body into a
+// part-failed warning, so the rest of the message is still parsed. Strict
+// parses, schema versions before 3 and exceeded size, depth and part limits
+// still fail.
+func (p *parser) recoverPart(err error) error {
+	if err == nil || p.opts.Strict || p.email.SchemaVersion < 3 {
+		return err
+	}
+	if limitError(err) {
+		return err
+	}
+
+	p.warn(FindingPartFailed, err.Error())
+
+	return nil
+}
+
+// transferEncoding returns the transfer encoding to decode a part with. An
+// unknown encoding fails unless TolerateUnknownEncoding is set, which decodes
+// the part as binary. Schema versions before 6 don't know the binary and
+// uuencode encodings.
+func (p *parser) transferEncoding(encoding string) (string, error) {
+	_, err := decoder(bytes.NewReader(nil), encoding)
+	if err == nil && p.email.SchemaVersion < 6 && isLegacyEncoding(encoding) {
+		err = &ParseError{Err: ErrUnknownEncoding, Value: strings.ToLower(encoding)}
+	}
+	if err != nil && p.opts.TolerateUnknownEncoding {
+		p.warn(FindingUnknownEncoding, "passed through part with unknown encoding "+encoding)
+		return "binary", nil
+	}
+
+	return encoding, err
+}
+
+// isLegacyEncoding reports whether encoding is one of the transfer encodings
+// of legacy gateways decoded since schema version 6
+func isLegacyEncoding(encoding string) bool {
+	switch strings.ToLower(encoding) {
+	case "binary", "x-uuencode", "uuencode", "x-uue":
+		return true
+	}
+
+	return false
+}
+
+// partError turns the error of reading a part into a warning if a budget is
+// exhausted, so the content read so far is kept
+func (p *parser) partError(err error, encoded *countingReader) error {
+	if err == errBudgetExceeded {
+		p.warn(FindingPartBudgetExceeded, fmt.Sprintf("part truncated after %d bytes", encoded.n))
+	} else if err != nil && !p.exhausted {
+		return err
+	}
+
+	return nil
+}
+
+// skip applies the NotesQuirks and SkipPart options to part and reports
+// whether the part is done with
+func (p *parser) skip(part *multipart.Part) bool {
+	if p.opts.NotesQuirks && isNotesRichText(part.Header) {
+		p.warn(FindingNotesRepaired, "dropped notes rich text part "+part.Header.Get("Content-Type"))
+		return true
+	}
+
+	if p.opts.SkipPart == nil {
+		return false
+	}
+
+	switch p.opts.SkipPart(part.Header) {
+	case PartSkip:
+		return true
+	case PartIndex:
+		p.addAttachment(attachmentInfo(part, p.email.SchemaVersion))
+		return true
+	}
+
+	return false
+}
+
+// startBudget wraps the message body in a reader enforcing the message
+// budgets of the options
+func (p *parser) startBudget(body io.Reader) io.Reader {
+	if p.opts.MaxParseTime > 0 && p.deadline.IsZero() {
+		p.deadline = time.Now().Add(p.opts.MaxParseTime)
+	}
+
+	if p.opts.MaxMessageBytes <= 0 && p.deadline.IsZero() {
+		return body
+	}
+
+	return &messageBudgetReader{r: body, p: p}
+}
+
+// overBudget reports whether the time budget is exhausted
+func (p *parser) overBudget() bool {
+	return !p.deadline.IsZero() && time.Now().After(p.deadline)
+}
+
+// exhaust marks the message budget as exhausted
+func (p *parser) exhaust(reason string) {
+	if !p.exhausted {
+		p.exhausted = true
+		p.warn(FindingMessageBudgetExceeded, reason)
+	}
+}
+
+func (p *parser) warn(code FindingCode, message string) {
+	p.email.Warnings = append(p.email.Warnings, Finding{Code: code, Message: message})
+}
+
+// messageBudgetReader stops reading the message body once the message
+// budgets are exhausted
+type messageBudgetReader struct {
+	r io.Reader
+	p *parser
+	n int64
+}
+
+func (m *messageBudgetReader) Read(b []byte) (int, error) {
+	if m.p.exhausted {
+		return 0, errBudgetExceeded
+	}
+
+	if m.p.overBudget() {
+		m.p.exhaust(fmt.Sprintf("parsing stopped after %s", m.p.opts.MaxParseTime))
+		return 0, errBudgetExceeded
+	}
+
+	if max := m.p.opts.MaxMessageBytes; max > 0 {
+		if m.n >= max {
+			m.p.exhaust(fmt.Sprintf("parsing stopped after %d bytes", m.n))
+			return 0, errBudgetExceeded
+		}
+
+		if int64(len(b)) > max-m.n {
+			b = b[:max-m.n]
+		}
+	}
+
+	n, err := m.r.Read(b)
+	m.n += int64(n)
+
+	return n, err
+}
+
+// partBudgetReader stops reading a part once the part budget or the time
+// budget is exhausted
+type partBudgetReader struct {
+	r io.Reader
+	p *parser
+	n int64
+}
+
+func (pb *partBudgetReader) Read(b []byte) (int, error) {
+	if pb.p.overBudget() {
+		pb.p.exhaust(fmt.Sprintf("parsing stopped after %s", pb.p.opts.MaxParseTime))
+		return 0, errBudgetExceeded
+	}
+
+	if max := pb.p.opts.MaxPartBytes; max > 0 {
+		if pb.n >= max {
+			return 0, errBudgetExceeded
+		}
+
+		if int64(len(b)) > max-pb.n {
+			b = b[:max-pb.n]
+		}
+	}
+
+	n, err := pb.r.Read(b)
+	pb.n += int64(n)
+
+	return n, err
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// sizeGuard fails reading from the decoded reader r once the decoded size
+// exceeds the limits relative to the bytes consumed from encoded
+type sizeGuard struct {
+	r       io.Reader
+	encoded *countingReader
+	opts    Options
+	n       int64
+}
+
+func (g *sizeGuard) Read(b []byte) (int, error) {
+	n, err := g.r.Read(b)
+	g.n += int64(n)
+
+	if (g.opts.MaxDecodedSize > 0 && g.n > g.opts.MaxDecodedSize) ||
+		(g.opts.MaxDecodedRatio > 0 && float64(g.n) > g.opts.MaxDecodedRatio*float64(g.encoded.n)) {
+		return n, &DecodedSizeError{EncodedSize: g.encoded.n, DecodedSize: g.n}
+	}
+
+	return n, err
+}
+
+// textEncoding returns the encoding of a text part with the given beginning
+// and Content-Type, applying the CharsetFallback of the options. Without it,
+// strict parses fail on a charset that isn't supported.
+func (p *parser) textEncoding(preview []byte, contentType string) (encoding.Encoding, error) {
+	_, params, _ := mime.ParseMediaType(contentType)
+	if enc, _ := cs.Lookup(params["charset"]); enc == nil {
+		if enc, _ := cs.Lookup(p.opts.CharsetFallback); enc != nil {
+			return enc, nil
+		}
+		if p.opts.Strict && params["charset"] != "" {
+			return nil, &ParseError{Err: ErrUnsupportedCharset, Value: params["charset"]}
+		}
+	}
+
+	enc, _, _ := cs.DetermineEncoding(preview, contentType)
+	return enc, nil
+}
+
+// strictAddressFields are checked by checkHeaderFields
+var strictAddressFields = []string{
+	"From", "Sender", "Reply-To", "To", "Cc", "Bcc", "Mail-Reply-To",
+	"Mail-Followup-To", "Resent-From", "Resent-Sender", "Resent-To",
+	"Resent-Cc", "Resent-Bcc",
+}
+
+// checkHeaderFields returns an error for the first malformed address list or
+// date of the header, see Options.Strict
+func checkHeaderFields(header mail.Header) error {
+	for _, name := range strictAddressFields {
+		value := header.Get(name)
+		if strings.Trim(value, " \n") == "" {
+			continue
+		}
+		if _, err := mail.ParseAddressList(value); err != nil {
+			return fmt.Errorf("invalid %s header: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"Date", "Resent-Date"} {
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+
+		valid := false
+		for _, format := range dateFormats {
+			if _, err := time.Parse(format, value); err == nil {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid %s header: %q", name, value)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,447 @@
+package parsemail
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodedSizeLimits(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		opts     Options
+		err      bool
+	}{
+		1: {latin1Attachment, Options{}, false},
+		2: {latin1Attachment, Options{MaxDecodedSize: 1024}, false},
+		3: {latin1Attachment, Options{MaxDecodedSize: 10}, true},
+		4: {latin1Attachment, Options{MaxDecodedRatio: 1}, false},
+		5: {latin1Attachment, Options{MaxDecodedRatio: 0.5}, true},
+	}
+
+	for index, td := range testData {
+		_, err := ParseWithOptions(strings.NewReader(td.mailData), td.opts)
+		if !td.err {
+			if err != nil {
+				t.Errorf("[Test Case %v] Unexpected error: %v", index, err)
+			}
+			continue
+		}
+
+		if _, ok := err.(*DecodedSizeError); !ok {
+			t.Errorf("[Test Case %v] Expected a DecodedSizeError, Got: %v", index, err)
+		}
+	}
+}
+
+func TestParseBudgets(t *testing.T) {
+	var testData = map[int]struct {
+		opts     Options
+		warning  FindingCode
+		textBody string
+	}{
+		1: {Options{}, "", "See attachment."},
+		2: {Options{MaxPartBytes: 5}, FindingPartBudgetExceeded, "See a"},
+		3: {Options{MaxMessageBytes: 120}, FindingMessageBudgetExceeded, "See attachment."},
+		4: {Options{MaxParseTime: time.Nanosecond}, FindingMessageBudgetExceeded, ""},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(latin1Attachment), td.opts)
+		if err != nil {
+			t.Errorf("[Test Case %v] Unexpected error: %v", index, err)
+			continue
+		}
+
+		if e.TextBody != td.textBody {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, td.textBody, e.TextBody)
+		}
+
+		if td.warning == "" {
+			if len(e.Warnings) != 0 {
+				t.Errorf("[Test Case %v] Unexpected warnings: %v", index, e.Warnings)
+			}
+			continue
+		}
+
+		if len(e.Warnings) == 0 || e.Warnings[0].Code != td.warning {
+			t.Errorf("[Test Case %v] Wrong warnings. Expected: %s, Got: %v", index, td.warning, e.Warnings)
+		}
+	}
+}
+
+func TestSkipPart(t *testing.T) {
+	var decided []string
+	opts := Options{
+		SkipPart: func(header textproto.MIMEHeader) SkipDecision {
+			contentType := strings.Split(header.Get("Content-Type"), ";")[0]
+			decided = append(decided, contentType)
+
+			switch contentType {
+			case "application/octet-stream":
+				return PartSkip
+			case "text/csv":
+				return PartIndex
+			}
+
+			return PartDecode
+		},
+	}
+
+	e, err := ParseWithOptions(strings.NewReader(latin1Attachment), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"text/plain", "text/csv", "application/octet-stream"}; !assertSliceEq(decided, expected) {
+		t.Errorf("Wrong parts passed to SkipPart. Expected: %s, Got: %s", expected, decided)
+	}
+
+	if e.TextBody != "See attachment." {
+		t.Errorf("Wrong text body. Expected: %s, Got: %s", "See attachment.", e.TextBody)
+	}
+
+	if len(e.Attachments) != 1 {
+		t.Fatalf("Incorrect number of attachments! Expected: 1, Got: %v.", len(e.Attachments))
+	}
+
+	at := e.Attachments[0]
+	if at.Filename != "report.csv" || at.ContentType != "text/csv" || at.Params["charset"] != "iso-8859-1" || at.Data != nil {
+		t.Errorf("Wrong indexed attachment: %+v", at)
+	}
+}
+
+func TestCharsetFallback(t *testing.T) {
+	var testData = map[int]struct {
+		contentType string
+		opts        Options
+		text        string
+	}{
+		1: {"text/plain", Options{}, "Ïðèâåò"},
+		2: {"text/plain", Options{CharsetFallback: "windows-1251"}, "Привет"},
+		3: {"text/plain; charset=x-unknown", Options{CharsetFallback: "windows-1251"}, "Привет"},
+		4: {"text/plain; charset=iso-8859-1", Options{CharsetFallback: "windows-1251"}, "Ïðèâåò"},
+	}
+
+	for index, td := range testData {
+		mailData := "From: a@example.com\nContent-Type: " + td.contentType + "\n\n\xcf\xf0\xe8\xe2\xe5\xf2\n"
+
+		e, err := ParseWithOptions(strings.NewReader(mailData), td.opts)
+		if err != nil {
+			t.Errorf("[Test Case %v] Unexpected error: %v", index, err)
+			continue
+		}
+
+		if e.TextBody != td.text {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, td.text, e.TextBody)
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	var testData = map[int]struct {
+		header string
+		err    bool
+	}{
+		1: {"From: John Doe <jdoe@machine.example>\nDate: Fri, 21 Nov 1997 09:55:06 -0600\n", false},
+		2: {"From: John Doe <jdoe@machine.example\n", true},
+		3: {"From: jdoe@machine.example\nCc: Mary, Smith <mary@example.net>\n", true},
+		4: {"From: jdoe@machine.example\nDate: yesterday\n", true},
+	}
+
+	for index, td := range testData {
+		mailData := td.header + "Subject: Test\n\nHello\n"
+
+		if _, err := Parse(strings.NewReader(mailData)); err != nil {
+			t.Errorf("[Test Case %v] Unexpected error without strict: %v", index, err)
+		}
+
+		_, err := ParseWithOptions(strings.NewReader(mailData), Options{Strict: true})
+		if td.err != (err != nil) {
+			t.Errorf("[Test Case %v] Wrong strict error. Expected error: %v, Got: %v", index, td.err, err)
+		}
+	}
+}
+
+func TestLazyAttachments(t *testing.T) {
+	eager, err := Parse(strings.NewReader(filteredParts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lazy, err := ParseWithOptions(strings.NewReader(filteredParts), Options{LazyAttachments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lazy.Attachments) != len(eager.Attachments) || len(lazy.EmbeddedFiles) != len(eager.EmbeddedFiles) {
+		t.Fatalf("Wrong number of files. Expected: %v, %v, Got: %v, %v", len(eager.Attachments), len(eager.EmbeddedFiles), len(lazy.Attachments), len(lazy.EmbeddedFiles))
+	}
+
+	for i := range lazy.Attachments {
+		if _, ok := lazy.Attachments[i].Data.(*lazyReader); !ok {
+			t.Errorf("[Attachment %v] Data was decoded while parsing", i)
+		}
+
+		expected, _ := eager.Attachments[i].bytes()
+		if data, err := lazy.Attachments[i].bytes(); err != nil || string(data) != string(expected) {
+			t.Errorf("[Attachment %v] Wrong data. Expected: %q, Got: %q, %v", i, expected, data, err)
+		}
+	}
+
+	for i := range lazy.EmbeddedFiles {
+		expected, _ := eager.EmbeddedFiles[i].bytes()
+		if data, err := lazy.EmbeddedFiles[i].bytes(); err != nil || string(data) != string(expected) {
+			t.Errorf("[Embedded File %v] Wrong data. Expected: %q, Got: %q, %v", i, expected, data, err)
+		}
+	}
+
+	// limits are enforced when the data is read
+	e, err := ParseWithOptions(strings.NewReader(latin1Attachment), Options{LazyAttachments: true, MaxDecodedSize: 16})
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing: %v", err)
+	}
+	if _, err := e.Attachments[0].bytes(); err == nil {
+		t.Error("Expected a DecodedSizeError reading the attachment")
+	} else if _, ok := err.(*DecodedSizeError); !ok {
+		t.Errorf("Expected a DecodedSizeError, Got: %v", err)
+	}
+}
+
+func TestKeepRaw(t *testing.T) {
+	e, err := Parse(strings.NewReader(filteredParts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the header is kept
+	header, _ := splitHeader([]byte(filteredParts))
+	if string(e.raw) != string(header) || e.Raw() != nil {
+		t.Errorf("Wrong raw message. Expected: %q, Got: %q, %q", header, e.raw, e.Raw())
+	}
+	if e.size != int64(len(filteredParts)) {
+		t.Errorf("Wrong size. Expected: %d, Got: %d", len(filteredParts), e.size)
+	}
+	if _, err := e.DecodePart("1"); err != ErrRawNotKept {
+		t.Errorf("Wrong DecodePart error. Expected: %v, Got: %v", ErrRawNotKept, err)
+	}
+	if _, err := e.Root(); err != ErrRawNotKept {
+		t.Errorf("Wrong Root error. Expected: %v, Got: %v", ErrRawNotKept, err)
+	}
+	if _, err := e.PrepareResend(ResendOptions{}); err != ErrRawNotKept {
+		t.Errorf("Wrong PrepareResend error. Expected: %v, Got: %v", ErrRawNotKept, err)
+	}
+
+	kept, err := ParseWithOptions(strings.NewReader(filteredParts), Options{KeepRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(kept.Raw()) != filteredParts || kept.size != e.size {
+		t.Errorf("Wrong raw message. Expected: %q, Got: %q", filteredParts, kept.Raw())
+	}
+	if kept.TextBody != e.TextBody || kept.HTMLBody != e.HTMLBody || len(kept.Attachments) != len(e.Attachments) {
+		t.Errorf("Wrong email. Expected: %q, %q, Got: %q, %q", e.TextBody, e.HTMLBody, kept.TextBody, kept.HTMLBody)
+	}
+
+	// reports are read from the parsed parts without the raw message
+	for _, version := range []int{4, SchemaVersion} {
+		rawReport, _ := ParseWithOptions(strings.NewReader(reportFailed), Options{SchemaVersion: version, KeepRaw: true})
+		parsedReport, _ := ParseWithOptions(strings.NewReader(reportFailed), Options{SchemaVersion: version})
+
+		expected, err := rawReport.DeliveryReport()
+		if err != nil || expected == nil {
+			t.Fatalf("[Version %v] Expected a delivery report. Got: %v", version, err)
+		}
+		got, err := parsedReport.DeliveryReport()
+		if err != nil || got == nil || got.Kind != expected.Kind || len(got.Recipients) != len(expected.Recipients) {
+			t.Errorf("[Version %v] Wrong delivery report. Expected: %+v, Got: %+v, %v", version, expected, got, err)
+		}
+	}
+}
+
+func TestTolerateUnknownEncoding(t *testing.T) {
+	mailData := "From: a@example.com\r\nSubject: Test\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain\r\nContent-Transfer-Encoding: 7-bit\r\n\r\nHello\r\n" +
+		"--b\r\nContent-Type: application/octet-stream\r\nContent-Disposition: attachment; filename=a.bin\r\nContent-Transfer-Encoding: x-gzip64\r\n\r\nH4sIAAAA\r\n" +
+		"--b\r\nContent-Type: application/octet-stream\r\nContent-Disposition: attachment; filename=b.bin\r\nContent-Transfer-Encoding: base64\r\n\r\nSGk=\r\n--b--\r\n"
+
+	if _, err := ParseWithOptions(strings.NewReader(mailData), Options{Strict: true}); err == nil {
+		t.Error("Unknown encoding parsed strictly without TolerateUnknownEncoding")
+	}
+
+	for _, lazy := range []bool{false, true} {
+		e, err := ParseWithOptions(strings.NewReader(mailData), Options{TolerateUnknownEncoding: true, LazyAttachments: lazy})
+		if err != nil {
+			t.Fatalf("[Lazy %v] %v", lazy, err)
+		}
+
+		if strings.TrimSpace(e.TextBody) != "Hello" {
+			t.Errorf("[Lazy %v] Wrong text body. Expected: %s, Got: %q", lazy, "Hello", e.TextBody)
+		}
+
+		if len(e.Attachments) != 2 {
+			t.Fatalf("[Lazy %v] Wrong number of attachments. Expected: %v, Got: %v", lazy, 2, len(e.Attachments))
+		}
+		if data, _ := e.Attachments[0].bytes(); string(data) != "H4sIAAAA" {
+			t.Errorf("[Lazy %v] Wrong raw attachment. Expected: %s, Got: %q", lazy, "H4sIAAAA", data)
+		}
+		if data, _ := e.Attachments[1].bytes(); string(data) != "Hi" {
+			t.Errorf("[Lazy %v] Wrong decoded attachment. Expected: %s, Got: %q", lazy, "Hi", data)
+		}
+
+		var warnings int
+		for _, w := range e.Warnings {
+			if w.Code == FindingUnknownEncoding {
+				warnings++
+			}
+		}
+		if warnings != 2 {
+			t.Errorf("[Lazy %v] Wrong number of warnings. Expected: %v, Got: %v", lazy, 2, e.Warnings)
+		}
+	}
+}
+
+func TestPartialResults(t *testing.T) {
+	var testData = map[int]struct {
+		body        string
+		textBody    string
+		attachments int
+		warnings    int
+	}{
+		1: {
+			body: "--b\r\nContent-Type: text/plain\r\n\r\nHello\r\n" +
+				"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=a.pdf\r\nContent-Transfer-Encoding: base64\r\n\r\n!!not base64!!\r\n" +
+				"--b\r\nContent-Type: application/zip\r\nContent-Disposition: attachment; filename=b.zip\r\nContent-Transfer-Encoding: base64\r\n\r\nUEsDBA==\r\n--b--\r\n",
+			textBody:    "Hello",
+			attachments: 1,
+			warnings:    1,
+		},
+		2: {
+			body: "--b\r\nContent-Type: text/plain; charset=\"utf-8\r\n\r\nBroken\r\n" +
+				"--b\r\nContent-Type: multipart/alternative; boundary=c\r\n\r\n--c\r\nContent-Type: text/plain\r\n\r\nNested\r\n--c--\r\n--b--\r\n",
+			textBody: "Nested",
+			warnings: 1,
+		},
+		3: {
+			// the message was cut off in its second part
+			body:     "--b\r\nContent-Type: text/plain\r\n\r\nHello\r\n--b\r\nContent-Type: text/plain\r\n\r\nCut",
+			textBody: "Hello",
+			// the cut part and the missing closing boundary
+			warnings: 2,
+		},
+	}
+
+	for index, td := range testData {
+		mailData := "From: a@example.com\r\nSubject: Test\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" + td.body
+
+		if _, err := ParseWithOptions(strings.NewReader(mailData), Options{Strict: true}); err == nil {
+			t.Errorf("[Test Case %v] Malformed part parsed strictly", index)
+		}
+		if _, err := ParseWithOptions(strings.NewReader(mailData), Options{SchemaVersion: 2}); err == nil {
+			t.Errorf("[Test Case %v] Malformed part parsed with schema version 2", index)
+		}
+
+		e, err := Parse(strings.NewReader(mailData))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if e.Subject != "Test" || strings.TrimSpace(e.TextBody) != td.textBody {
+			t.Errorf("[Test Case %v] Wrong result. Expected: %s, Got: %s, %q", index, td.textBody, e.Subject, e.TextBody)
+		}
+		if len(e.Attachments) != td.attachments {
+			t.Errorf("[Test Case %v] Wrong number of attachments. Expected: %v, Got: %v", index, td.attachments, len(e.Attachments))
+		}
+
+		var warnings int
+		for _, w := range e.Warnings {
+			if w.Code == FindingPartFailed {
+				warnings++
+			}
+		}
+		if warnings != td.warnings {
+			t.Errorf("[Test Case %v] Wrong warnings. Expected: %v part-failed, Got: %v", index, td.warnings, e.Warnings)
+		}
+	}
+}
+
+func TestPartLimits(t *testing.T) {
+	// nested builds a message of multipart/mixed bodies nested depth levels
+	// deep, each with n text parts
+	nested := func(depth, n int) string {
+		body := "Content-Type: text/plain\r\n\r\nHello\r\n"
+		for d := depth; d > 0; d-- {
+			b := fmt.Sprintf("b%d", d)
+			inner := body
+			body = "Content-Type: multipart/mixed; boundary=" + b + "\r\n\r\n"
+			for i := 0; i < n; i++ {
+				body += "--" + b + "\r\nContent-Type: text/plain\r\n\r\nPart\r\n"
+			}
+			body += "--" + b + "\r\n" + inner + "--" + b + "--\r\n"
+		}
+		return "From: a@example.com\r\nSubject: Test\r\n" + body
+	}
+
+	var testData = map[int]struct {
+		mailData string
+		opts     Options
+		option   string
+		limit    int
+	}{
+		1: {
+			mailData: nested(3, 0),
+			opts:     Options{MaxDepth: 3},
+		},
+		2: {
+			mailData: nested(4, 0),
+			opts:     Options{MaxDepth: 3},
+			option:   "MaxDepth",
+			limit:    3,
+		},
+		3: {
+			mailData: nested(defaultMaxDepth+1, 0),
+			option:   "MaxDepth",
+			limit:    defaultMaxDepth,
+		},
+		4: {
+			mailData: nested(2, 4),
+			opts:     Options{MaxParts: 10},
+		},
+		5: {
+			mailData: nested(2, 5),
+			opts:     Options{MaxParts: 10},
+			option:   "MaxParts",
+			limit:    10,
+		},
+		// schema versions before 6 have no default depth limit
+		6: {
+			mailData: nested(defaultMaxDepth+1, 0),
+			opts:     Options{SchemaVersion: 5},
+		},
+	}
+
+	for index, td := range testData {
+		_, err := ParseWithOptions(strings.NewReader(td.mailData), td.opts)
+		serr := NewParser(td.opts).ParseStream(strings.NewReader(td.mailData), &recordingHandler{})
+
+		for _, err := range []error{err, serr} {
+			if td.option == "" {
+				if err != nil {
+					t.Errorf("[Test Case %v] %v", index, err)
+				}
+				continue
+			}
+
+			le, ok := err.(*PartLimitError)
+			if !ok {
+				t.Errorf("[Test Case %v] Expected a PartLimitError, Got: %v", index, err)
+				continue
+			}
+			if le.Option != td.option || le.Limit != td.limit {
+				t.Errorf("[Test Case %v] Wrong limit. Expected: %s %d, Got: %s %d", index, td.option, td.limit, le.Option, le.Limit)
+			}
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package parsemail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// Parquet physical and converted types, repetitions, encodings and thrift
+// compact protocol types (parquet.thrift)
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+
+	// parquetRowGroupRows is the number of rows buffered before they are
+	// written as a row group
+	parquetRowGroupRows = 1 << 16
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn is a column of a Parquet file and the values of the row
+// group being buffered. Values are strings, int64s or times, a zero time is
+// null.
+type parquetColumn struct {
+	name      string
+	ptype     int32
+	converted int32
+	optional  bool
+	values    []interface{}
+	chunks    []parquetChunk
+}
+
+// parquetChunk is a written column chunk
+type parquetChunk struct {
+	offset, size int64
+	values       int
+}
+
+// parquetWriter writes a Parquet file with a single data page per column and
+// row group, plain encoded and uncompressed
+type parquetWriter struct {
+	w       io.Writer
+	offset  int64
+	columns []*parquetColumn
+	rows    int
+	groups  []int
+}
+
+func newParquetWriter(w io.Writer, columns []MetadataColumn) *parquetWriter {
+	pw := &parquetWriter{w: w}
+	for _, c := range columns {
+		col := &parquetColumn{name: string(c), ptype: parquetByteArray, converted: parquetUTF8}
+		switch c {
+		case ColumnSize, ColumnTextSize, ColumnHTMLSize, ColumnAttachmentCount, ColumnAttachmentSize:
+			col.ptype, col.converted = parquetInt64, -1
+		case ColumnDate:
+			col.ptype, col.converted, col.optional = parquetInt64, parquetTimestampMillis, true
+		}
+		pw.columns = append(pw.columns, col)
+	}
+
+	return pw
+}
+
+// write buffers a row, writing a row group once enough rows are buffered
+func (pw *parquetWriter) write(row []interface{}) error {
+	for i, v := range row {
+		pw.columns[i].values = append(pw.columns[i].values, v)
+	}
+	pw.rows++
+
+	if pw.rows >= parquetRowGroupRows {
+		return pw.flush()
+	}
+
+	return nil
+}
+
+func (pw *parquetWriter) emit(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+
+	return err
+}
+
+// start writes the magic number the file starts with
+func (pw *parquetWriter) start() error {
+	if pw.offset > 0 {
+		return nil
+	}
+
+	return pw.emit(parquetMagic)
+}
+
+// flush writes the buffered rows as a row group
+func (pw *parquetWriter) flush() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	if err := pw.start(); err != nil {
+		return err
+	}
+
+	for _, col := range pw.columns {
+		var page bytes.Buffer
+		if col.optional {
+			levels := parquetLevels(col.values)
+			binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+			page.Write(levels)
+		}
+
+		for _, v := range col.values {
+			switch v := v.(type) {
+			case string:
+				binary.Write(&page, binary.LittleEndian, uint32(len(v)))
+				page.WriteString(v)
+			case int64:
+				binary.Write(&page, binary.LittleEndian, v)
+			case time.Time:
+				if !v.IsZero() {
+					binary.Write(&page, binary.LittleEndian, v.UnixNano()/int64(time.Millisecond))
+				}
+			}
+		}
+
+		var header thriftWriter
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(page.Len()))
+		header.begin(5)
+		header.i32(1, int32(len(col.values)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.buf.WriteByte(0)
+
+		chunk := parquetChunk{offset: pw.offset, size: int64(header.buf.Len() + page.Len()), values: len(col.values)}
+		if err := pw.emit(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := pw.emit(page.Bytes()); err != nil {
+			return err
+		}
+
+		col.chunks = append(col.chunks, chunk)
+		col.values = col.values[:0]
+	}
+
+	pw.groups = append(pw.groups, pw.rows)
+	pw.rows = 0
+
+	return nil
+}
+
+// close writes the buffered rows and the footer describing the row groups
+func (pw *parquetWriter) close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+	if err := pw.start(); err != nil {
+		return err
+	}
+
+	rows := 0
+	for _, n := range pw.groups {
+		rows += n
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(pw.columns)+1)
+	meta.push()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(pw.columns)))
+	meta.end()
+	for _, col := range pw.columns {
+		repetition := int32(parquetRequired)
+		if col.optional {
+			repetition = parquetOptional
+		}
+
+		meta.push()
+		meta.i32(1, col.ptype)
+		meta.i32(3, repetition)
+		meta.binary(4, col.name)
+		if col.converted >= 0 {
+			meta.i32(6, col.converted)
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(rows))
+
+	meta.list(4, thriftStruct, len(pw.groups))
+	for g, n := range pw.groups {
+		total := int64(0)
+		for _, col := range pw.columns {
+			total += col.chunks[g].size
+		}
+
+		meta.push()
+		meta.list(1, thriftStruct, len(pw.columns))
+		for _, col := range pw.columns {
+			chunk := col.chunks[g]
+
+			meta.push()
+			meta.i64(2, chunk.offset)
+			meta.begin(3)
+			meta.i32(1, col.ptype)
+			meta.list(2, thriftI32, 2)
+			meta.varint(zigzag(parquetPlain))
+			meta.varint(zigzag(parquetRLE))
+			meta.list(3, thriftBinary, 1)
+			meta.varint(uint64(len(col.name)))
+			meta.buf.WriteString(col.name)
+			meta.i32(4, 0)
+			meta.i64(5, int64(chunk.values))
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.end()
+			meta.end()
+		}
+		meta.i64(2, total)
+		meta.i64(3, int64(n))
+		meta.end()
+	}
+	meta.binary(6, "parsemail")
+	meta.buf.WriteByte(0)
+
+	binary.Write(&meta.buf, binary.LittleEndian, uint32(meta.buf.Len()))
+	meta.buf.Write(parquetMagic)
+
+	return pw.emit(meta.buf.Bytes())
+}
+
+// parquetLevels returns the definition levels of an optional column, a
+// single bit-packed run of the RLE/bit-packing hybrid encoding
+func parquetLevels(values []interface{}) []byte {
+	groups := (len(values) + 7) / 8
+	b := make([]byte, binary.MaxVarintLen64)
+	b = b[:binary.PutUvarint(b, uint64(groups)<<1|1)]
+
+	packed := make([]byte, groups)
+	for i, v := range values {
+		if t, ok := v.(time.Time); !ok || !t.IsZero() {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+
+	return append(b, packed...)
+}
+
+// thriftWriter writes structs in the thrift compact protocol
+type thriftWriter struct {
+	buf   bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (tw *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	tw.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1 ^ v>>63)
+}
+
+func (tw *thriftWriter) field(id int16, typ byte) {
+	if delta := id - tw.last; delta > 0 && delta <= 15 {
+		tw.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		tw.buf.WriteByte(typ)
+		tw.varint(zigzag(int64(id)))
+	}
+	tw.last = id
+}
+
+func (tw *thriftWriter) i32(id int16, v int32) {
+	tw.field(id, thriftI32)
+	tw.varint(zigzag(int64(v)))
+}
+
+func (tw *thriftWriter) i64(id int16, v int64) {
+	tw.field(id, thriftI64)
+	tw.varint(zigzag(v))
+}
+
+func (tw *thriftWriter) binary(id int16, s string) {
+	tw.field(id, thriftBinary)
+	tw.varint(uint64(len(s)))
+	tw.buf.WriteString(s)
+}
+
+// list starts the list field id of n elements, which are written without
+// field headers
+func (tw *thriftWriter) list(id int16, elem byte, n int) {
+	tw.field(id, thriftList)
+	if n < 15 {
+		tw.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		tw.buf.WriteByte(0xf0 | elem)
+		tw.varint(uint64(n))
+	}
+}
+
+// begin starts the struct field id
+func (tw *thriftWriter) begin(id int16) {
+	tw.field(id, thriftStruct)
+	tw.push()
+}
+
+// push starts a struct, like an element of a list
+func (tw *thriftWriter) push() {
+	tw.stack = append(tw.stack, tw.last)
+	tw.last = 0
+}
+
+// end ends the struct started last
+func (tw *thriftWriter) end() {
+	tw.buf.WriteByte(0)
+	tw.last = tw.stack[len(tw.stack)-1]
+	tw.stack = tw.stack[:len(tw.stack)-1]
+}
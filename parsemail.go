@@ -1,6 +1,7 @@
 package parsemail
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/base64"
 	"fmt"
@@ -10,10 +11,13 @@ import (
 	"mime/multipart"
 	"mime/quotedprintable"
 	"net/mail"
+	"net/textproto"
+	"path/filepath"
 	"strings"
 	"time"
 
 	cs "golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
 )
 
 const contentTypeMultipartMixed = "multipart/mixed"
@@ -22,58 +26,224 @@ const contentTypeMultipartAppleDouble = "multipart/appledouble"
 const messageRFC822 = "message/rfc822"
 const contentTypeMultipartSigned = "multipart/signed"
 const contentTypeMultipartRelated = "multipart/related"
+const contentTypeMultipartFormData = "multipart/form-data"
+const contentTypeMultipartReport = "multipart/report"
 const contentTypeTextHtml = "text/html"
 const contentTypeTextPlain = "text/plain"
 
 // Parse an email message read from io.Reader into parsemail.Email struct
 func Parse(r io.Reader) (email Email, err error) {
-	msg, err := mail.ReadMessage(r)
+	return ParseWithOptions(r, Options{})
+}
+
+// ParseWithOptions parses an email message like Parse, applying the limits
+// set in opts
+func ParseWithOptions(r io.Reader, opts Options) (email Email, err error) {
+	return NewParser(opts).Parse(r)
+}
+
+// Parser parses email messages with a fixed set of options. The decode
+// buffers are shared between parses, so a single Parser can serve many
+// messages, also concurrently.
+type Parser struct {
+	opts Options
+}
+
+// NewParser returns a Parser applying opts
+func NewParser(opts Options) *Parser {
+	return &Parser{opts: opts}
+}
+
+// Parse an email message read from io.Reader into parsemail.Email struct
+func (ps *Parser) Parse(r io.Reader) (email Email, err error) {
+	return ps.parse(r, nil)
+}
+
+// parse parses a message. The message of a message/rfc822 attachment is
+// parsed with the parser of its parent, whose depth, part counts and time
+// budget it continues.
+func (ps *Parser) parse(r io.Reader, parent *parser) (email Email, err error) {
+	opts := ps.opts
+
+	version, err := opts.schemaVersion()
+	if err != nil {
+		return
+	}
+
+	// the whole message is only buffered when it is kept or has to be
+	// repaired, decrypted or compared, otherwise just the header is
+	var raw, header []byte
+	var counter *countingReader
+	var src io.Reader
+	var repairs []Finding
+	decrypted := false
+
+	if opts.KeepRaw || opts.NotesQuirks || opts.SMIMEKey != nil || opts.Differential {
+		buf := getBuffer()
+		_, err = buf.ReadFrom(r)
+		raw = append([]byte(nil), buf.Bytes()...)
+		putBuffer(buf)
+		if err != nil {
+			return
+		}
+
+		if opts.NotesQuirks {
+			raw, repairs = repairNotesMIME(raw)
+		}
+
+		if opts.SMIMEKey != nil {
+			var warning *Finding
+			dp := &parser{email: &Email{SchemaVersion: version}, opts: opts}
+			raw, decrypted, warning = dp.decryptMessage(raw)
+			if warning != nil {
+				repairs = append(repairs, *warning)
+			}
+		}
+
+		header, _ = splitHeader(raw)
+		src = bytes.NewReader(raw)
+	} else {
+		counter = &countingReader{r: r}
+		br := bufio.NewReader(counter)
+		if header, err = readHeaderBytes(br); err != nil {
+			return
+		}
+
+		src = io.MultiReader(bytes.NewReader(header), br)
+	}
+
+	msg, err := mail.ReadMessage(src)
 	if err != nil {
 		return
 	}
 
-	email, err = createEmailFromHeader(msg.Header)
+	email, err = createEmailFromHeader(msg.Header, version)
 	if err != nil {
 		return
 	}
 
+	if opts.Strict {
+		if err = checkHeaderFields(msg.Header); err != nil {
+			return
+		}
+	}
+
+	email.raw = raw
+	if !opts.KeepRaw {
+		email.raw = append([]byte(nil), header...)
+	}
+	email.opts = opts
+	email.Warnings = repairs
+	email.Decrypted = decrypted
+
 	email.ContentType = msg.Header.Get("Content-Type")
 	contentType, params, err := parseContentType(email.ContentType)
 	if err != nil {
 		return
 	}
 
+	if raw == nil && contentType == contentTypeMultipartSigned {
+		// the signature covers the raw bytes of the signed part
+		var rest []byte
+		if rest, err = ioutil.ReadAll(msg.Body); err != nil {
+			return
+		}
+		raw = append(append(raw, header...), rest...)
+		msg.Body = bytes.NewReader(rest)
+	}
+
 	encoding := strings.ToLower(msg.Header.Get("Content-Transfer-Encoding"))
 
+	p := parser{email: &email, opts: opts}
+	if parent != nil {
+		p.depth = parent.depth + 1
+		p.nesting, p.parts, p.deadline = parent.nesting, parent.parts, parent.deadline
+		defer func() { parent.parts = p.parts }()
+	}
+	body := p.startBudget(msg.Body)
+
 	switch contentType {
 	case contentTypeMultipartMixed, contentTypeMultipartSigned:
-		email.TextBody, email.HTMLBody, email.Attachments, email.EmbeddedFiles, err = parseMultipartMixed(msg.Body, params["boundary"])
+		err = p.parseMultipartMixed(body, params["boundary"])
 	case contentTypeMultipartAlternative:
-		email.TextBody, email.HTMLBody, email.Attachments, email.EmbeddedFiles, err = parseMultipartAlternative(msg.Body, params["boundary"])
+		err = p.parseMultipartAlternative(body, params["boundary"])
 	case contentTypeMultipartRelated:
-		email.TextBody, email.HTMLBody, email.Attachments, email.EmbeddedFiles, err = parseMultipartRelated(msg.Body, params["boundary"])
+		err = p.parseMultipartRelated(body, params["boundary"], params["start"], params["type"])
+	case contentTypeMultipartFormData:
+		err = p.parseMultipartFormData(body, params["boundary"])
+	case contentTypeMultipartReport:
+		if version < 5 {
+			email.Content, err = p.decodeContent(body, encoding)
+			if err == nil {
+				email.OriginalMessage = p.reportOriginalMessage(email.Content, params["boundary"])
+			}
+		} else {
+			err = p.parseMultipartReport(body, params["boundary"])
+		}
 	case contentTypeTextPlain:
 		var message []byte
-		message, err = readAllDecode(msg.Body, encoding, email.ContentType)
-		email.TextBody = strings.TrimSuffix(string(message[:]), "\n")
+		message, err = p.readAllDecode(body, encoding, email.ContentType)
+		// legacy systems without MIME embed files uuencoded in the text
+		if version >= 6 && msg.Header.Get("MIME-Version") == "" && msg.Header.Get("Content-Type") == "" {
+			message = p.extractUUEncoded(message)
+		}
+		p.addTextBody(message, textproto.MIMEHeader(msg.Header))
 	case contentTypeTextHtml:
 		var message []byte
-		message, err = readAllDecode(msg.Body, encoding, email.ContentType)
-		email.HTMLBody = strings.TrimSuffix(string(message[:]), "\n")
+		message, err = p.readAllDecode(body, encoding, email.ContentType)
+		p.addHTMLBody(message, textproto.MIMEHeader(msg.Header))
+	case contentTypeTextCalendar:
+		if version < 7 {
+			email.Content, err = p.decodeContent(body, encoding)
+			break
+		}
+		var message []byte
+		message, err = p.readAllDecode(body, encoding, email.ContentType)
+		email.Calendars = append(email.Calendars, parseCalendar(message, params["method"]))
 	default:
-		email.Content, err = decodeContent(msg.Body, encoding)
+		email.Content, err = p.decodeContent(body, encoding)
+	}
+
+	if counter == nil {
+		email.size = int64(len(raw))
+	} else {
+		if !p.exhausted {
+			// the size of the message includes what the parts left unread
+			io.Copy(ioutil.Discard, msg.Body)
+		}
+		email.size = counter.n
+	}
+
+	if p.exhausted {
+		// a partial result is returned when the budget is exhausted
+		err = nil
+	}
+
+	if contentType == contentTypeMultipartSigned {
+		email.Signature = p.parseSignature(raw)
+	}
+
+	if err == nil && opts.Tagger != nil {
+		email.Tags = opts.Tagger.Tags(&email)
+	}
+
+	if err == nil && opts.Differential && parent == nil {
+		email.Warnings = append(email.Warnings, divergences(&email, raw)...)
 	}
 
 	return
 }
 
-func createEmailFromHeader(header mail.Header) (email Email, err error) {
+func createEmailFromHeader(header mail.Header, version int) (email Email, err error) {
 	hp := headerParser{header: &header}
 
-	email.Subject = decodeMimeSentence(header.Get("Subject"))
+	email.SchemaVersion = version
+	email.Subject = decodeMimeSentenceVersion(header.Get("Subject"), version)
 	email.From = hp.parseAddressList(header.Get("From"))
 	email.Sender = hp.parseAddress(header.Get("Sender"))
 	email.ReplyTo = hp.parseAddressList(header.Get("Reply-To"))
+	email.MailReplyTo = hp.parseAddressList(header.Get("Mail-Reply-To"))
+	email.MailFollowupTo = hp.parseAddressList(header.Get("Mail-Followup-To"))
 	email.To = hp.parseAddressList(header.Get("To"))
 	email.Cc = hp.parseAddressList(header.Get("Cc"))
 	email.Bcc = hp.parseAddressList(header.Get("Bcc"))
@@ -88,6 +258,12 @@ func createEmailFromHeader(header mail.Header) (email Email, err error) {
 	email.InReplyTo = hp.parseMessageIdList(header.Get("In-Reply-To"))
 	email.References = hp.parseMessageIdList(header.Get("References"))
 	email.ResentDate = hp.parseTime(header.Get("Resent-Date"))
+	email.Keywords = parseKeywords(header["Keywords"], version)
+	email.Comments = parseComments(header["Comments"], version)
+	email.Sensitivity = parseSensitivity(header)
+	email.ContentLanguage = ParseLanguageTags(header.Get("Content-Language"))
+	email.AcceptLanguage = ParseLanguageTags(header.Get("Accept-Language"))
+	email.AuthenticationResults = parseAuthenticationResults(header["Authentication-Results"])
 
 	if hp.err != nil {
 		err = hp.err
@@ -96,7 +272,7 @@ func createEmailFromHeader(header mail.Header) (email Email, err error) {
 
 	//decode whole header for easier access to extra fields
 	//todo: should we decode? aren't only standard fields mime encoded?
-	email.Header, err = decodeHeaderMime(header)
+	email.Header, err = decodeHeaderMime(header, version)
 	if err != nil {
 		return
 	}
@@ -110,228 +286,575 @@ func parseContentType(contentTypeHeader string) (contentType string, params map[
 		return
 	}
 
-	return mime.ParseMediaType(contentTypeHeader)
+	contentType, params, err = parseMediaType(contentTypeHeader)
+	if err != nil {
+		err = &ParseError{Err: ErrMalformedContentType, Value: contentTypeHeader, cause: err}
+	}
+
+	return
+}
+
+// parser collects the bodies and files of the multipart structure of an
+// email into it
+type parser struct {
+	email *Email
+	opts  Options
+	depth int
+
+	deadline  time.Time
+	exhausted bool
+
+	// nesting is the depth of the multipart body being parsed, parts the
+	// number of parts read
+	nesting int
+	parts   int
+
+	// report is the nesting of the multipart/report body whose returned
+	// message becomes the OriginalMessage, 0 outside of it
+	report int
+}
+
+// addTextBody appends a decoded text/plain part to the text body. The header
+// of the first part contributing to the body is kept.
+func (p *parser) addTextBody(content []byte, header textproto.MIMEHeader) {
+	if !p.email.TextBodyTruncated {
+		p.email.TextBody += strings.TrimSuffix(string(content[:]), "\n")
+		p.email.TextBodyTruncated = truncateBody(&p.email.TextBody, p.opts.MaxBodyLength)
+	}
+	if p.email.TextBodyHeader == nil {
+		p.email.TextBodyHeader = mail.Header(header)
+	}
+}
+
+// addHTMLBody appends a decoded text/html part to the html body. The header
+// of the first part contributing to the body is kept.
+func (p *parser) addHTMLBody(content []byte, header textproto.MIMEHeader) {
+	if !p.email.HTMLBodyTruncated {
+		p.email.HTMLBody += strings.TrimSuffix(string(content[:]), "\n")
+		p.email.HTMLBodyTruncated = truncateBody(&p.email.HTMLBody, p.opts.MaxBodyLength)
+	}
+	if p.email.HTMLBodyHeader == nil {
+		p.email.HTMLBodyHeader = mail.Header(header)
+	}
 }
 
-func parseMultipartRelated(msg io.Reader, boundary string) (textBody, htmlBody string, attachments []Attachment, embeddedFiles []EmbeddedFile, err error) {
+// addCalendar decodes a text/calendar part
+func (p *parser) addCalendar(part *multipart.Part, params map[string]string) error {
+	content, err := p.readAllDecode(part, part.Header.Get("Content-Transfer-Encoding"), part.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+
+	p.email.Calendars = append(p.email.Calendars, parseCalendar(content, params["method"]))
+
+	return nil
+}
+
+// parseMultipartRelated parses a multipart/related body. When the start or
+// type parameter is given, only the root part they select provides the body
+// and other text parts are treated as embedded files.
+func (p *parser) parseMultipartRelated(msg io.Reader, boundary, start, rootType string) error {
+	if boundary == "" {
+		return &ParseError{Err: ErrBoundaryMissing}
+	}
+	if err := p.enterMultipart(); err != nil {
+		return err
+	}
+	defer p.leaveMultipart()
+
 	pmr := multipart.NewReader(msg, boundary)
+	rootFound := false
+	read := false
+	// the embedded file made of the first part and its header
+	first, firstHeader := -1, textproto.MIMEHeader(nil)
 	for {
 		part, err := pmr.NextPart()
 
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return textBody, htmlBody, attachments, embeddedFiles, err
+			return p.nextPartError(err, read)
+		}
+		files := len(p.email.EmbeddedFiles)
+		if err := p.countPart(); err != nil {
+			return err
 		}
 
-		contentType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
-		if err != nil {
-			return textBody, htmlBody, attachments, embeddedFiles, err
+		if err := p.recoverPart(p.relatedPart(part, start, rootType, &rootFound)); err != nil {
+			return err
+		}
+
+		if !read && len(p.email.EmbeddedFiles) == files+1 {
+			first, firstHeader = files, part.Header
 		}
+		read = true
+	}
 
-		encoding := part.Header.Get("Content-Transfer-Encoding")
+	// without a part matching start the first part is the root (RFC 2387)
+	if start != "" && !rootFound && first >= 0 && p.email.SchemaVersion >= 6 {
+		return p.recoverPart(p.addRelatedRoot(first, firstHeader))
+	}
 
-		switch contentType {
-		case contentTypeTextPlain:
-			ppContent, err := readAllDecode(part, encoding, part.Header.Get("Content-Type"))
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
+	return nil
+}
 
-			textBody += strings.TrimSuffix(string(ppContent[:]), "\n")
-		case contentTypeTextHtml:
-			ppContent, err := readAllDecode(part, encoding, part.Header.Get("Content-Type"))
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
+// addRelatedRoot makes the text or html embedded file i, taken from the
+// first part of a multipart/related body, the body of the email
+func (p *parser) addRelatedRoot(i int, header textproto.MIMEHeader) error {
+	contentType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if contentType != contentTypeTextPlain && contentType != contentTypeTextHtml {
+		return nil
+	}
+
+	// the content of the file has its transfer encoding undone already
+	content, err := p.readAllDecode(p.email.EmbeddedFiles[i].Data, "", header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	p.email.EmbeddedFiles = append(p.email.EmbeddedFiles[:i], p.email.EmbeddedFiles[i+1:]...)
+
+	if contentType == contentTypeTextHtml {
+		p.addHTMLBody(content, header)
+	} else {
+		p.addTextBody(content, header)
+	}
+
+	return nil
+}
+
+// relatedPart adds a part of a multipart/related body to the email
+func (p *parser) relatedPart(part *multipart.Part, start, rootType string, rootFound *bool) error {
+	if p.skip(part) {
+		return nil
+	}
 
-			htmlBody += strings.TrimSuffix(string(ppContent[:]), "\n")
-		case contentTypeMultipartMixed:
-			tb, hb, at, ef, err := parseMultipartMixed(part, params["boundary"])
+	contentType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if err != nil {
+		return &ParseError{Err: ErrMalformedContentType, Value: part.Header.Get("Content-Type"), cause: err}
+	}
+
+	if start != "" || rootType != "" {
+		if !*rootFound && isRelatedRoot(part, contentType, start, rootType) {
+			*rootFound = true
+		} else if contentType == contentTypeTextPlain || contentType == contentTypeTextHtml {
+			ef, err := p.decodeEmbeddedFile(part)
 			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
+				return err
 			}
 
-			htmlBody += hb
-			textBody += tb
-			embeddedFiles = append(embeddedFiles, ef...)
-			attachments = append(attachments, at...)
-		case contentTypeMultipartAlternative:
-			tb, hb, at, ef, err := parseMultipartAlternative(part, params["boundary"])
+			p.addEmbeddedFile(ef)
+			return nil
+		}
+	}
+
+	encoding := part.Header.Get("Content-Transfer-Encoding")
+
+	switch contentType {
+	case contentTypeTextPlain:
+		ppContent, err := p.readAllDecode(part, encoding, part.Header.Get("Content-Type"))
+		if err != nil {
+			return err
+		}
+
+		p.addTextBody(ppContent, part.Header)
+	case contentTypeTextHtml:
+		ppContent, err := p.readAllDecode(part, encoding, part.Header.Get("Content-Type"))
+		if err != nil {
+			return err
+		}
+
+		p.addHTMLBody(ppContent, part.Header)
+	case contentTypeMultipartMixed:
+		if err := p.parseMultipartMixed(part, params["boundary"]); err != nil {
+			return err
+		}
+	case contentTypeMultipartAlternative:
+		if err := p.parseMultipartAlternative(part, params["boundary"]); err != nil {
+			return err
+		}
+	case contentTypeTextCalendar:
+		if err := p.addCalendar(part, params); err != nil {
+			return err
+		}
+	default:
+		if isEmbeddedFile(part) {
+			ef, err := p.decodeEmbeddedFile(part)
 			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
+				return err
 			}
 
-			htmlBody += hb
-			textBody += tb
-			embeddedFiles = append(embeddedFiles, ef...)
-			attachments = append(attachments, at...)
-		default:
-			if isEmbeddedFile(part) {
-				ef, err := decodeEmbeddedFile(part)
-				if err != nil {
-					return textBody, htmlBody, attachments, embeddedFiles, err
-				}
-
-				embeddedFiles = append(embeddedFiles, ef)
-			} else {
-				return textBody, htmlBody, attachments, embeddedFiles, fmt.Errorf("Can't process multipart/related inner mime type: %s", contentType)
-			}
+			p.addEmbeddedFile(ef)
+		} else if err := p.addUnknownPart(part, fmt.Errorf("Can't process multipart/related inner mime type: %s", contentType)); err != nil {
+			return err
 		}
 	}
 
-	return textBody, htmlBody, attachments, embeddedFiles, err
+	return nil
+}
+
+// nextPartError returns the error of reading the next part of a multipart
+// body. A body broken after parts were read keeps them, with the error as a
+// warning.
+func (p *parser) nextPartError(err error, read bool) error {
+	if !read {
+		return err
+	}
+
+	return p.recoverPart(err)
 }
 
-func parseMultipartAlternative(msg io.Reader, boundary string) (textBody, htmlBody string, attachments []Attachment, embeddedFiles []EmbeddedFile, err error) {
+// addUnknownPart adds a nested part of a type the parser doesn't process as
+// an attachment. Before schema version 2 such a part failed the parse with
+// err.
+func (p *parser) addUnknownPart(part *multipart.Part, err error) error {
+	if p.email.SchemaVersion < 2 {
+		return err
+	}
+
+	at, err := p.decodeAttachment(part)
+	if err != nil {
+		return err
+	}
+	p.addAttachment(at)
+
+	return nil
+}
+
+// isRelatedRoot reports whether part is the root of a multipart/related body,
+// the part with the Content-ID given by start or, without start, the first
+// part of the given type.
+func isRelatedRoot(part *multipart.Part, contentType, start, rootType string) bool {
+	if start != "" {
+		return strings.Trim(part.Header.Get("Content-Id"), "<> ") == strings.Trim(start, "<> ")
+	}
+
+	return strings.EqualFold(contentType, rootType)
+}
+
+func (p *parser) parseMultipartAlternative(msg io.Reader, boundary string) error {
+	if boundary == "" {
+		return &ParseError{Err: ErrBoundaryMissing}
+	}
+	if err := p.enterMultipart(); err != nil {
+		return err
+	}
+	defer p.leaveMultipart()
+
 	pmr := multipart.NewReader(msg, boundary)
+	read := false
 	for {
 		part, err := pmr.NextPart()
 
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return textBody, htmlBody, attachments, embeddedFiles, err
+			return p.nextPartError(err, read)
+		}
+		read = true
+		if err := p.countPart(); err != nil {
+			return err
 		}
 
-		contentType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
-		if err != nil {
-			return textBody, htmlBody, attachments, embeddedFiles, err
+		if err := p.recoverPart(p.alternativePart(part)); err != nil {
+			return err
 		}
+	}
 
-		encoding := part.Header.Get("Content-Transfer-Encoding")
+	return nil
+}
 
-		switch contentType {
-		case contentTypeTextPlain:
-			ppContent, err := readAllDecode(part, encoding, part.Header.Get("Content-Type"))
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
+// alternativePart adds a part of a multipart/alternative body to the email
+func (p *parser) alternativePart(part *multipart.Part) error {
+	if p.skip(part) {
+		return nil
+	}
 
-			textBody += strings.TrimSuffix(string(ppContent[:]), "\n")
-		case contentTypeTextHtml:
-			ppContent, err := readAllDecode(part, encoding, part.Header.Get("Content-Type"))
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
+	contentType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if err != nil {
+		return &ParseError{Err: ErrMalformedContentType, Value: part.Header.Get("Content-Type"), cause: err}
+	}
 
-			htmlBody += strings.TrimSuffix(string(ppContent[:]), "\n")
-		case contentTypeMultipartRelated:
-			tb, hb, at, ef, err := parseMultipartRelated(part, params["boundary"])
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
+	encoding := part.Header.Get("Content-Transfer-Encoding")
 
-			htmlBody += hb
-			textBody += tb
-			embeddedFiles = append(embeddedFiles, ef...)
-			attachments = append(attachments, at...)
-		case contentTypeMultipartMixed:
-			tb, hb, at, ef, err := parseMultipartMixed(part, params["boundary"])
+	switch contentType {
+	case contentTypeTextPlain:
+		ppContent, err := p.readAllDecode(part, encoding, part.Header.Get("Content-Type"))
+		if err != nil {
+			return err
+		}
+
+		p.addTextBody(ppContent, part.Header)
+	case contentTypeTextHtml:
+		ppContent, err := p.readAllDecode(part, encoding, part.Header.Get("Content-Type"))
+		if err != nil {
+			return err
+		}
+
+		p.addHTMLBody(ppContent, part.Header)
+	case contentTypeMultipartRelated:
+		if err := p.parseMultipartRelated(part, params["boundary"], params["start"], params["type"]); err != nil {
+			return err
+		}
+	case contentTypeMultipartMixed:
+		if err := p.parseMultipartMixed(part, params["boundary"]); err != nil {
+			return err
+		}
+	case contentTypeTextCalendar:
+		if err := p.addCalendar(part, params); err != nil {
+			return err
+		}
+	default:
+		if isEmbeddedFile(part) {
+			ef, err := p.decodeEmbeddedFile(part)
 			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
+				return err
 			}
 
-			htmlBody += hb
-			textBody += tb
-			embeddedFiles = append(embeddedFiles, ef...)
-			attachments = append(attachments, at...)
-		default:
-			if isEmbeddedFile(part) {
-				ef, err := decodeEmbeddedFile(part)
-				if err != nil {
-					return textBody, htmlBody, attachments, embeddedFiles, err
-				}
-
-				embeddedFiles = append(embeddedFiles, ef)
-			} else {
-				return textBody, htmlBody, attachments, embeddedFiles, fmt.Errorf("Can't process multipart/alternative inner mime type: %s", contentType)
-			}
+			p.addEmbeddedFile(ef)
+		} else if err := p.addUnknownPart(part, fmt.Errorf("Can't process multipart/alternative inner mime type: %s", contentType)); err != nil {
+			return err
 		}
 	}
 
-	return textBody, htmlBody, attachments, embeddedFiles, err
+	return nil
 }
 
-func parseMultipartMixed(msg io.Reader, boundary string) (textBody, htmlBody string, attachments []Attachment, embeddedFiles []EmbeddedFile, err error) {
+func (p *parser) parseMultipartMixed(msg io.Reader, boundary string) error {
+	if boundary == "" {
+		return &ParseError{Err: ErrBoundaryMissing}
+	}
+	if err := p.enterMultipart(); err != nil {
+		return err
+	}
+	defer p.leaveMultipart()
+
 	mr := multipart.NewReader(msg, boundary)
+	read := false
 	for {
 		part, err := mr.NextPart()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return textBody, htmlBody, attachments, embeddedFiles, err
+			return p.nextPartError(err, read)
+		}
+		read = true
+		if err := p.countPart(); err != nil {
+			return err
 		}
 
-		contentType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err := p.recoverPart(p.mixedPart(part)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mixedPart adds a part of a multipart/mixed body to the email
+func (p *parser) mixedPart(part *multipart.Part) error {
+	if p.skip(part) {
+		return nil
+	}
+
+	contentType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if err != nil {
+		return &ParseError{Err: ErrMalformedContentType, Value: part.Header.Get("Content-Type"), cause: err}
+	}
+
+	attached := isAttachment(part)
+	if attached {
+		at, err := p.decodeAttachment(part)
 		if err != nil {
-			return textBody, htmlBody, attachments, embeddedFiles, err
+			return err
+		}
+		p.addAttachment(at)
+		if strings.Contains(contentType, "application") ||
+			isAttachmentByContentDisposition(part) {
+			return nil
 		}
+	}
 
-		if isAttachment(part) {
-			at, err := decodeAttachment(part)
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
-			attachments = append(attachments, at)
-			if strings.Contains(contentType, "application") ||
-				isAttachmentByContentDisposition(part) {
-				continue
-			}
+	encoding := part.Header.Get("Content-Transfer-Encoding")
+
+	if contentType == contentTypeMultipartAlternative {
+		if err := p.parseMultipartAlternative(part, params["boundary"]); err != nil {
+			return err
+		}
+	} else if contentType == contentTypeMultipartMixed {
+		if err := p.parseMultipartMixed(part, params["boundary"]); err != nil {
+			return err
+		}
+	} else if contentType == contentTypeMultipartRelated {
+		if err := p.parseMultipartRelated(part, params["boundary"], params["start"], params["type"]); err != nil {
+			return err
+		}
+	} else if contentType == contentTypeTextPlain {
+		ppContent, err := p.readAllDecode(part, encoding, part.Header.Get("Content-Type"))
+		if err != nil {
+			return err
 		}
 
-		encoding := part.Header.Get("Content-Transfer-Encoding")
+		p.addTextBody(ppContent, part.Header)
+	} else if contentType == contentTypeTextHtml {
+		ppContent, err := p.readAllDecode(part, encoding, part.Header.Get("Content-Type"))
+		if err != nil {
+			return err
+		}
 
-		if contentType == contentTypeMultipartAlternative {
-			textBody, htmlBody, attachments, embeddedFiles, err = parseMultipartAlternative(part, params["boundary"])
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
-		} else if contentType == contentTypeMultipartMixed {
-			textBody, htmlBody, attachments, embeddedFiles, err = parseMultipartMixed(part, params["boundary"])
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
-		} else if contentType == contentTypeMultipartRelated {
-			textBody, htmlBody, attachments, embeddedFiles, err = parseMultipartRelated(part, params["boundary"])
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
-		} else if contentType == contentTypeTextPlain {
-			ppContent, err := readAllDecode(part, encoding, part.Header.Get("Content-Type"))
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
+		p.addHTMLBody(ppContent, part.Header)
+	} else if contentType == contentTypeTextCalendar {
+		if err := p.addCalendar(part, params); err != nil {
+			return err
+		}
+	} else if contentType == contentTypeMultipartReport {
+		if err := p.parseMultipartMixed(part, params["boundary"]); err != nil {
+			return err
+		}
+	} else if contentType == messageRFC822 || matchContentType(contentType, reportPartTypes) ||
+		p.report == p.nesting && matchContentType(contentType, originalMessageTypes) {
+		at, err := p.decodeAttachment(part)
+		if err != nil {
+			return err
+		}
+		p.addAttachment(at)
+	} else if isEmbeddedFile(part) {
+		ef, err := p.decodeEmbeddedFile(part)
+		if err != nil {
+			return err
+		}
 
-			textBody += strings.TrimSuffix(string(ppContent[:]), "\n")
-		} else if contentType == contentTypeTextHtml {
-			ppContent, err := readAllDecode(part, encoding, part.Header.Get("Content-Type"))
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
-			}
+		p.addEmbeddedFile(ef)
+	} else if attached && p.email.SchemaVersion >= 2 {
+		// already added as an attachment
+	} else if err := p.addUnknownPart(part, fmt.Errorf("Unknown multipart/mixed nested mime type: %s", contentType)); err != nil {
+		return err
+	}
 
-			htmlBody += strings.TrimSuffix(string(ppContent[:]), "\n")
-		} else if isEmbeddedFile(part) {
-			ef, err := decodeEmbeddedFile(part)
-			if err != nil {
-				return textBody, htmlBody, attachments, embeddedFiles, err
+	return nil
+}
+
+// parseMultipartFormData parses the multipart/form-data bodies some HTTP to
+// mail gateways emit. Files become attachments, text fields are added to the
+// bodies.
+func (p *parser) parseMultipartFormData(msg io.Reader, boundary string) error {
+	if boundary == "" {
+		return &ParseError{Err: ErrBoundaryMissing}
+	}
+	if err := p.enterMultipart(); err != nil {
+		return err
+	}
+	defer p.leaveMultipart()
+
+	mr := multipart.NewReader(msg, boundary)
+	read := false
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return p.nextPartError(err, read)
+		}
+		read = true
+		if err := p.countPart(); err != nil {
+			return err
+		}
+
+		if err := p.recoverPart(p.formDataPart(part)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formDataPart adds a field of a multipart/form-data body to the email
+func (p *parser) formDataPart(part *multipart.Part) error {
+	if p.skip(part) {
+		return nil
+	}
+
+	contentType, _, err := parseContentType(part.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+
+	if part.FileName() != "" || (contentType != contentTypeTextPlain && contentType != contentTypeTextHtml) {
+		at, err := p.decodeAttachment(part)
+		if err != nil {
+			return err
+		}
+
+		p.addAttachment(at)
+		return nil
+	}
+
+	ppContent, err := p.readAllDecode(part, part.Header.Get("Content-Transfer-Encoding"), part.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+
+	if contentType == contentTypeTextHtml {
+		p.addHTMLBody(ppContent, part.Header)
+	} else {
+		if p.email.TextBody != "" {
+			// the separator counts against MaxBodyLength like the field
+			ppContent = append([]byte("\n"), ppContent...)
+		}
+		p.addTextBody(ppContent, part.Header)
+	}
+
+	return nil
+}
+
+// parseKeywords splits all Keywords fields into their comma separated phrases
+func parseKeywords(fields []string, version int) (keywords []string) {
+	for _, field := range fields {
+		for _, k := range strings.Split(field, ",") {
+			k = strings.TrimSpace(decodeMimeSentenceVersion(strings.TrimSpace(k), version))
+			if k != "" {
+				keywords = append(keywords, k)
 			}
+		}
+	}
 
-			embeddedFiles = append(embeddedFiles, ef)
-		} else {
-			return textBody, htmlBody, attachments, embeddedFiles, fmt.Errorf("Unknown multipart/mixed nested mime type: %s", contentType)
+	return
+}
+
+// parseComments returns the decoded text of all Comments fields
+func parseComments(fields []string, version int) (comments []string) {
+	for _, field := range fields {
+		c := strings.TrimSpace(decodeMimeSentenceVersion(field, version))
+		if c != "" {
+			comments = append(comments, c)
 		}
 	}
 
-	return textBody, htmlBody, attachments, embeddedFiles, err
+	return
 }
 
+// decodeMimeSentence decodes the encoded-words of unstructured header text.
+// Whitespace is only dropped between two adjacent encoded words (RFC 2047
+// section 6.2).
 func decodeMimeSentence(s string) string {
+	return decodeMimeSentenceVersion(s, SchemaVersion)
+}
+
+// decodeMimeSentenceVersion decodes header text like emails of the schema
+// version did: before version 4 the whitespace between a plain word and a
+// following encoded word was dropped as well, and only encoded-words in
+// UTF-8, US-ASCII and ISO-8859-1 were decoded
+func decodeMimeSentenceVersion(s string, version int) string {
 	result := []string{}
 	ss := strings.Split(s, " ")
 
+	dec := new(mime.WordDecoder)
+	if version >= 4 {
+		// encoded-words may use any charset the bodies may use
+		dec.CharsetReader = cs.NewReaderLabel
+	}
+
+	previousEncoded := false
 	for _, word := range ss {
-		dec := new(mime.WordDecoder)
 		w, err := dec.Decode(word)
 		if err != nil {
 			if len(result) == 0 {
@@ -339,6 +862,12 @@ func decodeMimeSentence(s string) string {
 			} else {
 				w = " " + word
 			}
+			previousEncoded = false
+		} else {
+			if len(result) != 0 && !previousEncoded && version >= 4 {
+				w = " " + w
+			}
+			previousEncoded = true
 		}
 
 		result = append(result, w)
@@ -347,14 +876,14 @@ func decodeMimeSentence(s string) string {
 	return strings.Join(result, "")
 }
 
-func decodeHeaderMime(header mail.Header) (mail.Header, error) {
+func decodeHeaderMime(header mail.Header, version int) (mail.Header, error) {
 	parsedHeader := map[string][]string{}
 
 	for headerName, headerData := range header {
 
 		parsedHeaderData := []string{}
 		for _, headerValue := range headerData {
-			parsedHeaderData = append(parsedHeaderData, decodeMimeSentence(headerValue))
+			parsedHeaderData = append(parsedHeaderData, decodeMimeSentenceVersion(headerValue, version))
 		}
 
 		parsedHeader[headerName] = parsedHeaderData
@@ -364,19 +893,22 @@ func decodeHeaderMime(header mail.Header) (mail.Header, error) {
 }
 
 func isEmbeddedFile(part *multipart.Part) bool {
-	return part.Header.Get("Content-Transfer-Encoding") != "" || part.Header.Get("Content-Disposition")[0:17] == "inline; filename="
+	return part.Header.Get("Content-Transfer-Encoding") != "" ||
+		part.Header.Get("Content-Location") != "" ||
+		strings.HasPrefix(part.Header.Get("Content-Disposition"), "inline; filename=")
 }
 
-func decodeEmbeddedFile(part *multipart.Part) (ef EmbeddedFile, err error) {
-	cid := decodeMimeSentence(part.Header.Get("Content-Id"))
-	decoded, err := decodeContent(part, part.Header.Get("Content-Transfer-Encoding"))
+func (p *parser) decodeEmbeddedFile(part *multipart.Part) (ef EmbeddedFile, err error) {
+	cid := decodeMimeSentenceVersion(part.Header.Get("Content-Id"), p.email.SchemaVersion)
+	decoded, err := p.fileContent(part, part.Header.Get("Content-Transfer-Encoding"))
 	if err != nil {
 		return
 	}
 
 	ef.CID = strings.Trim(cid, "<>")
-	if ef.CID == "" {
-		_, param, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	ef.ContentLocation = strings.TrimSpace(part.Header.Get("Content-Location"))
+	if ef.CID == "" && part.Header.Get("Content-Disposition") != "" {
+		_, param, err := parseMediaTypeVersion(part.Header.Get("Content-Disposition"), p.email.SchemaVersion)
 		if err != nil {
 			return ef, err
 		}
@@ -393,6 +925,19 @@ func decodeEmbeddedFile(part *multipart.Part) (ef EmbeddedFile, err error) {
 		contentType = strings.SplitN(contentType, ";", 2)[0]
 	}
 	ef.ContentType = contentType
+	ef.RawContentType = part.Header.Get("Content-Type")
+	ef.Params = parseContentTypeParams(ef.RawContentType, p.email.SchemaVersion)
+	ef.ContentLanguage = ParseLanguageTags(part.Header.Get("Content-Language"))
+
+	if disposition, params, err := parseMediaTypeVersion(part.Header.Get("Content-Disposition"), p.email.SchemaVersion); err == nil {
+		ef.Disposition = disposition
+		ef.DispositionParams = params
+	}
+
+	ef.Filename = decodeMimeSentenceVersion(ef.DispositionParams["filename"], p.email.SchemaVersion)
+	if ef.Filename == "" {
+		ef.Filename = decodeMimeSentenceVersion(ef.Params["name"], p.email.SchemaVersion)
+	}
 
 	return
 }
@@ -428,76 +973,132 @@ func isAttachmentByContentDisposition(part *multipart.Part) bool {
 	return false
 }
 
-func decodeAttachment(part *multipart.Part) (at Attachment, err error) {
-	filename := ""
+func (p *parser) decodeAttachment(part *multipart.Part) (at Attachment, err error) {
+	at = attachmentInfo(part, p.email.SchemaVersion)
+
+	encoding := part.Header.Get("Content-Transfer-Encoding")
 	if part.Header.Get("Content-Type") == messageRFC822 {
-		filename = strings.Trim(decodeMimeSentence(part.Header.Get("Content-Id")), "<>") + ".eml"
-	} else {
-		filename = decodeMimeSentence(part.FileName())
+		encoding = ""
 	}
 
-	if part.Header.Get("Content-Type") == messageRFC822 {
-		dd, err := ioutil.ReadAll(part)
-		if err != nil {
-			return at, err
+	at.Data, err = p.fileContent(part, encoding)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	if at.ContentType == messageRFC822 {
+		if err = p.parseChildEmail(&at); err != nil {
+			return Attachment{}, err
 		}
-		at.Data = bytes.NewReader(dd)
-	} else {
-		at.Data, err = decodeContent(part, part.Header.Get("Content-Transfer-Encoding"))
-		if err != nil {
-			return
+	}
+
+	if p.report != 0 && p.report == p.nesting {
+		if err = p.addOriginalMessage(&at); err != nil {
+			return Attachment{}, err
 		}
 	}
 
-	at.Filename = filename
+	return
+}
+
+// attachmentInfo returns the metadata of an attachment part without its data,
+// parsed with the semantics of the schema version
+func attachmentInfo(part *multipart.Part, version int) (at Attachment) {
+	if part.Header.Get("Content-Type") == messageRFC822 {
+		at.Filename = strings.Trim(decodeMimeSentenceVersion(part.Header.Get("Content-Id"), version), "<>") + ".eml"
+	} else {
+		at.Filename = decodeMimeSentenceVersion(partFilename(part.Header, version), version)
+	}
+
 	at.ContentType = strings.Split(part.Header.Get("Content-Type"), ";")[0]
+	at.RawContentType = part.Header.Get("Content-Type")
+	at.Params = parseContentTypeParams(at.RawContentType, version)
+	at.ContentLanguage = ParseLanguageTags(part.Header.Get("Content-Language"))
 
 	return
 }
 
-func readAllDecode(content io.Reader, encoding, contentType string) ([]byte, error) {
-	r, err := decodeContent(content, encoding)
+// partFilename returns the filename parameter of the Content-Disposition of
+// a part, or since schema version 6 the name parameter of its Content-Type
+// that older mailers set instead, without directories
+func partFilename(header textproto.MIMEHeader, version int) string {
+	_, params, _ := parseMediaTypeVersion(header.Get("Content-Disposition"), version)
+	filename := params["filename"]
+	if filename == "" && version >= 6 {
+		_, params, _ = parseMediaTypeVersion(header.Get("Content-Type"), version)
+		filename = params["name"]
+	}
+
+	if filename == "" {
+		return ""
+	}
+
+	return filepath.Base(filename)
+}
+
+// parseContentTypeParams returns the parameters of a Content-Type header
+// value parsed with the semantics of the schema version, or nil if it can't
+// be parsed.
+func parseContentTypeParams(contentType string, version int) map[string]string {
+	_, params, err := parseMediaTypeVersion(contentType, version)
+	if err != nil {
+		return nil
+	}
+
+	return params
+}
+
+// readAllDecode decodes a text part to UTF-8, streaming the transfer decoded
+// content through the charset transformation
+func (p *parser) readAllDecode(content io.Reader, encoding, contentType string) ([]byte, error) {
+	r, encoded, err := p.partReader(content, encoding)
 	if err != nil {
 		return nil, err
 	}
 
-	cr, err := cs.NewReader(r, contentType)
+	// the charset is sniffed from the first bytes when the content type
+	// doesn't name it
+	br := bufio.NewReaderSize(r, 1024)
+	var src io.Reader = br
+	preview, err := br.Peek(1024)
+	if err != nil && err != io.EOF {
+		if err := p.partError(err, encoded); err != nil {
+			return nil, err
+		}
+		src = bytes.NewReader(preview)
+	}
+
+	enc, err := p.textEncoding(preview, contentType)
 	if err != nil {
 		return nil, err
 	}
 
-	return ioutil.ReadAll(cr)
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	_, err = buf.ReadFrom(transform.NewReader(src, enc.NewDecoder()))
+	if err := p.partError(err, encoded); err != nil {
+		return nil, err
+	}
+
+	return append([]byte{}, buf.Bytes()...), nil
 }
 
-func decodeContent(content io.Reader, encoding string) (io.Reader, error) {
+// decoder returns a reader undoing the transfer encoding of content
+func decoder(content io.Reader, encoding string) (io.Reader, error) {
 	encoding = strings.ToLower(encoding)
 
 	switch encoding {
 	case "base64":
-		decoded := base64.NewDecoder(base64.StdEncoding, content)
-		b, err := ioutil.ReadAll(decoded)
-		if err != nil {
-			return nil, err
-		}
-
-		return bytes.NewReader(b), nil
-	case "7bit", "", "8bit":
-		dd, err := ioutil.ReadAll(content)
-		if err != nil {
-			return nil, err
-		}
-
-		return bytes.NewReader(dd), nil
+		return base64.NewDecoder(base64.StdEncoding, content), nil
+	case "7bit", "", "8bit", "binary":
+		return content, nil
 	case "quoted-printable":
-		decoded := quotedprintable.NewReader(content)
-		b, err := ioutil.ReadAll(decoded)
-		if err != nil {
-			return nil, err
-		}
-
-		return bytes.NewReader(b), nil
+		return quotedprintable.NewReader(content), nil
+	case "x-uuencode", "uuencode", "x-uue":
+		return &uuReader{r: content}, nil
 	default:
-		return nil, fmt.Errorf("unknown encoding: %s", encoding)
+		return nil, &ParseError{Err: ErrUnknownEncoding, Value: encoding}
 	}
 }
 
@@ -533,19 +1134,20 @@ func (hp headerParser) parseAddressList(s string) (ma []*mail.Address) {
 	return
 }
 
+// dateFormats are the layouts of Date header values tried in order
+var dateFormats = []string{
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	time.RFC1123Z + " (MST)",
+	"Mon, 2 Jan 2006 15:04:05 -0700 (MST)",
+}
+
 func (hp headerParser) parseTime(s string) (t time.Time) {
 	if hp.err != nil || s == "" {
 		return
 	}
 
-	formats := []string{
-		time.RFC1123Z,
-		"Mon, 2 Jan 2006 15:04:05 -0700",
-		time.RFC1123Z + " (MST)",
-		"Mon, 2 Jan 2006 15:04:05 -0700 (MST)",
-	}
-
-	for _, format := range formats {
+	for _, format := range dateFormats {
 		t, hp.err = time.Parse(format, s)
 		if hp.err == nil {
 			return
@@ -577,18 +1179,46 @@ func (hp headerParser) parseMessageIdList(s string) (result []string) {
 	return
 }
 
-// Attachment with filename, content type and data (as a io.Reader)
+// Attachment with filename, content type and data (as a io.Reader).
+// Params holds the Content-Type parameters and RawContentType the full
+// original Content-Type header value. ExtractedText is the searchable text
+// produced by the extractor registered for the content type or the ImageText
+// hook of the options.
 type Attachment struct {
-	Filename    string
-	ContentType string
-	Data        io.Reader
+	Filename        string
+	ContentType     string
+	Params          map[string]string
+	RawContentType  string
+	ContentLanguage []string
+	Data            io.Reader
+	ExtractedText   string
+
+	// ChildEmail is the parsed message of a message/rfc822 attachment, whose
+	// Data keeps the raw message
+	ChildEmail *Email
+
+	// opts are the options the attachment was parsed with
+	opts Options
 }
 
-// EmbeddedFile with content id, content type and data (as a io.Reader)
+// EmbeddedFile with content id, content type and data (as a io.Reader).
+// Params holds the Content-Type parameters and RawContentType the full
+// original Content-Type header value. Disposition and DispositionParams are
+// taken from the Content-Disposition header, if present. ContentLocation is
+// the URI the part is referenced by when it has no Content-ID. ExtractedText
+// is the text produced by the ImageText hook of the options.
 type EmbeddedFile struct {
-	CID         string
-	ContentType string
-	Data        io.Reader
+	CID               string
+	ContentLocation   string
+	Filename          string
+	ContentType       string
+	Params            map[string]string
+	RawContentType    string
+	Disposition       string
+	DispositionParams map[string]string
+	ContentLanguage   []string
+	Data              io.Reader
+	ExtractedText     string
 }
 
 // Email with fields for all the headers defined in RFC5322 with it's attachments and
@@ -606,6 +1236,20 @@ type Email struct {
 	MessageID  string
 	InReplyTo  []string
 	References []string
+	Keywords   []string
+	Comments   []string
+
+	MailReplyTo    []*mail.Address
+	MailFollowupTo []*mail.Address
+
+	Sensitivity Sensitivity
+
+	ContentLanguage []string
+	AcceptLanguage  []string
+
+	// AuthenticationResults holds the Authentication-Results fields in
+	// order, the one added last by the receiving server first
+	AuthenticationResults []AuthenticationResults
 
 	ResentFrom      []*mail.Address
 	ResentSender    *mail.Address
@@ -621,6 +1265,43 @@ type Email struct {
 	HTMLBody string
 	TextBody string
 
+	// HTMLBodyTruncated and TextBodyTruncated are set when the body was cut
+	// at the MaxBodyLength of the options
+	HTMLBodyTruncated bool
+	TextBodyTruncated bool
+
+	HTMLBodyHeader mail.Header
+	TextBodyHeader mail.Header
+
 	Attachments   []Attachment
 	EmbeddedFiles []EmbeddedFile
+	Calendars     []Calendar
+
+	// OriginalMessage is the returned message of a bounce or other report.
+	// It only has headers if the report includes just the original headers.
+	OriginalMessage *Email
+
+	// Signature is the detached signature of a multipart/signed message
+	Signature *Signature
+
+	// Decrypted is set when the message was S/MIME encrypted and has been
+	// parsed from its decrypted body, see Options.SMIMEKey
+	Decrypted bool
+
+	// Warnings lists the problems that made the parser return a partial
+	// result, like exhausted parse budgets
+	Warnings []Finding
+
+	// Tags are the tags set by the Tagger of the options
+	Tags []string
+
+	// SchemaVersion is the version of the semantics the email was parsed
+	// with, see Options.SchemaVersion
+	SchemaVersion int
+
+	// raw is the original message, or just its header without KeepRaw, and
+	// size is the length of the message
+	raw  []byte
+	size int64
+	opts Options
 }
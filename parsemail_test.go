@@ -665,6 +665,33 @@ So, "Hello".`,
 			htmlBody:  "<html><head></head><body>test body</body></html>",
 			textBody:  "test body",
 		},
+		24: {
+			contentType: "multipart/form-data; boundary=\"FORM\"",
+			mailData:    multipartFormData,
+			subject:     "Form submission",
+			from: []mail.Address{
+				{
+					Name:    "Gateway",
+					Address: "gateway@test.lan",
+				},
+			},
+			to: []mail.Address{
+				{
+					Name:    "",
+					Address: "inbox@test.lan",
+				},
+			},
+			date:     parseDate("Thu, 13 Feb 2020 13:39:30 +0000"),
+			textBody: "first field\nsecond field",
+			htmlBody: "<p>html field</p>",
+			attachments: []attachmentData{
+				{
+					filename:    "report.txt",
+					contentType: "text/plain",
+					data:        "report",
+				},
+			},
+		},
 	}
 
 	for index, td := range testData {
@@ -845,6 +872,347 @@ So, "Hello".`,
 	}
 }
 
+func TestContentTypeParams(t *testing.T) {
+	e, err := Parse(strings.NewReader(attachment7bit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.Attachments) != 1 {
+		t.Fatalf("Incorrect number of attachments! Expected: 1, Got: %v.", len(e.Attachments))
+	}
+
+	at := e.Attachments[0]
+	if at.Params["name"] != "unencoded.csv" {
+		t.Errorf("Wrong attachment name parameter. Expected: unencoded.csv, Got: %s", at.Params["name"])
+	}
+
+	if at.RawContentType != "application/csv; name=\"unencoded.csv\"" {
+		t.Errorf("Wrong raw content type: %q", at.RawContentType)
+	}
+
+	e, err = Parse(strings.NewReader(multipartMixedNestedInlineImage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.EmbeddedFiles) != 1 {
+		t.Fatalf("Incorrect number of embedded files! Expected: 1, Got: %v.", len(e.EmbeddedFiles))
+	}
+
+	ef := e.EmbeddedFiles[0]
+	if ef.Params["x-unix-mode"] != "0644" || ef.Params["name"] != "test.png" {
+		t.Errorf("Wrong embedded file parameters: %v", ef.Params)
+	}
+}
+
+func TestEmbeddedFileDisposition(t *testing.T) {
+	var testData = map[int]struct {
+		mailData          string
+		filename          string
+		disposition       string
+		dispositionParams map[string]string
+	}{
+		1: {
+			mailData:          multipartMixedNestedInlineImage,
+			filename:          "test.png",
+			disposition:       "inline",
+			dispositionParams: map[string]string{"filename": "test.png"},
+		},
+		2: {
+			mailData: multipartRelatedEmbeddedNoDisposition,
+			filename: "logo.png",
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if len(e.EmbeddedFiles) != 1 {
+			t.Errorf("[Test Case %v] Incorrect number of embedded files! Expected: 1, Got: %v.", index, len(e.EmbeddedFiles))
+			continue
+		}
+
+		ef := e.EmbeddedFiles[0]
+		if ef.Filename != td.filename {
+			t.Errorf("[Test Case %v] Wrong filename. Expected: %s, Got: %s", index, td.filename, ef.Filename)
+		}
+
+		if ef.Disposition != td.disposition {
+			t.Errorf("[Test Case %v] Wrong disposition. Expected: %s, Got: %s", index, td.disposition, ef.Disposition)
+		}
+
+		if len(ef.DispositionParams) != len(td.dispositionParams) {
+			t.Errorf("[Test Case %v] Wrong disposition params. Expected: %v, Got: %v", index, td.dispositionParams, ef.DispositionParams)
+		}
+
+		for k, v := range td.dispositionParams {
+			if ef.DispositionParams[k] != v {
+				t.Errorf("[Test Case %v] Wrong disposition param %s. Expected: %s, Got: %s", index, k, v, ef.DispositionParams[k])
+			}
+		}
+	}
+}
+
+func TestMultipartRelatedRoot(t *testing.T) {
+	var testData = map[int]struct {
+		mailData      string
+		version       int
+		htmlBody      string
+		embeddedFiles []string
+	}{
+		1: {
+			mailData:      multipartRelatedStart,
+			htmlBody:      `<html><body><iframe src="cid:frame@test.lan"></iframe></body></html>`,
+			embeddedFiles: []string{"frame@test.lan"},
+		},
+		2: {
+			mailData:      multipartRelatedType,
+			htmlBody:      `<html><body>root</body></html>`,
+			embeddedFiles: []string{"readme@test.lan"},
+		},
+		3: {
+			mailData:      strings.Replace(multipartRelatedStart, `start="<root@test.lan>"`, `start="<missing@test.lan>"`, 1),
+			htmlBody:      `<html><body>frame</body></html>`,
+			embeddedFiles: []string{"root@test.lan"},
+		},
+		4: {
+			mailData:      strings.Replace(multipartRelatedStart, `start="<root@test.lan>"`, `start="<missing@test.lan>"`, 1),
+			version:       5,
+			embeddedFiles: []string{"frame@test.lan", "root@test.lan"},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(td.mailData), Options{SchemaVersion: td.version})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if e.HTMLBody != td.htmlBody {
+			t.Errorf("[Test Case %v] Wrong html body. Expected: '%s', Got: '%s'", index, td.htmlBody, e.HTMLBody)
+		}
+
+		if e.TextBody != "" {
+			t.Errorf("[Test Case %v] Unexpected text body: '%s'", index, e.TextBody)
+		}
+
+		var cids []string
+		for _, ef := range e.EmbeddedFiles {
+			cids = append(cids, ef.CID)
+		}
+
+		if !assertSliceEq(td.embeddedFiles, cids) {
+			t.Errorf("[Test Case %v] Wrong embedded files. Expected: %s, Got: %s", index, td.embeddedFiles, cids)
+		}
+	}
+}
+
+func TestBodyHeaders(t *testing.T) {
+	var testData = map[int]struct {
+		mailData   string
+		textHeader map[string]string
+		htmlHeader map[string]string
+	}{
+		1: {
+			mailData:   rfc5322exampleA11,
+			textHeader: map[string]string{"Subject": "Saying Hello"},
+		},
+		2: {
+			mailData:   rfc822,
+			textHeader: map[string]string{"Content-Transfer-Encoding": "base64"},
+			htmlHeader: map[string]string{"Content-Id": "<14BA36B28C32FC42A7423CE2CC317CE8@firma.local>"},
+		},
+		3: {
+			mailData:   multipartRelatedContentLocation,
+			htmlHeader: map[string]string{"Content-Location": "http://www.example.com/index.html"},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if (td.textHeader == nil) != (e.TextBodyHeader == nil) || (td.htmlHeader == nil) != (e.HTMLBodyHeader == nil) {
+			t.Errorf("[Test Case %v] Wrong body headers. Got text: %v, html: %v", index, e.TextBodyHeader, e.HTMLBodyHeader)
+			continue
+		}
+
+		for k, v := range td.textHeader {
+			if e.TextBodyHeader.Get(k) != v {
+				t.Errorf("[Test Case %v] Wrong text body header %s. Expected: %s, Got: %s", index, k, v, e.TextBodyHeader.Get(k))
+			}
+		}
+
+		for k, v := range td.htmlHeader {
+			if e.HTMLBodyHeader.Get(k) != v {
+				t.Errorf("[Test Case %v] Wrong html body header %s. Expected: %s, Got: %s", index, k, v, e.HTMLBodyHeader.Get(k))
+			}
+		}
+	}
+}
+
+func TestNestedMultipartSiblings(t *testing.T) {
+	alternative := "Content-Type: multipart/alternative; boundary=alt\n\n" +
+		"--alt\nContent-Type: text/plain\n\nnested text\n" +
+		"--alt\nContent-Type: text/html\n\n<p>nested html</p>\n" +
+		"--alt--\n"
+	related := "Content-Type: multipart/related; boundary=rel\n\n" +
+		"--rel\nContent-Type: text/html\n\n<img src=\"cid:logo\">\n" +
+		"--rel\nContent-Type: image/png\nContent-Id: <logo>\nContent-Transfer-Encoding: base64\n\nUE5H\n" +
+		"--rel--\n"
+	attachment := "Content-Type: application/pdf\nContent-Disposition: attachment; filename=\"a.pdf\"\n\n%PDF\n"
+
+	var testData = map[int]struct {
+		parts         []string
+		textBody      string
+		htmlBody      string
+		attachments   int
+		embeddedFiles int
+	}{
+		1: {
+			parts:    []string{"Content-Type: text/plain\n\nfirst text\n", alternative},
+			textBody: "first textnested text",
+			htmlBody: "<p>nested html</p>",
+		},
+		2: {
+			parts:       []string{attachment, alternative},
+			textBody:    "nested text",
+			htmlBody:    "<p>nested html</p>",
+			attachments: 1,
+		},
+		3: {
+			parts:         []string{alternative, related},
+			textBody:      "nested text",
+			htmlBody:      "<p>nested html</p><img src=\"cid:logo\">",
+			embeddedFiles: 1,
+		},
+		4: {
+			parts:         []string{related, attachment, alternative},
+			textBody:      "nested text",
+			htmlBody:      "<img src=\"cid:logo\"><p>nested html</p>",
+			attachments:   1,
+			embeddedFiles: 1,
+		},
+	}
+
+	for index, td := range testData {
+		mailData := "From: a@example.com\nContent-Type: multipart/mixed; boundary=mix\n\n"
+		for _, part := range td.parts {
+			mailData += "--mix\n" + part
+		}
+		mailData += "--mix--\n"
+
+		e, err := Parse(strings.NewReader(mailData))
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		if e.TextBody != td.textBody {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, td.textBody, e.TextBody)
+		}
+		if e.HTMLBody != td.htmlBody {
+			t.Errorf("[Test Case %v] Wrong html body. Expected: %q, Got: %q", index, td.htmlBody, e.HTMLBody)
+		}
+		if len(e.Attachments) != td.attachments {
+			t.Errorf("[Test Case %v] Wrong number of attachments. Expected: %v, Got: %v", index, td.attachments, len(e.Attachments))
+		}
+		if len(e.EmbeddedFiles) != td.embeddedFiles {
+			t.Errorf("[Test Case %v] Wrong number of embedded files. Expected: %v, Got: %v", index, td.embeddedFiles, len(e.EmbeddedFiles))
+		}
+	}
+}
+
+func TestKeywordsAndComments(t *testing.T) {
+	e, err := Parse(strings.NewReader(keywordsAndComments))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keywords := []string{"project", "Überweisung", "urgent", "later", "Bank filiale"}
+	if !assertSliceEq(keywords, e.Keywords) {
+		t.Errorf("Wrong keywords. Expected: %s, Got: %s", keywords, e.Keywords)
+	}
+
+	comments := []string{"first comment", "zweiter Kommentar für dich"}
+	if !assertSliceEq(comments, e.Comments) {
+		t.Errorf("Wrong comments. Expected: %s, Got: %s", comments, e.Comments)
+	}
+
+	// schema versions before 4 drop the space before an encoded-word
+	e, err = ParseWithOptions(strings.NewReader(keywordsAndComments), Options{SchemaVersion: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keywords = []string{"project", "Überweisung", "urgent", "later", "Bankfiliale"}
+	if !assertSliceEq(keywords, e.Keywords) {
+		t.Errorf("Wrong keywords for schema version 3. Expected: %s, Got: %s", keywords, e.Keywords)
+	}
+
+	comments = []string{"first comment", "zweiter Kommentarfür dich"}
+	if !assertSliceEq(comments, e.Comments) {
+		t.Errorf("Wrong comments for schema version 3. Expected: %s, Got: %s", comments, e.Comments)
+	}
+}
+
+func TestLongCharsetBody(t *testing.T) {
+	line := "Gr=FC=DFe aus K=F6ln=0A"
+	body := strings.Repeat(line, 200)
+	mailData := "From: John Doe <jdoe@machine.example>\n" +
+		"Subject: Long\n" +
+		"Content-Type: text/plain; charset=iso-8859-1\n" +
+		"Content-Transfer-Encoding: quoted-printable\n\n" +
+		strings.Replace(body, "=0A", "=0A=\n", -1)
+
+	e, err := Parse(strings.NewReader(mailData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.TrimSuffix(strings.Repeat("Grüße aus Köln\n", 200), "\n")
+	if e.TextBody != expected {
+		t.Errorf("Wrong text body of %v bytes. Expected %v bytes", len(e.TextBody), len(expected))
+	}
+}
+
+func TestEncodedWordCharsets(t *testing.T) {
+	var testData = map[int]struct {
+		subject  string
+		version  int
+		expected string
+	}{
+		1: {subject: "=?big5?b?pKSk5Q==?=", expected: "中文"},
+		2: {subject: "=?euc-kr?b?x9Gx2w==?=", expected: "한글"},
+		3: {subject: "=?iso-2022-jp?b?GyRCRnxLXDhsGyhC?=", expected: "日本語"},
+		4: {subject: "=?windows-1252?q?caf=E9_=80?=", expected: "café €"},
+		5: {subject: "=?iso-8859-1?q?caf=E9?=", expected: "café"},
+		6: {subject: "=?big5?b?pKSk5Q==?=", version: 3, expected: "=?big5?b?pKSk5Q==?="},
+		7: {subject: "=?iso-8859-1?q?caf=E9?=", version: 3, expected: "café"},
+	}
+
+	for index, td := range testData {
+		raw := "From: a@example.com\r\nSubject: " + td.subject + "\r\n\r\nHello\r\n"
+
+		e, err := ParseWithOptions(strings.NewReader(raw), Options{SchemaVersion: td.version})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if e.Subject != td.expected {
+			t.Errorf("[Test Case %v] Wrong subject. Expected: %q, Got: %q", index, td.expected, e.Subject)
+		}
+	}
+}
+
 func parseDate(in string) time.Time {
 	out, err := time.Parse(time.RFC1123Z, in)
 	if err != nil {
@@ -1631,4 +1999,104 @@ PGh0bWw+PGhlYWQ+PC9oZWFkPjxib2R5PnRlc3QgYm9keTwvYm9keT48L2h0bWw+
 
 
 --000000000000ab2e2205a26de587--
-`
\ No newline at end of file
+`
+
+var multipartRelatedEmbeddedNoDisposition = `From: Test <test@test.lan>
+To: Test 2 <test2@test.lan>
+Subject: Test 1
+Date: Thu, 13 Feb 2020 13:39:30 +0000
+Content-Type: multipart/related; boundary="REL"
+MIME-Version: 1.0
+
+--REL
+Content-Type: text/html; charset="utf-8"
+
+<html><body><img src="cid:logo@test.lan"></body></html>
+--REL
+Content-Type: image/png; name="logo.png"
+Content-ID: <logo@test.lan>
+Content-Transfer-Encoding: base64
+
+dGVzdA==
+--REL--
+`
+
+var multipartRelatedStart = `From: Test <test@test.lan>
+To: Test 2 <test2@test.lan>
+Subject: Test 1
+Date: Thu, 13 Feb 2020 13:39:30 +0000
+Content-Type: multipart/related; boundary="REL"; type="text/html"; start="<root@test.lan>"
+MIME-Version: 1.0
+
+--REL
+Content-Type: text/html; charset="utf-8"
+Content-ID: <frame@test.lan>
+
+<html><body>frame</body></html>
+--REL
+Content-Type: text/html; charset="utf-8"
+Content-ID: <root@test.lan>
+
+<html><body><iframe src="cid:frame@test.lan"></iframe></body></html>
+--REL--
+`
+
+var multipartRelatedType = `From: Test <test@test.lan>
+To: Test 2 <test2@test.lan>
+Subject: Test 1
+Date: Thu, 13 Feb 2020 13:39:30 +0000
+Content-Type: multipart/related; boundary="REL"; type="text/html"
+MIME-Version: 1.0
+
+--REL
+Content-Type: text/html; charset="utf-8"
+
+<html><body>root</body></html>
+--REL
+Content-Type: text/plain; charset="utf-8"
+Content-ID: <readme@test.lan>
+
+readme
+--REL--
+`
+
+var multipartFormData = `From: Gateway <gateway@test.lan>
+To: inbox@test.lan
+Subject: Form submission
+Date: Thu, 13 Feb 2020 13:39:30 +0000
+Content-Type: multipart/form-data; boundary="FORM"
+MIME-Version: 1.0
+
+--FORM
+Content-Disposition: form-data; name="first"
+
+first field
+--FORM
+Content-Disposition: form-data; name="second"
+
+second field
+--FORM
+Content-Disposition: form-data; name="html"
+Content-Type: text/html
+
+<p>html field</p>
+--FORM
+Content-Disposition: form-data; name="file"; filename="report.txt"
+Content-Type: text/plain
+
+report
+--FORM--
+`
+
+var keywordsAndComments = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Saying Hello
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Keywords: project, =?UTF-8?Q?=C3=9Cberweisung?=,
+ urgent
+Keywords: later, Bank =?UTF-8?Q?filiale?=
+Comments: first comment
+Comments: zweiter Kommentar =?UTF-8?Q?f=C3=BCr?= dich
+
+Hello.
+`
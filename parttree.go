@@ -0,0 +1,147 @@
+package parsemail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Part is a node of the MIME structure of a message. Multipart parts have
+// their parts in Parts, message/rfc822 parts the root of the encapsulated
+// message in Message.
+//
+// Path is the IMAP style section path of the part, as accepted by
+// DecodePart. The root of a multipart message has the path of the message
+// itself, "" for the email, and the root of a message that isn't multipart
+// has the path of its body, "1" for the email.
+type Part struct {
+	Path              string
+	Header            textproto.MIMEHeader
+	ContentType       string
+	Params            map[string]string
+	Disposition       string
+	DispositionParams map[string]string
+	Filename          string
+
+	Parts   []*Part
+	Message *Part
+
+	body []byte
+}
+
+// Root returns the complete MIME structure of the original message, with
+// the parts the parser flattens into bodies and attachments as well as
+// unusual structures it ignores
+func (e *Email) Root() (*Part, error) {
+	raw, err := e.rawMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	return newMessagePart(raw, "", e.SchemaVersion)
+}
+
+// Body returns the content of the part with its transfer encoding decoded.
+// The body of a multipart part is its raw content including the delimiters.
+func (p *Part) Body() (io.Reader, error) {
+	return decoder(bytes.NewReader(p.body), p.Header.Get("Content-Transfer-Encoding"))
+}
+
+// Walk calls fn for the part and all parts below it, depth first, including
+// the parts of encapsulated messages. It stops at the first error of fn.
+func (p *Part) Walk(fn func(p *Part) error) error {
+	if err := fn(p); err != nil {
+		return err
+	}
+
+	for _, child := range p.Parts {
+		if err := child.Walk(fn); err != nil {
+			return err
+		}
+	}
+
+	if p.Message != nil {
+		return p.Message.Walk(fn)
+	}
+
+	return nil
+}
+
+// newMessagePart returns the root part of the message data at the section
+// path prefix, decoded the way schema version decodes it
+func newMessagePart(data []byte, prefix string, version int) (*Part, error) {
+	p, err := newPart(data, prefix, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.Parts) == 0 && !strings.HasPrefix(p.ContentType, "multipart/") {
+		p.Path = sectionPath(prefix, 1)
+	}
+
+	return p, nil
+}
+
+// newPart returns the part in data at path together with the parts below it
+func newPart(data []byte, path string, version int) (*Part, error) {
+	header, body := splitHeader(data)
+	mh, err := parseRawHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, params, err := parseContentType(mh.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("part %s: %v", path, err)
+	}
+
+	p := &Part{
+		Path:        path,
+		Header:      mh,
+		ContentType: strings.ToLower(contentType),
+		Params:      params,
+		body:        body,
+	}
+
+	if disposition, params, err := parseMediaTypeVersion(mh.Get("Content-Disposition"), version); err == nil {
+		p.Disposition = disposition
+		p.DispositionParams = params
+	}
+
+	p.Filename = decodeMimeSentenceVersion(p.DispositionParams["filename"], version)
+	if p.Filename == "" {
+		p.Filename = decodeMimeSentenceVersion(p.Params["name"], version)
+	}
+
+	switch {
+	case strings.HasPrefix(p.ContentType, "multipart/"):
+		if params["boundary"] == "" {
+			return nil, fmt.Errorf("part %s: %s without boundary", path, contentType)
+		}
+
+		for i, raw := range splitBody(body, params["boundary"]) {
+			child, err := newPart(raw, sectionPath(path, i+1), version)
+			if err != nil {
+				return nil, err
+			}
+			p.Parts = append(p.Parts, child)
+		}
+	case p.ContentType == messageRFC822 && path != "":
+		if p.Message, err = newMessagePart(body, path, version); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func sectionPath(prefix string, n int) string {
+	if prefix == "" {
+		return strconv.Itoa(n)
+	}
+
+	return prefix + "." + strconv.Itoa(n)
+}
@@ -0,0 +1,131 @@
+package parsemail
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRoot(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		parts    []string
+	}{
+		1: {
+			mailData: rfc822,
+			parts: []string{
+				"multipart/mixed",
+				"1 multipart/alternative",
+				"1.1 text/plain",
+				"1.2 text/html",
+				"2 message/rfc822 attachment",
+				"2 multipart/alternative",
+				"2.1 text/plain",
+				"2.2 text/html",
+			},
+		},
+		2: {
+			mailData: rfc5322exampleA11,
+			parts:    []string{"1 text/plain"},
+		},
+		3: {
+			mailData: splitPartsExample,
+			parts: []string{
+				"multipart/mixed",
+				"1 text/plain",
+				"2 text/html",
+			},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(td.mailData), Options{KeepRaw: true})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		root, err := e.Root()
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		var parts []string
+		root.Walk(func(p *Part) error {
+			parts = append(parts, strings.TrimSpace(fmt.Sprintf("%s %s %s %s", p.Path, p.ContentType, p.Disposition, p.Filename)))
+			return nil
+		})
+
+		if !assertSliceEq(parts, td.parts) {
+			t.Errorf("[Test Case %v] Wrong parts. Expected: %q, Got: %q", index, td.parts, parts)
+		}
+
+		// the bodies of leaf parts are the parts DecodePart returns
+		root.Walk(func(p *Part) error {
+			if len(p.Parts) > 0 || p.Message != nil {
+				return nil
+			}
+
+			body, err := p.Body()
+			if err != nil {
+				t.Errorf("[Test Case %v] Part %s: %v", index, p.Path, err)
+				return nil
+			}
+			at, err := e.DecodePart(p.Path)
+			if err != nil {
+				t.Errorf("[Test Case %v] Part %s: %v", index, p.Path, err)
+				return nil
+			}
+
+			b1, _ := ioutil.ReadAll(body)
+			b2, _ := ioutil.ReadAll(at.Data)
+			if string(b1) != string(b2) {
+				t.Errorf("[Test Case %v] Wrong body of part %s. Expected: %q, Got: %q", index, p.Path, b2, b1)
+			}
+			return nil
+		})
+	}
+}
+
+func TestRootFilenameVersions(t *testing.T) {
+	mailData := "From: a@example.com\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain\r\n\r\nSee attached\r\n" +
+		"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=\"=?big5?b?pKSk5S5wZGY=?=\"\r\n\r\n%PDF\r\n" +
+		"--b--\r\n"
+
+	var testData = map[int]struct {
+		version  int
+		filename string
+	}{
+		1: {filename: "中文.pdf"},
+		2: {version: 3, filename: "=?big5?b?pKSk5S5wZGY=?="},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(mailData), Options{KeepRaw: true, SchemaVersion: td.version})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		root, err := e.Root()
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if len(root.Parts) != 2 {
+			t.Fatalf("[Test Case %v] Wrong number of parts. Expected: 2, Got: %v", index, len(root.Parts))
+		}
+
+		if root.Parts[1].Filename != td.filename {
+			t.Errorf("[Test Case %v] Wrong filename. Expected: %q, Got: %q", index, td.filename, root.Parts[1].Filename)
+		}
+	}
+}
+
+func TestRootWithoutRaw(t *testing.T) {
+	e := Email{}
+	if _, err := e.Root(); err == nil {
+		t.Error("Expected an error for an email without raw message")
+	}
+}
@@ -0,0 +1,245 @@
+package parsemail
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFInflated is the most bytes inflated from the compressed streams of a
+// document, so a small zip bomb can't exhaust memory or CPU
+const maxPDFInflated = 32 << 20
+
+// PDFInfo holds basic metadata of a PDF document
+type PDFInfo struct {
+	Pages     int
+	Title     string
+	Encrypted bool
+}
+
+var (
+	pdfEndObj      = regexp.MustCompile(`\bendobj\b`)
+	pdfTypePages   = regexp.MustCompile(`/Type\s*/Pages\b`)
+	pdfTypePage    = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfCount       = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfInfoRef     = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	pdfTitle       = regexp.MustCompile(`/Title\s*([(<])`)
+	pdfEncrypt     = regexp.MustCompile(`/Encrypt\s*(\d+\s+\d+\s+R|<<)`)
+	pdfFlateStream = regexp.MustCompile(`(?s)/FlateDecode.*?>>\s*stream\r?\n`)
+)
+
+// PDFInfo extracts the page count, the title and whether the document is
+// encrypted from an application/pdf attachment without rendering it. The
+// values are best effort, the title of an encrypted document is not
+// available.
+func (a *Attachment) PDFInfo() (*PDFInfo, error) {
+	if !strings.EqualFold(a.ContentType, "application/pdf") {
+		return nil, fmt.Errorf("attachment is not a pdf: %s", a.ContentType)
+	}
+
+	data, err := a.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Contains(data[:minInt(len(data), 1024)], []byte("%PDF-")) {
+		return nil, fmt.Errorf("missing pdf header")
+	}
+
+	info := &PDFInfo{
+		Encrypted: pdfEncrypt.Match(data),
+	}
+
+	// object streams hide the page tree from a plain scan
+	chunks := pdfEndObj.Split(string(data), -1)
+	if !info.Encrypted {
+		chunks = append(chunks, pdfInflateStreams(data)...)
+	}
+
+	leaves := 0
+	for _, chunk := range chunks {
+		if pdfTypePages.MatchString(chunk) {
+			for _, m := range pdfCount.FindAllStringSubmatch(chunk, -1) {
+				if n, err := strconv.Atoi(m[1]); err == nil && n > info.Pages {
+					info.Pages = n
+				}
+			}
+		}
+
+		leaves += len(pdfTypePage.FindAllStringIndex(chunk, -1))
+	}
+
+	if info.Pages == 0 {
+		info.Pages = leaves
+	}
+
+	if !info.Encrypted {
+		info.Title = pdfInfoTitle(data)
+	}
+
+	return info, nil
+}
+
+// pdfInflateStreams returns the content of all flate compressed streams.
+// Inflating stops once maxPDFInflated bytes were inflated, the streams after
+// that are left out.
+func pdfInflateStreams(data []byte) (streams []string) {
+	left := int64(maxPDFInflated)
+	for _, loc := range pdfFlateStream.FindAllIndex(data, -1) {
+		r, err := zlib.NewReader(bytes.NewReader(data[loc[1]:]))
+		if err != nil {
+			continue
+		}
+
+		// trailing garbage after the compressed data surfaces as an error
+		b, _ := ioutil.ReadAll(io.LimitReader(r, left))
+		r.Close()
+		streams = append(streams, string(b))
+
+		if left -= int64(len(b)); left <= 0 {
+			break
+		}
+	}
+
+	return
+}
+
+// pdfInfoTitle returns the title of the document information dictionary
+// referenced by the last trailer
+func pdfInfoTitle(data []byte) string {
+	refs := pdfInfoRef.FindAllSubmatch(data, -1)
+	if len(refs) == 0 {
+		return ""
+	}
+
+	ref := refs[len(refs)-1]
+	start := regexp.MustCompile(`\b` + string(ref[1]) + `\s+` + string(ref[2]) + `\s+obj\b`).FindIndex(data)
+	if start == nil {
+		return ""
+	}
+
+	obj := data[start[1]:]
+	if end := pdfEndObj.FindIndex(obj); end != nil {
+		obj = obj[:end[0]]
+	}
+
+	m := pdfTitle.FindSubmatchIndex(obj)
+	if m == nil {
+		return ""
+	}
+
+	var s []byte
+	if obj[m[2]] == '(' {
+		s = pdfLiteralString(obj[m[3]:])
+	} else {
+		end := bytes.IndexByte(obj[m[3]:], '>')
+		if end < 0 {
+			return ""
+		}
+
+		hexString := strings.Join(strings.Fields(string(obj[m[3]:m[3]+end])), "")
+		if len(hexString)%2 == 1 {
+			hexString += "0"
+		}
+
+		var err error
+		if s, err = hex.DecodeString(hexString); err != nil {
+			return ""
+		}
+	}
+
+	return pdfTextString(s)
+}
+
+// pdfLiteralString decodes the literal string starting after its opening
+// parenthesis
+func pdfLiteralString(b []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+		case '\\':
+			i++
+			if i == len(b) {
+				return out
+			}
+
+			switch c = b[i]; c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if i+1 < len(b) && b[i+1] == '\n' {
+					i++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := 0
+					for j := 0; j < 3 && i < len(b) && b[i] >= '0' && b[i] <= '7'; j++ {
+						n = n*8 + int(b[i]-'0')
+						i++
+					}
+					i--
+					c = byte(n)
+				}
+			}
+		}
+
+		out = append(out, c)
+	}
+
+	return out
+}
+
+// pdfTextString converts a UTF-16BE string with byte order mark or a
+// PDFDocEncoding string, approximated as Latin-1, to UTF-8
+func pdfTextString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+
+		return string(utf16.Decode(u))
+	}
+
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+
+	return string(r)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,108 @@
+package parsemail
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+)
+
+func TestPDFInfo(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 >> << /Type /Page >>"))
+	zw.Close()
+
+	objectStream := "%PDF-1.5\n1 0 obj\n<< /Type /ObjStm /Filter /FlateDecode /Length " +
+		"99 >>\nstream\n" + compressed.String() + "\nendstream\nendobj\n" +
+		"9 0 obj\n<< /Type /XRef /Info 7 0 R >>\nendobj\n" +
+		"7 0 obj\n<< /Title <FEFF00C4006C0070> >>\nendobj\n%%EOF\n"
+
+	var testData = map[int]struct {
+		contentType string
+		data        string
+		info        PDFInfo
+		err         bool
+	}{
+		1: {"application/pdf", simplePDF, PDFInfo{Pages: 2, Title: "Quarterly (Q3) report"}, false},
+		2: {"application/pdf", encryptedPDF, PDFInfo{Pages: 1, Encrypted: true}, false},
+		3: {"application/pdf", objectStream, PDFInfo{Pages: 3, Title: "Älp"}, false},
+		4: {"application/pdf", "not a pdf", PDFInfo{}, true},
+		5: {"image/png", simplePDF, PDFInfo{}, true},
+	}
+
+	for index, td := range testData {
+		a := Attachment{ContentType: td.contentType, Data: bytes.NewReader([]byte(td.data))}
+
+		info, err := a.PDFInfo()
+		if td.err {
+			if err == nil {
+				t.Errorf("[Test Case %v] Expected an error", index)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[Test Case %v] Unexpected error: %v", index, err)
+			continue
+		}
+
+		if *info != td.info {
+			t.Errorf("[Test Case %v] Wrong pdf info. Expected: %+v, Got: %+v", index, td.info, *info)
+		}
+	}
+}
+
+var simplePDF = `%PDF-1.4
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R /Outlines 6 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R >>
+endobj
+4 0 obj
+<< /Type /Page /Parent 2 0 R >>
+endobj
+5 0 obj
+<< /Title (Quarterly \(Q3\) \162eport) /Producer (test) >>
+endobj
+6 0 obj
+<< /Type /Outlines /Title (Chapter 1) /Count 5 >>
+endobj
+trailer
+<< /Size 7 /Root 1 0 R /Info 5 0 R >>
+%%EOF
+`
+
+var encryptedPDF = `%PDF-1.4
+1 0 obj
+<< /Type /Pages /Kids [2 0 R] /Count 1 >>
+endobj
+3 0 obj
+<< /Title <8a9f03> >>
+endobj
+trailer
+<< /Root 4 0 R /Info 3 0 R /Encrypt 5 0 R >>
+%%EOF
+`
+
+func TestPDFInfoInflateLimit(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(make([]byte, maxPDFInflated))
+	zw.Write([]byte("<< /Type /Pages /Count 7 >>"))
+	zw.Close()
+
+	data := "%PDF-1.5\n1 0 obj\n<< /Filter /FlateDecode >>\nstream\n" + compressed.String() + "\nendstream\nendobj\n%%EOF\n"
+
+	a := Attachment{ContentType: "application/pdf", Data: bytes.NewReader([]byte(data))}
+	info, err := a.PDFInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Pages != 0 {
+		t.Errorf("Wrong page count. Expected: 0, Got: %d", info.Pages)
+	}
+}
@@ -0,0 +1,149 @@
+package parsemail
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	FindingHTMLForm       FindingCode = "html-form"
+	FindingCredentialForm FindingCode = "credential-form"
+	FindingPasswordInput  FindingCode = "password-input"
+	FindingDataURL        FindingCode = "data-url"
+)
+
+// credentialInputNames match the names of form inputs asking for an account
+var credentialInputNames = regexp.MustCompile(`(?i)user|login|e-?mail|account|pass|pwd|pin|otp|ssn|card|cvv`)
+
+// htmlForm collects what a form of an html body asks for
+type htmlForm struct {
+	action      string
+	method      string
+	password    bool
+	credentials []string
+}
+
+// CheckHTMLPhishing reports the html body features of credential phishing:
+// forms, forms asking for account names or passwords, password inputs
+// outside of forms and data URLs that carry documents instead of images.
+func (e *Email) CheckHTMLPhishing() (findings []Finding) {
+	var form *htmlForm
+	dataURLs := 0
+
+	closeForm := func() {
+		if form == nil {
+			return
+		}
+
+		findings = append(findings, Finding{
+			Code:    FindingHTMLForm,
+			Message: fmt.Sprintf("html body contains a form submitting with %s to %q", form.method, form.action),
+		})
+
+		if form.password || len(form.credentials) > 0 {
+			findings = append(findings, Finding{
+				Code:    FindingCredentialForm,
+				Message: fmt.Sprintf("form submitting to %q asks for %s", form.action, formFields(form)),
+			})
+		}
+
+		form = nil
+	}
+
+	z := html.NewTokenizer(strings.NewReader(e.HTMLBody))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		name, hasAttr := z.TagName()
+		tag := string(name)
+
+		if tt == html.EndTagToken {
+			if tag == "form" {
+				closeForm()
+			}
+			continue
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		attrs := map[string]string{}
+		for hasAttr {
+			var key, val []byte
+			key, val, hasAttr = z.TagAttr()
+			attrs[string(key)] = string(val)
+		}
+
+		for _, attr := range []string{"href", "src", "action", "data", "formaction"} {
+			if isDocumentDataURL(tag, attr, attrs[attr]) {
+				dataURLs++
+			}
+		}
+
+		switch tag {
+		case "form":
+			closeForm()
+
+			method := strings.ToUpper(attrs["method"])
+			if method == "" {
+				method = "GET"
+			}
+			form = &htmlForm{action: attrs["action"], method: method}
+		case "input":
+			inputType := strings.ToLower(attrs["type"])
+			switch {
+			case form == nil && inputType == "password":
+				findings = append(findings, Finding{
+					Code:    FindingPasswordInput,
+					Message: fmt.Sprintf("html body contains a password input %q outside of a form", attrs["name"]),
+				})
+			case form == nil:
+			case inputType == "password":
+				form.password = true
+			case inputType != "hidden" && inputType != "submit" && inputType != "button" &&
+				credentialInputNames.MatchString(attrs["name"]+" "+attrs["id"]+" "+attrs["placeholder"]):
+				form.credentials = append(form.credentials, attrs["name"])
+			}
+		}
+	}
+
+	// a form left open by a truncated body still counts
+	closeForm()
+
+	if dataURLs > 0 {
+		findings = append(findings, Finding{
+			Code:    FindingDataURL,
+			Message: fmt.Sprintf("html body contains %d data URLs with documents or scripts", dataURLs),
+		})
+	}
+
+	return
+}
+
+// isDocumentDataURL reports whether the attribute of a tag is a data URL
+// with anything else than an image shown by an img tag
+func isDocumentDataURL(tag, attr, value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if !strings.HasPrefix(value, "data:") {
+		return false
+	}
+
+	return !(tag == "img" && attr == "src" && strings.HasPrefix(value, "data:image/") &&
+		!strings.HasPrefix(value, "data:image/svg"))
+}
+
+// formFields describes the credentials a form asks for
+func formFields(form *htmlForm) string {
+	fields := form.credentials
+	if form.password {
+		fields = append([]string{"a password"}, fields...)
+	}
+
+	return strings.Join(fields, ", ")
+}
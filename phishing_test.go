@@ -0,0 +1,55 @@
+package parsemail
+
+import (
+	"testing"
+)
+
+func TestCheckHTMLPhishing(t *testing.T) {
+	var testData = map[int]struct {
+		html  string
+		codes []string
+	}{
+		1: {html: `<p>Hello <img src="data:image/png;base64,iVBORw0KGgo="> <a href="https://example.com">site</a></p>`},
+		2: {
+			html:  `<form action="https://example.com/search"><input name="q"><input type="submit"></form>`,
+			codes: []string{string(FindingHTMLForm)},
+		},
+		3: {
+			html: `<FORM method="post" action="https://login.example.net/verify">
+<input type="email" name="email" placeholder="Email">
+<input type="password" name="pw">
+<input type="hidden" name="token" value="x">
+</FORM>`,
+			codes: []string{string(FindingHTMLForm), string(FindingCredentialForm)},
+		},
+		4: {
+			html:  `<div><input type="password" name="pass"></div>`,
+			codes: []string{string(FindingPasswordInput)},
+		},
+		5: {
+			html:  `<a href="data:text/html;base64,PHNjcmlwdD4=">Open document</a><iframe src="data:text/html,x"></iframe>`,
+			codes: []string{string(FindingDataURL)},
+		},
+		6: {
+			html:  `<form action="https://evil.example/"><input name="username">`,
+			codes: []string{string(FindingHTMLForm), string(FindingCredentialForm)},
+		},
+		7: {
+			html:  `<img src="data:image/svg+xml;base64,PHN2Zz4=">`,
+			codes: []string{string(FindingDataURL)},
+		},
+	}
+
+	for index, td := range testData {
+		e := Email{HTMLBody: td.html}
+
+		var codes []string
+		for _, f := range e.CheckHTMLPhishing() {
+			codes = append(codes, string(f.Code))
+		}
+
+		if !assertSliceEq(codes, td.codes) {
+			t.Errorf("[Test Case %v] Wrong findings. Expected: %s, Got: %s", index, td.codes, codes)
+		}
+	}
+}
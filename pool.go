@@ -0,0 +1,33 @@
+package parsemail
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer keeps buffers grown by huge parts from being held on to
+const maxPooledBuffer = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	return buf
+}
+
+// putBuffer returns buf to the pool. Its content must not be used
+// afterwards.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,45 @@
+package parsemail
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParserConcurrent(t *testing.T) {
+	p := NewParser(Options{MaxDecodedSize: 1 << 20})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				e, err := p.Parse(strings.NewReader(latin1Attachment))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				text, err := e.Attachments[0].DecodedText()
+				if err != nil || text != "name;city\nJürgen;Köln\n" || e.TextBody != "See attachment." {
+					t.Errorf("Wrong result of concurrent parse: %q, %q, %v", e.TextBody, text, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkParserMultipart(b *testing.B) {
+	p := NewParser(Options{})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Parse(strings.NewReader(latin1Attachment)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
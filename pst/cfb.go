@@ -0,0 +1,249 @@
+package pst
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// errNotCompoundFile is returned for files that aren't compound files
+var errNotCompoundFile = errors.New("msg: not an outlook .msg file")
+
+const (
+	cfbSignature = "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"
+
+	cfbEndOfChain = 0xfffffffe
+	cfbNoStream   = 0xffffffff
+
+	cfbStorage = 1
+	cfbStream  = 2
+	cfbRoot    = 5
+
+	cfbMiniSectorSize = 64
+	cfbEntrySize      = 128
+	// cfbMaxDIFAT is the number of DIFAT sectors read, enough for files of
+	// several terabytes
+	cfbMaxDIFAT = 1 << 16
+)
+
+// compoundFile is a Compound File Binary file (MS-CFB), the container of
+// Outlook .msg files: a small file system of storages and streams
+type compoundFile struct {
+	data       []byte
+	sectorSize int
+	cutoff     uint64
+	fat        []uint32
+	miniFAT    []uint32
+	miniStream []byte
+	entries    []cfbEntry
+}
+
+// cfbEntry is an entry of the directory of a compound file
+type cfbEntry struct {
+	name               string
+	kind               byte
+	left, right, child uint32
+	start              uint32
+	size               uint64
+}
+
+func newCompoundFile(data []byte) (*compoundFile, error) {
+	if len(data) < 512 || string(data[:8]) != cfbSignature {
+		return nil, errNotCompoundFile
+	}
+
+	cf := &compoundFile{data: data, cutoff: uint64(le.Uint32(data[0x38:]))}
+	switch shift := le.Uint16(data[0x1e:]); shift {
+	case 9, 12:
+		cf.sectorSize = 1 << shift
+	default:
+		return nil, fmt.Errorf("msg: invalid sector size 2^%d", shift)
+	}
+
+	// the sectors of the FAT are listed by the header and a chain of DIFAT
+	// sectors, whose last entry points to the next one
+	var fatSectors []uint32
+	for i := 0; i < 109; i++ {
+		fatSectors = append(fatSectors, le.Uint32(data[0x4c+i*4:]))
+	}
+	next := le.Uint32(data[0x44:])
+	for i := 0; next < cfbEndOfChain && i < cfbMaxDIFAT; i++ {
+		sector, err := cf.sector(next)
+		if err != nil {
+			return nil, err
+		}
+		for j := 0; j+4 < len(sector); j += 4 {
+			fatSectors = append(fatSectors, le.Uint32(sector[j:]))
+		}
+		next = le.Uint32(sector[len(sector)-4:])
+	}
+
+	fatLen := int(le.Uint32(data[0x2c:]))
+	for _, n := range fatSectors {
+		if len(cf.fat) >= fatLen*cf.sectorSize/4 || n >= cfbEndOfChain {
+			break
+		}
+		sector, err := cf.sector(n)
+		if err != nil {
+			return nil, err
+		}
+		cf.fat = append(cf.fat, uint32s(sector)...)
+	}
+
+	dir, err := cf.readChain(le.Uint32(data[0x30:]), false, -1)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+cfbEntrySize <= len(dir); i += cfbEntrySize {
+		cf.entries = append(cf.entries, parseCFBEntry(dir[i:i+cfbEntrySize], cf.sectorSize))
+	}
+	if len(cf.entries) == 0 || cf.entries[0].kind != cfbRoot {
+		return nil, errors.New("msg: compound file without root storage")
+	}
+
+	if first := le.Uint32(data[0x3c:]); first < cfbEndOfChain {
+		miniFAT, err := cf.readChain(first, false, -1)
+		if err != nil {
+			return nil, err
+		}
+		cf.miniFAT = uint32s(miniFAT)
+	}
+
+	// the mini stream holding the small streams is the stream of the root
+	root := cf.entries[0]
+	if cf.miniStream, err = cf.readChain(root.start, false, int64(root.size)); err != nil {
+		return nil, err
+	}
+
+	return cf, nil
+}
+
+func parseCFBEntry(b []byte, sectorSize int) cfbEntry {
+	e := cfbEntry{
+		kind:  b[0x42],
+		left:  le.Uint32(b[0x44:]),
+		right: le.Uint32(b[0x48:]),
+		child: le.Uint32(b[0x4c:]),
+		start: le.Uint32(b[0x74:]),
+		size:  le.Uint64(b[0x78:]),
+	}
+
+	// files with 512 byte sectors may leave garbage in the high half
+	if sectorSize == 512 {
+		e.size &= 0xffffffff
+	}
+
+	n := int(le.Uint16(b[0x40:]))/2 - 1
+	if n > 31 {
+		n = 31
+	}
+	name := make([]uint16, 0, 32)
+	for i := 0; i < n; i++ {
+		name = append(name, le.Uint16(b[i*2:]))
+	}
+	e.name = string(utf16.Decode(name))
+
+	return e
+}
+
+// sector returns the regular sector n. The last sector of the file may be
+// truncated.
+func (cf *compoundFile) sector(n uint32) ([]byte, error) {
+	offset := (int64(n) + 1) * int64(cf.sectorSize)
+	if n >= cfbEndOfChain || offset >= int64(len(cf.data)) {
+		return nil, fmt.Errorf("msg: sector %d out of range", n)
+	}
+
+	end := offset + int64(cf.sectorSize)
+	if end > int64(len(cf.data)) {
+		end = int64(len(cf.data))
+	}
+
+	return cf.data[offset:end], nil
+}
+
+// readChain reads the sectors chained from start by the FAT, or the mini
+// sectors chained by the mini FAT if mini is set, up to size bytes unless
+// size is negative
+func (cf *compoundFile) readChain(start uint32, mini bool, size int64) ([]byte, error) {
+	fat := cf.fat
+	if mini {
+		fat = cf.miniFAT
+	}
+
+	var buf []byte
+	for n, steps := start, 0; n != cfbEndOfChain; steps++ {
+		if size >= 0 && int64(len(buf)) >= size {
+			break
+		}
+		if n >= uint32(len(fat)) || steps > len(fat) {
+			return nil, fmt.Errorf("msg: corrupt sector chain at %d", n)
+		}
+
+		if mini {
+			offset := int(n) * cfbMiniSectorSize
+			if offset+cfbMiniSectorSize > len(cf.miniStream) {
+				return nil, fmt.Errorf("msg: mini sector %d out of range", n)
+			}
+			buf = append(buf, cf.miniStream[offset:offset+cfbMiniSectorSize]...)
+		} else {
+			sector, err := cf.sector(n)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, sector...)
+		}
+
+		n = fat[n]
+	}
+
+	if size >= 0 {
+		if int64(len(buf)) < size {
+			return nil, errors.New("msg: truncated stream")
+		}
+		buf = buf[:size]
+	}
+
+	return buf, nil
+}
+
+// stream returns the content of the stream entry e
+func (cf *compoundFile) stream(e cfbEntry) ([]byte, error) {
+	if e.size == 0 {
+		return nil, nil
+	}
+
+	return cf.readChain(e.start, e.size < cf.cutoff, int64(e.size))
+}
+
+// children returns the entries of the storage i by their upper case names
+func (cf *compoundFile) children(i uint32) map[string]uint32 {
+	children := map[string]uint32{}
+	visited := map[uint32]bool{}
+
+	var walk func(n uint32)
+	walk = func(n uint32) {
+		if n == cfbNoStream || n >= uint32(len(cf.entries)) || visited[n] {
+			return
+		}
+		visited[n] = true
+
+		e := cf.entries[n]
+		children[strings.ToUpper(e.name)] = n
+		walk(e.left)
+		walk(e.right)
+	}
+	walk(cf.entries[i].child)
+
+	return children
+}
+
+func uint32s(b []byte) []uint32 {
+	u := make([]uint32, len(b)/4)
+	for i := range u {
+		u[i] = le.Uint32(b[i*4:])
+	}
+
+	return u
+}
@@ -0,0 +1,269 @@
+package pst
+
+import (
+	"fmt"
+	"unicode/utf16"
+)
+
+// Property types used by messages and attachments
+const (
+	ptypInteger16 = 0x0002
+	ptypInteger32 = 0x0003
+	ptypBoolean   = 0x000b
+	ptypInteger64 = 0x0014
+	ptypString8   = 0x001e
+	ptypString    = 0x001f
+	ptypTime      = 0x0040
+	ptypBinary    = 0x0102
+)
+
+// heap is a heap-on-node, the allocator of the items of a property or table
+// context inside the data blocks of a node
+type heap struct {
+	blocks [][]byte
+}
+
+// heapSignature and clientPC identify a heap holding a property context
+const (
+	heapSignature = 0xec
+	clientPC      = 0xbc
+	bthSignature  = 0xb5
+)
+
+func newHeap(blocks [][]byte) (*heap, error) {
+	if len(blocks) == 0 || len(blocks[0]) < 12 || blocks[0][2] != heapSignature {
+		return nil, fmt.Errorf("corrupt heap")
+	}
+
+	return &heap{blocks: blocks}, nil
+}
+
+// clientSig returns the signature of the structure stored in the heap
+func (h *heap) clientSig() byte {
+	return h.blocks[0][3]
+}
+
+// userRoot returns the HID of the structure stored in the heap
+func (h *heap) userRoot() uint32 {
+	return le.Uint32(h.blocks[0][4:])
+}
+
+// item returns the heap item identified by hid
+func (h *heap) item(hid uint32) ([]byte, error) {
+	if hid&0x1f != 0 {
+		return nil, fmt.Errorf("%#x is not a heap id", hid)
+	}
+
+	index := int(hid>>5) & 0x7ff
+	block := int(hid >> 16)
+	if block >= len(h.blocks) || index == 0 {
+		return nil, fmt.Errorf("heap id %#x out of range", hid)
+	}
+
+	data := h.blocks[block]
+	if len(data) < 2 {
+		return nil, fmt.Errorf("corrupt heap block %d", block)
+	}
+
+	ibHnpm := int(le.Uint16(data))
+	if ibHnpm+4 > len(data) {
+		return nil, fmt.Errorf("corrupt heap page map in block %d", block)
+	}
+
+	cAlloc := int(le.Uint16(data[ibHnpm:]))
+	if index > cAlloc || ibHnpm+4+(cAlloc+1)*2 > len(data) {
+		return nil, fmt.Errorf("heap id %#x out of range", hid)
+	}
+
+	start := int(le.Uint16(data[ibHnpm+4+(index-1)*2:]))
+	end := int(le.Uint16(data[ibHnpm+4+index*2:]))
+	if start > end || end > len(data) {
+		return nil, fmt.Errorf("corrupt heap item %#x", hid)
+	}
+
+	return data[start:end], nil
+}
+
+// bthRecords returns the leaf records of the B-tree on heap at hid as pairs
+// of key and data
+func (h *heap) bthRecords(hid uint32) (keys, values [][]byte, err error) {
+	header, err := h.item(hid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(header) < 8 || header[0] != bthSignature {
+		return nil, nil, fmt.Errorf("corrupt b-tree on heap")
+	}
+
+	cbKey, cbEnt, levels := int(header[1]), int(header[2]), int(header[3])
+	root := le.Uint32(header[4:])
+	if root == 0 {
+		return nil, nil, nil
+	}
+
+	if cbKey+cbEnt == 0 {
+		return nil, nil, fmt.Errorf("corrupt b-tree on heap: empty records")
+	}
+
+	visited := map[uint32]bool{}
+	var walk func(hid uint32, level int) error
+	walk = func(hid uint32, level int) error {
+		if visited[hid] {
+			return fmt.Errorf("corrupt b-tree on heap: %#x referenced twice", hid)
+		}
+		visited[hid] = true
+
+		records, err := h.item(hid)
+		if err != nil {
+			return err
+		}
+
+		size := cbKey + cbEnt
+		if level > 0 {
+			size = cbKey + 4
+		}
+
+		for i := 0; i+size <= len(records); i += size {
+			if level > 0 {
+				if err := walk(le.Uint32(records[i+cbKey:]), level-1); err != nil {
+					return err
+				}
+				continue
+			}
+
+			keys = append(keys, records[i:i+cbKey])
+			values = append(values, records[i+cbKey:i+size])
+		}
+
+		return nil
+	}
+
+	return keys, values, walk(root, levels)
+}
+
+// property is a single value of a property context
+type property struct {
+	ptype uint16
+	value []byte
+}
+
+// propertyContext is the set of properties of a message or attachment
+type propertyContext map[uint16]property
+
+// readPropertyContext decodes the property context stored in the data of a
+// node. Values stored in subnodes are read with the subnodes of the node.
+func (pr *Reader) readPropertyContext(node nbtEntry) (propertyContext, map[uint32]nbtEntry, error) {
+	blocks, err := pr.readDataBlocks(node.bidData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h, err := newHeap(blocks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if h.clientSig() != clientPC {
+		return nil, nil, fmt.Errorf("node %#x is not a property context", node.nid)
+	}
+
+	subnodes, err := pr.readSubnodes(node.bidSub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys, values, err := h.bthRecords(h.userRoot())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pc := propertyContext{}
+	for i := range keys {
+		if len(keys[i]) != 2 || len(values[i]) != 6 {
+			return nil, nil, fmt.Errorf("corrupt property context")
+		}
+
+		id, ptype, hnid := le.Uint16(keys[i]), le.Uint16(values[i]), le.Uint32(values[i][2:])
+
+		var value []byte
+		switch ptype {
+		case ptypInteger16, ptypInteger32, ptypBoolean:
+			value = values[i][2:6]
+		default:
+			if hnid == 0 {
+				break
+			}
+
+			if hnid&0x1f == 0 {
+				value, err = h.item(hnid)
+			} else if sub, ok := subnodes[hnid]; ok {
+				value, err = pr.readData(sub.bidData)
+			} else {
+				err = fmt.Errorf("subnode %#x not found", hnid)
+			}
+
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		pc[id] = property{ptype: ptype, value: value}
+	}
+
+	return pc, subnodes, nil
+}
+
+// string returns a string property, or "" if it is missing
+func (pc propertyContext) string(id uint16) string {
+	p, ok := pc[id]
+	if !ok {
+		return ""
+	}
+
+	switch p.ptype {
+	case ptypString:
+		u := make([]uint16, len(p.value)/2)
+		for i := range u {
+			u[i] = le.Uint16(p.value[i*2:])
+		}
+		return string(utf16.Decode(u))
+	case ptypString8, ptypBinary:
+		return string(p.value)
+	}
+
+	return ""
+}
+
+// bytes returns a binary or string property, or nil if it is missing
+func (pc propertyContext) bytes(id uint16) []byte {
+	p, ok := pc[id]
+	if !ok {
+		return nil
+	}
+
+	if p.ptype == ptypString {
+		return []byte(pc.string(id))
+	}
+
+	return p.value
+}
+
+// int returns an integer property, or 0 if it is missing
+func (pc propertyContext) int(id uint16) int64 {
+	p, ok := pc[id]
+	if !ok {
+		return 0
+	}
+
+	switch {
+	case (p.ptype == ptypInteger16 || p.ptype == ptypBoolean) && len(p.value) >= 2:
+		return int64(int16(le.Uint16(p.value)))
+	case p.ptype == ptypInteger32 && len(p.value) >= 4:
+		return int64(int32(le.Uint32(p.value)))
+	case (p.ptype == ptypInteger64 || p.ptype == ptypTime) && len(p.value) >= 8:
+		return int64(le.Uint64(p.value))
+	}
+
+	return 0
+}
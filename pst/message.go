@@ -0,0 +1,340 @@
+package pst
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/patrick-othmer/parsemail"
+)
+
+// Property ids of messages and attachments
+const (
+	pidTagMessageClass            = 0x001a
+	pidTagSubject                 = 0x0037
+	pidTagClientSubmitTime        = 0x0039
+	pidTagTransportMessageHeaders = 0x007d
+	pidTagSenderName              = 0x0c1a
+	pidTagSenderEmailAddress      = 0x0c1f
+	pidTagRecipientType           = 0x0c15
+	pidTagDisplayCc               = 0x0e03
+	pidTagDisplayTo               = 0x0e04
+	pidTagMessageDeliveryTime     = 0x0e06
+	pidTagBody                    = 0x1000
+	pidTagHTML                    = 0x1013
+	pidTagInternetMessageID       = 0x1035
+	pidTagDisplayName             = 0x3001
+	pidTagEmailAddress            = 0x3003
+	pidTagAttachDataBinary        = 0x3701
+	pidTagAttachFilename          = 0x3704
+	pidTagAttachMethod            = 0x3705
+	pidTagAttachLongFilename      = 0x3707
+	pidTagAttachMimeTag           = 0x370e
+	pidTagAttachContentID         = 0x3712
+	pidTagInternetCodepage        = 0x3fde
+	pidTagSMTPAddress             = 0x39fe
+	pidTagSenderSMTPAddress       = 0x5d01
+
+	attachByValue = 1
+
+	recipientTo = 1
+	recipientCc = 2
+)
+
+// codepageCharsets maps the Windows code pages common for html bodies to
+// charset names
+var codepageCharsets = map[int64]string{
+	1250:  "windows-1250",
+	1251:  "windows-1251",
+	1252:  "windows-1252",
+	20127: "us-ascii",
+	28591: "iso-8859-1",
+	28592: "iso-8859-2",
+	28605: "iso-8859-15",
+	50220: "iso-2022-jp",
+	51932: "euc-jp",
+	932:   "shift_jis",
+	936:   "gb2312",
+	949:   "euc-kr",
+	950:   "big5",
+	65001: "utf-8",
+}
+
+type message struct {
+	props       propertyContext
+	attachments []propertyContext
+	// recipients are only known for .msg files, PST messages store just
+	// their display names
+	recipients []propertyContext
+}
+
+func (pr *Reader) readMessage(node nbtEntry) (*message, error) {
+	props, subnodes, err := pr.readPropertyContext(node)
+	if err != nil {
+		return nil, err
+	}
+
+	var nids []uint32
+	for nid := range subnodes {
+		if nid&0x1f == nidTypeAttachment {
+			nids = append(nids, nid)
+		}
+	}
+	sort.Slice(nids, func(i, j int) bool { return nids[i] < nids[j] })
+
+	msg := &message{props: props}
+	for _, nid := range nids {
+		at, _, err := pr.readPropertyContext(subnodes[nid])
+		if err != nil {
+			return nil, err
+		}
+
+		if at.int(pidTagAttachMethod) == attachByValue {
+			msg.attachments = append(msg.attachments, at)
+		}
+	}
+
+	return msg, nil
+}
+
+// email builds the MIME message of the stored properties and parses it
+func (m *message) email() (parsemail.Email, error) {
+	raw, err := m.mime()
+	if err != nil {
+		return parsemail.Email{}, err
+	}
+
+	return parsemail.Parse(bytes.NewReader(raw))
+}
+
+// mime builds the MIME message of the stored properties
+func (m *message) mime() ([]byte, error) {
+	var buf bytes.Buffer
+
+	if headers := m.props.string(pidTagTransportMessageHeaders); headers != "" {
+		// the stored headers describe the original MIME structure, which is
+		// rebuilt below
+		s := parsemail.NewHeaderScanner([]byte(headers))
+		for s.Next() {
+			if s.Is("Content-Type") || s.Is("Content-Transfer-Encoding") || s.Is("MIME-Version") {
+				continue
+			}
+			fmt.Fprintf(&buf, "%s: %s\r\n", s.Name(), s.Value())
+		}
+	} else {
+		m.writeHeader(&buf)
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+
+	if err := m.writeBodies(mw); err != nil {
+		return nil, err
+	}
+
+	for _, at := range m.attachments {
+		if err := writeAttachment(mw, at); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeHeader synthesizes the header of messages stored without transport
+// headers, like drafts and sent items
+func (m *message) writeHeader(w io.Writer) {
+	sender := m.props.string(pidTagSenderSMTPAddress)
+	if sender == "" && strings.Contains(m.props.string(pidTagSenderEmailAddress), "@") {
+		sender = m.props.string(pidTagSenderEmailAddress)
+	}
+
+	if sender != "" {
+		fmt.Fprintf(w, "From: %s <%s>\r\n", mime.QEncoding.Encode("utf-8", m.props.string(pidTagSenderName)), sender)
+	}
+
+	if len(m.recipients) > 0 {
+		m.writeRecipients(w, "To", recipientTo)
+		m.writeRecipients(w, "Cc", recipientCc)
+	} else {
+		// only the display names of the recipients are stored with the message
+		if to := m.props.string(pidTagDisplayTo); to != "" {
+			fmt.Fprintf(w, "X-Display-To: %s\r\n", mime.QEncoding.Encode("utf-8", to))
+		}
+
+		if cc := m.props.string(pidTagDisplayCc); cc != "" {
+			fmt.Fprintf(w, "X-Display-Cc: %s\r\n", mime.QEncoding.Encode("utf-8", cc))
+		}
+	}
+
+	fmt.Fprintf(w, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject(m.props.string(pidTagSubject))))
+
+	date := m.props.int(pidTagClientSubmitTime)
+	if date == 0 {
+		date = m.props.int(pidTagMessageDeliveryTime)
+	}
+
+	if date != 0 {
+		fmt.Fprintf(w, "Date: %s\r\n", filetime(date).Format(time.RFC1123Z))
+	}
+
+	if id := m.props.string(pidTagInternetMessageID); id != "" {
+		fmt.Fprintf(w, "Message-ID: %s\r\n", id)
+	}
+
+	if class := m.props.string(pidTagMessageClass); class != "" {
+		fmt.Fprintf(w, "X-Message-Class: %s\r\n", class)
+	}
+}
+
+// writeRecipients writes the header field name with the recipients of the
+// type, leaving out those without an address like Bcc recipients
+func (m *message) writeRecipients(w io.Writer, name string, recipientType int64) {
+	var addrs []string
+	for _, r := range m.recipients {
+		if r.int(pidTagRecipientType) != recipientType {
+			continue
+		}
+
+		addr := r.string(pidTagSMTPAddress)
+		if addr == "" && strings.Contains(r.string(pidTagEmailAddress), "@") {
+			addr = r.string(pidTagEmailAddress)
+		}
+		if addr == "" {
+			continue
+		}
+
+		// display names often hold commas, like "Doe, John"
+		addrs = append(addrs, (&mail.Address{Name: r.string(pidTagDisplayName), Address: addr}).String())
+	}
+
+	if len(addrs) > 0 {
+		fmt.Fprintf(w, "%s: %s\r\n", name, strings.Join(addrs, ", "))
+	}
+}
+
+func (m *message) writeBodies(mw *multipart.Writer) error {
+	text := m.props.string(pidTagBody)
+	html := m.props.bytes(pidTagHTML)
+	if text == "" && len(html) == 0 {
+		return nil
+	}
+
+	var alt bytes.Buffer
+	aw := multipart.NewWriter(&alt)
+
+	if text != "" {
+		if err := writeBase64Part(aw, textproto.MIMEHeader{
+			"Content-Type": {"text/plain; charset=utf-8"},
+		}, []byte(text)); err != nil {
+			return err
+		}
+	}
+
+	if len(html) > 0 {
+		contentType := "text/html"
+		if m.props[pidTagHTML].ptype == ptypString {
+			contentType += "; charset=utf-8"
+		} else if charset, ok := codepageCharsets[m.props.int(pidTagInternetCodepage)]; ok {
+			contentType += "; charset=" + charset
+		}
+
+		if err := writeBase64Part(aw, textproto.MIMEHeader{
+			"Content-Type": {contentType},
+		}, html); err != nil {
+			return err
+		}
+	}
+
+	if err := aw.Close(); err != nil {
+		return err
+	}
+
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": aw.Boundary()})},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = pw.Write(alt.Bytes())
+
+	return err
+}
+
+func writeAttachment(mw *multipart.Writer, at propertyContext) error {
+	filename := at.string(pidTagAttachLongFilename)
+	if filename == "" {
+		filename = at.string(pidTagAttachFilename)
+	}
+
+	contentType := at.string(pidTagAttachMimeTag)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType(contentType, map[string]string{"name": filename})},
+	}
+
+	disposition := "attachment"
+	if cid := at.string(pidTagAttachContentID); cid != "" {
+		disposition = "inline"
+		header.Set("Content-Id", "<"+strings.Trim(cid, "<>")+">")
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+
+	return writeBase64Part(mw, header, at.bytes(pidTagAttachDataBinary))
+}
+
+// writeBase64Part writes a base64 encoded part with lines of 76 characters
+func writeBase64Part(mw *multipart.Writer, header textproto.MIMEHeader, data []byte) error {
+	header.Set("Content-Transfer-Encoding", "base64")
+
+	pw, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(pw, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+
+	_, err = io.WriteString(pw, encoded+"\r\n")
+
+	return err
+}
+
+// subject strips the prefix marker PST files may store in front of the
+// subject
+func subject(s string) string {
+	if len(s) >= 2 && s[0] == 0x01 {
+		return s[2:]
+	}
+
+	return s
+}
+
+// filetime converts a Windows FILETIME, 100ns intervals since 1601, to a
+// time
+func filetime(ft int64) time.Time {
+	const epochDiff = 116444736000000000 // 1601 to 1970 in 100ns
+
+	return time.Unix(0, (ft-epochDiff)*100).UTC()
+}
@@ -0,0 +1,145 @@
+package pst
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/patrick-othmer/parsemail"
+)
+
+// Storages and streams of a .msg file (MS-OXMSG)
+const (
+	msgPropertiesStream  = "__PROPERTIES_VERSION1.0"
+	msgRecipientPrefix   = "__RECIP_VERSION1.0_#"
+	msgAttachmentPrefix  = "__ATTACH_VERSION1.0_#"
+	msgSubstorageFormat  = "__SUBSTG1.0_%04X%04X"
+	msgMessageHeaderSize = 32
+	msgEntryHeaderSize   = 8
+
+	// multi-valued properties have this flag in their type
+	ptypMultiple = 0x1000
+)
+
+// ParseMsg parses an Outlook .msg file, which holds a single message
+func ParseMsg(r io.Reader) (parsemail.Email, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return parsemail.Email{}, err
+	}
+
+	raw, err := ConvertMsg(data)
+	if err != nil {
+		return parsemail.Email{}, err
+	}
+
+	return parsemail.Parse(bytes.NewReader(raw))
+}
+
+// ConvertMsg converts the Outlook .msg file data into a MIME message, like
+// the messages of a PST file. Only attachments stored by value are
+// included. It fits the Msg field of a parsemail.Converter.
+func ConvertMsg(data []byte) ([]byte, error) {
+	cf, err := newCompoundFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	root := cf.children(0)
+	props, err := cf.readProperties(root, msgMessageHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &message{props: props}
+	for _, name := range sortedStorages(root, msgRecipientPrefix) {
+		r, err := cf.readProperties(cf.children(root[name]), msgEntryHeaderSize)
+		if err != nil {
+			return nil, err
+		}
+		msg.recipients = append(msg.recipients, r)
+	}
+
+	for _, name := range sortedStorages(root, msgAttachmentPrefix) {
+		at, err := cf.readProperties(cf.children(root[name]), msgEntryHeaderSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if at.int(pidTagAttachMethod) == attachByValue {
+			msg.attachments = append(msg.attachments, at)
+		}
+	}
+
+	return msg.mime()
+}
+
+// sortedStorages returns the names of the storages starting with prefix,
+// in the order of their numbers
+func sortedStorages(children map[string]uint32, prefix string) []string {
+	var names []string
+	for name := range children {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// readProperties reads the property stream of a storage, which starts with
+// a header of headerSize bytes, followed by entries of 16 bytes. Values of
+// variable size are stored in streams of their own.
+func (cf *compoundFile) readProperties(children map[string]uint32, headerSize int) (propertyContext, error) {
+	i, ok := children[msgPropertiesStream]
+	if !ok {
+		return nil, fmt.Errorf("msg: storage without properties")
+	}
+
+	data, err := cf.stream(cf.entries[i])
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("msg: truncated properties")
+	}
+
+	pc := propertyContext{}
+	for entry := data[headerSize:]; len(entry) >= 16; entry = entry[16:] {
+		tag := le.Uint32(entry)
+		id, ptype := uint16(tag>>16), uint16(tag)
+
+		var value []byte
+		switch {
+		case ptype&ptypMultiple != 0:
+			continue
+		case ptype == ptypString || ptype == ptypString8 || ptype == ptypBinary:
+			sub, ok := children[fmt.Sprintf(msgSubstorageFormat, id, ptype)]
+			if !ok {
+				continue
+			}
+			if value, err = cf.stream(cf.entries[sub]); err != nil {
+				return nil, err
+			}
+
+			// strings may be stored with their terminating null
+			if ptype == ptypString {
+				for len(value) >= 2 && value[len(value)-2] == 0 && value[len(value)-1] == 0 {
+					value = value[:len(value)-2]
+				}
+			} else if ptype == ptypString8 {
+				value = bytes.TrimRight(value, "\x00")
+			}
+		default:
+			value = append([]byte(nil), entry[8:16]...)
+		}
+
+		pc[id] = property{ptype: ptype, value: value}
+	}
+
+	return pc, nil
+}
@@ -0,0 +1,287 @@
+package pst
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// cfbNode is a storage or stream of a compound file written by buildCFB
+type cfbNode struct {
+	name     string
+	data     []byte
+	storage  bool
+	children []*cfbNode
+}
+
+// buildCFB writes a version 3 compound file with 512 byte sectors, a single
+// FAT sector and the streams smaller than 4096 bytes in the mini stream
+func buildCFB(t *testing.T, root *cfbNode) []byte {
+	var sectors [][]byte
+	fat := []uint32{0xfffffffd}
+	addChain := func(data []byte) uint32 {
+		start := uint32(len(fat))
+		for len(data) > 0 {
+			n := len(data)
+			if n > 512 {
+				n = 512
+			}
+			sector := make([]byte, 512)
+			copy(sector, data[:n])
+			data = data[n:]
+
+			sectors = append(sectors, sector)
+			fat = append(fat, uint32(len(fat))+1)
+		}
+		fat[len(fat)-1] = cfbEndOfChain
+
+		return start
+	}
+
+	// the entries in breadth first order, siblings chained by their right
+	// pointers
+	nodes := []*cfbNode{root}
+	for i := 0; i < len(nodes); i++ {
+		nodes = append(nodes, nodes[i].children...)
+	}
+	index := map[*cfbNode]uint32{}
+	for i, n := range nodes {
+		index[n] = uint32(i)
+	}
+
+	var miniStream []byte
+	var miniFAT []uint32
+	starts := make([]uint32, len(nodes))
+	for i, n := range nodes {
+		switch {
+		case n.storage || len(n.data) == 0:
+			starts[i] = cfbEndOfChain
+		case len(n.data) >= 4096:
+			starts[i] = addChain(n.data)
+		default:
+			starts[i] = uint32(len(miniFAT))
+			for data := n.data; len(data) > 0; {
+				sector := make([]byte, cfbMiniSectorSize)
+				data = data[copy(sector, data):]
+				miniStream = append(miniStream, sector...)
+				miniFAT = append(miniFAT, uint32(len(miniFAT))+1)
+			}
+			miniFAT[len(miniFAT)-1] = cfbEndOfChain
+		}
+	}
+
+	starts[0] = cfbEndOfChain
+	if len(miniStream) > 0 {
+		starts[0] = addChain(miniStream)
+	}
+	miniFATStart := uint32(cfbEndOfChain)
+	if len(miniFAT) > 0 {
+		b := make([]byte, len(miniFAT)*4)
+		for i, n := range miniFAT {
+			le.PutUint32(b[i*4:], n)
+		}
+		miniFATStart = addChain(b)
+	}
+
+	dir := make([]byte, len(nodes)*cfbEntrySize)
+	for i, n := range nodes {
+		e := dir[i*cfbEntrySize:]
+		name := utf16.Encode([]rune(n.name + "\x00"))
+		for j, c := range name {
+			le.PutUint16(e[j*2:], c)
+		}
+		le.PutUint16(e[0x40:], uint16(len(name)*2))
+
+		e[0x42] = cfbStream
+		size := uint64(len(n.data))
+		if n.storage {
+			e[0x42] = cfbStorage
+		}
+		if i == 0 {
+			e[0x42], size = cfbRoot, uint64(len(miniStream))
+		}
+
+		le.PutUint32(e[0x44:], cfbNoStream)
+		le.PutUint32(e[0x48:], cfbNoStream)
+		le.PutUint32(e[0x4c:], cfbNoStream)
+		le.PutUint32(e[0x74:], starts[i])
+		le.PutUint64(e[0x78:], size)
+	}
+	for _, n := range nodes {
+		for j, c := range n.children {
+			if j == 0 {
+				le.PutUint32(dir[int(index[n])*cfbEntrySize+0x4c:], index[c])
+			} else {
+				le.PutUint32(dir[int(index[n.children[j-1]])*cfbEntrySize+0x48:], index[c])
+			}
+		}
+	}
+	dirStart := addChain(dir)
+
+	if len(fat) > 128 {
+		t.Fatal("compound file too large for a single FAT sector")
+	}
+
+	data := make([]byte, 1024)
+	copy(data, cfbSignature)
+	le.PutUint16(data[0x18:], 0x3e)
+	le.PutUint16(data[0x1a:], 3)
+	le.PutUint16(data[0x1c:], 0xfffe)
+	le.PutUint16(data[0x1e:], 9)
+	le.PutUint16(data[0x20:], 6)
+	le.PutUint32(data[0x2c:], 1)
+	le.PutUint32(data[0x30:], dirStart)
+	le.PutUint32(data[0x38:], 4096)
+	le.PutUint32(data[0x3c:], miniFATStart)
+	le.PutUint32(data[0x40:], uint32(len(miniFAT)*4+511)/512)
+	le.PutUint32(data[0x44:], cfbEndOfChain)
+	for i := 0; i < 109; i++ {
+		le.PutUint32(data[0x4c+i*4:], cfbNoStream)
+	}
+	le.PutUint32(data[0x4c:], 0)
+
+	// sector 0 is the FAT
+	for i := range data[512:] {
+		data[512+i] = 0xff
+	}
+	for i, n := range fat {
+		le.PutUint32(data[512+i*4:], n)
+	}
+	for _, sector := range sectors {
+		data = append(data, sector...)
+	}
+
+	return data
+}
+
+type msgProp struct {
+	id, ptype uint16
+	value     []byte
+}
+
+func msgString(id uint16, s string) msgProp {
+	u := utf16.Encode([]rune(s + "\x00"))
+	b := make([]byte, len(u)*2)
+	for i, c := range u {
+		le.PutUint16(b[i*2:], c)
+	}
+
+	return msgProp{id: id, ptype: ptypString, value: b}
+}
+
+func msgInt(id uint16, n uint32) msgProp {
+	b := make([]byte, 4)
+	le.PutUint32(b, n)
+
+	return msgProp{id: id, ptype: ptypInteger32, value: b}
+}
+
+// msgStorage returns a storage with the property stream of props, whose
+// variable size values are stored in streams of their own
+func msgStorage(name string, headerSize int, children []*cfbNode, props ...msgProp) *cfbNode {
+	stream := make([]byte, headerSize)
+	for _, p := range props {
+		entry := make([]byte, 16)
+		le.PutUint32(entry, uint32(p.id)<<16|uint32(p.ptype))
+		le.PutUint32(entry[4:], 6)
+
+		switch p.ptype {
+		case ptypString, ptypString8, ptypBinary:
+			le.PutUint32(entry[8:], uint32(len(p.value)))
+			children = append(children, &cfbNode{name: fmt.Sprintf("__substg1.0_%04X%04X", p.id, p.ptype), data: p.value})
+		default:
+			copy(entry[8:], p.value)
+		}
+		stream = append(stream, entry...)
+	}
+
+	children = append(children, &cfbNode{name: "__properties_version1.0", data: stream})
+
+	return &cfbNode{name: name, storage: true, children: children}
+}
+
+func TestParseMsg(t *testing.T) {
+	recipient := func(i int, recipientType uint32, name, addr string) *cfbNode {
+		props := []msgProp{msgInt(pidTagRecipientType, recipientType), msgString(pidTagDisplayName, name)}
+		if addr != "" {
+			props = append(props, msgString(pidTagSMTPAddress, addr))
+		}
+		return msgStorage(fmt.Sprintf("__recip_version1.0_#%08X", i), msgEntryHeaderSize, nil, props...)
+	}
+	attachment := func(i int, method uint32, filename string, data []byte) *cfbNode {
+		return msgStorage(fmt.Sprintf("__attach_version1.0_#%08X", i), msgEntryHeaderSize, nil,
+			msgInt(pidTagAttachMethod, method),
+			msgString(pidTagAttachLongFilename, filename),
+			msgProp{id: pidTagAttachMimeTag, ptype: ptypString8, value: []byte("application/pdf\x00")},
+			msgProp{id: pidTagAttachDataBinary, ptype: ptypBinary, value: data},
+		)
+	}
+
+	large := bytes.Repeat([]byte("0123456789"), 500)
+	root := msgStorage("Root Entry", msgMessageHeaderSize, []*cfbNode{
+		recipient(0, recipientTo, "Doe, John", "jdoe@example.org"),
+		recipient(1, recipientCc, "Mary Smith", "mary@example.org"),
+		recipient(2, recipientTo, "Exchange Only", ""),
+		recipient(3, 3, "Hidden", "hidden@example.org"),
+		attachment(0, attachByValue, "report.pdf", []byte("%PDF-1.4")),
+		attachment(1, 5, "embedded.msg", []byte("skipped")),
+		attachment(2, attachByValue, "large.pdf", large),
+	},
+		msgString(pidTagSubject, "Grüße"),
+		msgString(pidTagSenderName, "Jane Roe"),
+		msgString(pidTagSenderSMTPAddress, "jane@example.org"),
+		msgString(pidTagBody, "Hello John"),
+		msgProp{id: 0x8000, ptype: ptypMultiple | ptypString, value: []byte("ignored")},
+	)
+
+	data := buildCFB(t, root)
+	raw, err := ConvertMsg(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "hidden@example.org") {
+		t.Error("Bcc recipient in the converted message")
+	}
+
+	e, err := ParseMsg(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Subject != "Grüße" {
+		t.Errorf("Wrong subject. Expected: %s, Got: %s", "Grüße", e.Subject)
+	}
+	if len(e.From) != 1 || e.From[0].Address != "jane@example.org" || e.From[0].Name != "Jane Roe" {
+		t.Errorf("Wrong sender. Got: %v", e.From)
+	}
+	if len(e.To) != 1 || e.To[0].Address != "jdoe@example.org" || e.To[0].Name != "Doe, John" {
+		t.Errorf("Wrong recipients. Got: %v", e.To)
+	}
+	if len(e.Cc) != 1 || e.Cc[0].Address != "mary@example.org" {
+		t.Errorf("Wrong Cc. Got: %v", e.Cc)
+	}
+	if e.TextBody != "Hello John" {
+		t.Errorf("Wrong text body. Expected: %s, Got: %s", "Hello John", e.TextBody)
+	}
+
+	expected := map[string][]byte{"report.pdf": []byte("%PDF-1.4"), "large.pdf": large}
+	if len(e.Attachments) != len(expected) {
+		t.Fatalf("Wrong number of attachments. Expected: %d, Got: %d", len(expected), len(e.Attachments))
+	}
+	for _, at := range e.Attachments {
+		data := new(bytes.Buffer)
+		data.ReadFrom(at.Data)
+		if !bytes.Equal(data.Bytes(), expected[at.Filename]) || at.ContentType != "application/pdf" {
+			t.Errorf("Wrong attachment %s (%s), %d bytes", at.Filename, at.ContentType, data.Len())
+		}
+	}
+
+	if _, err := ConvertMsg([]byte("From: a@example.org\r\n\r\nHi")); err != errNotCompoundFile {
+		t.Errorf("Wrong error. Expected: %v, Got: %v", errNotCompoundFile, err)
+	}
+	if _, err := ConvertMsg(data[:1024]); err == nil {
+		t.Error("Expected an error for a truncated file")
+	}
+}
@@ -0,0 +1,362 @@
+// Package pst reads the messages of Outlook PST and OST files and yields
+// them as parsemail.Email structs.
+//
+// Unicode PST files and OST files in the same format are supported,
+// unencrypted or with compressible encryption. ANSI files (Outlook 97-2002),
+// OST files with 4K pages (Outlook 2013 and later) and high encryption are
+// not. ParseMsg reads single Outlook .msg files.
+package pst
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/patrick-othmer/parsemail"
+)
+
+// ErrUnsupported is returned for PST variants this package can't read
+var ErrUnsupported = errors.New("pst: unsupported file format")
+
+const (
+	pageSize = 512
+
+	cryptNone    = 0x00
+	cryptPermute = 0x01
+
+	ptypeBBT = 0x80
+	ptypeNBT = 0x81
+
+	// btEntrySize is the size of the smallest entry of a B-tree page
+	btEntrySize = 24
+
+	// maxTreeDepth is the deepest B-tree, XBLOCK or subnode tree read, far
+	// more levels than a file of the largest size has
+	maxTreeDepth = 16
+
+	nidTypeNormalMessage = 0x04
+	nidTypeAttachment    = 0x05
+)
+
+var le = binary.LittleEndian
+
+// Reader yields the messages of a PST or OST file
+type Reader struct {
+	r       io.ReaderAt
+	crypt   byte
+	bbtRoot uint64
+
+	messages []nbtEntry
+	next     int
+}
+
+// nbtEntry is a node of the node B-tree
+type nbtEntry struct {
+	nid     uint32
+	bidData uint64
+	bidSub  uint64
+}
+
+// bbtEntry is a block of the block B-tree
+type bbtEntry struct {
+	bid uint64
+	ib  uint64
+	cb  uint16
+}
+
+// NewReader reads the header and the node index of the file in r
+func NewReader(r io.ReaderAt) (*Reader, error) {
+	header := make([]byte, 514)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+
+	if string(header[0:4]) != "!BDN" {
+		return nil, fmt.Errorf("pst: not a pst file")
+	}
+
+	switch ver := le.Uint16(header[10:]); {
+	case ver == 14 || ver == 15:
+		return nil, fmt.Errorf("%v: ansi file", ErrUnsupported)
+	case ver == 36:
+		return nil, fmt.Errorf("%v: 4k page file", ErrUnsupported)
+	case ver < 23:
+		return nil, fmt.Errorf("%v: version %d", ErrUnsupported, ver)
+	}
+
+	pr := &Reader{
+		r:       r,
+		crypt:   header[513],
+		bbtRoot: le.Uint64(header[240:]),
+	}
+
+	if pr.crypt != cryptNone && pr.crypt != cryptPermute {
+		return nil, fmt.Errorf("%v: encryption method %d", ErrUnsupported, pr.crypt)
+	}
+
+	err := pr.walkBTree(le.Uint64(header[224:]), ptypeNBT, func(entry []byte) error {
+		e := nbtEntry{
+			nid:     le.Uint32(entry[0:]),
+			bidData: le.Uint64(entry[8:]),
+			bidSub:  le.Uint64(entry[16:]),
+		}
+
+		if e.nid&0x1f == nidTypeNormalMessage {
+			pr.messages = append(pr.messages, e)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+// Len returns the number of messages in the file
+func (pr *Reader) Len() int {
+	return len(pr.messages)
+}
+
+// Next returns the next message of the file. It returns io.EOF after the
+// last message. A message that fails to load or parse returns an error, the
+// following messages can still be read.
+func (pr *Reader) Next() (parsemail.Email, error) {
+	if pr.next >= len(pr.messages) {
+		return parsemail.Email{}, io.EOF
+	}
+
+	node := pr.messages[pr.next]
+	pr.next++
+
+	msg, err := pr.readMessage(node)
+	if err != nil {
+		return parsemail.Email{}, fmt.Errorf("pst: message %#x: %v", node.nid, err)
+	}
+
+	return msg.email()
+}
+
+// walkBTree calls fn with every leaf entry of the B-tree page at ib
+func (pr *Reader) walkBTree(ib uint64, ptype byte, fn func(entry []byte) error) error {
+	return pr.walkBTreePage(ib, ptype, 0, map[uint64]bool{}, fn)
+}
+
+func (pr *Reader) walkBTreePage(ib uint64, ptype byte, depth int, visited map[uint64]bool, fn func(entry []byte) error) error {
+	if depth > maxTreeDepth || visited[ib] {
+		return fmt.Errorf("pst: corrupt b-tree at %d", ib)
+	}
+	visited[ib] = true
+
+	page := make([]byte, pageSize)
+	if _, err := pr.r.ReadAt(page, int64(ib)); err != nil {
+		return err
+	}
+
+	if page[496] != ptype {
+		return fmt.Errorf("pst: unexpected page type %#x at %d", page[496], ib)
+	}
+
+	cEnt, cbEnt, cLevel := int(page[488]), int(page[490]), page[491]
+	if cbEnt < btEntrySize || cEnt*cbEnt > 488 {
+		return fmt.Errorf("pst: corrupt page at %d", ib)
+	}
+
+	for i := 0; i < cEnt; i++ {
+		entry := page[i*cbEnt : (i+1)*cbEnt]
+		var err error
+		if cLevel > 0 {
+			err = pr.walkBTreePage(le.Uint64(entry[16:]), ptype, depth+1, visited, fn)
+		} else {
+			err = fn(entry)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lookupBlock finds a block in the block B-tree
+func (pr *Reader) lookupBlock(bid uint64) (bbtEntry, error) {
+	bid &^= 1
+	ib := pr.bbtRoot
+	page := make([]byte, pageSize)
+
+	for depth := 0; ; depth++ {
+		if depth > maxTreeDepth {
+			return bbtEntry{}, fmt.Errorf("pst: corrupt b-tree at %d", ib)
+		}
+
+		if _, err := pr.r.ReadAt(page, int64(ib)); err != nil {
+			return bbtEntry{}, err
+		}
+
+		if page[496] != ptypeBBT {
+			return bbtEntry{}, fmt.Errorf("pst: unexpected page type %#x at %d", page[496], ib)
+		}
+
+		cEnt, cbEnt, cLevel := int(page[488]), int(page[490]), page[491]
+		if cbEnt < btEntrySize || cEnt*cbEnt > 488 || cEnt == 0 {
+			return bbtEntry{}, fmt.Errorf("pst: corrupt page at %d", ib)
+		}
+
+		if cLevel == 0 {
+			for i := 0; i < cEnt; i++ {
+				entry := page[i*cbEnt:]
+				if le.Uint64(entry)&^1 == bid {
+					return bbtEntry{bid: bid, ib: le.Uint64(entry[8:]), cb: le.Uint16(entry[16:])}, nil
+				}
+			}
+
+			return bbtEntry{}, fmt.Errorf("pst: block %#x not found", bid)
+		}
+
+		// descend into the last child whose key is not greater than bid
+		next := le.Uint64(page[16:])
+		for i := 1; i < cEnt; i++ {
+			entry := page[i*cbEnt:]
+			if le.Uint64(entry) > bid {
+				break
+			}
+			next = le.Uint64(entry[16:])
+		}
+		ib = next
+	}
+}
+
+// readBlock returns the data of a single block, decrypted if necessary
+func (pr *Reader) readBlock(bid uint64) ([]byte, error) {
+	b, err := pr.lookupBlock(bid)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, b.cb)
+	if _, err := pr.r.ReadAt(data, int64(b.ib)); err != nil {
+		return nil, err
+	}
+
+	// internal blocks are never encrypted
+	if bid&2 == 0 && pr.crypt == cryptPermute {
+		decryptPermute(data)
+	}
+
+	return data, nil
+}
+
+// readDataBlocks returns the data blocks of a node, resolving the XBLOCK and
+// XXBLOCK trees of data spanning multiple blocks
+func (pr *Reader) readDataBlocks(bid uint64) ([][]byte, error) {
+	return pr.readTreeBlocks(bid, 0, map[uint64]bool{})
+}
+
+func (pr *Reader) readTreeBlocks(bid uint64, depth int, visited map[uint64]bool) ([][]byte, error) {
+	if depth > maxTreeDepth || visited[bid&^1] {
+		return nil, fmt.Errorf("pst: corrupt xblock tree at %#x", bid)
+	}
+	visited[bid&^1] = true
+
+	data, err := pr.readBlock(bid)
+	if err != nil {
+		return nil, err
+	}
+
+	if bid&2 == 0 {
+		return [][]byte{data}, nil
+	}
+
+	if len(data) < 8 || data[0] != 0x01 {
+		return nil, fmt.Errorf("pst: corrupt xblock %#x", bid)
+	}
+
+	cEnt := int(le.Uint16(data[2:]))
+	if 8+cEnt*8 > len(data) {
+		return nil, fmt.Errorf("pst: corrupt xblock %#x", bid)
+	}
+
+	var blocks [][]byte
+	for i := 0; i < cEnt; i++ {
+		child, err := pr.readTreeBlocks(le.Uint64(data[8+i*8:]), depth+1, visited)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, child...)
+	}
+
+	return blocks, nil
+}
+
+// readData returns the data of a node as a single slice
+func (pr *Reader) readData(bid uint64) ([]byte, error) {
+	blocks, err := pr.readDataBlocks(bid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(blocks) == 1 {
+		return blocks[0], nil
+	}
+
+	var data []byte
+	for _, b := range blocks {
+		data = append(data, b...)
+	}
+
+	return data, nil
+}
+
+// readSubnodes returns the entries of the subnode B-tree of a node
+func (pr *Reader) readSubnodes(bid uint64) (map[uint32]nbtEntry, error) {
+	subnodes := map[uint32]nbtEntry{}
+	if bid == 0 {
+		return subnodes, nil
+	}
+
+	return subnodes, pr.walkSubnodes(bid, 0, map[uint64]bool{}, subnodes)
+}
+
+func (pr *Reader) walkSubnodes(bid uint64, depth int, visited map[uint64]bool, subnodes map[uint32]nbtEntry) error {
+	if depth > maxTreeDepth || visited[bid&^1] {
+		return fmt.Errorf("pst: corrupt subnode tree at %#x", bid)
+	}
+	visited[bid&^1] = true
+
+	data, err := pr.readBlock(bid)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 8 || data[0] != 0x02 {
+		return fmt.Errorf("pst: corrupt subnode block %#x", bid)
+	}
+
+	cLevel, cEnt := data[1], int(le.Uint16(data[2:]))
+	size := 24
+	if cLevel > 0 {
+		size = 16
+	}
+
+	if 8+cEnt*size > len(data) {
+		return fmt.Errorf("pst: corrupt subnode block %#x", bid)
+	}
+
+	for i := 0; i < cEnt; i++ {
+		entry := data[8+i*size:]
+		if cLevel > 0 {
+			if err := pr.walkSubnodes(le.Uint64(entry[8:]), depth+1, visited, subnodes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		nid := le.Uint32(entry)
+		subnodes[nid] = nbtEntry{nid: nid, bidData: le.Uint64(entry[8:]), bidSub: le.Uint64(entry[16:])}
+	}
+
+	return nil
+}
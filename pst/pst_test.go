@@ -0,0 +1,404 @@
+package pst
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// pstBuilder writes minimal unicode PST files for the tests
+type pstBuilder struct {
+	crypt  byte
+	data   []byte
+	blocks []bbtEntry
+	nodes  []nbtEntry
+	bid    uint64
+}
+
+func newPSTBuilder(crypt byte) *pstBuilder {
+	return &pstBuilder{crypt: crypt, data: make([]byte, 1024), bid: 0x100}
+}
+
+func (b *pstBuilder) addBlock(data []byte, internal bool) uint64 {
+	b.bid += 4
+	bid := b.bid
+	if internal {
+		bid |= 2
+	}
+
+	stored := append([]byte(nil), data...)
+	if !internal && b.crypt == cryptPermute {
+		for i, c := range stored {
+			stored[i] = permuteEncode[c]
+		}
+	}
+
+	b.blocks = append(b.blocks, bbtEntry{bid: bid, ib: uint64(len(b.data)), cb: uint16(len(data))})
+	b.data = append(b.data, stored...)
+
+	return bid
+}
+
+func (b *pstBuilder) addNode(nid uint32, bidData, bidSub uint64) {
+	b.nodes = append(b.nodes, nbtEntry{nid: nid, bidData: bidData, bidSub: bidSub})
+}
+
+// addSubnodes writes an SLBLOCK
+func (b *pstBuilder) addSubnodes(entries ...nbtEntry) uint64 {
+	block := make([]byte, 8+24*len(entries))
+	block[0] = 0x02
+	le.PutUint16(block[2:], uint16(len(entries)))
+	for i, e := range entries {
+		le.PutUint64(block[8+i*24:], uint64(e.nid))
+		le.PutUint64(block[16+i*24:], e.bidData)
+		le.PutUint64(block[24+i*24:], e.bidSub)
+	}
+
+	return b.addBlock(block, true)
+}
+
+// addXBlock splits data into blocks referenced by an XBLOCK
+func (b *pstBuilder) addXBlock(data []byte, size int) uint64 {
+	var bids []uint64
+	for len(data) > 0 {
+		n := size
+		if n > len(data) {
+			n = len(data)
+		}
+		bids = append(bids, b.addBlock(data[:n], false))
+		data = data[n:]
+	}
+
+	block := make([]byte, 8+8*len(bids))
+	block[0] = 0x01
+	block[1] = 1
+	le.PutUint16(block[2:], uint16(len(bids)))
+	for i, bid := range bids {
+		le.PutUint64(block[8+i*8:], bid)
+	}
+
+	return b.addBlock(block, true)
+}
+
+func (b *pstBuilder) page(ptype byte, entries [][]byte, level byte) uint64 {
+	page := make([]byte, pageSize)
+	for i, e := range entries {
+		copy(page[i*len(e):], e)
+	}
+	page[488] = byte(len(entries))
+	page[490] = byte(len(entries[0]))
+	page[491] = level
+	page[496] = ptype
+	page[497] = ptype
+
+	ib := uint64(len(b.data))
+	b.data = append(b.data, page...)
+
+	return ib
+}
+
+func (b *pstBuilder) bytes() []byte {
+	sort.Slice(b.nodes, func(i, j int) bool { return b.nodes[i].nid < b.nodes[j].nid })
+	var nbt [][]byte
+	for _, n := range b.nodes {
+		e := make([]byte, 32)
+		le.PutUint64(e, uint64(n.nid))
+		le.PutUint64(e[8:], n.bidData)
+		le.PutUint64(e[16:], n.bidSub)
+		nbt = append(nbt, e)
+	}
+	nbtRoot := b.page(ptypeNBT, nbt, 0)
+
+	// two leaf pages below an intermediate page
+	half := len(b.blocks) / 2
+	var intermediate [][]byte
+	for _, blocks := range [][]bbtEntry{b.blocks[:half], b.blocks[half:]} {
+		var leaf [][]byte
+		for _, bl := range blocks {
+			e := make([]byte, 24)
+			le.PutUint64(e, bl.bid)
+			le.PutUint64(e[8:], bl.ib)
+			le.PutUint16(e[16:], bl.cb)
+			leaf = append(leaf, e)
+		}
+
+		e := make([]byte, 24)
+		le.PutUint64(e, blocks[0].bid)
+		le.PutUint64(e[16:], b.page(ptypeBBT, leaf, 0))
+		intermediate = append(intermediate, e)
+	}
+	bbtRoot := b.page(ptypeBBT, intermediate, 1)
+
+	copy(b.data, "!BDN")
+	copy(b.data[8:], "SM")
+	le.PutUint16(b.data[10:], 23)
+	le.PutUint64(b.data[224:], nbtRoot)
+	le.PutUint64(b.data[240:], bbtRoot)
+	b.data[512] = 0x80
+	b.data[513] = b.crypt
+
+	return b.data
+}
+
+// prop is a property for buildPC. Values of up to 4 bytes of fixed size
+// types are stored inline, subnode values are stored as the given NID.
+type prop struct {
+	id      uint16
+	ptype   uint16
+	value   []byte
+	subnode uint32
+}
+
+// buildPC returns the heap of a property context in a single block
+func buildPC(props []prop) []byte {
+	sort.Slice(props, func(i, j int) bool { return props[i].id < props[j].id })
+
+	items := [][]byte{nil, nil}
+	records := make([]byte, 0, 8*len(props))
+	for _, p := range props {
+		record := make([]byte, 8)
+		le.PutUint16(record, p.id)
+		le.PutUint16(record[2:], p.ptype)
+
+		switch {
+		case p.subnode != 0:
+			le.PutUint32(record[4:], p.subnode)
+		case p.ptype == ptypInteger32 || p.ptype == ptypInteger16 || p.ptype == ptypBoolean:
+			copy(record[4:], p.value)
+		default:
+			items = append(items, p.value)
+			le.PutUint32(record[4:], uint32(len(items))<<5)
+		}
+
+		records = append(records, record...)
+	}
+
+	items[0] = []byte{bthSignature, 2, 6, 0, 0, 0, 0, 0}
+	le.PutUint32(items[0][4:], 2<<5)
+	items[1] = records
+
+	block := make([]byte, 12)
+	block[2] = heapSignature
+	block[3] = clientPC
+	le.PutUint32(block[4:], 1<<5)
+
+	offsets := []int{}
+	for _, item := range items {
+		offsets = append(offsets, len(block))
+		block = append(block, item...)
+	}
+	offsets = append(offsets, len(block))
+
+	le.PutUint16(block, uint16(len(block)))
+	pageMap := make([]byte, 4+2*len(offsets))
+	le.PutUint16(pageMap, uint16(len(items)))
+	for i, o := range offsets {
+		le.PutUint16(pageMap[4+i*2:], uint16(o))
+	}
+
+	return append(block, pageMap...)
+}
+
+func unicode(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		le.PutUint16(b[i*2:], c)
+	}
+
+	return b
+}
+
+func int32Value(v uint32) []byte {
+	b := make([]byte, 4)
+	le.PutUint32(b, v)
+	return b
+}
+
+func timeValue(t time.Time) []byte {
+	b := make([]byte, 8)
+	le.PutUint64(b, uint64(t.UnixNano()/100+116444736000000000))
+	return b
+}
+
+func buildTestPST(crypt byte) []byte {
+	b := newPSTBuilder(crypt)
+
+	// a folder, which is skipped
+	b.addNode(0x122, b.addBlock(buildPC([]prop{{id: 0x3001, ptype: ptypString, value: unicode("Inbox")}}), false), 0)
+
+	// a received message with transport headers, html body and attachment
+	attachmentData := bytes.Repeat([]byte("0123456789"), 30)
+	dataNode := nbtEntry{nid: 0x3f, bidData: b.addXBlock(attachmentData, 128)}
+	attachment := nbtEntry{
+		nid: 0x25,
+		bidData: b.addBlock(buildPC([]prop{
+			{id: pidTagAttachMethod, ptype: ptypInteger32, value: int32Value(attachByValue)},
+			{id: pidTagAttachLongFilename, ptype: ptypString, value: unicode("zahlen.txt")},
+			{id: pidTagAttachMimeTag, ptype: ptypString, value: unicode("application/octet-stream")},
+			{id: pidTagAttachDataBinary, ptype: ptypBinary, subnode: dataNode.nid},
+		}), false),
+		bidSub: b.addSubnodes(dataNode),
+	}
+	b.addNode(0x204, b.addBlock(buildPC([]prop{
+		{id: pidTagSubject, ptype: ptypString, value: unicode("\x01\x04RE: Grüße")},
+		{id: pidTagTransportMessageHeaders, ptype: ptypString, value: unicode("From: John Doe <jdoe@machine.example>\r\n" +
+			"To: Mary Smith <mary@example.net>\r\nSubject: =?utf-8?q?RE=3A_Gr=C3=BC=C3=9Fe?=\r\n" +
+			"Date: Fri, 21 Nov 1997 09:55:06 -0600\r\nMessage-ID: <1234@local.machine.example>\r\n" +
+			"MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=\"lost\"\r\n\r\n")},
+		{id: pidTagBody, ptype: ptypString, value: unicode("Grüße zurück")},
+		{id: pidTagHTML, ptype: ptypBinary, value: []byte("<p>Gr\xfc\xdfe zur\xfcck</p>")},
+		{id: pidTagInternetCodepage, ptype: ptypInteger32, value: int32Value(1252)},
+	}), false), b.addSubnodes(attachment))
+
+	// a draft without transport headers
+	b.addNode(0x224, b.addBlock(buildPC([]prop{
+		{id: pidTagSubject, ptype: ptypString, value: unicode("Draft")},
+		{id: pidTagSenderName, ptype: ptypString, value: unicode("Mary Smith")},
+		{id: pidTagSenderSMTPAddress, ptype: ptypString, value: unicode("mary@example.net")},
+		{id: pidTagDisplayTo, ptype: ptypString, value: unicode("John Doe")},
+		{id: pidTagClientSubmitTime, ptype: ptypTime, value: timeValue(time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC))},
+		{id: pidTagBody, ptype: ptypString, value: unicode("Not sent yet")},
+	}), false), 0)
+
+	return b.bytes()
+}
+
+func TestReader(t *testing.T) {
+	for _, crypt := range []byte{cryptNone, cryptPermute} {
+		r, err := NewReader(bytes.NewReader(buildTestPST(crypt)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.Len() != 2 {
+			t.Fatalf("[crypt %v] Wrong number of messages. Expected: 2, Got: %v", crypt, r.Len())
+		}
+
+		e, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if e.Subject != "RE: Grüße" || e.MessageID != "1234@local.machine.example" || len(e.To) != 1 || e.To[0].Address != "mary@example.net" {
+			t.Errorf("[crypt %v] Wrong headers: %q %q %v", crypt, e.Subject, e.MessageID, e.To)
+		}
+
+		if e.TextBody != "Grüße zurück" || e.HTMLBody != "<p>Grüße zurück</p>" {
+			t.Errorf("[crypt %v] Wrong bodies: %q %q", crypt, e.TextBody, e.HTMLBody)
+		}
+
+		if len(e.Attachments) != 1 || e.Attachments[0].Filename != "zahlen.txt" {
+			t.Fatalf("[crypt %v] Wrong attachments: %v", crypt, e.Attachments)
+		}
+
+		data, _ := ioutil.ReadAll(e.Attachments[0].Data)
+		if !bytes.Equal(data, bytes.Repeat([]byte("0123456789"), 30)) {
+			t.Errorf("[crypt %v] Wrong attachment data: %q", crypt, data)
+		}
+
+		e, err = r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if e.Subject != "Draft" || len(e.From) != 1 || e.From[0].Address != "mary@example.net" ||
+			e.Header.Get("X-Display-To") != "John Doe" || !e.Date.Equal(time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)) ||
+			e.TextBody != "Not sent yet" {
+			t.Errorf("[crypt %v] Wrong draft: %+v", crypt, e)
+		}
+
+		if _, err := r.Next(); err != io.EOF {
+			t.Errorf("[crypt %v] Expected io.EOF after the last message, Got: %v", crypt, err)
+		}
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	data := buildTestPST(cryptNone)
+	le.PutUint16(data[10:], 14)
+
+	if _, err := NewReader(bytes.NewReader(data)); err == nil {
+		t.Errorf("Expected an error for an ansi file")
+	}
+
+	if _, err := NewReader(bytes.NewReader([]byte("From: x\r\n\r\n" + string(make([]byte, 600))))); err == nil {
+		t.Errorf("Expected an error for a non pst file")
+	}
+}
+
+func TestCorruptFile(t *testing.T) {
+	// page modifies the root page of the node or block B-tree of a test file
+	page := func(offset int, modify func(data []byte, ib uint64)) []byte {
+		data := buildTestPST(cryptNone)
+		ib := le.Uint64(data[offset:])
+		modify(data[ib:ib+pageSize], ib)
+		return data
+	}
+
+	// message builds a file with a message whose data or subnode block
+	// references itself
+	message := func(sub bool) []byte {
+		b := newPSTBuilder(cryptNone)
+		self := (b.bid + 4) | 2
+
+		if sub {
+			block := make([]byte, 8+16)
+			block[0], block[1] = 0x02, 1
+			le.PutUint16(block[2:], 1)
+			le.PutUint64(block[16:], self)
+			b.addBlock(block, true)
+			b.addNode(0x204, b.addBlock(buildPC([]prop{{id: pidTagSubject, ptype: ptypString, value: unicode("Loop")}}), false), self)
+		} else {
+			block := make([]byte, 8+8)
+			block[0], block[1] = 0x01, 1
+			le.PutUint16(block[2:], 1)
+			le.PutUint64(block[8:], self)
+			b.addNode(0x204, b.addBlock(block, true), 0)
+			b.addBlock([]byte("unused"), false)
+		}
+
+		return b.bytes()
+	}
+
+	var testData = map[int]struct {
+		data []byte
+	}{
+		1: {page(224, func(p []byte, ib uint64) { p[490] = 4 })},
+		2: {page(224, func(p []byte, ib uint64) { p[491] = 1; le.PutUint64(p[16:], ib) })},
+		3: {page(240, func(p []byte, ib uint64) { p[490] = 4 })},
+		4: {page(240, func(p []byte, ib uint64) { le.PutUint64(p[16:], ib) })},
+		5: {message(false)},
+		6: {message(true)},
+	}
+
+	for index, td := range testData {
+		r, err := NewReader(bytes.NewReader(td.data))
+		if err != nil {
+			continue
+		}
+
+		if _, err := r.Next(); err == nil {
+			t.Errorf("[Test Case %v] Expected an error for a corrupt file", index)
+		}
+	}
+}
+
+func TestBTreeOnHeapEmptyRecords(t *testing.T) {
+	block := buildPC([]prop{{id: pidTagSubject, ptype: ptypString, value: unicode("Empty")}})
+	h, err := newHeap([][]byte{block})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, _ := h.item(h.userRoot())
+	header[1], header[2] = 0, 0
+
+	if _, _, err := h.bthRecords(h.userRoot()); err == nil {
+		t.Errorf("Expected an error for records of size 0")
+	}
+}
@@ -0,0 +1,81 @@
+package parsemail
+
+import (
+	"bytes"
+	"strings"
+)
+
+// qpMaxLine is the longest encoded line, excluding the soft line break "="
+const qpMaxLine = 75
+
+const qpHex = "0123456789ABCDEF"
+
+// encodeQuotedPrintable encodes a text body so that decoding it and removing
+// a final LF, as the parser does, yields text unchanged. Texts ending in a
+// line break get an encoded "=0A" after it, so the encoding never ends with a
+// bare line break that transports could alter.
+//
+// Texts containing CRLF keep CRLF hard line breaks and encode bare LF as
+// "=0A". Texts with LF line endings keep LF hard line breaks. A bare CR,
+// whitespace before a line break and every byte that isn't printable ASCII
+// are encoded, and lines longer than 76 characters get soft line breaks.
+func encodeQuotedPrintable(text string) []byte {
+	eol := "\n"
+	if strings.Contains(text, "\r\n") {
+		eol = "\r\n"
+	}
+
+	var buf bytes.Buffer
+	lineLen := 0
+
+	// write adds a token that can't be split, breaking the line first if it
+	// would get too long
+	write := func(token string) {
+		if lineLen+len(token) > qpMaxLine {
+			buf.WriteString("=" + eol)
+			lineLen = 0
+		}
+		buf.WriteString(token)
+		lineLen += len(token)
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case eol == "\r\n" && c == '\r' && i+1 < len(text) && text[i+1] == '\n':
+			buf.WriteString("\r\n")
+			lineLen = 0
+			i++
+		case eol == "\n" && c == '\n':
+			buf.WriteString("\n")
+			lineLen = 0
+		case (c == ' ' || c == '\t') && qpLineEnd(text, i+1, eol):
+			write(qpEscape(c))
+		case c == ' ' || c == '\t' || c >= 33 && c <= 126 && c != '=':
+			write(string(c))
+		default:
+			write(qpEscape(c))
+		}
+	}
+
+	if strings.HasSuffix(text, "\n") {
+		write("=0A")
+	}
+
+	return buf.Bytes()
+}
+
+// qpLineEnd reports whether a hard line break or the end of the text is at
+// text[i]
+func qpLineEnd(text string, i int, eol string) bool {
+	if i == len(text) {
+		return true
+	}
+
+	return strings.HasPrefix(text[i:], eol)
+}
+
+func qpEscape(c byte) string {
+	return string([]byte{'=', qpHex[c>>4], qpHex[c&0x0f]})
+}
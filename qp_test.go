@@ -0,0 +1,99 @@
+package parsemail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"mime/quotedprintable"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// qpText generates texts built from the tokens that are hard to round-trip
+type qpText string
+
+var qpTokens = []string{
+	"a", "Hello", " ", "  ", "\t", "\r", "\n", "\r\n", "=", "=20", "ü", "€", "😀",
+	".", "From ", "--", strings.Repeat("x", 80), strings.Repeat(" ", 80), "\x00", "\x7f", "\ufeff",
+}
+
+func (qpText) Generate(r *rand.Rand, size int) reflect.Value {
+	var sb strings.Builder
+	for n := r.Intn(size + 1); n > 0; n-- {
+		sb.WriteString(qpTokens[r.Intn(len(qpTokens))])
+	}
+
+	return reflect.ValueOf(qpText(sb.String()))
+}
+
+func TestEncodeQuotedPrintable(t *testing.T) {
+	var testData = map[int]struct {
+		text     string
+		expected string
+	}{
+		1: {text: "Hello", expected: "Hello"},
+		2: {text: "trailing \nspace\t", expected: "trailing=20\nspace=09"},
+		3: {text: "Grüße = 1\n", expected: "Gr=C3=BC=C3=9Fe =3D 1\n=0A"},
+		4: {text: "crlf\r\nbare\nlf\r\n", expected: "crlf\r\nbare=0Alf\r\n=0A"},
+		5: {text: strings.Repeat("x", 80), expected: strings.Repeat("x", 75) + "=\nxxxxx"},
+	}
+
+	for index, td := range testData {
+		if got := string(encodeQuotedPrintable(td.text)); got != td.expected {
+			t.Errorf("[Test Case %v] Wrong encoding. Expected: %q, Got: %q", index, td.expected, got)
+		}
+	}
+}
+
+func TestQuotedPrintableRoundTrip(t *testing.T) {
+	roundTrip := func(text qpText) bool {
+		decoded, err := ioutil.ReadAll(quotedprintable.NewReader(bytes.NewReader(encodeQuotedPrintable(string(text)))))
+		if strings.HasSuffix(string(text), "\n") {
+			text += "\n"
+		}
+		return err == nil && string(decoded) == string(text)
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+
+	if err := quick.Check(func(text string) bool { return roundTrip(qpText(text)) }, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuotedPrintableLineLength(t *testing.T) {
+	short := func(text qpText) bool {
+		for _, line := range strings.Split(string(encodeQuotedPrintable(string(text))), "\n") {
+			if len(strings.TrimSuffix(line, "\r")) > 76 {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(short, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSerializeBodiesRoundTrip(t *testing.T) {
+	roundTrip := func(text, html qpText) bool {
+		e := Email{TextBody: string(text), HTMLBody: string(html)}
+
+		raw, err := Serialize(&e)
+		if err != nil {
+			return false
+		}
+
+		parsed, err := Parse(bytes.NewReader(raw))
+		return err == nil && parsed.TextBody == e.TextBody && parsed.HTMLBody == e.HTMLBody
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,131 @@
+package parsemail
+
+import (
+	"bytes"
+	"net/textproto"
+	"strings"
+)
+
+// HeaderField is a single header field exactly as it appears in the original
+// message. Raw contains the name, the folded value and the line break.
+type HeaderField struct {
+	Name string
+	Raw  []byte
+}
+
+// Value returns the unfolded value of the field without surrounding
+// whitespace
+func (f HeaderField) Value() string {
+	i := bytes.IndexByte(f.Raw, ':')
+	if i < 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(UnfoldHeader(string(f.Raw[i+1:])))
+}
+
+// RawHeaderFields returns the header fields of the original message in the
+// order they appear in.
+func (e *Email) RawHeaderFields() []HeaderField {
+	header, _ := splitHeader(e.raw)
+	return splitHeaderFields(header)
+}
+
+func splitHeaderFields(header []byte) (fields []HeaderField) {
+	for i := 0; i < len(header); {
+		end := bytes.IndexByte(header[i:], '\n')
+		next := len(header)
+		if end >= 0 {
+			next = i + end + 1
+		}
+
+		line := header[i:next]
+		switch {
+		case len(bytes.TrimRight(line, "\r\n")) == 0:
+			return
+		case (line[0] == ' ' || line[0] == '\t') && len(fields) > 0:
+			last := &fields[len(fields)-1]
+			last.Raw = header[i-len(last.Raw) : next]
+		default:
+			name := ""
+			if c := bytes.IndexByte(line, ':'); c >= 0 {
+				name = textproto.CanonicalMIMEHeaderKey(string(bytes.TrimSpace(line[:c])))
+			}
+			fields = append(fields, HeaderField{Name: name, Raw: line})
+		}
+
+		i = next
+	}
+
+	return
+}
+
+// isTraceField reports whether a header field belongs to the trace block
+// prepended by relays (RFC 5321 section 4.4)
+func isTraceField(name string) bool {
+	return name == "Return-Path" || name == "Received"
+}
+
+// TraceFields returns the Return-Path and Received fields of the message
+// exactly as received, in their original order (most recent hop first).
+func (e *Email) TraceFields() (trace []HeaderField) {
+	for _, f := range e.RawHeaderFields() {
+		if isTraceField(f.Name) {
+			trace = append(trace, f)
+		}
+	}
+
+	return
+}
+
+// TraceGroup is a Return-Path field together with the Received fields that
+// follow it. Received fields before the first Return-Path form a group
+// without Return-Path.
+type TraceGroup struct {
+	ReturnPath *HeaderField
+	Received   []HeaderField
+}
+
+// TraceGroups returns the trace fields of the message grouped by Return-Path
+func (e *Email) TraceGroups() (groups []TraceGroup) {
+	for _, f := range e.TraceFields() {
+		f := f
+		if f.Name == "Return-Path" || len(groups) == 0 {
+			groups = append(groups, TraceGroup{})
+		}
+
+		g := &groups[len(groups)-1]
+		if f.Name == "Return-Path" {
+			g.ReturnPath = &f
+		} else {
+			g.Received = append(g.Received, f)
+		}
+	}
+
+	return
+}
+
+// StripTrace returns the original message without its Return-Path and
+// Received fields, ready to be resubmitted. All other bytes are unchanged.
+// It returns nil unless the email was parsed with Options.KeepRaw.
+func (e *Email) StripTrace() []byte {
+	raw, err := e.rawMessage()
+	if err != nil {
+		return nil
+	}
+	header, body := splitHeader(raw)
+
+	var buf bytes.Buffer
+	buf.Grow(len(raw))
+	rest := header
+	for _, f := range splitHeaderFields(header) {
+		if !isTraceField(f.Name) {
+			buf.Write(f.Raw)
+		}
+		rest = rest[len(f.Raw):]
+	}
+	buf.Write(rest)
+	buf.Write(body)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,97 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRawHeaderFields(t *testing.T) {
+	e, err := ParseWithOptions(strings.NewReader(rfc5322exampleA4), Options{KeepRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := e.RawHeaderFields()
+	names := []string{"Received", "Received", "From", "To", "Subject", "Date", "Message-Id"}
+	var got []string
+	for _, f := range fields {
+		got = append(got, f.Name)
+	}
+
+	if !assertSliceEq(names, got) {
+		t.Fatalf("Wrong fields. Expected: %s, Got: %s", names, got)
+	}
+
+	if !strings.HasPrefix(string(fields[0].Raw), "Received: from x.y.test\n  by example.net\n") || !strings.HasSuffix(string(fields[0].Raw), "-0600\n") {
+		t.Errorf("Wrong raw field: %q", fields[0].Raw)
+	}
+
+	expected := "from x.y.test  by example.net  via TCP  with ESMTP  id ABC12345  for <mary@example.net>;  21 Nov 1997 10:05:43 -0600"
+	if fields[0].Value() != expected {
+		t.Errorf("Wrong unfolded value. Expected: %q, Got: %q", expected, fields[0].Value())
+	}
+}
+
+func TestTraceFields(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		trace    []string
+		groups   []int
+		stripped string
+	}{
+		1: {
+			mailData: rfc5322exampleA11,
+			stripped: rfc5322exampleA11,
+		},
+		2: {
+			mailData: traceExample,
+			trace:    []string{"Return-Path", "Received", "Received", "Return-Path", "Received"},
+			groups:   []int{2, 1},
+			stripped: "X-Spam: no\r\nFrom: John Doe <jdoe@node.example>\r\nSubject: Hello\r\n\r\nHello.\r\n",
+		},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(td.mailData), Options{KeepRaw: true})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		var trace []string
+		for _, f := range e.TraceFields() {
+			trace = append(trace, f.Name)
+		}
+
+		if !assertSliceEq(td.trace, trace) {
+			t.Errorf("[Test Case %v] Wrong trace. Expected: %s, Got: %s", index, td.trace, trace)
+		}
+
+		groups := e.TraceGroups()
+		if len(groups) != len(td.groups) {
+			t.Errorf("[Test Case %v] Wrong number of groups. Expected: %v, Got: %v", index, len(td.groups), len(groups))
+		} else {
+			for i, g := range groups {
+				if g.ReturnPath == nil || len(g.Received) != td.groups[i] {
+					t.Errorf("[Test Case %v] Wrong group %v: %v", index, i, g)
+				}
+			}
+		}
+
+		if stripped := string(e.StripTrace()); stripped != td.stripped {
+			t.Errorf("[Test Case %v] Wrong stripped message. Expected: %q, Got: %q", index, td.stripped, stripped)
+		}
+	}
+}
+
+var traceExample = "Return-Path: <jdoe@node.example>\r\n" +
+	"Received: from x.y.test\r\n" +
+	"\tby example.net; 21 Nov 1997 10:05:43 -0600\r\n" +
+	"Received: from node.example by x.y.test; 21 Nov 1997 10:01:22 -0600\r\n" +
+	"X-Spam: no\r\n" +
+	"Return-Path: <bounce@node.example>\r\n" +
+	"Received: from node.example by node.example; 21 Nov 1997 10:00:00 -0600\r\n" +
+	"From: John Doe <jdoe@node.example>\r\n" +
+	"Subject: Hello\r\n" +
+	"\r\n" +
+	"Hello.\r\n"
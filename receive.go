@@ -0,0 +1,451 @@
+package parsemail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReceiveEnvelope is the SMTP envelope of a message received by a Receiver
+type ReceiveEnvelope struct {
+	RemoteAddr net.Addr
+	Helo       string
+	From       string
+	Recipients []string
+}
+
+// ReceiveError rejects a message with an SMTP reply when returned by a
+// ReceiveHandler, e.g. &ReceiveError{550, "5.1.1 Unknown user"}. Other
+// errors reply with a temporary failure.
+type ReceiveError struct {
+	Code    int
+	Message string
+}
+
+func (e *ReceiveError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// ReceiveHandler delivers a message received by a Receiver. The message is
+// accepted if it returns nil.
+type ReceiveHandler func(env *ReceiveEnvelope, e *Email) error
+
+// Receiver is a minimal SMTP or LMTP server handing the messages it receives
+// to Handler as parsed emails. Messages are parsed with Options while the
+// DATA command transfers them, so only their header is held in memory
+// unparsed unless Options.KeepRaw or another option needing the whole
+// message is set. Command lines are limited to 512 bytes (RFC 5321), and
+// connections on which the client stays silent are closed. It doesn't
+// authenticate clients or relay messages, and is meant to receive from a
+// trusted MTA or behind one.
+type Receiver struct {
+	Handler ReceiveHandler
+	Options Options
+
+	// LMTP speaks LMTP (RFC 2033) instead of SMTP. Handler is then called
+	// once per recipient with an envelope holding only that recipient, and
+	// its result is the reply for that recipient.
+	LMTP bool
+	// Hostname is announced in the greeting, "localhost" if empty
+	Hostname string
+	// MaxMessageBytes rejects messages larger than this number of bytes
+	MaxMessageBytes int64
+	// MaxRecipients rejects further recipients of a message, 100 if zero
+	MaxRecipients int
+	// IdleTimeout closes a connection when the client sends nothing for
+	// this long, while a command is awaited or during DATA, 5 minutes if
+	// zero (RFC 5321 section 4.5.3.2)
+	IdleTimeout time.Duration
+}
+
+// Serve accepts connections from l and serves each in its own goroutine
+// until l fails
+func (rc *Receiver) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			rc.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn serves a single connection. It returns nil when the client
+// quits or closes the connection. A client that stays silent for longer
+// than IdleTimeout is answered with 421 and the timeout error is returned.
+func (rc *Receiver) ServeConn(conn net.Conn) error {
+	s := &receiveSession{
+		rc:      rc,
+		netConn: conn,
+		conn:    textproto.NewConn(conn),
+		parser:  NewParser(rc.Options),
+		env:     ReceiveEnvelope{RemoteAddr: conn.RemoteAddr()},
+	}
+
+	if err := s.idle(); err != nil {
+		return err
+	}
+	if err := s.reply(220, s.hostname()+" "+s.protocol()+" ready"); err != nil {
+		return err
+	}
+
+	for {
+		if err := s.idle(); err != nil {
+			return err
+		}
+
+		line, err := s.readCommand()
+		if err == io.EOF {
+			return nil
+		}
+		if err == errLineTooLong {
+			if err := s.reply(500, "5.5.2 Line too long"); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return s.closeIdle(err)
+		}
+
+		quit, err := s.command(line)
+		if err != nil || quit {
+			return s.closeIdle(err)
+		}
+	}
+}
+
+type receiveSession struct {
+	rc      *Receiver
+	netConn net.Conn
+	conn    *textproto.Conn
+	parser  *Parser
+
+	env  ReceiveEnvelope
+	mail bool
+}
+
+func (s *receiveSession) hostname() string {
+	if s.rc.Hostname == "" {
+		return "localhost"
+	}
+
+	return s.rc.Hostname
+}
+
+func (s *receiveSession) protocol() string {
+	if s.rc.LMTP {
+		return "LMTP"
+	}
+
+	return "ESMTP"
+}
+
+// idle extends the deadline of the connection by the idle timeout
+func (s *receiveSession) idle() error {
+	timeout := s.rc.IdleTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+
+	return s.netConn.SetDeadline(time.Now().Add(timeout))
+}
+
+// closeIdle tells a client that timed out that the connection is closed,
+// and returns err
+func (s *receiveSession) closeIdle(err error) error {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && s.idle() == nil {
+		s.reply(421, "4.4.2 "+s.hostname()+" Idle timeout, closing connection")
+	}
+
+	return err
+}
+
+func (s *receiveSession) reply(code int, lines ...string) error {
+	for i, l := range lines {
+		sep := " "
+		if i < len(lines)-1 {
+			sep = "-"
+		}
+		if err := s.conn.PrintfLine("%d%s%s", code, sep, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// maxCommandLine is the length of a command line including its CRLF
+// (RFC 5321 section 4.5.3.1.4)
+const maxCommandLine = 512
+
+// errLineTooLong is returned for a command line exceeding maxCommandLine
+var errLineTooLong = errors.New("line too long")
+
+// readCommand reads a command line. The rest of a line that is too long is
+// discarded without being kept.
+func (s *receiveSession) readCommand() (string, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, more, err := s.conn.R.ReadLine()
+		if err != nil {
+			return "", err
+		}
+
+		if len(line)+len(chunk)+2 > maxCommandLine {
+			tooLong = true
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+		if !more {
+			break
+		}
+	}
+
+	if tooLong {
+		return "", errLineTooLong
+	}
+
+	return string(line), nil
+}
+
+// reset forgets the message being received
+func (s *receiveSession) reset() {
+	s.mail = false
+	s.env.From, s.env.Recipients = "", nil
+}
+
+// command answers a command line. quit is set when the client quits.
+func (s *receiveSession) command(line string) (quit bool, err error) {
+	verb, arg := line, ""
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch strings.ToUpper(verb) {
+	case "HELO", "EHLO", "LHLO":
+		if s.rc.LMTP != (strings.ToUpper(verb) == "LHLO") {
+			return false, s.reply(500, "5.5.1 Wrong greeting for "+s.protocol())
+		}
+		if arg == "" {
+			return false, s.reply(501, "5.5.4 Hostname required")
+		}
+		s.env.Helo = arg
+		s.reset()
+		if strings.ToUpper(verb) == "HELO" {
+			return false, s.reply(250, s.hostname())
+		}
+		ext := []string{s.hostname(), "PIPELINING", "8BITMIME", "ENHANCEDSTATUSCODES"}
+		if s.rc.MaxMessageBytes > 0 {
+			ext = append(ext, "SIZE "+strconv.FormatInt(s.rc.MaxMessageBytes, 10))
+		}
+		return false, s.reply(250, ext...)
+
+	case "MAIL":
+		if s.env.Helo == "" {
+			return false, s.reply(503, "5.5.1 Say hello first")
+		}
+		if s.mail {
+			return false, s.reply(503, "5.5.1 Sender already given")
+		}
+		from, params, ok := receivePath(arg, "FROM:")
+		if !ok {
+			return false, s.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+		}
+		if size, err := strconv.ParseInt(params["SIZE"], 10, 64); err == nil && s.rc.MaxMessageBytes > 0 && size > s.rc.MaxMessageBytes {
+			return false, s.reply(552, "5.3.4 Message too big")
+		}
+		s.mail, s.env.From = true, from
+		return false, s.reply(250, "2.1.0 OK")
+
+	case "RCPT":
+		if !s.mail {
+			return false, s.reply(503, "5.5.1 Need MAIL first")
+		}
+		to, _, ok := receivePath(arg, "TO:")
+		if !ok || to == "" {
+			return false, s.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+		}
+		max := s.rc.MaxRecipients
+		if max == 0 {
+			max = 100
+		}
+		if len(s.env.Recipients) >= max {
+			return false, s.reply(452, "4.5.3 Too many recipients")
+		}
+		s.env.Recipients = append(s.env.Recipients, to)
+		return false, s.reply(250, "2.1.5 OK")
+
+	case "DATA":
+		if len(s.env.Recipients) == 0 {
+			return false, s.reply(503, "5.5.1 Need RCPT first")
+		}
+		if err := s.reply(354, "End data with <CR><LF>.<CR><LF>"); err != nil {
+			return false, err
+		}
+		err := s.data()
+		s.reset()
+		return false, err
+
+	case "RSET":
+		s.reset()
+		return false, s.reply(250, "2.0.0 OK")
+
+	case "NOOP":
+		return false, s.reply(250, "2.0.0 OK")
+
+	case "VRFY":
+		return false, s.reply(252, "2.5.0 Cannot verify")
+
+	case "QUIT":
+		return true, s.reply(221, "2.0.0 Bye")
+	}
+
+	return false, s.reply(502, "5.5.2 Command not implemented")
+}
+
+// errMessageTooLarge stops reading a message exceeding MaxMessageBytes
+var errMessageTooLarge = errors.New("message too large")
+
+// data receives, parses and delivers a message, and replies with the result
+func (s *receiveSession) data() error {
+	body := &countingReader{r: &idleReader{r: s.conn.DotReader(), s: s}}
+	var r io.Reader = body
+	if s.rc.MaxMessageBytes > 0 {
+		r = &receiveLimitReader{r: body, n: s.rc.MaxMessageBytes}
+	}
+
+	// handlers get a complete email, which ParseStream doesn't build. Parse
+	// reads the body as it parses it too, unless the options need the whole
+	// message.
+	e, err := s.parser.Parse(r)
+
+	// the rest of a message the parse stopped reading is discarded
+	if _, derr := io.Copy(ioutil.Discard, body); derr != nil {
+		return derr
+	}
+
+	var results []error
+	switch {
+	case s.rc.MaxMessageBytes > 0 && body.n > s.rc.MaxMessageBytes:
+		results = []error{&ReceiveError{552, "5.3.4 Message too big"}}
+	case err != nil:
+		results = []error{&ReceiveError{554, "5.6.0 Malformed message"}}
+	case s.rc.LMTP:
+		for _, to := range s.env.Recipients {
+			env := s.env
+			env.Recipients = []string{to}
+			results = append(results, s.rc.Handler(&env, &e))
+		}
+	default:
+		env := s.env
+		results = []error{s.rc.Handler(&env, &e)}
+	}
+
+	// LMTP replies for every recipient
+	if s.rc.LMTP && len(results) == 1 {
+		for range s.env.Recipients[1:] {
+			results = append(results, results[0])
+		}
+	}
+
+	// the handlers may have taken longer than the idle timeout
+	if err := s.idle(); err != nil {
+		return err
+	}
+	for _, err := range results {
+		if err := s.result(err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// result replies with the result of delivering a message
+func (s *receiveSession) result(err error) error {
+	switch err := err.(type) {
+	case nil:
+		return s.reply(250, "2.0.0 OK")
+	case *ReceiveError:
+		return s.reply(err.Code, err.Message)
+	}
+
+	return s.reply(451, "4.3.0 Delivery failed")
+}
+
+// receiveLimitReader fails once more than n bytes are read
+type receiveLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *receiveLimitReader) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	if l.n -= int64(n); l.n < 0 {
+		return n, errMessageTooLarge
+	}
+
+	return n, err
+}
+
+// idleReader extends the deadline of the connection before every read of
+// a message. Its first error is returned by all further reads, so a timed
+// out message isn't waited for again while the rest is discarded.
+type idleReader struct {
+	r   io.Reader
+	s   *receiveSession
+	err error
+}
+
+func (r *idleReader) Read(b []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.err = r.s.idle(); r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.r.Read(b)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+
+	return n, err
+}
+
+// receivePath parses the argument of MAIL or RCPT, "FROM:<address> PARAM=x"
+func receivePath(arg, prefix string) (address string, params map[string]string, ok bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+
+	fields := strings.Fields(arg[len(prefix):])
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "<") || !strings.HasSuffix(fields[0], ">") {
+		return "", nil, false
+	}
+
+	params = map[string]string{}
+	for _, p := range fields[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = kv[1]
+		} else {
+			params[strings.ToUpper(kv[0])] = ""
+		}
+	}
+
+	return strings.Trim(fields[0], "<>"), params, true
+}
@@ -0,0 +1,179 @@
+package parsemail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReceiverSMTP(t *testing.T) {
+	var envs []ReceiveEnvelope
+	var emails []Email
+	rc := &Receiver{Hostname: "mx.example.net", MaxMessageBytes: 1000, Handler: func(env *ReceiveEnvelope, e *Email) error {
+		envs, emails = append(envs, *env), append(emails, *e)
+		if e.Subject == "Spam" {
+			return &ReceiveError{550, "5.7.1 Refused"}
+		}
+		return nil
+	}}
+
+	client, server := net.Pipe()
+	done := make(chan error)
+	go func() { done <- rc.ServeConn(server) }()
+
+	c, err := smtp.NewClient(client, "mx.example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Hello("client.example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, size := c.Extension("SIZE"); !ok || size != "1000" {
+		t.Errorf("Wrong SIZE extension. Expected: %s, Got: %v %s", "1000", ok, size)
+	}
+
+	var testData = map[int]struct {
+		message string
+		err     string
+	}{
+		1: {message: "From: a@example.org\r\nSubject: Report\r\n\r\nHello\r\n.hidden\r\n"},
+		2: {message: "From: a@example.org\r\nSubject: Spam\r\n\r\nBuy\r\n", err: "550 5.7.1 Refused"},
+		3: {message: "From: a@example.org\r\nSubject: Big\r\n\r\n" + strings.Repeat("x", 1000) + "\r\n", err: "552 5.3.4 Message too big"},
+	}
+
+	for index := 1; index <= len(testData); index++ {
+		td := testData[index]
+		if err := c.Mail("a@example.org"); err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if err := c.Rcpt("b@example.net"); err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		w, err := c.Data()
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		w.Write([]byte(td.message))
+		err = w.Close()
+		if got := ""; err != nil || td.err != "" {
+			if err, ok := err.(*textproto.Error); ok {
+				got = fmt.Sprintf("%d %s", err.Code, err.Msg)
+			}
+			if got != td.err {
+				t.Errorf("[Test Case %v] Wrong reply. Expected: %s, Got: %s", index, td.err, got)
+			}
+		}
+	}
+
+	if err := c.Quit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(emails) != 2 {
+		t.Fatalf("Wrong number of messages. Expected: %v, Got: %v", 2, len(emails))
+	}
+	if emails[0].Subject != "Report" || strings.TrimSpace(emails[0].TextBody) != "Hello\n.hidden" {
+		t.Errorf("Wrong message. Got: %s, %q", emails[0].Subject, emails[0].TextBody)
+	}
+	if env := envs[0]; env.Helo != "client.example.org" || env.From != "a@example.org" || len(env.Recipients) != 1 || env.Recipients[0] != "b@example.net" {
+		t.Errorf("Wrong envelope. Got: %+v", env)
+	}
+}
+
+func TestReceiverLMTP(t *testing.T) {
+	rc := &Receiver{LMTP: true, Handler: func(env *ReceiveEnvelope, e *Email) error {
+		if env.Recipients[0] == "full@example.net" {
+			return &ReceiveError{452, "4.2.2 Mailbox full"}
+		}
+		return nil
+	}}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go rc.ServeConn(server)
+	c := textproto.NewConn(client)
+
+	expect := func(code int, cmd string) string {
+		if cmd != "" {
+			c.PrintfLine("%s", cmd)
+		}
+		_, msg, err := c.ReadResponse(code)
+		if err != nil {
+			t.Fatalf("Wrong reply to %s. Expected: %v, Got: %v", cmd, code, err)
+		}
+		return msg
+	}
+
+	expect(220, "")
+	expect(500, "EHLO client.example.org")
+	if msg := expect(500, "LHLO "+strings.Repeat("x", 600)); msg != "5.5.2 Line too long" {
+		t.Errorf("Wrong reply. Expected: %s, Got: %s", "5.5.2 Line too long", msg)
+	}
+	expect(250, "LHLO client.example.org")
+	expect(250, "MAIL FROM:<a@example.org>")
+	expect(250, "RCPT TO:<ok@example.net>")
+	expect(250, "RCPT TO:<full@example.net>")
+	expect(354, "DATA")
+	c.PrintfLine("Subject: Hi\r\n\r\nHi\r\n.")
+	expect(250, "")
+	if msg := expect(452, ""); msg != "4.2.2 Mailbox full" {
+		t.Errorf("Wrong reply. Expected: %s, Got: %s", "4.2.2 Mailbox full", msg)
+	}
+	expect(221, "QUIT")
+}
+
+func TestReceiverIdleTimeout(t *testing.T) {
+	// commands sent after the greeting, with the reply expected or none
+	var testData = map[int][]struct {
+		cmd  string
+		code int
+	}{
+		1: nil,
+		2: {{"EHLO client.example.org", 250}, {"MAIL FROM:<a@example.org>", 250}, {"RCPT TO:<b@example.net>", 250}, {"DATA", 354}, {"Subject: Hi", 0}},
+	}
+
+	for index, commands := range testData {
+		rc := &Receiver{IdleTimeout: 50 * time.Millisecond, Handler: func(env *ReceiveEnvelope, e *Email) error {
+			return nil
+		}}
+
+		client, server := net.Pipe()
+		done := make(chan error, 1)
+		go func() { done <- rc.ServeConn(server) }()
+		c := textproto.NewConn(client)
+
+		if _, _, err := c.ReadResponse(220); err != nil {
+			t.Fatalf("[Test Case %v] Wrong greeting. Got: %v", index, err)
+		}
+		for _, cmd := range commands {
+			c.PrintfLine("%s", cmd.cmd)
+			if cmd.code == 0 {
+				continue
+			}
+			if _, _, err := c.ReadResponse(cmd.code); err != nil {
+				t.Fatalf("[Test Case %v] Wrong reply to %s. Expected: %v, Got: %v", index, cmd.cmd, cmd.code, err)
+			}
+		}
+
+		// the client stays silent
+		if _, msg, err := c.ReadResponse(421); err != nil || !strings.Contains(msg, "Idle timeout") {
+			t.Errorf("[Test Case %v] Wrong reply. Expected: %v, Got: %v %s", index, 421, err, msg)
+		}
+		select {
+		case err := <-done:
+			if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+				t.Errorf("[Test Case %v] Wrong error. Expected a timeout, Got: %v", index, err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("[Test Case %v] Idle session not closed", index)
+		}
+		client.Close()
+	}
+}
@@ -0,0 +1,393 @@
+package parsemail
+
+import (
+	"bytes"
+	"html"
+	"net/mail"
+	"regexp"
+	"strings"
+	"text/template"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// AttributionTemplates holds the text/template used for the attribution line
+// of quoted replies, keyed by language tag. The templates are executed with
+// the fields From and Date. Entries may be added or replaced by the caller.
+var AttributionTemplates = map[string]string{
+	"en": "On {{.Date}}, {{.From}} wrote:",
+	"de": "Am {{.Date}} schrieb {{.From}}:",
+	"fr": "Le {{.Date}}, {{.From}} a écrit :",
+	"es": "El {{.Date}}, {{.From}} escribió:",
+	"it": "Il {{.Date}}, {{.From}} ha scritto:",
+	"nl": "Op {{.Date}} schreef {{.From}}:",
+}
+
+const attributionDateFormat = "Mon, 2 Jan 2006 15:04"
+
+// Attribution returns the localized "On ..., ... wrote:" line introducing a
+// quote of the email. Unknown languages fall back to their primary subtag and
+// then to English.
+func (e *Email) Attribution(lang string) (string, error) {
+	text, ok := AttributionTemplates[lang]
+	if !ok {
+		text, ok = AttributionTemplates[strings.SplitN(lang, "-", 2)[0]]
+	}
+	if !ok {
+		text = AttributionTemplates["en"]
+	}
+
+	t, err := template.New("attribution").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, struct{ From, Date string }{
+		From: DisplayAddressList([]AddressGroup{{Addresses: e.From}}),
+		Date: e.Date.Format(attributionDateFormat),
+	})
+
+	return buf.String(), err
+}
+
+// QuoteText returns the plain text body of the email quoted for a reply: the
+// attribution line followed by the body with every line prefixed by "> ".
+// Emails with only an html body are quoted by their text content.
+func (e *Email) QuoteText(lang string) (string, error) {
+	attribution, err := e.Attribution(lang)
+	if err != nil {
+		return "", err
+	}
+
+	body := e.TextBody
+	if body == "" && e.HTMLBody != "" {
+		body = htmlToText(e.HTMLBody)
+	}
+
+	lines := strings.Split(strings.TrimRight(body, "\r\n"), "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, ">"):
+			lines[i] = ">" + line
+		case line == "":
+			lines[i] = ">"
+		default:
+			lines[i] = "> " + line
+		}
+	}
+
+	return attribution + "\n" + strings.Join(lines, "\n"), nil
+}
+
+// QuoteHTML returns the html body of the email quoted for a reply: the
+// attribution line followed by the original body in a blockquote. Style
+// elements of the original document are kept inside the quote, with their
+// rules scoped to it. Scripts, event handlers, forms and embedded objects
+// are removed. Emails with only a text body are quoted as escaped text.
+func (e *Email) QuoteHTML(lang string) (string, error) {
+	attribution, err := e.Attribution(lang)
+	if err != nil {
+		return "", err
+	}
+
+	var content string
+	if e.HTMLBody != "" {
+		content, err = htmlBodyContent(e.HTMLBody)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		content = strings.Replace(html.EscapeString(strings.TrimRight(e.TextBody, "\r\n")), "\n", "<br>\n", -1)
+	}
+
+	return `<div class="parsemail-quote"><div>` + html.EscapeString(attribution) + `</div>` +
+		`<blockquote type="cite" style="margin:0 0 0 .8ex;border-left:1px #ccc solid;padding-left:1ex">` +
+		content + `</blockquote></div>`, nil
+}
+
+// quoteScope is the selector of the quoted content of QuoteHTML
+const quoteScope = ".parsemail-quote > blockquote"
+
+// htmlBodyContent returns the style elements and the inner html of the body
+// of an html document, sanitized to be quoted.
+func htmlBodyContent(s string) (string, error) {
+	doc, err := nethtml.Parse(strings.NewReader(s))
+	if err != nil {
+		return "", err
+	}
+
+	var styles, body []*nethtml.Node
+	var walk func(n *nethtml.Node)
+	walk = func(n *nethtml.Node) {
+		if n.Type == nethtml.ElementNode {
+			switch n.DataAtom {
+			case atom.Style:
+				styles = append(styles, n)
+				return
+			case atom.Body:
+				sanitizeQuote(n)
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					body = append(body, c)
+				}
+				return
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	for _, n := range styles {
+		scopeStyle(n)
+	}
+
+	var buf bytes.Buffer
+	for _, n := range append(styles, body...) {
+		if err := nethtml.Render(&buf, n); err != nil {
+			return "", err
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// sanitizeQuote removes the active elements, comments, event handlers and
+// script URLs below n, like Neutralize but keeping links and images. Forms
+// are replaced by their content and style elements are scoped to the quote.
+func sanitizeQuote(n *nethtml.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		switch {
+		case c.Type == nethtml.CommentNode:
+			n.RemoveChild(c)
+		case c.Type != nethtml.ElementNode:
+		case activeElements[c.Data]:
+			n.RemoveChild(c)
+		case formElements[c.Data]:
+			if c.FirstChild != nil {
+				next = c.FirstChild
+			}
+			for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+				c.RemoveChild(gc)
+				n.InsertBefore(gc, c)
+			}
+			n.RemoveChild(c)
+		case c.DataAtom == atom.Style:
+			scopeStyle(c)
+		default:
+			c.Attr = quoteAttributes(c.Attr)
+			sanitizeQuote(c)
+		}
+
+		c = next
+	}
+}
+
+// quoteAttributes removes the event handlers, the URLs running scripts and
+// the URLs of local files from attrs
+func quoteAttributes(attrs []nethtml.Attribute) []nethtml.Attribute {
+	var kept []nethtml.Attribute
+	for _, attr := range attrs {
+		key := attr.Key
+		if attr.Namespace != "" {
+			key = attr.Namespace + ":" + key
+		}
+
+		value := strings.ToLower(strings.Map(func(r rune) rune {
+			if r <= ' ' {
+				return -1
+			}
+			return r
+		}, attr.Val))
+
+		switch {
+		case strings.HasPrefix(key, "on"):
+			continue
+		case !urlAttributes[key]:
+		case strings.HasPrefix(value, "javascript:") || strings.HasPrefix(value, "vbscript:") || strings.HasPrefix(value, "file:"):
+			continue
+		case strings.HasPrefix(value, "data:") && (!strings.HasPrefix(value, "data:image/") || strings.HasPrefix(value, "data:image/svg")):
+			continue
+		}
+
+		kept = append(kept, attr)
+	}
+
+	return kept
+}
+
+// scopeStyle scopes the rules of the style element n to the quote
+func scopeStyle(n *nethtml.Node) {
+	n.Attr = nil
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == nethtml.TextNode {
+			c.Data = scopeCSS(c.Data, quoteScope)
+		}
+	}
+}
+
+var cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// scopeCSS prefixes the selectors of the style sheet css with scope, so its
+// rules only apply below it. Selectors of html, body and :root select scope
+// itself. The rules of @media and @supports blocks are scoped in turn, other
+// blocks like @font-face are kept. @import rules are dropped.
+func scopeCSS(css, scope string) string {
+	css = cssComment.ReplaceAllString(css, "")
+
+	var rules []string
+	for {
+		css = strings.TrimSpace(css)
+		if css == "" {
+			return strings.Join(rules, "\n")
+		}
+
+		open := strings.IndexByte(css, '{')
+		if semi := strings.IndexByte(css, ';'); semi >= 0 && (open < 0 || semi < open) {
+			// a statement like @charset, or garbage
+			if rule := css[:semi+1]; strings.HasPrefix(rule, "@") && !strings.HasPrefix(strings.ToLower(rule), "@import") {
+				rules = append(rules, rule)
+			}
+			css = css[semi+1:]
+			continue
+		}
+		if open < 0 {
+			return strings.Join(rules, "\n")
+		}
+
+		end := cssBlockEnd(css, open)
+		prelude, block := strings.TrimSpace(css[:open]), css[open:end]
+		css = css[end:]
+
+		lower := strings.ToLower(prelude)
+		switch {
+		case strings.HasPrefix(lower, "@media") || strings.HasPrefix(lower, "@supports"):
+			inner := strings.TrimSuffix(block[1:], "}")
+			rules = append(rules, prelude+" {\n"+scopeCSS(inner, scope)+"\n}")
+		case strings.HasPrefix(lower, "@"):
+			rules = append(rules, prelude+" "+block)
+		default:
+			rules = append(rules, scopeSelectors(prelude, scope)+" "+block)
+		}
+	}
+}
+
+// cssBlockEnd returns the index after the brace closing the block opened at
+// open, or the length of css if it isn't closed
+func cssBlockEnd(css string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(css); i++ {
+		switch c := css[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(css)
+}
+
+// cssRootSelector matches a compound selector of the html or body element,
+// with its classes, ids, attributes and pseudo-classes, and the combinator
+// following it
+var cssRootSelector = regexp.MustCompile(`(?i)^(?:html|body|:root)(?:[.#][-\w]+|\[[^\]]*\]|::?[-\w]+(?:\([^)]*\))?)*(\s*[>+~]\s*|\s+|$)`)
+
+// scopeSelectors prefixes each selector of a comma separated list with scope
+func scopeSelectors(selectors, scope string) string {
+	var scoped []string
+	for _, sel := range strings.Split(selectors, ",") {
+		sel = strings.TrimSpace(sel)
+		if sel == "" {
+			continue
+		}
+
+		// root compounds select scope, the combinator after the last one is
+		// kept
+		combinator := ""
+		for {
+			m := cssRootSelector.FindStringSubmatch(sel)
+			if m == nil {
+				break
+			}
+			sel = strings.TrimSpace(sel[len(m[0]):])
+			combinator = strings.TrimSpace(m[1])
+		}
+		if combinator != "" && sel != "" {
+			sel = combinator + " " + sel
+		}
+
+		if sel == "" {
+			scoped = append(scoped, scope)
+		} else {
+			scoped = append(scoped, scope+" "+sel)
+		}
+	}
+
+	return strings.Join(scoped, ", ")
+}
+
+// ReplyRecipients returns the addresses a reply to the author should be sent
+// to: Mail-Reply-To if present, otherwise Reply-To, otherwise From.
+func (e *Email) ReplyRecipients() []*mail.Address {
+	switch {
+	case len(e.MailReplyTo) > 0:
+		return e.MailReplyTo
+	case len(e.ReplyTo) > 0:
+		return e.ReplyTo
+	default:
+		return e.From
+	}
+}
+
+// ReplyAllRecipients returns the recipients of a reply to all. When the
+// author asked for followups to go to Mail-Followup-To, those addresses are
+// the only recipients. Otherwise the reply goes to the ReplyRecipients with
+// the original To and Cc addresses copied. The addresses in self are left out.
+func (e *Email) ReplyAllRecipients(self ...string) (to, cc []*mail.Address) {
+	seen := map[string]bool{}
+	for _, s := range self {
+		seen[strings.ToLower(s)] = true
+	}
+
+	add := func(list []*mail.Address, al []*mail.Address) []*mail.Address {
+		for _, a := range al {
+			if a == nil || seen[strings.ToLower(a.Address)] {
+				continue
+			}
+
+			seen[strings.ToLower(a.Address)] = true
+			list = append(list, a)
+		}
+
+		return list
+	}
+
+	if len(e.MailFollowupTo) > 0 {
+		return add(nil, e.MailFollowupTo), nil
+	}
+
+	to = add(nil, e.ReplyRecipients())
+	to = add(to, e.To)
+	cc = add(nil, e.Cc)
+
+	return
+}
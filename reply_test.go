@@ -0,0 +1,212 @@
+package parsemail
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestQuoteText(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		lang     string
+		quoted   string
+	}{
+		1: {
+			mailData: rfc5322exampleA11,
+			lang:     "en",
+			quoted: `On Fri, 21 Nov 1997 09:55, John Doe <jdoe@machine.example> wrote:
+> This is a message just to say hello.
+> So, "Hello".`,
+		},
+		2: {
+			mailData: transcriptQuotedReply,
+			lang:     "de-AT",
+			quoted: `Am Fri, 21 Nov 1997 10:30 schrieb Mary Smith <mary@example.net>:
+> This is my answer.
+>
+> On Fri, 21 Nov 1997, John Doe wrote:
+>> quoted hello`,
+		},
+		3: {
+			mailData: textHTMLInMultipart,
+			lang:     "xx",
+			quoted: `On Thu, 2 May 2019 11:25, Rares <rares@example.com> wrote:
+> html text part`,
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		quoted, err := e.QuoteText(td.lang)
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+		} else if quoted != td.quoted {
+			t.Errorf("[Test Case %v] Wrong quoted body. Expected: %q, Got: %q", index, td.quoted, quoted)
+		}
+	}
+}
+
+func TestQuoteHTML(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		quoted   string
+	}{
+		1: {
+			mailData: rfc5322exampleA11,
+			quoted: `<div class="parsemail-quote"><div>On Fri, 21 Nov 1997 09:55, John Doe &lt;jdoe@machine.example&gt; wrote:</div>` +
+				`<blockquote type="cite" style="margin:0 0 0 .8ex;border-left:1px #ccc solid;padding-left:1ex">` +
+				"This is a message just to say hello.<br>\nSo, &#34;Hello&#34;.</blockquote></div>",
+		},
+		2: {
+			mailData: quoteHTMLStyled,
+			quoted: `<div class="parsemail-quote"><div>On Fri, 21 Nov 1997 09:55, John Doe &lt;jdoe@machine.example&gt; wrote:</div>` +
+				`<blockquote type="cite" style="margin:0 0 0 .8ex;border-left:1px #ccc solid;padding-left:1ex">` +
+				`<style>.parsemail-quote > blockquote p { color: red; }</style><p>Hello</p></blockquote></div>`,
+		},
+		3: {
+			mailData: quoteHTMLActive,
+			quoted: `<div class="parsemail-quote"><div>On Fri, 21 Nov 1997 09:55, John Doe &lt;jdoe@machine.example&gt; wrote:</div>` +
+				`<blockquote type="cite" style="margin:0 0 0 .8ex;border-left:1px #ccc solid;padding-left:1ex">` +
+				"<style>.parsemail-quote > blockquote { margin: 0; }\n.parsemail-quote > blockquote > p, .parsemail-quote > blockquote .note { color: red; }\n" +
+				"@media (max-width: 600px) {\n.parsemail-quote > blockquote p { font-size: 12px; }\n}</style>" +
+				`<p style="color:blue">Hello <a href="https://example.org/">there</a> <a>run</a><img/></p>` +
+				`Name: <style>.parsemail-quote > blockquote div { display: none; }</style></blockquote></div>`,
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		quoted, err := e.QuoteHTML("en")
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+		} else if quoted != td.quoted {
+			t.Errorf("[Test Case %v] Wrong quoted body. Expected: %q, Got: %q", index, td.quoted, quoted)
+		}
+	}
+}
+
+func TestScopeSelectors(t *testing.T) {
+	var testData = map[int]struct {
+		selectors string
+		expected  string
+	}{
+		1: {"p, .note", "S p, S .note"},
+		2: {"html body", "S"},
+		3: {"body.foo, body#x, :root[lang=en]", "S, S, S"},
+		4: {"html>body p", "S p"},
+		5: {"body > p", "S > p"},
+		6: {"body.foo + div", "S + div"},
+		7: {"bodyguard p", "S bodyguard p"},
+		8: {"body:not(.dark) a:hover", "S a:hover"},
+	}
+
+	for index, td := range testData {
+		if got := scopeSelectors(td.selectors, "S"); got != td.expected {
+			t.Errorf("[Test Case %v] Wrong selectors. Expected: %q, Got: %q", index, td.expected, got)
+		}
+	}
+}
+
+var quoteHTMLStyled = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Saying Hello
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Content-Type: text/html; charset=utf-8
+
+<html><head><style>p { color: red; }</style></head><body><p>Hello</p></body></html>
+`
+
+var quoteHTMLActive = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Saying Hello
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Content-Type: text/html; charset=utf-8
+
+<html><head><style>@import url(https://example.org/x.css); html body { margin: 0; }
+/* highlighted */ body > p, .note { color: red; }
+@media (max-width: 600px) { p { font-size: 12px; } }</style><script>alert(1)</script></head>` +
+	`<body onload="alert(2)"><p style="color:blue" onclick="alert(3)">Hello <a href="https://example.org/">there</a> ` +
+	`<a href=" java&#09;script:alert(4)">run</a><img src="file:///etc/passwd"></p><!-- note --><form action="https://example.org/">Name: <input name="n"></form>` +
+	`<style>div { display: none; }</style><iframe src="https://example.org/"></iframe></body></html>
+`
+
+func TestReplyRecipients(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		self     []string
+		reply    []string
+		to       []string
+		cc       []string
+	}{
+		1: {
+			mailData: rfc5322exampleA12,
+			self:     []string{"jdoe@example.org"},
+			reply:    []string{"john.q.public@example.com"},
+			to:       []string{"john.q.public@example.com", "mary@x.test", "one@y.test"},
+			cc:       []string{"boss@nil.test", "sysservices@example.net"},
+		},
+		2: {
+			mailData: rfc5322exampleA2a,
+			self:     []string{"JDOE@machine.example"},
+			reply:    []string{"smith@home.example"},
+			to:       []string{"smith@home.example"},
+		},
+		3: {
+			mailData: replyMailingList,
+			self:     []string{"me@example.org"},
+			reply:    []string{"private@example.net"},
+			to:       []string{"list@lists.example", "author@example.net"},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if reply := addressStrings(e.ReplyRecipients()); !assertSliceEq(td.reply, reply) {
+			t.Errorf("[Test Case %v] Wrong reply recipients. Expected: %s, Got: %s", index, td.reply, reply)
+		}
+
+		to, cc := e.ReplyAllRecipients(td.self...)
+		if !assertSliceEq(td.to, addressStrings(to)) {
+			t.Errorf("[Test Case %v] Wrong reply all to. Expected: %s, Got: %s", index, td.to, addressStrings(to))
+		}
+
+		if !assertSliceEq(td.cc, addressStrings(cc)) {
+			t.Errorf("[Test Case %v] Wrong reply all cc. Expected: %s, Got: %s", index, td.cc, addressStrings(cc))
+		}
+	}
+}
+
+func addressStrings(al []*mail.Address) (result []string) {
+	for _, a := range al {
+		result = append(result, a.Address)
+	}
+
+	return
+}
+
+var replyMailingList = `From: Author <author@example.net>
+To: List <list@lists.example>
+Cc: me@example.org
+Mail-Reply-To: Author <private@example.net>
+Mail-Followup-To: List <list@lists.example>, Author <author@example.net>
+Subject: Discussion
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+
+Hello list.
+`
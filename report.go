@@ -0,0 +1,354 @@
+package parsemail
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+)
+
+// ReportKind classifies delivery status and disposition notifications
+type ReportKind string
+
+const (
+	ReportDelivered   ReportKind = "delivered"
+	ReportFailed      ReportKind = "failed"
+	ReportDelayed     ReportKind = "delayed"
+	ReportRead        ReportKind = "read"
+	ReportDisposition ReportKind = "disposition"
+)
+
+// reportPartTypes are the machine readable parts of a multipart/report
+var reportPartTypes = []string{
+	"message/delivery-status",
+	"message/global-delivery-status",
+	"message/disposition-notification",
+	"message/global-disposition-notification",
+	contentTypeFeedbackReport,
+	"text/rfc822-headers",
+	"message/global-headers",
+}
+
+// DeliveryReport is the content of a delivery status notification (RFC 3464)
+// or a message disposition notification (RFC 8098)
+type DeliveryReport struct {
+	Kind              ReportKind
+	OriginalMessageID string
+	Recipients        []ReportRecipient
+	Disposition       string
+}
+
+// ReportRecipient is the per-recipient part of a delivery status
+// notification
+type ReportRecipient struct {
+	Recipient      string
+	Action         string
+	Status         string
+	DiagnosticCode string
+}
+
+// DeliveryReport classifies a multipart/report message as successful,
+// failed or delayed delivery, read receipt or other disposition. It returns
+// nil if the email contains no delivery or disposition report.
+func (e *Email) DeliveryReport() (*DeliveryReport, error) {
+	var report *DeliveryReport
+	var parseErr error
+
+	p := e.partParser()
+	err := e.walkReportParts(p, func(header textproto.MIMEHeader, body []byte) {
+		contentType, _, _ := parseContentType(header.Get("Content-Type"))
+		contentType = strings.ToLower(contentType)
+
+		switch contentType {
+		case "message/delivery-status", "message/global-delivery-status":
+			data, err := p.decodeRawBody(header, body)
+			if err != nil {
+				parseErr = err
+				return
+			}
+			report = parseDeliveryStatus(data)
+		case "message/disposition-notification", "message/global-disposition-notification":
+			data, err := p.decodeRawBody(header, body)
+			if err != nil {
+				parseErr = err
+				return
+			}
+			report = parseDispositionNotification(data)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	if report != nil && report.OriginalMessageID == "" && e.OriginalMessage != nil {
+		report.OriginalMessageID = e.OriginalMessage.MessageID
+	}
+
+	return report, nil
+}
+
+// originalMessageTypes are the parts of a multipart/report holding the
+// returned message or its header
+var originalMessageTypes = []string{
+	messageRFC822,
+	"text/rfc822-headers",
+	"message/global",
+	"message/global-headers",
+}
+
+// parseMultipartReport parses a multipart/report body (RFC 6522) like a
+// multipart/mixed one: the human readable part becomes the text body, the
+// other parts attachments. The returned message or header it includes
+// becomes the OriginalMessage of the email.
+func (p *parser) parseMultipartReport(msg io.Reader, boundary string) error {
+	outer := p.report
+	p.report = p.nesting + 1
+	defer func() { p.report = outer }()
+
+	return p.parseMultipartMixed(msg, boundary)
+}
+
+// addOriginalMessage makes the message or header of a part of a
+// multipart/report the OriginalMessage of the email, parsed like the
+// message of a message/rfc822 attachment. A part that can't be parsed is
+// left as an attachment only, unless it exceeds the limits of the options.
+func (p *parser) addOriginalMessage(at *Attachment) error {
+	if p.email.OriginalMessage != nil || !matchContentType(at.ContentType, originalMessageTypes) {
+		return nil
+	}
+
+	if at.ChildEmail != nil {
+		p.email.OriginalMessage = at.ChildEmail
+		return nil
+	}
+
+	if at.ContentType == messageRFC822 || p.depth+1 >= maxMessageDepth {
+		// parseChildEmail failed or refused to parse the message already
+		return nil
+	}
+
+	data, err := at.bytes()
+	if err != nil {
+		return nil
+	}
+
+	e, err := (&Parser{opts: p.opts}).parse(bytes.NewReader(data), p)
+	if err == nil {
+		p.email.OriginalMessage = &e
+	} else if limitError(err) {
+		return err
+	}
+
+	return nil
+}
+
+// reportOriginalMessage parses the returned message of the multipart/report
+// body in content, as schema versions before 5 did besides keeping the body
+// in Content. content is rewound afterwards.
+func (p *parser) reportOriginalMessage(content io.Reader, boundary string) *Email {
+	data, err := rewindData(&content)
+	if err != nil {
+		return nil
+	}
+
+	scratch := Email{SchemaVersion: p.email.SchemaVersion}
+	rp := *p
+	rp.email = &scratch
+	rp.parseMultipartReport(bytes.NewReader(data), boundary)
+	p.parts = rp.parts
+
+	return scratch.OriginalMessage
+}
+
+// walkReportParts calls fn with the header and body of the parts of the
+// original message, counted against the part limits by p. Without the raw
+// message it walks the parsed attachments instead, which hold the parts of
+// reports, or the Content of a report parsed with a schema version before 5.
+func (e *Email) walkReportParts(p *parser, fn func(header textproto.MIMEHeader, body []byte)) error {
+	if raw, err := e.rawMessage(); err == nil {
+		return p.walkRawParts(raw, fn)
+	}
+
+	if e.Content != nil {
+		data, err := rewindData(&e.Content)
+		if err != nil {
+			return err
+		}
+
+		header := "Content-Type: " + e.ContentType + "\r\n\r\n"
+		return p.walkRawParts(append([]byte(header), data...), fn)
+	}
+
+	for i := range e.Attachments {
+		at := &e.Attachments[i]
+		data, err := at.bytes()
+		if err != nil {
+			return err
+		}
+
+		contentType := at.RawContentType
+		if contentType == "" {
+			contentType = at.ContentType
+		}
+		fn(textproto.MIMEHeader{"Content-Type": {contentType}}, data)
+
+		// the parts of a forwarded message
+		if at.ChildEmail != nil {
+			if err := at.ChildEmail.walkReportParts(p, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseOriginalMessage parses the returned message or message headers
+// included in a multipart/report like addOriginalMessage, with the limits
+// of the options counting the parts of the report and of the message
+// together. It returns nil if there is none or it can't be parsed.
+func (e *Email) parseOriginalMessage() *Email {
+	var original *Email
+
+	p := e.partParser()
+	e.walkReportParts(p, func(header textproto.MIMEHeader, body []byte) {
+		if original != nil {
+			return
+		}
+
+		contentType, _, _ := parseContentType(header.Get("Content-Type"))
+		if matchContentType(contentType, originalMessageTypes) {
+			data, err := p.decodeRawBody(header, body)
+			if err != nil {
+				return
+			}
+
+			if e, err := (&Parser{opts: p.opts}).parse(bytes.NewReader(data), p); err == nil {
+				original = &e
+			}
+		}
+	})
+
+	return original
+}
+
+func parseDeliveryStatus(data []byte) *DeliveryReport {
+	report := &DeliveryReport{}
+
+	blocks := readHeaderBlocks(data)
+	if len(blocks) > 0 {
+		// the first block holds the per-message fields
+		blocks = blocks[1:]
+	}
+
+	failed, delayed := false, false
+	for _, h := range blocks {
+		r := ReportRecipient{
+			Recipient:      reportAddress(h.Get("Final-Recipient")),
+			Action:         strings.ToLower(strings.TrimSpace(h.Get("Action"))),
+			Status:         strings.TrimSpace(h.Get("Status")),
+			DiagnosticCode: strings.TrimSpace(h.Get("Diagnostic-Code")),
+		}
+		if r.Recipient == "" {
+			r.Recipient = reportAddress(h.Get("Original-Recipient"))
+		}
+
+		switch r.Action {
+		case "failed":
+			failed = true
+		case "delayed":
+			delayed = true
+		}
+
+		report.Recipients = append(report.Recipients, r)
+	}
+
+	switch {
+	case failed:
+		report.Kind = ReportFailed
+	case delayed:
+		report.Kind = ReportDelayed
+	default:
+		report.Kind = ReportDelivered
+	}
+
+	return report
+}
+
+func parseDispositionNotification(data []byte) *DeliveryReport {
+	report := &DeliveryReport{Kind: ReportDisposition}
+
+	h, err := readHeaderBlock(data)
+	if err != nil {
+		return report
+	}
+
+	report.OriginalMessageID = strings.Trim(h.Get("Original-Message-Id"), "<> ")
+	report.Disposition = strings.TrimSpace(h.Get("Disposition"))
+
+	// disposition: action-mode/sending-mode; disposition-type[/modifier]
+	dispositionType := report.Disposition
+	if i := strings.Index(dispositionType, ";"); i >= 0 {
+		dispositionType = dispositionType[i+1:]
+	}
+	dispositionType = strings.ToLower(strings.TrimSpace(strings.SplitN(dispositionType, "/", 2)[0]))
+	if dispositionType == "displayed" {
+		report.Kind = ReportRead
+	}
+
+	if r := reportAddress(h.Get("Final-Recipient")); r != "" {
+		report.Recipients = append(report.Recipients, ReportRecipient{Recipient: r, Action: dispositionType})
+	}
+
+	return report
+}
+
+// reportAddress strips the address type of a recipient field, e.g.
+// "rfc822; user@example.com"
+func reportAddress(s string) string {
+	if i := strings.Index(s, ";"); i >= 0 {
+		s = s[i+1:]
+	}
+
+	return strings.Trim(strings.TrimSpace(s), "<>")
+}
+
+// decodeRawBody undoes the Content-Transfer-Encoding of a raw part body
+// within the limits of the options
+func (p *parser) decodeRawBody(header textproto.MIMEHeader, body []byte) ([]byte, error) {
+	r, err := p.decodeContent(bytes.NewReader(body), header.Get("Content-Transfer-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(r)
+}
+
+func readHeaderBlock(data []byte) (textproto.MIMEHeader, error) {
+	h, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader()
+	if err == io.EOF {
+		err = nil
+	}
+
+	return h, err
+}
+
+// readHeaderBlocks reads consecutive header blocks separated by blank lines
+func readHeaderBlocks(data []byte) (blocks []textproto.MIMEHeader) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		h, err := r.ReadMIMEHeader()
+		if len(h) > 0 {
+			blocks = append(blocks, h)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
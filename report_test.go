@@ -0,0 +1,311 @@
+package parsemail
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDeliveryReport(t *testing.T) {
+	var testData = map[int]struct {
+		mailData          string
+		kind              ReportKind
+		originalMessageID string
+		recipients        []ReportRecipient
+	}{
+		1: {
+			mailData: rfc5322exampleA11,
+		},
+		2: {
+			mailData:          reportFailed,
+			kind:              ReportFailed,
+			originalMessageID: "1234@local.machine.example",
+			recipients: []ReportRecipient{
+				{
+					Recipient:      "mary@example.net",
+					Action:         "failed",
+					Status:         "5.1.1",
+					DiagnosticCode: "smtp; 550 5.1.1 user unknown",
+				},
+				{
+					Recipient: "jane@example.net",
+					Action:    "delivered",
+					Status:    "2.0.0",
+				},
+			},
+		},
+		3: {
+			mailData:          reportDelayed,
+			kind:              ReportDelayed,
+			originalMessageID: "1234@local.machine.example",
+			recipients: []ReportRecipient{
+				{
+					Recipient: "mary@example.net",
+					Action:    "delayed",
+					Status:    "4.4.7",
+				},
+			},
+		},
+		4: {
+			mailData:          reportRead,
+			kind:              ReportRead,
+			originalMessageID: "1234@local.machine.example",
+			recipients: []ReportRecipient{
+				{
+					Recipient: "mary@example.net",
+					Action:    "displayed",
+				},
+			},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		report, err := e.DeliveryReport()
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		if td.kind == "" {
+			if report != nil {
+				t.Errorf("[Test Case %v] Unexpected report: %v", index, report)
+			}
+			continue
+		}
+
+		if report == nil {
+			t.Errorf("[Test Case %v] Report not found", index)
+			continue
+		}
+
+		if report.Kind != td.kind {
+			t.Errorf("[Test Case %v] Wrong kind. Expected: %s, Got: %s", index, td.kind, report.Kind)
+		}
+
+		if report.OriginalMessageID != td.originalMessageID {
+			t.Errorf("[Test Case %v] Wrong original message id. Expected: %s, Got: %s", index, td.originalMessageID, report.OriginalMessageID)
+		}
+
+		if len(report.Recipients) != len(td.recipients) {
+			t.Errorf("[Test Case %v] Wrong recipients. Expected: %v, Got: %v", index, td.recipients, report.Recipients)
+			continue
+		}
+
+		for i, r := range report.Recipients {
+			if r != td.recipients[i] {
+				t.Errorf("[Test Case %v] Wrong recipient. Expected: %v, Got: %v", index, td.recipients[i], r)
+			}
+		}
+	}
+}
+
+func TestReportBody(t *testing.T) {
+	tagger := NewTagger()
+	tagger.Add(SubjectContains("Hello"), "original")
+
+	var testData = map[int]struct {
+		mailData    string
+		version     int
+		textBody    string
+		attachments []string
+	}{
+		1: {
+			mailData:    reportFailed,
+			textBody:    "Your message could not be delivered to one or more recipients.",
+			attachments: []string{"message/delivery-status", "text/rfc822-headers"},
+		},
+		2: {
+			mailData:    reportDelayed,
+			textBody:    "Your message is delayed.",
+			attachments: []string{"message/delivery-status", "message/rfc822"},
+		},
+		3: {
+			mailData: reportDelayed,
+			version:  4,
+		},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(td.mailData), Options{SchemaVersion: td.version, Tagger: tagger})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if e.TextBody != td.textBody {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, td.textBody, e.TextBody)
+		}
+
+		var attachments []string
+		for _, at := range e.Attachments {
+			attachments = append(attachments, at.ContentType)
+		}
+		if !assertSliceEq(td.attachments, attachments) {
+			t.Errorf("[Test Case %v] Wrong attachments. Expected: %v, Got: %v", index, td.attachments, attachments)
+		}
+
+		if (e.Content != nil) != (td.version == 4) {
+			t.Errorf("[Test Case %v] Wrong content. Got: %v", index, e.Content)
+		}
+
+		// the original message is parsed with the options of the report
+		if e.OriginalMessage == nil || !assertSliceEq([]string{"original"}, e.OriginalMessage.Tags) {
+			t.Errorf("[Test Case %v] Options not applied to the original message. Got: %+v", index, e.OriginalMessage)
+		}
+	}
+}
+
+func TestReportNestingLimit(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("From: MAILER-DAEMON@example.net\nContent-Type: multipart/report; boundary=\"b0\"\n\n")
+	for i := 1; i <= 200; i++ {
+		fmt.Fprintf(&sb, "--b%d\nContent-Type: multipart/mixed; boundary=\"b%d\"\n\n", i-1, i)
+	}
+
+	_, err := ParseWithOptions(strings.NewReader(sb.String()), Options{MaxDepth: 20})
+	if _, ok := err.(*PartLimitError); !ok {
+		t.Errorf("Wrong error for a deeply nested report. Expected: *PartLimitError, Got: %v", err)
+	}
+}
+
+var reportFailed = `From: Mail Delivery System <MAILER-DAEMON@example.net>
+To: John Doe <jdoe@machine.example>
+Subject: Undelivered Mail Returned to Sender
+Date: Fri, 21 Nov 1997 10:05:43 -0600
+Content-Type: multipart/report; report-type=delivery-status; boundary="REPORT"
+
+--REPORT
+Content-Type: text/plain
+
+Your message could not be delivered to one or more recipients.
+--REPORT
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+Arrival-Date: Fri, 21 Nov 1997 10:01:22 -0600
+
+Final-Recipient: rfc822; mary@example.net
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 user unknown
+
+Final-Recipient: rfc822; jane@example.net
+Action: delivered
+Status: 2.0.0
+--REPORT
+Content-Type: text/rfc822-headers
+
+From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Saying Hello
+Message-ID: <1234@local.machine.example>
+--REPORT--
+`
+
+var reportDelayed = `From: Mail Delivery System <MAILER-DAEMON@example.net>
+To: John Doe <jdoe@machine.example>
+Subject: Delayed Mail (still being retried)
+Date: Fri, 21 Nov 1997 14:05:43 -0600
+Content-Type: multipart/report; report-type=delivery-status; boundary="REPORT"
+
+--REPORT
+Content-Type: text/plain
+
+Your message is delayed.
+--REPORT
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+
+Original-Recipient: rfc822;<mary@example.net>
+Action: delayed
+Status: 4.4.7
+--REPORT
+Content-Type: message/rfc822
+
+From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Saying Hello
+Message-ID: <1234@local.machine.example>
+
+This is a message just to say hello.
+--REPORT--
+`
+
+var reportRead = `From: Mary Smith <mary@example.net>
+To: John Doe <jdoe@machine.example>
+Subject: Read: Saying Hello
+Date: Fri, 21 Nov 1997 10:05:43 -0600
+Content-Type: multipart/report; report-type=disposition-notification; boundary="REPORT"
+
+--REPORT
+Content-Type: text/plain
+
+Your message was displayed.
+--REPORT
+Content-Type: message/disposition-notification
+
+Reporting-UA: mail.example.net; Example Mail
+Final-Recipient: rfc822; mary@example.net
+Original-Message-ID: <1234@local.machine.example>
+Disposition: manual-action/MDN-sent-manually; displayed
+--REPORT--
+`
+
+func TestOriginalMessage(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		subject  string
+		textBody string
+	}{
+		1: {
+			mailData: rfc5322exampleA11,
+		},
+		2: {
+			mailData: reportFailed,
+			subject:  "Saying Hello",
+		},
+		3: {
+			mailData: reportDelayed,
+			subject:  "Saying Hello",
+			textBody: "This is a message just to say hello.",
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if td.subject == "" {
+			if e.OriginalMessage != nil {
+				t.Errorf("[Test Case %v] Unexpected original message", index)
+			}
+			continue
+		}
+
+		if e.OriginalMessage == nil {
+			t.Errorf("[Test Case %v] Original message not found", index)
+			continue
+		}
+
+		if e.OriginalMessage.Subject != td.subject {
+			t.Errorf("[Test Case %v] Wrong subject. Expected: %s, Got: %s", index, td.subject, e.OriginalMessage.Subject)
+		}
+
+		if e.OriginalMessage.TextBody != td.textBody {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, td.textBody, e.OriginalMessage.TextBody)
+		}
+
+		if len(e.OriginalMessage.To) != 1 || e.OriginalMessage.To[0].Address != "mary@example.net" {
+			t.Errorf("[Test Case %v] Wrong bounced recipient: %v", index, e.OriginalMessage.To)
+		}
+	}
+}
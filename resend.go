@@ -0,0 +1,127 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// resendRemovedHeaders are dropped by PrepareResend besides the trace fields
+// and X- fields. They reveal hidden recipients or the delivery to the
+// original recipient, or carry signatures broken by the new Message-ID and
+// Date.
+var resendRemovedHeaders = map[string]bool{
+	"Bcc":                        true,
+	"Resent-Bcc":                 true,
+	"Delivered-To":               true,
+	"Envelope-To":                true,
+	"Received-Spf":               true,
+	"Authentication-Results":     true,
+	"Arc-Seal":                   true,
+	"Arc-Message-Signature":      true,
+	"Arc-Authentication-Results": true,
+	"Dkim-Signature":             true,
+	"Message-Id":                 true,
+	"Date":                       true,
+}
+
+// ResendOptions configures PrepareResend
+type ResendOptions struct {
+	// Domain is used for the new Message-ID. It defaults to the domain of
+	// the first From address.
+	Domain string
+	// KeepHeaders lists the X- header fields kept, e.g. "X-Mailer"
+	KeepHeaders []string
+	// Now is the new Date, the current time if zero
+	Now time.Time
+}
+
+// PrepareResend returns the original message ready to be sent on by a relay
+// or forwarding service without leaking anything about its first delivery:
+// the Bcc and Resent-Bcc fields, the Return-Path and Received trace fields,
+// delivery and authentication results, signatures and X- fields not listed
+// in KeepHeaders are removed, and new Message-ID and Date fields are
+// prepended. The remaining fields and the body are unchanged.
+func (e *Email) PrepareResend(opts ResendOptions) ([]byte, error) {
+	id, err := newMessageID(resendDomain(e, opts.Domain))
+	if err != nil {
+		return nil, err
+	}
+
+	date := opts.Now
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	keep := map[string]bool{}
+	for _, k := range opts.KeepHeaders {
+		keep[strings.ToLower(k)] = true
+	}
+
+	raw, err := e.rawMessage()
+	if err != nil {
+		return nil, err
+	}
+	header, body := splitHeader(raw)
+	fields := splitHeaderFields(header)
+
+	// the new fields use the line break of the original message
+	newline := "\r\n"
+	if len(fields) > 0 && !bytes.HasSuffix(fields[0].Raw, []byte("\r\n")) {
+		newline = "\n"
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(raw))
+	buf.WriteString(strings.Replace(FoldHeader("Message-ID", "<"+id+">"), "\r\n", newline, -1))
+	buf.WriteString(strings.Replace(FoldHeader("Date", date.Format(time.RFC1123Z)), "\r\n", newline, -1))
+
+	rest := header
+	for _, f := range fields {
+		if !isResendRemoved(f.Name, keep) {
+			buf.Write(f.Raw)
+		}
+		rest = rest[len(f.Raw):]
+	}
+	buf.Write(rest)
+	buf.Write(body)
+
+	return buf.Bytes(), nil
+}
+
+func isResendRemoved(name string, keep map[string]bool) bool {
+	if isTraceField(name) || resendRemovedHeaders[name] {
+		return true
+	}
+
+	return strings.HasPrefix(name, "X-") && !keep[strings.ToLower(name)]
+}
+
+// resendDomain returns the domain of the new Message-ID
+func resendDomain(e *Email, domain string) string {
+	if domain != "" {
+		return domain
+	}
+
+	for _, a := range e.From {
+		if a != nil {
+			if i := strings.LastIndex(a.Address, "@"); i >= 0 {
+				return a.Address[i+1:]
+			}
+		}
+	}
+
+	return "localhost"
+}
+
+// newMessageID returns a random Message-ID without angle brackets
+func newMessageID(domain string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b) + "@" + domain, nil
+}
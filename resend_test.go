@@ -0,0 +1,93 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrepareResend(t *testing.T) {
+	now := time.Date(2020, 5, 4, 12, 0, 0, 0, time.UTC)
+
+	var testData = map[int]struct {
+		mailData string
+		opts     ResendOptions
+		expected string
+	}{
+		1: {
+			mailData: resendExample,
+			opts:     ResendOptions{Domain: "relay.example", Now: now},
+			expected: "Date: Mon, 04 May 2020 12:00:00 +0000\r\n" +
+				"From: John Doe <jdoe@node.example>\r\n" +
+				"To: Mary Smith <mary@example.net>\r\n" +
+				"Subject: Hello\r\n" +
+				"\r\n" +
+				"Hello.\r\n",
+		},
+		2: {
+			mailData: resendExample,
+			opts:     ResendOptions{KeepHeaders: []string{"x-mailer"}, Now: now},
+			expected: "Date: Mon, 04 May 2020 12:00:00 +0000\r\n" +
+				"From: John Doe <jdoe@node.example>\r\n" +
+				"To: Mary Smith <mary@example.net>\r\n" +
+				"X-Mailer: Mailer 1.0\r\n" +
+				"Subject: Hello\r\n" +
+				"\r\n" +
+				"Hello.\r\n",
+		},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(td.mailData), Options{KeepRaw: true})
+		if err != nil {
+			t.Errorf("[Test Case %v] Error parsing email: %v", index, err)
+			continue
+		}
+
+		raw, err := e.PrepareResend(td.opts)
+		if err != nil {
+			t.Errorf("[Test Case %v] Error preparing email: %v", index, err)
+			continue
+		}
+
+		resent, err := Parse(strings.NewReader(string(raw)))
+		if err != nil {
+			t.Errorf("[Test Case %v] Error parsing prepared email: %v", index, err)
+			continue
+		}
+
+		if resent.MessageID == "" || resent.MessageID == e.MessageID {
+			t.Errorf("[Test Case %v] Wrong Message-ID. Expected a new one, Got: %s", index, resent.MessageID)
+		}
+
+		domain := td.opts.Domain
+		if domain == "" {
+			domain = "node.example"
+		}
+		if !strings.HasSuffix(resent.MessageID, "@"+domain) {
+			t.Errorf("[Test Case %v] Wrong Message-ID domain. Expected: %s, Got: %s", index, domain, resent.MessageID)
+		}
+
+		// the Message-ID is random, compare the rest
+		rest := string(raw[strings.Index(string(raw), "\r\n")+2:])
+		if rest != td.expected {
+			t.Errorf("[Test Case %v] Wrong prepared message. Expected: %q, Got: %q", index, td.expected, rest)
+		}
+	}
+}
+
+var resendExample = "Return-Path: <jdoe@node.example>\r\n" +
+	"Received: from node.example by x.y.test; 21 Nov 1997 10:01:22 -0600\r\n" +
+	"Delivered-To: mary@example.net\r\n" +
+	"DKIM-Signature: v=1; a=rsa-sha256; d=node.example; s=mail;\r\n" +
+	"\tb=abc\r\n" +
+	"From: John Doe <jdoe@node.example>\r\n" +
+	"To: Mary Smith <mary@example.net>\r\n" +
+	"Bcc: secret@example.org\r\n" +
+	"X-Mailer: Mailer 1.0\r\n" +
+	"X-Internal-Route: queue-7\r\n" +
+	"Subject: Hello\r\n" +
+	"Date: Fri, 21 Nov 1997 09:55:06 -0600\r\n" +
+	"Message-ID: <1234@local.node.example>\r\n" +
+	"\r\n" +
+	"Hello.\r\n"
@@ -0,0 +1,91 @@
+package parsemail
+
+import (
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// EmbeddedFileByReference returns the embedded file an html body refers to
+// by ref, either a "cid:" URL matched against the Content-ID or any other
+// URL matched against the Content-Location of the embedded files. Relative
+// references and locations are resolved against the base of the html body
+// first (RFC 2557 section 5), its Content-Base or absolute Content-Location,
+// or else those of the message.
+func (e *Email) EmbeddedFileByReference(ref string) (EmbeddedFile, bool) {
+	ref = strings.TrimSpace(ref)
+
+	if len(ref) > 4 && strings.EqualFold(ref[:4], "cid:") {
+		cid := ref[4:]
+		if unescaped, err := url.PathUnescape(cid); err == nil {
+			cid = unescaped
+		}
+
+		for _, ef := range e.EmbeddedFiles {
+			if ef.CID == cid {
+				return ef, true
+			}
+		}
+
+		return EmbeddedFile{}, false
+	}
+
+	if ref == "" {
+		return EmbeddedFile{}, false
+	}
+
+	base := e.contentBase()
+	target := resolveLocation(base, ref)
+	for _, ef := range e.EmbeddedFiles {
+		if ef.ContentLocation == "" {
+			continue
+		}
+		if ef.ContentLocation == ref || resolveLocation(base, ef.ContentLocation) == target {
+			return ef, true
+		}
+	}
+
+	return EmbeddedFile{}, false
+}
+
+// contentBase returns the absolute URL relative locations of the html body
+// are resolved against, or nil. The Content-Base or Content-Location of the
+// body part, resolved against those of the message, take precedence.
+func (e *Email) contentBase() *url.URL {
+	var base *url.URL
+	for _, h := range []mail.Header{e.Header, e.HTMLBodyHeader} {
+		for _, field := range []string{"Content-Base", "Content-Location"} {
+			v := strings.TrimSpace(h.Get(field))
+			if v == "" {
+				continue
+			}
+
+			u, err := url.Parse(v)
+			if err != nil {
+				continue
+			}
+			if base != nil {
+				u = base.ResolveReference(u)
+			}
+			if u.IsAbs() {
+				base = u
+				break
+			}
+		}
+	}
+
+	return base
+}
+
+// resolveLocation resolves loc against base, if there is one
+func resolveLocation(base *url.URL, loc string) string {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return loc
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+
+	return u.String()
+}
@@ -0,0 +1,116 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedFileByReference(t *testing.T) {
+	var testData = map[int]struct {
+		mailData    string
+		ref         string
+		found       bool
+		contentType string
+	}{
+		1: {
+			mailData:    multipartRelatedEmbeddedNoDisposition,
+			ref:         "cid:logo@test.lan",
+			found:       true,
+			contentType: "image/png",
+		},
+		2: {
+			mailData: multipartRelatedEmbeddedNoDisposition,
+			ref:      "cid:other@test.lan",
+		},
+		3: {
+			mailData:    multipartRelatedContentLocation,
+			ref:         "http://www.example.com/images/logo.gif",
+			found:       true,
+			contentType: "image/gif",
+		},
+		4: {
+			mailData: multipartRelatedContentLocation,
+			ref:      "http://www.example.com/images/other.gif",
+		},
+		5: {
+			mailData:    multipartRelatedRelativeLocation,
+			ref:         "images/logo.gif",
+			found:       true,
+			contentType: "image/gif",
+		},
+		6: {
+			mailData:    multipartRelatedRelativeLocation,
+			ref:         "http://www.example.com/pages/images/logo.gif",
+			found:       true,
+			contentType: "image/gif",
+		},
+		7: {
+			mailData:    multipartRelatedRelativeLocation,
+			ref:         "../pages/./images/logo.gif",
+			found:       true,
+			contentType: "image/gif",
+		},
+		8: {
+			mailData: multipartRelatedRelativeLocation,
+			ref:      "http://www.example.com/images/logo.gif",
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		ef, found := e.EmbeddedFileByReference(td.ref)
+		if found != td.found {
+			t.Errorf("[Test Case %v] Wrong result. Expected: %v, Got: %v", index, td.found, found)
+		} else if found && ef.ContentType != td.contentType {
+			t.Errorf("[Test Case %v] Wrong content type. Expected: %s, Got: %s", index, td.contentType, ef.ContentType)
+		}
+	}
+}
+
+var multipartRelatedContentLocation = `From: Test <test@test.lan>
+To: Test 2 <test2@test.lan>
+Subject: Test 1
+Date: Thu, 13 Feb 2020 13:39:30 +0000
+Content-Type: multipart/related; boundary="REL"; type="text/html"
+MIME-Version: 1.0
+
+--REL
+Content-Type: text/html; charset="utf-8"
+Content-Location: http://www.example.com/index.html
+
+<html><body><img src="http://www.example.com/images/logo.gif"></body></html>
+--REL
+Content-Type: image/gif
+Content-Location: http://www.example.com/images/logo.gif
+Content-Transfer-Encoding: base64
+
+R0lGODlhAQE7
+--REL--
+`
+
+var multipartRelatedRelativeLocation = `From: Test <test@test.lan>
+To: Test 2 <test2@test.lan>
+Subject: Test 1
+Date: Thu, 13 Feb 2020 13:39:30 +0000
+Content-Type: multipart/related; boundary="REL"; type="text/html"
+Content-Base: http://www.example.com/
+MIME-Version: 1.0
+
+--REL
+Content-Type: text/html; charset="utf-8"
+Content-Location: pages/index.html
+
+<html><body><img src="images/logo.gif"></body></html>
+--REL
+Content-Type: image/gif
+Content-Location: images/logo.gif
+Content-Transfer-Encoding: base64
+
+R0lGODlhAQE7
+--REL--
+`
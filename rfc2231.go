@@ -0,0 +1,141 @@
+package parsemail
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	cs "golang.org/x/net/html/charset"
+)
+
+// parseMediaType parses a Content-Type or Content-Disposition value like
+// mime.ParseMediaType, which only decodes RFC 2231 parameters in UTF-8 and
+// US-ASCII. Parameters in other charsets, like filename*=iso-8859-1'de'...
+// or continuations of filename*0*=, filename*1*=, are decoded in any
+// charset the bodies may use. Malformed percent escapes are kept as they
+// are.
+func parseMediaType(v string) (mediatype string, params map[string]string, err error) {
+	mediatype, params, err = mime.ParseMediaType(v)
+	if err != nil {
+		return
+	}
+
+	// mime.ParseMediaType keeps the sections following a dropped one
+	for name, value := range extendedParams(v) {
+		params[name] = value
+	}
+
+	return
+}
+
+// parseMediaTypeVersion parses a media type with the semantics of the schema
+// version. Versions before 6 decode RFC 2231 parameters in UTF-8 and
+// US-ASCII only, like mime.ParseMediaType.
+func parseMediaTypeVersion(v string, version int) (string, map[string]string, error) {
+	if version < 6 {
+		return mime.ParseMediaType(v)
+	}
+
+	return parseMediaType(v)
+}
+
+// extendedSection is one section of an RFC 2231 parameter
+type extendedSection struct {
+	index   int
+	value   string
+	encoded bool
+}
+
+// extendedParams decodes the RFC 2231 parameters of a media type value,
+// keyed by their lower case name without "*"
+func extendedParams(v string) map[string]string {
+	sections := map[string][]extendedSection{}
+	for _, p := range splitQuoted(v, ';')[1:] {
+		eq := strings.IndexByte(p, '=')
+		if eq < 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(p[:eq]))
+		value := unquotePhrase(strings.TrimSpace(p[eq+1:]))
+
+		star := strings.IndexByte(name, '*')
+		if star < 0 {
+			continue
+		}
+
+		s := extendedSection{value: value, encoded: strings.HasSuffix(name, "*")}
+		if index := strings.TrimSuffix(name[star+1:], "*"); index != "" {
+			n, err := strconv.Atoi(index)
+			if err != nil {
+				continue
+			}
+			s.index = n
+		}
+		sections[name[:star]] = append(sections[name[:star]], s)
+	}
+
+	params := map[string]string{}
+	for name, ss := range sections {
+		sort.Slice(ss, func(i, j int) bool { return ss[i].index < ss[j].index })
+		if ss[0].index != 0 {
+			continue
+		}
+
+		// the charset and language precede the value of the first section
+		charset := ""
+		if ss[0].encoded {
+			parts := strings.SplitN(ss[0].value, "'", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			charset, ss[0].value = parts[0], parts[2]
+		}
+
+		var b []byte
+		for i, s := range ss {
+			if s.index != i {
+				// a missing section ends the value
+				break
+			}
+			if s.encoded {
+				b = append(b, percentUnescape(s.value)...)
+			} else {
+				b = append(b, s.value...)
+			}
+		}
+
+		params[name] = decodeCharset(charset, b)
+	}
+
+	return params
+}
+
+// percentUnescape decodes the %XX escapes of s, keeping malformed ones
+func percentUnescape(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(n))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+
+	return b
+}
+
+// decodeCharset converts b from the charset to UTF-8. Unknown charsets are
+// assumed to be UTF-8.
+func decodeCharset(charset string, b []byte) string {
+	if enc, _ := cs.Lookup(charset); enc != nil {
+		if decoded, err := enc.NewDecoder().Bytes(b); err == nil {
+			return string(decoded)
+		}
+	}
+
+	return string(b)
+}
@@ -0,0 +1,70 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMediaType(t *testing.T) {
+	var testData = map[int]struct {
+		value    string
+		param    string
+		expected string
+	}{
+		1: {value: `attachment; filename="report.pdf"`, param: "filename", expected: "report.pdf"},
+		2: {value: `attachment; filename*=utf-8''%E2%82%AC%20rates.pdf`, param: "filename", expected: "€ rates.pdf"},
+		3: {value: `attachment; filename*=iso-8859-1'de'Gr%FC%DFe.txt`, param: "filename", expected: "Grüße.txt"},
+		4: {value: `attachment; filename*0*=windows-1252''Rechnung%20M%E4rz; filename*1*=%202024; filename*2=".pdf"`, param: "filename", expected: "Rechnung März 2024.pdf"},
+		5: {value: `attachment; filename*1="part2.txt"; filename*0="a-very-long-name-"`, param: "filename", expected: "a-very-long-name-part2.txt"},
+		6: {value: `application/octet-stream; name*=koi8-r''%F0%D2%C9%D7%C5%D4`, param: "name", expected: "Привет"},
+		7: {value: `attachment; filename*=iso-8859-1''100%25%ZZ`, param: "filename", expected: "100%%ZZ"},
+		8: {value: `attachment; filename*0*=iso-8859-1''a%E4; filename*2*=c`, param: "filename", expected: "aä"},
+	}
+
+	for index, td := range testData {
+		_, params, err := parseMediaType(td.value)
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		if got := params[td.param]; got != td.expected {
+			t.Errorf("[Test Case %v] Wrong %s. Expected: %q, Got: %q", index, td.param, td.expected, got)
+		}
+	}
+}
+
+func TestExtendedFilenames(t *testing.T) {
+	raw := "From: a@example.com\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain\r\n\r\nSee attached\r\n" +
+		"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment;\r\n filename*0*=iso-8859-1''Pr%E9sentation%20;\r\n filename*1*=finale.pdf\r\n\r\n%PDF\r\n" +
+		"--b\r\nContent-Type: text/csv\r\nContent-Disposition: attachment; filename*=windows-1252''Zahlen%20%80.csv\r\n\r\na,b\r\n" +
+		"--b--\r\n"
+
+	e, err := Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var filenames []string
+	for _, a := range e.Attachments {
+		filenames = append(filenames, a.Filename)
+	}
+	if expected := []string{"Présentation finale.pdf", "Zahlen €.csv"}; !assertSliceEq(filenames, expected) {
+		t.Errorf("Wrong filenames. Expected: %q, Got: %q", expected, filenames)
+	}
+
+	// schema versions before 6 decode UTF-8 and US-ASCII parameters only
+	e, err = ParseWithOptions(strings.NewReader(raw), Options{SchemaVersion: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filenames = nil
+	for _, a := range e.Attachments {
+		filenames = append(filenames, a.Filename)
+	}
+	if expected := []string{"finale.pdf", ""}; !assertSliceEq(filenames, expected) {
+		t.Errorf("Wrong version 5 filenames. Expected: %q, Got: %q", expected, filenames)
+	}
+}
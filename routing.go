@@ -0,0 +1,186 @@
+package parsemail
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// maxRoutePattern limits the length of regular expressions of routes and
+// maxRouteAddress the length of addresses matched against them. Matching
+// time is linear in both.
+const (
+	maxRoutePattern = 512
+	maxRouteAddress = 254
+)
+
+// AddressMatcher reports whether an address belongs to a route
+type AddressMatcher func(address string) bool
+
+// MatchExact matches the given addresses, ignoring case. Subaddresses match
+// their base address, so "support@example.com" matches
+// "support+1234@example.com" too.
+func MatchExact(addresses ...string) AddressMatcher {
+	set := map[string]bool{}
+	for _, a := range addresses {
+		set[strings.ToLower(a)] = true
+	}
+
+	return func(address string) bool {
+		address = strings.ToLower(address)
+		return set[address] || set[ParseSubaddress(address).Base]
+	}
+}
+
+// MatchDomain matches all addresses of the given domains. Domains starting
+// with "*." or "." match their subdomains instead.
+func MatchDomain(domains ...string) AddressMatcher {
+	return func(address string) bool {
+		at := strings.LastIndex(address, "@")
+		if at < 0 {
+			return false
+		}
+		domain := strings.ToLower(address[at+1:])
+
+		for _, d := range domains {
+			d = strings.ToLower(strings.TrimPrefix(d, "*"))
+			if strings.HasPrefix(d, ".") && strings.HasSuffix(domain, d) || domain == d {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// MatchWildcard matches addresses against patterns where "*" matches any run
+// of characters and "?" a single one, e.g. "support-*@example.com". Case is
+// ignored.
+func MatchWildcard(patterns ...string) AddressMatcher {
+	return func(address string) bool {
+		address = strings.ToLower(address)
+		for _, p := range patterns {
+			if matchWildcard(strings.ToLower(p), address) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// MatchRegexp matches addresses against a regular expression, ignoring case.
+// The expression must match the whole address. Expressions longer than 512
+// bytes are rejected and addresses longer than 254 bytes never match, which
+// bounds the matching time.
+func MatchRegexp(expr string) (AddressMatcher, error) {
+	if len(expr) > maxRoutePattern {
+		return nil, fmt.Errorf("route pattern exceeds %d bytes", maxRoutePattern)
+	}
+
+	re, err := regexp.Compile(`(?i)^(?:` + expr + `)$`)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(address string) bool {
+		return len(address) <= maxRouteAddress && re.MatchString(address)
+	}, nil
+}
+
+// matchWildcard matches s against a pattern of "*" and "?" wildcards. It
+// backtracks to the last star only, so it runs in O(len(pattern)*len(s)).
+func matchWildcard(pattern, s string) bool {
+	p, i := 0, 0
+	star, mark := -1, 0
+
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+// Router assigns messages to the first of its routes matching a recipient
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	name  string
+	match AddressMatcher
+}
+
+// NewRouter returns a Router without routes
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Add appends a route. Routes are tried in the order they are added, so
+// catch-all routes should come last.
+func (r *Router) Add(name string, match AddressMatcher) {
+	r.routes = append(r.routes, route{name: name, match: match})
+}
+
+// Route returns the name of the first route matching a recipient of the
+// email and the matching address. Recipients are taken from Delivered-To,
+// which includes recipients only known to the envelope, To and Cc. ok is
+// false if no route matches.
+func (r *Router) Route(e *Email) (name, address string, ok bool) {
+	recipients := e.routingRecipients()
+
+	for _, rt := range r.routes {
+		for _, a := range recipients {
+			if rt.match(a) {
+				return rt.name, a, true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+// routingRecipients returns the addresses of the Delivered-To, To and Cc
+// fields, each once
+func (e *Email) routingRecipients() (addresses []string) {
+	seen := map[string]bool{}
+	for _, list := range [][]*mail.Address{e.deliveredTo(), e.To, e.Cc} {
+		for _, a := range list {
+			if a != nil && !seen[strings.ToLower(a.Address)] {
+				seen[strings.ToLower(a.Address)] = true
+				addresses = append(addresses, a.Address)
+			}
+		}
+	}
+
+	return
+}
+
+// deliveredTo returns the addresses of the Delivered-To fields
+func (e *Email) deliveredTo() (addresses []*mail.Address) {
+	for _, v := range e.Header["Delivered-To"] {
+		if a, err := mail.ParseAddress(v); err == nil {
+			addresses = append(addresses, a)
+		}
+	}
+
+	return
+}
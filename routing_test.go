@@ -0,0 +1,73 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddressMatchers(t *testing.T) {
+	re, err := MatchRegexp(`ticket-[0-9]+@support\.example\.com`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = map[int]struct {
+		match    AddressMatcher
+		address  string
+		expected bool
+	}{
+		1:  {MatchExact("support@example.com"), "Support@Example.com", true},
+		2:  {MatchExact("support@example.com"), "support+1234@example.com", true},
+		3:  {MatchExact("support@example.com"), "sales@example.com", false},
+		4:  {MatchDomain("example.com"), "anyone@EXAMPLE.com", true},
+		5:  {MatchDomain("example.com"), "anyone@mail.example.com", false},
+		6:  {MatchDomain("*.example.com"), "anyone@mail.example.com", true},
+		7:  {MatchDomain("*.example.com"), "anyone@badexample.com", false},
+		8:  {MatchWildcard("support-*@example.com"), "support-eu@example.com", true},
+		9:  {MatchWildcard("support-?@example.com"), "support-eu@example.com", false},
+		10: {MatchWildcard("*@*.example.com"), "a@b.example.com", true},
+		11: {re, "ticket-42@support.example.com", true},
+		12: {re, "x-ticket-42@support.example.com", false},
+		13: {re, "ticket-" + strings.Repeat("4", 300) + "@support.example.com", false},
+	}
+
+	for index, td := range testData {
+		if got := td.match(td.address); got != td.expected {
+			t.Errorf("[Test Case %v] Wrong match of %s. Expected: %v, Got: %v", index, td.address, td.expected, got)
+		}
+	}
+
+	if _, err := MatchRegexp(strings.Repeat("a", maxRoutePattern+1)); err == nil {
+		t.Errorf("Expected an overlong pattern to fail")
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	router.Add("tickets", MatchWildcard("ticket-*@in.example.com"))
+	router.Add("support", MatchExact("support@in.example.com"))
+	router.Add("catch-all", MatchDomain("in.example.com"))
+
+	var testData = map[int]struct {
+		header  string
+		name    string
+		address string
+	}{
+		1: {"To: support+eu@in.example.com\r\n", "support", "support+eu@in.example.com"},
+		2: {"To: support@in.example.com\r\nCc: ticket-7@in.example.com\r\n", "tickets", "ticket-7@in.example.com"},
+		3: {"To: list@lists.example.org\r\nDelivered-To: sales@in.example.com\r\n", "catch-all", "sales@in.example.com"},
+		4: {"To: mary@example.org\r\n", "", ""},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader("From: jdoe@example.com\r\n" + td.header + "Subject: Hi\r\n\r\nHi.\r\n"))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		name, address, ok := router.Route(&e)
+		if name != td.name || address != td.address || ok != (td.name != "") {
+			t.Errorf("[Test Case %v] Wrong route. Expected: %s %s, Got: %s %s", index, td.name, td.address, name, address)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package parsemail
+
+import "fmt"
+
+// SchemaVersion is the version of the parse output of this package: the
+// fields of Email and of its JSON exports and the semantics that fill them.
+// It is raised with every change that makes a message parse to a different
+// result than before, so an archive storing results can tell which
+// semantics produced them.
+//
+// Versions:
+//
+//	1: the first versioned output
+//	2: parts of types the parser doesn't process, nested in multipart
+//	   bodies, become attachments instead of failing the parse
+//	3: malformed parts of multipart bodies are skipped with a part-failed
+//	   warning instead of failing the parse
+//	4: the whitespace between a plain word and a following encoded-word is
+//	   kept when decoding the subject and the other header fields, and
+//	   encoded-words in any charset the bodies support are decoded
+//	5: a multipart/report body is parsed like a multipart/mixed one, into
+//	   the text body and attachments instead of Content
+//	6: uuencoded blocks in the text body of a message without MIME become
+//	   attachments, and parts in the binary and uuencode transfer encodings
+//	   are decoded instead of failing with an unknown encoding. RFC 2231
+//	   parameters like filenames are decoded in any charset, and attachments
+//	   without a filename take the name parameter of their Content-Type.
+//	   Multipart bodies nested deeper than 64 levels fail unless MaxDepth
+//	   is set. The first part of a multipart/related body whose start
+//	   parameter matches no part is its root.
+//	7: a message whose body is a single text/calendar part is parsed into
+//	   Calendars instead of Content
+const SchemaVersion = 7
+
+// schemaVersion returns the schema version parses with the options follow
+func (opts Options) schemaVersion() (int, error) {
+	switch v := opts.SchemaVersion; {
+	case v == 0:
+		return SchemaVersion, nil
+	case v < 0 || v > SchemaVersion:
+		return 0, fmt.Errorf("unsupported schema version %d", v)
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,102 @@
+package parsemail
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSchemaVersion(t *testing.T) {
+	const raw = "From: a@example.com\r\nSubject: Hi\r\n\r\nHello\r\n"
+
+	var testData = map[int]struct {
+		version  int
+		expected int
+		fails    bool
+	}{
+		1: {version: 0, expected: SchemaVersion},
+		2: {version: 1, expected: 1},
+		3: {version: SchemaVersion + 1, fails: true},
+		4: {version: -1, fails: true},
+	}
+
+	for index, td := range testData {
+		err := NewParser(Options{SchemaVersion: td.version}).ParseStream(strings.NewReader(raw), &recordingHandler{})
+		if td.fails != (err != nil) {
+			t.Errorf("[Test Case %v] Wrong streaming error: %v", index, err)
+		}
+
+		e, err := ParseWithOptions(strings.NewReader(raw), Options{SchemaVersion: td.version})
+		if td.fails {
+			if err == nil {
+				t.Errorf("[Test Case %v] Expected an error", index)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if e.SchemaVersion != td.expected {
+			t.Errorf("[Test Case %v] Wrong schema version. Expected: %d, Got: %d", index, td.expected, e.SchemaVersion)
+		}
+	}
+}
+
+func TestSchemaVersionEncodedWordSpace(t *testing.T) {
+	const raw = "From: a@example.com\r\nSubject: Hello =?utf-8?q?W=C3=B6rld?= =?utf-8?q?_again?=\r\n" +
+		"X-Note: Hello =?utf-8?q?W=C3=B6rld?=\r\n\r\nHello\r\n"
+
+	var testData = map[int]struct {
+		version  int
+		expected string
+	}{
+		1: {version: 0, expected: "Hello Wörld again"},
+		2: {version: 4, expected: "Hello Wörld again"},
+		3: {version: 3, expected: "HelloWörld again"},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(raw), Options{SchemaVersion: td.version})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if e.Subject != td.expected {
+			t.Errorf("[Test Case %v] Wrong subject. Expected: %q, Got: %q", index, td.expected, e.Subject)
+		}
+		if expected := strings.TrimSuffix(td.expected, " again"); e.Header.Get("X-Note") != expected {
+			t.Errorf("[Test Case %v] Wrong header field. Expected: %q, Got: %q", index, expected, e.Header.Get("X-Note"))
+		}
+	}
+}
+
+func TestSchemaVersionCalendar(t *testing.T) {
+	const calendar = "BEGIN:VCALENDAR\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:1\r\nSUMMARY:Standup\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	const raw = "From: a@example.com\r\nContent-Type: text/calendar; method=REQUEST\r\n\r\n" + calendar
+
+	var testData = map[int]struct {
+		version   int
+		content   string
+		calendars int
+	}{
+		1: {version: 0, calendars: 1},
+		2: {version: 7, calendars: 1},
+		3: {version: 6, content: calendar},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(raw), Options{SchemaVersion: td.version})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		var content []byte
+		if e.Content != nil {
+			content, _ = ioutil.ReadAll(e.Content)
+		}
+		if string(content) != td.content {
+			t.Errorf("[Test Case %v] Wrong content. Expected: %q, Got: %q", index, td.content, content)
+		}
+		if len(e.Calendars) != td.calendars {
+			t.Errorf("[Test Case %v] Wrong number of calendars. Expected: %d, Got: %d", index, td.calendars, len(e.Calendars))
+		}
+	}
+}
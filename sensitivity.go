@@ -0,0 +1,46 @@
+package parsemail
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// Sensitivity is the sensitivity an email was marked with by its sender
+type Sensitivity string
+
+const (
+	SensitivityNone                Sensitivity = ""
+	SensitivityPersonal            Sensitivity = "personal"
+	SensitivityPrivate             Sensitivity = "private"
+	SensitivityCompanyConfidential Sensitivity = "company-confidential"
+)
+
+// sensitivityHeaders are checked in order, the first known value wins
+var sensitivityHeaders = []string{
+	"Sensitivity",
+	"X-Sensitivity",
+	"X-Confidentiality",
+	"X-Confidential",
+}
+
+// parseSensitivity maps the Sensitivity header (RFC 2156) and the common
+// X-Sensitivity and X-Confidentiality markers to a Sensitivity.
+func parseSensitivity(header mail.Header) Sensitivity {
+	for _, name := range sensitivityHeaders {
+		value := strings.ToLower(strings.TrimSpace(header.Get(name)))
+		switch {
+		case value == "":
+			continue
+		case value == "personal":
+			return SensitivityPersonal
+		case value == "private":
+			return SensitivityPrivate
+		case strings.Contains(value, "confidential"):
+			return SensitivityCompanyConfidential
+		case name == "X-Confidential" && (value == "yes" || value == "true"):
+			return SensitivityCompanyConfidential
+		}
+	}
+
+	return SensitivityNone
+}
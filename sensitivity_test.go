@@ -0,0 +1,48 @@
+package parsemail
+
+import (
+	"net/mail"
+	"testing"
+)
+
+func TestParseSensitivity(t *testing.T) {
+	var testData = map[int]struct {
+		header      mail.Header
+		sensitivity Sensitivity
+	}{
+		1: {
+			header:      mail.Header{},
+			sensitivity: SensitivityNone,
+		},
+		2: {
+			header:      mail.Header{"Sensitivity": {"Personal"}},
+			sensitivity: SensitivityPersonal,
+		},
+		3: {
+			header:      mail.Header{"Sensitivity": {"private"}},
+			sensitivity: SensitivityPrivate,
+		},
+		4: {
+			header:      mail.Header{"Sensitivity": {"Company-Confidential"}},
+			sensitivity: SensitivityCompanyConfidential,
+		},
+		5: {
+			header:      mail.Header{"X-Confidentiality": {"Confidential"}},
+			sensitivity: SensitivityCompanyConfidential,
+		},
+		6: {
+			header:      mail.Header{"X-Confidential": {"yes"}},
+			sensitivity: SensitivityCompanyConfidential,
+		},
+		7: {
+			header:      mail.Header{"Sensitivity": {"Normal"}, "X-Sensitivity": {"Private"}},
+			sensitivity: SensitivityPrivate,
+		},
+	}
+
+	for index, td := range testData {
+		if s := parseSensitivity(td.header); s != td.sensitivity {
+			t.Errorf("[Test Case %v] Wrong sensitivity. Expected: %q, Got: %q", index, td.sensitivity, s)
+		}
+	}
+}
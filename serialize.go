@@ -0,0 +1,415 @@
+package parsemail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// serializedHeaders are written from the fields of the Email and never copied
+// from its Header
+var serializedHeaders = map[string]bool{
+	"From":         true,
+	"Sender":       true,
+	"Reply-To":     true,
+	"To":           true,
+	"Cc":           true,
+	"Bcc":          true,
+	"Subject":      true,
+	"Date":         true,
+	"Message-Id":   true,
+	"In-Reply-To":  true,
+	"References":   true,
+	"Keywords":     true,
+	"Comments":     true,
+	"Mime-Version": true,
+}
+
+// Serialize writes the email as an RFC 5322 message. The text and HTML
+// bodies, embedded files and attachments are assembled into the matching
+// multipart structure and the remaining headers of Header are copied. Bcc
+// recipients are not written. Parsing the result yields the same bodies,
+// files and address fields.
+func Serialize(e *Email) ([]byte, error) {
+	body, err := e.mimeBody()
+	if err != nil {
+		return nil, err
+	}
+
+	return serialize(e, body)
+}
+
+// serialize writes the header fields of the email followed by body
+func serialize(e *Email, body mimePart) ([]byte, error) {
+	var buf bytes.Buffer
+
+	hw := headerWriter{w: &buf}
+	hw.addresses("From", e.From)
+	if e.Sender != nil {
+		hw.addresses("Sender", []*mail.Address{e.Sender})
+	}
+	hw.addresses("Reply-To", e.ReplyTo)
+	hw.addresses("To", e.To)
+	hw.addresses("Cc", e.Cc)
+	hw.text("Subject", e.Subject)
+	if !e.Date.IsZero() {
+		hw.raw("Date", e.Date.Format(time.RFC1123Z))
+	}
+	hw.ids("Message-Id", []string{e.MessageID})
+	hw.ids("In-Reply-To", e.InReplyTo)
+	hw.ids("References", e.References)
+	if len(e.Keywords) > 0 {
+		hw.text("Keywords", strings.Join(e.Keywords, ", "))
+	}
+	for _, c := range e.Comments {
+		hw.text("Comments", c)
+	}
+
+	keys := make([]string, 0, len(e.Header))
+	for k := range e.Header {
+		k = textproto.CanonicalMIMEHeaderKey(k)
+		if !serializedHeaders[k] && !strings.HasPrefix(k, "Content-") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range e.Header[k] {
+			hw.text(k, v)
+		}
+	}
+
+	hw.raw("MIME-Version", "1.0")
+	for _, k := range sortedKeys(body.header) {
+		hw.raw(k, body.header.Get(k))
+	}
+	if hw.err != nil {
+		return nil, hw.err
+	}
+
+	buf.WriteString("\r\n")
+	if err := body.write(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// assembleMessage writes a message of the header fields and the MIME
+// structure of part. The Content fields and MIME-Version of fields are
+// replaced by those of part.
+func assembleMessage(fields []HeaderField, part mimePart) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, f := range fields {
+		if f.Name == "" || f.Name == "Mime-Version" || strings.HasPrefix(f.Name, "Content-") {
+			continue
+		}
+		buf.Write(f.Raw)
+		if !bytes.HasSuffix(f.Raw, []byte("\n")) {
+			buf.WriteString("\r\n")
+		}
+	}
+
+	hw := headerWriter{w: &buf}
+	hw.raw("MIME-Version", "1.0")
+	for _, k := range sortedKeys(part.header) {
+		hw.raw(k, part.header.Get(k))
+	}
+	if hw.err != nil {
+		return nil, hw.err
+	}
+
+	buf.WriteString("\r\n")
+	if err := part.write(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mimePart is a part of the serialized message with its header and a
+// function writing its content. content is the decoded content of a leaf
+// part, parts the parts of a multipart.
+type mimePart struct {
+	header  textproto.MIMEHeader
+	write   func(w io.Writer) error
+	content []byte
+	parts   []mimePart
+}
+
+// mimeBody assembles the multipart structure of the email
+func (e *Email) mimeBody() (mimePart, error) {
+	var bodies []mimePart
+	if e.TextBody != "" || e.HTMLBody == "" && e.Content == nil {
+		bodies = append(bodies, textPart(contentTypeTextPlain, e.TextBody))
+	}
+
+	if e.HTMLBody != "" {
+		bodies = append(bodies, textPart(contentTypeTextHtml, e.HTMLBody))
+	}
+
+	if len(bodies) == 0 {
+		data, err := rewindData(&e.Content)
+		if err != nil {
+			return mimePart{}, err
+		}
+		ct := e.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		bodies = append(bodies, filePart(textproto.MIMEHeader{"Content-Type": {ct}}, data))
+	}
+
+	if len(e.EmbeddedFiles) > 0 {
+		related := []mimePart{bodies[len(bodies)-1]}
+		for i := range e.EmbeddedFiles {
+			p, err := embeddedPart(&e.EmbeddedFiles[i])
+			if err != nil {
+				return mimePart{}, err
+			}
+			related = append(related, p)
+		}
+		bodies[len(bodies)-1] = multipartPart("related", related)
+	}
+
+	body := bodies[0]
+	if len(bodies) > 1 {
+		body = multipartPart("alternative", bodies)
+	}
+
+	if len(e.Attachments) == 0 {
+		return body, nil
+	}
+
+	mixed := []mimePart{body}
+	for i := range e.Attachments {
+		p, err := attachmentPart(&e.Attachments[i])
+		if err != nil {
+			return mimePart{}, err
+		}
+		mixed = append(mixed, p)
+	}
+
+	return multipartPart("mixed", mixed), nil
+}
+
+// textPart returns a UTF-8 text part, quoted-printable encoded so that the
+// parser returns text unchanged
+func textPart(contentType, text string) mimePart {
+	return mimePart{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"})},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		write: func(w io.Writer) error {
+			_, err := w.Write(encodeQuotedPrintable(text))
+			return err
+		},
+		content: []byte(text),
+	}
+}
+
+// filePart returns a base64 encoded part with the given header
+func filePart(header textproto.MIMEHeader, data []byte) mimePart {
+	header.Set("Content-Transfer-Encoding", "base64")
+
+	return mimePart{
+		header: header,
+		write: func(w io.Writer) error {
+			return writeBase64Lines(w, data)
+		},
+		content: data,
+	}
+}
+
+func attachmentPart(a *Attachment) (mimePart, error) {
+	data, err := a.bytes()
+	if err != nil {
+		return mimePart{}, err
+	}
+
+	ct := a.RawContentType
+	if ct == "" {
+		ct = fileContentType(a.ContentType, a.Params, a.Filename)
+	}
+
+	header := textproto.MIMEHeader{
+		"Content-Type":        {ct},
+		"Content-Disposition": {fileDisposition("attachment", a.Filename)},
+	}
+	if len(a.ContentLanguage) > 0 {
+		header.Set("Content-Language", strings.Join(a.ContentLanguage, ", "))
+	}
+
+	// message/rfc822 parts can't be base64 encoded
+	if a.ContentType == messageRFC822 {
+		header.Set("Content-Transfer-Encoding", "8bit")
+
+		return mimePart{header: header, content: data, write: func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		}}, nil
+	}
+
+	return filePart(header, data), nil
+}
+
+func embeddedPart(ef *EmbeddedFile) (mimePart, error) {
+	data, err := ef.bytes()
+	if err != nil {
+		return mimePart{}, err
+	}
+
+	ct := ef.RawContentType
+	if ct == "" {
+		ct = fileContentType(ef.ContentType, ef.Params, ef.Filename)
+	}
+
+	header := textproto.MIMEHeader{
+		"Content-Type":        {ct},
+		"Content-Disposition": {fileDisposition("inline", ef.Filename)},
+	}
+	if ef.CID != "" {
+		header.Set("Content-Id", "<"+ef.CID+">")
+	}
+	if ef.ContentLocation != "" {
+		header.Set("Content-Location", ef.ContentLocation)
+	}
+
+	return filePart(header, data), nil
+}
+
+// fileContentType formats a Content-Type header naming the file
+func fileContentType(contentType string, params map[string]string, filename string) string {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	p := make(map[string]string, len(params)+1)
+	for k, v := range params {
+		p[k] = v
+	}
+	if filename != "" {
+		p["name"] = filename
+	}
+
+	return mime.FormatMediaType(contentType, p)
+}
+
+func fileDisposition(disposition, filename string) string {
+	if filename == "" {
+		return disposition
+	}
+
+	return mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+}
+
+// multipartPart returns a multipart/subtype part of parts
+func multipartPart(subtype string, parts []mimePart) mimePart {
+	boundary := multipart.NewWriter(ioutil.Discard).Boundary()
+
+	return mimePart{
+		header: textproto.MIMEHeader{
+			"Content-Type": {mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": boundary})},
+		},
+		write: func(w io.Writer) error {
+			mw := multipart.NewWriter(w)
+			if err := mw.SetBoundary(boundary); err != nil {
+				return err
+			}
+
+			for _, p := range parts {
+				pw, err := mw.CreatePart(p.header)
+				if err != nil {
+					return err
+				}
+				if err := p.write(pw); err != nil {
+					return err
+				}
+			}
+
+			return mw.Close()
+		},
+		parts: parts,
+	}
+}
+
+// writeBase64Lines writes data base64 encoded in lines of 76 characters
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+
+	_, err := io.WriteString(w, encoded+"\r\n")
+
+	return err
+}
+
+// headerWriter writes header fields, keeping the first error
+type headerWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (hw *headerWriter) raw(name, value string) {
+	if hw.err != nil {
+		return
+	}
+
+	_, hw.err = io.WriteString(hw.w, FoldHeader(name, value))
+}
+
+// text writes an unstructured field, encoding non-ASCII text
+func (hw *headerWriter) text(name, value string) {
+	if value != "" {
+		hw.raw(name, EncodeHeaderText(value))
+	}
+}
+
+func (hw *headerWriter) addresses(name string, list []*mail.Address) {
+	var s []string
+	for _, a := range list {
+		if a != nil {
+			s = append(s, formatAddress(a))
+		}
+	}
+
+	if len(s) > 0 {
+		hw.raw(name, strings.Join(s, ", "))
+	}
+}
+
+func (hw *headerWriter) ids(name string, ids []string) {
+	var s []string
+	for _, id := range ids {
+		if id != "" {
+			s = append(s, "<"+id+">")
+		}
+	}
+
+	if len(s) > 0 {
+		hw.raw(name, strings.Join(s, " "))
+	}
+}
+
+func sortedKeys(h textproto.MIMEHeader) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
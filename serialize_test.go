@@ -0,0 +1,94 @@
+package parsemail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+	}{
+		1: {mailData: latin1Attachment},
+		2: {mailData: serializeRelated},
+		3: {mailData: journalReport},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		raw, err := Serialize(&e)
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		r, err := Parse(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v\n%s", index, err, raw)
+		}
+
+		if r.Subject != e.Subject {
+			t.Errorf("[Test Case %v] Wrong subject. Expected: %s, Got: %s", index, e.Subject, r.Subject)
+		}
+		if r.TextBody != e.TextBody {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, e.TextBody, r.TextBody)
+		}
+		if r.HTMLBody != e.HTMLBody {
+			t.Errorf("[Test Case %v] Wrong html body. Expected: %q, Got: %q", index, e.HTMLBody, r.HTMLBody)
+		}
+		if !assertAddressListEq(dereferenceAddressList(r.From), dereferenceAddressList(e.From)) {
+			t.Errorf("[Test Case %v] Wrong from. Expected: %v, Got: %v", index, e.From, r.From)
+		}
+		if len(r.Attachments) != len(e.Attachments) || len(r.EmbeddedFiles) != len(e.EmbeddedFiles) {
+			t.Fatalf("[Test Case %v] Wrong number of files. Expected: %d/%d, Got: %d/%d", index, len(e.Attachments), len(e.EmbeddedFiles), len(r.Attachments), len(r.EmbeddedFiles))
+		}
+
+		for i := range e.Attachments {
+			expected, _ := e.Attachments[i].bytes()
+			got, _ := ioutil.ReadAll(r.Attachments[i].Data)
+			if r.Attachments[i].Filename != e.Attachments[i].Filename || !bytes.Equal(got, expected) {
+				t.Errorf("[Test Case %v] Wrong attachment %d: %s %q", index, i, r.Attachments[i].Filename, got)
+			}
+		}
+
+		for i := range e.EmbeddedFiles {
+			expected, _ := e.EmbeddedFiles[i].bytes()
+			got, _ := ioutil.ReadAll(r.EmbeddedFiles[i].Data)
+			if r.EmbeddedFiles[i].CID != e.EmbeddedFiles[i].CID || !bytes.Equal(got, expected) {
+				t.Errorf("[Test Case %v] Wrong embedded file %d: %s %q", index, i, r.EmbeddedFiles[i].CID, got)
+			}
+		}
+	}
+}
+
+var serializeRelated = `From: =?utf-8?q?J=C3=BCrgen?= <juergen@example.com>
+To: mary@example.com
+Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Content-Type: multipart/alternative; boundary="ALT"
+
+--ALT
+Content-Type: text/plain; charset=utf-8
+
+Grüße
+--ALT
+Content-Type: multipart/related; boundary="REL"
+
+--REL
+Content-Type: text/html; charset=utf-8
+
+<p>Grüße <img src="cid:logo@example.com"></p>
+--REL
+Content-Type: image/png
+Content-Id: <logo@example.com>
+Content-Transfer-Encoding: base64
+
+iVBORw0KGgo=
+--REL--
+--ALT--
+`
@@ -0,0 +1,812 @@
+package parsemail
+
+import (
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SieveAction is an action a Sieve script decided on, to be carried out by
+// the caller
+type SieveAction struct {
+	// Action is "keep", "fileinto", "redirect" or "discard"
+	Action string
+	// Argument is the mailbox of fileinto or the address of redirect
+	Argument string
+}
+
+// SieveScript is a compiled Sieve script (RFC 5228). It supports the
+// fileinto extension and the body extension (RFC 5173) except for its
+// :content transform. The envelope is unknown to a parsed email, so the
+// envelope test isn't supported. The body test with the :raw transform needs
+// the raw message, so it matches an empty body unless the email was parsed
+// with Options.KeepRaw.
+type SieveScript struct {
+	commands []sieveCommand
+}
+
+// sieveCapabilities are the extensions a script may require
+var sieveCapabilities = map[string]bool{
+	"fileinto":                   true,
+	"body":                       true,
+	"comparator-i;octet":         true,
+	"comparator-i;ascii-casemap": true,
+}
+
+// ParseSieve compiles a Sieve script. Syntax errors, unknown commands and
+// tests, malformed arguments and extensions used without being required
+// are reported with their line.
+func ParseSieve(script string) (*SieveScript, error) {
+	tokens, err := lexSieve(script)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &sieveParser{tokens: tokens, required: map[string]bool{}}
+	commands, err := sp.commands(true)
+	if err != nil {
+		return nil, err
+	}
+	if t := sp.peek(); t.kind != sieveEOF {
+		return nil, t.errorf("unexpected %s", t)
+	}
+
+	return &SieveScript{commands: commands}, nil
+}
+
+// Evaluate runs the script on the email and returns the actions it decided
+// on in order, without duplicates. The implicit keep is reported as a keep
+// action unless a fileinto, redirect or discard action cancelled it, and a
+// discard is only reported when no other action remains.
+func (s *SieveScript) Evaluate(e *Email) []SieveAction {
+	st := &sieveState{email: e, implicitKeep: true}
+	st.run(s.commands)
+
+	if st.implicitKeep {
+		st.add(SieveAction{Action: "keep"})
+	}
+	if len(st.actions) == 0 && st.discarded {
+		st.add(SieveAction{Action: "discard"})
+	}
+
+	return st.actions
+}
+
+type sieveTokenKind int
+
+const (
+	sieveEOF sieveTokenKind = iota
+	sieveIdentifier
+	sieveTag
+	sieveNumber
+	sieveString
+	sievePunct
+)
+
+type sieveToken struct {
+	kind   sieveTokenKind
+	text   string
+	number int64
+	line   int
+}
+
+func (t sieveToken) String() string {
+	switch t.kind {
+	case sieveEOF:
+		return "end of script"
+	case sieveString:
+		return strconv.Quote(t.text)
+	case sieveTag:
+		return ":" + t.text
+	}
+
+	return t.text
+}
+
+func (t sieveToken) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("sieve: line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+// lexSieve splits a script into tokens, dropping white space and comments
+func lexSieve(s string) (tokens []sieveToken, err error) {
+	line := 1
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("sieve: line %d: unterminated comment", line)
+			}
+			line += strings.Count(s[i:i+2+end], "\n")
+			i += end + 4
+		case strings.ContainsRune("[](),;{}", rune(c)):
+			tokens = append(tokens, sieveToken{kind: sievePunct, text: string(c), line: line})
+			i++
+		case c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				sb.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("sieve: line %d: unterminated string", line)
+			}
+			tokens = append(tokens, sieveToken{kind: sieveString, text: sb.String(), line: line})
+			line += strings.Count(s[i:j], "\n")
+			i = j + 1
+		case c == ':':
+			j := i + 1
+			for j < len(s) && isSieveIdentifierChar(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("sieve: line %d: empty tag", line)
+			}
+			tokens = append(tokens, sieveToken{kind: sieveTag, text: strings.ToLower(s[i+1 : j]), line: line})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			n, err := strconv.ParseInt(s[i:j], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("sieve: line %d: malformed number %s", line, s[i:j])
+			}
+			if j < len(s) {
+				switch s[j] {
+				case 'K', 'k':
+					n, j = n<<10, j+1
+				case 'M', 'm':
+					n, j = n<<20, j+1
+				case 'G', 'g':
+					n, j = n<<30, j+1
+				}
+			}
+			tokens = append(tokens, sieveToken{kind: sieveNumber, number: n, text: s[i:j], line: line})
+			i = j
+		case isSieveIdentifierChar(c):
+			j := i
+			for j < len(s) && isSieveIdentifierChar(s[j]) {
+				j++
+			}
+			word := strings.ToLower(s[i:j])
+
+			// multi-line strings end with a line holding a single dot
+			if word == "text" && j < len(s) && s[j] == ':' {
+				text, n, lines, err := lexSieveText(s[j+1:])
+				if err != nil {
+					return nil, fmt.Errorf("sieve: line %d: %v", line, err)
+				}
+				tokens = append(tokens, sieveToken{kind: sieveString, text: text, line: line})
+				line += lines
+				i = j + 1 + n
+				continue
+			}
+
+			tokens = append(tokens, sieveToken{kind: sieveIdentifier, text: word, line: line})
+			i = j
+		default:
+			return nil, fmt.Errorf("sieve: line %d: unexpected character %q", line, c)
+		}
+	}
+
+	return append(tokens, sieveToken{kind: sieveEOF, line: line}), nil
+}
+
+// lexSieveText reads a multi-line string following "text:", returning its
+// content, the bytes consumed and the line breaks crossed
+func lexSieveText(s string) (text string, n, lines int, err error) {
+	// the rest of the "text:" line may only hold white space or a comment
+	eol := strings.IndexByte(s, '\n')
+	if eol < 0 {
+		return "", 0, 0, fmt.Errorf("unterminated multi-line string")
+	}
+	if rest := strings.TrimSpace(s[:eol]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", 0, 0, fmt.Errorf("unexpected %q after text:", rest)
+	}
+	n, lines = eol+1, 1
+
+	var sb strings.Builder
+	for {
+		eol := strings.IndexByte(s[n:], '\n')
+		if eol < 0 {
+			return "", 0, 0, fmt.Errorf("unterminated multi-line string")
+		}
+		l := s[n : n+eol+1]
+		n += eol + 1
+		lines++
+
+		if strings.TrimRight(l, "\r\n") == "." {
+			return sb.String(), n, lines, nil
+		}
+		// dot-stuffing
+		if strings.HasPrefix(l, "..") {
+			l = l[1:]
+		}
+		sb.WriteString(l)
+	}
+}
+
+func isSieveIdentifierChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// sieveArgument is a tag, a number or a string list
+type sieveArgument struct {
+	token   sieveToken
+	strings []string
+}
+
+type sieveParser struct {
+	tokens   []sieveToken
+	pos      int
+	required map[string]bool
+	// ended is set once a command other than require was parsed
+	ended bool
+}
+
+func (sp *sieveParser) peek() sieveToken {
+	return sp.tokens[sp.pos]
+}
+
+func (sp *sieveParser) next() sieveToken {
+	t := sp.tokens[sp.pos]
+	if t.kind != sieveEOF {
+		sp.pos++
+	}
+	return t
+}
+
+func (sp *sieveParser) isPunct(text string) bool {
+	t := sp.peek()
+	return t.kind == sievePunct && t.text == text
+}
+
+func (sp *sieveParser) expect(text string) error {
+	if t := sp.next(); t.kind != sievePunct || t.text != text {
+		return t.errorf("expected %q, got %s", text, t)
+	}
+	return nil
+}
+
+// commands parses commands up to the end of the script or of a block
+func (sp *sieveParser) commands(top bool) (commands []sieveCommand, err error) {
+	for {
+		t := sp.peek()
+		if t.kind == sieveEOF || !top && t.kind == sievePunct && t.text == "}" {
+			return
+		}
+
+		c, err := sp.command()
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			commands = append(commands, *c)
+		}
+	}
+}
+
+// arguments parses the arguments of a command or test
+func (sp *sieveParser) arguments() (args []sieveArgument, err error) {
+	for {
+		t := sp.peek()
+		switch {
+		case t.kind == sieveTag || t.kind == sieveNumber:
+			args = append(args, sieveArgument{token: sp.next()})
+		case t.kind == sieveString:
+			sp.next()
+			args = append(args, sieveArgument{token: t, strings: []string{t.text}})
+		case t.kind == sievePunct && t.text == "[":
+			sp.next()
+			arg := sieveArgument{token: t}
+			for {
+				s := sp.next()
+				if s.kind != sieveString {
+					return nil, s.errorf("expected a string, got %s", s)
+				}
+				arg.strings = append(arg.strings, s.text)
+				if sp.isPunct("]") {
+					sp.next()
+					break
+				}
+				if err := sp.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			args = append(args, arg)
+		default:
+			return
+		}
+	}
+}
+
+func (sp *sieveParser) command() (*sieveCommand, error) {
+	t := sp.next()
+	if t.kind != sieveIdentifier {
+		return nil, t.errorf("expected a command, got %s", t)
+	}
+
+	if t.text == "require" {
+		if sp.ended {
+			return nil, t.errorf("require must precede other commands")
+		}
+		args, err := sp.arguments()
+		if err != nil {
+			return nil, err
+		}
+		if len(args) != 1 || args[0].strings == nil {
+			return nil, t.errorf("require expects a string list")
+		}
+		for _, c := range args[0].strings {
+			if !sieveCapabilities[strings.ToLower(c)] {
+				return nil, t.errorf("unsupported extension %q", c)
+			}
+			sp.required[strings.ToLower(c)] = true
+		}
+		return nil, sp.expect(";")
+	}
+	sp.ended = true
+
+	switch t.text {
+	case "if":
+		c := &sieveCommand{kind: t.text}
+		for {
+			var b sieveBranch
+			if t.text != "else" {
+				test, err := sp.test()
+				if err != nil {
+					return nil, err
+				}
+				b.test = test
+			}
+
+			if err := sp.expect("{"); err != nil {
+				return nil, err
+			}
+			block, err := sp.commands(false)
+			if err != nil {
+				return nil, err
+			}
+			if err := sp.expect("}"); err != nil {
+				return nil, err
+			}
+			b.block = block
+			c.branches = append(c.branches, b)
+
+			next := sp.peek()
+			if t.text == "else" || next.kind != sieveIdentifier || next.text != "elsif" && next.text != "else" {
+				return c, nil
+			}
+			t = sp.next()
+		}
+
+	case "elsif", "else":
+		return nil, t.errorf("%s without if", t.text)
+
+	case "keep", "discard", "stop", "fileinto", "redirect":
+		args, err := sp.arguments()
+		if err != nil {
+			return nil, err
+		}
+
+		c := &sieveCommand{kind: t.text}
+		switch t.text {
+		case "fileinto", "redirect":
+			if t.text == "fileinto" && !sp.required["fileinto"] {
+				return nil, t.errorf("fileinto requires the fileinto extension")
+			}
+			if len(args) != 1 || len(args[0].strings) != 1 {
+				return nil, t.errorf("%s expects a string", t.text)
+			}
+			c.argument = args[0].strings[0]
+		default:
+			if len(args) != 0 {
+				return nil, t.errorf("%s takes no arguments", t.text)
+			}
+		}
+
+		return c, sp.expect(";")
+	}
+
+	return nil, t.errorf("unknown command %s", t.text)
+}
+
+// test parses and compiles a test
+func (sp *sieveParser) test() (sieveTest, error) {
+	t := sp.next()
+	if t.kind != sieveIdentifier {
+		return nil, t.errorf("expected a test, got %s", t)
+	}
+
+	switch t.text {
+	case "true", "false":
+		value := t.text == "true"
+		return func(*sieveState) bool { return value }, nil
+
+	case "not":
+		test, err := sp.test()
+		if err != nil {
+			return nil, err
+		}
+		return func(st *sieveState) bool { return !test(st) }, nil
+
+	case "allof", "anyof":
+		if err := sp.expect("("); err != nil {
+			return nil, err
+		}
+		var tests []sieveTest
+		for {
+			test, err := sp.test()
+			if err != nil {
+				return nil, err
+			}
+			tests = append(tests, test)
+			if sp.isPunct(")") {
+				sp.next()
+				break
+			}
+			if err := sp.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		all := t.text == "allof"
+		return func(st *sieveState) bool {
+			for _, test := range tests {
+				if test(st) != all {
+					return !all
+				}
+			}
+			return all
+		}, nil
+	}
+
+	args, err := sp.arguments()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.text {
+	case "exists":
+		if len(args) != 1 || args[0].strings == nil {
+			return nil, t.errorf("exists expects a string list")
+		}
+		names := args[0].strings
+		return func(st *sieveState) bool {
+			for _, n := range names {
+				if len(st.email.Header[canonicalSieveHeader(n)]) == 0 {
+					return false
+				}
+			}
+			return true
+		}, nil
+
+	case "size":
+		if len(args) != 2 || args[0].token.kind != sieveTag || args[1].token.kind != sieveNumber ||
+			args[0].token.text != "over" && args[0].token.text != "under" {
+			return nil, t.errorf("size expects :over or :under and a number")
+		}
+		over, limit := args[0].token.text == "over", args[1].token.number
+		return func(st *sieveState) bool {
+			size := st.email.size
+			if over {
+				return size > limit
+			}
+			return size < limit
+		}, nil
+
+	case "header", "address", "body":
+		if t.text == "body" && !sp.required["body"] {
+			return nil, t.errorf("body requires the body extension")
+		}
+		return sp.matchTest(t, args)
+
+	case "envelope":
+		return nil, t.errorf("the envelope of a parsed email is unknown")
+	}
+
+	return nil, t.errorf("unknown test %s", t.text)
+}
+
+// matchTest compiles the header, address and body tests, which share the
+// comparator and match type arguments
+func (sp *sieveParser) matchTest(t sieveToken, args []sieveArgument) (sieveTest, error) {
+	matchType, comparator, part, transform := "is", "i;ascii-casemap", "all", "text"
+
+	var lists [][]string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a.token.kind == sieveNumber {
+			return nil, a.token.errorf("unexpected number")
+		}
+		if a.token.kind != sieveTag {
+			lists = append(lists, a.strings)
+			continue
+		}
+
+		switch tag := a.token.text; {
+		case tag == "is" || tag == "contains" || tag == "matches":
+			matchType = tag
+		case tag == "comparator":
+			if i+1 >= len(args) || len(args[i+1].strings) != 1 {
+				return nil, a.token.errorf(":comparator expects a string")
+			}
+			i++
+			comparator = strings.ToLower(args[i].strings[0])
+			if comparator != "i;ascii-casemap" && comparator != "i;octet" {
+				return nil, a.token.errorf("unsupported comparator %q", comparator)
+			}
+		case t.text == "address" && (tag == "all" || tag == "localpart" || tag == "domain"):
+			part = tag
+		case t.text == "body" && (tag == "raw" || tag == "text"):
+			transform = tag
+		case t.text == "body" && tag == "content":
+			return nil, a.token.errorf("the :content body transform is not supported")
+		default:
+			return nil, a.token.errorf("unexpected tag :%s for %s", tag, t.text)
+		}
+	}
+
+	want := 2
+	if t.text == "body" {
+		want = 1
+	}
+	if len(lists) != want {
+		return nil, t.errorf("%s expects %d string lists", t.text, want)
+	}
+
+	match, err := sieveMatcher(matchType, comparator, lists[want-1])
+	if err != nil {
+		return nil, t.errorf("%v", err)
+	}
+
+	switch t.text {
+	case "header":
+		names := lists[0]
+		return func(st *sieveState) bool {
+			for _, n := range names {
+				for _, v := range st.email.Header[canonicalSieveHeader(n)] {
+					if match(v) {
+						return true
+					}
+				}
+			}
+			return false
+		}, nil
+
+	case "address":
+		names := lists[0]
+		return func(st *sieveState) bool {
+			for _, n := range names {
+				for _, v := range st.email.Header[canonicalSieveHeader(n)] {
+					for _, a := range sieveAddresses(v) {
+						if match(sieveAddressPart(a, part)) {
+							return true
+						}
+					}
+				}
+			}
+			return false
+		}, nil
+	}
+
+	return func(st *sieveState) bool {
+		return match(st.body(transform))
+	}, nil
+}
+
+// sieveMatcher returns a function matching a value against the keys
+func sieveMatcher(matchType, comparator string, keys []string) (func(string) bool, error) {
+	fold := comparator == "i;ascii-casemap"
+	if fold {
+		for i := range keys {
+			keys[i] = asciiLower(keys[i])
+		}
+	}
+
+	switch matchType {
+	case "is", "contains":
+		contains := matchType == "contains"
+		return func(v string) bool {
+			if fold {
+				v = asciiLower(v)
+			}
+			for _, k := range keys {
+				if v == k || contains && strings.Contains(v, k) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	var patterns []*regexp.Regexp
+	for _, k := range keys {
+		re, err := regexp.Compile(sieveWildcardExpr(k))
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, re)
+	}
+
+	return func(v string) bool {
+		if fold {
+			v = asciiLower(v)
+		}
+		for _, re := range patterns {
+			if re.MatchString(v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// sieveWildcardExpr translates a :matches key, where "*" matches any run
+// of characters, "?" a single one and "\" escapes, to a regular expression
+func sieveWildcardExpr(key string) string {
+	var sb strings.Builder
+	sb.WriteString(`(?s)^`)
+	for i := 0; i < len(key); {
+		switch key[i] {
+		case '*':
+			sb.WriteString(`.*`)
+			i++
+		case '?':
+			sb.WriteString(`.`)
+			i++
+		case '\\':
+			i++
+			fallthrough
+		default:
+			if i >= len(key) {
+				break
+			}
+			_, n := utf8.DecodeRuneInString(key[i:])
+			sb.WriteString(regexp.QuoteMeta(key[i : i+n]))
+			i += n
+		}
+	}
+	sb.WriteString(`$`)
+
+	return sb.String()
+}
+
+// asciiLower folds ASCII letters only, like the i;ascii-casemap comparator
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func canonicalSieveHeader(name string) string {
+	return textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+}
+
+// sieveAddresses returns the addresses of a header value, or the value
+// itself if it doesn't parse
+func sieveAddresses(v string) []string {
+	list, err := mail.ParseAddressList(v)
+	if err != nil {
+		return []string{strings.TrimSpace(v)}
+	}
+
+	addresses := make([]string, len(list))
+	for i, a := range list {
+		addresses[i] = a.Address
+	}
+	return addresses
+}
+
+func sieveAddressPart(address, part string) string {
+	at := strings.LastIndex(address, "@")
+	switch {
+	case part == "localpart" && at >= 0:
+		return address[:at]
+	case part == "domain" && at >= 0:
+		return address[at+1:]
+	case part == "domain":
+		return ""
+	}
+	return address
+}
+
+type sieveTest func(st *sieveState) bool
+
+type sieveBranch struct {
+	// test is nil for else
+	test  sieveTest
+	block []sieveCommand
+}
+
+type sieveCommand struct {
+	kind     string
+	argument string
+	branches []sieveBranch
+}
+
+// sieveState is the state of an evaluation
+type sieveState struct {
+	email        *Email
+	actions      []SieveAction
+	implicitKeep bool
+	discarded    bool
+}
+
+func (st *sieveState) add(a SieveAction) {
+	for _, b := range st.actions {
+		if a == b {
+			return
+		}
+	}
+	st.actions = append(st.actions, a)
+}
+
+// run executes commands and reports whether stop was reached
+func (st *sieveState) run(commands []sieveCommand) (stopped bool) {
+	for _, c := range commands {
+		switch c.kind {
+		case "if":
+			for _, b := range c.branches {
+				if b.test == nil || b.test(st) {
+					if st.run(b.block) {
+						return true
+					}
+					break
+				}
+			}
+		case "stop":
+			return true
+		case "keep":
+			st.add(SieveAction{Action: "keep"})
+			st.implicitKeep = false
+		case "discard":
+			st.discarded = true
+			st.implicitKeep = false
+		case "fileinto", "redirect":
+			st.add(SieveAction{Action: c.kind, Argument: c.argument})
+			st.implicitKeep = false
+		}
+	}
+
+	return false
+}
+
+// body returns the body of the email for the body test: the raw body
+// after the header, empty if the raw message isn't kept, or the text of the
+// text parts
+func (st *sieveState) body(transform string) string {
+	if transform == "raw" {
+		raw, _ := st.email.rawMessage()
+		_, body := splitHeader(raw)
+		return string(body)
+	}
+
+	if st.email.TextBody != "" || st.email.HTMLBody == "" {
+		return st.email.TextBody
+	}
+	return htmlToText(st.email.HTMLBody)
+}
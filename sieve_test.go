@@ -0,0 +1,137 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSieve(t *testing.T) {
+	const raw = "From: \"Build Bot\" <ci@builds.example.com>\r\n" +
+		"To: dev@lists.example.org, Mary <mary@example.net>\r\n" +
+		"Subject: [CI] Build #42 failed\r\n" +
+		"List-Id: <dev.lists.example.org>\r\n" +
+		"X-Spam-Score: 7.5\r\n\r\n" +
+		"The build failed on the release branch.\r\n"
+
+	var testData = map[int]struct {
+		script   string
+		expected []SieveAction
+	}{
+		1: {
+			script:   `keep;`,
+			expected: []SieveAction{{Action: "keep"}},
+		},
+		2: {
+			script: `require "fileinto";
+if header :contains "subject" "[ci]" { fileinto "CI"; }`,
+			expected: []SieveAction{{Action: "fileinto", Argument: "CI"}},
+		},
+		3: {
+			script: `require ["fileinto"];
+# lists are filed by their id
+if exists "List-Id" {
+    if address :domain :is "from" "builds.example.com" {
+        fileinto "Builds";
+        stop;
+    }
+    fileinto "Lists";
+}
+fileinto "Never";`,
+			expected: []SieveAction{{Action: "fileinto", Argument: "Builds"}},
+		},
+		4: {
+			script:   `if header :matches "Subject" "*Build #?? failed" { redirect "oncall@example.com"; keep; }`,
+			expected: []SieveAction{{Action: "redirect", Argument: "oncall@example.com"}, {Action: "keep"}},
+		},
+		5: {
+			script:   `if header :comparator "i;octet" :contains "subject" "[ci]" { discard; }`,
+			expected: []SieveAction{{Action: "keep"}},
+		},
+		6: {
+			script:   `if anyof (size :over 10K, address :localpart "to" "mary") { discard; }`,
+			expected: []SieveAction{{Action: "discard"}},
+		},
+		7: {
+			script:   `if allof (size :under 1M, not exists "X-Spam-Flag") { redirect "archive@example.com"; }`,
+			expected: []SieveAction{{Action: "redirect", Argument: "archive@example.com"}},
+		},
+		8: {
+			script: `require ["body", "fileinto"];
+if body :text :contains "release branch" { fileinto "Release"; }
+elsif true { fileinto "Other"; }
+else { discard; }`,
+			expected: []SieveAction{{Action: "fileinto", Argument: "Release"}},
+		},
+		9: {
+			script: `require "fileinto";
+if header :is "x-spam-score" "7.5" { fileinto text:
+Spam
+..folder
+.
+; }`,
+			expected: []SieveAction{{Action: "fileinto", Argument: "Spam\r\n.folder\r\n"}},
+		},
+		10: {
+			script:   `if header :matches "from" "\"Build\\*" { discard; } /* "Build Bot" doesn't match a literal star */`,
+			expected: []SieveAction{{Action: "keep"}},
+		},
+		12: {
+			script:   `if header :matches "from" "\"Build*" { discard; }`,
+			expected: []SieveAction{{Action: "discard"}},
+		},
+		11: {
+			script:   `if address :all ["from", "sender"] "CI@BUILDS.EXAMPLE.COM" { discard; } else { keep; }`,
+			expected: []SieveAction{{Action: "discard"}},
+		},
+	}
+
+	e, err := Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index, td := range testData {
+		s, err := ParseSieve(strings.Replace(td.script, "\n", "\r\n", -1))
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		actions := s.Evaluate(&e)
+		if len(actions) != len(td.expected) {
+			t.Errorf("[Test Case %v] Wrong actions. Expected: %v, Got: %v", index, td.expected, actions)
+			continue
+		}
+		for i := range actions {
+			if actions[i] != td.expected[i] {
+				t.Errorf("[Test Case %v] Wrong actions. Expected: %v, Got: %v", index, td.expected, actions)
+				break
+			}
+		}
+	}
+}
+
+func TestParseSieveErrors(t *testing.T) {
+	var testData = map[int]string{
+		1:  `fileinto "INBOX";`,
+		2:  `require "vacation";`,
+		3:  `keep`,
+		4:  `if header "subject" { keep; }`,
+		5:  `if header :regex "subject" "x" { keep; }`,
+		6:  `keep; require "fileinto";`,
+		7:  `else { keep; }`,
+		8:  `if envelope "from" "a@example.com" { keep; }`,
+		9:  `if body :contains "x" { keep; }`,
+		10: `if size 100 { keep; }`,
+		11: `reject "no";`,
+		12: `if header :contains "subject" "x" { keep;`,
+		13: `if header :comparator "i;ascii-numeric" "subject" "1" { keep; }`,
+		14: `"unterminated`,
+	}
+
+	for index, script := range testData {
+		if _, err := ParseSieve(script); err == nil {
+			t.Errorf("[Test Case %v] Expected an error for %s", index, script)
+		}
+	}
+}
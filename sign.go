@@ -0,0 +1,25 @@
+package parsemail
+
+// Signer signs a serialized message, returning the signed message.
+// DKIMSigner and SMIMESigner implement it.
+type Signer interface {
+	Sign(message []byte) ([]byte, error)
+}
+
+// SerializeSigned serializes the email like Serialize and applies the
+// signers in order. A DKIMSigner should come last, as every later change to
+// the message invalidates its signature.
+func SerializeSigned(e *Email, signers ...Signer) ([]byte, error) {
+	raw, err := Serialize(e)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range signers {
+		if raw, err = s.Sign(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	return raw, nil
+}
@@ -0,0 +1,385 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// Signature is the detached signature of a multipart/signed message
+// (RFC 1847). Only S/MIME signatures can be verified.
+type Signature struct {
+	// Protocol is the content type of the signature part, e.g.
+	// application/pkcs7-signature or application/pgp-signature
+	Protocol string
+	// Micalg names the digest algorithm announced by the message
+	Micalg string
+	// Data is the decoded signature part
+	Data []byte
+	// Content is the signed first part including its header, with CRLF
+	// line breaks as it was signed
+	Content []byte
+}
+
+// parseSignature returns the signature of a multipart/signed message or nil
+// if it has none
+func (p *parser) parseSignature(raw []byte) *Signature {
+	_, mh, parts, err := splitParts(raw)
+	if err != nil || len(parts) != 2 {
+		return nil
+	}
+
+	_, params, _ := parseContentType(mh.Get("Content-Type"))
+
+	header, body := splitHeader(parts[1])
+	sh, err := parseRawHeader(header)
+	if err != nil {
+		return nil
+	}
+
+	data, err := p.decodeRawBody(sh, body)
+	if err != nil {
+		return nil
+	}
+
+	protocol := strings.ToLower(params["protocol"])
+	if protocol == "" {
+		protocol, _, _ = parseContentType(sh.Get("Content-Type"))
+		protocol = strings.ToLower(protocol)
+	}
+
+	return &Signature{
+		Protocol: protocol,
+		Micalg:   strings.ToLower(params["micalg"]),
+		Data:     data,
+		Content:  crlfLines(parts[0]),
+	}
+}
+
+// Verify checks the S/MIME signature over the signed content and the
+// certificate chain of the signer up to a root of certPool, or of the system
+// if certPool is nil. Intermediates are taken from the signature. The chain
+// is checked at the current time. The certificate of the first valid signer
+// is returned.
+func (s *Signature) Verify(certPool *x509.CertPool) (*x509.Certificate, error) {
+	return s.verify(certPool, false)
+}
+
+// VerifyAtSigningTime is Verify checking the chain at the signing time the
+// signature claims, or at the current time if it has none. The signing time
+// is chosen by the sender, so it only fits messages whose time of receipt
+// is trusted otherwise, like those of an archive.
+func (s *Signature) VerifyAtSigningTime(certPool *x509.CertPool) (*x509.Certificate, error) {
+	return s.verify(certPool, true)
+}
+
+func (s *Signature) verify(certPool *x509.CertPool, atSigningTime bool) (*x509.Certificate, error) {
+	if s.Protocol != "application/pkcs7-signature" && s.Protocol != "application/x-pkcs7-signature" {
+		return nil, fmt.Errorf("smime: unsupported signature protocol %s", s.Protocol)
+	}
+
+	certs, signers, err := parseSignedData(s.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("smime: signature has no signers")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		intermediates.AddCert(c)
+	}
+
+	for _, si := range signers {
+		var cert *x509.Certificate
+		var signingTime time.Time
+		cert, signingTime, err = si.verify(certs, s.Content)
+		if err != nil {
+			continue
+		}
+
+		// the zero time verifies at the current time
+		if !atSigningTime {
+			signingTime = time.Time{}
+		}
+
+		_, err = cert.Verify(x509.VerifyOptions{
+			Roots:         certPool,
+			Intermediates: intermediates,
+			CurrentTime:   signingTime,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		})
+		if err == nil {
+			return cert, nil
+		}
+		err = fmt.Errorf("smime: %v", err)
+	}
+
+	return nil, err
+}
+
+// parsedSigner is a SignerInfo of a PKCS #7 signature
+type parsedSigner struct {
+	sid         asn1.RawValue
+	digest      asn1.ObjectIdentifier
+	signedAttrs *asn1.RawValue
+	signature   []byte
+}
+
+// parseSignedData returns the certificates and signers of a DER encoded
+// PKCS #7 SignedData content info
+func parseSignedData(der []byte) (certs []*x509.Certificate, signers []parsedSigner, err error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, nil, fmt.Errorf("smime: %v", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, nil, fmt.Errorf("smime: content type %v is not signed data", ci.ContentType)
+	}
+
+	elems, err := asn1Elements(ci.Content.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(elems) < 4 {
+		return nil, nil, errors.New("smime: truncated signed data")
+	}
+
+	// version, digest algorithms and content info are followed by the
+	// optional certificates [0] and CRLs [1] and the signer infos
+	for _, e := range elems[3:] {
+		switch {
+		case e.Class == asn1.ClassContextSpecific && e.Tag == 0:
+			if certs, err = x509.ParseCertificates(e.Bytes); err != nil {
+				return nil, nil, fmt.Errorf("smime: %v", err)
+			}
+		case e.Class == asn1.ClassUniversal && e.Tag == asn1.TagSet:
+			infos, err := asn1Children(e.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, info := range infos {
+				si, err := parseSignerInfo(info.Bytes)
+				if err != nil {
+					return nil, nil, err
+				}
+				signers = append(signers, si)
+			}
+		}
+	}
+
+	return certs, signers, nil
+}
+
+func parseSignerInfo(b []byte) (si parsedSigner, err error) {
+	elems, err := asn1Children(b)
+	if err != nil {
+		return si, err
+	}
+	if len(elems) < 5 {
+		return si, errors.New("smime: truncated signer info")
+	}
+
+	si.sid = elems[1]
+
+	var alg algorithmIdentifier
+	if _, err := asn1.Unmarshal(elems[2].FullBytes, &alg); err != nil {
+		return si, fmt.Errorf("smime: %v", err)
+	}
+	si.digest = alg.Algorithm
+
+	i := 3
+	if elems[i].Class == asn1.ClassContextSpecific && elems[i].Tag == 0 {
+		si.signedAttrs = &elems[i]
+		i++
+	}
+
+	// the signature algorithm follows, the key type of the certificate is
+	// used instead
+	if i+1 >= len(elems) {
+		return si, errors.New("smime: truncated signer info")
+	}
+	if _, err := asn1.Unmarshal(elems[i+1].FullBytes, &si.signature); err != nil {
+		return si, fmt.Errorf("smime: %v", err)
+	}
+
+	return si, nil
+}
+
+// verify checks the signature of the signer over content and returns its
+// certificate and the signing time, if any
+func (si *parsedSigner) verify(certs []*x509.Certificate, content []byte) (*x509.Certificate, time.Time, error) {
+	var signingTime time.Time
+
+	cert := si.certificate(certs)
+	if cert == nil {
+		return nil, signingTime, errors.New("smime: certificate of the signer not found")
+	}
+
+	hash, ok := digestHash(si.digest)
+	if !ok || !hash.Available() {
+		return nil, signingTime, fmt.Errorf("smime: unsupported digest algorithm %v", si.digest)
+	}
+
+	algorithm := signatureAlgorithm(cert.PublicKey, hash)
+	if algorithm == x509.UnknownSignatureAlgorithm {
+		return nil, signingTime, fmt.Errorf("smime: unsupported key type %T", cert.PublicKey)
+	}
+
+	signed := content
+	if si.signedAttrs != nil {
+		attrs, err := asn1Children(si.signedAttrs.Bytes)
+		if err != nil {
+			return nil, signingTime, err
+		}
+
+		h := hash.New()
+		h.Write(content)
+		digest := h.Sum(nil)
+
+		found, typed := false, false
+		for _, a := range attrs {
+			var attr struct {
+				Type   asn1.ObjectIdentifier
+				Values asn1.RawValue
+			}
+			if _, err := asn1.Unmarshal(a.FullBytes, &attr); err != nil {
+				return nil, signingTime, fmt.Errorf("smime: %v", err)
+			}
+
+			switch {
+			case attr.Type.Equal(oidMessageDigest):
+				var d []byte
+				if _, err := asn1.Unmarshal(attr.Values.Bytes, &d); err != nil {
+					return nil, signingTime, fmt.Errorf("smime: %v", err)
+				}
+				if !bytes.Equal(d, digest) {
+					return nil, signingTime, errors.New("smime: message digest mismatch")
+				}
+				found = true
+			case attr.Type.Equal(oidContentType):
+				var ct asn1.ObjectIdentifier
+				if _, err := asn1.Unmarshal(attr.Values.Bytes, &ct); err != nil {
+					return nil, signingTime, fmt.Errorf("smime: %v", err)
+				}
+				if !ct.Equal(oidData) {
+					return nil, signingTime, fmt.Errorf("smime: signed content type %v is not data", ct)
+				}
+				typed = true
+			case attr.Type.Equal(oidSigningTime):
+				asn1.Unmarshal(attr.Values.Bytes, &signingTime)
+			}
+		}
+		if !found {
+			return nil, signingTime, errors.New("smime: signed attributes without message digest")
+		}
+		if !typed {
+			return nil, signingTime, errors.New("smime: signed attributes without content type")
+		}
+
+		// the signature covers the attributes encoded as SET OF
+		signed = append([]byte{0x31}, si.signedAttrs.FullBytes[1:]...)
+	}
+
+	if err := cert.CheckSignature(algorithm, signed, si.signature); err != nil {
+		return nil, signingTime, fmt.Errorf("smime: %v", err)
+	}
+
+	return cert, signingTime, nil
+}
+
+// certificate returns the certificate identified by the signer
+func (si *parsedSigner) certificate(certs []*x509.Certificate) *x509.Certificate {
+	for _, c := range certs {
+		if identifiesCertificate(si.sid, c) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// identifiesCertificate reports whether a signer or recipient identifier,
+// an issuer and serial number or a subject key identifier [0], refers to c
+func identifiesCertificate(id asn1.RawValue, c *x509.Certificate) bool {
+	if id.Class == asn1.ClassContextSpecific && id.Tag == 0 {
+		return len(c.SubjectKeyId) > 0 && bytes.Equal(c.SubjectKeyId, id.Bytes)
+	}
+
+	var ias issuerAndSerial
+	if _, err := asn1.Unmarshal(id.FullBytes, &ias); err != nil {
+		return false
+	}
+
+	return bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.Serial) == 0
+}
+
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, true
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, true
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, true
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, true
+	}
+
+	return 0, false
+}
+
+func signatureAlgorithm(pub interface{}, hash crypto.Hash) x509.SignatureAlgorithm {
+	algorithms := map[crypto.Hash][2]x509.SignatureAlgorithm{
+		crypto.SHA1:   {x509.SHA1WithRSA, x509.ECDSAWithSHA1},
+		crypto.SHA256: {x509.SHA256WithRSA, x509.ECDSAWithSHA256},
+		crypto.SHA384: {x509.SHA384WithRSA, x509.ECDSAWithSHA384},
+		crypto.SHA512: {x509.SHA512WithRSA, x509.ECDSAWithSHA512},
+	}
+
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return algorithms[hash][0]
+	case *ecdsa.PublicKey:
+		return algorithms[hash][1]
+	}
+
+	return x509.UnknownSignatureAlgorithm
+}
+
+// asn1Elements returns the elements of a DER encoded SEQUENCE or SET
+func asn1Elements(der []byte) ([]asn1.RawValue, error) {
+	var outer asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &outer); err != nil {
+		return nil, fmt.Errorf("smime: %v", err)
+	}
+
+	return asn1Children(outer.Bytes)
+}
+
+// asn1Children splits the DER encoded content of a SEQUENCE or SET
+func asn1Children(b []byte) (elems []asn1.RawValue, err error) {
+	for len(b) > 0 {
+		var rv asn1.RawValue
+		if b, err = asn1.Unmarshal(b, &rv); err != nil {
+			return nil, fmt.Errorf("smime: %v", err)
+		}
+		elems = append(elems, rv)
+	}
+
+	return
+}
@@ -0,0 +1,175 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignatureVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = map[int]struct {
+		key interface{}
+	}{
+		1: {key: rsaKey},
+		2: {key: ecKey},
+	}
+
+	e, err := NewEmail().
+		From("jdoe@example.com").
+		To("mary@example.com").
+		Subject("Signed").
+		Text("Hello Mary,\nsee attachment.\n").
+		Attach("notes.pdf", "", []byte("%PDF-1.4")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index, td := range testData {
+		cert := testCertificate(t, td.key)
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+
+		raw, err := SerializeSigned(&e, &SMIMESigner{Certificate: cert, Key: td.key.(crypto.Signer)})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		parsed, err := Parse(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if parsed.Signature == nil {
+			t.Fatalf("[Test Case %v] Wrong signature. Expected a signature, Got: nil", index)
+		}
+
+		if parsed.Signature.Protocol != "application/pkcs7-signature" || parsed.Signature.Micalg != "sha-256" {
+			t.Errorf("[Test Case %v] Wrong signature protocol. Expected: %s, Got: %s %s", index, "application/pkcs7-signature sha-256", parsed.Signature.Protocol, parsed.Signature.Micalg)
+		}
+
+		signer, err := parsed.Signature.Verify(roots)
+		if err != nil {
+			t.Errorf("[Test Case %v] Error verifying signature: %v", index, err)
+		} else if !signer.Equal(cert) {
+			t.Errorf("[Test Case %v] Wrong signer. Expected: %s, Got: %s", index, cert.Subject, signer.Subject)
+		}
+
+		if _, err := parsed.Signature.Verify(x509.NewCertPool()); err == nil {
+			t.Errorf("[Test Case %v] Expected an untrusted signer to fail", index)
+		}
+
+		tampered, err := Parse(strings.NewReader(strings.Replace(string(raw), "Hello Mary", "Hello Marc", 1)))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+		if _, err := tampered.Signature.Verify(roots); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+			t.Errorf("[Test Case %v] Wrong error for modified content. Expected a digest mismatch, Got: %v", index, err)
+		}
+	}
+}
+
+func TestSignatureSignedAttributes(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificate expired an hour ago
+	signedAt := time.Now().Add(-2 * time.Hour).UTC()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(43),
+		Subject:      pkix.Name{CommonName: "jdoe@example.com"},
+		NotBefore:    signedAt.Add(-time.Hour),
+		NotAfter:     signedAt.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	var testData = map[int]struct {
+		attrs         []attribute
+		atSigningTime bool
+		err           string
+	}{
+		1: {
+			attrs: []attribute{{Type: oidContentType, Values: []interface{}{oidData}}, {Type: oidSigningTime, Values: []interface{}{signedAt}}},
+			err:   "expired",
+		},
+		2: {
+			attrs:         []attribute{{Type: oidContentType, Values: []interface{}{oidData}}, {Type: oidSigningTime, Values: []interface{}{signedAt}}},
+			atSigningTime: true,
+		},
+		3: {
+			attrs:         []attribute{{Type: oidSigningTime, Values: []interface{}{signedAt}}},
+			atSigningTime: true,
+			err:           "without content type",
+		},
+		4: {
+			attrs:         []attribute{{Type: oidContentType, Values: []interface{}{oidSignedData}}, {Type: oidSigningTime, Values: []interface{}{signedAt}}},
+			atSigningTime: true,
+			err:           "is not data",
+		},
+	}
+
+	content := []byte("Content-Type: text/plain\r\n\r\nHello\r\n")
+	for index, td := range testData {
+		data, err := (&SMIMESigner{Certificate: cert, Key: key}).signatureWith(content, td.attrs)
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		s := &Signature{Protocol: "application/pkcs7-signature", Data: data, Content: content}
+		verify := s.Verify
+		if td.atSigningTime {
+			verify = s.VerifyAtSigningTime
+		}
+
+		_, err = verify(roots)
+		if td.err == "" {
+			if err != nil {
+				t.Errorf("[Test Case %v] Error verifying signature: %v", index, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), td.err) {
+			t.Errorf("[Test Case %v] Wrong error. Expected: %s, Got: %v", index, td.err, err)
+		}
+	}
+}
+
+func TestSignatureUnsigned(t *testing.T) {
+	e, err := Parse(strings.NewReader(rfc5322exampleA11))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Signature != nil {
+		t.Errorf("Wrong signature. Expected: nil, Got: %+v", e.Signature)
+	}
+}
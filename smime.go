@@ -0,0 +1,238 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSA           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSASHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// SMIMESigner turns messages into S/MIME multipart/signed messages (RFC 8551)
+// with a detached PKCS #7 signature. Key must be the RSA or ECDSA key of
+// Certificate. Intermediates are included in the signature.
+type SMIMESigner struct {
+	Certificate   *x509.Certificate
+	Intermediates []*x509.Certificate
+	Key           crypto.Signer
+}
+
+// Sign moves the content header fields and the body of message into the
+// signed part, converting its line breaks to CRLF, and adds the signature as
+// second part. The other header fields stay unchanged.
+func (s *SMIMESigner) Sign(message []byte) ([]byte, error) {
+	if s.Certificate == nil || s.Key == nil {
+		return nil, errors.New("smime: certificate and key are required")
+	}
+
+	header, body := splitHeader(message)
+
+	var outer, content bytes.Buffer
+	for _, f := range splitHeaderFields(header) {
+		switch {
+		case strings.HasPrefix(f.Name, "Content-"):
+			content.Write(crlfLines(f.Raw))
+		case f.Name != "Mime-Version":
+			outer.Write(f.Raw)
+		}
+	}
+	content.WriteString("\r\n")
+	content.Write(crlfLines(body))
+
+	signature, err := s.signature(content.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	boundary := multipart.NewWriter(ioutil.Discard).Boundary()
+	ct := mime.FormatMediaType("multipart/signed", map[string]string{
+		"protocol": "application/pkcs7-signature",
+		"micalg":   "sha-256",
+		"boundary": boundary,
+	})
+
+	var buf bytes.Buffer
+	buf.Write(outer.Bytes())
+	buf.WriteString("MIME-Version: 1.0\r\n" + FoldHeader("Content-Type", ct) + "\r\n")
+
+	mw := multipart.NewWriter(&buf)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+
+	// the signed part is written as is, including its own header
+	buf.WriteString("--" + boundary + "\r\n")
+	buf.Write(content.Bytes())
+	buf.WriteString("\r\n")
+
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`application/pkcs7-signature; name="smime.p7s"`},
+		"Content-Disposition":       {`attachment; filename="smime.p7s"`},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := filePart(textproto.MIMEHeader{}, signature).write(pw); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerial
+	DigestAlgorithm    algorithmIdentifier
+	SignedAttrs        asn1.RawValue
+	SignatureAlgorithm algorithmIdentifier
+	Signature          []byte
+}
+
+type encapsulatedContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []algorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      []signerInfo `asn1:"set"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []interface{} `asn1:"set"`
+}
+
+// signature returns the DER encoded detached PKCS #7 signature of content
+func (s *SMIMESigner) signature(content []byte) ([]byte, error) {
+	return s.signatureWith(content, []attribute{
+		{Type: oidContentType, Values: []interface{}{oidData}},
+		{Type: oidSigningTime, Values: []interface{}{time.Now().UTC()}},
+	})
+}
+
+// signatureWith returns the signature of content over the signed attributes
+// attrs and the message digest of content
+func (s *SMIMESigner) signatureWith(content []byte, attrs []attribute) ([]byte, error) {
+	var sigAlg algorithmIdentifier
+	switch s.Key.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlg = algorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.NullRawValue}
+	case *ecdsa.PublicKey:
+		sigAlg = algorithmIdentifier{Algorithm: oidECDSASHA256}
+	default:
+		return nil, fmt.Errorf("smime: unsupported key type %T", s.Key.Public())
+	}
+
+	digest := sha256.Sum256(content)
+	signedAttrs, err := marshalSet(append(attrs, attribute{Type: oidMessageDigest, Values: []interface{}{digest[:]}}))
+	if err != nil {
+		return nil, err
+	}
+
+	// the signature covers the DER encoding of the attributes as SET OF
+	attrsDigest := sha256.Sum256(signedAttrs.FullBytes)
+	sig, err := s.Key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("smime: %v", err)
+	}
+
+	var certs []byte
+	for _, c := range append([]*x509.Certificate{s.Certificate}, s.Intermediates...) {
+		certs = append(certs, c.Raw...)
+	}
+
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []algorithmIdentifier{{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}},
+		EncapContentInfo: encapsulatedContentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			SID: issuerAndSerial{
+				Issuer: asn1.RawValue{FullBytes: s.Certificate.RawIssuer},
+				Serial: s.Certificate.SerialNumber,
+			},
+			DigestAlgorithm:    algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttrs.Bytes},
+			SignatureAlgorithm: sigAlg,
+			Signature:          sig,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+// marshalSet encodes attrs as a DER SET OF, sorted by their encodings
+func marshalSet(attrs []attribute) (asn1.RawValue, error) {
+	encoded := make([][]byte, len(attrs))
+	for i, a := range attrs {
+		b, err := asn1.Marshal(a)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		encoded[i] = b
+	}
+
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+
+	full, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(encoded, nil)})
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	return asn1.RawValue{Bytes: bytes.Join(encoded, nil), FullBytes: full}, nil
+}
@@ -0,0 +1,202 @@
+package parsemail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"testing"
+	"time"
+)
+
+func TestSMIMESigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = map[int]struct {
+		key interface{}
+	}{
+		1: {key: rsaKey},
+		2: {key: ecKey},
+	}
+
+	e, err := NewEmail().
+		From("jdoe@example.com").
+		To("mary@example.com").
+		Subject("Signed").
+		Text("Hello Mary,\nsee attachment.\n").
+		Attach("notes.pdf", "", []byte("%PDF-1.4")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index, td := range testData {
+		signer := &SMIMESigner{Certificate: testCertificate(t, td.key), Key: td.key.(crypto.Signer)}
+
+		raw, err := SerializeSigned(&e, signer)
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		parsed, err := Parse(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if parsed.Subject != "Signed" || parsed.TextBody != "Hello Mary,\r\nsee attachment.\r\n" {
+			t.Errorf("[Test Case %v] Wrong parsed message: %s %q", index, parsed.Subject, parsed.TextBody)
+		}
+
+		if len(parsed.Attachments) != 2 || parsed.Attachments[1].Filename != "smime.p7s" {
+			t.Fatalf("[Test Case %v] Wrong attachments: %v", index, parsed.Attachments)
+		}
+
+		content, signature := signedParts(t, raw)
+		if msg := verifySMIME(content, signature, signer.Certificate); msg != "" {
+			t.Errorf("[Test Case %v] %s", index, msg)
+		}
+
+		if msg := verifySMIME(append(content, ' '), signature, signer.Certificate); msg == "" {
+			t.Errorf("[Test Case %v] Expected modified content to fail", index)
+		}
+	}
+}
+
+func testCertificate(t *testing.T, key interface{}) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "jdoe@example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+
+	var pub interface{}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		pub = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		pub = &k.PublicKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// signedParts returns the raw signed part and the decoded signature
+func signedParts(t *testing.T, raw []byte) ([]byte, []byte) {
+	header, body := splitHeader(raw)
+
+	var ct string
+	for _, f := range splitHeaderFields(header) {
+		if f.Name == "Content-Type" {
+			ct = f.Value()
+		}
+	}
+
+	_, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := splitBody(body, params["boundary"])
+	if len(parts) != 2 {
+		t.Fatalf("Wrong number of signed parts: %d", len(parts))
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	mr.NextPart()
+	p, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parts[0], signature
+}
+
+// verifySMIME checks the detached signature of content and describes a
+// failure
+func verifySMIME(content, signature []byte, cert *x509.Certificate) string {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(signature, &ci); err != nil {
+		return err.Error()
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return err.Error()
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		return "wrong number of signers"
+	}
+	si := sd.SignerInfos[0]
+
+	attrs := append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+
+	var rawAttrs []struct {
+		Type   asn1.ObjectIdentifier
+		Values asn1.RawValue
+	}
+	if _, err := asn1.UnmarshalWithParams(attrs, &rawAttrs, "set"); err != nil {
+		return err.Error()
+	}
+
+	digest := sha256.Sum256(content)
+	found := false
+	for _, a := range rawAttrs {
+		if a.Type.Equal(oidMessageDigest) {
+			var d []byte
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &d); err != nil {
+				return err.Error()
+			}
+			found = bytes.Equal(d, digest[:])
+		}
+	}
+	if !found {
+		return "message digest mismatch"
+	}
+
+	algorithm := x509.SHA256WithRSA
+	if si.SignatureAlgorithm.Algorithm.Equal(oidECDSASHA256) {
+		algorithm = x509.ECDSAWithSHA256
+	}
+
+	if err := cert.CheckSignature(algorithm, attrs, si.Signature); err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
@@ -0,0 +1,238 @@
+package parsemail
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// SplitParts splits a message into its raw header block and the raw bytes of
+// its top level parts without decoding anything. Every part keeps its own
+// header block, so a part can be split again to reach nested parts. Messages
+// which aren't multipart have no parts.
+func SplitParts(r io.Reader) (header []byte, parts [][]byte, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	header, _, parts, err = splitParts(data)
+
+	return
+}
+
+func splitParts(data []byte) (header []byte, mh textproto.MIMEHeader, parts [][]byte, err error) {
+	header, body := splitHeader(data)
+
+	mh, err = parseRawHeader(header)
+	if err != nil {
+		return
+	}
+
+	contentType, params, err := parseContentType(mh.Get("Content-Type"))
+	if err != nil {
+		return
+	}
+
+	if !strings.HasPrefix(contentType, "multipart/") {
+		return
+	}
+
+	if params["boundary"] == "" {
+		err = fmt.Errorf("%s without boundary", contentType)
+		return
+	}
+
+	parts = splitBody(body, params["boundary"])
+
+	return
+}
+
+func parseRawHeader(header []byte) (textproto.MIMEHeader, error) {
+	if len(bytes.TrimSpace(header)) == 0 {
+		return textproto.MIMEHeader{}, nil
+	}
+
+	h, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(header))).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// DecodePart decodes a single part of the original message addressed by its
+// IMAP style section path ("1", "2.3") without decoding any other part. Parts
+// of an attached message/rfc822 are addressed through the attachment's path.
+func (e *Email) DecodePart(path string) (at Attachment, err error) {
+	cur, err := e.rawMessage()
+	if err != nil {
+		return at, err
+	}
+
+	isMessage := true
+	for _, section := range strings.Split(path, ".") {
+		n, err := strconv.Atoi(section)
+		if err != nil || n < 1 {
+			return at, fmt.Errorf("invalid part path: %s", path)
+		}
+
+		_, mh, parts, err := splitParts(cur)
+		if err != nil {
+			return at, err
+		}
+
+		// the section of an encapsulated message refers to the parts of its body
+		if !isMessage && strings.HasPrefix(strings.ToLower(mh.Get("Content-Type")), messageRFC822) {
+			_, cur = splitHeader(cur)
+			if _, _, parts, err = splitParts(cur); err != nil {
+				return at, err
+			}
+		}
+
+		if parts == nil {
+			if n != 1 {
+				return at, fmt.Errorf("part %s not found", path)
+			}
+		} else {
+			if n > len(parts) {
+				return at, fmt.Errorf("part %s not found", path)
+			}
+
+			cur = parts[n-1]
+		}
+
+		isMessage = false
+	}
+
+	header, body := splitHeader(cur)
+	mh, err := parseRawHeader(header)
+	if err != nil {
+		return
+	}
+
+	at.Data, err = e.partParser().decodeContent(bytes.NewReader(body), mh.Get("Content-Transfer-Encoding"))
+	if err != nil {
+		return
+	}
+
+	at.ContentType, _, _ = parseContentType(mh.Get("Content-Type"))
+	at.Filename = decodeMimeSentenceVersion(partFilename(mh, e.SchemaVersion), e.SchemaVersion)
+
+	return
+}
+
+// partParser returns a parser for the parts of the original message of the
+// email, decoding them within the limits of the options the email was parsed
+// with. Its findings aren't added to the email.
+func (e *Email) partParser() *parser {
+	return &parser{email: &Email{SchemaVersion: e.SchemaVersion}, opts: e.opts}
+}
+
+// walkRawParts calls fn with the header and raw body of every leaf part of
+// the message, depth first. The parts of encapsulated messages are not
+// visited. Multipart bodies and their parts count against the MaxDepth and
+// MaxParts of opts like when parsing.
+func walkRawParts(data []byte, opts Options, fn func(header textproto.MIMEHeader, body []byte)) error {
+	return (&parser{opts: opts}).walkRawParts(data, fn)
+}
+
+func (p *parser) walkRawParts(data []byte, fn func(header textproto.MIMEHeader, body []byte)) error {
+	header, mh, parts, err := splitParts(data)
+	if err != nil {
+		return err
+	}
+
+	if parts == nil {
+		fn(mh, data[len(header):])
+		return nil
+	}
+
+	if err := p.enterMultipart(); err != nil {
+		return err
+	}
+	defer p.leaveMultipart()
+
+	for _, part := range parts {
+		if err := p.countPart(); err != nil {
+			return err
+		}
+
+		if err := p.walkRawParts(part, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitHeader returns the header block including its terminating blank line
+// and the body that follows it.
+func splitHeader(data []byte) (header, body []byte) {
+	for i := 0; i < len(data); {
+		end := bytes.IndexByte(data[i:], '\n')
+		if end < 0 {
+			return data, nil
+		}
+
+		line := data[i : i+end+1]
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			return data[:i+end+1], data[i+end+1:]
+		}
+
+		i += end + 1
+	}
+
+	return data, nil
+}
+
+// splitBody returns the parts delimited by boundary. The line break before a
+// delimiter line belongs to the delimiter and is not part of the part.
+func splitBody(body []byte, boundary string) (parts [][]byte) {
+	delimiter := []byte("--" + boundary)
+	start := -1
+
+	for i := 0; i < len(body); {
+		end := bytes.IndexByte(body[i:], '\n')
+		next := len(body)
+		if end >= 0 {
+			next = i + end + 1
+		}
+
+		line := bytes.TrimRight(body[i:next], " \t\r\n")
+		if bytes.HasPrefix(line, delimiter) {
+			rest := line[len(delimiter):]
+			closing := bytes.Equal(rest, []byte("--"))
+
+			if len(rest) == 0 || closing {
+				if start >= 0 {
+					parts = append(parts, trimLineBreak(body[start:i]))
+				}
+
+				if closing {
+					return
+				}
+
+				start = next
+			}
+		}
+
+		i = next
+	}
+
+	if start >= 0 && start < len(body) {
+		parts = append(parts, body[start:])
+	}
+
+	return
+}
+
+func trimLineBreak(b []byte) []byte {
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	return bytes.TrimSuffix(b, []byte("\r"))
+}
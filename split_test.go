@@ -0,0 +1,230 @@
+package parsemail
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestSplitParts(t *testing.T) {
+	var testData = map[int]struct {
+		mailData     string
+		headerPrefix string
+		parts        []string
+	}{
+		1: {
+			mailData:     rfc5322exampleA11,
+			headerPrefix: "From: John Doe",
+		},
+		2: {
+			mailData:     textPlainInMultipart,
+			headerPrefix: "From: Rares",
+			parts: []string{
+				"Content-Type: text/plain; charset=\"UTF-8\"\n\nplain text part",
+			},
+		},
+		3: {
+			mailData:     splitPartsExample,
+			headerPrefix: "From: Test",
+			parts: []string{
+				"Content-Type: text/plain\r\n\r\nfirst\r\n",
+				"Content-Type: text/html\r\n\r\n<b>second</b>",
+			},
+		},
+	}
+
+	for index, td := range testData {
+		header, parts, err := SplitParts(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		if !strings.HasPrefix(string(header), td.headerPrefix) {
+			t.Errorf("[Test Case %v] Wrong header. Expected prefix: %q, Got: %q", index, td.headerPrefix, header)
+		}
+
+		if len(parts) != len(td.parts) {
+			t.Errorf("[Test Case %v] Wrong number of parts. Expected: %v, Got: %v", index, len(td.parts), len(parts))
+			continue
+		}
+
+		for i, p := range parts {
+			if string(p) != td.parts[i] {
+				t.Errorf("[Test Case %v] Wrong part %v. Expected: %q, Got: %q", index, i, td.parts[i], p)
+			}
+		}
+	}
+}
+
+var splitPartsExample = "From: Test <test@test.lan>\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"XX\"\r\n" +
+	"\r\n" +
+	"preamble\r\n" +
+	"--XX\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"first\r\n" +
+	"\r\n" +
+	"--XX  \r\n" +
+	"Content-Type: text/html\r\n" +
+	"\r\n" +
+	"<b>second</b>\r\n" +
+	"--XX--\r\n" +
+	"epilogue\r\n"
+
+func TestDecodePart(t *testing.T) {
+	var testData = map[int]struct {
+		mailData    string
+		path        string
+		contentType string
+		data        string
+		err         bool
+	}{
+		1: {
+			mailData:    rfc822,
+			path:        "1.1",
+			contentType: "text/plain",
+			data:        "test body",
+		},
+		2: {
+			mailData:    rfc822,
+			path:        "1.2",
+			contentType: "text/html",
+			data:        "<html><head></head><body>test body</body></html>",
+		},
+		3: {
+			mailData:    rfc822,
+			path:        "2.1",
+			contentType: "text/plain",
+			data:        "test\n",
+		},
+		4: {
+			mailData: rfc822,
+			path:     "3",
+			err:      true,
+		},
+		5: {
+			mailData:    rfc5322exampleA11,
+			path:        "1",
+			contentType: "text/plain",
+			data:        "This is a message just to say hello.\nSo, \"Hello\".\n",
+		},
+		6: {
+			mailData: rfc5322exampleA11,
+			path:     "1.x",
+			err:      true,
+		},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(td.mailData), Options{KeepRaw: true})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		at, err := e.DecodePart(td.path)
+		if td.err {
+			if err == nil {
+				t.Errorf("[Test Case %v] Expected an error", index)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		if at.ContentType != td.contentType {
+			t.Errorf("[Test Case %v] Wrong content type. Expected: %s, Got: %s", index, td.contentType, at.ContentType)
+		}
+
+		b, err := ioutil.ReadAll(at.Data)
+		if err != nil {
+			t.Error(err)
+		} else if string(b) != td.data {
+			t.Errorf("[Test Case %v] Wrong data. Expected: %q, Got: %q", index, td.data, b)
+		}
+	}
+}
+
+func TestDecodePartOptions(t *testing.T) {
+	mailData := "From: a@example.com\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain\r\n\r\nSee attached\r\n" +
+		"--b\r\nContent-Type: application/octet-stream; name=\"C:/Documents/zeros.bin\"\r\nContent-Disposition: attachment\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" + base64.StdEncoding.EncodeToString(make([]byte, 64)) + "\r\n" +
+		"--b--\r\n"
+
+	var testData = map[int]struct {
+		opts     Options
+		filename string
+		err      bool
+	}{
+		1: {opts: Options{KeepRaw: true}, filename: "zeros.bin"},
+		2: {opts: Options{KeepRaw: true, SchemaVersion: 5}, filename: ""},
+		3: {opts: Options{KeepRaw: true, LazyAttachments: true, MaxDecodedSize: 32}, err: true},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(mailData), td.opts)
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		at, err := e.DecodePart("2")
+		if td.err {
+			if _, ok := err.(*DecodedSizeError); !ok {
+				t.Errorf("[Test Case %v] Wrong error. Expected: *DecodedSizeError, Got: %v", index, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if at.Filename != td.filename {
+			t.Errorf("[Test Case %v] Wrong filename. Expected: %q, Got: %q", index, td.filename, at.Filename)
+		}
+	}
+}
+
+func TestWalkRawPartsLimits(t *testing.T) {
+	data := []byte("Content-Type: multipart/mixed; boundary=\"a\"\n\n" +
+		"--a\nContent-Type: multipart/mixed; boundary=\"b\"\n\n" +
+		"--b\nContent-Type: text/plain\n\none\n--b\nContent-Type: text/plain\n\ntwo\n--b--\n" +
+		"--a\nContent-Type: text/plain\n\nthree\n--a--\n")
+
+	var testData = map[int]struct {
+		opts   Options
+		leaves int
+		option string
+	}{
+		1: {opts: Options{}, leaves: 3},
+		2: {opts: Options{MaxParts: 4}, leaves: 3},
+		3: {opts: Options{MaxParts: 3}, leaves: 2, option: "MaxParts"},
+		4: {opts: Options{MaxDepth: 1}, leaves: 0, option: "MaxDepth"},
+	}
+
+	for index, td := range testData {
+		leaves := 0
+		err := walkRawParts(data, td.opts, func(header textproto.MIMEHeader, body []byte) {
+			leaves++
+		})
+
+		if leaves != td.leaves {
+			t.Errorf("[Test Case %v] Wrong number of parts visited. Expected: %v, Got: %v", index, td.leaves, leaves)
+		}
+
+		if td.option == "" {
+			if err != nil {
+				t.Errorf("[Test Case %v] Unexpected error: %v", index, err)
+			}
+		} else if le, ok := err.(*PartLimitError); !ok || le.Option != td.option {
+			t.Errorf("[Test Case %v] Wrong error. Expected: %s exceeded, Got: %v", index, td.option, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package parsemail
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+)
+
+const (
+	FindingBidiControl         FindingCode = "bidi-control"
+	FindingInvisibleCharacter  FindingCode = "invisible-character"
+	FindingMixedScript         FindingCode = "mixed-script"
+	FindingConfusableCharacter FindingCode = "confusable-character"
+)
+
+// confusableScripts are the scripts with letters that look like latin ones.
+// Mixing them within a word is a common homoglyph attack.
+var confusableScripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Armenian", unicode.Armenian},
+	{"Cherokee", unicode.Cherokee},
+}
+
+// confusables maps letters of other scripts to the latin letters they can't
+// be told apart from in common fonts
+var confusables = map[rune]rune{
+	// cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y',
+	'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's', 'һ': 'h', 'ԁ': 'd', 'ԛ': 'q',
+	'ԝ': 'w', 'ӏ': 'l', 'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M',
+	'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'І': 'I',
+	'Ј': 'J', 'Ѕ': 'S', 'Ү': 'Y',
+	// greek
+	'ο': 'o', 'α': 'a', 'ν': 'v', 'ι': 'i', 'κ': 'k', 'Α': 'A', 'Β': 'B',
+	'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N',
+	'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+}
+
+// CheckDisplayNames reports spoofing techniques in the display names of the
+// From and Reply-To addresses: bidi control characters that reorder the
+// visible text, invisible characters, words mixing latin with lookalike
+// scripts and words written entirely in lookalikes of latin letters.
+func (e *Email) CheckDisplayNames() (findings []Finding) {
+	fields := []struct {
+		name      string
+		addresses []*mail.Address
+	}{
+		{"From", e.From},
+		{"Reply-To", e.ReplyTo},
+	}
+
+	for _, f := range fields {
+		for _, a := range f.addresses {
+			if a != nil {
+				findings = append(findings, checkDisplayName(f.name, a.Name)...)
+			}
+		}
+	}
+
+	return
+}
+
+func checkDisplayName(field, name string) (findings []Finding) {
+	bidi, invisible := false, false
+	for _, r := range name {
+		switch {
+		case isBidiControl(r):
+			bidi = true
+		case isInvisible(r):
+			invisible = true
+		}
+	}
+
+	if bidi {
+		findings = append(findings, Finding{
+			Code:    FindingBidiControl,
+			Message: fmt.Sprintf("%s display name %q contains bidi control characters", field, name),
+		})
+	}
+
+	if invisible {
+		findings = append(findings, Finding{
+			Code:    FindingInvisibleCharacter,
+			Message: fmt.Sprintf("%s display name %q contains invisible characters", field, name),
+		})
+	}
+
+	for _, word := range strings.FieldsFunc(StripInvisible(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r)
+	}) {
+		scripts := wordScripts(word)
+
+		if len(scripts) > 1 {
+			findings = append(findings, Finding{
+				Code:    FindingMixedScript,
+				Message: fmt.Sprintf("%s display name %q mixes %s in %q", field, name, strings.Join(scripts, " and "), word),
+			})
+		} else if skeleton, ok := latinSkeleton(word); ok && len(scripts) == 1 && scripts[0] != "Latin" {
+			findings = append(findings, Finding{
+				Code:    FindingConfusableCharacter,
+				Message: fmt.Sprintf("%s display name %q contains %q written in %s, which looks like %q", field, name, word, scripts[0], skeleton),
+			})
+		}
+	}
+
+	return
+}
+
+// wordScripts returns the confusable scripts used by the letters of word
+func wordScripts(word string) (scripts []string) {
+	for _, s := range confusableScripts {
+		for _, r := range word {
+			if unicode.Is(s.table, r) {
+				scripts = append(scripts, s.name)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// latinSkeleton returns the latin word a word of at least two lookalikes of
+// latin letters is mistaken for. Single letters, like initials, are ignored.
+func latinSkeleton(word string) (string, bool) {
+	var sb strings.Builder
+	for _, r := range word {
+		l, ok := confusables[r]
+		if !ok {
+			return "", false
+		}
+		sb.WriteRune(l)
+	}
+
+	return sb.String(), sb.Len() > 1
+}
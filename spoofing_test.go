@@ -0,0 +1,39 @@
+package parsemail
+
+import (
+	"net/mail"
+	"testing"
+)
+
+func TestCheckDisplayNames(t *testing.T) {
+	var testData = map[int]struct {
+		from    string
+		replyTo string
+		codes   []string
+	}{
+		1: {from: "John Doe", replyTo: "Jürgen Müller"},
+		2: {from: "Иван Петров", replyTo: "Σωκράτης"},
+		3: {from: "Support \u202etxt.exe", codes: []string{string(FindingBidiControl)}},
+		4: {from: "Pay\u200bPal", codes: []string{string(FindingInvisibleCharacter)}},
+		5: {from: "Pаypal Support", codes: []string{string(FindingMixedScript)}},
+		6: {replyTo: "раураӏ Team", codes: []string{string(FindingConfusableCharacter)}},
+		7: {from: "А. Smith"},
+		8: {from: "\u2066Αpple\u2069", replyTo: "HR", codes: []string{string(FindingBidiControl), string(FindingMixedScript)}},
+	}
+
+	for index, td := range testData {
+		e := Email{
+			From:    []*mail.Address{{Name: td.from, Address: "from@example.com"}},
+			ReplyTo: []*mail.Address{{Name: td.replyTo, Address: "reply@example.com"}},
+		}
+
+		var codes []string
+		for _, f := range e.CheckDisplayNames() {
+			codes = append(codes, string(f.Code))
+		}
+
+		if !assertSliceEq(codes, td.codes) {
+			t.Errorf("[Test Case %v] Wrong findings. Expected: %s, Got: %s", index, td.codes, codes)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package parsemail
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store keeps parsed emails in memory and answers queries by sender, date
+// range, attachments, subject and thread, for tools that need a small
+// mailbox without a database. The indices are built when emails are added.
+// The zero value is not usable, create it with NewStore.
+type Store struct {
+	emails []*Email
+	// threads holds the thread id of every email, by position
+	threads []string
+
+	bySender    map[string][]int
+	byThread    map[string][]int
+	byMessageID map[string]int
+	// byDate holds the positions of the emails ordered by date
+	byDate []int
+}
+
+// NewStore returns an empty Store
+func NewStore() *Store {
+	return &Store{
+		bySender:    map[string][]int{},
+		byThread:    map[string][]int{},
+		byMessageID: map[string]int{},
+	}
+}
+
+// Len returns the number of emails in the store
+func (s *Store) Len() int {
+	return len(s.emails)
+}
+
+// Add indexes the email and returns the id of its thread. The thread id is
+// the message ID of the first message of the thread: the first entry of
+// References, or the thread of the message it replies to, or its own
+// message ID. A message that arrives after its replies pulls them into its
+// thread.
+func (s *Store) Add(e *Email) string {
+	i := len(s.emails)
+	s.emails = append(s.emails, e)
+
+	thread := s.threadOf(e)
+	s.threads = append(s.threads, thread)
+	s.byThread[thread] = append(s.byThread[thread], i)
+
+	if e.MessageID != "" {
+		if _, ok := s.byMessageID[e.MessageID]; !ok {
+			s.byMessageID[e.MessageID] = i
+		}
+
+		// replies added earlier were threaded by this message's ID
+		if thread != e.MessageID {
+			if replies, ok := s.byThread[e.MessageID]; ok {
+				for _, r := range replies {
+					s.threads[r] = thread
+				}
+				s.byThread[thread] = append(s.byThread[thread], replies...)
+				sort.Ints(s.byThread[thread])
+				delete(s.byThread, e.MessageID)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, a := range e.From {
+		if a == nil {
+			continue
+		}
+		address := strings.ToLower(a.Address)
+		if !seen[address] {
+			seen[address] = true
+			s.bySender[address] = append(s.bySender[address], i)
+		}
+	}
+
+	// keep byDate ordered, emails with equal dates in the order added
+	at := sort.Search(len(s.byDate), func(j int) bool {
+		return s.emails[s.byDate[j]].Date.After(e.Date)
+	})
+	s.byDate = append(s.byDate, 0)
+	copy(s.byDate[at+1:], s.byDate[at:])
+	s.byDate[at] = i
+
+	return thread
+}
+
+func (s *Store) threadOf(e *Email) string {
+	if len(e.References) > 0 {
+		return s.rootOf(e.References[0])
+	}
+
+	if len(e.InReplyTo) > 0 {
+		return s.rootOf(e.InReplyTo[0])
+	}
+
+	return e.MessageID
+}
+
+// rootOf returns the thread of the message with the id if it is in the
+// store, and the id otherwise
+func (s *Store) rootOf(id string) string {
+	if i, ok := s.byMessageID[id]; ok {
+		return s.threads[i]
+	}
+
+	return id
+}
+
+// ThreadID returns the thread id of the message with the message ID, or ""
+// if it isn't in the store
+func (s *Store) ThreadID(messageID string) string {
+	if i, ok := s.byMessageID[messageID]; ok {
+		return s.threads[i]
+	}
+
+	return ""
+}
+
+// StoreQuery selects emails of a Store. Empty fields don't restrict the
+// result.
+type StoreQuery struct {
+	// From matches the addresses of the From field, ignoring case
+	From string
+	// Since and Until restrict the date to the range [Since, Until)
+	Since time.Time
+	Until time.Time
+	// HasAttachments selects the emails with attachments
+	HasAttachments bool
+	// SubjectContains matches a part of the subject, ignoring case
+	SubjectContains string
+	// ThreadID selects the emails of a thread, see Store.Add
+	ThreadID string
+}
+
+// Query returns the emails matching all fields of q, ordered by date
+func (s *Store) Query(q StoreQuery) []*Email {
+	// start from the most selective index
+	var candidates []int
+	switch {
+	case q.ThreadID != "":
+		candidates = s.byThread[q.ThreadID]
+	case q.From != "":
+		candidates = s.bySender[strings.ToLower(q.From)]
+	default:
+		candidates = s.dateRange(q.Since, q.Until)
+	}
+
+	subject := strings.ToLower(q.SubjectContains)
+	from := strings.ToLower(q.From)
+
+	var matches []int
+	for _, i := range candidates {
+		e := s.emails[i]
+		switch {
+		case from != "" && !hasSender(e, from):
+		case !q.Since.IsZero() && e.Date.Before(q.Since):
+		case !q.Until.IsZero() && !e.Date.Before(q.Until):
+		case q.HasAttachments && len(e.Attachments) == 0:
+		case subject != "" && !strings.Contains(strings.ToLower(e.Subject), subject):
+		default:
+			matches = append(matches, i)
+		}
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		return s.emails[matches[a]].Date.Before(s.emails[matches[b]].Date)
+	})
+
+	result := make([]*Email, len(matches))
+	for j, i := range matches {
+		result[j] = s.emails[i]
+	}
+
+	return result
+}
+
+// dateRange returns the positions of the emails dated in [since, until)
+// from the date index
+func (s *Store) dateRange(since, until time.Time) []int {
+	start := 0
+	if !since.IsZero() {
+		start = sort.Search(len(s.byDate), func(j int) bool {
+			return !s.emails[s.byDate[j]].Date.Before(since)
+		})
+	}
+
+	end := len(s.byDate)
+	if !until.IsZero() {
+		end = sort.Search(len(s.byDate), func(j int) bool {
+			return !s.emails[s.byDate[j]].Date.Before(until)
+		})
+	}
+
+	if end < start {
+		return nil
+	}
+
+	return s.byDate[start:end]
+}
+
+func hasSender(e *Email, address string) bool {
+	for _, a := range e.From {
+		if a != nil && strings.ToLower(a.Address) == address {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	messages := []string{
+		"From: Alice <alice@example.com>\r\nMessage-ID: <2@example.com>\r\nIn-Reply-To: <1@example.com>\r\nSubject: Re: Budget\r\nDate: Tue, 02 Jan 2024 10:00:00 +0000\r\n\r\nOk\r\n",
+		"From: Bob <bob@example.com>\r\nMessage-ID: <1@example.com>\r\nSubject: Budget\r\nDate: Mon, 01 Jan 2024 10:00:00 +0000\r\n\r\nNumbers\r\n",
+		"From: bob@example.com\r\nMessage-ID: <3@example.com>\r\nReferences: <1@example.com> <2@example.com>\r\nSubject: Re: Budget\r\nDate: Wed, 03 Jan 2024 10:00:00 +0000\r\n" +
+			"Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: text/plain\r\n\r\nSee attached\r\n" +
+			"--b\r\nContent-Type: text/csv\r\nContent-Disposition: attachment; filename=budget.csv\r\n\r\na,b\r\n--b--\r\n",
+		"From: Carol <CAROL@example.org>\r\nMessage-ID: <4@example.org>\r\nSubject: Lunch\r\nDate: Tue, 02 Jan 2024 12:00:00 +0000\r\n\r\nPizza?\r\n",
+	}
+
+	s := NewStore()
+	for _, raw := range messages {
+		e, err := Parse(strings.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Add(&e)
+	}
+
+	if s.Len() != 4 {
+		t.Errorf("Wrong length. Expected: 4, Got: %d", s.Len())
+	}
+	if thread := s.ThreadID("2@example.com"); thread != "1@example.com" {
+		t.Errorf("Wrong thread id. Expected: 1@example.com, Got: %s", thread)
+	}
+
+	jan2 := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	var testData = map[int]struct {
+		query    StoreQuery
+		expected []string
+	}{
+		1: {query: StoreQuery{}, expected: []string{"1@example.com", "2@example.com", "4@example.org", "3@example.com"}},
+		2: {query: StoreQuery{From: "BOB@example.com"}, expected: []string{"1@example.com", "3@example.com"}},
+		3: {query: StoreQuery{Since: jan2}, expected: []string{"2@example.com", "4@example.org", "3@example.com"}},
+		4: {query: StoreQuery{Since: jan2, Until: jan2.Add(24 * time.Hour)}, expected: []string{"2@example.com", "4@example.org"}},
+		5: {query: StoreQuery{HasAttachments: true}, expected: []string{"3@example.com"}},
+		6: {query: StoreQuery{SubjectContains: "BUDGET", From: "alice@example.com"}, expected: []string{"2@example.com"}},
+		7: {query: StoreQuery{ThreadID: "1@example.com"}, expected: []string{"1@example.com", "2@example.com", "3@example.com"}},
+		8: {query: StoreQuery{ThreadID: "4@example.org", From: "carol@example.org"}, expected: []string{"4@example.org"}},
+		9: {query: StoreQuery{Since: jan2.Add(24 * time.Hour), Until: jan2}},
+	}
+
+	for index, td := range testData {
+		var got []string
+		for _, e := range s.Query(td.query) {
+			got = append(got, e.MessageID)
+		}
+
+		if !assertSliceEq(got, td.expected) {
+			t.Errorf("[Test Case %v] Wrong emails. Expected: %s, Got: %s", index, td.expected, got)
+		}
+	}
+}
+
+func TestStoreLateParent(t *testing.T) {
+	messages := []string{
+		"From: a@example.com\r\nMessage-ID: <c@example.com>\r\nIn-Reply-To: <b@example.com>\r\nSubject: Re: Re: Plan\r\n\r\nLast\r\n",
+		"From: b@example.com\r\nMessage-ID: <b@example.com>\r\nReferences: <a@example.com>\r\nSubject: Re: Plan\r\n\r\nMiddle\r\n",
+	}
+
+	s := NewStore()
+	for _, raw := range messages {
+		e, err := Parse(strings.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Add(&e)
+	}
+
+	if thread := s.ThreadID("c@example.com"); thread != "a@example.com" {
+		t.Errorf("Wrong thread id. Expected: a@example.com, Got: %s", thread)
+	}
+	if thread := s.Query(StoreQuery{ThreadID: "a@example.com"}); len(thread) != 2 {
+		t.Errorf("Wrong thread. Expected 2 emails, Got: %d", len(thread))
+	}
+}
@@ -0,0 +1,266 @@
+package parsemail
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"golang.org/x/text/transform"
+)
+
+// PartHandler receives the parts of a message parsed by ParseStream in the
+// order they appear. An error returned by a method stops the parse and is
+// returned by ParseStream. Warnings of the parse are added to the email
+// passed to OnHeader while the parts follow.
+type PartHandler interface {
+	// OnHeader is called first with the email built from the message header,
+	// without bodies and files
+	OnHeader(email *Email) error
+	// OnTextPart is called with the text of every text/plain and text/html
+	// part that isn't an attachment, converted to UTF-8
+	OnTextPart(contentType string, header textproto.MIMEHeader, text []byte) error
+	// OnAttachment is called for every other leaf part, including embedded
+	// files. The Data of the attachment streams the decoded content and can
+	// only be read until OnAttachment returns. Parts indexed by
+	// Options.SkipPart have nil Data.
+	OnAttachment(at Attachment, header textproto.MIMEHeader) error
+}
+
+// ParseStream parses a message read from r and passes its parts to handler
+// as they are encountered. Unlike Parse it never holds the message or an
+// attachment in memory, so it handles messages of any size. Only text parts
+// are read completely.
+func ParseStream(r io.Reader, handler PartHandler) error {
+	return NewParser(Options{}).ParseStream(r, handler)
+}
+
+// ParseStream parses a message like the ParseStream function, applying the
+// limits, budgets and SkipPart function of the options like Parse: parts
+// exceeding MaxPartBytes are truncated and the parse stops without an error
+// once MaxMessageBytes or MaxParseTime is exhausted, with a warning each.
+// Malformed nested parts are skipped with a warning unless Strict is set.
+// Options building the whole email, like KeepRaw, Tagger or Differential,
+// don't apply.
+func (ps *Parser) ParseStream(r io.Reader, handler PartHandler) error {
+	version, err := ps.opts.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	msg, err := mail.ReadMessage(bufio.NewReader(r))
+	if err != nil {
+		return err
+	}
+
+	email, err := createEmailFromHeader(msg.Header, version)
+	if err != nil {
+		return err
+	}
+	email.ContentType = msg.Header.Get("Content-Type")
+
+	if err := handler.OnHeader(&email); err != nil {
+		return err
+	}
+
+	s := streamParser{p: parser{email: &email, opts: ps.opts}, handler: handler}
+	body := s.p.startBudget(msg.Body)
+
+	err = s.part(body, textproto.MIMEHeader(msg.Header), 0)
+	if he, ok := err.(*streamHandlerError); ok {
+		return he.err
+	}
+	if s.p.exhausted {
+		// the parts passed before the budget was exhausted are kept
+		return nil
+	}
+
+	return err
+}
+
+// streamParser walks the parts of a message for ParseStream
+type streamParser struct {
+	p       parser
+	handler PartHandler
+}
+
+// streamHandlerError carries an error of the handler up to ParseStream, so
+// it isn't taken for a malformed part
+type streamHandlerError struct {
+	err error
+}
+
+func (e *streamHandlerError) Error() string {
+	return e.err.Error()
+}
+
+// handled wraps an error returned by the handler
+func handled(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &streamHandlerError{err: err}
+}
+
+// part passes the part with the given header and body, and the parts nested
+// in it, to the handler
+func (s *streamParser) part(body io.Reader, header textproto.MIMEHeader, depth int) error {
+	contentType, params, err := parseContentType(header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	contentType = strings.ToLower(contentType)
+
+	disposition, _, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+
+	switch {
+	case strings.HasPrefix(contentType, "multipart/"):
+		if max := s.p.maxDepth(); max > 0 && depth >= max {
+			return &PartLimitError{Option: "MaxDepth", Limit: max}
+		}
+
+		mr := multipart.NewReader(body, params["boundary"])
+		read := false
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				if s.p.exhausted {
+					return err
+				}
+				return s.p.nextPartError(err, read)
+			}
+			read = true
+
+			if err := s.p.countPart(); err != nil {
+				return err
+			}
+
+			if skip, err := s.skip(part); err != nil {
+				return err
+			} else if skip {
+				continue
+			}
+
+			if err := s.recoverPart(s.part(part, part.Header, depth+1)); err != nil {
+				return err
+			}
+		}
+	case (contentType == contentTypeTextPlain || contentType == contentTypeTextHtml) && disposition != "attachment":
+		text, err := s.text(body, header)
+		if err != nil {
+			return err
+		}
+
+		return handled(s.handler.OnTextPart(contentType, header, text))
+	default:
+		return s.attachment(body, header, contentType)
+	}
+}
+
+// recoverPart skips a malformed nested part with a warning like Parse does.
+// Errors of the handler and of an exhausted budget stop the parse.
+func (s *streamParser) recoverPart(err error) error {
+	if _, ok := err.(*streamHandlerError); ok || s.p.exhausted {
+		return err
+	}
+
+	return s.p.recoverPart(err)
+}
+
+// text decodes a text part to UTF-8. A part exceeding the part budget is
+// truncated with a warning.
+func (s *streamParser) text(body io.Reader, header textproto.MIMEHeader) ([]byte, error) {
+	r, encoded, err := s.p.partReader(body, header.Get("Content-Transfer-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+
+	// an exceeded budget is reported by reading the text below
+	br := bufio.NewReaderSize(r, 1024)
+	preview, err := br.Peek(1024)
+	if err != nil && err != io.EOF && err != errBudgetExceeded {
+		return nil, err
+	}
+
+	enc, err := s.p.textEncoding(preview, header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := ioutil.ReadAll(transform.NewReader(br, enc.NewDecoder()))
+	if err = s.p.partError(err, encoded); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(text, []byte("\n")), nil
+}
+
+// attachment passes a leaf part with a streaming reader of its content to
+// the handler
+func (s *streamParser) attachment(body io.Reader, header textproto.MIMEHeader, contentType string) error {
+	at := attachmentInfo(&multipart.Part{Header: header}, s.p.email.SchemaVersion)
+
+	// encapsulated messages are passed on as they are, like Parse does
+	encoding := header.Get("Content-Transfer-Encoding")
+	if contentType == messageRFC822 {
+		encoding = ""
+	}
+
+	data, encoded, err := s.p.partReader(body, encoding)
+	if err != nil {
+		return err
+	}
+	at.Data = &streamPartReader{r: data, p: &s.p, encoded: encoded}
+
+	return handled(s.handler.OnAttachment(at, header))
+}
+
+// streamPartReader ends the content of an attachment where a budget is
+// exhausted, with a warning like Parse truncates it
+type streamPartReader struct {
+	r       io.Reader
+	p       *parser
+	encoded *countingReader
+	err     error
+}
+
+func (r *streamPartReader) Read(b []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.r.Read(b)
+	if err != nil && err != io.EOF {
+		if err = r.p.partError(err, r.encoded); err == nil {
+			err = io.EOF
+		}
+		r.err = err
+	}
+
+	return n, err
+}
+
+// skip applies the SkipPart function of the options to part and reports
+// whether the part is done with
+func (s *streamParser) skip(part *multipart.Part) (bool, error) {
+	if s.p.opts.SkipPart == nil {
+		return false, nil
+	}
+
+	switch s.p.opts.SkipPart(part.Header) {
+	case PartSkip:
+		return true, nil
+	case PartIndex:
+		return true, handled(s.handler.OnAttachment(attachmentInfo(part, s.p.email.SchemaVersion), part.Header))
+	}
+
+	return false, nil
+}
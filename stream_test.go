@@ -0,0 +1,196 @@
+package parsemail
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+type recordingHandler struct {
+	events []string
+	read   func(at Attachment) error
+	email  *Email
+}
+
+func (h *recordingHandler) OnHeader(email *Email) error {
+	h.email = email
+	h.events = append(h.events, "header "+email.Subject)
+	return nil
+}
+
+func (h *recordingHandler) OnTextPart(contentType string, header textproto.MIMEHeader, text []byte) error {
+	h.events = append(h.events, fmt.Sprintf("%s %q", contentType, text))
+	return nil
+}
+
+func (h *recordingHandler) OnAttachment(at Attachment, header textproto.MIMEHeader) error {
+	if h.read != nil {
+		return h.read(at)
+	}
+
+	data := "<nil>"
+	if at.Data != nil {
+		b, err := ioutil.ReadAll(at.Data)
+		if err != nil {
+			return err
+		}
+		data = fmt.Sprintf("%q", b)
+	}
+
+	h.events = append(h.events, fmt.Sprintf("%s %s %s", at.ContentType, at.Filename, data))
+	return nil
+}
+
+func TestParseStream(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		opts     Options
+		events   []string
+	}{
+		1: {
+			mailData: rfc822,
+			events: []string{
+				"header Test 1",
+				`text/plain "test body"`,
+				`text/html "<html><head></head><body>test body</body></html>"`,
+				`message/rfc822 00FE068B62DDEC478BCC3A7E82CBC1CD@firma.local.eml "From: Test Internal <internal@test.lan>` + "\\n",
+			},
+		},
+		2: {
+			mailData: filteredParts,
+			opts: Options{SkipPart: func(header textproto.MIMEHeader) SkipDecision {
+				if strings.HasPrefix(header.Get("Content-Type"), "image/") {
+					return PartSkip
+				}
+				if strings.HasPrefix(header.Get("Content-Type"), "application/x-msdownload") {
+					return PartIndex
+				}
+				return PartDecode
+			}},
+			events: []string{
+				"header Invoice",
+				`text/html "<p>Your invoice <img src=\"cid:logo@example.com\"><img src=\"cid:pixel@example.com\"></p>"`,
+				"application/x-msdownload setup.exe <nil>",
+				`application/pdf invoice.pdf "%PDF-1.4\n"`,
+			},
+		},
+		3: {
+			mailData: "Subject: Latin\nContent-Type: text/plain; charset=iso-8859-1\n\nGr\xfc\xdfe\n",
+			events:   []string{"header Latin", `text/plain "Grüße"`},
+		},
+	}
+
+	for index, td := range testData {
+		h := &recordingHandler{}
+		if err := NewParser(td.opts).ParseStream(strings.NewReader(td.mailData), h); err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		// the encapsulated message is compared by its first line
+		for i, e := range h.events {
+			if strings.HasPrefix(e, "message/rfc822") {
+				h.events[i] = e[:strings.Index(e, `\n`)+2]
+			}
+		}
+
+		if !assertSliceEq(h.events, td.events) {
+			t.Errorf("[Test Case %v] Wrong events. Expected: %q, Got: %q", index, td.events, h.events)
+		}
+	}
+}
+
+func TestParseStreamBudgets(t *testing.T) {
+	const mailData = "Subject: Budget\nContent-Type: multipart/mixed; boundary=B\n\n" +
+		"--B\nContent-Type: text/plain\n\n0123456789\n" +
+		"--B\nContent-Type: application/octet-stream\n\n0123456789\n" +
+		"--B\nContent-Type: text/plain\n\nlast\n--B--\n"
+
+	var testData = map[int]struct {
+		opts     Options
+		events   []string
+		warnings []FindingCode
+	}{
+		1: {
+			opts:     Options{MaxPartBytes: 4},
+			events:   []string{"header Budget", `text/plain "0123"`, `application/octet-stream  "0123"`, `text/plain "last"`},
+			warnings: []FindingCode{FindingPartBudgetExceeded, FindingPartBudgetExceeded, FindingPartBudgetExceeded},
+		},
+		2: {
+			opts:     Options{MaxMessageBytes: 70},
+			events:   []string{"header Budget", `text/plain "0123456789"`},
+			warnings: []FindingCode{FindingMessageBudgetExceeded},
+		},
+		3: {
+			opts:     Options{MaxMessageBytes: 100},
+			events:   []string{"header Budget", `text/plain "0123456789"`, `application/octet-stream  "0123456789"`},
+			warnings: []FindingCode{FindingMessageBudgetExceeded},
+		},
+	}
+
+	for index, td := range testData {
+		h := &recordingHandler{}
+		if err := NewParser(td.opts).ParseStream(strings.NewReader(mailData), h); err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		if !assertSliceEq(h.events, td.events) {
+			t.Errorf("[Test Case %v] Wrong events. Expected: %q, Got: %q", index, td.events, h.events)
+		}
+
+		var warnings []FindingCode
+		for _, w := range h.email.Warnings {
+			warnings = append(warnings, w.Code)
+		}
+		if fmt.Sprint(warnings) != fmt.Sprint(td.warnings) {
+			t.Errorf("[Test Case %v] Wrong warnings. Expected: %v, Got: %v", index, td.warnings, warnings)
+		}
+	}
+}
+
+// countingSource counts the bytes ParseStream consumed
+type countingSource struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingSource) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += n
+	return n, err
+}
+
+func TestParseStreamDoesNotBuffer(t *testing.T) {
+	const size = 8 << 20
+
+	line := base64.StdEncoding.EncodeToString(make([]byte, 57)) + "\n"
+	src := &countingSource{r: io.MultiReader(
+		strings.NewReader("Subject: Big\nContent-Type: multipart/mixed; boundary=B\n\n--B\nContent-Type: application/octet-stream\nContent-Transfer-Encoding: base64\n\n"),
+		strings.NewReader(strings.Repeat(line, size/57)),
+		strings.NewReader("--B--\n"),
+	)}
+
+	h := &recordingHandler{read: func(at Attachment) error {
+		if _, err := io.ReadFull(at.Data, make([]byte, 1024)); err != nil {
+			return err
+		}
+		if src.n > 1<<20 {
+			t.Errorf("Attachment was buffered: %d bytes read before its data", src.n)
+		}
+
+		n, err := io.Copy(ioutil.Discard, at.Data)
+		if n+1024 != size/57*57 {
+			t.Errorf("Wrong attachment size. Expected: %v, Got: %v", size/57*57, n+1024)
+		}
+		return err
+	}}
+
+	if err := ParseStream(src, h); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,113 @@
+package parsemail
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// SubaddressConvention describes how a mail provider delivers addresses with
+// a detail appended to the local part, like "jdoe+newsletter@example.com"
+type SubaddressConvention struct {
+	// Separators start the detail. The first separator found in the local
+	// part is used.
+	Separators []string
+	// IgnoreDots is set for providers ignoring dots in local parts
+	IgnoreDots bool
+}
+
+// SubaddressConventions holds the conventions of well known providers, keyed
+// by domain. The entry with the empty key applies to all other domains.
+// Entries may be added or replaced by the caller, e.g. for the domain of an
+// inbound parse service using "--" or "=" as separator.
+var SubaddressConventions = map[string]SubaddressConvention{
+	"":               {Separators: []string{"+"}},
+	"gmail.com":      {Separators: []string{"+"}, IgnoreDots: true},
+	"googlemail.com": {Separators: []string{"+"}, IgnoreDots: true},
+	"yahoo.com":      {Separators: []string{"-"}},
+	"outlook.com":    {Separators: []string{"+"}},
+	"hotmail.com":    {Separators: []string{"+"}},
+	"fastmail.com":   {Separators: []string{"+"}},
+	"icloud.com":     {Separators: []string{"+"}},
+	"proton.me":      {Separators: []string{"+"}},
+	"protonmail.com": {Separators: []string{"+"}},
+}
+
+// Subaddress is an address split into the mailbox it is delivered to and the
+// detail appended to its local part
+type Subaddress struct {
+	// Address is the address as given
+	Address string
+	// Base is the address without detail, with a lower case domain
+	Base   string
+	Detail string
+}
+
+// RoutingKey returns the detail in lower case, which identifies the ticket,
+// tenant or list an inbound message is routed to. It is empty for addresses
+// without detail.
+func (s Subaddress) RoutingKey() string {
+	return strings.ToLower(s.Detail)
+}
+
+// ParseSubaddress splits an address by the convention of its domain
+func ParseSubaddress(address string) Subaddress {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return Subaddress{Address: address, Base: address}
+	}
+
+	local, domain := address[:at], strings.ToLower(address[at+1:])
+	conv, ok := SubaddressConventions[domain]
+	if !ok {
+		conv = SubaddressConventions[""]
+	}
+
+	return splitSubaddress(address, local, domain, conv)
+}
+
+func splitSubaddress(address, local, domain string, conv SubaddressConvention) Subaddress {
+	s := Subaddress{Address: address}
+
+	// the earliest separator wins, the longer one at the same position
+	cut, sepLen := -1, 0
+	for _, sep := range conv.Separators {
+		if sep == "" {
+			continue
+		}
+		// a separator at the start isn't one, "+1@example.com" has no detail
+		if i := strings.Index(local, sep); i > 0 && (cut < 0 || i < cut || i == cut && len(sep) > sepLen) {
+			cut, sepLen = i, len(sep)
+		}
+	}
+
+	if cut >= 0 {
+		s.Detail = local[cut+sepLen:]
+		local = local[:cut]
+	}
+
+	if conv.IgnoreDots {
+		local = strings.Replace(local, ".", "", -1)
+	}
+	s.Base = local + "@" + domain
+
+	return s
+}
+
+// Subaddresses splits the recipients of the To, Cc and Delivered-To fields,
+// each address once, in that order. Delivered-To carries the address the
+// message was delivered to, including recipients only known to the envelope.
+func (e *Email) Subaddresses() (subaddresses []Subaddress) {
+	seen := map[string]bool{}
+	for _, list := range [][]*mail.Address{e.To, e.Cc, e.deliveredTo()} {
+		for _, a := range list {
+			if a == nil || seen[strings.ToLower(a.Address)] {
+				continue
+			}
+			seen[strings.ToLower(a.Address)] = true
+
+			subaddresses = append(subaddresses, ParseSubaddress(a.Address))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,64 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSubaddress(t *testing.T) {
+	SubaddressConventions["in.example.org"] = SubaddressConvention{Separators: []string{"=", "--"}}
+	defer delete(SubaddressConventions, "in.example.org")
+
+	var testData = map[int]struct {
+		address    string
+		base       string
+		detail     string
+		routingKey string
+	}{
+		1: {"jdoe@example.com", "jdoe@example.com", "", ""},
+		2: {"jdoe+Newsletter@Example.COM", "jdoe@example.com", "Newsletter", "newsletter"},
+		3: {"j.doe+shop+2020@gmail.com", "jdoe@gmail.com", "shop+2020", "shop+2020"},
+		4: {"jdoe-shopping@yahoo.com", "jdoe@yahoo.com", "shopping", "shopping"},
+		5: {"support=ticket-1234@in.example.org", "support@in.example.org", "ticket-1234", "ticket-1234"},
+		6: {"support--tenant-a@in.example.org", "support@in.example.org", "tenant-a", "tenant-a"},
+		7: {"+1555@example.com", "+1555@example.com", "", ""},
+		8: {"first-last@example.com", "first-last@example.com", "", ""},
+	}
+
+	for index, td := range testData {
+		s := ParseSubaddress(td.address)
+
+		if s.Base != td.base {
+			t.Errorf("[Test Case %v] Wrong base. Expected: %s, Got: %s", index, td.base, s.Base)
+		}
+
+		if s.Detail != td.detail {
+			t.Errorf("[Test Case %v] Wrong detail. Expected: %s, Got: %s", index, td.detail, s.Detail)
+		}
+
+		if s.RoutingKey() != td.routingKey {
+			t.Errorf("[Test Case %v] Wrong routing key. Expected: %s, Got: %s", index, td.routingKey, s.RoutingKey())
+		}
+	}
+}
+
+func TestSubaddresses(t *testing.T) {
+	e, err := Parse(strings.NewReader("From: jdoe@example.com\r\n" +
+		"To: Support <support+ticket-42@example.net>, mary@example.net\r\n" +
+		"Cc: support+ticket-42@example.net\r\n" +
+		"Delivered-To: archive+2020@example.net\r\n" +
+		"Subject: Hello\r\n\r\nHello.\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, s := range e.Subaddresses() {
+		keys = append(keys, s.Base+" "+s.RoutingKey())
+	}
+
+	expected := []string{"support@example.net ticket-42", "mary@example.net ", "archive@example.net 2020"}
+	if !assertSliceEq(keys, expected) {
+		t.Errorf("Wrong subaddresses. Expected: %q, Got: %q", expected, keys)
+	}
+}
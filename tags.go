@@ -0,0 +1,170 @@
+package parsemail
+
+import (
+	"net/textproto"
+	"strings"
+)
+
+// TagCondition reports whether a tagging rule applies to an email
+type TagCondition func(e *Email) bool
+
+// FromMatches applies to emails with a From address matching m
+func FromMatches(m AddressMatcher) TagCondition {
+	return func(e *Email) bool {
+		for _, a := range e.From {
+			if a != nil && m(a.Address) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// RecipientMatches applies to emails with a recipient matching m, taken
+// from Delivered-To, To and Cc like the recipients of a Router
+func RecipientMatches(m AddressMatcher) TagCondition {
+	return func(e *Email) bool {
+		for _, a := range e.routingRecipients() {
+			if m(a) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// SubjectContains applies to emails whose subject contains s, ignoring case
+func SubjectContains(s string) TagCondition {
+	s = strings.ToLower(s)
+	return func(e *Email) bool {
+		return strings.Contains(strings.ToLower(e.Subject), s)
+	}
+}
+
+// HeaderContains applies to emails with a header field name whose decoded
+// value contains s, ignoring case. An empty s matches any field named name.
+func HeaderContains(name, s string) TagCondition {
+	name, s = textproto.CanonicalMIMEHeaderKey(name), strings.ToLower(s)
+	return func(e *Email) bool {
+		for _, v := range e.Header[name] {
+			if strings.Contains(strings.ToLower(v), s) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// HasAttachment applies to emails with an attachment whose content type
+// matches one of the patterns, like "application/pdf" or "image/*", or with
+// any attachment if there are no patterns
+func HasAttachment(patterns ...string) TagCondition {
+	return func(e *Email) bool {
+		for _, a := range e.Attachments {
+			if len(patterns) == 0 || matchContentType(a.ContentType, patterns) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// AllOf applies when all conditions apply
+func AllOf(conditions ...TagCondition) TagCondition {
+	return func(e *Email) bool {
+		for _, c := range conditions {
+			if !c(e) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// AnyOf applies when one of the conditions applies
+func AnyOf(conditions ...TagCondition) TagCondition {
+	return func(e *Email) bool {
+		for _, c := range conditions {
+			if c(e) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Not applies when the condition doesn't
+func Not(c TagCondition) TagCondition {
+	return func(e *Email) bool {
+		return !c(e)
+	}
+}
+
+// Tagger attaches tags to emails by rules, like the filters of a mail
+// server. Set it as the Tagger of the options to tag emails while they are
+// parsed.
+type Tagger struct {
+	rules []tagRule
+}
+
+type tagRule struct {
+	match TagCondition
+	tags  []string
+}
+
+// NewTagger returns a Tagger without rules
+func NewTagger() *Tagger {
+	return &Tagger{}
+}
+
+// Add appends a rule adding the tags to the emails the condition applies
+// to. A tag prefixed with "-" removes the tag added by an earlier rule
+// instead, like notmuch does. Rules apply in the order they are added.
+func (t *Tagger) Add(match TagCondition, tags ...string) {
+	t.rules = append(t.rules, tagRule{match: match, tags: tags})
+}
+
+// Tags returns the tags of the email in the order they were first added
+func (t *Tagger) Tags(e *Email) (tags []string) {
+	for _, r := range t.rules {
+		if !r.match(e) {
+			continue
+		}
+
+		for _, tag := range r.tags {
+			if strings.HasPrefix(tag, "-") {
+				tags = removeTag(tags, tag[1:])
+			} else if !hasTag(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeTag(tags []string, tag string) []string {
+	for i, t := range tags {
+		if t == tag {
+			return append(tags[:i], tags[i+1:]...)
+		}
+	}
+
+	return tags
+}
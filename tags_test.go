@@ -0,0 +1,47 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagger(t *testing.T) {
+	tagger := NewTagger()
+	tagger.Add(RecipientMatches(MatchExact("dev@lists.example.org")), "lists", "dev")
+	tagger.Add(AllOf(FromMatches(MatchDomain("example.com")), HasAttachment("application/pdf")), "invoice")
+	tagger.Add(AnyOf(SubjectContains("[urgent]"), HeaderContains("Importance", "high")), "urgent")
+	tagger.Add(HeaderContains("List-Unsubscribe", ""), "bulk")
+	tagger.Add(AllOf(HeaderContains("List-Unsubscribe", ""), Not(FromMatches(MatchDomain("example.com")))), "-dev")
+
+	var testData = map[int]struct {
+		raw  string
+		tags []string
+	}{
+		1: {
+			raw:  "From: a@example.com\r\nTo: dev@lists.example.org\r\nSubject: Build\r\nList-Unsubscribe: <mailto:leave@lists.example.org>\r\n\r\nGreen\r\n",
+			tags: []string{"lists", "dev", "bulk"},
+		},
+		2: {
+			raw:  "From: b@other.org\r\nTo: Dev <DEV+build@lists.example.org>\r\nSubject: [URGENT] Build\r\nList-Unsubscribe: <mailto:leave@lists.example.org>\r\n\r\nRed\r\n",
+			tags: []string{"lists", "urgent", "bulk"},
+		},
+		3: {
+			raw: "From: billing@example.com\r\nTo: me@example.net\r\nImportance: High\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nAttached\r\n" +
+				"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=invoice.pdf\r\n\r\n%PDF\r\n--b--\r\n",
+			tags: []string{"invoice", "urgent"},
+		},
+		4: {raw: "From: c@example.net\r\nTo: me@example.net\r\nSubject: Hi\r\n\r\nHi\r\n"},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(td.raw), Options{Tagger: tagger})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if !assertSliceEq(e.Tags, td.tags) {
+			t.Errorf("[Test Case %v] Wrong tags. Expected: %s, Got: %s", index, td.tags, e.Tags)
+		}
+	}
+}
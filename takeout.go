@@ -0,0 +1,141 @@
+package parsemail
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// GmailLabels returns the labels of a message exported by Google Takeout,
+// read from the X-Gmail-Labels header. Labels containing commas are quoted in
+// the header and returned unquoted.
+func (e *Email) GmailLabels() []string {
+	var labels []string
+
+	for _, h := range e.Header["X-Gmail-Labels"] {
+		for _, l := range splitQuoted(h, ',') {
+			l = strings.TrimSpace(l)
+			if len(l) >= 2 && l[0] == '"' && l[len(l)-1] == '"' {
+				l = strings.Replace(l[1:len(l)-1], `\"`, `"`, -1)
+			}
+			if l != "" {
+				labels = append(labels, l)
+			}
+		}
+	}
+
+	return labels
+}
+
+// GmailThreadID returns the Gmail thread id of a Takeout message
+func (e *Email) GmailThreadID() string {
+	return strings.TrimSpace(e.Header.Get("X-GM-THRID"))
+}
+
+// IsGmailChat reports whether the message is a chat conversation exported by
+// Google Takeout, which carries the "Chat" label
+func (e *Email) IsGmailChat() bool {
+	for _, l := range e.GmailLabels() {
+		if l == "Chat" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ChatMessage is a single line of a Gmail chat conversation
+type ChatMessage struct {
+	From string
+	Body string
+}
+
+// ChatMessages returns the lines of a Gmail chat conversation, stored by
+// Takeout as XML in the body. It returns nil for other messages.
+func (e *Email) ChatMessages() []ChatMessage {
+	if !e.IsGmailChat() {
+		return nil
+	}
+
+	content := e.TextBody
+	if !strings.Contains(content, "<") {
+		content = e.HTMLBody
+	}
+	if content == "" && e.Content != nil {
+		b, err := rewindData(&e.Content)
+		if err != nil {
+			return nil
+		}
+		content = string(b)
+	}
+
+	var messages []ChatMessage
+	var current *ChatMessage
+	inBody := false
+
+	d := xml.NewDecoder(strings.NewReader(content))
+	d.Strict = false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "message":
+				messages = append(messages, ChatMessage{})
+				current = &messages[len(messages)-1]
+				for _, a := range t.Attr {
+					if a.Name.Local == "from" {
+						current.From = chatAddress(a.Value)
+					}
+				}
+			case "body":
+				inBody = current != nil
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "message":
+				current = nil
+			case "body":
+				inBody = false
+			}
+		case xml.CharData:
+			if inBody {
+				current.Body += string(t)
+			}
+		}
+	}
+
+	return messages
+}
+
+// chatAddress strips the resource from a Jabber id
+func chatAddress(jid string) string {
+	if i := strings.Index(jid, "/"); i >= 0 {
+		return jid[:i]
+	}
+
+	return jid
+}
+
+// splitQuoted splits s at sep outside of double quotes
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
@@ -0,0 +1,53 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGmailLabels(t *testing.T) {
+	mr := NewMboxReader(strings.NewReader(takeoutMbox), Options{})
+
+	mail, err := mr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Inbox", "Important", "Work, Projects", "Category Updates"}
+	if !assertSliceEq(mail.GmailLabels(), expected) {
+		t.Errorf("Wrong labels. Expected: %q, Got: %q", expected, mail.GmailLabels())
+	}
+
+	if mail.GmailThreadID() != "1683529148731234567" {
+		t.Errorf("Wrong thread id: %s", mail.GmailThreadID())
+	}
+
+	if mail.IsGmailChat() || mail.ChatMessages() != nil {
+		t.Errorf("Expected no chat")
+	}
+
+	chat, err := mr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !chat.IsGmailChat() {
+		t.Fatalf("Expected a chat")
+	}
+
+	messages := chat.ChatMessages()
+	expectedMessages := []ChatMessage{
+		{From: "bob@gmail.com", Body: "hey there"},
+		{From: "alice@gmail.com", Body: "hi Bob & co"},
+	}
+
+	if len(messages) != len(expectedMessages) {
+		t.Fatalf("Wrong number of chat messages. Expected: %d, Got: %d", len(expectedMessages), len(messages))
+	}
+
+	for i := range messages {
+		if messages[i] != expectedMessages[i] {
+			t.Errorf("[Test Case %v] Wrong chat message. Expected: %+v, Got: %+v", i, expectedMessages[i], messages[i])
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package parsemail
+
+import (
+	"io"
+	"net/mail"
+	"time"
+)
+
+// TemplateData is a representation of an Email without io.Reader fields that
+// can be passed to html/template and text/template. HTMLBody is a plain
+// string and therefore escaped by html/template unless the caller sanitizes
+// and converts it.
+type TemplateData struct {
+	Header map[string][]string
+
+	Subject    string
+	Sender     *mail.Address
+	From       []mail.Address
+	ReplyTo    []mail.Address
+	To         []mail.Address
+	Cc         []mail.Address
+	Bcc        []mail.Address
+	Date       time.Time
+	MessageID  string
+	InReplyTo  []string
+	References []string
+
+	ContentType string
+	HTMLBody    string
+	TextBody    string
+
+	Attachments   []TemplateFile
+	EmbeddedFiles []TemplateFile
+}
+
+// TemplateFile describes an attachment or embedded file. Size is -1 when it
+// can't be determined without consuming the data.
+type TemplateFile struct {
+	Filename    string
+	CID         string
+	ContentType string
+	Size        int
+}
+
+// TemplateData returns the decoded headers, bodies and attachment metadata
+// of the email for template rendering.
+func (e *Email) TemplateData() TemplateData {
+	td := TemplateData{
+		Header:      map[string][]string(e.Header),
+		Subject:     e.Subject,
+		Sender:      e.Sender,
+		From:        dereferenceAddresses(e.From),
+		ReplyTo:     dereferenceAddresses(e.ReplyTo),
+		To:          dereferenceAddresses(e.To),
+		Cc:          dereferenceAddresses(e.Cc),
+		Bcc:         dereferenceAddresses(e.Bcc),
+		Date:        e.Date,
+		MessageID:   e.MessageID,
+		InReplyTo:   e.InReplyTo,
+		References:  e.References,
+		ContentType: e.ContentType,
+		HTMLBody:    e.HTMLBody,
+		TextBody:    e.TextBody,
+	}
+
+	for _, a := range e.Attachments {
+		td.Attachments = append(td.Attachments, TemplateFile{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Size:        readerLen(a.Data),
+		})
+	}
+
+	for _, ef := range e.EmbeddedFiles {
+		td.EmbeddedFiles = append(td.EmbeddedFiles, TemplateFile{
+			CID:         ef.CID,
+			ContentType: ef.ContentType,
+			Size:        readerLen(ef.Data),
+		})
+	}
+
+	return td
+}
+
+func dereferenceAddresses(al []*mail.Address) (result []mail.Address) {
+	for _, a := range al {
+		if a != nil {
+			result = append(result, *a)
+		}
+	}
+
+	return
+}
+
+// readerLen returns the number of unread bytes of r, or -1 if r doesn't
+// report it.
+func readerLen(r io.Reader) int {
+	if l, ok := r.(interface{ Len() int }); ok {
+		return l.Len()
+	}
+
+	return -1
+}
@@ -0,0 +1,57 @@
+package parsemail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+	texttemplate "text/template"
+)
+
+func TestTemplateData(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		template string
+		expected string
+	}{
+		1: {
+			mailData: rfc5322exampleA11,
+			template: `{{.Subject}} from {{(index .From 0).Name}} <{{(index .From 0).Address}}> on {{.Date.Format "2006-01-02"}}`,
+			expected: `Saying Hello from John Doe <jdoe@machine.example> on 1997-11-21`,
+		},
+		2: {
+			mailData: attachment7bit,
+			template: `{{range .Attachments}}{{.Filename}} {{.ContentType}} {{.Size}};{{end}}`,
+			expected: `unencoded.csv application/csv 73;`,
+		},
+		3: {
+			mailData: rfc5322exampleA12,
+			template: `{{index .Header.Date 0}}|{{range .Cc}}{{.Address}},{{end}}`,
+			expected: `Tue, 1 Jul 2003 10:52:37 +0200|boss@nil.test,sysservices@example.net,`,
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		data := e.TemplateData()
+
+		var buf bytes.Buffer
+		tt := texttemplate.Must(texttemplate.New("test").Parse(td.template))
+		if err := tt.Execute(&buf, data); err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+		} else if buf.String() != td.expected {
+			t.Errorf("[Test Case %v] Wrong output. Expected: %q, Got: %q", index, td.expected, buf.String())
+		}
+
+		buf.Reset()
+		ht := htmltemplate.Must(htmltemplate.New("test").Parse(`<p>{{.Subject}}</p>` + td.template))
+		if err := ht.Execute(&buf, data); err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+		}
+	}
+}
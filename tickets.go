@@ -0,0 +1,69 @@
+package parsemail
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TicketPatterns match the ticket and issue references of helpdesks and
+// issue trackers: bracketed keys like "[TICKET-1234]", numbers like "#5678"
+// and phrases like "Case 00123". The first submatch of a pattern is the
+// reference. Entries may be added or replaced by the caller.
+var TicketPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\[#?([A-Za-z][A-Za-z0-9_]*-[0-9]+)\]`),
+	regexp.MustCompile(`(?:^|[^&\w])#([0-9]{2,})\b`),
+	regexp.MustCompile(`(?i)\b(?:ticket|case|issue|request)(?:\s+(?:no\.?|number|id))?(?:\s*[:#]\s*|\s+)([A-Za-z]*-?[0-9]{3,})\b`),
+}
+
+// TicketReferences returns the normalized ticket references found in the
+// subject, the message IDs of In-Reply-To and References, which helpdesks
+// often build from the ticket, and the bodies, in that order and each once.
+// Keys are upper cased and plain numbers are prefixed with "#". The
+// TicketPatterns are used unless patterns are given.
+func (e *Email) TicketReferences(patterns ...*regexp.Regexp) (refs []string) {
+	if len(patterns) == 0 {
+		patterns = TicketPatterns
+	}
+
+	texts := []string{e.Subject}
+	texts = append(texts, e.InReplyTo...)
+	texts = append(texts, e.References...)
+	texts = append(texts, e.TextBody)
+	if e.HTMLBody != "" {
+		texts = append(texts, htmlToText(e.HTMLBody))
+	}
+
+	seen := map[string]bool{}
+	for _, text := range texts {
+		for _, re := range patterns {
+			for _, m := range re.FindAllStringSubmatch(text, -1) {
+				ref := m[0]
+				if len(m) > 1 {
+					ref = m[1]
+				}
+
+				ref = normalizeTicket(ref)
+				if ref != "" && !seen[ref] {
+					seen[ref] = true
+					refs = append(refs, ref)
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// normalizeTicket upper cases a reference and prefixes numbers with "#"
+func normalizeTicket(ref string) string {
+	ref = strings.ToUpper(strings.Trim(ref, "[]#-: \t"))
+	if ref == "" {
+		return ""
+	}
+
+	if strings.Trim(ref, "0123456789") == "" {
+		return "#" + ref
+	}
+
+	return ref
+}
@@ -0,0 +1,43 @@
+package parsemail
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestTicketReferences(t *testing.T) {
+	var testData = map[int]struct {
+		email    Email
+		patterns []*regexp.Regexp
+		expected []string
+	}{
+		1: {
+			email:    Email{Subject: "Hello", TextBody: "No references here, see page 12 & chapter 3."},
+			expected: nil,
+		},
+		2: {
+			email:    Email{Subject: "Re: [ticket-1234] Printer broken", TextBody: "Still broken, see also #5678 and [TICKET-1234]."},
+			expected: []string{"TICKET-1234", "#5678"},
+		},
+		3: {
+			email: Email{
+				Subject:    "Re: Your request",
+				References: []string{"ticket#00123.4f2a@helpdesk.example.com"},
+				HTMLBody:   "<p>Regarding Case Number: 00123 &#169; and issue #77</p>",
+			},
+			expected: []string{"#00123", "#77"},
+		},
+		4: {
+			email:    Email{Subject: "SR-99 and [OPS-1]"},
+			patterns: []*regexp.Regexp{regexp.MustCompile(`\bSR-([0-9]+)\b`)},
+			expected: []string{"#99"},
+		},
+	}
+
+	for index, td := range testData {
+		refs := td.email.TicketReferences(td.patterns...)
+		if !assertSliceEq(refs, td.expected) {
+			t.Errorf("[Test Case %v] Wrong references. Expected: %v, Got: %v", index, td.expected, refs)
+		}
+	}
+}
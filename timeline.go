@@ -0,0 +1,82 @@
+package parsemail
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	FindingMissingReceived    FindingCode = "missing-received"
+	FindingReceivedOutOfOrder FindingCode = "received-out-of-order"
+	FindingDateInFuture       FindingCode = "date-in-future"
+	FindingDateInPast         FindingCode = "date-in-past"
+)
+
+// CheckTimeline compares the Date header with the timestamps of the Received
+// chain and reports missing chains, hops in non-monotonic order and dates
+// that are further than maxSkew away from the time the message was relayed.
+func (e *Email) CheckTimeline(maxSkew time.Duration) (findings []Finding) {
+	received := e.Header["Received"]
+	if len(received) == 0 {
+		return append(findings, Finding{
+			Code:    FindingMissingReceived,
+			Message: "message has no Received headers",
+		})
+	}
+
+	// Received headers are prepended by every hop, so walk them oldest first.
+	var hops []time.Time
+	for i := len(received) - 1; i >= 0; i-- {
+		t, ok := parseReceivedTime(received[i])
+		if !ok {
+			continue
+		}
+
+		if len(hops) > 0 && t.Before(hops[len(hops)-1].Add(-maxSkew)) {
+			findings = append(findings, Finding{
+				Code:    FindingReceivedOutOfOrder,
+				Message: fmt.Sprintf("Received hop at %v is earlier than the previous hop at %v", t, hops[len(hops)-1]),
+			})
+		}
+
+		hops = append(hops, t)
+	}
+
+	if len(hops) == 0 || e.Date.IsZero() {
+		return
+	}
+
+	if first := hops[0]; e.Date.After(first.Add(maxSkew)) {
+		findings = append(findings, Finding{
+			Code:    FindingDateInFuture,
+			Message: fmt.Sprintf("Date %v is after the first Received hop at %v", e.Date, first),
+		})
+	}
+
+	if last := hops[len(hops)-1]; e.Date.Before(last.Add(-maxSkew)) {
+		findings = append(findings, Finding{
+			Code:    FindingDateInPast,
+			Message: fmt.Sprintf("Date %v is before the last Received hop at %v", e.Date, last),
+		})
+	}
+
+	return
+}
+
+// parseReceivedTime extracts the date-time that follows the last semicolon
+// of a Received header value.
+func parseReceivedTime(received string) (time.Time, bool) {
+	i := strings.LastIndex(received, ";")
+	if i < 0 {
+		return time.Time{}, false
+	}
+
+	t, err := mail.ParseDate(strings.TrimSpace(received[i+1:]))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -0,0 +1,73 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckTimeline(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		maxSkew  time.Duration
+		codes    []FindingCode
+	}{
+		1: {
+			mailData: rfc5322exampleA4,
+			maxSkew:  time.Hour,
+		},
+		2: {
+			mailData: rfc5322exampleA11,
+			maxSkew:  time.Hour,
+			codes:    []FindingCode{FindingMissingReceived},
+		},
+		3: {
+			mailData: timelineOutOfOrder,
+			maxSkew:  time.Hour,
+			codes:    []FindingCode{FindingReceivedOutOfOrder},
+		},
+		4: {
+			mailData: timelineFutureDate,
+			maxSkew:  time.Hour,
+			codes:    []FindingCode{FindingDateInFuture},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		findings := e.CheckTimeline(td.maxSkew)
+		if len(findings) != len(td.codes) {
+			t.Errorf("[Test Case %v] Wrong number of findings. Expected: %v, Got: %v", index, td.codes, findings)
+			continue
+		}
+
+		for i, f := range findings {
+			if f.Code != td.codes[i] {
+				t.Errorf("[Test Case %v] Wrong finding. Expected: %s, Got: %s", index, td.codes[i], f.Code)
+			}
+		}
+	}
+}
+
+var timelineOutOfOrder = `Received: from x.y.test by example.net; 21 Nov 1997 08:05:43 -0600
+Received: from node.example by x.y.test; 21 Nov 1997 10:01:22 -0600
+From: John Doe <jdoe@node.example>
+To: Mary Smith <mary@example.net>
+Subject: Saying Hello
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+
+Hello.`
+
+var timelineFutureDate = `Received: from x.y.test by example.net; 21 Nov 1997 10:05:43 -0600
+Received: from node.example by x.y.test; 21 Nov 1997 10:01:22 -0600
+From: John Doe <jdoe@node.example>
+To: Mary Smith <mary@example.net>
+Subject: Saying Hello
+Date: Sat, 22 Nov 1997 09:55:06 -0600
+
+Hello.`
@@ -0,0 +1,187 @@
+package parsemail
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// TranscriptFormat selects the output format of WriteTranscript
+type TranscriptFormat int
+
+const (
+	TranscriptText TranscriptFormat = iota
+	TranscriptHTML
+)
+
+const transcriptTextTemplate = `{{range $i, $m := .Messages}}{{if $i}}
+----------------------------------------
+{{end}}From: {{$m.From}}
+To: {{$m.To}}{{if $m.Cc}}
+Cc: {{$m.Cc}}{{end}}
+Date: {{$m.Date}}
+Subject: {{$m.Subject}}
+
+{{$m.Body}}
+{{end}}{{if .Attachments}}
+Attachments:
+{{range .Attachments}}- [{{.Message}}] {{.Filename}} ({{.ContentType}}{{if ge .Size 0}}, {{.Size}} bytes{{end}})
+{{end}}{{end}}`
+
+const transcriptHTMLTemplate = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Subject}}</title></head><body>
+{{range .Messages}}<div class="message">
+<dl>
+<dt>From</dt><dd>{{.From}}</dd>
+<dt>To</dt><dd>{{.To}}</dd>{{if .Cc}}
+<dt>Cc</dt><dd>{{.Cc}}</dd>{{end}}
+<dt>Date</dt><dd>{{.Date}}</dd>
+<dt>Subject</dt><dd>{{.Subject}}</dd>
+</dl>
+<pre>{{.Body}}</pre>
+</div>
+{{end}}{{if .Attachments}}<h2>Attachments</h2>
+<ul>
+{{range .Attachments}}<li>[{{.Message}}] {{.Filename}} ({{.ContentType}}{{if ge .Size 0}}, {{.Size}} bytes{{end}})</li>
+{{end}}</ul>
+{{end}}</body></html>
+`
+
+var (
+	transcriptText = texttemplate.Must(texttemplate.New("transcript").Parse(transcriptTextTemplate))
+	transcriptHTML = htmltemplate.Must(htmltemplate.New("transcript").Parse(transcriptHTMLTemplate))
+)
+
+type transcript struct {
+	Subject     string
+	Messages    []transcriptMessage
+	Attachments []transcriptAttachment
+}
+
+type transcriptMessage struct {
+	From    string
+	To      string
+	Cc      string
+	Date    string
+	Subject string
+	Body    string
+}
+
+type transcriptAttachment struct {
+	Message     int
+	Filename    string
+	ContentType string
+	Size        int
+}
+
+// WriteTranscript renders a thread of emails as a single chronological
+// transcript. Quoted text of earlier messages is removed from the bodies and
+// the attachments of all messages are listed in a manifest at the end.
+func WriteTranscript(w io.Writer, thread []Email, format TranscriptFormat) error {
+	messages := make([]Email, len(thread))
+	copy(messages, thread)
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Date.Before(messages[j].Date)
+	})
+
+	var t transcript
+	for i, e := range messages {
+		if t.Subject == "" {
+			t.Subject = e.Subject
+		}
+
+		body := e.TextBody
+		if body == "" && e.HTMLBody != "" {
+			body = htmlToText(e.HTMLBody)
+		}
+
+		t.Messages = append(t.Messages, transcriptMessage{
+			From:    DisplayAddressList([]AddressGroup{{Addresses: e.From}}),
+			To:      DisplayAddressList([]AddressGroup{{Addresses: e.To}}),
+			Cc:      DisplayAddressList([]AddressGroup{{Addresses: e.Cc}}),
+			Date:    e.Date.Format(time.RFC1123Z),
+			Subject: e.Subject,
+			Body:    stripQuotedText(body),
+		})
+
+		for _, a := range e.Attachments {
+			t.Attachments = append(t.Attachments, transcriptAttachment{
+				Message:     i + 1,
+				Filename:    a.Filename,
+				ContentType: a.ContentType,
+				Size:        readerLen(a.Data),
+			})
+		}
+	}
+
+	if format == TranscriptHTML {
+		return transcriptHTML.Execute(w, t)
+	}
+
+	return transcriptText.Execute(w, t)
+}
+
+// stripQuotedText removes "> " quoted lines together with the attribution
+// line ("On ... wrote:") introducing them.
+func stripQuotedText(body string) string {
+	lines := strings.Split(body, "\n")
+	result := make([]string, 0, len(lines))
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+
+		if strings.HasSuffix(trimmed, "wrote:") && i+1 < len(lines) &&
+			strings.HasPrefix(strings.TrimSpace(lines[i+1]), ">") {
+			continue
+		}
+
+		result = append(result, line)
+	}
+
+	return strings.TrimSpace(strings.Join(result, "\n"))
+}
+
+// htmlToText returns the text content of an html document, skipping scripts
+// and styles.
+func htmlToText(s string) string {
+	var sb strings.Builder
+	skip := 0
+
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(sb.String())
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style":
+				skip++
+			case "br", "p", "div", "tr", "li":
+				sb.WriteString("\n")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style":
+				if skip > 0 {
+					skip--
+				}
+			case "p", "div":
+				sb.WriteString("\n")
+			}
+		case html.TextToken:
+			if skip == 0 {
+				sb.Write(z.Text())
+			}
+		}
+	}
+}
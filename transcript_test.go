@@ -0,0 +1,94 @@
+package parsemail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTranscript(t *testing.T) {
+	var thread []Email
+	for _, data := range []string{rfc5322exampleA2b, rfc5322exampleA11, transcriptQuotedReply, attachment7bit} {
+		e, err := Parse(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		thread = append(thread, e)
+	}
+
+	var testData = map[int]struct {
+		format   TranscriptFormat
+		contains []string
+		excludes []string
+	}{
+		1: {
+			format: TranscriptText,
+			contains: []string{
+				"From: John Doe <jdoe@machine.example>",
+				"This is a message just to say hello.",
+				"This is my answer.",
+				`Cc: "Doe, Jane" <jane@machine.example>`,
+				"Attachments:\n- [",
+			},
+			excludes: []string{"> quoted hello", "wrote:"},
+		},
+		2: {
+			format: TranscriptHTML,
+			contains: []string{
+				"<title>Saying Hello</title>",
+				"<dd>John Doe &lt;jdoe@machine.example&gt;</dd>",
+				"<h2>Attachments</h2>",
+			},
+			excludes: []string{"> quoted hello"},
+		},
+	}
+
+	for index, td := range testData {
+		var buf bytes.Buffer
+		if err := WriteTranscript(&buf, thread, td.format); err != nil {
+			t.Errorf("[Test Case %v] %v", index, err)
+			continue
+		}
+
+		out := buf.String()
+		for _, s := range td.contains {
+			if !strings.Contains(out, s) {
+				t.Errorf("[Test Case %v] Transcript does not contain %q:\n%s", index, s, out)
+			}
+		}
+
+		for _, s := range td.excludes {
+			if strings.Contains(out, s) {
+				t.Errorf("[Test Case %v] Transcript should not contain %q:\n%s", index, s, out)
+			}
+		}
+	}
+
+	first := strings.Index(mustTranscript(t, thread), "This is a message just to say hello.")
+	last := strings.Index(mustTranscript(t, thread), "This is a reply to your reply.")
+	if first < 0 || last < 0 || first > last {
+		t.Errorf("Transcript is not in chronological order")
+	}
+}
+
+func mustTranscript(t *testing.T, thread []Email) string {
+	var buf bytes.Buffer
+	if err := WriteTranscript(&buf, thread, TranscriptText); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+var transcriptQuotedReply = `From: Mary Smith <mary@example.net>
+To: John Doe <jdoe@machine.example>
+Cc: "Doe, Jane" <jane@machine.example>
+Subject: Re: Saying Hello
+Date: Fri, 21 Nov 1997 10:30:00 -0600
+
+This is my answer.
+
+On Fri, 21 Nov 1997, John Doe wrote:
+> quoted hello
+`
@@ -0,0 +1,84 @@
+package parsemail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncateBody cuts body to at most max bytes without splitting a UTF-8
+// sequence. It reports whether body was cut.
+func truncateBody(body *string, max int) bool {
+	if max <= 0 || len(*body) <= max {
+		return false
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart((*body)[cut]) {
+		cut--
+	}
+	*body = (*body)[:cut]
+
+	return true
+}
+
+// TextBodyRemainder returns the part of the text body cut off by the
+// MaxBodyLength option. The message is decoded again to produce it, so the
+// remainder isn't held in memory until it is needed.
+func (e *Email) TextBodyRemainder() (io.Reader, error) {
+	if !e.TextBodyTruncated {
+		return strings.NewReader(""), nil
+	}
+
+	full, err := e.untruncated()
+	if err != nil {
+		return nil, err
+	}
+
+	return bodyRemainder(full.TextBody, e.TextBody)
+}
+
+// HTMLBodyRemainder returns the part of the html body cut off by the
+// MaxBodyLength option, see TextBodyRemainder.
+func (e *Email) HTMLBodyRemainder() (io.Reader, error) {
+	if !e.HTMLBodyTruncated {
+		return strings.NewReader(""), nil
+	}
+
+	full, err := e.untruncated()
+	if err != nil {
+		return nil, err
+	}
+
+	return bodyRemainder(full.HTMLBody, e.HTMLBody)
+}
+
+// bodyRemainder returns the part of the full body following the truncated
+// one
+func bodyRemainder(full, truncated string) (io.Reader, error) {
+	if !strings.HasPrefix(full, truncated) {
+		return nil, errors.New("the message doesn't yield the truncated body")
+	}
+
+	return strings.NewReader(full[len(truncated):]), nil
+}
+
+// untruncated parses the original message again without body length limit.
+// The time and size budgets are lifted too, so the parse can't stop before
+// the end of the truncated body.
+func (e *Email) untruncated() (Email, error) {
+	opts := e.opts
+	opts.MaxBodyLength = 0
+	opts.MaxPartBytes = 0
+	opts.MaxMessageBytes = 0
+	opts.MaxParseTime = 0
+
+	raw, err := e.rawMessage()
+	if err != nil {
+		return Email{}, err
+	}
+
+	return ParseWithOptions(bytes.NewReader(raw), opts)
+}
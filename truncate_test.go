@@ -0,0 +1,122 @@
+package parsemail
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodyLength(t *testing.T) {
+	var testData = map[int]struct {
+		max           int
+		textBody      string
+		textRemainder string
+		htmlBody      string
+		htmlRemainder string
+	}{
+		1: {0, "Grüße aus Köln", "", "<p>Grüße aus Köln</p>", ""},
+		2: {100, "Grüße aus Köln", "", "<p>Grüße aus Köln</p>", ""},
+		3: {4, "Grü", "ße aus Köln", "<p>G", "rüße aus Köln</p>"},
+		4: {5, "Grü", "ße aus Köln", "<p>Gr", "üße aus Köln</p>"},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(truncatedBodies), Options{MaxBodyLength: td.max, KeepRaw: true})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if e.TextBody != td.textBody || e.TextBodyTruncated != (td.textRemainder != "") {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q (%v)", index, td.textBody, e.TextBody, e.TextBodyTruncated)
+		}
+
+		if e.HTMLBody != td.htmlBody || e.HTMLBodyTruncated != (td.htmlRemainder != "") {
+			t.Errorf("[Test Case %v] Wrong html body. Expected: %q, Got: %q (%v)", index, td.htmlBody, e.HTMLBody, e.HTMLBodyTruncated)
+		}
+
+		r, err := e.TextBodyRemainder()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if b, _ := ioutil.ReadAll(r); string(b) != td.textRemainder {
+			t.Errorf("[Test Case %v] Wrong text remainder. Expected: %q, Got: %q", index, td.textRemainder, b)
+		}
+
+		r, err = e.HTMLBodyRemainder()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if b, _ := ioutil.ReadAll(r); string(b) != td.htmlRemainder {
+			t.Errorf("[Test Case %v] Wrong html remainder. Expected: %q, Got: %q", index, td.htmlRemainder, b)
+		}
+	}
+}
+
+var truncatedBodies = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Greetings
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Content-Type: multipart/alternative; boundary="ALT"
+
+--ALT
+Content-Type: text/plain; charset=UTF-8
+
+Grüße aus Köln
+--ALT
+Content-Type: text/html; charset=UTF-8
+
+<p>Grüße aus Köln</p>
+--ALT--
+`
+
+func TestMaxBodyLengthFormData(t *testing.T) {
+	raw := "From: a@example.com\r\nContent-Type: multipart/form-data; boundary=\"FORM\"\r\n\r\n"
+	for _, field := range []string{"ab", "cd", "ef", "gh"} {
+		raw += "--FORM\r\nContent-Disposition: form-data; name=\"" + field + "\"\r\n\r\n" + field + "\r\n"
+	}
+	raw += "--FORM--\r\n"
+
+	var testData = map[int]struct {
+		max      int
+		textBody string
+	}{
+		1: {0, "ab\ncd\nef\ngh"},
+		2: {4, "ab\nc"},
+		3: {5, "ab\ncd"},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(raw), Options{MaxBodyLength: td.max})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if e.TextBody != td.textBody || e.TextBodyTruncated != (td.max > 0) {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q (%v)", index, td.textBody, e.TextBody, e.TextBodyTruncated)
+		}
+	}
+}
+
+func TestBodyRemainderMismatch(t *testing.T) {
+	e, err := ParseWithOptions(strings.NewReader(truncatedBodies), Options{MaxBodyLength: 4, KeepRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a body longer than the one the message yields must not panic
+	e.TextBody += strings.Repeat("x", 100)
+	if _, err := e.TextBodyRemainder(); err == nil {
+		t.Error("Expected an error for a text body the message doesn't yield")
+	}
+
+	e.HTMLBody = "<div>"
+	if _, err := e.HTMLBodyRemainder(); err == nil {
+		t.Error("Expected an error for an html body the message doesn't yield")
+	}
+}
@@ -0,0 +1,170 @@
+package parsemail
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// UnsubscribeMethod is the way an unsubscribe action is carried out
+type UnsubscribeMethod string
+
+const (
+	// UnsubscribeOneClick is an https URL to POST "List-Unsubscribe=One-Click"
+	// to, as defined in RFC 8058
+	UnsubscribeOneClick UnsubscribeMethod = "one-click"
+	// UnsubscribeMailto is a mailto URI to send a message to
+	UnsubscribeMailto UnsubscribeMethod = "mailto"
+	// UnsubscribeURL is a web page to open
+	UnsubscribeURL UnsubscribeMethod = "url"
+)
+
+// UnsubscribeAction is a single way to unsubscribe from a mailing. FromBody
+// is set for links found in the body instead of the List-Unsubscribe header.
+type UnsubscribeAction struct {
+	Method   UnsubscribeMethod
+	URL      string
+	FromBody bool
+}
+
+var (
+	unsubscribeWords = regexp.MustCompile(`(?i)unsubscribe|opt[ -]?out|abmelden|abbestellen|d[ée]sabonner|d[ée]sinscri|darse de baja|cancelar suscripci|disiscriv`)
+	unsubscribeURL   = regexp.MustCompile(`(?i)(https?://|mailto:)[^\s<>"')\]]+`)
+)
+
+// UnsubscribeActions returns the ways to unsubscribe from the mailing the
+// email belongs to, ordered by preference: one-click POST URLs, mailto URIs,
+// then web pages. Actions from the List-Unsubscribe header come before links
+// found in the body by their wording.
+func (e *Email) UnsubscribeActions() (actions []UnsubscribeAction) {
+	seen := map[string]bool{}
+	add := func(uri string, fromBody bool) {
+		uri = strings.TrimSpace(uri)
+		lower := strings.ToLower(uri)
+		if uri == "" || seen[uri] {
+			return
+		}
+
+		action := UnsubscribeAction{URL: uri, FromBody: fromBody}
+		switch {
+		case strings.HasPrefix(lower, "mailto:"):
+			action.Method = UnsubscribeMailto
+		case strings.HasPrefix(lower, "https://") && !fromBody && isOneClick(e.Header.Get("List-Unsubscribe-Post")):
+			action.Method = UnsubscribeOneClick
+		case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"):
+			action.Method = UnsubscribeURL
+		default:
+			return
+		}
+
+		seen[uri] = true
+		actions = append(actions, action)
+	}
+
+	for _, field := range e.Header["List-Unsubscribe"] {
+		for _, uri := range strings.Split(field, ",") {
+			uri = strings.TrimSpace(uri)
+			if strings.HasPrefix(uri, "<") && strings.HasSuffix(uri, ">") {
+				add(uri[1:len(uri)-1], false)
+			}
+		}
+	}
+
+	for _, link := range unsubscribeLinksHTML(e.HTMLBody) {
+		add(link, true)
+	}
+
+	for _, link := range unsubscribeLinksText(e.TextBody) {
+		add(link, true)
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		if actions[i].FromBody != actions[j].FromBody {
+			return !actions[i].FromBody
+		}
+
+		return unsubscribeRank(actions[i].Method) < unsubscribeRank(actions[j].Method)
+	})
+
+	return
+}
+
+func isOneClick(post string) bool {
+	return strings.EqualFold(strings.Replace(post, " ", "", -1), "List-Unsubscribe=One-Click")
+}
+
+func unsubscribeRank(m UnsubscribeMethod) int {
+	switch m {
+	case UnsubscribeOneClick:
+		return 0
+	case UnsubscribeMailto:
+		return 1
+	}
+
+	return 2
+}
+
+// unsubscribeLinksHTML returns the targets of the anchors of an html body
+// whose target or text reads like an unsubscribe link
+func unsubscribeLinksHTML(body string) (links []string) {
+	var href string
+	var text strings.Builder
+	inAnchor := false
+
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "a" {
+				continue
+			}
+
+			inAnchor, href = true, ""
+			text.Reset()
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if string(key) == "href" {
+					href = string(val)
+				}
+			}
+		case html.TextToken:
+			if inAnchor {
+				text.Write(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) == "a" && inAnchor {
+				inAnchor = false
+				if unsubscribeWords.MatchString(href) || unsubscribeWords.MatchString(text.String()) {
+					links = append(links, href)
+				}
+			}
+		}
+	}
+}
+
+// unsubscribeLinksText returns the URLs of a text body found in or right
+// after a line mentioning unsubscribing
+func unsubscribeLinksText(body string) (links []string) {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if !unsubscribeWords.MatchString(line) {
+			continue
+		}
+
+		candidates := unsubscribeURL.FindAllString(line, -1)
+		if len(candidates) == 0 && i+1 < len(lines) {
+			candidates = unsubscribeURL.FindAllString(lines[i+1], -1)
+		}
+
+		links = append(links, candidates...)
+	}
+
+	return
+}
@@ -0,0 +1,81 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnsubscribeActions(t *testing.T) {
+	var testData = map[int]struct {
+		mailData string
+		actions  []UnsubscribeAction
+	}{
+		1: {
+			mailData: newsletter,
+			actions: []UnsubscribeAction{
+				{Method: UnsubscribeOneClick, URL: "https://news.example.com/u/1?one-click"},
+				{Method: UnsubscribeMailto, URL: "mailto:unsub@news.example.com?subject=unsubscribe"},
+				{Method: UnsubscribeURL, URL: "https://news.example.com/preferences", FromBody: true},
+				{Method: UnsubscribeURL, URL: "https://news.example.com/optout", FromBody: true},
+			},
+		},
+		2: {
+			mailData: strings.Replace(newsletter, "List-Unsubscribe-Post: List-Unsubscribe=One-Click\n", "", 1),
+			actions: []UnsubscribeAction{
+				{Method: UnsubscribeMailto, URL: "mailto:unsub@news.example.com?subject=unsubscribe"},
+				{Method: UnsubscribeURL, URL: "https://news.example.com/u/1?one-click"},
+				{Method: UnsubscribeURL, URL: "https://news.example.com/preferences", FromBody: true},
+				{Method: UnsubscribeURL, URL: "https://news.example.com/optout", FromBody: true},
+			},
+		},
+		3: {
+			mailData: keywordsAndComments,
+			actions:  nil,
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader(td.mailData))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		actions := e.UnsubscribeActions()
+		if len(actions) != len(td.actions) {
+			t.Errorf("[Test Case %v] Wrong number of actions. Expected: %+v, Got: %+v", index, td.actions, actions)
+			continue
+		}
+
+		for i := range actions {
+			if actions[i] != td.actions[i] {
+				t.Errorf("[Test Case %v] Wrong action %v. Expected: %+v, Got: %+v", index, i, td.actions[i], actions[i])
+			}
+		}
+	}
+}
+
+var newsletter = `From: News <news@news.example.com>
+To: Mary Smith <mary@example.net>
+Subject: This week
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+List-Unsubscribe: <mailto:unsub@news.example.com?subject=unsubscribe>,
+ <https://news.example.com/u/1?one-click>
+List-Unsubscribe-Post: List-Unsubscribe=One-Click
+Content-Type: multipart/alternative; boundary="ALT"
+
+--ALT
+Content-Type: text/plain
+
+This week's news at https://news.example.com/week
+
+To opt out of these mails visit
+https://news.example.com/optout
+--ALT
+Content-Type: text/html
+
+<p>This week's <a href="https://news.example.com/week">news</a>.</p>
+<p><a href="https://news.example.com/preferences">Unsubscribe</a> or
+<a href="https://news.example.com/u/1?one-click">leave</a></p>
+--ALT--
+`
@@ -0,0 +1,141 @@
+package parsemail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// uuBegin matches the line starting a uuencoded block, "begin 644 name"
+var uuBegin = regexp.MustCompile(`^begin [0-7]{3,4} (.+)$`)
+
+// extractUUEncoded removes the uuencoded blocks of the plain text body of a
+// message without MIME, as sent by legacy systems, and adds them as
+// attachments. Blocks that don't decode are left in the text.
+func (p *parser) extractUUEncoded(text []byte) []byte {
+	if !bytes.Contains(text, []byte("begin ")) {
+		return text
+	}
+
+	lines := bytes.SplitAfter(text, []byte("\n"))
+
+	var kept []byte
+	for i := 0; i < len(lines); i++ {
+		m := uuBegin.FindSubmatch(bytes.TrimRight(lines[i], "\r\n"))
+		if m == nil {
+			kept = append(kept, lines[i]...)
+			continue
+		}
+
+		data, end, ok := uudecode(lines[i+1:])
+		if !ok {
+			kept = append(kept, lines[i]...)
+			continue
+		}
+
+		filename := path.Base(strings.Replace(strings.TrimSpace(string(m[1])), `\`, "/", -1))
+		contentType, _, err := mime.ParseMediaType(mime.TypeByExtension(path.Ext(filename)))
+		if err != nil {
+			contentType = "application/octet-stream"
+		}
+
+		p.addAttachment(Attachment{Filename: filename, ContentType: contentType, Data: bytes.NewReader(data)})
+		i += end
+	}
+
+	return kept
+}
+
+// uudecode decodes the lines following a begin line up to the end line. end
+// is the number of lines consumed, end line included. ok is false if the
+// lines aren't uuencoded or the end line is missing.
+func uudecode(lines [][]byte) (data []byte, end int, ok bool) {
+	for i, line := range lines {
+		line = bytes.TrimRight(line, "\r\n")
+		if string(line) == "end" {
+			return data, i + 1, true
+		}
+		if len(line) == 0 || line[0] < ' ' || line[0] > '`' {
+			return nil, 0, false
+		}
+
+		n := int(uuChar(line[0]))
+		if n > 45 {
+			return nil, 0, false
+		}
+
+		// encoders may strip trailing spaces, which encode zero bits
+		groups := line[1:]
+		if need := (n + 2) / 3 * 4; len(groups) < need {
+			groups = append(append([]byte(nil), groups...), bytes.Repeat([]byte(" "), need-len(groups))...)
+		}
+
+		decoded := make([]byte, 0, n+2)
+		for j := 0; j+4 <= len(groups) && len(decoded) < n; j += 4 {
+			var c [4]byte
+			for k := range c {
+				if groups[j+k] < ' ' || groups[j+k] > '`' {
+					return nil, 0, false
+				}
+				c[k] = uuChar(groups[j+k])
+			}
+			decoded = append(decoded, c[0]<<2|c[1]>>4, c[1]<<4|c[2]>>2, c[2]<<6|c[3])
+		}
+		data = append(data, decoded[:n]...)
+	}
+
+	return nil, 0, false
+}
+
+// uuChar returns the 6 bits encoded by a character; "`" encodes zero like
+// the space
+func uuChar(c byte) byte {
+	return (c - ' ') & 63
+}
+
+// uuReader decodes a part with the x-uuencode transfer encoding on its
+// first read. The begin line is optional, as some gateways omit it.
+type uuReader struct {
+	r       io.Reader
+	decoded *bytes.Reader
+	err     error
+}
+
+func (u *uuReader) Read(b []byte) (int, error) {
+	if u.decoded == nil && u.err == nil {
+		var encoded []byte
+		if encoded, u.err = ioutil.ReadAll(u.r); u.err != nil {
+			return 0, u.err
+		}
+
+		lines := bytes.SplitAfter(encoded, []byte("\n"))
+		for i, l := range lines {
+			if uuBegin.Match(bytes.TrimRight(l, "\r\n")) {
+				lines = lines[i+1:]
+				break
+			}
+		}
+
+		for len(lines) > 0 && len(bytes.TrimSpace(lines[0])) == 0 {
+			lines = lines[1:]
+		}
+
+		data, _, ok := uudecode(lines)
+		if !ok {
+			u.err = errors.New("malformed uuencoded content")
+			return 0, u.err
+		}
+		u.decoded = bytes.NewReader(data)
+	}
+
+	if u.err != nil {
+		return 0, u.err
+	}
+
+	return u.decoded.Read(b)
+}
@@ -0,0 +1,154 @@
+package parsemail
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// uuencode encodes data as the lines of a uuencoded block
+func uuencode(data []byte) string {
+	var sb strings.Builder
+	for len(data) > 0 {
+		n := minInt(len(data), 45)
+		chunk := append([]byte(nil), data[:n]...)
+		for len(chunk)%3 != 0 {
+			chunk = append(chunk, 0)
+		}
+
+		sb.WriteByte(byte(' ' + n))
+		for i := 0; i < len(chunk); i += 3 {
+			for _, c := range []byte{chunk[i] >> 2, (chunk[i]<<4 | chunk[i+1]>>4) & 63, (chunk[i+1]<<2 | chunk[i+2]>>6) & 63, chunk[i+2] & 63} {
+				if c == 0 {
+					sb.WriteByte('`')
+				} else {
+					sb.WriteByte(' ' + c)
+				}
+			}
+		}
+		sb.WriteString("\r\n")
+		data = data[n:]
+	}
+
+	return sb.String() + "`\r\nend\r\n"
+}
+
+func TestUUEncodedAttachments(t *testing.T) {
+	report := []byte(strings.Repeat("%PDF-1.4 quarterly report\x00\x01\xff", 5))
+	notes := []byte("meeting notes")
+
+	var testData = map[int]struct {
+		body        string
+		textBody    string
+		attachments map[string]string
+	}{
+		1: {
+			body:        "Please find the report attached.\r\n\r\nbegin 644 report.pdf\r\n" + uuencode(report) + "\r\nRegards\r\n",
+			textBody:    "Please find the report attached.\r\n\r\n\r\nRegards\r",
+			attachments: map[string]string{"report.pdf application/pdf": string(report)},
+		},
+		2: {
+			body:        "begin 600 C:\\Temp\\notes.dat\r\n" + uuencode(notes) + "begin 644 report.pdf\r\n" + uuencode(report),
+			textBody:    "",
+			attachments: map[string]string{"notes.dat application/octet-stream": string(notes), "report.pdf application/pdf": string(report)},
+		},
+		3: {
+			body:     "begin 644 broken.bin\r\nthis is not uuencoded\r\nend\r\n",
+			textBody: "begin 644 broken.bin\r\nthis is not uuencoded\r\nend\r",
+		},
+		4: {
+			body:     "begin 644 cut.bin\r\n" + strings.TrimSuffix(uuencode(notes), "end\r\n"),
+			textBody: "begin 644 cut.bin\r\n" + strings.TrimSuffix(uuencode(notes), "\nend\r\n"),
+		},
+		5: {
+			// trailing spaces stripped in transit
+			body:        "begin 644 notes.txt\r\n" + strings.Replace(uuencode([]byte("abc   ")), "`", " ", -1),
+			attachments: map[string]string{"notes.txt text/plain": "abc   "},
+		},
+	}
+
+	for index, td := range testData {
+		e, err := Parse(strings.NewReader("From: a@example.com\r\nSubject: Files\r\n\r\n" + td.body))
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if e.TextBody != td.textBody {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q", index, td.textBody, e.TextBody)
+		}
+
+		if len(e.Attachments) != len(td.attachments) {
+			t.Errorf("[Test Case %v] Incorrect number of attachments! Expected: %v, Got: %v.", index, len(td.attachments), len(e.Attachments))
+		}
+		for _, a := range e.Attachments {
+			data, _ := ioutil.ReadAll(a.Data)
+			if expected, ok := td.attachments[a.Filename+" "+a.ContentType]; !ok || string(data) != expected {
+				t.Errorf("[Test Case %v] Unexpected attachment %s %s: %q", index, a.Filename, a.ContentType, data)
+			}
+		}
+	}
+}
+
+func TestUUEncodedMIMEText(t *testing.T) {
+	body := "begin 644 notes.txt\r\n" + uuencode([]byte("meeting notes"))
+
+	var testData = map[int]struct {
+		header  string
+		version int
+	}{
+		1: {header: "MIME-Version: 1.0\r\nContent-Type: text/plain\r\n"},
+		2: {header: "Content-Type: text/plain; charset=us-ascii\r\n"},
+		3: {header: "MIME-Version: 1.0\r\n"},
+		4: {version: 5},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader("From: a@example.com\r\n"+td.header+"\r\n"+body), Options{SchemaVersion: td.version})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if expected := strings.TrimSuffix(body, "\n"); e.TextBody != expected || len(e.Attachments) != 0 {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %q, Got: %q with %d attachments", index, expected, e.TextBody, len(e.Attachments))
+		}
+	}
+}
+
+func TestUUEncodeTransferEncoding(t *testing.T) {
+	report := []byte("%PDF-1.4\x00\x01\x02 quarterly report")
+
+	raw := "From: a@example.com\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain\r\nContent-Transfer-Encoding: binary\r\n\r\nSee attached\r\n" +
+		"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=a.pdf\r\nContent-Transfer-Encoding: x-uuencode\r\n\r\nbegin 644 a.pdf\r\n" + uuencode(report) +
+		"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=b.pdf\r\nContent-Transfer-Encoding: UUENCODE\r\n\r\n\r\n" + uuencode(report) +
+		"--b--\r\n"
+
+	e, err := Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.TextBody != "See attached" {
+		t.Errorf("Wrong text body. Expected: %q, Got: %q", "See attached", e.TextBody)
+	}
+	if len(e.Attachments) != 2 {
+		t.Fatalf("Incorrect number of attachments! Expected: 2, Got: %v.", len(e.Attachments))
+	}
+	for _, a := range e.Attachments {
+		if data, err := ioutil.ReadAll(a.Data); err != nil || string(data) != string(report) {
+			t.Errorf("Wrong data of %s. Expected: %q, Got: %q (%v)", a.Filename, report, data, err)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader("From: a@example.com\r\nContent-Transfer-Encoding: x-uuencode\r\n\r\nnot uuencoded\r\n")); err == nil {
+		t.Error("Expected an error for malformed uuencoded content")
+	}
+
+	// schema versions before 6 don't know the encodings
+	for _, encoding := range []string{"binary", "x-uuencode"} {
+		_, err := ParseWithOptions(strings.NewReader("From: a@example.com\r\nContent-Transfer-Encoding: "+encoding+"\r\n\r\nHello\r\n"), Options{SchemaVersion: 5})
+		if pe, ok := err.(*ParseError); !ok || pe.Err != ErrUnknownEncoding {
+			t.Errorf("Wrong error for %s. Expected: %v, Got: %v", encoding, ErrUnknownEncoding, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package parsemail
+
+import (
+	"bytes"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Voicemail holds the metadata of a voicemail notification and its audio
+type Voicemail struct {
+	System     string
+	CallerID   string
+	CallerName string
+	Duration   time.Duration
+	Codec      string
+	Audio      *Attachment
+}
+
+// Voicemail detects voicemail notifications by the headers of common PBX
+// systems (Asterisk, Exchange Unified Messaging), a voice Content-Class or an
+// audio part carrying a Content-Duration as used by VPIM. It returns nil if
+// the email is not a voicemail. VPIM parts are only looked at in the original
+// message, so they are detected with Options.KeepRaw only.
+func (e *Email) Voicemail() *Voicemail {
+	h := e.Header
+	vm := Voicemail{}
+
+	switch {
+	case h.Get("X-Asterisk-Vm-Message-Num") != "" || h.Get("X-Asterisk-Callerid") != "":
+		vm.System = "asterisk"
+		vm.CallerID = h.Get("X-Asterisk-Callerid")
+		vm.CallerName = h.Get("X-Asterisk-Calleridname")
+		vm.Duration = parseSeconds(h.Get("X-Asterisk-Vm-Duration"))
+	case h.Get("X-Voicemessageduration") != "" || h.Get("X-Callingtelephonenumber") != "":
+		vm.System = "exchange"
+		vm.CallerID = h.Get("X-Callingtelephonenumber")
+		vm.CallerName = h.Get("X-Voicemessagesendername")
+		vm.Duration = parseSeconds(h.Get("X-Voicemessageduration"))
+	case h.Get("X-Voicemail") != "" || strings.HasPrefix(strings.ToLower(h.Get("Content-Class")), "voice"):
+		vm.System = "generic"
+	}
+
+	if vm.Duration == 0 {
+		vm.Duration = parseSeconds(h.Get("Content-Duration"))
+	}
+
+	// VPIM puts the duration on the audio part, whose multipart/voice-message
+	// container isn't decoded by Parse
+	partDuration := false
+	var rawAudio *Attachment
+	raw, _ := e.rawMessage()
+	p := e.partParser()
+	p.walkRawParts(raw, func(header textproto.MIMEHeader, body []byte) {
+		contentType := header.Get("Content-Type")
+		if !strings.HasPrefix(strings.ToLower(contentType), "audio/") {
+			return
+		}
+
+		if rawAudio == nil {
+			if data, err := p.decodeRawBody(header, body); err == nil {
+				mediaType, params, _ := parseMediaType(contentType)
+				_, dispParams, _ := parseMediaType(header.Get("Content-Disposition"))
+				rawAudio = &Attachment{
+					Filename:       decodeMimeSentenceVersion(dispParams["filename"], e.SchemaVersion),
+					ContentType:    mediaType,
+					Params:         params,
+					RawContentType: contentType,
+					Data:           bytes.NewReader(data),
+				}
+			}
+		}
+
+		if d := header.Get("Content-Duration"); d != "" {
+			partDuration = true
+			if vm.Duration == 0 {
+				vm.Duration = parseSeconds(d)
+			}
+		}
+	})
+
+	if vm.System == "" {
+		if !partDuration {
+			return nil
+		}
+		vm.System = "vpim"
+	}
+
+	vm.Audio = e.audioPart()
+	if vm.Audio == nil {
+		vm.Audio = rawAudio
+	}
+	if vm.Audio != nil {
+		vm.Codec = audioCodec(vm.Audio)
+	}
+
+	return &vm
+}
+
+// audioPart returns the first audio attachment or embedded file
+func (e *Email) audioPart() *Attachment {
+	for i := range e.Attachments {
+		if strings.HasPrefix(strings.ToLower(e.Attachments[i].ContentType), "audio/") {
+			return &e.Attachments[i]
+		}
+	}
+
+	for _, ef := range e.EmbeddedFiles {
+		if strings.HasPrefix(strings.ToLower(ef.ContentType), "audio/") {
+			return &Attachment{
+				Filename:        ef.Filename,
+				ContentType:     ef.ContentType,
+				Params:          ef.Params,
+				RawContentType:  ef.RawContentType,
+				ContentLanguage: ef.ContentLanguage,
+				Data:            ef.Data,
+			}
+		}
+	}
+
+	return nil
+}
+
+// audioCodec returns the codecs parameter of the audio content type or its
+// subtype without the x- prefix
+func audioCodec(a *Attachment) string {
+	if c := a.Params["codecs"]; c != "" {
+		return c
+	}
+
+	subtype := strings.ToLower(a.ContentType[strings.Index(a.ContentType, "/")+1:])
+
+	return strings.TrimPrefix(subtype, "x-")
+}
+
+// parseSeconds parses a duration given in whole seconds, like "37", or as
+// hours, minutes and seconds, like "0:00:37"
+func parseSeconds(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+
+	seconds := 0
+	for _, field := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,121 @@
+package parsemail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVoicemail(t *testing.T) {
+	var testData = map[int]struct {
+		mailData  string
+		voicemail bool
+		system    string
+		callerID  string
+		duration  time.Duration
+		codec     string
+		audioFile string
+	}{
+		1: {asteriskVoicemail, true, "asterisk", "\"Jane\" <5551234>", 37 * time.Second, "wav", "msg0001.wav"},
+		2: {vpimVoicemail, true, "vpim", "", 12 * time.Second, "32kadpcm", ""},
+		3: {audioAttachment, false, "", "", 0, "", ""},
+	}
+
+	for index, td := range testData {
+		e, err := ParseWithOptions(strings.NewReader(td.mailData), Options{KeepRaw: true})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		vm := e.Voicemail()
+		if (vm != nil) != td.voicemail {
+			t.Errorf("[Test Case %v] Wrong voicemail detection. Expected: %v, Got: %v", index, td.voicemail, vm != nil)
+			continue
+		}
+
+		if vm == nil {
+			continue
+		}
+
+		if vm.System != td.system {
+			t.Errorf("[Test Case %v] Wrong system. Expected: %s, Got: %s", index, td.system, vm.System)
+		}
+
+		if vm.CallerID != td.callerID {
+			t.Errorf("[Test Case %v] Wrong caller id. Expected: %s, Got: %s", index, td.callerID, vm.CallerID)
+		}
+
+		if vm.Duration != td.duration {
+			t.Errorf("[Test Case %v] Wrong duration. Expected: %s, Got: %s", index, td.duration, vm.Duration)
+		}
+
+		if vm.Codec != td.codec {
+			t.Errorf("[Test Case %v] Wrong codec. Expected: %s, Got: %s", index, td.codec, vm.Codec)
+		}
+
+		if vm.Audio == nil {
+			t.Errorf("[Test Case %v] Missing audio", index)
+		} else if vm.Audio.Filename != td.audioFile {
+			t.Errorf("[Test Case %v] Wrong audio filename. Expected: %s, Got: %s", index, td.audioFile, vm.Audio.Filename)
+		}
+	}
+}
+
+var asteriskVoicemail = `From: Asterisk PBX <asterisk@pbx.example>
+To: Mary Smith <mary@example.net>
+Subject: New message 1 in mailbox 200
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+X-Asterisk-CallerID: "Jane" <5551234>
+X-Asterisk-CallerIDName: Jane
+X-Asterisk-VM-Message-Num: 1
+X-Asterisk-VM-Duration: 37
+Content-Type: multipart/mixed; boundary="VM"
+
+--VM
+Content-Type: text/plain; charset=UTF-8
+
+You have a new voicemail.
+--VM
+Content-Type: audio/x-wav; name="msg0001.wav"
+Content-Transfer-Encoding: base64
+Content-Disposition: attachment; filename="msg0001.wav"
+
+UklGRg==
+--VM--
+`
+
+var vpimVoicemail = `From: Unity <vm@pbx.example>
+To: Mary Smith <mary@example.net>
+Subject: Voice message
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+MIME-Version: 1.0
+Content-Type: multipart/voice-message; boundary="VP"; version=2.0
+
+--VP
+Content-Type: audio/32kadpcm
+Content-Transfer-Encoding: base64
+Content-Duration: 12
+
+AAEC
+--VP--
+`
+
+var audioAttachment = `From: John Doe <jdoe@machine.example>
+To: Mary Smith <mary@example.net>
+Subject: Our song
+Date: Fri, 21 Nov 1997 09:55:06 -0600
+Content-Type: multipart/mixed; boundary="MIX"
+
+--MIX
+Content-Type: text/plain
+
+Listen to this.
+--MIX
+Content-Type: audio/mpeg; name="song.mp3"
+Content-Transfer-Encoding: base64
+Content-Disposition: attachment; filename="song.mp3"
+
+AAEC
+--MIX--
+`
@@ -0,0 +1,411 @@
+package parsemail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	cs "golang.org/x/net/html/charset"
+)
+
+// maxWebhookMemory is the size of a webhook form kept in memory, larger
+// files are stored in temporary files while parsing
+const maxWebhookMemory = 32 << 20
+
+// maxWebhookBody is the size of a request body WebhookHandler accepts
+const maxWebhookBody = 64 << 20
+
+// maxSESNotification is the size of an SES notification read. SNS limits
+// messages to 256 KiB and SES publishes the content of messages up to
+// 150 KiB only, so larger notifications aren't SES ones.
+const maxSESNotification = 1 << 20
+
+// ParseWebhook parses the request of an inbound parse webhook of SendGrid,
+// Mailgun or Amazon SES, telling the provider from the form fields or the
+// SNS notification, into an email and its envelope. Applications switch
+// providers without changing their mail handling this way.
+func ParseWebhook(r *http.Request, opts Options) (Email, ReceiveEnvelope, error) {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct != "multipart/form-data" && ct != "application/x-www-form-urlencoded" {
+		return ParseSESNotification(r.Body, opts)
+	}
+
+	if err := parseWebhookForm(r); err != nil {
+		return Email{}, ReceiveEnvelope{}, err
+	}
+
+	switch {
+	case hasFormValue(r, "email", "headers"):
+		return ParseSendGridWebhook(r, opts)
+	case hasFormValue(r, "body-mime", "message-headers"):
+		return ParseMailgunWebhook(r, opts)
+	}
+
+	return Email{}, ReceiveEnvelope{}, errors.New("webhook: unknown inbound parse format")
+}
+
+// ParseSendGridWebhook parses a request of the SendGrid Inbound Parse
+// webhook, with or without the raw message
+func ParseSendGridWebhook(r *http.Request, opts Options) (e Email, env ReceiveEnvelope, err error) {
+	if err = parseWebhookForm(r); err != nil {
+		return
+	}
+
+	var envelope struct {
+		From string   `json:"from"`
+		To   []string `json:"to"`
+	}
+	if v := r.FormValue("envelope"); v != "" {
+		if err = json.Unmarshal([]byte(v), &envelope); err != nil {
+			err = fmt.Errorf("sendgrid: envelope: %v", err)
+			return
+		}
+	}
+	env = ReceiveEnvelope{From: envelope.From, Recipients: envelope.To}
+
+	if raw := r.FormValue("email"); raw != "" {
+		e, err = ParseWithOptions(strings.NewReader(raw), opts)
+		return
+	}
+
+	var charsets map[string]string
+	if v := r.FormValue("charsets"); v != "" {
+		if err = json.Unmarshal([]byte(v), &charsets); err != nil {
+			err = fmt.Errorf("sendgrid: charsets: %v", err)
+			return
+		}
+	}
+
+	var info map[string]struct {
+		Filename  string `json:"filename"`
+		Type      string `json:"type"`
+		ContentID string `json:"content-id"`
+	}
+	if v := r.FormValue("attachment-info"); v != "" {
+		if err = json.Unmarshal([]byte(v), &info); err != nil {
+			err = fmt.Errorf("sendgrid: attachment info: %v", err)
+			return
+		}
+	}
+
+	body := Email{
+		TextBody: webhookText(r.FormValue("text"), charsets["text"]),
+		HTMLBody: webhookText(r.FormValue("html"), charsets["html"]),
+	}
+
+	n, _ := strconv.Atoi(r.FormValue("attachments"))
+	for i := 1; i <= n; i++ {
+		key := "attachment" + strconv.Itoa(i)
+		fi := info[key]
+		if err = addWebhookFile(&body, r, key, fi.Filename, fi.Type, fi.ContentID); err != nil {
+			return
+		}
+	}
+
+	raw, err := assembleWebhookMessage(splitHeaderFields([]byte(r.FormValue("headers"))), &body)
+	if err != nil {
+		return
+	}
+
+	e, err = ParseWithOptions(bytes.NewReader(raw), opts)
+
+	return
+}
+
+// ParseMailgunWebhook parses a request of a Mailgun route forwarding
+// messages, to a URL ending in "mime" for the raw message or parsed
+func ParseMailgunWebhook(r *http.Request, opts Options) (e Email, env ReceiveEnvelope, err error) {
+	if err = parseWebhookForm(r); err != nil {
+		return
+	}
+
+	env = ReceiveEnvelope{From: r.FormValue("sender")}
+	for _, to := range strings.Split(r.FormValue("recipient"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			env.Recipients = append(env.Recipients, to)
+		}
+	}
+
+	if raw := r.FormValue("body-mime"); raw != "" {
+		e, err = ParseWithOptions(strings.NewReader(raw), opts)
+		return
+	}
+
+	var fields [][]string
+	if err = json.Unmarshal([]byte(r.FormValue("message-headers")), &fields); err != nil {
+		err = fmt.Errorf("mailgun: message headers: %v", err)
+		return
+	}
+
+	var header bytes.Buffer
+	for _, f := range fields {
+		if len(f) == 2 {
+			header.WriteString(f[0] + ": " + f[1] + "\r\n")
+		}
+	}
+
+	// inline files are mapped from their content id to their form field
+	cids := map[string]string{}
+	if v := r.FormValue("content-id-map"); v != "" {
+		var m map[string]string
+		if err = json.Unmarshal([]byte(v), &m); err != nil {
+			err = fmt.Errorf("mailgun: content id map: %v", err)
+			return
+		}
+		for cid, key := range m {
+			cids[key] = cid
+		}
+	}
+
+	body := Email{TextBody: r.FormValue("body-plain"), HTMLBody: r.FormValue("body-html")}
+
+	n, _ := strconv.Atoi(r.FormValue("attachment-count"))
+	for i := 1; i <= n; i++ {
+		key := "attachment-" + strconv.Itoa(i)
+		if err = addWebhookFile(&body, r, key, "", "", cids[key]); err != nil {
+			return
+		}
+	}
+
+	raw, err := assembleWebhookMessage(splitHeaderFields(header.Bytes()), &body)
+	if err != nil {
+		return
+	}
+
+	e, err = ParseWithOptions(bytes.NewReader(raw), opts)
+
+	return
+}
+
+// SNSSubscriptionError is returned for the subscription confirmation SNS
+// sends before the first notification. The subscription is confirmed by
+// requesting SubscribeURL.
+type SNSSubscriptionError struct {
+	SubscribeURL string
+}
+
+func (e *SNSSubscriptionError) Error() string {
+	return "ses: SNS subscription confirmation, confirm with " + e.SubscribeURL
+}
+
+// ParseSESNotification parses an Amazon SES receipt notification, as
+// published to SNS by an SNS receipt rule action, from the body of the SNS
+// request or as the bare notification. The signature of the SNS message
+// isn't verified, which is up to the caller. Notifications larger than
+// 1 MiB are rejected.
+func ParseSESNotification(r io.Reader, opts Options) (e Email, env ReceiveEnvelope, err error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxSESNotification+1))
+	if err != nil {
+		err = fmt.Errorf("ses: %v", err)
+		return
+	}
+	if len(data) > maxSESNotification {
+		err = fmt.Errorf("ses: notification exceeds %d bytes", maxSESNotification)
+		return
+	}
+
+	var sns struct {
+		Type         string
+		Message      string
+		SubscribeURL string
+	}
+	if err = json.Unmarshal(data, &sns); err != nil {
+		err = fmt.Errorf("ses: %v", err)
+		return
+	}
+
+	switch sns.Type {
+	case "SubscriptionConfirmation":
+		err = &SNSSubscriptionError{SubscribeURL: sns.SubscribeURL}
+		return
+	case "Notification":
+		data = []byte(sns.Message)
+	}
+
+	var n struct {
+		NotificationType string `json:"notificationType"`
+		Mail             struct {
+			Source      string   `json:"source"`
+			Destination []string `json:"destination"`
+		} `json:"mail"`
+		Receipt struct {
+			Action struct {
+				Type       string `json:"type"`
+				Encoding   string `json:"encoding"`
+				BucketName string `json:"bucketName"`
+				ObjectKey  string `json:"objectKey"`
+			} `json:"action"`
+		} `json:"receipt"`
+		Content string `json:"content"`
+	}
+	if err = json.Unmarshal(data, &n); err != nil {
+		err = fmt.Errorf("ses: notification: %v", err)
+		return
+	}
+
+	if n.NotificationType != "Received" {
+		err = fmt.Errorf("ses: unexpected notification type %q", n.NotificationType)
+		return
+	}
+
+	env = ReceiveEnvelope{From: n.Mail.Source, Recipients: n.Mail.Destination}
+
+	action := n.Receipt.Action
+	if n.Content == "" {
+		if action.Type == "S3" {
+			err = fmt.Errorf("ses: message stored in S3 object %s/%s", action.BucketName, action.ObjectKey)
+		} else {
+			err = errors.New("ses: notification without message content")
+		}
+		return
+	}
+
+	raw := []byte(n.Content)
+	if strings.EqualFold(action.Encoding, "BASE64") {
+		if raw, err = base64.StdEncoding.DecodeString(n.Content); err != nil {
+			err = fmt.Errorf("ses: content: %v", err)
+			return
+		}
+	}
+
+	e, err = ParseWithOptions(bytes.NewReader(raw), opts)
+
+	return
+}
+
+// WebhookHandler returns an http.Handler receiving inbound parse webhooks
+// with ParseWebhook and delivering their messages to handler, like a
+// Receiver does. Requests that don't parse are answered with 400 Bad
+// Request, messages the handler fails with 500 Internal Server Error, so
+// the provider retries them. Request bodies are limited to 64 MiB.
+func WebhookHandler(handler ReceiveHandler, opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxWebhookBody)
+
+		e, env, err := ParseWebhook(r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := handler(&env, &e); err != nil {
+			http.Error(w, "delivery failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// parseWebhookForm parses a multipart or url-encoded form
+func parseWebhookForm(r *http.Request) error {
+	if err := r.ParseMultipartForm(maxWebhookMemory); err != nil && err != http.ErrNotMultipart {
+		return fmt.Errorf("webhook: %v", err)
+	}
+
+	return nil
+}
+
+// hasFormValue reports whether the form has one of the keys
+func hasFormValue(r *http.Request, keys ...string) bool {
+	for _, k := range keys {
+		if _, ok := r.Form[k]; ok {
+			return true
+		}
+		if r.MultipartForm != nil {
+			if _, ok := r.MultipartForm.Value[k]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// webhookText converts a body sent in the given charset to UTF-8
+func webhookText(s, charset string) string {
+	enc, _ := cs.Lookup(charset)
+	if enc == nil {
+		return s
+	}
+
+	decoded, err := enc.NewDecoder().String(s)
+	if err != nil {
+		return s
+	}
+
+	return decoded
+}
+
+// addWebhookFile adds the file uploaded as the form field key to body, as
+// an embedded file if it has a content id. The filename and content type
+// default to those of the upload.
+func addWebhookFile(body *Email, r *http.Request, key, filename, contentType, cid string) error {
+	if r.MultipartForm == nil || len(r.MultipartForm.File[key]) == 0 {
+		return fmt.Errorf("webhook: missing file %s", key)
+	}
+
+	fh := r.MultipartForm.File[key][0]
+	data, err := readWebhookFile(fh)
+	if err != nil {
+		return err
+	}
+
+	if filename == "" {
+		filename = fh.Filename
+	}
+	if contentType == "" {
+		contentType = fh.Header.Get("Content-Type")
+	}
+	contentType, params, _ := mime.ParseMediaType(contentType)
+
+	if cid != "" {
+		body.EmbeddedFiles = append(body.EmbeddedFiles, EmbeddedFile{
+			CID:         strings.Trim(cid, "<>"),
+			Filename:    filename,
+			ContentType: contentType,
+			Params:      params,
+			Data:        bytes.NewReader(data),
+		})
+	} else {
+		body.Attachments = append(body.Attachments, Attachment{
+			Filename:    filename,
+			ContentType: contentType,
+			Params:      params,
+			Data:        bytes.NewReader(data),
+		})
+	}
+
+	return nil
+}
+
+func readWebhookFile(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(f)
+}
+
+// assembleWebhookMessage writes a message of the header fields sent by the
+// provider and the MIME structure of the bodies and files of body, so it
+// parses like the original message
+func assembleWebhookMessage(fields []HeaderField, body *Email) ([]byte, error) {
+	part, err := body.mimeBody()
+	if err != nil {
+		return nil, err
+	}
+
+	return assembleMessage(fields, part)
+}
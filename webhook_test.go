@@ -0,0 +1,203 @@
+package parsemail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const webhookMessage = "From: Alice <alice@example.org>\r\nTo: support@example.net\r\nSubject: Broken printer\r\n\r\nIt jams.\r\n"
+
+// webhookRequest returns a multipart form request of the fields and of the
+// files given as field name, filename, content type and content
+func webhookRequest(t *testing.T, fields map[string]string, files ...[4]string) *http.Request {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for _, f := range files {
+		h := make(map[string][]string)
+		h["Content-Disposition"] = []string{`form-data; name="` + f[0] + `"; filename="` + f[1] + `"`}
+		h["Content-Type"] = []string{f[2]}
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f[3]))
+	}
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/inbound", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return r
+}
+
+func sesNotification(t *testing.T, content string) string {
+	message, err := json.Marshal(map[string]interface{}{
+		"notificationType": "Received",
+		"mail":             map[string]interface{}{"source": "alice@example.org", "destination": []string{"support@example.net"}},
+		"receipt":          map[string]interface{}{"action": map[string]string{"type": "SNS", "encoding": "BASE64"}},
+		"content":          base64.StdEncoding.EncodeToString([]byte(content)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sns, _ := json.Marshal(map[string]string{"Type": "Notification", "Message": string(message)})
+
+	return string(sns)
+}
+
+func TestParseWebhook(t *testing.T) {
+	latin1 := "Il bloque le caf\xe9."
+
+	var testData = map[int]struct {
+		request     *http.Request
+		text        string
+		attachments []string
+		embedded    []string
+	}{
+		1: {
+			request: webhookRequest(t, map[string]string{
+				"email":    webhookMessage,
+				"envelope": `{"to":["support@example.net"],"from":"alice@example.org"}`,
+			}),
+			text: "It jams.",
+		},
+		2: {
+			request: webhookRequest(t, map[string]string{
+				"headers":         "From: Alice <alice@example.org>\nTo: support@example.net\nSubject: Broken printer\nContent-Type: multipart/mixed; boundary=x\n",
+				"text":            latin1,
+				"html":            "<p>Jams <img src=\"cid:photo\"></p>",
+				"charsets":        `{"text":"iso-8859-1","html":"utf-8"}`,
+				"envelope":        `{"to":["support@example.net"],"from":"alice@example.org"}`,
+				"attachments":     "2",
+				"attachment-info": `{"attachment1":{"filename":"log.csv","type":"text/csv"},"attachment2":{"filename":"photo.jpg","type":"image/jpeg","content-id":"photo"}}`,
+			}, [4]string{"attachment1", "log.csv", "text/csv", "code\n42\n"}, [4]string{"attachment2", "photo.jpg", "image/jpeg", "\xff\xd8\xff"}),
+			text:        "Il bloque le café.",
+			attachments: []string{"log.csv"},
+			embedded:    []string{"photo"},
+		},
+		3: {
+			request: webhookRequest(t, map[string]string{
+				"body-mime": webhookMessage,
+				"recipient": "support@example.net",
+				"sender":    "alice@example.org",
+			}),
+			text: "It jams.",
+		},
+		4: {
+			request: webhookRequest(t, map[string]string{
+				"message-headers":  `[["From","Alice <alice@example.org>"],["To","support@example.net"],["Subject","Broken printer"],["Content-Type","multipart/mixed; boundary=\"x\""]]`,
+				"body-plain":       "It jams.",
+				"recipient":        "support@example.net",
+				"sender":           "alice@example.org",
+				"attachment-count": "1",
+			}, [4]string{"attachment-1", "report.pdf", "application/pdf", "%PDF"}),
+			text:        "It jams.",
+			attachments: []string{"report.pdf"},
+		},
+		5: {
+			request: httptest.NewRequest("POST", "/inbound", strings.NewReader(sesNotification(t, webhookMessage))),
+			text:    "It jams.",
+		},
+	}
+
+	for index, td := range testData {
+		e, env, err := ParseWebhook(td.request, Options{})
+		if err != nil {
+			t.Fatalf("[Test Case %v] %v", index, err)
+		}
+
+		if e.Subject != "Broken printer" || len(e.From) != 1 || e.From[0].Address != "alice@example.org" {
+			t.Errorf("[Test Case %v] Wrong header. Got: %s, %v", index, e.Subject, e.From)
+		}
+		if strings.TrimSpace(e.TextBody) != td.text {
+			t.Errorf("[Test Case %v] Wrong text body. Expected: %s, Got: %q", index, td.text, e.TextBody)
+		}
+		if env.From != "alice@example.org" || len(env.Recipients) != 1 || env.Recipients[0] != "support@example.net" {
+			t.Errorf("[Test Case %v] Wrong envelope. Got: %+v", index, env)
+		}
+
+		var attachments, embedded []string
+		for _, a := range e.Attachments {
+			attachments = append(attachments, a.Filename)
+		}
+		for _, ef := range e.EmbeddedFiles {
+			embedded = append(embedded, ef.CID)
+		}
+		if !assertSliceEq(attachments, td.attachments) || !assertSliceEq(embedded, td.embedded) {
+			t.Errorf("[Test Case %v] Wrong files. Expected: %v, %v, Got: %v, %v", index, td.attachments, td.embedded, attachments, embedded)
+		}
+	}
+}
+
+func TestSNSSubscription(t *testing.T) {
+	body := `{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.example.com/confirm"}`
+
+	_, _, err := ParseSESNotification(strings.NewReader(body), Options{})
+	if sub, ok := err.(*SNSSubscriptionError); !ok || sub.SubscribeURL != "https://sns.example.com/confirm" {
+		t.Errorf("Wrong error. Got: %v", err)
+	}
+}
+
+// endlessReader returns a JSON string that never ends
+type endlessReader struct {
+	started bool
+}
+
+func (r *endlessReader) Read(b []byte) (int, error) {
+	n := 0
+	if !r.started {
+		n = copy(b, `{"Type":"Notification","Message":"`)
+		r.started = true
+	}
+	for i := n; i < len(b); i++ {
+		b[i] = 'x'
+	}
+
+	return len(b), nil
+}
+
+func TestSESNotificationTooLarge(t *testing.T) {
+	_, _, err := ParseSESNotification(&endlessReader{}, Options{})
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("Wrong error. Got: %v", err)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	var delivered []string
+	handler := WebhookHandler(func(env *ReceiveEnvelope, e *Email) error {
+		delivered = append(delivered, e.Subject)
+		return nil
+	}, Options{})
+
+	var testData = map[int]struct {
+		request *http.Request
+		status  int
+	}{
+		1: {request: webhookRequest(t, map[string]string{"body-mime": webhookMessage}), status: http.StatusOK},
+		2: {request: webhookRequest(t, map[string]string{"unknown": "field"}), status: http.StatusBadRequest},
+		3: {request: httptest.NewRequest("POST", "/inbound", strings.NewReader(sesNotification(t, webhookMessage))), status: http.StatusOK},
+		4: {request: httptest.NewRequest("POST", "/inbound", &endlessReader{}), status: http.StatusBadRequest},
+	}
+
+	for index := 1; index <= len(testData); index++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, testData[index].request)
+		if w.Code != testData[index].status {
+			t.Errorf("[Test Case %v] Wrong status. Expected: %v, Got: %v", index, testData[index].status, w.Code)
+		}
+	}
+
+	if !assertSliceEq(delivered, []string{"Broken printer", "Broken printer"}) {
+		t.Errorf("Wrong delivered messages. Got: %v", delivered)
+	}
+}